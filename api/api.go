@@ -156,11 +156,19 @@ func maybeRunTaskInBackground(c *gin.Context, name string, resources []string, p
 	background := truthy(c.DefaultQuery("_async", strconv.FormatBool(context.Config().AsyncAPI)))
 	if background {
 		log.Debug().Msg("Executing task asynchronously")
+		state := getAuditState(c)
+		if state != nil {
+			// audit entry is completed once task is finished
+			proc = auditTask(state, proc)
+		}
 		task, conflictErr := runTaskInBackground(name, resources, proc)
 		if conflictErr != nil {
 			AbortWithJSONError(c, 409, conflictErr)
 			return
 		}
+		if state != nil {
+			state.deferred = true
+		}
 		c.JSON(202, task)
 	} else {
 		log.Debug().Msg("Executing task synchronously")
@@ -188,11 +196,11 @@ func maybeRunTaskInBackground(c *gin.Context, name string, resources []string, p
 }
 
 // Common piece of code to show list of packages,
-// with searching & details if requested
-func showPackages(c *gin.Context, reflist *deb.PackageRefList, collectionFactory *deb.CollectionFactory) {
+// with searching & details if requested, localRepo (if not nil) is used to match held packages
+func showPackages(c *gin.Context, reflist *deb.PackageRefList, localRepo *deb.LocalRepo, collectionFactory *deb.CollectionFactory) {
 	result := []*deb.Package{}
 
-	list, err := deb.NewPackageListFromRefList(reflist, collectionFactory.PackageCollection(), nil)
+	list, err := deb.NewPackageListFromRefListWithFields(reflist, collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, nil)
 	if err != nil {
 		AbortWithJSONError(c, 404, err)
 		return
@@ -200,11 +208,14 @@ func showPackages(c *gin.Context, reflist *deb.PackageRefList, collectionFactory
 
 	queryS := c.Request.URL.Query().Get("q")
 	if queryS != "" {
-		q, err := query.Parse(c.Request.URL.Query().Get("q"))
+		q, err := query.ParseWithPackageSets(c.Request.URL.Query().Get("q"), collectionFactory.PackageSetCollection())
 		if err != nil {
 			AbortWithJSONError(c, 400, err)
 			return
 		}
+		if localRepo != nil {
+			query.ResolveHeld(q, localRepo)
+		}
 
 		withDeps := c.Request.URL.Query().Get("withDeps") == "1"
 		architecturesList := []string{}
@@ -271,6 +282,13 @@ func showPackages(c *gin.Context, reflist *deb.PackageRefList, collectionFactory
 		})
 
 		c.JSON(200, result)
+	} else if c.Request.URL.Query().Get("format") == "deb822" {
+		list.ForEach(func(p *deb.Package) error {
+			result = append(result, p)
+			return nil
+		})
+
+		writeStanzas(c, result)
 	} else {
 		c.JSON(200, list.Strings())
 	}
@@ -10,9 +10,11 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	ctx "github.com/aptly-dev/aptly/context"
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/gin-gonic/gin"
 
 	"github.com/smira/flag"
@@ -41,6 +43,7 @@ func createTestConfig() *os.File {
 	jsonString, err := json.Marshal(gin.H{
 		"architectures":         []string{},
 		"enableMetricsEndpoint": true,
+		"enableAuditLog":        true,
 	})
 	if err != nil {
 		return nil
@@ -139,6 +142,10 @@ func (s *ApiSuite) TestGetMetrics(c *C) {
 	c.Check(b, Matches, ".*# TYPE aptly_api_http_request_duration_seconds summary.*")
 	c.Check(b, Matches, ".*# TYPE aptly_build_info gauge.*")
 	c.Check(b, Matches, ".*aptly_build_info.*version=\"testVersion\".*")
+	c.Check(b, Matches, ".*# TYPE aptly_package_pool_size_bytes gauge.*")
+	c.Check(b, Matches, ".*# TYPE aptly_package_pool_files gauge.*")
+	c.Check(b, Matches, ".*aptly_tasks.*state=\"queued\".*")
+	c.Check(b, Matches, ".*aptly_tasks.*state=\"running\".*")
 }
 
 func (s *ApiSuite) TestRepoCreate(c *C) {
@@ -150,6 +157,108 @@ func (s *ApiSuite) TestRepoCreate(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *ApiSuite) TestRepoPromoteErrors(c *C) {
+	body, err := json.Marshal(gin.H{"Distribution": "stable"})
+	c.Assert(err, IsNil)
+	response, err := s.HTTPRequest("POST", "/api/repos/no-such-repo/promote", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	body, err = json.Marshal(gin.H{"Name": "promote-stage"})
+	c.Assert(err, IsNil)
+	_, err = s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+
+	body, err = json.Marshal(gin.H{"Distribution": "stable", "Validations": []string{"Name (% lib[abc)"}})
+	c.Assert(err, IsNil)
+	response, err = s.HTTPRequest("POST", "/api/repos/promote-stage/promote", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*unable to promote: parsing failed: pattern compile failed.*")
+
+	body, err = json.Marshal(gin.H{"Distribution": "no-such-distribution"})
+	c.Assert(err, IsNil)
+	response, err = s.HTTPRequest("POST", "/api/repos/promote-stage/promote", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Matches, ".*unable to promote: published repo .* not found.*")
+}
+
+func (s *ApiSuite) TestPublishCleanupNoPublished(c *C) {
+	response, err := s.HTTPRequest("POST", "/api/publish/no-such-prefix/cleanup?DryRun=1", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Matches, ".*unable to cleanup: no published repositories under prefix no-such-prefix.*")
+}
+
+func (s *ApiSuite) TestPublishResumeNoPublished(c *C) {
+	response, err := s.HTTPRequest("POST", "/api/publish/no-such-prefix/wheezy/resume", strings.NewReader(`{"Signing": {"Skip": true}}`))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Matches, ".*unable to resume: published repo with storage:prefix/distribution no-such-prefix/wheezy not found.*")
+}
+
+func (s *ApiSuite) TestHistory(c *C) {
+	// database is shared between test runs, so repository name should be unique
+	name := fmt.Sprintf("audited-%d", time.Now().UnixNano())
+
+	body, err := json.Marshal(gin.H{"Name": name})
+	c.Assert(err, IsNil)
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+
+	body, err = json.Marshal(gin.H{"Comment": "audited repo"})
+	c.Assert(err, IsNil)
+	// username from Authorization header is not verified, so it's not recorded as actor
+	req, err := http.NewRequest("PUT", "/api/repos/"+name, bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth("mallory", "secret")
+	req.RemoteAddr = "192.0.2.1:1234"
+	response = httptest.NewRecorder()
+	s.router.ServeHTTP(response, req)
+	c.Assert(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("GET", "/api/history?entity="+name, nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var entries []deb.AuditEntry
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &entries), IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Source, Equals, deb.AuditSourceAPI)
+	c.Check(entries[0].Actor, Equals, "192.0.2.1")
+	c.Check(entries[0].Operation, Equals, "PUT /api/repos/"+name)
+	c.Check(entries[0].Status, Equals, 200)
+	c.Assert(entries[0].Changes, HasLen, 1)
+	c.Check(entries[0].Changes[0].Action, Equals, deb.AuditActionUpdated)
+	c.Check(entries[0].Changes[0].Kind, Equals, deb.AuditKindLocalRepo)
+	c.Check(entries[1].Operation, Equals, "POST /api/repos")
+	c.Check(entries[1].Status, Equals, 201)
+	c.Assert(entries[1].Changes, HasLen, 1)
+	c.Check(entries[1].Changes[0].Action, Equals, deb.AuditActionCreated)
+	c.Check(entries[1].Changes[0].UUID, Equals, entries[0].Changes[0].UUID)
+
+	response, err = s.HTTPRequest("PUT", "/api/repos/no-such-repo", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("GET", "/api/history?operation=no-such-repo&limit=1", nil)
+	c.Assert(err, IsNil)
+
+	var failed []deb.AuditEntry
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &failed), IsNil)
+	c.Assert(failed, HasLen, 1)
+	c.Check(failed[0].Status, Equals, 404)
+	c.Check(failed[0].Error, Matches, ".*not found.*")
+	c.Check(failed[0].Changes, HasLen, 0)
+
+	response, err = s.HTTPRequest("GET", "/api/history?since=yesterday", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+}
+
 func (s *ApiSuite) TestTruthy(c *C) {
 	c.Check(truthy("no"), Equals, false)
 	c.Check(truthy("n"), Equals, false)
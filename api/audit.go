@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+)
+
+const auditStateKey = "aptly.audit"
+
+// auditState is audit entry being recorded for the request
+type auditState struct {
+	entry    *deb.AuditEntry
+	recorder *deb.AuditRecorder
+	// deferred is set when entry is completed by background task
+	deferred bool
+}
+
+func getAuditState(c *gin.Context) *auditState {
+	state, ok := c.Get(auditStateKey)
+	if !ok {
+		return nil
+	}
+
+	return state.(*auditState)
+}
+
+// AuditLogger records mutating API requests in the audit log
+func AuditLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		// username from Authorization header is not verified by aptly, so it can't be trusted
+		actor := c.ClientIP()
+		if token := getAuthToken(c); token != nil {
+			actor = token.Name
+		}
+
+		var arguments []string
+		if c.Request.URL.RawQuery != "" {
+			arguments = append(arguments, c.Request.URL.RawQuery)
+		}
+
+		state := &auditState{
+			entry:    deb.NewAuditEntry(deb.AuditSourceAPI, actor, c.Request.Method+" "+c.Request.URL.Path, arguments),
+			recorder: deb.NewAuditRecorder(),
+		}
+		c.Set(auditStateKey, state)
+
+		c.Next()
+
+		if state.deferred {
+			return
+		}
+
+		var err error
+		if last := c.Errors.Last(); last != nil {
+			err = last.Err
+		}
+
+		finishAuditEntry(state, c.Writer.Status(), err)
+	}
+}
+
+// finishAuditEntry stores audit entry with the outcome of the request
+func finishAuditEntry(state *auditState, status int, err error) {
+	state.entry.Status = status
+	state.entry.Changes = state.recorder.Changes()
+	if err != nil {
+		state.entry.Error = err.Error()
+	}
+
+	context.Audit(state.entry)
+}
+
+// auditTask wraps background task so that audit entry is completed when task finishes
+func auditTask(state *auditState, proc task.Process) task.Process {
+	return func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+		ret, err := proc(out, detail)
+
+		status := http.StatusOK
+		if ret != nil {
+			status = ret.Code
+		} else if err != nil {
+			status = http.StatusInternalServerError
+		}
+		finishAuditEntry(state, status, err)
+
+		return ret, err
+	}
+}
+
+// newCollectionFactory creates collection factory which records changes in the
+// audit entry of the request
+func newCollectionFactory(c *gin.Context) *deb.CollectionFactory {
+	factory := context.NewCollectionFactory()
+
+	if state := getAuditState(c); state != nil {
+		factory.SetAuditRecorder(state.recorder)
+	}
+
+	return factory
+}
+
+// GET /api/history
+func apiHistory(c *gin.Context) {
+	var (
+		filter deb.AuditFilter
+		err    error
+	)
+
+	if since := c.Query("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse since: %s", err))
+			return
+		}
+	}
+
+	if until := c.Query("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse until: %s", err))
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 0 {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse limit: %s", limit))
+			return
+		}
+	}
+
+	filter.Actor = c.Query("actor")
+	filter.Operation = c.Query("operation")
+	filter.Entity = c.Query("entity")
+
+	entries, err := context.NewCollectionFactory().AuditCollection().Query(filter)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, entries)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// API roles, every role is granted permissions of the roles listed before it
+const (
+	// RoleReadOnly allows GET requests only
+	RoleReadOnly = "read-only"
+	// RoleUploader allows uploading files and adding/removing packages in local repos
+	RoleUploader = "uploader"
+	// RolePublisher allows creating snapshots and publishing
+	RolePublisher = "publisher"
+	// RoleAdmin allows everything, including creating and dropping mirrors and local repos
+	RoleAdmin = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleReadOnly:  0,
+	RoleUploader:  1,
+	RolePublisher: 2,
+	RoleAdmin:     3,
+}
+
+const authTokenKey = "aptly.token"
+
+// publicRoutes are available without authentication (health checks)
+var publicRoutes = map[string]bool{
+	"GET /api/version": true,
+	"GET /api/ready":   true,
+	"GET /api/healthy": true,
+}
+
+// routeRoles lists roles required for routes, GET requests not listed here require
+// read-only role, other requests not listed require admin role
+var routeRoles = map[string]string{
+	"GET /api/history": RoleAdmin,
+	// parsing package stanzas doesn't change anything
+	"POST /api/packages/stanzas": RoleReadOnly,
+
+	"PUT /api/files/:dir/:name":                RoleUploader,
+	"POST /api/files/:dir":                     RoleUploader,
+	"DELETE /api/files/:dir":                   RoleUploader,
+	"DELETE /api/files/:dir/:name":             RoleUploader,
+	"POST /api/repos/:name/packages":           RoleUploader,
+	"DELETE /api/repos/:name/packages":         RoleUploader,
+	"POST /api/repos/:name/packages/hold":      RoleUploader,
+	"DELETE /api/repos/:name/packages/hold":    RoleUploader,
+	"POST /api/repos/:name/file/:dir/:file":    RoleUploader,
+	"POST /api/repos/:name/file/:dir":          RoleUploader,
+	"POST /api/repos/:name/copy/:src/:file":    RoleUploader,
+	"POST /api/repos/:name/move/:src/:file":    RoleUploader,
+	"POST /api/repos/:name/include/:dir/:file": RoleUploader,
+	"POST /api/repos/:name/include/:dir":       RoleUploader,
+
+	"POST /api/repos/:name/snapshots":                 RolePublisher,
+	"POST /api/repos/:name/promote":                   RolePublisher,
+	"POST /api/mirrors/:name/snapshots":               RolePublisher,
+	"PUT /api/mirrors/:name":                          RolePublisher,
+	"POST /api/snapshots":                             RolePublisher,
+	"PUT /api/snapshots/:name":                        RolePublisher,
+	"DELETE /api/snapshots/:name":                     RolePublisher,
+	"POST /api/snapshots/:name/sign":                  RolePublisher,
+	"POST /api/snapshots/merge":                       RolePublisher,
+	"POST /api/snapshots/query":                       RolePublisher,
+	"POST /api/publish":                               RolePublisher,
+	"POST /api/publish/:prefix":                       RolePublisher,
+	"POST /api/publish/:prefix/cleanup":               RolePublisher,
+	"PUT /api/publish/:prefix/:distribution":          RolePublisher,
+	"DELETE /api/publish/:prefix/:distribution":       RolePublisher,
+	"POST /api/publish/:prefix/:distribution/refresh": RolePublisher,
+	"POST /api/publish/:prefix/:distribution/resume":  RolePublisher,
+	"POST /api/schedules/:name/run":                   RolePublisher,
+}
+
+// ValidateAPITokens checks configuration of API tokens
+func ValidateAPITokens(tokens []utils.APITokenConfig) error {
+	seen := map[string]bool{}
+
+	for _, token := range tokens {
+		if token.Name == "" {
+			return fmt.Errorf("API token should have a name")
+		}
+
+		if token.Token == "" {
+			return fmt.Errorf("API token %s is empty", token.Name)
+		}
+
+		if seen[token.Token] {
+			return fmt.Errorf("API token %s is used more than once", token.Name)
+		}
+		seen[token.Token] = true
+
+		if _, ok := roleLevels[token.Role]; !ok {
+			return fmt.Errorf("unknown role %s of API token %s, should be one of %s, %s, %s, %s", token.Role, token.Name,
+				RoleReadOnly, RoleUploader, RolePublisher, RoleAdmin)
+		}
+
+		for _, prefix := range token.PublishPrefixes {
+			_, pattern := deb.ParsePrefix(prefix)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid publish prefix %s of API token %s: %s", prefix, token.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// requiredRole returns role required for the request, route is the route pattern
+func requiredRole(method, route string) string {
+	if role, ok := routeRoles[method+" "+route]; ok {
+		return role
+	}
+
+	if method == http.MethodGet || method == http.MethodHead {
+		return RoleReadOnly
+	}
+
+	return RoleAdmin
+}
+
+// publishPrefixAllowed checks whether published repository prefix (as in API, e.g. s3:bucket:ppa)
+// matches one of allowed prefixes, which could contain shell wildcards
+func publishPrefixAllowed(allowed []string, param string) bool {
+	storage, prefix := deb.ParsePrefix(param)
+
+	for _, entry := range allowed {
+		allowedStorage, pattern := deb.ParsePrefix(entry)
+		if allowedStorage != storage {
+			continue
+		}
+
+		if matched, _ := path.Match(pattern, prefix); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPublishPrefix verifies that token request has been authenticated with is allowed
+// to publish under prefix ([<storage>:]<prefix>), otherwise request is aborted
+func checkPublishPrefix(c *gin.Context, param string) bool {
+	token := getAuthToken(c)
+	if token == nil || len(token.PublishPrefixes) == 0 {
+		return true
+	}
+
+	if param == "" {
+		param = "."
+	}
+
+	if !publishPrefixAllowed(token.PublishPrefixes, param) {
+		AbortWithJSONError(c, http.StatusForbidden, fmt.Errorf("API token %s is not allowed to publish under prefix %s", token.Name, param))
+		return false
+	}
+
+	return true
+}
+
+// findToken looks up token presented in Authorization header as "Bearer <token>"
+func findToken(tokens []utils.APITokenConfig, header string) *utils.APITokenConfig {
+	scheme, value, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil
+	}
+
+	value = strings.TrimSpace(value)
+	for i := range tokens {
+		if subtle.ConstantTimeCompare([]byte(tokens[i].Token), []byte(value)) == 1 {
+			return &tokens[i]
+		}
+	}
+
+	return nil
+}
+
+// getAuthToken returns token request was authenticated with (if API authentication is enabled)
+func getAuthToken(c *gin.Context) *utils.APITokenConfig {
+	token, ok := c.Get(authTokenKey)
+	if !ok {
+		return nil
+	}
+
+	return token.(*utils.APITokenConfig)
+}
+
+// Authorizer authenticates API requests with tokens and verifies that role of the token
+// allows the request
+func Authorizer(tokens []utils.APITokenConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if publicRoutes[c.Request.Method+" "+route] {
+			c.Next()
+			return
+		}
+
+		token := findToken(tokens, c.GetHeader("Authorization"))
+		if token == nil {
+			c.Header("WWW-Authenticate", `Bearer realm="aptly"`)
+			AbortWithJSONError(c, http.StatusUnauthorized, fmt.Errorf("missing or invalid API token"))
+			return
+		}
+		c.Set(authTokenKey, token)
+
+		role := requiredRole(c.Request.Method, route)
+		if roleLevels[token.Role] < roleLevels[role] {
+			AbortWithJSONError(c, http.StatusForbidden, fmt.Errorf("API token %s with role %s is not allowed to %s %s, %s role is required",
+				token.Name, token.Role, c.Request.Method, c.Request.URL.Path, role))
+			return
+		}
+
+		// routes which publish to prefix given in request body or configuration (promote,
+		// scheduled jobs) verify prefix with checkPublishPrefix in the handler
+		if role == RolePublisher && strings.HasPrefix(route, "/api/publish") {
+			param := "."
+			if _, ok := c.Params.Get("prefix"); ok {
+				param = parseEscapedPath(c.Params.ByName("prefix"))
+			}
+
+			if !checkPublishPrefix(c, param) {
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type AuthSuite struct {
+	router http.Handler
+}
+
+var _ = Suite(&AuthSuite{})
+
+func (s *AuthSuite) SetUpTest(c *C) {
+	tokens := []utils.APITokenConfig{
+		{Name: "viewer", Token: "t-viewer", Role: RoleReadOnly},
+		{Name: "ci", Token: "t-ci", Role: RoleUploader},
+		{Name: "team-a", Token: "t-team-a", Role: RolePublisher, PublishPrefixes: []string{"team-a/*", "s3:bucket:ppa"}},
+		{Name: "root", Token: "t-root", Role: RoleAdmin},
+	}
+
+	router := gin.New()
+	router.UseRawPath = true
+	router.Use(gin.Recovery(), gin.ErrorLogger())
+
+	api := router.Group("/api")
+	api.Use(Authorizer(tokens))
+
+	ok := func(c *gin.Context) { c.JSON(200, gin.H{}) }
+	api.GET("/version", ok)
+	api.GET("/repos", ok)
+	api.POST("/repos", ok)
+	api.POST("/repos/:name/packages", ok)
+	api.POST("/publish", ok)
+	api.PUT("/publish/:prefix/:distribution", ok)
+
+	s.router = router
+}
+
+func (s *AuthSuite) request(method, url, token string) int {
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	s.router.ServeHTTP(recorder, req)
+
+	return recorder.Code
+}
+
+func (s *AuthSuite) TestAuthentication(c *C) {
+	c.Check(s.request("GET", "/api/version", ""), Equals, 200)
+	c.Check(s.request("GET", "/api/repos", ""), Equals, 401)
+	c.Check(s.request("GET", "/api/repos", "wrong"), Equals, 401)
+	c.Check(s.request("GET", "/api/repos", "t-viewer"), Equals, 200)
+}
+
+func (s *AuthSuite) TestRoles(c *C) {
+	c.Check(s.request("POST", "/api/repos/local/packages", "t-viewer"), Equals, 403)
+	c.Check(s.request("POST", "/api/repos/local/packages", "t-ci"), Equals, 200)
+	c.Check(s.request("POST", "/api/repos", "t-ci"), Equals, 403)
+	c.Check(s.request("POST", "/api/repos", "t-team-a"), Equals, 403)
+	c.Check(s.request("POST", "/api/repos", "t-root"), Equals, 200)
+	c.Check(s.request("PUT", "/api/publish/:./wheezy", "t-ci"), Equals, 403)
+	c.Check(s.request("PUT", "/api/publish/:./wheezy", "t-root"), Equals, 200)
+}
+
+func (s *AuthSuite) TestPublishPrefixes(c *C) {
+	c.Check(s.request("PUT", "/api/publish/team-a_main/wheezy", "t-team-a"), Equals, 200)
+	c.Check(s.request("PUT", "/api/publish/team-b_main/wheezy", "t-team-a"), Equals, 403)
+	c.Check(s.request("PUT", "/api/publish/s3:bucket:ppa/wheezy", "t-team-a"), Equals, 200)
+	c.Check(s.request("PUT", "/api/publish/ppa/wheezy", "t-team-a"), Equals, 403)
+	c.Check(s.request("POST", "/api/publish", "t-team-a"), Equals, 403)
+}
+
+func (s *AuthSuite) TestValidateAPITokens(c *C) {
+	c.Check(ValidateAPITokens(nil), IsNil)
+	c.Check(ValidateAPITokens([]utils.APITokenConfig{{Name: "a", Token: "x", Role: RoleAdmin}}), IsNil)
+	c.Check(ValidateAPITokens([]utils.APITokenConfig{{Name: "a", Token: "x", Role: "owner"}}), ErrorMatches, "unknown role owner of API token a.*")
+	c.Check(ValidateAPITokens([]utils.APITokenConfig{{Name: "a", Role: RoleAdmin}}), ErrorMatches, "API token a is empty")
+	c.Check(ValidateAPITokens([]utils.APITokenConfig{{Name: "a", Token: "x", Role: RoleAdmin}, {Name: "b", Token: "x", Role: RoleAdmin}}),
+		ErrorMatches, "API token b is used more than once")
+	c.Check(ValidateAPITokens([]utils.APITokenConfig{{Name: "a", Token: "x", Role: RoleAdmin, PublishPrefixes: []string{"[a"}}}),
+		ErrorMatches, "invalid publish prefix .*")
+}
+
+// AuthHandlersSuite covers routes which verify publish prefix in the handler, as prefix
+// comes from request body or configuration
+type AuthHandlersSuite struct {
+	ApiSuite
+	authRouter http.Handler
+}
+
+var _ = Suite(&AuthHandlersSuite{})
+
+func (s *AuthHandlersSuite) SetUpTest(c *C) {
+	tokens := []utils.APITokenConfig{
+		{Name: "team-a", Token: "t-team-a", Role: RolePublisher, PublishPrefixes: []string{"team-a/*"}},
+		{Name: "publisher", Token: "t-publisher", Role: RolePublisher},
+	}
+
+	router := gin.New()
+	router.UseRawPath = true
+	router.Use(gin.ErrorLogger())
+
+	api := router.Group("/api")
+	api.Use(Authorizer(tokens))
+	api.POST("/repos/:name/promote", apiReposPromote)
+	api.POST("/schedules/:name/run", apiSchedulesRun)
+
+	s.authRouter = router
+
+	s.context.Config().MirrorSchedules = []utils.MirrorScheduleConfig{
+		{Mirror: "debian", Schedule: "@daily", Snapshot: "{mirror}", PublishPrefix: "team-b/main", PublishDistribution: "bookworm"},
+		{Mirror: "ubuntu", Schedule: "@daily"},
+	}
+}
+
+func (s *AuthHandlersSuite) TearDownTest(c *C) {
+	s.context.Config().MirrorSchedules = []utils.MirrorScheduleConfig{}
+}
+
+func (s *AuthHandlersSuite) request(method, url, token, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.authRouter.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *AuthHandlersSuite) TestPromotePrefix(c *C) {
+	response := s.request("POST", "/api/repos/local/promote", "t-team-a", `{"Prefix": "team-b/main", "Distribution": "bookworm"}`)
+	c.Check(response.Code, Equals, 403)
+	c.Check(response.Body.String(), Equals, `{"error":"API token team-a is not allowed to publish under prefix team-b/main"}`)
+
+	c.Check(s.request("POST", "/api/repos/local/promote", "t-team-a", `{"Distribution": "bookworm"}`).Code, Equals, 403)
+
+	// allowed prefix gets through to the lookup of local repo
+	c.Check(s.request("POST", "/api/repos/local/promote", "t-team-a", `{"Prefix": "team-a/main", "Distribution": "bookworm"}`).Code, Equals, 404)
+	c.Check(s.request("POST", "/api/repos/local/promote", "t-publisher", `{"Prefix": "team-b/main", "Distribution": "bookworm"}`).Code, Equals, 404)
+}
+
+func (s *AuthHandlersSuite) TestScheduleRunPrefix(c *C) {
+	response := s.request("POST", "/api/schedules/debian/run", "t-team-a", "")
+	c.Check(response.Code, Equals, 403)
+	c.Check(response.Body.String(), Equals, `{"error":"API token team-a is not allowed to publish under prefix team-b/main"}`)
+
+	// job which doesn't publish, and token without prefix restrictions
+	c.Check(s.request("POST", "/api/schedules/ubuntu/run", "t-team-a", "").Code, Equals, 404)
+	c.Check(s.request("POST", "/api/schedules/debian/run", "t-publisher", "").Code, Equals, 404)
+}
@@ -18,7 +18,7 @@ func apiDbCleanup(c *gin.Context) {
 	maybeRunTaskInBackground(c, "Clean up db", resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
 		var err error
 
-		collectionFactory := context.NewCollectionFactory()
+		collectionFactory := newCollectionFactory(c)
 
 		// collect information about referenced packages...
 		existingPackageRefs := deb.NewPackageRefList()
@@ -100,13 +100,7 @@ func apiDbCleanup(c *gin.Context) {
 		db, _ := context.Database()
 
 		if toDelete.Len() > 0 {
-			batch := db.CreateBatch()
-			toDelete.ForEach(func(ref []byte) error {
-				collectionFactory.PackageCollection().DeleteByKey(ref, batch)
-				return nil
-			})
-
-			err = batch.Write()
+			err = collectionFactory.PackageCollection().DeleteByKeys(toDelete)
 			if err != nil {
 				return nil, fmt.Errorf("unable to write to DB: %s", err)
 			}
@@ -174,6 +168,12 @@ func apiDbCleanup(c *gin.Context) {
 			out.Printf("Disk space freed: %s...", utils.HumanBytes(totalSize))
 		}
 
+		out.Printf("Removing unused cached package indexes...")
+		_, err = context.IndexCache().Prune()
+		if err != nil {
+			return nil, fmt.Errorf("unable to clean up package index cache: %s", err)
+		}
+
 		out.Printf("Compacting database...")
 		return nil, db.CompactDB()
 	})
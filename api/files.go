@@ -3,12 +3,17 @@ package api
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 	"github.com/saracen/walker"
 )
 
@@ -130,7 +135,7 @@ func apiFilesListFiles(c *gin.Context) {
 			return err
 		}
 
-		if path == root {
+		if path == root || isPartialUpload(path) {
 			return nil
 		}
 
@@ -179,7 +184,10 @@ func apiFilesDeleteFile(c *gin.Context) {
 		return
 	}
 
-	err := os.Remove(filepath.Join(context.UploadPath(), c.Params.ByName("dir"), c.Params.ByName("name")))
+	dir := filepath.Join(context.UploadPath(), c.Params.ByName("dir"))
+	_ = os.Remove(partialUploadPath(dir, c.Params.ByName("name")))
+
+	err := os.Remove(filepath.Join(dir, c.Params.ByName("name")))
 	if err != nil {
 		if err1, ok := err.(*os.PathError); !ok || !os.IsNotExist(err1.Err) {
 			AbortWithJSONError(c, 500, err)
@@ -189,3 +197,261 @@ func apiFilesDeleteFile(c *gin.Context) {
 
 	c.JSON(200, gin.H{})
 }
+
+// uploadStatus is state of the file being uploaded in chunks
+type uploadStatus struct {
+	Name string
+	// Size is number of bytes received so far
+	Size     int64
+	Complete bool
+}
+
+// uploadLocks serialize writes of chunks of the same file
+var uploadLocks sync.Map
+
+var contentRangeRegexp = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+|\*)$`)
+
+// partialUploadPath is path of the file being uploaded in chunks, partial uploads are
+// hidden from file listings and are not picked up when packages are added
+func partialUploadPath(dir, name string) string {
+	return filepath.Join(dir, "."+name+".part")
+}
+
+func isPartialUpload(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasPrefix(base, ".") && strings.HasSuffix(base, ".part")
+}
+
+// parseContentRange parses Content-Range header of the chunk, total is -1 if unknown
+func parseContentRange(header string) (start, end, total int64, err error) {
+	matches := contentRangeRegexp.FindStringSubmatch(header)
+	if matches == nil {
+		err = fmt.Errorf("wrong Content-Range %s, should be 'bytes <start>-<end>/<total>'", header)
+		return
+	}
+
+	start, _ = strconv.ParseInt(matches[1], 10, 64)
+	end, _ = strconv.ParseInt(matches[2], 10, 64)
+	total = -1
+	if matches[3] != "*" {
+		total, _ = strconv.ParseInt(matches[3], 10, 64)
+	}
+
+	if end < start || (total != -1 && end >= total) {
+		err = fmt.Errorf("wrong Content-Range %s", header)
+	}
+
+	return
+}
+
+// PUT /files/:dir/:name
+//
+// File is uploaded as request body, possibly in several chunks, each one with Content-Range header
+// ('bytes <start>-<end>/<total>', total could be '*' until it's known); chunks should be sent in order,
+// upload could be resumed from the size returned by GET /files/:dir/:name. File appears in the upload
+// directory when last chunk is received.
+func apiFilesUploadChunk(c *gin.Context) {
+	if !verifyDir(c) {
+		return
+	}
+
+	name := c.Params.ByName("name")
+	if !verifyPath(name) || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		AbortWithJSONError(c, 400, fmt.Errorf("wrong file"))
+		return
+	}
+
+	var (
+		start, end int64
+		total      int64 = -1
+		err        error
+	)
+
+	chunked := c.GetHeader("Content-Range") != ""
+	if chunked {
+		start, end, total, err = parseContentRange(c.GetHeader("Content-Range"))
+		if err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
+
+	dir := filepath.Join(context.UploadPath(), c.Params.ByName("dir"))
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	partPath := partialUploadPath(dir, name)
+
+	lock, _ := uploadLocks.LoadOrStore(partPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	if start != 0 && start != st.Size() {
+		AbortWithJSONError(c, http.StatusConflict, fmt.Errorf("chunk starts at %d, while %d bytes were received so far", start, st.Size()))
+		return
+	}
+
+	err = f.Truncate(start)
+	if err == nil {
+		_, err = f.Seek(start, io.SeekStart)
+	}
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if chunked {
+		body = io.LimitReader(body, end-start+1)
+	}
+
+	written, err := io.Copy(f, body)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	size := start + written
+	if chunked && size != end+1 {
+		_ = f.Truncate(start)
+		AbortWithJSONError(c, 400, fmt.Errorf("incomplete chunk: %d bytes received, %d expected", written, end-start+1))
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	status := uploadStatus{Name: name, Size: size}
+
+	if !chunked || size == total {
+		err = os.Rename(partPath, filepath.Join(dir, name))
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+		uploadLocks.Delete(partPath)
+
+		status.Complete = true
+		apiFilesUploadedCounter.WithLabelValues(c.Params.ByName("dir")).Inc()
+	}
+
+	c.JSON(200, status)
+}
+
+// GET /files/:dir/:name
+func apiFilesUploadStatus(c *gin.Context) {
+	if !verifyDir(c) {
+		return
+	}
+
+	name := c.Params.ByName("name")
+	if !verifyPath(name) {
+		AbortWithJSONError(c, 400, fmt.Errorf("wrong file"))
+		return
+	}
+
+	dir := filepath.Join(context.UploadPath(), c.Params.ByName("dir"))
+
+	if st, err := os.Stat(filepath.Join(dir, name)); err == nil {
+		c.JSON(200, uploadStatus{Name: name, Size: st.Size(), Complete: true})
+		return
+	}
+
+	st, err := os.Stat(partialUploadPath(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			AbortWithJSONError(c, 404, fmt.Errorf("file %s not found", name))
+		} else {
+			AbortWithJSONError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, uploadStatus{Name: name, Size: st.Size()})
+}
+
+// cleanupUploads removes upload directories which were not modified for maxAge (abandoned uploads),
+// returns names of removed directories
+func cleanupUploads(root string, maxAge time.Duration, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(root, entry.Name())
+
+		var latest time.Time
+		err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+
+		if now.Sub(latest) < maxAge {
+			continue
+		}
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry.Name())
+	}
+
+	return removed, nil
+}
+
+// cleanupUploadsPeriodically removes abandoned uploads (see cleanupUploads) in background
+func cleanupUploadsPeriodically(root string, maxAge time.Duration) {
+	interval := maxAge
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+
+	for range time.Tick(interval) {
+		removed, err := cleanupUploads(root, maxAge, time.Now())
+		if err != nil {
+			log.Error().Msgf("unable to clean up abandoned uploads: %s", err)
+		}
+		if len(removed) > 0 {
+			log.Info().Msgf("removed abandoned uploads: %s", strings.Join(removed, ", "))
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type FilesSuite struct{}
+
+var _ = Suite(&FilesSuite{})
+
+func (s *FilesSuite) TestParseContentRange(c *C) {
+	start, end, total, err := parseContentRange("bytes 0-99/200")
+	c.Assert(err, IsNil)
+	c.Check([]int64{start, end, total}, DeepEquals, []int64{0, 99, 200})
+
+	start, end, total, err = parseContentRange("bytes 100-149/*")
+	c.Assert(err, IsNil)
+	c.Check([]int64{start, end, total}, DeepEquals, []int64{100, 149, -1})
+
+	_, _, _, err = parseContentRange("bytes 100-99/200")
+	c.Check(err, ErrorMatches, "wrong Content-Range .*")
+	_, _, _, err = parseContentRange("bytes 0-200/200")
+	c.Check(err, ErrorMatches, "wrong Content-Range .*")
+	_, _, _, err = parseContentRange("items 0-1/2")
+	c.Check(err, ErrorMatches, "wrong Content-Range .*")
+}
+
+func (s *FilesSuite) TestCleanupUploads(c *C) {
+	root := c.MkDir()
+	now := time.Now()
+
+	for _, dir := range []string{"fresh", "abandoned", "partial"} {
+		c.Assert(os.MkdirAll(filepath.Join(root, dir), 0777), IsNil)
+	}
+	c.Assert(os.WriteFile(filepath.Join(root, "fresh", "a.deb"), []byte("deb"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "abandoned", "b.deb"), []byte("deb"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "partial", ".c.deb.part"), []byte("de"), 0644), IsNil)
+
+	old := now.Add(-2 * time.Hour)
+	for _, path := range []string{"abandoned/b.deb", "abandoned", "partial"} {
+		c.Assert(os.Chtimes(filepath.Join(root, path), old, old), IsNil)
+	}
+	// directory is still being uploaded to
+	c.Assert(os.Chtimes(filepath.Join(root, "partial", ".c.deb.part"), now, now), IsNil)
+
+	removed, err := cleanupUploads(root, time.Hour, now)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"abandoned"})
+	_, err = os.Stat(filepath.Join(root, "fresh"))
+	c.Check(err, IsNil)
+	_, err = os.Stat(filepath.Join(root, "partial"))
+	c.Check(err, IsNil)
+
+	removed, err = cleanupUploads(filepath.Join(root, "missing"), time.Hour, now)
+	c.Assert(err, IsNil)
+	c.Check(removed, HasLen, 0)
+}
+
+func (s *ApiSuite) uploadChunk(url, contentRange, data string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", url, strings.NewReader(data))
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *ApiSuite) TestFilesChunkedUpload(c *C) {
+	dir := fmt.Sprintf("chunked-%d", time.Now().UnixNano())
+	url := "/api/files/" + dir + "/pkg.deb"
+
+	response := s.uploadChunk(url, "bytes 0-4/10", "01234")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, `{"Name":"pkg.deb","Size":5,"Complete":false}`)
+
+	response, err := s.HTTPRequest("GET", "/api/files/"+dir, nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Body.String(), Equals, "[]")
+
+	response, err = s.HTTPRequest("GET", url, nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Body.String(), Equals, `{"Name":"pkg.deb","Size":5,"Complete":false}`)
+
+	response = s.uploadChunk(url, "bytes 7-9/10", "789")
+	c.Check(response.Code, Equals, 409)
+
+	response = s.uploadChunk(url, "bytes 5-9/10", "56789")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, `{"Name":"pkg.deb","Size":10,"Complete":true}`)
+
+	content, err := os.ReadFile(filepath.Join(s.context.UploadPath(), dir, "pkg.deb"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "0123456789")
+
+	response = s.uploadChunk("/api/files/"+dir+"/other.deb", "", "whole")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, `{"Name":"other.deb","Size":5,"Complete":true}`)
+
+	response, err = s.HTTPRequest("GET", "/api/files/"+dir, nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Body.String(), Equals, `["other.deb","pkg.deb"]`)
+
+	response = s.uploadChunk("/api/files/"+dir+"/.hidden", "", "x")
+	c.Check(response.Code, Equals, 400)
+
+	response, err = s.HTTPRequest("DELETE", "/api/files/"+dir, nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+}
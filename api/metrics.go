@@ -3,9 +3,12 @@ package api
 import (
 	"fmt"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -69,8 +72,139 @@ var (
 		},
 		[]string{"source", "distribution", "component"},
 	)
+	mirrorUpdateDurationSummary = promauto.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name: "aptly_mirror_update_duration_seconds",
+			Help: "Duration of mirror updates performed by API server in seconds labeled by mirror and status.",
+		},
+		[]string{"mirror", "status"},
+	)
+	mirrorDownloadsCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aptly_mirror_downloads_total",
+			Help: "Total number of package files downloaded while updating mirrors by API server.",
+		},
+		[]string{"mirror"},
+	)
+	mirrorDownloadErrorsCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aptly_mirror_download_errors_total",
+			Help: "Total number of failed package file downloads while updating mirrors by API server.",
+		},
+		[]string{"mirror"},
+	)
+	mirrorDownloadedBytesCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aptly_mirror_downloaded_bytes_total",
+			Help: "Total size of package files downloaded while updating mirrors by API server in bytes.",
+		},
+		[]string{"mirror"},
+	)
+	mirrorLastUpdateGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aptly_mirror_last_update_timestamp_seconds",
+			Help: "Time of the last successful mirror update as unix timestamp, 0 if mirror was never updated.",
+		},
+		[]string{"mirror"},
+	)
+	mirrorStaleGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aptly_mirror_stale",
+			Help: "1 if mirror hasn't been updated successfully within mirrorStaleAfter or upstream Release file has expired, 0 otherwise.",
+		},
+		[]string{"mirror"},
+	)
+	mirrorPackageCountGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aptly_mirror_package_count",
+			Help: "Current number of packages in mirror.",
+		},
+		[]string{"mirror"},
+	)
+	localRepoPackageCountGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aptly_local_repo_package_count",
+			Help: "Current number of packages in local repo.",
+		},
+		[]string{"repo"},
+	)
+	publishDurationSummary = promauto.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name: "aptly_publish_duration_seconds",
+			Help: "Duration of publishing performed by API server in seconds labeled by prefix, distribution and status.",
+		},
+		[]string{"prefix", "distribution", "status"},
+	)
+	poolSizeGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "aptly_package_pool_size_bytes",
+			Help: "Total size of files in package pool in bytes.",
+		},
+	)
+	poolFilesGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "aptly_package_pool_files",
+			Help: "Number of files in package pool.",
+		},
+	)
+	tasksGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aptly_tasks",
+			Help: "Current number of tasks labeled by state (queued or running).",
+		},
+		[]string{"state"},
+	)
 )
 
+// poolStatsInterval is how often package pool is walked to calculate its size
+const poolStatsInterval = 10 * time.Minute
+
+// packageCountInterval is how often number of packages in mirrors and local repos
+// is recalculated, as it requires loading package lists of all the repos
+const packageCountInterval = time.Minute
+
+// periodicStats limits how often expensive metrics are recalculated
+type periodicStats struct {
+	sync.Mutex
+	interval  time.Duration
+	updatedAt time.Time
+	running   bool
+}
+
+var (
+	poolStats         = &periodicStats{interval: poolStatsInterval}
+	packageCountStats = &periodicStats{interval: packageCountInterval}
+)
+
+// start returns true if stats should be recalculated now: interval has passed since
+// last recalculation and no other recalculation is running; if true is returned,
+// finish should be called once recalculation is over
+//
+// Lock is not held while stats are recalculated, so that concurrent scrapes
+// are not blocked.
+func (stats *periodicStats) start() bool {
+	stats.Lock()
+	defer stats.Unlock()
+
+	if stats.running || time.Since(stats.updatedAt) < stats.interval {
+		return false
+	}
+
+	stats.running = true
+	return true
+}
+
+// finish marks recalculation as complete, failed recalculation is retried on next scrape
+func (stats *periodicStats) finish(success bool) {
+	stats.Lock()
+	defer stats.Unlock()
+
+	stats.running = false
+	if success {
+		stats.updatedAt = time.Now()
+	}
+}
+
 type metricsCollectorRegistrar struct {
 	hasRegistered bool
 }
@@ -114,3 +248,129 @@ func countPackagesByRepos() {
 		log.Warn().Msg(msg)
 	}
 }
+
+// observeTask wraps task process to observe its duration in summary, labeled with labels
+// followed by status of the task (success or failure)
+func observeTask(summary *prometheus.SummaryVec, labels []string, proc task.Process) task.Process {
+	return func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+		started := time.Now()
+		retValue, err := proc(out, detail)
+
+		status := "success"
+		if err != nil {
+			status = "failure"
+		}
+		summary.WithLabelValues(append(labels, status)...).Observe(time.Since(started).Seconds())
+
+		return retValue, err
+	}
+}
+
+// measureMirrorHealth updates time of last update and staleness of mirrors
+func measureMirrorHealth() {
+	now := time.Now()
+	staleAfter := time.Duration(context.Config().MirrorStaleAfter) * time.Second
+
+	err := context.NewCollectionFactory().RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
+		if repo.LastDownloadDate.IsZero() {
+			mirrorLastUpdateGauge.WithLabelValues(repo.Name).Set(0)
+		} else {
+			mirrorLastUpdateGauge.WithLabelValues(repo.Name).Set(float64(repo.LastDownloadDate.Unix()))
+		}
+
+		if repo.Health(now, staleAfter).Stale {
+			mirrorStaleGauge.WithLabelValues(repo.Name).Set(1)
+		} else {
+			mirrorStaleGauge.WithLabelValues(repo.Name).Set(0)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Warn().Msgf("Error %s found while listing mirrors for metrics endpoint", err)
+	}
+}
+
+// countPackagesByMirrorsAndLocalRepos counts packages in mirrors and local repos, as package
+// lists have to be loaded, it's done at most once per packageCountInterval
+func countPackagesByMirrorsAndLocalRepos() {
+	if !packageCountStats.start() {
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	err := collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
+		err := collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return err
+		}
+		mirrorPackageCountGauge.WithLabelValues(repo.Name).Set(float64(repo.NumPackages()))
+
+		return nil
+	})
+	if err != nil {
+		log.Warn().Msgf("Error %s found while listing mirrors for metrics endpoint", err)
+		packageCountStats.finish(false)
+		return
+	}
+
+	err = collectionFactory.LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
+		err := collectionFactory.LocalRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return err
+		}
+		localRepoPackageCountGauge.WithLabelValues(repo.Name).Set(float64(repo.NumPackages()))
+
+		return nil
+	})
+	if err != nil {
+		log.Warn().Msgf("Error %s found while listing local repos for metrics endpoint", err)
+	}
+
+	packageCountStats.finish(err == nil)
+}
+
+func countTasks() {
+	queued, running := 0, 0
+	for _, t := range context.TaskList().GetTasks() {
+		switch t.State {
+		case task.IDLE:
+			queued++
+		case task.RUNNING:
+			running++
+		}
+	}
+
+	tasksGauge.WithLabelValues("queued").Set(float64(queued))
+	tasksGauge.WithLabelValues("running").Set(float64(running))
+}
+
+// measurePackagePool calculates size of the package pool, as walking the pool is expensive,
+// it's done at most once per poolStatsInterval
+func measurePackagePool() {
+	if !poolStats.start() {
+		return
+	}
+
+	pool := context.PackagePool()
+	files, err := pool.FilepathList(nil)
+	if err != nil {
+		log.Warn().Msgf("Error %s found while listing package pool for metrics endpoint", err)
+		poolStats.finish(false)
+		return
+	}
+
+	var size int64
+	for _, file := range files {
+		fileSize, err := pool.Size(file)
+		if err != nil {
+			continue
+		}
+		size += fileSize
+	}
+
+	poolFilesGauge.Set(float64(len(files)))
+	poolSizeGauge.Set(float64(size))
+	poolStats.finish(true)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct{}
+
+var _ = Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TestPeriodicStats(c *C) {
+	stats := &periodicStats{interval: time.Hour}
+
+	c.Assert(stats.start(), Equals, true)
+	// recalculation is already running
+	c.Check(stats.start(), Equals, false)
+	stats.finish(true)
+
+	// interval hasn't passed yet
+	c.Check(stats.start(), Equals, false)
+
+	stats.updatedAt = time.Now().Add(-2 * time.Hour)
+	c.Assert(stats.start(), Equals, true)
+	stats.finish(false)
+
+	// failed recalculation is retried
+	c.Check(stats.start(), Equals, true)
+}
@@ -1,28 +1,41 @@
 package api
 
 import (
+	gocontext "context"
 	"fmt"
 	"net/http"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
-func getVerifier(keyRings []string) (pgp.Verifier, error) {
+func getVerifier(keyRings []string, repo *deb.RemoteRepo) (pgp.Verifier, error) {
 	verifier := context.GetVerifier()
 	for _, keyRing := range keyRings {
 		verifier.AddKeyring(keyRing)
 	}
 
+	if trustedKeyrings := context.TrustedKeyrings(repo); len(trustedKeyrings) > 0 {
+		if len(keyRings) == 0 {
+			// keys from key store are trusted in addition to default keyring
+			verifier.AddKeyring("trustedkeys.gpg")
+		}
+		for _, keyRing := range trustedKeyrings {
+			verifier.AddKeyring(keyRing)
+		}
+	}
+
 	err := verifier.InitKeyring(false)
 	if err != nil {
 		return nil, err
@@ -31,9 +44,24 @@ func getVerifier(keyRings []string) (pgp.Verifier, error) {
 	return verifier, nil
 }
 
+// getRemoteDownloader returns new downloader configured with mirror proxy, credentials and certificates
+func getRemoteDownloader(repo *deb.RemoteRepo, progress aptly.Progress) (aptly.Downloader, error) {
+	options, err := repo.DownloaderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	downloader, err := context.NewDownloaderWithOptions(progress, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.MirrorDownloader(downloader), nil
+}
+
 // GET /api/mirrors
 func apiMirrorsList(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 
 	result := []*deb.RemoteRepo{}
@@ -63,6 +91,24 @@ func apiMirrorsCreate(c *gin.Context) {
 		SkipComponentCheck    bool
 		SkipArchitectureCheck bool
 		IgnoreSignatures      bool
+		UsePDiffs             bool
+		Proxy                 string
+		Username              string
+		PasswordFile          string
+		PasswordEnv           string
+		TLSClientCert         string
+		TLSClientKey          string
+		TLSCACert             string
+		AptlyAPI              string
+		AptlyPrefix           string
+		AlternateURLs         []string
+		IncludeSections       []string
+		ExcludeSections       []string
+		MinPriority           string
+		SnapshotTime          string
+		ChecksumPolicy        string
+		Quarantine            bool
+		KeepVersions          int
 	}
 
 	b.DownloadSources = context.Config().DownloadSourcePackages
@@ -73,7 +119,7 @@ func apiMirrorsCreate(c *gin.Context) {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 
 	if strings.HasPrefix(b.ArchiveURL, "ppa:") {
@@ -85,13 +131,32 @@ func apiMirrorsCreate(c *gin.Context) {
 	}
 
 	if b.Filter != "" {
-		_, err = query.Parse(b.Filter)
+		_, err = query.ParseWithPackageSets(b.Filter, collectionFactory.PackageSetCollection())
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+	}
+
+	if b.MinPriority != "" {
+		err = deb.ValidatePriority(b.MinPriority)
 		if err != nil {
 			AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
 			return
 		}
 	}
 
+	err = deb.ValidateChecksumPolicy(b.ChecksumPolicy)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	if b.KeepVersions < 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: number of versions to keep should be non-negative"))
+		return
+	}
+
 	repo, err := deb.NewRemoteRepo(b.Name, b.ArchiveURL, b.Distribution, b.Components, b.Architectures,
 		b.DownloadSources, b.DownloadUdebs, b.DownloadInstaller)
 
@@ -100,26 +165,65 @@ func apiMirrorsCreate(c *gin.Context) {
 		return
 	}
 
+	if b.SnapshotTime != "" {
+		err = repo.ApplyDebianSnapshotTime(b.SnapshotTime)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+	} else if strings.HasPrefix(b.ArchiveURL, "snapshot:") {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: SnapshotTime is required to mirror %s", b.ArchiveURL))
+		return
+	}
+
 	repo.Filter = b.Filter
 	repo.FilterWithDeps = b.FilterWithDeps
+	repo.IncludeSections = b.IncludeSections
+	repo.ExcludeSections = b.ExcludeSections
+	repo.MinPriority = strings.ToLower(b.MinPriority)
+	repo.ChecksumPolicy = b.ChecksumPolicy
+	repo.Quarantine = b.Quarantine
+	repo.KeepVersions = b.KeepVersions
 	repo.SkipComponentCheck = b.SkipComponentCheck
 	repo.SkipArchitectureCheck = b.SkipArchitectureCheck
+	repo.UsePDiffs = b.UsePDiffs
 	repo.DownloadSources = b.DownloadSources
 	repo.DownloadUdebs = b.DownloadUdebs
-
-	verifier, err := getVerifier(b.Keyrings)
+	repo.Proxy = b.Proxy
+	repo.Username = b.Username
+	repo.PasswordFile = b.PasswordFile
+	repo.PasswordEnv = b.PasswordEnv
+	repo.TLSClientCert = b.TLSClientCert
+	repo.TLSClientKey = b.TLSClientKey
+	repo.TLSCACert = b.TLSCACert
+	repo.AptlyAPI = b.AptlyAPI
+	repo.AptlyPrefix = b.AptlyPrefix
+	repo.AlternateURLs = b.AlternateURLs
+
+	verifier, err := getVerifier(b.Keyrings, nil)
 	if err != nil {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
 		return
 	}
 
-	downloader := context.NewDownloader(nil)
+	downloader, err := getRemoteDownloader(repo, nil)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
 	err = repo.Fetch(downloader, verifier, b.IgnoreSignatures)
 	if err != nil {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to fetch mirror: %s", err))
 		return
 	}
 
+	err = repo.FetchUpstreamSources(downloader)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to fetch mirror: %s", err))
+		return
+	}
+
 	err = collection.Add(repo)
 	if err != nil {
 		AbortWithJSONError(c, 500, fmt.Errorf("unable to add mirror: %s", err))
@@ -134,7 +238,7 @@ func apiMirrorsDrop(c *gin.Context) {
 	name := c.Params.ByName("name")
 	force := c.Request.URL.Query().Get("force") == "1"
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	mirrorCollection := collectionFactory.RemoteRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
 
@@ -164,13 +268,28 @@ func apiMirrorsDrop(c *gin.Context) {
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
 		}
+
+		err = os.RemoveAll(context.IndexCachePath(repo))
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
+		}
+
+		err = os.RemoveAll(context.PartialDownloadPath(repo))
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
+		}
+
+		err = context.KeyStore().Drop(pgp.MirrorKeyNamespace(repo.UUID))
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
+		}
 		return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
 	})
 }
 
 // GET /api/mirrors/:name
 func apiMirrorsShow(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 
 	name := c.Params.ByName("name")
@@ -188,9 +307,34 @@ func apiMirrorsShow(c *gin.Context) {
 	c.JSON(200, repo)
 }
 
+// GET /api/mirrors/:name/status
+//
+// Staleness window defaults to mirrorStaleAfter from configuration, could be overridden
+// with `staleAfter` query parameter (duration, e.g. 48h).
+func apiMirrorsStatus(c *gin.Context) {
+	staleAfter := time.Duration(context.Config().MirrorStaleAfter) * time.Second
+	if value := c.Request.URL.Query().Get("staleAfter"); value != "" {
+		var err error
+		staleAfter, err = time.ParseDuration(value)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to show status: %s", err))
+			return
+		}
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to show status: %s", err))
+		return
+	}
+
+	c.JSON(200, repo.Health(time.Now(), staleAfter))
+}
+
 // GET /api/mirrors/:name/packages
 func apiMirrorsPackages(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 
 	name := c.Params.ByName("name")
@@ -221,7 +365,7 @@ func apiMirrorsPackages(c *gin.Context) {
 
 	queryS := c.Request.URL.Query().Get("q")
 	if queryS != "" {
-		q, err := query.Parse(c.Request.URL.Query().Get("q"))
+		q, err := query.ParseWithPackageSets(c.Request.URL.Query().Get("q"), collectionFactory.PackageSetCollection())
 		if err != nil {
 			AbortWithJSONError(c, 400, err)
 			return
@@ -283,15 +427,33 @@ func apiMirrorsUpdate(c *gin.Context) {
 		FilterWithDeps        bool
 		DownloadSources       bool
 		DownloadUdebs         bool
+		DownloadInstaller     bool
 		SkipComponentCheck    bool
 		SkipArchitectureCheck bool
 		IgnoreChecksums       bool
 		IgnoreSignatures      bool
 		ForceUpdate           bool
+		ForceIndexes          bool
 		SkipExistingPackages  bool
+		UsePDiffs             bool
+		Proxy                 *string
+		Username              *string
+		PasswordFile          *string
+		PasswordEnv           *string
+		TLSClientCert         *string
+		TLSClientKey          *string
+		TLSCACert             *string
+		AlternateURLs         *[]string
+		IncludeSections       []string
+		ExcludeSections       []string
+		MinPriority           string
+		SnapshotTime          string
+		ChecksumPolicy        string
+		Quarantine            bool
+		KeepVersions          int
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 
 	remote, err = collection.ByName(c.Params.ByName("name"))
@@ -303,10 +465,18 @@ func apiMirrorsUpdate(c *gin.Context) {
 	b.Name = remote.Name
 	b.DownloadUdebs = remote.DownloadUdebs
 	b.DownloadSources = remote.DownloadSources
+	b.DownloadInstaller = remote.DownloadInstaller
 	b.SkipComponentCheck = remote.SkipComponentCheck
 	b.SkipArchitectureCheck = remote.SkipArchitectureCheck
 	b.FilterWithDeps = remote.FilterWithDeps
+	b.UsePDiffs = remote.UsePDiffs
 	b.Filter = remote.Filter
+	b.IncludeSections = remote.IncludeSections
+	b.ExcludeSections = remote.ExcludeSections
+	b.MinPriority = remote.MinPriority
+	b.ChecksumPolicy = remote.ChecksumPolicy
+	b.Quarantine = remote.Quarantine
+	b.KeepVersions = remote.KeepVersions
 	b.Architectures = remote.Architectures
 	b.Components = remote.Components
 	b.IgnoreSignatures = context.Config().GpgDisableVerify
@@ -332,63 +502,197 @@ func apiMirrorsUpdate(c *gin.Context) {
 		}
 	}
 
+	if b.DownloadInstaller != remote.DownloadInstaller {
+		if remote.IsFlat() && b.DownloadInstaller {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: flat mirrors don't support installer images"))
+			return
+		}
+	}
+
+	if b.MinPriority != "" {
+		err = deb.ValidatePriority(b.MinPriority)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	err = deb.ValidateChecksumPolicy(b.ChecksumPolicy)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+		return
+	}
+
+	if b.KeepVersions < 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to update: number of versions to keep should be non-negative"))
+		return
+	}
+
+	if b.IgnoreChecksums && b.ChecksumPolicy == deb.ChecksumPolicyStrict {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to update: checksums can't be ignored for mirror with strict checksum policy"))
+		return
+	}
+
 	if b.ArchiveURL != "" {
 		remote.SetArchiveRoot(b.ArchiveURL)
+		remote.ClearDebianSnapshot()
+	}
+
+	if b.SnapshotTime != "" {
+		err = remote.ApplyDebianSnapshotTime(b.SnapshotTime)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	if b.AlternateURLs != nil {
+		remote.AlternateURLs = *b.AlternateURLs
 	}
 
 	remote.Name = b.Name
 	remote.DownloadUdebs = b.DownloadUdebs
 	remote.DownloadSources = b.DownloadSources
+	remote.DownloadInstaller = b.DownloadInstaller
 	remote.SkipComponentCheck = b.SkipComponentCheck
 	remote.SkipArchitectureCheck = b.SkipArchitectureCheck
 	remote.FilterWithDeps = b.FilterWithDeps
+	remote.UsePDiffs = b.UsePDiffs
 	remote.Filter = b.Filter
+	remote.IncludeSections = b.IncludeSections
+	remote.ExcludeSections = b.ExcludeSections
+	remote.MinPriority = strings.ToLower(b.MinPriority)
+	remote.ChecksumPolicy = b.ChecksumPolicy
+	remote.Quarantine = b.Quarantine
+	remote.KeepVersions = b.KeepVersions
 	remote.Architectures = b.Architectures
 	remote.Components = b.Components
 
-	verifier, err := getVerifier(b.Keyrings)
+	for _, setting := range []struct {
+		value  *string
+		target *string
+	}{
+		{b.Proxy, &remote.Proxy},
+		{b.Username, &remote.Username},
+		{b.PasswordFile, &remote.PasswordFile},
+		{b.PasswordEnv, &remote.PasswordEnv},
+		{b.TLSClientCert, &remote.TLSClientCert},
+		{b.TLSClientKey, &remote.TLSClientKey},
+		{b.TLSCACert, &remote.TLSCACert},
+	} {
+		if setting.value != nil {
+			*setting.target = *setting.value
+		}
+	}
+
+	verifier, err := getVerifier(b.Keyrings, remote)
 	if err != nil {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
 		return
 	}
 
 	resources := []string{string(remote.Key())}
-	maybeRunTaskInBackground(c, "Update mirror "+b.Name, resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+	maybeRunTaskInBackground(c, "Update mirror "+b.Name, resources, observeTask(mirrorUpdateDurationSummary, []string{b.Name},
+		mirrorUpdateProcess(remote, collectionFactory, verifier, mirrorUpdateOptions{
+			IgnoreSignatures:     b.IgnoreSignatures,
+			IgnoreChecksums:      b.IgnoreChecksums,
+			ForceUpdate:          b.ForceUpdate,
+			ForceIndexes:         b.ForceIndexes,
+			SkipExistingPackages: b.SkipExistingPackages,
+		})))
+}
+
+// mirrorUpdateOptions are settings of mirror update which are not stored in the mirror itself
+type mirrorUpdateOptions struct {
+	IgnoreSignatures     bool
+	IgnoreChecksums      bool
+	ForceUpdate          bool
+	ForceIndexes         bool
+	SkipExistingPackages bool
+}
+
+// mirrorUpdateProcess returns task process which downloads updated package indexes and packages of the mirror
+func mirrorUpdateProcess(remote *deb.RemoteRepo, collectionFactory *deb.CollectionFactory, verifier pgp.Verifier,
+	options mirrorUpdateOptions) task.Process {
+	collection := collectionFactory.RemoteRepoCollection()
+
+	return func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+		downloader, err := getRemoteDownloader(remote, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
+		_, err = remote.FetchIfModified(downloader, verifier, options.IgnoreSignatures)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
 
-		downloader := context.NewDownloader(out)
-		err := remote.Fetch(downloader, verifier, b.IgnoreSignatures)
+		err = remote.FetchUpstreamSources(downloader)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
-		if !b.ForceUpdate {
+		if !options.ForceUpdate {
 			err = remote.CheckLock()
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 			}
 		}
 
-		err = remote.DownloadPackageIndexes(out, downloader, verifier, collectionFactory, b.IgnoreSignatures, b.SkipComponentCheck)
+		var filterQuery deb.PackageQuery
+		if remote.Filter != "" {
+			filterQuery, err = query.ParseWithPackageSets(remote.Filter, collectionFactory.PackageSetCollection())
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			}
+		}
+
+		// previous list of packages is needed to record the update and to retain older versions
+		err = collection.LoadComplete(remote)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
-		if remote.Filter != "" {
-			var filterQuery deb.PackageQuery
+		// package sets used in filter might have been changed since last update
+		resumed := false
+		if !options.ForceIndexes && (filterQuery == nil || len(query.PackageSetNames(filterQuery)) == 0) {
+			if remote.IndexesUnchanged() {
+				remote.MarkAsChecked()
+				err = collection.Update(remote)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+				}
 
-			filterQuery, err = query.Parse(remote.Filter)
+				log.Info().Msgf("%s: Mirror is up to date", remote.Name)
+				return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
+			}
+
+			resumed, err = remote.ResumeDownload(collectionFactory, out)
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 			}
+		}
 
-			_, _, err = remote.ApplyFilter(context.DependencyOptions(), filterQuery, out)
+		if resumed {
+			log.Info().Msgf("%s: Resuming interrupted update", remote.Name)
+		} else {
+			remote.SetIndexCache(context.IndexCachePath(remote))
+
+			err = remote.DownloadPackageIndexes(out, context.IndexCache().Downloader(downloader), verifier, collectionFactory, options.IgnoreSignatures, remote.SkipComponentCheck)
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 			}
+
+			if filterQuery != nil {
+				_, _, err = remote.ApplyFilter(context.DependencyOptions(), filterQuery, out)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+				}
+			}
 		}
 
 		queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), collectionFactory.PackageCollection(),
-			collectionFactory.ChecksumCollection(nil), b.SkipExistingPackages)
+			collectionFactory.ChecksumCollection(nil), options.SkipExistingPackages)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
@@ -402,6 +706,12 @@ func apiMirrorsUpdate(c *gin.Context) {
 			}
 		}()
 
+		// save list of packages, so that interrupted update could be resumed without parsing indexes again
+		err = remote.SaveResumeState(collectionFactory)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
 		remote.MarkAsUpdating()
 		err = collection.Update(remote)
 		if err != nil {
@@ -410,6 +720,18 @@ func apiMirrorsUpdate(c *gin.Context) {
 
 		context.GoContextHandleSignals()
 
+		// downloads are aborted either on signal or when task is canceled
+		downloadCtx, cancelDownloads := gocontext.WithCancel(context)
+		defer cancelDownloads()
+
+		go func() {
+			select {
+			case <-detail.Context().Done():
+				cancelDownloads()
+			case <-downloadCtx.Done():
+			}
+		}()
+
 		count := len(queue)
 		taskDetail := struct {
 			TotalDownloadSize         int64
@@ -421,6 +743,8 @@ func apiMirrorsUpdate(c *gin.Context) {
 		}
 		detail.Store(taskDetail)
 
+		quarantinePath := context.QuarantinePath(remote)
+
 		downloadQueue := make(chan int)
 		taskFinished := make(chan *deb.PackageDownloadTask)
 
@@ -439,7 +763,7 @@ func apiMirrorsUpdate(c *gin.Context) {
 			for idx := range queue {
 				select {
 				case downloadQueue <- idx:
-				case <-context.Done():
+				case <-downloadCtx.Done():
 					return
 				}
 			}
@@ -461,9 +785,9 @@ func apiMirrorsUpdate(c *gin.Context) {
 			}
 		}()
 
-		log.Info().Msgf("%s: Spawning background processes...", b.Name)
+		log.Info().Msgf("%s: Spawning background processes...", remote.Name)
 		var wg sync.WaitGroup
-		for i := 0; i < context.Config().DownloadConcurrency; i++ {
+		for i := 0; i < remote.DownloadConcurrency(context.Config().DownloadConcurrency); i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -495,16 +819,19 @@ func apiMirrorsUpdate(c *gin.Context) {
 						}
 
 						// download file...
-						e = context.Downloader().DownloadWithChecksum(
-							context,
-							remote.PackageURL(task.File.DownloadURL()).String(),
-							task.TempDownPath,
-							&task.File.Checksums,
-							b.IgnoreChecksums)
+						mirrorDownloadsCounter.WithLabelValues(remote.Name).Inc()
+						e = remote.DownloadPackageFile(downloadCtx, downloader, task, options.IgnoreChecksums, quarantinePath, out)
 						if e != nil {
+							mirrorDownloadErrorsCounter.WithLabelValues(remote.Name).Inc()
 							pushError(e)
 							continue
 						}
+						mirrorDownloadedBytesCounter.WithLabelValues(remote.Name).Add(float64(task.File.Checksums.Size))
+
+						if task.Quarantined {
+							taskFinished <- task
+							continue
+						}
 
 						// and import it back to the pool
 						task.File.PoolPath, err = context.PackagePool().Import(task.TempDownPath, task.File.Filename, &task.File.Checksums, true, collectionFactory.ChecksumCollection(nil))
@@ -522,7 +849,7 @@ func apiMirrorsUpdate(c *gin.Context) {
 
 						task.Done = true
 						taskFinished <- task
-					case <-context.Done():
+					case <-downloadCtx.Done():
 						return
 					}
 
@@ -531,9 +858,9 @@ func apiMirrorsUpdate(c *gin.Context) {
 		}
 
 		// Wait for all download goroutines to finish
-		log.Info().Msgf("%s: Waiting for background processes to finish...", b.Name)
+		log.Info().Msgf("%s: Waiting for background processes to finish...", remote.Name)
 		wg.Wait()
-		log.Info().Msgf("%s: Background processes finished", b.Name)
+		log.Info().Msgf("%s: Background processes finished", remote.Name)
 		close(taskFinished)
 
 		defer func() {
@@ -549,24 +876,30 @@ func apiMirrorsUpdate(c *gin.Context) {
 		}()
 
 		select {
-		case <-context.Done():
+		case <-downloadCtx.Done():
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: interrupted")
 		default:
 		}
 
 		if len(errors) > 0 {
-			log.Info().Msgf("%s: Unable to update because of previous errors", b.Name)
+			log.Info().Msgf("%s: Unable to update because of previous errors", remote.Name)
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
 		}
 
-		log.Info().Msgf("%s: Finalizing download...", b.Name)
+		if skipped := remote.SkipQuarantined(queue); len(skipped) > 0 {
+			out.Printf("%d packages skipped, as their files failed verification (quarantined in %s): %s\n", len(skipped), quarantinePath, strings.Join(skipped, ", "))
+		}
+
+		log.Info().Msgf("%s: Finalizing download...", remote.Name)
 		remote.FinalizeDownload(collectionFactory, out)
 		err = collectionFactory.RemoteRepoCollection().Update(remote)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
-		log.Info().Msgf("%s: Mirror updated successfully", b.Name)
+		context.Notify(webhook.EventMirrorUpdated, map[string]interface{}{"mirror": remote}, nil)
+
+		log.Info().Msgf("%s: Mirror updated successfully", remote.Name)
 		return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
-	})
+	}
 }
@@ -2,8 +2,15 @@ package api
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/gin-gonic/gin"
 	. "gopkg.in/check.v1"
 )
@@ -26,6 +33,15 @@ func (s *MirrorSuite) TestDeleteMirrorNonExisting(c *C) {
 	c.Check(response.Body.String(), Equals, "{\"error\":\"unable to drop: mirror with name does-not-exist not found\"}")
 }
 
+func (s *MirrorSuite) TestMirrorStatus(c *C) {
+	response, _ := s.HTTPRequest("GET", "/api/mirrors/does-not-exist/status", nil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Equals, "{\"error\":\"unable to show status: mirror with name does-not-exist not found\"}")
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/does-not-exist/status?staleAfter=2days", nil)
+	c.Check(response.Code, Equals, 400)
+}
+
 func (s *MirrorSuite) TestCreateMirror(c *C) {
 	c.ExpectFailure("Need to mock downloads")
 	body, err := json.Marshal(gin.H{
@@ -38,3 +54,117 @@ func (s *MirrorSuite) TestCreateMirror(c *C) {
 	c.Check(response.Code, Equals, 400)
 	c.Check(response.Body.String(), Equals, "")
 }
+
+// fakeArchive serves Debian archive with single component & architecture, list of packages
+// could be replaced between mirror updates
+type fakeArchive struct {
+	files map[string][]byte
+}
+
+func (archive *fakeArchive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, ok := archive.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Write(data)
+}
+
+// setPackages publishes packages (by name and version) in the archive
+func (archive *fakeArchive) setPackages(versions map[string]string) {
+	archive.files = map[string][]byte{}
+
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var packages bytes.Buffer
+	for _, name := range names {
+		filename := fmt.Sprintf("pool/main/%s_%s_amd64.deb", name, versions[name])
+		contents := []byte(name + " " + versions[name])
+		archive.files["/"+filename] = contents
+
+		fmt.Fprintf(&packages, "Package: %s\nVersion: %s\nArchitecture: amd64\nFilename: %s\nSize: %d\nMD5sum: %x\nSHA256: %x\n\n",
+			name, versions[name], filename, len(contents), md5.Sum(contents), sha256.Sum256(contents))
+	}
+	archive.files["/dists/stable/main/binary-amd64/Packages"] = packages.Bytes()
+
+	archive.files["/dists/stable/Release"] = []byte(fmt.Sprintf("Suite: stable\nCodename: stable\nArchitectures: amd64\nComponents: main\n"+
+		"MD5Sum:\n %x %d main/binary-amd64/Packages\nSHA256:\n %x %d main/binary-amd64/Packages\n",
+		md5.Sum(packages.Bytes()), packages.Len(), sha256.Sum256(packages.Bytes()), packages.Len()))
+}
+
+// createMirror creates mirror of the archive, mirror is dropped at the end of the test
+func (s *MirrorSuite) createMirror(c *C, name, archiveURL string, keepVersions int) {
+	body, err := json.Marshal(gin.H{
+		"Name":             name,
+		"ArchiveURL":       archiveURL,
+		"Distribution":     "stable",
+		"IgnoreSignatures": true,
+		"KeepVersions":     keepVersions,
+	})
+	c.Assert(err, IsNil)
+	response, _ := s.HTTPRequest("POST", "/api/mirrors", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 201, Commentf("%s", response.Body.String()))
+}
+
+func (s *MirrorSuite) updateMirror(c *C, name string, params gin.H) {
+	body, err := json.Marshal(params)
+	c.Assert(err, IsNil)
+	response, _ := s.HTTPRequest("PUT", "/api/mirrors/"+name, bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 204, Commentf("%s", response.Body.String()))
+}
+
+func (s *MirrorSuite) TestUpdateMirrorForceIndexesHistory(c *C) {
+	archive := &fakeArchive{}
+	archive.setPackages(map[string]string{"alpha": "1.0", "beta": "1.0"})
+	server := httptest.NewServer(archive)
+	defer server.Close()
+
+	s.createMirror(c, "force-indexes-history", server.URL, 0)
+	defer s.HTTPRequest("DELETE", "/api/mirrors/force-indexes-history?force=1", nil)
+
+	s.updateMirror(c, "force-indexes-history", gin.H{"IgnoreSignatures": true, "ForceIndexes": true})
+
+	archive.setPackages(map[string]string{"alpha": "2.0", "beta": "1.0"})
+	s.updateMirror(c, "force-indexes-history", gin.H{"IgnoreSignatures": true, "ForceIndexes": true})
+
+	// update is recorded against previous list of packages
+	response, _ := s.HTTPRequest("GET", "/api/mirrors/force-indexes-history/status", nil)
+	c.Assert(response.Code, Equals, 200)
+	var health deb.MirrorHealth
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &health), IsNil)
+	c.Assert(health.Updates, HasLen, 2)
+	c.Check(health.Updates[0].Added, Equals, 1)
+	c.Check(health.Updates[0].Removed, Equals, 1)
+	c.Check(health.Updates[0].Total, Equals, 2)
+}
+
+func (s *MirrorSuite) TestUpdateMirrorForceIndexesKeepVersions(c *C) {
+	archive := &fakeArchive{}
+	archive.setPackages(map[string]string{"alpha": "1.0", "beta": "1.0"})
+	server := httptest.NewServer(archive)
+	defer server.Close()
+
+	s.createMirror(c, "force-indexes-keep", server.URL, 2)
+	defer s.HTTPRequest("DELETE", "/api/mirrors/force-indexes-keep?force=1", nil)
+
+	s.updateMirror(c, "force-indexes-keep", gin.H{"IgnoreSignatures": true, "ForceIndexes": true})
+
+	archive.setPackages(map[string]string{"alpha": "2.0", "beta": "1.0"})
+	s.updateMirror(c, "force-indexes-keep", gin.H{"IgnoreSignatures": true, "ForceIndexes": true})
+
+	// previous version is retained
+	response, _ := s.HTTPRequest("GET", "/api/mirrors/force-indexes-keep/packages", nil)
+	c.Assert(response.Code, Equals, 200)
+	var refs []string
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &refs), IsNil)
+	sort.Strings(refs)
+	c.Assert(refs, HasLen, 3)
+	c.Check(refs[0], Matches, "Pamd64 alpha 1.0 .*")
+	c.Check(refs[1], Matches, "Pamd64 alpha 2.0 .*")
+	c.Check(refs[2], Matches, "Pamd64 beta 1.0 .*")
+}
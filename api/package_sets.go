@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/package-sets
+func apiPackageSetsList(c *gin.Context) {
+	result := []*deb.PackageSet{}
+
+	collectionFactory := newCollectionFactory(c)
+	collectionFactory.PackageSetCollection().ForEach(func(set *deb.PackageSet) error {
+		result = append(result, set)
+		return nil
+	})
+
+	c.JSON(200, result)
+}
+
+// POST /api/package-sets
+func apiPackageSetsCreate(c *gin.Context) {
+	var b struct {
+		Name    string `binding:"required"`
+		Comment string
+		Queries []string `binding:"required"`
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PackageSetCollection()
+
+	for _, q := range b.Queries {
+		_, err := query.ParseWithPackageSets(q, collection)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to create package set: %s", err))
+			return
+		}
+	}
+
+	set := deb.NewPackageSet(b.Name, b.Comment, b.Queries)
+
+	err := collection.Add(set)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	c.JSON(201, set)
+}
+
+// GET /api/package-sets/:name
+func apiPackageSetsShow(c *gin.Context) {
+	collectionFactory := newCollectionFactory(c)
+
+	set, err := collectionFactory.PackageSetCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	c.JSON(200, set)
+}
+
+// PUT /api/package-sets/:name
+func apiPackageSetsEdit(c *gin.Context) {
+	var b struct {
+		Comment *string
+		Queries []string
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PackageSetCollection()
+
+	set, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if b.Queries != nil {
+		oldQueries := set.Queries
+		set.Queries = b.Queries
+
+		_, err = query.ParseWithPackageSets(fmt.Sprintf("$PackageSet (%s)", set.Name), collection)
+		if err != nil {
+			set.Queries = oldQueries
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit package set: %s", err))
+			return
+		}
+	}
+	if b.Comment != nil {
+		set.Comment = *b.Comment
+	}
+
+	err = collection.Update(set)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, set)
+}
+
+// DELETE /api/package-sets/:name
+func apiPackageSetsDrop(c *gin.Context) {
+	force := c.Request.URL.Query().Get("force") == "1"
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PackageSetCollection()
+
+	set, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if !force {
+		references := query.PackageSetReferences(set.Name, collectionFactory)
+		if len(references) > 0 {
+			AbortWithJSONError(c, 409, fmt.Errorf("unable to drop, package set is referenced by %d entities, use ?force=1 to override", len(references)))
+			return
+		}
+	}
+
+	err = collection.Drop(set)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
@@ -1,24 +1,184 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/gin-gonic/gin"
 )
 
+// writeStanzas responds with package stanzas in deb822 format, separated with empty lines
+func writeStanzas(c *gin.Context, packages []*deb.Package) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	for i, p := range packages {
+		if i > 0 {
+			_ = w.WriteByte('\n')
+		}
+
+		err := p.Stanza().WriteTo(w, p.IsSource, false, p.IsInstaller)
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.Data(200, "text/plain; charset=utf-8", buf.Bytes())
+}
+
 // GET /api/packages/:key
+//
+// With `format=deb822` package stanza is returned as in Packages/Sources index.
 func apiPackagesShow(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	p, err := collectionFactory.PackageCollection().ByKey([]byte(c.Params.ByName("key")))
 	if err != nil {
 		AbortWithJSONError(c, 404, err)
 		return
 	}
 
+	if c.Request.URL.Query().Get("format") == "deb822" {
+		writeStanzas(c, []*deb.Package{p})
+		return
+	}
+
 	c.JSON(200, p)
 }
 
+// POST /api/packages/stanzas
+//
+// Parses package stanzas (deb822 text, or JSON list of objects with Content-Type
+// application/json) and returns them normalized along with aptly package keys, in JSON
+// or, with `format=deb822`, in deb822 format. Nothing is stored in the database.
+func apiPackagesStanzas(c *gin.Context) {
+	var stanzas []deb.Stanza
+
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		var input []map[string]string
+		if err := json.NewDecoder(c.Request.Body).Decode(&input); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse stanzas: %s", err))
+			return
+		}
+
+		for _, fields := range input {
+			stanza := make(deb.Stanza, len(fields))
+			for field, value := range fields {
+				stanza[field] = value
+			}
+
+			// aptly-specific fields are added to JSON output, so they are dropped to allow round-trip
+			delete(stanza, "FilesHash")
+			delete(stanza, "Key")
+			delete(stanza, "ShortKey")
+
+			stanzas = append(stanzas, stanza)
+		}
+	} else {
+		reader := deb.NewPackageFileReader(c.Request.Body, false)
+		for {
+			stanza, err := reader.ReadStanza()
+			if err != nil {
+				AbortWithJSONError(c, 400, fmt.Errorf("unable to parse stanzas: %s", err))
+				return
+			}
+			if stanza == nil {
+				break
+			}
+			stanzas = append(stanzas, stanza)
+		}
+	}
+
+	packages := make([]*deb.Package, 0, len(stanzas))
+	for i, stanza := range stanzas {
+		p, err := deb.NewPackageFromStanza(stanza)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse stanza #%d: %s", i+1, err))
+			return
+		}
+		packages = append(packages, p)
+	}
+
+	if c.Request.URL.Query().Get("format") == "deb822" {
+		writeStanzas(c, packages)
+		return
+	}
+
+	c.JSON(200, packages)
+}
+
+// GET /api/packages/:key/changelog
+//
+// Changes are limited to versions newer than `since` query parameter or than version
+// of the package published in repository `distribution` (with optional `prefix`).
+func apiPackagesChangelog(c *gin.Context) {
+	collectionFactory := newCollectionFactory(c)
+	p, err := collectionFactory.PackageCollection().ByKey([]byte(c.Params.ByName("key")))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if p.IsSource {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to show changelog: %s is source package", p))
+		return
+	}
+
+	since := c.Request.URL.Query().Get("since")
+	if distribution := c.Request.URL.Query().Get("distribution"); distribution != "" {
+		if since != "" {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to show changelog: since can't be used together with distribution"))
+			return
+		}
+
+		prefix := c.Request.URL.Query().Get("prefix")
+		if prefix == "" {
+			prefix = "."
+		}
+		storage, prefix := deb.ParsePrefix(prefix)
+
+		collection := collectionFactory.PublishedRepoCollection()
+		published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			AbortWithJSONError(c, 404, fmt.Errorf("unable to show changelog: %s", err))
+			return
+		}
+
+		err = collection.LoadComplete(published, collectionFactory)
+		if err != nil {
+			AbortWithJSONError(c, 500, fmt.Errorf("unable to show changelog: %s", err))
+			return
+		}
+
+		since = deb.PublishedVersion(published, p.Name, p.Architecture)
+	}
+
+	changelog, err := p.Changelog(context.PackagePool())
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to show changelog: %s", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"Package":   string(p.Key("")),
+		"Since":     since,
+		"Changelog": deb.ChangelogEntriesSince(deb.ParseChangelog(changelog.Changelog), since),
+		"News":      deb.ChangelogEntriesSince(deb.ParseChangelog(changelog.News), since),
+	})
+}
+
 // GET /api/packages
 func apiPackages(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.PackageCollection()
-	showPackages(c, collection.AllPackageRefs(), collectionFactory)
+	showPackages(c, collection.AllPackageRefs(), nil, collectionFactory)
 }
@@ -1,6 +1,11 @@
 package api
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -16,3 +21,56 @@ func (s *PackagesSuite) TestPackagesGetMaximumVersion(c *C) {
 	c.Check(response.Code, Equals, 200)
 	c.Check(response.Body.String(), Equals, "[]")
 }
+
+func (s *PackagesSuite) TestPackagesChangelogNotFound(c *C) {
+	response, err := s.HTTPRequest("GET", "/api/packages/Pamd64%20hello%201.0%2000000000/changelog", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+const helloStanza = `Package: hello
+Architecture: amd64
+Version: 2.10-3
+Filename: pool/main/h/hello/hello_2.10-3_amd64.deb
+Size: 56132
+SHA256: 2a9e6b6a11d4f3c4dd5d2b4c38f53f3b0b8bd0dff6f6b0d2e4b7c1bf0e4c2c5a
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It allows non-programmers to use a classic computer science tool.
+`
+
+func (s *PackagesSuite) postStanzas(url, contentType, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *PackagesSuite) TestPackagesStanzas(c *C) {
+	response := s.postStanzas("/api/packages/stanzas?format=deb822", "text/plain", helloStanza)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, helloStanza)
+
+	response = s.postStanzas("/api/packages/stanzas", "text/plain", helloStanza)
+	c.Check(response.Code, Equals, 200)
+
+	var packages []map[string]string
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &packages), IsNil)
+	c.Assert(packages, HasLen, 1)
+	c.Check(packages[0]["Key"], Equals, "Pamd64 hello 2.10-3 "+packages[0]["FilesHash"])
+	c.Check(packages[0]["Description"], Equals, " example package based on GNU hello\n"+
+		" The GNU hello program produces a familiar, friendly greeting.\n .\n"+
+		" It allows non-programmers to use a classic computer science tool.\n")
+
+	// JSON is accepted as well
+	body, _ := json.Marshal(packages)
+	response = s.postStanzas("/api/packages/stanzas?format=deb822", "application/json", string(body))
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, helloStanza)
+
+	response = s.postStanzas("/api/packages/stanzas", "text/plain", "Package: hello\n")
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Equals, "{\"error\":\"unable to parse stanza #1: stanza is missing Version field\"}")
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/repos/:name/promote
+//
+// Promotion snapshots local repository, validates packages of the snapshot and switches
+// published repository to the snapshot; if validation or publishing fails, snapshot is
+// not kept and published repository is not changed.
+func apiReposPromote(c *gin.Context) {
+	var b struct {
+		// Name of the snapshot, defaults to <repo>-<timestamp>
+		SnapshotName string
+		Description  string
+		Provenance   string
+		// Published repository to switch: [<storage>:]<prefix> and distribution
+		Prefix       string
+		Distribution string `binding:"required"`
+		// Component of the published repository, could be omitted if there's single component
+		Component string
+		// Queries each package of the snapshot should match
+		Validations    []string
+		Signing        SigningOptions
+		ForceOverwrite bool
+		SkipCleanup    bool
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Prefix == "" {
+		b.Prefix = "."
+	}
+	if !checkPublishPrefix(c, b.Prefix) {
+		return
+	}
+	storage, prefix := deb.ParsePrefix(b.Prefix)
+
+	collectionFactory := newCollectionFactory(c)
+	localCollection := collectionFactory.LocalRepoCollection()
+	snapshotCollection := collectionFactory.SnapshotCollection()
+	publishedCollection := collectionFactory.PublishedRepoCollection()
+
+	repo, err := localCollection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if b.SnapshotName == "" {
+		b.SnapshotName = fmt.Sprintf("%s-%s", repo.Name, time.Now().Format("20060102150405"))
+	}
+
+	if _, err = snapshotCollection.ByName(b.SnapshotName); err == nil {
+		AbortWithJSONError(c, 409, fmt.Errorf("unable to promote: snapshot %s already exists", b.SnapshotName))
+		return
+	}
+
+	validations := make([]deb.PackageQuery, len(b.Validations))
+	for i := range b.Validations {
+		validations[i], err = query.ParseWithPackageSets(b.Validations[i], collectionFactory.PackageSetCollection())
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to promote: %s", err))
+			return
+		}
+	}
+
+	published, err := publishedCollection.ByStoragePrefixDistribution(storage, prefix, b.Distribution)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to promote: %s", err))
+		return
+	}
+
+	if published.SourceKind != deb.SourceSnapshot {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to promote: published repository %s/%s is not published from snapshots",
+			published.StoragePrefix(), published.Distribution))
+		return
+	}
+
+	err = publishedCollection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to promote: %s", err))
+		return
+	}
+
+	components := published.SourceComponents()
+	if b.Component == "" {
+		if len(components) != 1 {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to promote: published repository has several components, Component should be specified"))
+			return
+		}
+		b.Component = components[0]
+	} else if _, ok := published.Sources[b.Component]; !ok {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to promote: component %s is not in published repository", b.Component))
+		return
+	}
+
+	signer, err := getSigner(&b.Signing)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
+	}
+
+	resources := []string{string(repo.Key()), "S" + b.SnapshotName, string(published.Key())}
+	taskName := fmt.Sprintf("Promote repo %s to %s/%s", repo.Name, published.StoragePrefix(), published.Distribution)
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := localCollection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		snapshot, err := deb.NewSnapshotFromLocalRepo(b.SnapshotName, repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+		}
+		if b.Description != "" {
+			snapshot.Description = b.Description
+		}
+		snapshot.Provenance = b.Provenance
+
+		if len(validations) > 0 {
+			var list *deb.PackageList
+			list, err = deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+			}
+
+			err = deb.ValidatePackages(list, validations)
+			if err != nil {
+				var validationErr *deb.ValidationError
+				if errors.As(err, &validationErr) {
+					return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+				}
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+			}
+		}
+
+		err = snapshotCollection.Add(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+		}
+
+		// snapshot is dropped if it couldn't be published
+		rollback := func(err error) (*task.ProcessReturnValue, error) {
+			_ = snapshotCollection.Drop(snapshot)
+			context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, err)
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+		}
+
+		published.UpdateSnapshot(b.Component, snapshot)
+
+		// if only replicas failed, snapshot is published and is kept
+		saved, publishErr := publishedCollection.PublishAndSave(published, false, context.PackagePool(), context, collectionFactory, signer, out, b.ForceOverwrite, false)
+		if !saved {
+			return rollback(publishErr)
+		}
+
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+		if publishErr != nil {
+			context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+		} else {
+			context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+		}
+
+		if !b.SkipCleanup {
+			err = publishedCollection.CleanupPublishedComponentFiles(published, []string{b.Component},
+				context, collectionFactory, out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", err)
+			}
+		}
+
+		if publishErr != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to promote: %s", publishErr)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: gin.H{"Snapshot": snapshot, "Published": published}}, nil
+	})
+}
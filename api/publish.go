@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,6 +23,7 @@ type SigningOptions struct {
 	SecretKeyring  string
 	Passphrase     string
 	PassphraseFile string
+	DigestAlgo     string
 }
 
 func getSigner(options *SigningOptions) (pgp.Signer, error) {
@@ -32,6 +35,7 @@ func getSigner(options *SigningOptions) (pgp.Signer, error) {
 	signer.SetKey(options.GpgKey)
 	signer.SetKeyRing(options.Keyring, options.SecretKeyring)
 	signer.SetPassphrase(options.Passphrase, options.PassphraseFile)
+	signer.SetDigestAlgorithm(options.DigestAlgo)
 
 	// If Batch is false, GPG will ask for passphrase on stdin, which would block the api process
 	signer.SetBatch(true)
@@ -55,7 +59,7 @@ func parseEscapedPath(path string) string {
 
 // GET /publish
 func apiPublishList(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.PublishedRepoCollection()
 
 	result := make([]*deb.PublishedRepo, 0, collection.Len())
@@ -101,13 +105,68 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 		Architectures        []string
 		Signing              SigningOptions
 		AcquireByHash        *bool
+		PDiffs               *bool
+		DebianFieldOrder     *bool
+		ArchitectureAllMode  string
+		IncludeArchitectures []string
+		ExcludeArchitectures []string
 		MultiDist            bool
+		Description          string
+		Provenance           string
+		ValidFor             string
+		ReleaseFields        map[string]string
+		Overrides            deb.OverrideTable
+		SourceOverrides      deb.OverrideTable
+		PublishKey           bool
+		PublicURL            string
+		Aliases              []string
+		Replicas             []string
+		ComponentRules       []deb.ComponentRule
 	}
 
 	if c.Bind(&b) != nil {
 		return
 	}
 
+	var validFor time.Duration
+	if b.ValidFor != "" {
+		var err error
+		validFor, err = time.ParseDuration(b.ValidFor)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: invalid ValidFor: %s", err))
+			return
+		}
+	}
+
+	if err := deb.ValidateReleaseFields(b.ReleaseFields); err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: %s", err))
+		return
+	}
+
+	if err := b.Overrides.Validate(); err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: %s", err))
+		return
+	}
+
+	if err := b.SourceOverrides.ValidateSource(); err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: %s", err))
+		return
+	}
+
+	if b.PublicURL != "" {
+		if err := deb.ValidatePublicURL(b.PublicURL); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: %s", err))
+			return
+		}
+	}
+
+	for _, replica := range b.Replicas {
+		if err := context.CheckPublishedStorage(replica); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to publish: %s", err))
+			return
+		}
+	}
+
 	signer, err := getSigner(&b.Signing)
 	if err != nil {
 		AbortWithJSONError(c, 500, fmt.Errorf("unable to initialize GPG signer: %s", err))
@@ -123,7 +182,7 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 	var names []string
 	var sources []interface{}
 	var resources []string
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 
 	if b.SourceKind == "snapshot" {
 		var snapshot *deb.Snapshot
@@ -177,6 +236,11 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 		return
 	}
 
+	if b.ArchitectureAllMode != "" && !utils.StrSliceHasItem(deb.ArchitectureAllModes, b.ArchitectureAllMode) {
+		AbortWithJSONError(c, 400, fmt.Errorf("unknown mode for architecture all: %s", b.ArchitectureAllMode))
+		return
+	}
+
 	published, err := deb.NewPublishedRepo(storage, prefix, b.Distribution, b.Architectures, components, sources, collectionFactory)
 	if err != nil {
 		AbortWithJSONError(c, 500, fmt.Errorf("unable to publish: %s", err))
@@ -187,7 +251,7 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 	collection := collectionFactory.PublishedRepoCollection()
 
 	taskName := fmt.Sprintf("Publish %s: %s", b.SourceKind, strings.Join(names, ", "))
-	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+	maybeRunTaskInBackground(c, taskName, resources, observeTask(publishDurationSummary, []string{published.StoragePrefix(), published.Distribution}, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
 		taskDetail := task.PublishDetail{
 			Detail: detail,
 		}
@@ -206,6 +270,8 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 			published.ButAutomaticUpgrades = b.ButAutomaticUpgrades
 		}
 		published.Label = b.Label
+		published.Description = b.Description
+		published.Provenance = b.Provenance
 
 		published.SkipContents = context.Config().SkipContentsPublishing
 		if b.SkipContents != nil {
@@ -221,24 +287,60 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 			published.AcquireByHash = *b.AcquireByHash
 		}
 
+		if b.PDiffs != nil {
+			published.PDiffs = *b.PDiffs
+		}
+
+		if b.DebianFieldOrder != nil {
+			published.DebianFieldOrder = *b.DebianFieldOrder
+		}
+
+		published.ArchitectureAllMode = b.ArchitectureAllMode
+		published.IncludeArchitectures = b.IncludeArchitectures
+		published.ExcludeArchitectures = b.ExcludeArchitectures
+		published.ValidFor = validFor
+		published.ReleaseFields = b.ReleaseFields
+		published.Overrides = b.Overrides
+		published.SourceOverrides = b.SourceOverrides
+		published.PublishKey = b.PublishKey
+		published.PublicURL = b.PublicURL
+
+		err = published.SetAliases(b.Aliases)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = published.SetReplicas(b.Replicas)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = published.SetComponentRules(b.ComponentRules, collectionFactory)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to publish: %s", err)
+		}
+
 		duplicate := collection.CheckDuplicate(published)
 		if duplicate != nil {
 			collectionFactory.PublishedRepoCollection().LoadComplete(duplicate, collectionFactory)
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("prefix/distribution already used by another published repo: %s", duplicate)
 		}
 
-		err := published.Publish(context.PackagePool(), context, collectionFactory, signer, publishOutput, b.ForceOverwrite, b.MultiDist)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to publish: %s", err)
-		}
+		saved, publishErr := collection.PublishAndSave(published, true, context.PackagePool(), context, collectionFactory, signer, publishOutput, b.ForceOverwrite, b.MultiDist)
+		if publishErr != nil {
+			context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
 
-		err = collection.Add(published)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save to DB: %s", err)
+			value := interface{}(nil)
+			if saved {
+				value = published
+			}
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: value}, fmt.Errorf("unable to publish: %s", publishErr)
 		}
 
+		context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: published}, nil
-	})
+	}))
 }
 
 // PUT /publish/:prefix/:distribution
@@ -257,21 +359,81 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 			Component string `binding:"required"`
 			Name      string `binding:"required"`
 		}
-		AcquireByHash *bool
-		MultiDist     bool
+		AcquireByHash    *bool
+		PDiffs           *bool
+		DebianFieldOrder *bool
+		MultiDist        bool
+		Description      *string
+		Provenance       *string
+		ValidFor         *string
+		ReleaseFields    *map[string]string
+		Overrides        *deb.OverrideTable
+		SourceOverrides  *deb.OverrideTable
+		PublishKey       *bool
+		PublicURL        *string
+		Aliases          *[]string
+		Replicas         *[]string
+		ComponentRules   *[]deb.ComponentRule
 	}
 
 	if c.Bind(&b) != nil {
 		return
 	}
 
+	var validFor time.Duration
+	if b.ValidFor != nil {
+		var err error
+		validFor, err = time.ParseDuration(*b.ValidFor)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: invalid ValidFor: %s", err))
+			return
+		}
+	}
+
+	if b.ReleaseFields != nil {
+		if err := deb.ValidateReleaseFields(*b.ReleaseFields); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	if b.Overrides != nil {
+		if err := b.Overrides.Validate(); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	if b.SourceOverrides != nil {
+		if err := b.SourceOverrides.ValidateSource(); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	if b.PublicURL != nil && *b.PublicURL != "" {
+		if err := deb.ValidatePublicURL(*b.PublicURL); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
+	if b.Replicas != nil {
+		for _, replica := range *b.Replicas {
+			if err := context.CheckPublishedStorage(replica); err != nil {
+				AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+				return
+			}
+		}
+	}
+
 	signer, err := getSigner(&b.Signing)
 	if err != nil {
 		AbortWithJSONError(c, 500, fmt.Errorf("unable to initialize GPG signer: %s", err))
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.PublishedRepoCollection()
 
 	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
@@ -294,12 +456,12 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 			AbortWithJSONError(c, 400, fmt.Errorf("snapshots shouldn't be given when updating local repo"))
 			return
 		}
-		updatedComponents = published.Components()
+		updatedComponents = published.SourceComponents()
 		for _, component := range updatedComponents {
 			published.UpdateLocalRepo(component)
 		}
 	} else if published.SourceKind == "snapshot" {
-		publishedComponents := published.Components()
+		publishedComponents := published.SourceComponents()
 		for _, snapshotInfo := range b.Snapshots {
 			if !utils.StrSliceHasItem(publishedComponents, snapshotInfo.Component) {
 				AbortWithJSONError(c, 404, fmt.Errorf("component %s is not in published repository", snapshotInfo.Component))
@@ -340,24 +502,233 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 		published.AcquireByHash = *b.AcquireByHash
 	}
 
+	if b.PDiffs != nil {
+		published.PDiffs = *b.PDiffs
+	}
+
+	if b.DebianFieldOrder != nil {
+		published.DebianFieldOrder = *b.DebianFieldOrder
+	}
+
+	if b.Description != nil {
+		published.Description = *b.Description
+	}
+
+	if b.Provenance != nil {
+		published.Provenance = *b.Provenance
+	}
+
+	if b.ValidFor != nil {
+		published.ValidFor = validFor
+	}
+
+	if b.ReleaseFields != nil {
+		published.ReleaseFields = *b.ReleaseFields
+	}
+
+	if b.Overrides != nil {
+		published.Overrides = *b.Overrides
+	}
+
+	if b.SourceOverrides != nil {
+		published.SourceOverrides = *b.SourceOverrides
+	}
+
+	if b.PublishKey != nil {
+		published.PublishKey = *b.PublishKey
+	}
+
+	if b.PublicURL != nil {
+		published.PublicURL = *b.PublicURL
+	}
+
+	if b.Aliases != nil {
+		err = published.SetAliases(*b.Aliases)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+
+		duplicate := collection.CheckAliasDuplicate(published)
+		if duplicate != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: distribution alias already used by another published repo: %s/%s", duplicate.StoragePrefix(), duplicate.Distribution))
+			return
+		}
+	}
+
+	if b.Replicas != nil {
+		err = published.SetReplicas(*b.Replicas)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+
+		duplicate := collection.CheckAliasDuplicate(published)
+		if duplicate != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: distribution already published to replica by another published repo: %s/%s", duplicate.StoragePrefix(), duplicate.Distribution))
+			return
+		}
+	}
+
+	if b.ComponentRules != nil {
+		err = published.SetComponentRules(*b.ComponentRules, collectionFactory)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
+	}
+
 	resources = append(resources, string(published.Key()))
 	taskName := fmt.Sprintf("Update published %s (%s): %s", published.SourceKind, strings.Join(updatedComponents, " "), strings.Join(updatedSnapshots, ", "))
+	maybeRunTaskInBackground(c, taskName, resources, observeTask(publishDurationSummary, []string{published.StoragePrefix(), published.Distribution}, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		saved, publishErr := collection.PublishAndSave(published, false, context.PackagePool(), context, collectionFactory, signer, out, b.ForceOverwrite, b.MultiDist)
+		if publishErr != nil {
+			context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+		} else {
+			context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+		}
+
+		if !saved {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", publishErr)
+		}
+
+		if b.SkipCleanup == nil || !*b.SkipCleanup {
+			err := collection.CleanupPublishedComponentFiles(published, updatedComponents, context, collectionFactory, out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			}
+		}
+
+		if publishErr != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: published}, fmt.Errorf("unable to update: %s", publishErr)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
+	}))
+}
+
+// POST /publish/:prefix/:distribution/refresh
+func apiPublishRefresh(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	var b struct {
+		Signing SigningOptions
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	signer, err := getSigner(&b.Signing)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to refresh: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to refresh: %s", err))
+		return
+	}
+
+	resources := []string{string(published.Key())}
+
+	taskName := fmt.Sprintf("Refresh published %s (%s)", prefix, distribution)
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
-		err := published.Publish(context.PackagePool(), context, collectionFactory, signer, out, b.ForceOverwrite, b.MultiDist)
+		refreshErr := published.RefreshRelease(context, signer, out)
+		if _, ok := refreshErr.(*deb.ReplicaPublishError); refreshErr != nil && !ok {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to refresh: %s", refreshErr)
+		}
+
+		err := collection.Update(published)
 		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save to DB: %s", err)
+		}
+
+		if refreshErr != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: published}, fmt.Errorf("unable to refresh: %s", refreshErr)
 		}
 
-		err = collection.Update(published)
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
+	})
+}
+
+// POST /publish/:prefix/:distribution/resume
+func apiPublishResume(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	var b struct {
+		ForceOverwrite bool
+		Signing        SigningOptions
+		SkipCleanup    *bool
+		MultiDist      bool
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	signer, err := getSigner(&b.Signing)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to resume: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to resume: %s", err))
+		return
+	}
+
+	if len(published.FailedReplicas) == 0 {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to resume: published repository has no failed replicas"))
+		return
+	}
+
+	resources := []string{string(published.Key())}
+
+	taskName := fmt.Sprintf("Resume publishing of %s (%s) to replicas: %s", prefix, distribution, strings.Join(published.FailedReplicas, ", "))
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		publishErr := published.ResumeReplicas(context.PackagePool(), context, collectionFactory, signer, out, b.ForceOverwrite, b.MultiDist)
+
+		err := collection.Update(published)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save to DB: %s", err)
 		}
 
+		if publishErr != nil {
+			context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: published}, fmt.Errorf("unable to resume: %s", publishErr)
+		}
+
+		context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+
 		if b.SkipCleanup == nil || !*b.SkipCleanup {
-			err = collection.CleanupPrefixComponentFiles(published.Prefix, updatedComponents,
-				context.GetPublishedStorage(storage), collectionFactory, out)
+			err = collection.CleanupPublishedComponentFiles(published, published.Components(), context, collectionFactory, out)
 			if err != nil {
-				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to resume: %s", err)
 			}
 		}
 
@@ -365,6 +736,83 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 	})
 }
 
+// GET /publish/:prefix/:distribution/sources
+func apiPublishSources(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	collection := newCollectionFactory(c).PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to generate sources: %s", err))
+		return
+	}
+
+	baseURL := c.Request.URL.Query().Get("url")
+	if baseURL == "" {
+		baseURL = published.PublicURL
+	}
+	if baseURL == "" {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to generate sources: URL published repository is served from is unknown"))
+		return
+	}
+
+	err = deb.ValidatePublicURL(baseURL)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to generate sources: %s", err))
+		return
+	}
+
+	switch format := c.Request.URL.Query().Get("format"); format {
+	case "", "deb822":
+		c.String(http.StatusOK, published.SourcesEntry(baseURL))
+	case "list":
+		c.String(http.StatusOK, published.SourcesListEntry(baseURL))
+	default:
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to generate sources: unknown format %s, expected deb822 or list", format))
+	}
+}
+
+// POST /publish/:prefix/cleanup
+func apiPublishCleanup(c *gin.Context) {
+	dryRun := c.Request.URL.Query().Get("DryRun") == "1"
+
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.PublishedRepoCollection()
+
+	resources := []string{}
+	_ = collection.ForEach(func(published *deb.PublishedRepo) error {
+		if published.Prefix == prefix && utils.StrSliceHasItem(published.Storages(), storage) {
+			resources = append(resources, string(published.Key()))
+		}
+		return nil
+	})
+
+	if len(resources) == 0 {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to cleanup: no published repositories under prefix %s", prefix))
+		return
+	}
+
+	taskName := fmt.Sprintf("Cleanup published prefix %s", prefix)
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		removed, err := collection.CleanupPrefix(storage, prefix, context.GetPublishedStorage(storage), collectionFactory, out, dryRun)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to cleanup: %s", err)
+		}
+
+		if removed == nil {
+			removed = []string{}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{"Removed": removed, "DryRun": dryRun}}, nil
+	})
+}
+
 // DELETE /publish/:prefix/:distribution
 func apiPublishDrop(c *gin.Context) {
 	force := c.Request.URL.Query().Get("force") == "1"
@@ -374,7 +822,7 @@ func apiPublishDrop(c *gin.Context) {
 	storage, prefix := deb.ParsePrefix(param)
 	distribution := c.Params.ByName("distribution")
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.PublishedRepoCollection()
 
 	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
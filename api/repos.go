@@ -54,7 +54,7 @@ func reposServeInAPIMode(c *gin.Context) {
 func apiReposList(c *gin.Context) {
 	result := []*deb.LocalRepo{}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 	collection.ForEach(func(r *deb.LocalRepo) error {
 		result = append(result, r)
@@ -81,7 +81,7 @@ func apiReposCreate(c *gin.Context) {
 	repo.DefaultComponent = b.DefaultComponent
 	repo.DefaultDistribution = b.DefaultDistribution
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 	err := collection.Add(repo)
 	if err != nil {
@@ -105,7 +105,7 @@ func apiReposEdit(c *gin.Context) {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 
 	repo, err := collection.ByName(c.Params.ByName("name"))
@@ -144,7 +144,7 @@ func apiReposEdit(c *gin.Context) {
 
 // GET /api/repos/:name
 func apiReposShow(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 
 	repo, err := collection.ByName(c.Params.ByName("name"))
@@ -161,7 +161,7 @@ func apiReposDrop(c *gin.Context) {
 	force := c.Request.URL.Query().Get("force") == "1"
 	name := c.Params.ByName("name")
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
 	publishedCollection := collectionFactory.PublishedRepoCollection()
@@ -193,7 +193,7 @@ func apiReposDrop(c *gin.Context) {
 
 // GET /api/repos/:name/packages
 func apiReposPackagesShow(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 
 	repo, err := collection.ByName(c.Params.ByName("name"))
@@ -208,7 +208,7 @@ func apiReposPackagesShow(c *gin.Context) {
 		return
 	}
 
-	showPackages(c, repo.RefList(), collectionFactory)
+	showPackages(c, repo.RefList(), repo, collectionFactory)
 }
 
 // Handler for both add and delete
@@ -221,7 +221,7 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 
 	repo, err := collection.ByName(c.Params.ByName("name"))
@@ -243,6 +243,7 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
+		repo.HoldPackages(list)
 
 		// verify package refs and build package list
 		for _, ref := range b.PackageRefs {
@@ -275,6 +276,10 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 // POST /repos/:name/packages
 func apiReposPackagesAdd(c *gin.Context) {
 	apiReposPackagesAddDelete(c, "Add packages to repo ", func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+		if held := list.HeldFor(p); held != nil {
+			out.Printf("Skipping package %s, as %s is held\n", p, held)
+			return nil
+		}
 		out.Printf("Adding package %s\n", p.Name)
 		return list.Add(p)
 	})
@@ -289,6 +294,78 @@ func apiReposPackagesDelete(c *gin.Context) {
 	})
 }
 
+func apiReposPackagesHoldUnhold(c *gin.Context, hold bool) {
+	var b struct {
+		PackageRefs []string
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.LocalRepoCollection()
+
+	repo, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	err = collection.LoadComplete(repo)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	taskName := "Hold packages in repo " + repo.Name
+	if !hold {
+		taskName = "Release held packages in repo " + repo.Name
+	}
+
+	resources := []string{string(repo.Key())}
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		for _, ref := range b.PackageRefs {
+			p, err := collectionFactory.PackageCollection().ByKey([]byte(ref))
+			if err != nil {
+				if err == database.ErrNotFound {
+					return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, fmt.Errorf("packages %s: %s", ref, err)
+				}
+
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+
+			if repo.RefList() == nil || !repo.RefList().Has(p) {
+				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("package %s is not in repo %s", p, repo.Name)
+			}
+
+			if hold {
+				out.Printf("Holding package %s\n", p)
+				repo.Hold(p)
+			} else {
+				out.Printf("Releasing package %s\n", p)
+				repo.Unhold(p)
+			}
+		}
+
+		err = collection.Update(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+		}
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: repo}, nil
+	})
+}
+
+// POST /repos/:name/packages/hold
+func apiReposPackagesHold(c *gin.Context) {
+	apiReposPackagesHoldUnhold(c, true)
+}
+
+// DELETE /repos/:name/packages/hold
+func apiReposPackagesUnhold(c *gin.Context) {
+	apiReposPackagesHoldUnhold(c, false)
+}
+
 // POST /repos/:name/file/:dir/:file
 func apiReposPackageFromFile(c *gin.Context) {
 	// redirect all work to dir method
@@ -297,8 +374,22 @@ func apiReposPackageFromFile(c *gin.Context) {
 
 // POST /repos/:name/file/:dir
 func apiReposPackageFromDir(c *gin.Context) {
-	forceReplace := c.Request.URL.Query().Get("forceReplace") == "1"
+	// onConflict is one of deb.ImportConflictResolutions, forceReplace=1 is the same as onConflict=replace
+	onConflict := c.Request.URL.Query().Get("onConflict")
+	if onConflict == "" {
+		onConflict = deb.ConflictFail
+	}
+	if c.Request.URL.Query().Get("forceReplace") == "1" {
+		onConflict = deb.ConflictReplace
+	}
 	noRemove := c.Request.URL.Query().Get("noRemove") == "1"
+	// with atomic=1, packages are added only if all the files could be imported
+	atomic := c.Request.URL.Query().Get("atomic") == "1"
+
+	if !utils.StrSliceHasItem(deb.ImportConflictResolutions, onConflict) {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown conflict resolution mode: %s", onConflict))
+		return
+	}
 
 	if !verifyDir(c) {
 		return
@@ -311,7 +402,7 @@ func apiReposPackageFromDir(c *gin.Context) {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 
 	name := c.Params.ByName("name")
@@ -351,7 +442,8 @@ func apiReposPackageFromDir(c *gin.Context) {
 				AddedLines:   []string{},
 				RemovedLines: []string{},
 			}
-			list *deb.PackageList
+			list      *deb.PackageList
+			conflicts []deb.PackageConflict
 		)
 
 		packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(sources, reporter)
@@ -360,8 +452,9 @@ func apiReposPackageFromDir(c *gin.Context) {
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
 		}
+		repo.HoldPackages(list)
 
-		processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
+		processedFiles, failedFiles2, conflicts, err = deb.ImportPackageFiles(list, packageFiles, onConflict, verifier, context.PackagePool(),
 			collectionFactory.PackageCollection(), reporter, nil, collectionFactory.ChecksumCollection)
 		failedFiles = append(failedFiles, failedFiles2...)
 		processedFiles = append(processedFiles, otherFiles...)
@@ -370,6 +463,15 @@ func apiReposPackageFromDir(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import package files: %s", err)
 		}
 
+		if atomic && len(failedFiles) > 0 {
+			out.Printf("Failed files: %s\n", strings.Join(failedFiles, ", "))
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: gin.H{
+				"Report":      reporter,
+				"FailedFiles": failedFiles,
+				"Conflicts":   conflicts,
+			}}, fmt.Errorf("unable to add packages: %d file(s) failed to import, repository is not changed", len(failedFiles))
+		}
+
 		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 		err = collectionFactory.LocalRepoCollection().Update(repo)
@@ -394,6 +496,9 @@ func apiReposPackageFromDir(c *gin.Context) {
 		if failedFiles == nil {
 			failedFiles = []string{}
 		}
+		if conflicts == nil {
+			conflicts = []deb.PackageConflict{}
+		}
 
 		if len(reporter.AddedLines) > 0 {
 			out.Printf("Added: %s\n", strings.Join(reporter.AddedLines, ", "))
@@ -407,26 +512,41 @@ func apiReposPackageFromDir(c *gin.Context) {
 		if len(failedFiles) > 0 {
 			out.Printf("Failed files: %s\n", strings.Join(failedFiles, ", "))
 		}
+		for _, conflict := range conflicts {
+			out.Printf("Conflict: %s\n", conflict)
+		}
 
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
 			"Report":      reporter,
 			"FailedFiles": failedFiles,
+			"Conflicts":   conflicts,
 		}}, nil
 	})
 }
 
 // POST /repos/:name/copy/:src/:file
 func apiReposCopyPackage(c *gin.Context) {
+	apiReposCopyMovePackage(c, false)
+}
+
+// POST /repos/:name/move/:src/:file
+func apiReposMovePackage(c *gin.Context) {
+	apiReposCopyMovePackage(c, true)
+}
+
+func apiReposCopyMovePackage(c *gin.Context, move bool) {
 	dstRepoName := c.Params.ByName("name")
 	srcRepoName := c.Params.ByName("src")
 	fileName := c.Params.ByName("file")
 
 	jsonBody := struct {
-		WithDeps bool `json:"with-deps,omitempty"`
-		DryRun   bool `json:"dry-run,omitempty"`
+		WithDeps   bool   `json:"with-deps,omitempty"`
+		DryRun     bool   `json:"dry-run,omitempty"`
+		OnConflict string `json:"on-conflict,omitempty"`
 	}{
-		WithDeps: false,
-		DryRun:   false,
+		WithDeps:   false,
+		DryRun:     false,
+		OnConflict: deb.ConflictFail,
 	}
 
 	err := c.Bind(&jsonBody)
@@ -434,7 +554,12 @@ func apiReposCopyPackage(c *gin.Context) {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	if !utils.StrSliceHasItem(deb.ConflictResolutions, jsonBody.OnConflict) {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown conflict resolution mode: %s", jsonBody.OnConflict))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
 	dstRepo, err := collectionFactory.LocalRepoCollection().ByName(dstRepoName)
 	if err != nil {
 		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("dest repo error: %s", err))
@@ -471,6 +596,9 @@ func apiReposCopyPackage(c *gin.Context) {
 
 	srcRefList = srcRepo.RefList()
 	taskName := fmt.Sprintf("Copy packages from repo %s to repo %s", srcRepoName, dstRepoName)
+	if move {
+		taskName = fmt.Sprintf("Move packages from repo %s to repo %s", srcRepoName, dstRepoName)
+	}
 	resources := []string{string(dstRepo.Key()), string(srcRepo.Key())}
 
 	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
@@ -485,6 +613,7 @@ func apiReposCopyPackage(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages in dest: %s", err)
 
 		}
+		dstRepo.HoldPackages(dstList)
 
 		srcList, err := deb.NewPackageListFromRefList(srcRefList, collectionFactory.PackageCollection(), context.Progress())
 		if err != nil {
@@ -514,10 +643,11 @@ func apiReposCopyPackage(c *gin.Context) {
 
 		// srcList.Filter|FilterWithProgress only accept query list
 		queries := make([]deb.PackageQuery, 1)
-		queries[0], err = query.Parse(fileName)
+		queries[0], err = query.ParseWithPackageSets(fileName, collectionFactory.PackageSetCollection())
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to parse query '%s': %s", fileName, err)
 		}
+		query.ResolveHeld(queries[0], srcRepo)
 
 		toProcess, err := srcList.FilterWithProgress(queries, jsonBody.WithDeps, dstList, context.DependencyOptions(), architecturesList, context.Progress())
 		if err != nil {
@@ -529,11 +659,28 @@ func apiReposCopyPackage(c *gin.Context) {
 		}
 
 		err = toProcess.ForEach(func(p *deb.Package) error {
-			err = dstList.Add(p)
+			if held := dstList.HeldFor(p); held != nil {
+				reporter.Warning("%s skipped, as %s is held in destination", p, held)
+				return nil
+			}
+
+			conflicting, err := dstList.AddWithConflictResolution(p, jsonBody.OnConflict)
 			if err != nil {
 				return err
 			}
 
+			if conflicting != nil {
+				if jsonBody.OnConflict == deb.ConflictSkip {
+					reporter.Warning("%s skipped due to conflict with package in destination", p)
+					return nil
+				}
+				reporter.Removed("%s removed due to conflict with package being added", conflicting)
+			}
+
+			if move {
+				srcList.Remove(p)
+			}
+
 			name := fmt.Sprintf("added %s-%s(%s)", p.Name, p.Version, p.Architecture)
 			reporter.AddedLines = append(reporter.AddedLines, name)
 			return nil
@@ -551,6 +698,15 @@ func apiReposCopyPackage(c *gin.Context) {
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 			}
+
+			if move {
+				srcRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(srcList))
+
+				err = collectionFactory.LocalRepoCollection().Update(srcRepo)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+				}
+			}
 		}
 
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
@@ -573,7 +729,7 @@ func apiReposIncludePackageFromDir(c *gin.Context) {
 	ignoreSignature := c.Request.URL.Query().Get("ignoreSignature") == "1"
 
 	repoTemplateString := c.Params.ByName("name")
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 
 	if !verifyDir(c) {
 		return
@@ -634,7 +790,10 @@ func apiReposIncludePackageFromDir(c *gin.Context) {
 		_, failedFiles2, err = deb.ImportChangesFiles(
 			changesFiles, reporter, acceptUnsigned, ignoreSignature, forceReplace, noRemoveFiles, verifier,
 			repoTemplate, context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
-			context.PackagePool(), collectionFactory.ChecksumCollection, nil, query.Parse)
+			context.PackagePool(), collectionFactory.ChecksumCollection, nil,
+			func(q string) (deb.PackageQuery, error) {
+				return query.ParseWithPackageSets(q, collectionFactory.PackageSetCollection())
+			})
 		failedFiles = append(failedFiles, failedFiles2...)
 
 		if err != nil {
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"os"
 	"sync/atomic"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	ctx "github.com/aptly-dev/aptly/context"
@@ -18,6 +19,10 @@ var context *ctx.AptlyContext
 func apiMetricsGet() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		countPackagesByRepos()
+		measureMirrorHealth()
+		countPackagesByMirrorsAndLocalRepos()
+		countTasks()
+		measurePackagePool()
 		promhttp.Handler().ServeHTTP(c.Writer, c.Request)
 	}
 }
@@ -52,6 +57,14 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		MetricsCollectorRegistrar.Register(router)
 	}
 
+	if c.Config().UploadExpiration > 0 {
+		go cleanupUploadsPeriodically(c.UploadPath(), time.Duration(c.Config().UploadExpiration)*time.Second)
+	}
+
+	if len(c.Config().MirrorSchedules) > 0 {
+		startScheduler(c.Config().MirrorSchedules)
+	}
+
 	if c.Config().ServeInAPIMode {
 		router.GET("/repos/", reposListInAPIMode(c.Config().FileSystemPublishRoots))
 		router.GET("/repos/:storage/*pkgPath", reposServeInAPIMode)
@@ -90,6 +103,14 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		})
 	}
 
+	if len(c.Config().APITokens) > 0 {
+		api.Use(Authorizer(c.Config().APITokens))
+	}
+
+	if c.Config().EnableAuditLog {
+		api.Use(AuditLogger())
+	}
+
 	{
 		if c.Config().EnableMetricsEndpoint {
 			api.GET("/metrics", apiMetricsGet())
@@ -101,6 +122,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		defer isReady.Store(true)
 		api.GET("/ready", apiReady(isReady))
 		api.GET("/healthy", apiHealthy)
+		api.GET("/history", apiHistory)
 	}
 
 	{
@@ -113,15 +135,19 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/repos/:name/packages", apiReposPackagesShow)
 		api.POST("/repos/:name/packages", apiReposPackagesAdd)
 		api.DELETE("/repos/:name/packages", apiReposPackagesDelete)
+		api.POST("/repos/:name/packages/hold", apiReposPackagesHold)
+		api.DELETE("/repos/:name/packages/hold", apiReposPackagesUnhold)
 
 		api.POST("/repos/:name/file/:dir/:file", apiReposPackageFromFile)
 		api.POST("/repos/:name/file/:dir", apiReposPackageFromDir)
 		api.POST("/repos/:name/copy/:src/:file", apiReposCopyPackage)
+		api.POST("/repos/:name/move/:src/:file", apiReposMovePackage)
 
 		api.POST("/repos/:name/include/:dir/:file", apiReposIncludePackageFromFile)
 		api.POST("/repos/:name/include/:dir", apiReposIncludePackageFromDir)
 
 		api.POST("/repos/:name/snapshots", apiSnapshotsCreateFromRepository)
+		api.POST("/repos/:name/promote", apiReposPromote)
 	}
 
 	{
@@ -132,6 +158,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/mirrors", apiMirrorsList)
 		api.GET("/mirrors/:name", apiMirrorsShow)
 		api.GET("/mirrors/:name/packages", apiMirrorsPackages)
+		api.GET("/mirrors/:name/status", apiMirrorsStatus)
 		api.POST("/mirrors", apiMirrorsCreate)
 		api.PUT("/mirrors/:name", apiMirrorsUpdate)
 		api.DELETE("/mirrors/:name", apiMirrorsDrop)
@@ -150,6 +177,8 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.POST("/files/:dir", apiFilesUpload)
 		api.GET("/files/:dir", apiFilesListFiles)
 		api.DELETE("/files/:dir", apiFilesDeleteDir)
+		api.GET("/files/:dir/:name", apiFilesUploadStatus)
+		api.PUT("/files/:dir/:name", apiFilesUploadChunk)
 		api.DELETE("/files/:dir/:name", apiFilesDeleteFile)
 	}
 
@@ -157,8 +186,12 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/publish", apiPublishList)
 		api.POST("/publish", apiPublishRepoOrSnapshot)
 		api.POST("/publish/:prefix", apiPublishRepoOrSnapshot)
+		api.POST("/publish/:prefix/cleanup", apiPublishCleanup)
 		api.PUT("/publish/:prefix/:distribution", apiPublishUpdateSwitch)
 		api.DELETE("/publish/:prefix/:distribution", apiPublishDrop)
+		api.POST("/publish/:prefix/:distribution/refresh", apiPublishRefresh)
+		api.POST("/publish/:prefix/:distribution/resume", apiPublishResume)
+		api.GET("/publish/:prefix/:distribution/sources", apiPublishSources)
 	}
 
 	{
@@ -169,12 +202,26 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/snapshots/:name/packages", apiSnapshotsSearchPackages)
 		api.DELETE("/snapshots/:name", apiSnapshotsDrop)
 		api.GET("/snapshots/:name/diff/:withSnapshot", apiSnapshotsDiff)
+		api.GET("/snapshots/:name/verify", apiSnapshotsVerify)
+		api.GET("/snapshots/:name/manifest", apiSnapshotsManifest)
+		api.POST("/snapshots/:name/sign", apiSnapshotsSign)
 		api.POST("/snapshots/merge", apiSnapshotsMerge)
+		api.POST("/snapshots/query", apiSnapshotsCreateFromQuery)
+	}
+
+	{
+		api.GET("/package-sets", apiPackageSetsList)
+		api.POST("/package-sets", apiPackageSetsCreate)
+		api.GET("/package-sets/:name", apiPackageSetsShow)
+		api.PUT("/package-sets/:name", apiPackageSetsEdit)
+		api.DELETE("/package-sets/:name", apiPackageSetsDrop)
 	}
 
 	{
 		api.GET("/packages/:key", apiPackagesShow)
+		api.GET("/packages/:key/changelog", apiPackagesChangelog)
 		api.GET("/packages", apiPackages)
+		api.POST("/packages/stanzas", apiPackagesStanzas)
 	}
 
 	{
@@ -183,6 +230,10 @@ func Router(c *ctx.AptlyContext) http.Handler {
 	{
 		api.POST("/db/cleanup", apiDbCleanup)
 	}
+	{
+		api.GET("/schedules", apiSchedulesList)
+		api.POST("/schedules/:name/run", apiSchedulesRun)
+	}
 	{
 		api.GET("/tasks", apiTasksList)
 		api.POST("/tasks-clear", apiTasksClear)
@@ -193,6 +244,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/tasks/:id/return_value", apiTasksReturnValueShow)
 		api.GET("/tasks/:id", apiTasksShow)
 		api.DELETE("/tasks/:id", apiTasksDelete)
+		api.POST("/tasks/:id/cancel", apiTasksCancel)
 		api.POST("/tasks-dummy", apiTasksDummy)
 	}
 
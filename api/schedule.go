@@ -0,0 +1,304 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/schedule"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// number of runs of every scheduled job kept in history
+const scheduleHistorySize = 20
+
+// format of {timestamp} in names of scheduled snapshots
+const scheduleTimestampFormat = "20060102150405"
+
+var scheduler *schedule.Scheduler
+
+// ValidateMirrorSchedules checks configuration of scheduled mirror updates
+func ValidateMirrorSchedules(schedules []utils.MirrorScheduleConfig) error {
+	seen := map[string]bool{}
+
+	for _, config := range schedules {
+		if config.Mirror == "" {
+			return fmt.Errorf("mirror schedule should have a mirror name")
+		}
+
+		if seen[config.Mirror] {
+			return fmt.Errorf("mirror %s is scheduled more than once", config.Mirror)
+		}
+		seen[config.Mirror] = true
+
+		if _, err := schedule.Parse(config.Schedule); err != nil {
+			return fmt.Errorf("mirror %s: %s", config.Mirror, err)
+		}
+
+		// every run takes new snapshot, so name of the snapshot should be unique
+		if config.Snapshot != "" && !strings.Contains(config.Snapshot, "{timestamp}") {
+			return fmt.Errorf("mirror %s: snapshot name %s should contain {timestamp}", config.Mirror, config.Snapshot)
+		}
+
+		if (config.PublishPrefix != "" || config.PublishComponent != "") && config.PublishDistribution == "" {
+			return fmt.Errorf("mirror %s: publishDistribution is required to publish scheduled snapshot", config.Mirror)
+		}
+
+		if config.PublishDistribution != "" && config.Snapshot == "" {
+			return fmt.Errorf("mirror %s: snapshot is required to publish after scheduled update", config.Mirror)
+		}
+	}
+
+	return nil
+}
+
+// startScheduler schedules mirror updates configured in mirrorSchedules
+func startScheduler(schedules []utils.MirrorScheduleConfig) {
+	scheduler = schedule.NewScheduler(scheduleHistorySize)
+
+	for _, config := range schedules {
+		err := scheduler.Add(config.Mirror, config.Schedule, mirrorScheduleJob(config))
+		if err != nil {
+			log.Error().Msgf("unable to schedule update of mirror %s: %s", config.Mirror, err)
+		}
+	}
+
+	scheduler.Start()
+}
+
+// scheduledSnapshotName expands {mirror} and {timestamp} in name of the snapshot
+func scheduledSnapshotName(template string, mirror string, now time.Time) string {
+	return strings.NewReplacer("{mirror}", mirror, "{timestamp}", now.Format(scheduleTimestampFormat)).Replace(template)
+}
+
+// mirrorScheduleJob returns job which updates the mirror, optionally taking snapshot of it
+// and switching published repository to the new snapshot
+//
+// Everything is done in a single task, so that the job shows up in the task list
+// and doesn't run concurrently with other changes to the same mirror or published repository.
+func mirrorScheduleJob(config utils.MirrorScheduleConfig) schedule.JobFunc {
+	return func() error {
+		err := acquireDatabaseConnection()
+		if err != nil {
+			return err
+		}
+
+		collectionFactory := context.NewCollectionFactory()
+
+		remote, err := collectionFactory.RemoteRepoCollection().ByName(config.Mirror)
+		if err != nil {
+			releaseDatabaseConnection()
+			return fmt.Errorf("unable to update: %s", err)
+		}
+
+		resources := []string{string(remote.Key())}
+
+		var snapshotName string
+		if config.Snapshot != "" {
+			snapshotName = scheduledSnapshotName(config.Snapshot, remote.Name, time.Now())
+			resources = append(resources, "S"+snapshotName)
+		}
+
+		var published *deb.PublishedRepo
+		if config.PublishDistribution != "" {
+			storage, prefix := deb.ParsePrefix(config.PublishPrefix)
+			if prefix == "" {
+				prefix = "."
+			}
+
+			published, err = collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, config.PublishDistribution)
+			if err != nil {
+				releaseDatabaseConnection()
+				return fmt.Errorf("unable to publish: %s", err)
+			}
+			resources = append(resources, string(published.Key()))
+		}
+
+		releaseDatabaseConnection()
+
+		audit := &auditState{
+			entry:    deb.NewAuditEntry(deb.AuditSourceScheduler, deb.AuditSourceScheduler, "scheduled update of mirror "+remote.Name, []string{config.Schedule}),
+			recorder: deb.NewAuditRecorder(),
+		}
+		collectionFactory.SetAuditRecorder(audit.recorder)
+
+		verifier, err := getVerifier(nil, remote)
+		if err != nil {
+			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+		}
+
+		update := observeTask(mirrorUpdateDurationSummary, []string{remote.Name},
+			mirrorUpdateProcess(remote, collectionFactory, verifier, mirrorUpdateOptions{
+				IgnoreSignatures: context.Config().GpgDisableVerify,
+			}))
+
+		t, conflictErr := runTaskInBackground("Scheduled update of mirror "+remote.Name, resources,
+			auditTask(audit, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+				digest := remote.IndexesDigest
+
+				retValue, err := update(out, detail)
+				if err != nil || snapshotName == "" {
+					return retValue, err
+				}
+
+				if remote.IndexesDigest == digest {
+					out.Printf("Mirror %s hasn't changed, snapshot is not created\n", remote.Name)
+					return retValue, nil
+				}
+
+				snapshot, err := scheduledSnapshot(remote, snapshotName, collectionFactory)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+				}
+				out.Printf("Snapshot %s created\n", snapshot.Name)
+
+				if published == nil {
+					return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
+				}
+
+				err = scheduledPublish(published, config.PublishComponent, snapshot, collectionFactory, out)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+				}
+				out.Printf("Published repository %s switched to snapshot %s\n", published.String(), snapshot.Name)
+
+				return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
+			}))
+		if conflictErr != nil {
+			finishAuditEntry(audit, http.StatusConflict, conflictErr)
+			return conflictErr
+		}
+
+		context.TaskList().WaitForTaskByID(t.ID)
+		err, _ = context.TaskList().GetTaskErrorByID(t.ID)
+
+		return err
+	}
+}
+
+// scheduledSnapshot creates snapshot of the mirror after scheduled update
+func scheduledSnapshot(remote *deb.RemoteRepo, name string, collectionFactory *deb.CollectionFactory) (*deb.Snapshot, error) {
+	err := collectionFactory.RemoteRepoCollection().LoadComplete(remote)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
+	snapshot, err := deb.NewSnapshotFromRepository(name, remote)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create snapshot: %s", err)
+	}
+	snapshot.Description = fmt.Sprintf("Scheduled snapshot from mirror %s", remote)
+
+	err = collectionFactory.SnapshotCollection().Add(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
+	context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
+	return snapshot, nil
+}
+
+// scheduledPublish switches published repository to the snapshot taken after scheduled update
+func scheduledPublish(published *deb.PublishedRepo, component string, snapshot *deb.Snapshot,
+	collectionFactory *deb.CollectionFactory, out aptly.Progress) error {
+	collection := collectionFactory.PublishedRepoCollection()
+
+	err := collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	if published.SourceKind != deb.SourceSnapshot {
+		return fmt.Errorf("unable to publish: published repository %s is not published from snapshot", published.String())
+	}
+
+	components := published.SourceComponents()
+	if component == "" {
+		if len(components) != 1 {
+			return fmt.Errorf("unable to publish: published repository %s has several components, publishComponent should be set", published.String())
+		}
+		component = components[0]
+	} else if !utils.StrSliceHasItem(components, component) {
+		return fmt.Errorf("unable to publish: component %s is not in published repository %s", component, published.String())
+	}
+
+	signer, err := getSigner(&SigningOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	published.UpdateSnapshot(component, snapshot)
+
+	started := time.Now()
+	_, err = collection.PublishAndSave(published, false, context.PackagePool(), context, collectionFactory, signer, out, false, false)
+
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	publishDurationSummary.WithLabelValues(published.StoragePrefix(), published.Distribution, status).Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, err)
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+
+	err = collection.CleanupPublishedComponentFiles(published, []string{component}, context, collectionFactory, out)
+	if err != nil {
+		return fmt.Errorf("unable to clean up: %s", err)
+	}
+
+	return nil
+}
+
+// GET /api/schedules
+func apiSchedulesList(c *gin.Context) {
+	if scheduler == nil {
+		c.JSON(http.StatusOK, []schedule.JobStatus{})
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduler.Jobs())
+}
+
+// POST /api/schedules/:name/run
+func apiSchedulesRun(c *gin.Context) {
+	name := c.Params.ByName("name")
+
+	// job switches published repository, so token should be allowed to publish there
+	for _, config := range context.Config().MirrorSchedules {
+		if config.Mirror == name && config.PublishDistribution != "" && !checkPublishPrefix(c, config.PublishPrefix) {
+			return
+		}
+	}
+
+	if scheduler == nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("job %s is not scheduled", name))
+		return
+	}
+
+	for _, job := range scheduler.Jobs() {
+		if job.Name == name {
+			err := scheduler.Trigger(name)
+			if err != nil {
+				AbortWithJSONError(c, http.StatusConflict, err)
+				return
+			}
+
+			c.JSON(http.StatusAccepted, gin.H{"Name": name})
+			return
+		}
+	}
+
+	AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("job %s is not scheduled", name))
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type ScheduleSuite struct {
+	ApiSuite
+}
+
+var _ = Suite(&ScheduleSuite{})
+
+func (s *ScheduleSuite) TestListSchedules(c *C) {
+	response, _ := s.HTTPRequest("GET", "/api/schedules", nil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, "[]")
+
+	response, _ = s.HTTPRequest("POST", "/api/schedules/debian/run", nil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Equals, "{\"error\":\"job debian is not scheduled\"}")
+}
+
+func (s *ScheduleSuite) TestValidateMirrorSchedules(c *C) {
+	c.Check(ValidateMirrorSchedules(nil), IsNil)
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{
+		{Mirror: "debian", Schedule: "0 3 * * *", Snapshot: "{mirror}-{timestamp}", PublishDistribution: "bookworm"},
+		{Mirror: "ubuntu", Schedule: "@hourly"},
+	}), IsNil)
+
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Schedule: "@daily"}}),
+		ErrorMatches, "mirror schedule should have a mirror name")
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Mirror: "debian", Schedule: "@daily"}, {Mirror: "debian", Schedule: "@hourly"}}),
+		ErrorMatches, "mirror debian is scheduled more than once")
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Mirror: "debian", Schedule: "0 25 * * *"}}),
+		ErrorMatches, "mirror debian: invalid schedule .*: hour 25 out of range 0-23")
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Mirror: "debian", Schedule: "@daily", PublishDistribution: "bookworm"}}),
+		ErrorMatches, "mirror debian: snapshot is required to publish after scheduled update")
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Mirror: "debian", Schedule: "@daily", Snapshot: "x-{timestamp}", PublishPrefix: "ppa"}}),
+		ErrorMatches, "mirror debian: publishDistribution is required to publish scheduled snapshot")
+	c.Check(ValidateMirrorSchedules([]utils.MirrorScheduleConfig{{Mirror: "debian", Schedule: "@daily", Snapshot: "nightly"}}),
+		ErrorMatches, "mirror debian: snapshot name nightly should contain \\{timestamp\\}")
+}
+
+func (s *ScheduleSuite) TestScheduledSnapshotName(c *C) {
+	now := time.Date(2024, 1, 10, 3, 0, 5, 0, time.UTC)
+
+	c.Check(scheduledSnapshotName("{mirror}-{timestamp}", "debian", now), Equals, "debian-20240110030005")
+	c.Check(scheduledSnapshotName("nightly", "debian", now), Equals, "nightly")
+}
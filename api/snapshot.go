@@ -3,12 +3,16 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,7 +20,7 @@ import (
 func apiSnapshotsList(c *gin.Context) {
 	SortMethodString := c.Request.URL.Query().Get("sort")
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.SnapshotCollection()
 
 	if SortMethodString == "" {
@@ -43,13 +47,14 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 	var b struct {
 		Name        string `binding:"required"`
 		Description string
+		Provenance  string
 	}
 
 	if c.Bind(&b) != nil {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.RemoteRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
 	name := c.Params.ByName("name")
@@ -82,11 +87,15 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Provenance = b.Provenance
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -101,6 +110,7 @@ func apiSnapshotsCreate(c *gin.Context) {
 	var b struct {
 		Name            string `binding:"required"`
 		Description     string
+		Provenance      string
 		SourceSnapshots []string
 		PackageRefs     []string
 	}
@@ -115,7 +125,7 @@ func apiSnapshotsCreate(c *gin.Context) {
 		}
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	snapshotCollection := collectionFactory.SnapshotCollection()
 	var resources []string
 
@@ -156,11 +166,15 @@ func apiSnapshotsCreate(c *gin.Context) {
 		}
 
 		snapshot = deb.NewSnapshotFromRefList(b.Name, sources, deb.NewPackageRefListFromPackageList(list), b.Description)
+		snapshot.Provenance = b.Provenance
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -176,13 +190,14 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 	var b struct {
 		Name        string `binding:"required"`
 		Description string
+		Provenance  string
 	}
 
 	if c.Bind(&b) != nil {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.LocalRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
 	name := c.Params.ByName("name")
@@ -210,11 +225,15 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Provenance = b.Provenance
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -229,13 +248,14 @@ func apiSnapshotsUpdate(c *gin.Context) {
 	var b struct {
 		Name        string
 		Description string
+		Provenance  *string
 	}
 
 	if c.Bind(&b) != nil {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.SnapshotCollection()
 	name := c.Params.ByName("name")
 
@@ -261,6 +281,10 @@ func apiSnapshotsUpdate(c *gin.Context) {
 			snapshot.Description = b.Description
 		}
 
+		if b.Provenance != nil {
+			snapshot.Provenance = *b.Provenance
+		}
+
 		err = collectionFactory.SnapshotCollection().Update(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
@@ -271,7 +295,7 @@ func apiSnapshotsUpdate(c *gin.Context) {
 
 // GET /api/snapshots/:name
 func apiSnapshotsShow(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.SnapshotCollection()
 
 	snapshot, err := collection.ByName(c.Params.ByName("name"))
@@ -289,14 +313,89 @@ func apiSnapshotsShow(c *gin.Context) {
 	c.JSON(200, snapshot)
 }
 
+// POST /api/snapshots/:name/sign
+func apiSnapshotsSign(c *gin.Context) {
+	var b SigningOptions
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Skip {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to sign: signing can't be skipped"))
+		return
+	}
+
+	signer, err := getSigner(&b)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.SnapshotCollection()
+	name := c.Params.ByName("name")
+
+	snapshot, err := collection.ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	resources := []string{string(snapshot.ResourceKey())}
+	taskName := fmt.Sprintf("Sign snapshot %s", name)
+	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := collection.LoadComplete(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		err = snapshot.SignManifest(signer, collectionFactory.PackageCollection())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to sign: %s", err)
+		}
+
+		err = collection.Update(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: snapshot}, nil
+	})
+}
+
+// GET /api/snapshots/:name/manifest
+func apiSnapshotsManifest(c *gin.Context) {
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	manifest, err := snapshot.Manifest(collectionFactory.PackageCollection())
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.Data(200, "text/plain; charset=utf-8", manifest)
+}
+
 // DELETE /api/snapshots/:name
 func apiSnapshotsDrop(c *gin.Context) {
 	name := c.Params.ByName("name")
 	force := c.Request.URL.Query().Get("force") == "1"
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	snapshotCollection := collectionFactory.SnapshotCollection()
-	publishedCollection := collectionFactory.PublishedRepoCollection()
 
 	snapshot, err := snapshotCollection.ByName(name)
 	if err != nil {
@@ -307,17 +406,15 @@ func apiSnapshotsDrop(c *gin.Context) {
 	resources := []string{string(snapshot.ResourceKey())}
 	taskName := fmt.Sprintf("Delete snapshot %s", name)
 	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
-		published := publishedCollection.BySnapshot(snapshot)
-
-		if len(published) > 0 {
-			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to drop: snapshot is published")
-		}
+		refs := collectionFactory.SnapshotReferences(snapshot)
 
-		if !force {
-			snapshots := snapshotCollection.BySnapshotSource(snapshot)
-			if len(snapshots) > 0 {
-				return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("won't delete snapshot that was used as source for other snapshots, use ?force=1 to override")
+		if err := refs.CheckDrop(force); err != nil {
+			if len(refs.Published) > 0 {
+				err = fmt.Errorf("unable to drop: %s", err)
+			} else {
+				err = fmt.Errorf("%s, use ?force=1 to override", err)
 			}
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, err
 		}
 
 		err = snapshotCollection.Drop(snapshot)
@@ -332,7 +429,7 @@ func apiSnapshotsDrop(c *gin.Context) {
 func apiSnapshotsDiff(c *gin.Context) {
 	onlyMatching := c.Request.URL.Query().Get("onlyMatching") == "1"
 
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.SnapshotCollection()
 
 	snapshotA, err := collection.ByName(c.Params.ByName("name"))
@@ -379,9 +476,98 @@ func apiSnapshotsDiff(c *gin.Context) {
 	c.JSON(200, result)
 }
 
+// GET /api/snapshots/:name/verify
+func apiSnapshotsVerify(c *gin.Context) {
+	collectionFactory := newCollectionFactory(c)
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	snapshots := []*deb.Snapshot{snapshot}
+	for _, name := range c.QueryArray("source") {
+		var source *deb.Snapshot
+		source, err = collection.ByName(name)
+		if err != nil {
+			AbortWithJSONError(c, 404, err)
+			return
+		}
+		snapshots = append(snapshots, source)
+	}
+
+	for _, s := range snapshots {
+		err = collection.LoadComplete(s)
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+	}
+
+	packageList, err := deb.NewPackageListFromRefListWithFields(snapshot.RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, nil)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	sourcePackageList := deb.NewPackageList()
+	err = sourcePackageList.Append(packageList)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	for _, source := range snapshots[1:] {
+		var pL *deb.PackageList
+		pL, err = deb.NewPackageListFromRefListWithFields(source.RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, nil)
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+
+		err = sourcePackageList.Append(pL)
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+	}
+
+	sourcePackageList.PrepareIndex()
+
+	var architecturesList []string
+	if c.Query("architectures") != "" {
+		architecturesList = strings.Split(c.Query("architectures"), ",")
+	} else {
+		architecturesList = packageList.Architectures(true)
+	}
+
+	if len(architecturesList) == 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to determine list of architectures, please specify explicitly"))
+		return
+	}
+
+	missing, err := packageList.VerifyDependenciesRequiredBy(context.DependencyOptions(), architecturesList, sourcePackageList, nil)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	if missing == nil {
+		missing = []deb.MissingDependency{}
+	}
+
+	c.JSON(200, gin.H{
+		"Architectures": architecturesList,
+		"Complete":      len(missing) == 0,
+		"Missing":       missing,
+	})
+}
+
 // GET /api/snapshots/:name/packages
 func apiSnapshotsSearchPackages(c *gin.Context) {
-	collectionFactory := context.NewCollectionFactory()
+	collectionFactory := newCollectionFactory(c)
 	collection := collectionFactory.SnapshotCollection()
 
 	snapshot, err := collection.ByName(c.Params.ByName("name"))
@@ -396,7 +582,7 @@ func apiSnapshotsSearchPackages(c *gin.Context) {
 		return
 	}
 
-	showPackages(c, snapshot.RefList(), collectionFactory)
+	showPackages(c, snapshot.RefList(), nil, collectionFactory)
 }
 
 // POST /api/snapshots/merge
@@ -429,7 +615,25 @@ func apiSnapshotsMerge(c *gin.Context) {
 		return
 	}
 
-	collectionFactory := context.NewCollectionFactory()
+	extraSourceOnly := c.Request.URL.Query().Get("extra-source-only")
+	if extraSourceOnly == "" {
+		extraSourceOnly = deb.ExtraSourceOnlyRetain
+	}
+	if extraSourceOnly != deb.ExtraSourceOnlyRetain && extraSourceOnly != deb.ExtraSourceOnlyDrop {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown mode for Extra-Source-Only packages: %s", extraSourceOnly))
+		return
+	}
+
+	onConflict := c.Request.URL.Query().Get("on-conflict")
+	if onConflict == "" {
+		onConflict = deb.ConflictReplace
+	}
+	if !utils.StrSliceHasItem(deb.ImportConflictResolutions, onConflict) {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown conflict resolution mode: %s", onConflict))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
 	snapshotCollection := collectionFactory.SnapshotCollection()
 
 	sources := make([]*deb.Snapshot, len(body.Sources))
@@ -449,10 +653,32 @@ func apiSnapshotsMerge(c *gin.Context) {
 		resources[i] = string(sources[i].ResourceKey())
 	}
 
-	maybeRunTaskInBackground(c, "Merge snapshot "+body.Destination, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
-		result := sources[0].RefList()
-		for i := 1; i < len(sources); i++ {
-			result = result.Merge(sources[i].RefList(), overrideMatching, false)
+	maybeRunTaskInBackground(c, "Merge snapshot "+body.Destination, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		refLists := make([]*deb.PackageRefList, len(sources))
+		for i := range sources {
+			refLists[i] = sources[i].RefList()
+			if extraSourceOnly == deb.ExtraSourceOnlyDrop {
+				refLists[i], err = refLists[i].WithoutExtraSourceOnly(collectionFactory.PackageCollection())
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to merge: %s", err)
+				}
+			}
+		}
+
+		result := refLists[0]
+		for i := 1; i < len(refLists); i++ {
+			right, conflicts, err := deb.ResolveMergeConflicts(result, refLists[i], onConflict, collectionFactory.PackageCollection())
+			if err != nil {
+				if _, ok := err.(*deb.PackageConflictsError); ok {
+					return &task.ProcessReturnValue{Code: http.StatusConflict, Value: gin.H{"Conflicts": conflicts}}, fmt.Errorf("unable to merge: %s", err)
+				}
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to merge: %s", err)
+			}
+			for _, conflict := range conflicts {
+				out.Printf("Conflict: %s\n", conflict)
+			}
+
+			result = result.Merge(right, overrideMatching, false)
 		}
 
 		if latest {
@@ -472,6 +698,102 @@ func apiSnapshotsMerge(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
 		}
 
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
+		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
+	})
+}
+
+// POST /api/snapshots/query
+//
+// Snapshot is built out of packages matching query across several sources: mirrors,
+// local repos and snapshots (specified as mirror:<name>, repo:<name> or snapshot:<name>)
+func apiSnapshotsCreateFromQuery(c *gin.Context) {
+	var b struct {
+		Name        string   `binding:"required"`
+		Query       string   `binding:"required"`
+		Sources     []string `binding:"required"`
+		WithDeps    bool
+		Description string
+		Provenance  string
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if len(b.Sources) < 1 {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("At least one source is required"))
+		return
+	}
+
+	collectionFactory := newCollectionFactory(c)
+
+	q, err := query.ParseWithPackageSets(b.Query, collectionFactory.PackageSetCollection())
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to parse query: %s", err))
+		return
+	}
+
+	sources := make([]*deb.SnapshotSource, len(b.Sources))
+	resources := make([]string, len(sources))
+	for i := range b.Sources {
+		sources[i], err = deb.FindSnapshotSource(collectionFactory, b.Sources[i])
+		if err != nil {
+			AbortWithJSONError(c, http.StatusNotFound, err)
+			return
+		}
+		resources[i] = string(sources[i].ResourceKey())
+	}
+
+	maybeRunTaskInBackground(c, "Create snapshot "+b.Name+" from query", resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		for _, source := range sources {
+			err := source.Load(collectionFactory)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to load source %s: %s", source, err)
+			}
+		}
+
+		list, err := deb.NewPackageListFromRefListWithFields(deb.MergeSnapshotSources(sources), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		var architecturesList []string
+		if b.WithDeps {
+			if len(context.ArchitecturesList()) > 0 {
+				architecturesList = context.ArchitecturesList()
+			} else {
+				architecturesList = list.Architectures(false)
+			}
+
+			sort.Strings(architecturesList)
+
+			if len(architecturesList) == 0 {
+				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to determine list of architectures, please specify explicitly")
+			}
+		}
+
+		list.PrepareIndex()
+
+		result, err := list.Filter([]deb.PackageQuery{q}, b.WithDeps, nil, context.DependencyOptions(), architecturesList)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		snapshot := deb.NewSnapshotFromQuery(b.Name, b.Query, sources, result)
+		if b.Description != "" {
+			snapshot.Description = b.Description
+		}
+		snapshot.Provenance = b.Provenance
+
+		err = collectionFactory.SnapshotCollection().Add(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
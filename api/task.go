@@ -141,6 +141,25 @@ func apiTasksDelete(c *gin.Context) {
 	c.JSON(200, delTask)
 }
 
+// POST /tasks/:id/cancel
+func apiTasksCancel(c *gin.Context) {
+	list := context.TaskList()
+	id, err := strconv.ParseInt(c.Params.ByName("id"), 10, 0)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	var canceledTask task.Task
+	canceledTask, err = list.CancelTaskByID(int(id))
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	c.JSON(200, canceledTask)
+}
+
 // POST /tasks-dummy
 func apiTasksDummy(c *gin.Context) {
 	resources := []string{"dummy"}
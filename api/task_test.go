@@ -73,3 +73,19 @@ func (s *TaskSuite) TestTasksClear(c *C) {
 	c.Check(response.Code, Equals, 200)
 	c.Check(response.Body.String(), Equals, "[]")
 }
+
+func (s *TaskSuite) TestTasksCancel(c *C) {
+	response, _ := s.HTTPRequest("POST", "/api/tasks-dummy?_async=true", nil)
+	c.Check(response.Code, Equals, 202)
+	var t task.Task
+	err := json.Unmarshal(response.Body.Bytes(), &t)
+	c.Assert(err, IsNil)
+	response, _ = s.HTTPRequest("GET", fmt.Sprintf("/api/tasks/%d/wait", t.ID), nil)
+	c.Check(response.Code, Equals, 200)
+	response, _ = s.HTTPRequest("POST", fmt.Sprintf("/api/tasks/%d/cancel", t.ID), nil)
+	c.Check(response.Code, Equals, 400)
+	response, _ = s.HTTPRequest("POST", "/api/tasks/9999/cancel", nil)
+	c.Check(response.Code, Equals, 400)
+	response, _ = s.HTTPRequest("POST", "/api/tasks-clear", nil)
+	c.Check(response.Code, Equals, 200)
+}
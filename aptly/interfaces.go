@@ -93,6 +93,14 @@ type FileSystemPublishedStorage interface {
 	PublicPath() string
 }
 
+// ChecksumPublishedStorage is published storage which reports checksums of published files
+// (usually remote object storage), so that unchanged files are not uploaded again on re-publish
+type ChecksumPublishedStorage interface {
+	// FileMD5s returns MD5 checksums of files under prefix by path relative to prefix,
+	// files with unknown checksum are omitted
+	FileMD5s(prefix string) (map[string]string, error)
+}
+
 // PublishedStorageProvider is a thing that returns PublishedStorage by name
 type PublishedStorageProvider interface {
 	// GetPublishedStorage returns PublishedStorage by name
@@ -167,6 +175,44 @@ type Downloader interface {
 	GetLength(ctx context.Context, url string) (int64, error)
 }
 
+// DownloaderOptions configures access to remote repository: proxy, credentials
+// and client TLS certificates
+type DownloaderOptions struct {
+	// Proxy is URL of HTTP(S) proxy, if empty proxy is taken from environment
+	Proxy string
+	// Username and Password are used for HTTP basic or digest authentication
+	Username string
+	Password string
+	// TLSClientCert and TLSClientKey are paths to PEM-encoded client certificate and key
+	TLSClientCert string
+	TLSClientKey  string
+	// TLSCACert is path to PEM-encoded CA certificates used to verify remote server
+	TLSCACert string
+}
+
+// Empty returns true if options don't change default downloader behavior
+func (o *DownloaderOptions) Empty() bool {
+	return *o == DownloaderOptions{}
+}
+
+// HTTPValidators are cache validators returned by HTTP server for the resource
+type HTTPValidators struct {
+	ETag         string `codec:",omitempty" json:",omitempty"`
+	LastModified string `codec:",omitempty" json:",omitempty"`
+}
+
+// Empty returns true if no validators are available
+func (v *HTTPValidators) Empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// ConditionalDownloader is Downloader which supports conditional requests
+type ConditionalDownloader interface {
+	// DownloadIfModified downloads url to destination only if remote resource doesn't match validators,
+	// validators are updated with values returned by the server; returns false if resource hasn't been modified
+	DownloadIfModified(ctx context.Context, url string, destination string, validators *HTTPValidators) (bool, error)
+}
+
 // ChecksumStorageProvider creates ChecksumStorage based on DB
 type ChecksumStorageProvider func(db database.ReaderWriter) ChecksumStorage
 
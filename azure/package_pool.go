@@ -38,6 +38,12 @@ func (pool *PackagePool) String() string {
 func (pool *PackagePool) buildPoolPath(filename string, checksums *utils.ChecksumInfo) string {
 	hash := checksums.SHA256
 	// Use the same path as the file pool, for compat reasons.
+	return filepath.Join(hash[0:2], hash[2:4], hash[4:])
+}
+
+// namedPoolPath returns path used before content-addressed pool layout (based on SHA256 and filename)
+func (pool *PackagePool) namedPoolPath(filename string, checksums *utils.ChecksumInfo) string {
+	hash := checksums.SHA256
 	return filepath.Join(hash[0:2], hash[2:4], hash[4:32]+"_"+filename)
 }
 
@@ -146,13 +152,15 @@ func (pool *PackagePool) Import(srcPath, basename string, checksums *utils.Check
 
 	path := pool.buildPoolPath(basename, checksums)
 	blob := pool.az.blobURL(path)
-	targetChecksums, err := pool.ensureChecksums(path, checksumStorage)
-	if err != nil {
-		return "", err
-	} else if targetChecksums != nil {
-		// target already exists
-		*checksums = *targetChecksums
-		return path, nil
+	for _, candidatePath := range []string{path, pool.namedPoolPath(basename, checksums)} {
+		targetChecksums, err := pool.ensureChecksums(candidatePath, checksumStorage)
+		if err != nil {
+			return "", err
+		} else if targetChecksums != nil {
+			// target already exists
+			*checksums = *targetChecksums
+			return candidatePath, nil
+		}
 	}
 
 	source, err := os.Open(srcPath)
@@ -186,6 +194,14 @@ func (pool *PackagePool) Verify(poolPath, basename string, checksums *utils.Chec
 	if poolPath == "" {
 		if checksums.SHA256 != "" {
 			poolPath = pool.buildPoolPath(basename, checksums)
+
+			// file might be stored at the location used by previous versions
+			namedPath := pool.namedPoolPath(basename, checksums)
+			if _, err := pool.Size(poolPath); err != nil {
+				if _, err = pool.Size(namedPath); err == nil {
+					poolPath = namedPath
+				}
+			}
 		} else {
 			// No checksums or pool path, so no idea what file to look for.
 			return "", false, nil
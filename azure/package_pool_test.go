@@ -98,7 +98,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	var checksum utils.ChecksumInfo
 	path, err := s.pool.Import(s.debFile, filepath.Base(s.debFile), &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// SHA256 should be automatically calculated
 	c.Check(checksum.SHA256, Equals, "c76b4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum storage is filled with new checksum
@@ -108,11 +108,11 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(size, Equals, int64(2738))
 
-	// import as different name
+	// import as different name, same contents are stored once
 	checksum = utils.ChecksumInfo{}
 	path, err = s.pool.Import(s.debFile, "some.deb", &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_some.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum storage is filled with new checksum
 	c.Check(s.cs.(*files.MockChecksumStorage).Store[path].SHA256, Equals, "c76b4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 
@@ -120,7 +120,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	checksum = utils.ChecksumInfo{}
 	path, err = s.pool.Import(s.debFile, filepath.Base(s.debFile), &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on checksum storage
 	c.Check(checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 
@@ -129,7 +129,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	checksum = utils.ChecksumInfo{}
 	path, err = s.pool.Import(s.debFile, filepath.Base(s.debFile), &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on re-calculation of file in the pool
 	c.Check(checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 
@@ -137,7 +137,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	checksum = utils.ChecksumInfo{SHA256: checksum.SHA256}
 	path, err = s.pool.Import(s.debFile, "other.deb", &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_other.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on re-calculation of source file
 	c.Check(checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 }
@@ -153,7 +153,7 @@ func (s *PackagePoolSuite) TestVerify(c *C) {
 	checksum := utils.ChecksumInfo{}
 	path, err := s.pool.Import(s.debFile, filepath.Base(s.debFile), &checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 
 	// check existence
 	ppath, exists, err = s.pool.Verify("", filepath.Base(s.debFile), &checksum, s.cs)
@@ -24,7 +24,8 @@ type PublishedStorage struct {
 
 // Check interface
 var (
-	_ aptly.PublishedStorage = (*PublishedStorage)(nil)
+	_ aptly.PublishedStorage         = (*PublishedStorage)(nil)
+	_ aptly.ChecksumPublishedStorage = (*PublishedStorage)(nil)
 )
 
 // NewPublishedStorage creates published storage from Azure storage credentials
@@ -175,6 +176,23 @@ func (storage *PublishedStorage) Filelist(prefix string) ([]string, error) {
 	return paths, err
 }
 
+// FileMD5s returns MD5 checksums of files under prefix
+func (storage *PublishedStorage) FileMD5s(prefix string) (map[string]string, error) {
+	paths, md5s, err := storage.az.internalFilelist(prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(paths))
+	for i := range paths {
+		if md5s[i] != "" {
+			result[paths[i]] = md5s[i]
+		}
+	}
+
+	return result, nil
+}
+
 // Internal copy or move implementation
 func (storage *PublishedStorage) internalCopyOrMoveBlob(src, dst string, metadata azblob.Metadata, move bool) error {
 	const leaseDuration = 30
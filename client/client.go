@@ -0,0 +1,282 @@
+// Package client is a Go client for aptly REST API
+//
+// Client covers mirrors, snapshots, published repositories, tasks and file uploads. Long-running
+// operations are started as asynchronous tasks and awaited by the client, so they are not limited
+// by HTTP timeouts and cancellation of the context cancels the task on the server.
+//
+// Example:
+//
+//	c := client.New("http://localhost:8080")
+//	err := c.UpdateMirror(ctx, "wheezy-main", client.MirrorUpdateRequest{})
+//	...
+//	snapshot, err := c.CreateSnapshotFromMirror(ctx, "wheezy-main", client.SnapshotCreateRequest{Name: "wheezy-main-2024"})
+//	...
+//	_, err = c.UpdatePublished(ctx, "", "wheezy", client.PublishUpdateRequest{
+//		Snapshots: []client.PublishSource{{Component: "main", Name: snapshot.Name}},
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// Default retry and polling settings of the client
+const (
+	DefaultRetries      = 3
+	DefaultRetryDelay   = time.Second
+	DefaultPollInterval = time.Second
+)
+
+// Client is aptly API client
+//
+// Fields could be changed after client has been created with New, but not while
+// the client is in use.
+type Client struct {
+	// HTTPClient performs requests, timeouts are better controlled by passing context
+	// with deadline to the methods
+	HTTPClient *http.Client
+	// Token is sent as bearer token in Authorization header, if set
+	Token string
+	// Retries is number of times failed request is retried: idempotent requests are retried on
+	// network errors and on 502, 503 and 504, other requests are retried on 503 only
+	Retries int
+	// RetryDelay is delay before the first retry, it is doubled with each retry
+	RetryDelay time.Duration
+	// PollInterval is interval of polling state of asynchronous tasks
+	PollInterval time.Duration
+
+	baseURL string
+}
+
+// APIError is error reported by aptly API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error returns error message
+func (e *APIError) Error() string {
+	return fmt.Sprintf("aptly API error (HTTP %d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound checks whether err is API error reporting that object doesn't exist
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// New creates client for aptly API at baseURL, e.g. http://localhost:8080
+func New(baseURL string) *Client {
+	return &Client{
+		HTTPClient:   &http.Client{},
+		Retries:      DefaultRetries,
+		RetryDelay:   DefaultRetryDelay,
+		PollInterval: DefaultPollInterval,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// request is single API request, body is created for every attempt
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        func() (io.Reader, error)
+}
+
+// jsonRequest creates request with body encoded as JSON
+func jsonRequest(method, path string, query url.Values, body interface{}) (*request, error) {
+	req := &request{method: method, path: path, query: query}
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode request: %s", err)
+		}
+
+		req.contentType = "application/json"
+		req.body = func() (io.Reader, error) {
+			return bytes.NewReader(data), nil
+		}
+	}
+
+	return req, nil
+}
+
+// idempotent checks whether request could be safely repeated after network error
+func (req *request) idempotent() bool {
+	return req.method == http.MethodGet || req.method == http.MethodPut || req.method == http.MethodDelete
+}
+
+// retryable checks whether request failed with err should be retried, statusCode is 0 if
+// request failed before getting response
+func (req *request) retryable(statusCode int, err error) bool {
+	if _, ok := err.(*APIError); !ok {
+		return statusCode == 0 && req.idempotent()
+	}
+
+	switch statusCode {
+	case http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return req.idempotent()
+	}
+
+	return false
+}
+
+// do performs request retrying it if required and decodes JSON response into result
+// (if not nil), status code of response is returned
+func (c *Client) do(ctx context.Context, req *request, result interface{}) (int, error) {
+	delay := c.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		statusCode, err := c.doOnce(ctx, req, result)
+		if err == nil {
+			return statusCode, nil
+		}
+
+		if attempt >= c.Retries || ctx.Err() != nil || !req.retryable(statusCode, err) {
+			return statusCode, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return statusCode, err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// doOnce performs single attempt of request, status code is 0 if request failed before
+// getting response
+func (c *Client) doOnce(ctx context.Context, req *request, result interface{}) (int, error) {
+	u := c.baseURL + "/api" + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		var err error
+		body, err = req.body()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		return 0, err
+	}
+
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", fmt.Sprintf("aptly/%s", aptly.Version))
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, decodeError(resp)
+	}
+
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("unable to decode response: %s", err)
+		}
+	} else {
+		_, _ = io.Copy(io.Discard, resp.Body)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// decodeError builds APIError from error response, aptly reports errors as {"error": "..."}
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// get performs GET request decoding response into result
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	req, _ := jsonRequest(http.MethodGet, path, query, nil)
+	_, err := c.do(ctx, req, result)
+	return err
+}
+
+// send performs request with JSON body decoding response into result
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	req, err := jsonRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(ctx, req, result)
+	return err
+}
+
+// Version returns version of aptly server
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var result struct {
+		Version string
+	}
+
+	err := c.get(ctx, "/version", nil, &result)
+	return result.Version, err
+}
+
+// escapePrefix escapes publishing prefix to be used in URL path: "_" is doubled, "/" is replaced
+// with "_" and default prefix is sent as ":."
+func escapePrefix(prefix string) string {
+	if prefix == "" || prefix == "." {
+		return ":."
+	}
+
+	return strings.Replace(strings.Replace(prefix, "_", "__", -1), "/", "_", -1)
+}
+
+// setFlag sets boolean query parameter, if enabled
+func setFlag(query url.Values, name string, value bool) {
+	if value {
+		query.Set(name, "1")
+	}
+}
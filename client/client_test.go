@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ClientSuite struct {
+	srv      *httptest.Server
+	client   *Client
+	handlers map[string]http.HandlerFunc
+
+	mu       sync.Mutex
+	requests []string
+}
+
+var _ = Suite(&ClientSuite{})
+
+func (s *ClientSuite) SetUpTest(c *C) {
+	s.handlers = map[string]http.HandlerFunc{}
+	s.requests = nil
+
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		handler, ok := s.handlers[r.Method+" "+r.URL.Path]
+		s.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+
+		handler(w, r)
+	}))
+
+	s.client = New(s.srv.URL + "/")
+	s.client.RetryDelay = time.Millisecond
+	s.client.PollInterval = time.Millisecond
+}
+
+func (s *ClientSuite) TearDownTest(c *C) {
+	s.srv.Close()
+}
+
+func (s *ClientSuite) handle(pattern string, handler http.HandlerFunc) {
+	s.handlers[pattern] = handler
+}
+
+func (s *ClientSuite) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func (s *ClientSuite) TestShowMirror(c *C) {
+	s.client.Token = "secret"
+
+	s.handle("GET /api/mirrors/wheezy-main", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"Name":         "wheezy-main",
+			"ArchiveRoot":  "http://deb.debian.org/debian/",
+			"Distribution": "wheezy",
+			"Components":   []string{"main"},
+		})
+	})
+
+	mirror, err := s.client.ShowMirror(context.Background(), "wheezy-main")
+	c.Assert(err, IsNil)
+	c.Check(mirror.Name, Equals, "wheezy-main")
+	c.Check(mirror.Components, DeepEquals, []string{"main"})
+
+	_, err = s.client.ShowMirror(context.Background(), "squeeze")
+	c.Check(err, ErrorMatches, `aptly API error \(HTTP 404\): not found`)
+	c.Check(IsNotFound(err), Equals, true)
+
+	s.client.Token = "wrong"
+	_, err = s.client.ShowMirror(context.Background(), "wheezy-main")
+	c.Check(err, DeepEquals, &APIError{StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+	c.Check(IsNotFound(err), Equals, false)
+}
+
+func (s *ClientSuite) TestRetries(c *C) {
+	attempts := 0
+	s.handle("GET /api/snapshots", func(w http.ResponseWriter, _ *http.Request) {
+		attempts++
+		if attempts < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Aptly is unavailable"})
+			return
+		}
+		writeJSON(w, http.StatusOK, []map[string]string{{"Name": "snap1"}})
+	})
+
+	snapshots, err := s.client.ListSnapshots(context.Background(), "")
+	c.Assert(err, IsNil)
+	c.Check(snapshots, HasLen, 1)
+	c.Check(attempts, Equals, 3)
+
+	// number of retries is limited
+	attempts = -10
+	_, err = s.client.ListSnapshots(context.Background(), "")
+	c.Check(err, ErrorMatches, `.*HTTP 503.*`)
+	c.Check(attempts, Equals, -6)
+
+	// non-idempotent requests are not retried on bad gateway
+	posts := 0
+	s.handle("POST /api/mirrors", func(w http.ResponseWriter, _ *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err = s.client.CreateMirror(context.Background(), MirrorCreateRequest{Name: "mirror", ArchiveURL: "http://example.com/"})
+	c.Check(err, ErrorMatches, `aptly API error \(HTTP 502\): Bad Gateway`)
+	c.Check(posts, Equals, 1)
+}
+
+func (s *ClientSuite) TestRunTask(c *C) {
+	s.handle("POST /api/mirrors/wheezy-main/snapshots", func(w http.ResponseWriter, r *http.Request) {
+		var body SnapshotCreateRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		c.Check(body.Name, Equals, "snap1")
+		c.Check(r.URL.Query().Get("_async"), Equals, "true")
+
+		writeJSON(w, http.StatusAccepted, Task{ID: 7, Name: "Create snapshot", State: TaskIdle})
+	})
+
+	polls := 0
+	s.handle("GET /api/tasks/7", func(w http.ResponseWriter, _ *http.Request) {
+		polls++
+		state := TaskRunning
+		if polls > 2 {
+			state = TaskSucceeded
+		}
+		writeJSON(w, http.StatusOK, Task{ID: 7, Name: "Create snapshot", State: state})
+	})
+
+	s.handle("GET /api/tasks/7/return_value", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"Code":  http.StatusCreated,
+			"Value": map[string]string{"Name": "snap1", "Description": "Snapshot from mirror"},
+		})
+	})
+
+	snapshot, err := s.client.CreateSnapshotFromMirror(context.Background(), "wheezy-main", SnapshotCreateRequest{Name: "snap1"})
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Name, Equals, "snap1")
+	c.Check(snapshot.Description, Equals, "Snapshot from mirror")
+	c.Check(polls, Equals, 3)
+}
+
+func (s *ClientSuite) TestRunTaskFailed(c *C) {
+	s.handle("DELETE /api/publish/:./wheezy", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusAccepted, Task{ID: 3, Name: "Delete published . (wheezy)"})
+	})
+	s.handle("GET /api/tasks/3", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, Task{ID: 3, Name: "Delete published . (wheezy)", State: TaskFailed})
+	})
+	s.handle("GET /api/tasks/3/output", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, "Removing...\nTask failed with error: unable to drop: cleanup failed\n")
+	})
+
+	err := s.client.DropPublished(context.Background(), "", "wheezy", false, false)
+	c.Assert(err, FitsTypeOf, &TaskError{})
+	c.Check(err.(*TaskError).Task.State, Equals, TaskFailed)
+	c.Check(err, ErrorMatches, `task "Delete published \. \(wheezy\)" failed: Task failed with error: unable to drop: cleanup failed`)
+}
+
+func (s *ClientSuite) TestWaitTaskCanceled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.handle("GET /api/tasks/5", func(w http.ResponseWriter, _ *http.Request) {
+		cancel()
+		writeJSON(w, http.StatusOK, Task{ID: 5, Name: "Update mirror", State: TaskRunning})
+	})
+	s.handle("POST /api/tasks/5/cancel", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, Task{ID: 5, Name: "Update mirror", State: TaskRunning})
+	})
+
+	_, err := s.client.WaitTask(ctx, 5)
+	c.Check(err, Equals, context.Canceled)
+	c.Check(s.requested()[len(s.requested())-1], Equals, "POST /api/tasks/5/cancel")
+}
+
+func (s *ClientSuite) TestUpdatePublished(c *C) {
+	s.handle("PUT /api/publish/s3:repo:ppa_my__team/focal", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.Check(string(body), Equals, `{"Snapshots":[{"Component":"main","Name":"snap2"}],"Signing":{"Skip":true}}`)
+
+		writeJSON(w, http.StatusAccepted, Task{ID: 1})
+	})
+	s.handle("GET /api/tasks/1", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, Task{ID: 1, State: TaskSucceeded})
+	})
+	s.handle("GET /api/tasks/1/return_value", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"Code":  http.StatusOK,
+			"Value": map[string]interface{}{"Prefix": "ppa/my_team", "Distribution": "focal", "Sources": []PublishSource{{Component: "main", Name: "snap2"}}},
+		})
+	})
+
+	published, err := s.client.UpdatePublished(context.Background(), "s3:repo:ppa/my_team", "focal", PublishUpdateRequest{
+		Snapshots: []PublishSource{{Component: "main", Name: "snap2"}},
+		Signing:   SigningOptions{Skip: true},
+	})
+	c.Assert(err, IsNil)
+	c.Check(published.Prefix, Equals, "ppa/my_team")
+	c.Check(published.Sources, DeepEquals, []PublishSource{{Component: "main", Name: "snap2"}})
+}
+
+func (s *ClientSuite) TestUploadFiles(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "a_1.0_amd64.deb"), []byte("package a"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "b_1.0_amd64.deb"), []byte("package b"), 0644), IsNil)
+
+	uploaded := map[string]string{}
+	s.handle("POST /api/files/incoming", func(w http.ResponseWriter, r *http.Request) {
+		if !c.Check(r.ParseMultipartForm(1024), IsNil) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		stored := []string{}
+		for _, files := range r.MultipartForm.File {
+			for _, file := range files {
+				f, _ := file.Open()
+				contents, _ := io.ReadAll(f)
+				f.Close()
+
+				uploaded[file.Filename] = string(contents)
+				stored = append(stored, "incoming/"+file.Filename)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, stored)
+	})
+
+	stored, err := s.client.UploadFiles(context.Background(), "incoming", filepath.Join(dir, "a_1.0_amd64.deb"), filepath.Join(dir, "b_1.0_amd64.deb"))
+	c.Assert(err, IsNil)
+	c.Check(stored, DeepEquals, []string{"incoming/a_1.0_amd64.deb", "incoming/b_1.0_amd64.deb"})
+	c.Check(uploaded, DeepEquals, map[string]string{"a_1.0_amd64.deb": "package a", "b_1.0_amd64.deb": "package b"})
+
+	_, err = s.client.UploadFiles(context.Background(), "incoming", filepath.Join(dir, "missing.deb"))
+	c.Check(err, ErrorMatches, "unable to upload: .*no such file or directory")
+}
+
+func (s *ClientSuite) TestEscapePrefix(c *C) {
+	c.Check(escapePrefix(""), Equals, ":.")
+	c.Check(escapePrefix("."), Equals, ":.")
+	c.Check(escapePrefix("ppa"), Equals, "ppa")
+	c.Check(escapePrefix("ppa/my_team"), Equals, "ppa_my__team")
+	c.Check(escapePrefix("s3:repo:."), Equals, "s3:repo:.")
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// AddFilesOptions control import of uploaded files into local repo
+type AddFilesOptions struct {
+	// OnConflict is policy for packages conflicting with packages in the repo: "fail" (default),
+	// "skip", "replace" or "rename"
+	OnConflict string
+	// ForceReplace replaces packages conflicting with imported packages
+	ForceReplace bool
+	// NoRemove keeps uploaded files after import
+	NoRemove bool
+	// Atomic doesn't change the repo if any of the files fails to import
+	Atomic bool
+}
+
+// AddFilesReport describes results of importing uploaded files into local repo
+type AddFilesReport struct {
+	Report struct {
+		Warnings []string
+		Added    []string
+		Removed  []string
+	}
+	FailedFiles []string
+}
+
+func uploadPath(dir string) string {
+	return "/files/" + url.PathEscape(dir)
+}
+
+// UploadFiles uploads local files to upload directory dir, paths of uploaded files
+// relative to upload directory root are returned
+func (c *Client) UploadFiles(ctx context.Context, dir string, paths ...string) ([]string, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("unable to upload: %s", err)
+		}
+	}
+
+	req := &request{method: http.MethodPost, path: uploadPath(dir)}
+
+	// multipart body is streamed, so files are not loaded into memory
+	req.body = func() (io.Reader, error) {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		req.contentType = writer.FormDataContentType()
+
+		go func() {
+			pw.CloseWithError(writeMultipart(writer, paths))
+		}()
+
+		return pr, nil
+	}
+
+	var result []string
+	_, err := c.do(ctx, req, &result)
+	return result, err
+}
+
+// writeMultipart writes files as parts of multipart form
+func writeMultipart(writer *multipart.Writer, paths []string) error {
+	for _, path := range paths {
+		err := func() error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			part, err := writer.CreateFormFile("file", filepath.Base(path))
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(part, f)
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// ListUploadDirs lists upload directories
+func (c *Client) ListUploadDirs(ctx context.Context) ([]string, error) {
+	var result []string
+	err := c.get(ctx, "/files", nil, &result)
+	return result, err
+}
+
+// ListUploadedFiles lists files in upload directory
+func (c *Client) ListUploadedFiles(ctx context.Context, dir string) ([]string, error) {
+	var result []string
+	err := c.get(ctx, uploadPath(dir), nil, &result)
+	return result, err
+}
+
+// DeleteUploadDir removes upload directory with all the files
+func (c *Client) DeleteUploadDir(ctx context.Context, dir string) error {
+	return c.send(ctx, http.MethodDelete, uploadPath(dir), nil, nil, nil)
+}
+
+// DeleteUploadedFile removes single file from upload directory
+func (c *Client) DeleteUploadedFile(ctx context.Context, dir, name string) error {
+	return c.send(ctx, http.MethodDelete, uploadPath(dir)+"/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// AddUploadedFiles imports packages from upload directory into local repo, upload directory
+// is removed afterwards unless NoRemove is set
+func (c *Client) AddUploadedFiles(ctx context.Context, repo, dir string, options AddFilesOptions) (*AddFilesReport, error) {
+	query := url.Values{}
+	if options.OnConflict != "" {
+		query.Set("onConflict", options.OnConflict)
+	}
+	setFlag(query, "forceReplace", options.ForceReplace)
+	setFlag(query, "noRemove", options.NoRemove)
+	setFlag(query, "atomic", options.Atomic)
+
+	result := &AddFilesReport{}
+	err := c.runTask(ctx, http.MethodPost, "/repos/"+url.PathEscape(repo)+"/file/"+url.PathEscape(dir), query, nil, result)
+	return result, err
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Mirror is mirror of remote repository
+type Mirror struct {
+	Name                  string
+	ArchiveRoot           string
+	AlternateURLs         []string
+	Distribution          string
+	Components            []string
+	Architectures         []string
+	Meta                  map[string]string
+	LastDownloadDate      time.Time
+	Filter                string
+	IncludeSections       []string
+	ExcludeSections       []string
+	MinPriority           string
+	FilterWithDeps        bool
+	SkipComponentCheck    bool
+	SkipArchitectureCheck bool
+	DownloadSources       bool
+	DownloadUdebs         bool
+	DownloadInstaller     bool
+	UsePDiffs             bool
+	Proxy                 string
+	AptlyAPI              string
+	AptlyPrefix           string
+	UpstreamSourceKind    string
+	UpstreamSources       map[string]string
+}
+
+// MirrorCreateRequest describes mirror to be created
+//
+// Options which are not set default to aptly server configuration.
+type MirrorCreateRequest struct {
+	Name                  string
+	ArchiveURL            string
+	Distribution          string   `json:",omitempty"`
+	Filter                string   `json:",omitempty"`
+	Components            []string `json:",omitempty"`
+	Architectures         []string `json:",omitempty"`
+	Keyrings              []string `json:",omitempty"`
+	DownloadSources       bool     `json:",omitempty"`
+	DownloadUdebs         bool     `json:",omitempty"`
+	DownloadInstaller     bool     `json:",omitempty"`
+	FilterWithDeps        bool     `json:",omitempty"`
+	SkipComponentCheck    bool     `json:",omitempty"`
+	SkipArchitectureCheck bool     `json:",omitempty"`
+	IgnoreSignatures      bool     `json:",omitempty"`
+	UsePDiffs             bool     `json:",omitempty"`
+	Proxy                 string   `json:",omitempty"`
+	Username              string   `json:",omitempty"`
+	Password              string   `json:",omitempty"`
+	AlternateURLs         []string `json:",omitempty"`
+	IncludeSections       []string `json:",omitempty"`
+	ExcludeSections       []string `json:",omitempty"`
+	MinPriority           string   `json:",omitempty"`
+	AptlyAPI              string   `json:",omitempty"`
+	AptlyPrefix           string   `json:",omitempty"`
+}
+
+// MirrorUpdateRequest describes how mirror should be updated
+//
+// Settings which are not set (nil) keep current settings of the mirror.
+type MirrorUpdateRequest struct {
+	// Name renames mirror, if set
+	Name                  string    `json:",omitempty"`
+	ArchiveURL            string    `json:",omitempty"`
+	Filter                *string   `json:",omitempty"`
+	Architectures         *[]string `json:",omitempty"`
+	Components            *[]string `json:",omitempty"`
+	Keyrings              []string  `json:",omitempty"`
+	FilterWithDeps        *bool     `json:",omitempty"`
+	DownloadSources       *bool     `json:",omitempty"`
+	DownloadUdebs         *bool     `json:",omitempty"`
+	DownloadInstaller     *bool     `json:",omitempty"`
+	SkipComponentCheck    *bool     `json:",omitempty"`
+	SkipArchitectureCheck *bool     `json:",omitempty"`
+	UsePDiffs             *bool     `json:",omitempty"`
+	IgnoreChecksums       bool      `json:",omitempty"`
+	IgnoreSignatures      bool      `json:",omitempty"`
+	ForceUpdate           bool      `json:",omitempty"`
+	ForceIndexes          bool      `json:",omitempty"`
+	SkipExistingPackages  bool      `json:",omitempty"`
+}
+
+// MirrorUpdateRecord summarizes changes made by single mirror update
+type MirrorUpdateRecord struct {
+	Time    time.Time
+	Added   int
+	Removed int
+	Total   int
+}
+
+// MirrorHealth is health of the mirror: update history and staleness
+type MirrorHealth struct {
+	Name        string
+	LastUpdate  time.Time
+	ReleaseDate time.Time
+	ValidUntil  time.Time
+	Updates     []MirrorUpdateRecord
+	Stale       bool
+	Reason      string
+}
+
+func mirrorPath(name string) string {
+	return "/mirrors/" + url.PathEscape(name)
+}
+
+// ListMirrors lists all mirrors
+func (c *Client) ListMirrors(ctx context.Context) ([]Mirror, error) {
+	var result []Mirror
+	err := c.get(ctx, "/mirrors", nil, &result)
+	return result, err
+}
+
+// ShowMirror returns mirror by name
+func (c *Client) ShowMirror(ctx context.Context, name string) (*Mirror, error) {
+	result := &Mirror{}
+	err := c.get(ctx, mirrorPath(name), nil, result)
+	return result, err
+}
+
+// CreateMirror creates new mirror, mirror should be updated to download packages
+func (c *Client) CreateMirror(ctx context.Context, mirror MirrorCreateRequest) (*Mirror, error) {
+	result := &Mirror{}
+	err := c.send(ctx, http.MethodPost, "/mirrors", nil, mirror, result)
+	return result, err
+}
+
+// UpdateMirror downloads latest packages of the mirror, changing its settings if requested
+func (c *Client) UpdateMirror(ctx context.Context, name string, update MirrorUpdateRequest) error {
+	if update.Name == "" {
+		update.Name = name
+	}
+
+	return c.runTask(ctx, http.MethodPut, mirrorPath(name), nil, update, nil)
+}
+
+// DropMirror removes mirror, force allows to remove mirror which has snapshots
+func (c *Client) DropMirror(ctx context.Context, name string, force bool) error {
+	query := url.Values{}
+	setFlag(query, "force", force)
+
+	return c.runTask(ctx, http.MethodDelete, mirrorPath(name), query, nil, nil)
+}
+
+// MirrorStatus reports health of the mirror, staleAfter overrides staleness window of
+// server configuration, if positive
+func (c *Client) MirrorStatus(ctx context.Context, name string, staleAfter time.Duration) (*MirrorHealth, error) {
+	query := url.Values{}
+	if staleAfter > 0 {
+		query.Set("staleAfter", staleAfter.String())
+	}
+
+	result := &MirrorHealth{}
+	err := c.get(ctx, mirrorPath(name)+"/status", query, result)
+	return result, err
+}
+
+// MirrorPackages lists keys of mirror packages, optionally filtered by query q
+func (c *Client) MirrorPackages(ctx context.Context, name, q string) ([]string, error) {
+	query := url.Values{}
+	if q != "" {
+		query.Set("q", q)
+	}
+
+	var result []string
+	err := c.get(ctx, mirrorPath(name)+"/packages", query, &result)
+	return result, err
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Source kinds of published repositories
+const (
+	SourceSnapshot  = "snapshot"
+	SourceLocalRepo = "local"
+)
+
+// PublishSource is snapshot or local repo published to the component
+type PublishSource struct {
+	Component string `json:",omitempty"`
+	Name      string
+}
+
+// ComponentRule routes packages matching Query into Component on publish
+type ComponentRule struct {
+	Component string
+	Query     string
+}
+
+// SigningOptions control signing of published repository, by default server configuration
+// is used
+type SigningOptions struct {
+	Skip           bool   `json:",omitempty"`
+	GpgKey         string `json:",omitempty"`
+	Keyring        string `json:",omitempty"`
+	SecretKeyring  string `json:",omitempty"`
+	Passphrase     string `json:",omitempty"`
+	PassphraseFile string `json:",omitempty"`
+	DigestAlgo     string `json:",omitempty"`
+}
+
+// PublishedRepo is published repository
+type PublishedRepo struct {
+	Storage              string
+	Prefix               string
+	Path                 string
+	Distribution         string
+	Label                string
+	Origin               string
+	Suite                string
+	Codename             string
+	NotAutomatic         string
+	ButAutomaticUpgrades string
+	Architectures        []string
+	SourceKind           string
+	Sources              []PublishSource
+	SkipContents         bool
+	AcquireByHash        bool
+	DebianFieldOrder     bool
+	Description          string
+	CreatedAt            time.Time
+	Provenance           string
+	ValidFor             string
+	ValidUntil           time.Time
+	ReleaseFields        map[string]string
+	PublishKey           bool
+	PublicURL            string
+	Aliases              []string
+	Replicas             []string
+	FailedReplicas       []string
+	ComponentRules       []ComponentRule
+}
+
+// PublishRequest describes repository to be published
+type PublishRequest struct {
+	// SourceKind is SourceSnapshot or SourceLocalRepo
+	SourceKind           string
+	Sources              []PublishSource
+	Distribution         string            `json:",omitempty"`
+	Label                string            `json:",omitempty"`
+	Origin               string            `json:",omitempty"`
+	NotAutomatic         string            `json:",omitempty"`
+	ButAutomaticUpgrades string            `json:",omitempty"`
+	ForceOverwrite       bool              `json:",omitempty"`
+	SkipContents         *bool             `json:",omitempty"`
+	SkipBz2              *bool             `json:",omitempty"`
+	Architectures        []string          `json:",omitempty"`
+	AcquireByHash        *bool             `json:",omitempty"`
+	DebianFieldOrder     *bool             `json:",omitempty"`
+	MultiDist            bool              `json:",omitempty"`
+	Description          string            `json:",omitempty"`
+	Provenance           string            `json:",omitempty"`
+	ValidFor             string            `json:",omitempty"`
+	ReleaseFields        map[string]string `json:",omitempty"`
+	PublishKey           bool              `json:",omitempty"`
+	PublicURL            string            `json:",omitempty"`
+	Aliases              []string          `json:",omitempty"`
+	Replicas             []string          `json:",omitempty"`
+	ComponentRules       []ComponentRule   `json:",omitempty"`
+	// Signing overrides signing settings of server configuration
+	Signing SigningOptions
+}
+
+// PublishUpdateRequest describes update of published repository: local repos are
+// republished, snapshot repositories are switched to Snapshots
+//
+// Settings which are not set (nil) keep current settings of published repository.
+type PublishUpdateRequest struct {
+	Snapshots        []PublishSource    `json:",omitempty"`
+	ForceOverwrite   bool               `json:",omitempty"`
+	SkipContents     *bool              `json:",omitempty"`
+	SkipBz2          *bool              `json:",omitempty"`
+	SkipCleanup      *bool              `json:",omitempty"`
+	AcquireByHash    *bool              `json:",omitempty"`
+	DebianFieldOrder *bool              `json:",omitempty"`
+	MultiDist        bool               `json:",omitempty"`
+	Description      *string            `json:",omitempty"`
+	Provenance       *string            `json:",omitempty"`
+	ValidFor         *string            `json:",omitempty"`
+	ReleaseFields    *map[string]string `json:",omitempty"`
+	PublishKey       *bool              `json:",omitempty"`
+	PublicURL        *string            `json:",omitempty"`
+	Aliases          *[]string          `json:",omitempty"`
+	Replicas         *[]string          `json:",omitempty"`
+	ComponentRules   *[]ComponentRule   `json:",omitempty"`
+	// Signing overrides signing settings of server configuration
+	Signing SigningOptions
+}
+
+func publishedPath(prefix, distribution string) string {
+	return "/publish/" + url.PathEscape(escapePrefix(prefix)) + "/" + url.PathEscape(distribution)
+}
+
+// ListPublished lists published repositories
+func (c *Client) ListPublished(ctx context.Context) ([]PublishedRepo, error) {
+	var result []PublishedRepo
+	err := c.get(ctx, "/publish", nil, &result)
+	return result, err
+}
+
+// Publish publishes snapshots or local repos under prefix ([<storage>:]<prefix>, empty
+// for default prefix)
+func (c *Client) Publish(ctx context.Context, prefix string, publish PublishRequest) (*PublishedRepo, error) {
+	result := &PublishedRepo{}
+	err := c.runTask(ctx, http.MethodPost, "/publish/"+url.PathEscape(escapePrefix(prefix)), nil, publish, result)
+	return result, err
+}
+
+// UpdatePublished updates or switches published repository
+func (c *Client) UpdatePublished(ctx context.Context, prefix, distribution string, update PublishUpdateRequest) (*PublishedRepo, error) {
+	result := &PublishedRepo{}
+	err := c.runTask(ctx, http.MethodPut, publishedPath(prefix, distribution), nil, update, result)
+	return result, err
+}
+
+// DropPublished removes published repository, force allows to remove it even if cleanup of
+// published files fails
+func (c *Client) DropPublished(ctx context.Context, prefix, distribution string, force, skipCleanup bool) error {
+	query := url.Values{}
+	setFlag(query, "force", force)
+	setFlag(query, "SkipCleanup", skipCleanup)
+
+	return c.runTask(ctx, http.MethodDelete, publishedPath(prefix, distribution), query, nil, nil)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Snapshot is immutable list of packages
+type Snapshot struct {
+	Name        string
+	CreatedAt   time.Time
+	SourceKind  string
+	Description string
+	Provenance  string
+	Query       string
+	Origin      string
+}
+
+// SnapshotCreateRequest describes snapshot to be created from mirror or local repo
+type SnapshotCreateRequest struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Provenance  string `json:",omitempty"`
+}
+
+// SnapshotFromPackagesRequest describes snapshot to be created from list of packages
+// and source snapshots
+type SnapshotFromPackagesRequest struct {
+	Name            string
+	Description     string   `json:",omitempty"`
+	Provenance      string   `json:",omitempty"`
+	SourceSnapshots []string `json:",omitempty"`
+	PackageRefs     []string `json:",omitempty"`
+}
+
+func snapshotPath(name string) string {
+	return "/snapshots/" + url.PathEscape(name)
+}
+
+// ListSnapshots lists snapshots sorted by name or by creation time (sortMethod "time")
+func (c *Client) ListSnapshots(ctx context.Context, sortMethod string) ([]Snapshot, error) {
+	query := url.Values{}
+	if sortMethod != "" {
+		query.Set("sort", sortMethod)
+	}
+
+	var result []Snapshot
+	err := c.get(ctx, "/snapshots", query, &result)
+	return result, err
+}
+
+// ShowSnapshot returns snapshot by name
+func (c *Client) ShowSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	result := &Snapshot{}
+	err := c.get(ctx, snapshotPath(name), nil, result)
+	return result, err
+}
+
+// CreateSnapshotFromMirror creates snapshot of current state of the mirror
+func (c *Client) CreateSnapshotFromMirror(ctx context.Context, mirror string, snapshot SnapshotCreateRequest) (*Snapshot, error) {
+	result := &Snapshot{}
+	err := c.runTask(ctx, http.MethodPost, mirrorPath(mirror)+"/snapshots", nil, snapshot, result)
+	return result, err
+}
+
+// CreateSnapshotFromRepo creates snapshot of current state of the local repo
+func (c *Client) CreateSnapshotFromRepo(ctx context.Context, repo string, snapshot SnapshotCreateRequest) (*Snapshot, error) {
+	result := &Snapshot{}
+	err := c.runTask(ctx, http.MethodPost, "/repos/"+url.PathEscape(repo)+"/snapshots", nil, snapshot, result)
+	return result, err
+}
+
+// CreateSnapshot creates snapshot from package references and source snapshots
+func (c *Client) CreateSnapshot(ctx context.Context, snapshot SnapshotFromPackagesRequest) (*Snapshot, error) {
+	result := &Snapshot{}
+	err := c.runTask(ctx, http.MethodPost, "/snapshots", nil, snapshot, result)
+	return result, err
+}
+
+// DropSnapshot removes snapshot, force allows to remove snapshot which is source of
+// other snapshots
+func (c *Client) DropSnapshot(ctx context.Context, name string, force bool) error {
+	query := url.Values{}
+	setFlag(query, "force", force)
+
+	return c.runTask(ctx, http.MethodDelete, snapshotPath(name), query, nil, nil)
+}
+
+// SnapshotPackages lists keys of snapshot packages, optionally filtered by query q
+func (c *Client) SnapshotPackages(ctx context.Context, name, q string) ([]string, error) {
+	query := url.Values{}
+	if q != "" {
+		query.Set("q", q)
+	}
+
+	var result []string
+	err := c.get(ctx, snapshotPath(name)+"/packages", query, &result)
+	return result, err
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TaskState is state of the task on aptly server
+type TaskState int
+
+// Task states, as reported by aptly API
+const (
+	TaskIdle TaskState = iota
+	TaskRunning
+	TaskSucceeded
+	TaskFailed
+	TaskCanceled
+)
+
+// String returns human-readable task state
+func (s TaskState) String() string {
+	switch s {
+	case TaskIdle:
+		return "idle"
+	case TaskRunning:
+		return "running"
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskFailed:
+		return "failed"
+	case TaskCanceled:
+		return "canceled"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// Task is background task running on aptly server
+type Task struct {
+	ID    int
+	Name  string
+	State TaskState
+}
+
+// Finished checks whether task is not running anymore
+func (t *Task) Finished() bool {
+	return t.State == TaskSucceeded || t.State == TaskFailed || t.State == TaskCanceled
+}
+
+// TaskError is returned when asynchronous task has failed or has been canceled
+type TaskError struct {
+	Task Task
+	// Output is output of the task, last line usually explains failure
+	Output string
+}
+
+// Error returns error message
+func (e *TaskError) Error() string {
+	lines := strings.Split(strings.TrimSpace(e.Output), "\n")
+	return fmt.Sprintf("task %q %s: %s", e.Task.Name, e.Task.State, lines[len(lines)-1])
+}
+
+func taskPath(id int, suffix string) string {
+	return fmt.Sprintf("/tasks/%d%s", id, suffix)
+}
+
+// ListTasks lists tasks known to aptly server
+func (c *Client) ListTasks(ctx context.Context) ([]Task, error) {
+	var result []Task
+	err := c.get(ctx, "/tasks", nil, &result)
+	return result, err
+}
+
+// ShowTask returns task by ID
+func (c *Client) ShowTask(ctx context.Context, id int) (*Task, error) {
+	result := &Task{}
+	err := c.get(ctx, taskPath(id, ""), nil, result)
+	return result, err
+}
+
+// TaskOutput returns output of the task
+func (c *Client) TaskOutput(ctx context.Context, id int) (string, error) {
+	var result string
+	err := c.get(ctx, taskPath(id, "/output"), nil, &result)
+	return result, err
+}
+
+// CancelTask requests cancellation of the task, cancellation is cooperative, so task might
+// still be running when CancelTask returns
+func (c *Client) CancelTask(ctx context.Context, id int) (*Task, error) {
+	result := &Task{}
+	err := c.send(ctx, http.MethodPost, taskPath(id, "/cancel"), nil, nil, result)
+	return result, err
+}
+
+// DeleteTask removes finished task from the list of tasks
+func (c *Client) DeleteTask(ctx context.Context, id int) error {
+	return c.send(ctx, http.MethodDelete, taskPath(id, ""), nil, nil, nil)
+}
+
+// ClearTasks removes all finished tasks from the list of tasks
+func (c *Client) ClearTasks(ctx context.Context) error {
+	return c.send(ctx, http.MethodPost, "/tasks-clear", nil, nil, nil)
+}
+
+// WaitTask polls task until it is finished
+//
+// If ctx is canceled while waiting, cancellation of the task is requested and ctx error
+// is returned. Task which hasn't succeeded is reported as *TaskError.
+func (c *Client) WaitTask(ctx context.Context, id int) (*Task, error) {
+	for {
+		task, err := c.ShowTask(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.abandonTask(id)
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		if task.Finished() {
+			if task.State != TaskSucceeded {
+				output, _ := c.TaskOutput(ctx, id)
+				return task, &TaskError{Task: *task, Output: output}
+			}
+
+			return task, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			c.abandonTask(id)
+			return task, ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// abandonTask cancels task client is no longer waiting for, context of the caller is
+// already canceled at this point
+func (c *Client) abandonTask(id int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = c.CancelTask(ctx, id)
+}
+
+// runTask starts operation as asynchronous task, waits for it and decodes value returned
+// by the task into result (if not nil)
+func (c *Client) runTask(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("_async", "true")
+
+	req, err := jsonRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	task := &Task{}
+	_, err = c.do(ctx, req, task)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.WaitTask(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	var returnValue struct {
+		Code  int
+		Value json.RawMessage
+	}
+
+	err = c.get(ctx, taskPath(task.ID, "/return_value"), nil, &returnValue)
+	if err != nil {
+		return err
+	}
+
+	if len(returnValue.Value) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(returnValue.Value, result)
+	if err != nil {
+		return fmt.Errorf("unable to decode value returned by task: %s", err)
+	}
+
+	return nil
+}
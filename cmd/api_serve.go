@@ -39,6 +39,16 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 		return err
 	}
 
+	err = api.ValidateAPITokens(context.Config().APITokens)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	err = api.ValidateMirrorSchedules(context.Config().MirrorSchedules)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
 	// Try to recycle systemd fds for listening
 	listeners, err := activation.Listeners(true)
 	if len(listeners) > 1 {
@@ -106,6 +116,19 @@ or Unix domain socket. When using a socket, Aptly will fully manage the socket
 file. This command also supports taking over from a systemd file descriptors to
 enable systemd socket activation.
 
+If apiTokens are configured, every request (except /api/version, /api/ready
+and /api/healthy) should present one of the tokens in Authorization header
+('Authorization: Bearer <token>'). Role of the token defines what is allowed:
+read-only (GET requests), uploader (upload files and add or remove packages
+in local repositories), publisher (create snapshots, update mirrors and
+publish) and admin (everything). Token with publishPrefixes could change
+only published repositories under matching prefixes.
+
+If mirrorSchedules are configured, the server updates listed mirrors according
+to their cron schedules, optionally taking snapshot of the updated mirror and
+switching published repository to it. Scheduled jobs and their recent runs are
+listed at /api/schedules.
+
 Example:
 
   $ aptly api serve -listen=:8080
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// readOnlyCommands are commands which don't change anything, so they are not recorded
+// in the audit log; last word of the command is matched as well
+var readOnlyCommands = []string{"list", "show", "search", "diff", "verify", "graph", "version", "serve",
+	"api serve", "config show", "task run", "generate packages", "generate release", "help"}
+
+// sensitiveFlags have their values hidden in the audit log
+var sensitiveFlags = []string{"passphrase", "password"}
+
+// resolveCommand splits arguments into the name of the command being run and its arguments
+func resolveCommand(root *commander.Command, args []string) (name []string, rest []string) {
+	cmd := root
+
+	for len(args) > 0 {
+		var next *commander.Command
+		for _, sub := range cmd.Subcommands {
+			if sub.Name() == args[0] {
+				next = sub
+				break
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		name = append(name, args[0])
+		cmd, args = next, args[1:]
+	}
+
+	return name, args
+}
+
+// auditActor returns name of OS user running the command
+func auditActor() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name = fmt.Sprintf("%s (as %s)", sudoUser, name)
+	}
+
+	return name
+}
+
+// startAudit starts recording changes made by command, nil is returned if
+// audit log is disabled or command doesn't change anything
+func startAudit(root *commander.Command, flags *flag.FlagSet, args []string) (*deb.AuditEntry, *deb.AuditRecorder) {
+	name, rest := resolveCommand(root, args)
+	if len(name) == 0 {
+		return nil, nil
+	}
+
+	operation := strings.Join(name, " ")
+	for _, readOnly := range readOnlyCommands {
+		if operation == readOnly || name[len(name)-1] == readOnly {
+			return nil, nil
+		}
+	}
+
+	if !context.Config().EnableAuditLog {
+		return nil, nil
+	}
+
+	arguments := append([]string(nil), rest...)
+	flags.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		for _, sensitive := range sensitiveFlags {
+			if f.Name == sensitive {
+				value = "***"
+			}
+		}
+		arguments = append(arguments, fmt.Sprintf("-%s=%s", f.Name, value))
+	})
+
+	recorder := deb.NewAuditRecorder()
+	context.SetAuditRecorder(recorder)
+
+	return deb.NewAuditEntry(deb.AuditSourceCLI, auditActor(), operation, arguments), recorder
+}
+
+// finishAudit stores audit entry with the outcome of the command
+func finishAudit(entry *deb.AuditEntry, recorder *deb.AuditRecorder, err error) {
+	if entry == nil {
+		return
+	}
+
+	context.SetAuditRecorder(nil)
+
+	entry.Changes = recorder.Changes()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	context.Audit(entry)
+}
@@ -3,6 +3,7 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"text/template"
@@ -65,6 +66,25 @@ func PrintPackageList(result *deb.PackageList, format, prefix string) error {
 
 }
 
+// PrintPackageListJSON shows package list as JSON array of package stanzas
+func PrintPackageListJSON(result *deb.PackageList) error {
+	result.PrepareIndex()
+
+	packages := make([]*deb.Package, 0, result.Len())
+	_ = result.ForEachIndexed(func(p *deb.Package) error {
+		packages = append(packages, p)
+		return nil
+	})
+
+	output, err := json.MarshalIndent(packages, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(output))
+	return nil
+}
+
 // LookupOption checks boolean flag with default (usually config) and command-line
 // setting
 func LookupOption(defaultValue bool, flags *flag.FlagSet, name string) (result bool) {
@@ -98,7 +118,9 @@ package environment to new version.`,
 		Subcommands: []*commander.Command{
 			makeCmdConfig(),
 			makeCmdDb(),
+			makeCmdGenerate(),
 			makeCmdGraph(),
+			makeCmdKey(),
 			makeCmdMirror(),
 			makeCmdRepo(),
 			makeCmdServe(),
@@ -119,7 +141,7 @@ package environment to new version.`,
 	cmd.Flag.Bool("dep-verbose-resolve", false, "when processing dependencies, print detailed logs")
 	cmd.Flag.String("architectures", "", "list of architectures to consider during (comma-separated), default to all available")
 	cmd.Flag.String("config", "", "location of configuration file (default locations are /etc/aptly.conf, ~/.aptly.conf)")
-	cmd.Flag.String("gpg-provider", "", "PGP implementation (\"gpg\", \"gpg1\", \"gpg2\" for external gpg or \"internal\" for Go internal implementation)")
+	cmd.Flag.String("gpg-provider", "", "PGP implementation (\"gpg\", \"gpg1\", \"gpg2\" for external gpg, \"internal\" for Go internal implementation or \"external\" for external signing service)")
 
 	if aptly.EnableDebug {
 		cmd.Flag.String("cpuprofile", "", "write cpu profile to file")
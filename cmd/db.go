@@ -11,6 +11,7 @@ func makeCmdDb() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdDbCleanup(),
 			makeCmdDbRecover(),
+			makeCmdDbFsck(),
 		},
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -25,7 +26,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 	collectionFactory := context.NewCollectionFactory()
 
 	// collect information about references packages...
-	existingPackageRefs := deb.NewPackageRefList()
+	packageRefCounter := deb.NewPackageRefCounter()
 
 	// used only in verbose mode to report package use source
 	packageRefSources := map[string][]string{}
@@ -44,7 +45,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 			return e
 		}
 		if repo.RefList() != nil {
-			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
+			packageRefCounter.Add(repo.RefList())
 
 			if verbose {
 				description := fmt.Sprintf("mirror %s", repo.Name)
@@ -77,7 +78,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 		}
 
 		if repo.RefList() != nil {
-			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
+			packageRefCounter.Add(repo.RefList())
 
 			if verbose {
 				description := fmt.Sprintf("local repo %s", repo.Name)
@@ -109,7 +110,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 			return e
 		}
 
-		existingPackageRefs = existingPackageRefs.Merge(snapshot.RefList(), false, true)
+		packageRefCounter.Add(snapshot.RefList())
 
 		if verbose {
 			description := fmt.Sprintf("snapshot %s", snapshot.Name)
@@ -142,7 +143,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 		}
 
 		for _, component := range published.Components() {
-			existingPackageRefs = existingPackageRefs.Merge(published.RefList(component), false, true)
+			packageRefCounter.Add(published.RefList(component))
 			if verbose {
 				description := fmt.Sprintf("published repository %s:%s/%s component %s",
 					published.Storage, published.Prefix, published.Distribution, component)
@@ -164,7 +165,8 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 	context.Progress().ColoredPrintf("@{w!}Loading list of all packages...@|")
 	allPackageRefs := collectionFactory.PackageCollection().AllPackageRefs()
 
-	toDelete := allPackageRefs.Subtract(existingPackageRefs)
+	toDelete := packageRefCounter.Unreferenced(allPackageRefs)
+	existingPackageRefs := packageRefCounter.Referenced()
 
 	// delete packages that are no longer referenced
 	context.Progress().ColoredPrintf("@{r!}Deleting unreferenced packages (%d)...@|", toDelete.Len())
@@ -185,18 +187,10 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 		}
 
 		if !dryRun {
-			batch := db.CreateBatch()
-			err = toDelete.ForEach(func(ref []byte) error {
-				return collectionFactory.PackageCollection().DeleteByKey(ref, batch)
-			})
+			err = collectionFactory.PackageCollection().DeleteByKeys(toDelete)
 			if err != nil {
 				return fmt.Errorf("unable to delete by key: %s", err)
 			}
-
-			err = batch.Write()
-			if err != nil {
-				return fmt.Errorf("unable to write to DB: %s", err)
-			}
 		} else {
 			context.Progress().ColoredPrintf("@{y!}Skipped deletion, as -dry-run has been requested.@|")
 		}
@@ -282,6 +276,26 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 	}
 
 	if !dryRun {
+		context.Progress().ColoredPrintf("@{w!}Removing unused cached package indexes...@|")
+		removed, e := context.IndexCache().Prune()
+		if e != nil {
+			return fmt.Errorf("unable to clean up package index cache: %s", e)
+		}
+		if verbose {
+			context.Progress().ColoredPrintf("@{r}Removed %d cached package indexes@|", removed)
+		}
+
+		if retention := context.Config().AuditLogRetention; retention > 0 {
+			context.Progress().ColoredPrintf("@{w!}Removing old audit log entries...@|")
+			removed, e = collectionFactory.AuditCollection().Prune(time.Now().Add(-time.Duration(retention) * time.Second))
+			if e != nil {
+				return fmt.Errorf("unable to clean up audit log: %s", e)
+			}
+			if verbose {
+				context.Progress().ColoredPrintf("@{r}Removed %d audit log entries@|", removed)
+			}
+		}
+
 		context.Progress().ColoredPrintf("@{w!}Compacting database...@|")
 		err = db.CompactDB()
 	} else {
@@ -298,7 +312,9 @@ func makeCmdDbCleanup() *commander.Command {
 		Short:     "cleanup DB and package pool",
 		Long: `
 Database cleanup removes information about unreferenced packages and removes
-files in the package pool that aren't used by packages anymore
+files in the package pool that aren't used by packages anymore. Cached package
+indexes which haven't been used by mirror updates for 30 days are removed as well,
+audit log entries are removed after auditLogRetention (if configured).
 
 Example:
 
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+// aptly db fsck
+func aptlyDbFsck(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	verbose := context.Flags().Lookup("verbose").Value.Get().(bool)
+	collectionFactory := context.NewCollectionFactory()
+
+	checker := deb.NewConsistencyChecker(collectionFactory, context.PackagePool(), context, context.Progress())
+	checker.Repair = context.Flags().Lookup("repair").Value.Get().(bool)
+
+	report, err := checker.Check()
+	if err != nil {
+		return fmt.Errorf("unable to check consistency: %s", err)
+	}
+
+	for _, issue := range report.Issues {
+		if issue.Kind == deb.IssueUnreferencedPoolFile && !verbose {
+			continue
+		}
+		if issue.Repaired {
+			context.Progress().ColoredPrintf("@{g}%s@|", issue)
+		} else {
+			context.Progress().ColoredPrintf("@{r}%s@|", issue)
+		}
+	}
+
+	context.Progress().ColoredPrintf("@{w!}Summary:@|")
+	for _, kind := range []deb.ConsistencyIssueKind{deb.IssueDanglingReference, deb.IssueMissingPoolFile,
+		deb.IssueUnreferencedPoolFile, deb.IssueMissingPublishedFile, deb.IssueMissingSource} {
+		context.Progress().Printf("  %s: %d\n", kind, report.Count(kind))
+	}
+
+	if report.Count(deb.IssueMissingSource) > 0 {
+		context.Progress().ColoredPrintf("@{y}Published repositories with missing sources should be dropped with 'aptly publish drop' and published again.@|")
+	}
+
+	if report.Count(deb.IssueUnreferencedPoolFile) > 0 {
+		context.Progress().ColoredPrintf("@{y}Unreferenced pool files could be removed with 'aptly db cleanup'.@|")
+	}
+
+	if unrepaired := report.Unrepaired() - report.Count(deb.IssueUnreferencedPoolFile); unrepaired > 0 {
+		if !checker.Repair {
+			context.Progress().ColoredPrintf("@{y}Run with -repair to fix dangling references and missing published files.@|")
+		}
+		return fmt.Errorf("database is inconsistent: %d issue(s) left unrepaired", unrepaired)
+	}
+
+	return nil
+}
+
+func makeCmdDbFsck() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyDbFsck,
+		UsageLine: "fsck",
+		Short:     "check consistency of DB, package pool and published repositories",
+		Long: `
+Command fsck cross-checks references in the database, files in the package pool
+and pool files of published repositories in one pass, and reports every
+inconsistency found:
+
+  * dangling reference: mirror, local repo, snapshot or published repository
+    references package which is missing from the database
+  * missing pool file: package file is missing from the package pool
+  * unreferenced pool file: file in the package pool is not used by any package
+    (shown only with -verbose, removed by 'aptly db cleanup')
+  * missing published file: package file is missing from the pool of published
+    repository
+  * missing published source: snapshot or local repo published repository is
+    based on is missing from the database (such published repositories are
+    not checked any further and can't be repaired automatically)
+
+With -repair dangling references are pruned and missing published files are
+linked again from the package pool.
+
+Example:
+
+  $ aptly db fsck -repair
+`,
+	}
+
+	cmd.Flag.Bool("repair", false, "repair dangling references and missing published files")
+	cmd.Flag.Bool("verbose", false, "report unreferenced pool files as well")
+
+	return cmd
+}
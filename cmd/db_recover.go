@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/smira/commander"
 
 	"github.com/aptly-dev/aptly/database/goleveldb"
@@ -15,6 +17,10 @@ func aptlyDbRecover(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	if backend := context.Config().DatabaseBackend.Type; backend != "" && backend != "leveldb" {
+		return fmt.Errorf("unable to recover: not supported for %s database backend", backend)
+	}
+
 	context.Progress().Printf("Recovering database...\n")
 	err = goleveldb.RecoverDB(context.DBPath())
 
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/smira/commander"
+)
+
+func makeCmdGenerate() *commander.Command {
+	return &commander.Command{
+		UsageLine: "generate",
+		Short:     "generate indexes for plain directory of packages",
+		Subcommands: []*commander.Command{
+			makeCmdGeneratePackages(),
+			makeCmdGenerateRelease(),
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyGeneratePackages(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	// index goes to stdout, so warnings are printed to stderr
+	reporter := &aptly.RecordingResultReporter{}
+
+	packageFiles, _, failedFiles := deb.CollectPackageFiles(args, reporter)
+	list, failedScans := deb.ScanPackageFiles(packageFiles, context.ArchitecturesList(), reporter)
+	failedFiles = append(failedFiles, failedScans...)
+
+	for _, warning := range reporter.Warnings {
+		fmt.Fprintf(os.Stderr, "%s\n", warning)
+	}
+
+	list.PrepareIndex()
+
+	w := bufio.NewWriter(os.Stdout)
+	err = list.ForEachIndexed(func(p *deb.Package) error {
+		if e := p.Stanza().WriteTo(w, false, false, false); e != nil {
+			return e
+		}
+		return w.WriteByte('\n')
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to generate: %s", err)
+	}
+
+	if len(failedFiles) > 0 {
+		return fmt.Errorf("some files failed to be scanned")
+	}
+
+	return err
+}
+
+func makeCmdGeneratePackages() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyGeneratePackages,
+		UsageLine: "packages <directory> | <package file> ...",
+		Short:     "generate Packages index for package files",
+		Long: `
+Command packages scans directories (recursively) and files for .deb and .udeb
+package files and writes Packages index for them to stdout, as apt-ftparchive
+packages and dpkg-scanpackages do. Mirrors, local repos and package pool are not
+involved. Filename field of each package is path to the package file as it was
+found, so command should be run from the root of the repository being generated.
+
+Only packages for architectures listed with global -architectures flag are
+included, if it is set.
+
+Example:
+
+    $ cd /srv/repo
+    $ aptly generate packages . | tee Packages | gzip -9 > Packages.gz
+    $ aptly generate release . > Release
+`,
+		Flag: *flag.NewFlagSet("aptly-generate-packages", flag.ExitOnError),
+	}
+
+	return cmd
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyGenerateRelease(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	release := make(deb.Stanza)
+	for _, field := range []string{"origin", "label", "suite", "codename"} {
+		value := context.Flags().Lookup(field).Value.String()
+		if value != "" {
+			release[strings.ToUpper(field[:1])+field[1:]] = value
+		}
+	}
+	if description := context.Flags().Lookup("description").Value.String(); description != "" {
+		release["Description"] = " " + description
+	}
+	if architectures := context.GlobalFlags().Lookup("architectures").Value.String(); architectures != "" {
+		release["Architectures"] = strings.Join(strings.Split(architectures, ","), " ")
+	}
+	if components := context.Flags().Lookup("component").Value.String(); components != "" {
+		release["Components"] = strings.Join(strings.Split(components, ","), " ")
+	}
+
+	err = deb.BuildDirectoryRelease(args[0], release)
+	if err != nil {
+		return fmt.Errorf("unable to generate: %s", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	err = release.WriteTo(w, false, true, false)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to generate: %s", err)
+	}
+
+	return err
+}
+
+func makeCmdGenerateRelease() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyGenerateRelease,
+		UsageLine: "release <directory>",
+		Short:     "generate Release file for directory with package indexes",
+		Long: `
+Command release writes Release file for repository in <directory> to stdout,
+as apt-ftparchive release does: all package indexes (Packages, Sources,
+Contents, Translation files and their compressed variants) found in directory
+are listed with their checksums. Release file is not signed, use gpg to sign it.
+
+Example:
+
+    $ aptly generate release -origin=Example . > Release
+`,
+		Flag: *flag.NewFlagSet("aptly-generate-release", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("origin", "", "value of Origin field")
+	cmd.Flag.String("label", "", "value of Label field")
+	cmd.Flag.String("suite", "", "value of Suite field")
+	cmd.Flag.String("codename", "", "value of Codename field")
+	cmd.Flag.String("description", "", "value of Description field")
+	cmd.Flag.String("component", "", "list of components, comma-separated")
+
+	return cmd
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/commander"
+)
+
+// keyNamespace returns key store namespace selected with -mirror flag and its description
+func keyNamespace() (string, string, error) {
+	mirrorName := context.Flags().Lookup("mirror").Value.String()
+	if mirrorName == "" {
+		return pgp.GlobalKeyNamespace, "global trusted keys", nil
+	}
+
+	repo, err := context.NewCollectionFactory().RemoteRepoCollection().ByName(mirrorName)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pgp.MirrorKeyNamespace(repo.UUID), fmt.Sprintf("trusted keys of mirror %s", repo.Name), nil
+}
+
+func makeCmdKey() *commander.Command {
+	return &commander.Command{
+		UsageLine: "key",
+		Short:     "manage trusted keys used to verify mirrors",
+		Subcommands: []*commander.Command{
+			makeCmdKeyImport(),
+			makeCmdKeyList(),
+			makeCmdKeyRemove(),
+		},
+	}
+}
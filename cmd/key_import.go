@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyKeyImport(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) == 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	namespace, description, err := keyNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to import keys: %s", err)
+	}
+
+	keyserver := context.Flags().Lookup("keyserver").Value.String()
+	store := context.KeyStore()
+
+	for _, source := range args {
+		var (
+			data []byte
+			only []pgp.Key
+		)
+
+		if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+			data, err = pgp.FetchKey(source)
+		} else if _, err = os.Stat(source); err == nil {
+			data, err = os.ReadFile(source)
+		} else {
+			var (
+				key       pgp.Key
+				lookupURL string
+			)
+
+			key, err = pgp.ParseKeyID(source)
+			if err != nil {
+				return fmt.Errorf("unable to import keys: %s is neither existing file, URL nor valid key ID", source)
+			}
+
+			// keyserver might return other keys, only requested key is imported
+			only = []pgp.Key{key}
+
+			lookupURL, err = pgp.KeyserverURL(keyserver, key)
+			if err == nil {
+				data, err = pgp.FetchKey(lookupURL)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unable to import keys from %s: %s", source, err)
+		}
+
+		var keys []pgp.StoredKey
+		keys, err = store.Import(namespace, bytes.NewReader(data), only)
+		if err != nil {
+			return fmt.Errorf("unable to import keys from %s: %s", source, err)
+		}
+
+		for _, key := range keys {
+			fmt.Printf("Key %s has been imported into %s.\n", key, description)
+		}
+	}
+
+	return err
+}
+
+func makeCmdKeyImport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyKeyImport,
+		UsageLine: "import <key-id> | <url> | <file> ...",
+		Short:     "import keys into aptly trusted key store",
+		Long: `
+Command import adds keys into aptly's own store of trusted keys, which is used
+to verify signatures of remote repositories in addition to GPG keyrings. Keys
+could be fetched from keyserver by key ID (or fingerprint), downloaded from
+http(s) URL or read from file, both ASCII-armored and binary keys are accepted.
+
+By default, keys are trusted for all mirrors, with -mirror keys are trusted
+only when verifying signatures of that mirror.
+
+Example:
+
+  $ aptly key import -mirror=wheezy-main 8B48AD6246925553
+  $ aptly key import https://download.docker.com/linux/debian/gpg
+`,
+		Flag: *flag.NewFlagSet("aptly-key-import", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("mirror", "", "import keys trusted only for mirror with this name")
+	cmd.Flag.String("keyserver", pgp.DefaultKeyserver, "keyserver to fetch keys by key ID from")
+
+	return cmd
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyKeyList(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	namespace, description, err := keyNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to list keys: %s", err)
+	}
+
+	keys, err := context.KeyStore().List(namespace)
+	if err != nil {
+		return fmt.Errorf("unable to list keys: %s", err)
+	}
+
+	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
+
+	if raw {
+		for _, key := range keys {
+			fmt.Printf("%s\n", key.KeyID)
+		}
+	} else {
+		if len(keys) > 0 {
+			fmt.Printf("List of %s:\n", description)
+			for _, key := range keys {
+				fmt.Printf(" * %s\n", key)
+			}
+		} else {
+			fmt.Printf("No %s found, import some with `aptly key import ...`.\n", description)
+		}
+	}
+
+	return nil
+}
+
+func makeCmdKeyList() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyKeyList,
+		UsageLine: "list",
+		Short:     "list keys in aptly trusted key store",
+		Long: `
+Command list shows keys trusted for all mirrors or, with -mirror,
+keys trusted only for that mirror.
+
+Example:
+
+  $ aptly key list -mirror=wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-key-list", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("mirror", "", "list keys trusted only for mirror with this name")
+	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
+
+	return cmd
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyKeyRemove(cmd *commander.Command, args []string) error {
+	if len(args) == 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	keys := make([]pgp.Key, len(args))
+	for i, arg := range args {
+		key, err := pgp.ParseKeyID(arg)
+		if err != nil {
+			return fmt.Errorf("unable to remove keys: %s", err)
+		}
+		keys[i] = key
+	}
+
+	namespace, description, err := keyNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to remove keys: %s", err)
+	}
+
+	removed, err := context.KeyStore().Remove(namespace, keys)
+	if err != nil {
+		return fmt.Errorf("unable to remove keys: %s", err)
+	}
+
+	for _, key := range removed {
+		fmt.Printf("Key %s has been removed from %s.\n", key, description)
+	}
+
+	return nil
+}
+
+func makeCmdKeyRemove() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyKeyRemove,
+		UsageLine: "remove <key-id> ...",
+		Short:     "remove keys from aptly trusted key store",
+		Long: `
+Command remove deletes keys matching key IDs (or fingerprints) from keys
+trusted for all mirrors or, with -mirror, from keys trusted for that mirror.
+
+Example:
+
+  $ aptly key remove -mirror=wheezy-main 8B48AD6246925553
+`,
+		Flag: *flag.NewFlagSet("aptly-key-remove", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("mirror", "", "remove keys trusted only for mirror with this name")
+
+	return cmd
+}
@@ -3,12 +3,14 @@ package cmd
 import (
 	"strings"
 
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
 
-func getVerifier(flags *flag.FlagSet) (pgp.Verifier, error) {
+func getVerifier(flags *flag.FlagSet, repo *deb.RemoteRepo) (pgp.Verifier, error) {
 	keyRings := flags.Lookup("keyring").Value.Get().([]string)
 	ignoreSignatures := context.Config().GpgDisableVerify
 	if context.Flags().IsSet("ignore-signatures") {
@@ -20,6 +22,16 @@ func getVerifier(flags *flag.FlagSet) (pgp.Verifier, error) {
 		verifier.AddKeyring(keyRing)
 	}
 
+	if trustedKeyrings := context.TrustedKeyrings(repo); len(trustedKeyrings) > 0 {
+		if len(keyRings) == 0 {
+			// keys from key store are trusted in addition to default keyring
+			verifier.AddKeyring("trustedkeys.gpg")
+		}
+		for _, keyRing := range trustedKeyrings {
+			verifier.AddKeyring(keyRing)
+		}
+	}
+
 	err := verifier.InitKeyring(ignoreSignatures == false) // be verbose only if verifying signatures is requested
 	if err != nil {
 		return nil, err
@@ -28,6 +40,98 @@ func getVerifier(flags *flag.FlagSet) (pgp.Verifier, error) {
 	return verifier, nil
 }
 
+// getRemoteDownloader returns downloader configured with mirror proxy, credentials and certificates
+func getRemoteDownloader(repo *deb.RemoteRepo) (aptly.Downloader, error) {
+	options, err := repo.DownloaderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	downloader, err := context.RemoteDownloader(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.MirrorDownloader(downloader), nil
+}
+
+// addMirrorAccessFlags adds flags configuring proxy, credentials and client certificates of mirror
+func addMirrorAccessFlags(cmd *commander.Command) {
+	cmd.Flag.String("proxy", "", "HTTP(S) proxy URL used to access remote repository (overrides environment settings)")
+	cmd.Flag.String("username", "", "username for HTTP basic or digest authentication")
+	cmd.Flag.String("password-file", "", "file with password for HTTP authentication (read on each access)")
+	cmd.Flag.String("password-env", "", "name of environment variable with password for HTTP authentication (read on each access)")
+	cmd.Flag.String("tls-client-cert", "", "PEM file with client TLS certificate")
+	cmd.Flag.String("tls-client-key", "", "PEM file with client TLS key")
+	cmd.Flag.String("tls-ca-cert", "", "PEM file with CA certificates used to verify remote repository")
+}
+
+// applyMirrorAccessFlag updates mirror access settings from the flag, other flags are ignored
+func applyMirrorAccessFlag(repo *deb.RemoteRepo, flag *flag.Flag) {
+	switch flag.Name {
+	case "proxy":
+		repo.Proxy = flag.Value.String()
+	case "username":
+		repo.Username = flag.Value.String()
+	case "password-file":
+		repo.PasswordFile = flag.Value.String()
+	case "password-env":
+		repo.PasswordEnv = flag.Value.String()
+	case "tls-client-cert":
+		repo.TLSClientCert = flag.Value.String()
+	case "tls-client-key":
+		repo.TLSClientKey = flag.Value.String()
+	case "tls-ca-cert":
+		repo.TLSCACert = flag.Value.String()
+	}
+}
+
+// addMirrorSectionFlags adds flags limiting mirrored packages by section and priority
+func addMirrorSectionFlags(cmd *commander.Command) {
+	cmd.Flag.String("include-sections", "", "comma-separated list of sections to mirror (e.g. admin,utils), other sections are skipped")
+	cmd.Flag.String("exclude-sections", "", "comma-separated list of sections to skip when mirroring (e.g. games,doc)")
+	cmd.Flag.String("min-priority", "", "skip packages with priority lower than that (required, important, standard, optional, extra)")
+}
+
+// applyMirrorSectionFlag updates mirror section and priority limits from the flag, other flags are ignored
+func applyMirrorSectionFlag(repo *deb.RemoteRepo, flag *flag.Flag) {
+	switch flag.Name {
+	case "include-sections":
+		repo.IncludeSections = parseSectionList(flag.Value.String())
+	case "exclude-sections":
+		repo.ExcludeSections = parseSectionList(flag.Value.String())
+	case "min-priority":
+		repo.MinPriority = strings.ToLower(flag.Value.String())
+	}
+}
+
+// addMirrorVerificationFlags adds flags configuring verification of files downloaded for the mirror
+func addMirrorVerificationFlags(cmd *commander.Command) {
+	cmd.Flag.String("checksum-policy", "", "verification policy for downloaded files: strict (SHA256 or SHA512 required) or permissive (only the strongest checksum verified)")
+	cmd.Flag.Bool("quarantine", false, "move files failing verification to quarantine and skip their packages instead of failing update")
+}
+
+// applyMirrorVerificationFlag updates mirror verification settings from the flag, other flags are ignored
+func applyMirrorVerificationFlag(repo *deb.RemoteRepo, flag *flag.Flag) {
+	switch flag.Name {
+	case "checksum-policy":
+		repo.ChecksumPolicy = strings.ToLower(flag.Value.String())
+	case "quarantine":
+		repo.Quarantine = flag.Value.Get().(bool)
+	}
+}
+
+func parseSectionList(value string) []string {
+	var sections []string
+	for _, section := range strings.Split(value, ",") {
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
+	}
+
+	return sections
+}
+
 type keyRingsFlag struct {
 	keyRings []string
 }
@@ -58,6 +162,7 @@ func makeCmdMirror() *commander.Command {
 			makeCmdMirrorRename(),
 			makeCmdMirrorEdit(),
 			makeCmdMirrorSearch(),
+			makeCmdMirrorStatus(),
 		},
 	}
 }
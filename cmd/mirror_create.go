@@ -46,29 +46,77 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to create mirror: %s", err)
 	}
 
+	snapshotTime := context.Flags().Lookup("snapshot-time").Value.String()
+	if snapshotTime != "" {
+		err = repo.ApplyDebianSnapshotTime(snapshotTime)
+		if err != nil {
+			return fmt.Errorf("unable to create mirror: %s", err)
+		}
+	} else if strings.HasPrefix(archiveURL, "snapshot:") {
+		return fmt.Errorf("unable to create mirror: -snapshot-time is required to mirror %s", archiveURL)
+	}
+
 	repo.Filter = context.Flags().Lookup("filter").Value.String()
 	repo.FilterWithDeps = context.Flags().Lookup("filter-with-deps").Value.Get().(bool)
 	repo.SkipComponentCheck = context.Flags().Lookup("force-components").Value.Get().(bool)
 	repo.SkipArchitectureCheck = context.Flags().Lookup("force-architectures").Value.Get().(bool)
+	repo.UsePDiffs = context.Flags().Lookup("pdiffs").Value.Get().(bool)
+	repo.AptlyAPI = context.Flags().Lookup("aptly-api").Value.String()
+	repo.AptlyPrefix = context.Flags().Lookup("aptly-prefix").Value.String()
+	if alternateURLs := context.Flags().Lookup("alternate-urls").Value.String(); alternateURLs != "" {
+		repo.AlternateURLs = strings.Split(alternateURLs, ",")
+	}
+	repo.KeepVersions = context.Flags().Lookup("keep-versions").Value.Get().(int)
+	context.Flags().Visit(func(flag *flag.Flag) {
+		applyMirrorAccessFlag(repo, flag)
+		applyMirrorSectionFlag(repo, flag)
+		applyMirrorVerificationFlag(repo, flag)
+	})
+
+	if repo.MinPriority != "" {
+		err = deb.ValidatePriority(repo.MinPriority)
+		if err != nil {
+			return fmt.Errorf("unable to create mirror: %s", err)
+		}
+	}
+
+	err = deb.ValidateChecksumPolicy(repo.ChecksumPolicy)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	if repo.KeepVersions < 0 {
+		return fmt.Errorf("unable to create mirror: number of versions to keep should be non-negative")
+	}
 
+	collectionFactory := context.NewCollectionFactory()
 	if repo.Filter != "" {
-		_, err = query.Parse(repo.Filter)
+		_, err = query.ParseWithPackageSets(repo.Filter, collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to create mirror: %s", err)
 		}
 	}
 
-	verifier, err := getVerifier(context.Flags())
+	verifier, err := getVerifier(context.Flags(), nil)
 	if err != nil {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+	downloader, err := getRemoteDownloader(repo)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	err = repo.Fetch(downloader, verifier, ignoreSignatures)
+	if err != nil {
+		return fmt.Errorf("unable to fetch mirror: %s", err)
+	}
+
+	err = repo.FetchUpstreamSources(downloader)
 	if err != nil {
 		return fmt.Errorf("unable to fetch mirror: %s", err)
 	}
 
-	collectionFactory := context.NewCollectionFactory()
 	err = collectionFactory.RemoteRepoCollection().Add(repo)
 	if err != nil {
 		return fmt.Errorf("unable to add mirror: %s", err)
@@ -92,9 +140,67 @@ PPA urls could specified in short format:
 
   $ aptly mirror create <name> ppa:<user>/<project>
 
+Repositories behind proxy or requiring authentication could be accessed with -proxy,
+-username, -password-file (or -password-env) and -tls-client-cert/-tls-client-key flags,
+these settings are stored with the mirror and used on each update. Password itself is
+never stored in aptly database: it is read from the file (or environment variable) on
+each access.
+
+Repository published by another aptly could be mirrored with awareness of the snapshots
+it was published from: -aptly-api specifies URL of the upstream aptly API and -aptly-prefix
+the publishing prefix ([<storage>:]<prefix>) there. Names of upstream snapshots are refreshed
+on each update and could be used as {upstream} in names of snapshots created from the mirror.
+
+Archive url could be a list of mirrors in apt-transport-mirror format (one mirror URL per line),
+prefixed with mirror+, e.g. mirror+file:///etc/aptly/debian.list or mirror+http://example.com/mirrors.txt;
+additional mirrors could be listed with -alternate-urls. On each fetch, mirrors are probed and the fastest
+one is used, downloads failed on one mirror are retried on the others. List of mirrors is re-read on each update.
+
+Mirror could be limited to packages from some sections with -include-sections, or packages
+from some sections could be skipped with -exclude-sections (area prefix like contrib/ could be
+omitted); with -min-priority, packages with lower priority (required > important > standard >
+optional > extra) are skipped. Limits are applied when parsing package indexes, so skipped
+packages are neither tracked nor downloaded.
+
+With -with-installer, debian-installer images (installer-<arch>/current/images tree
+listed in SHA256SUMS, including netboot images) are mirrored as well and published along
+with SHA256SUMS (signed as SHA256SUMS.gpg), so that published mirror could be used for
+PXE/netboot provisioning. Installer images are available as package 'installer' for each
+architecture.
+
+With -snapshot-time, mirror follows state of the archive on snapshot.debian.org as of
+specified date or time (e.g. 2023-06-01 or 2023-06-01T12:00:00Z), which is handy to
+reproduce historical builds. Archive could be given as snapshot:<archive> (e.g.
+snapshot:debian, snapshot:debian-security) or as URL of regular mirror of the archive.
+Number of parallel downloads is limited to be gentle with the service; snapshot time
+could be changed later with aptly mirror edit.
+
+With -pdiffs, copies of package indexes are kept between updates and brought up to date
+with pdiffs (Packages.diff/Index) if remote repository provides them, falling back to
+full download when the patch chain is broken.
+
+Files downloaded for the mirror are verified against all the checksums listed in Release
+file and package indexes. With -checksum-policy=strict, SHA256 or SHA512 checksum is required
+for every file (files listed only with MD5 or SHA1 fail verification); with
+-checksum-policy=permissive, only the strongest checksum listed is verified, weaker
+checksum mismatches and missing SHA256/SHA512 checksums are reported as warnings.
+With -quarantine, package files failing verification are moved to quarantine directory
+(<rootDir>/quarantine/<mirror uuid>) and packages they belong to are skipped, instead of
+aborting the whole update.
+
+With -keep-versions=N, mirror keeps N latest versions of every package on update,
+even if upstream no longer lists superseded versions, so that rollbacks remain
+possible; versions are retained only for packages upstream still lists.
+
 Example:
 
   $ aptly mirror create wheezy-main http://mirror.yandex.ru/debian/ wheezy main
+
+  $ aptly mirror create debian-main mirror+file:///etc/aptly/debian.list bookworm main
+
+  $ aptly mirror create -snapshot-time=2023-06-01 bookworm-20230601 snapshot:debian bookworm main
+
+  $ aptly mirror create -aptly-api=http://aptly.example.com:8080 edge-stable http://repo.example.com/ stable main
 `,
 		Flag: *flag.NewFlagSet("aptly-mirror-create", flag.ExitOnError),
 	}
@@ -107,8 +213,17 @@ Example:
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("force-components", false, "(only with component list) skip check that requested components are listed in Release file")
 	cmd.Flag.Bool("force-architectures", false, "(only with architecture list) skip check that requested architectures are listed in Release file")
+	cmd.Flag.Bool("pdiffs", false, "update package indexes with pdiffs (Packages.diff) when available")
+	cmd.Flag.String("snapshot-time", "", "mirror state of the archive on snapshot.debian.org as of this date or time")
+	cmd.Flag.String("aptly-api", "", "URL of upstream aptly API, if mirroring repository published by another aptly")
+	cmd.Flag.String("aptly-prefix", "", "publishing prefix ([<storage>:]<prefix>) of the repository on upstream aptly")
+	cmd.Flag.String("alternate-urls", "", "comma-separated list of other mirrors of the archive to fail over to")
+	cmd.Flag.Int("keep-versions", 0, "number of latest versions of every package to keep in the mirror, even if upstream no longer lists them")
 	cmd.Flag.Int("max-tries", 1, "max download tries till process fails with download error")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	addMirrorAccessFlags(cmd)
+	addMirrorSectionFlags(cmd)
+	addMirrorVerificationFlags(cmd)
 
 	return cmd
 }
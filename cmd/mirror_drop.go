@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -46,6 +48,21 @@ func aptlyMirrorDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
+	err = os.RemoveAll(context.IndexCachePath(repo))
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
+	err = os.RemoveAll(context.PartialDownloadPath(repo))
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
+	err = context.KeyStore().Drop(pgp.MirrorKeyNamespace(repo.UUID))
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
 	fmt.Printf("Mirror `%s` has been removed.\n", repo.Name)
 
 	return err
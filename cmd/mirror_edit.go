@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
@@ -41,20 +44,70 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			repo.DownloadSources = flag.Value.Get().(bool)
 		case "with-udebs":
 			repo.DownloadUdebs = flag.Value.Get().(bool)
+		case "pdiffs":
+			repo.UsePDiffs = flag.Value.Get().(bool)
 		case "archive-url":
 			repo.SetArchiveRoot(flag.Value.String())
+			repo.ClearDebianSnapshot()
+			fetchMirror = true
+		case "alternate-urls":
+			repo.AlternateURLs = nil
+			if flag.Value.String() != "" {
+				repo.AlternateURLs = strings.Split(flag.Value.String(), ",")
+			}
+			fetchMirror = true
+		case "aptly-api":
+			repo.AptlyAPI = flag.Value.String()
+			repo.UpstreamSourceKind, repo.UpstreamSources = "", nil
+			fetchMirror = true
+		case "aptly-prefix":
+			repo.AptlyPrefix = flag.Value.String()
 			fetchMirror = true
 		case "ignore-signatures":
 			ignoreSignatures = true
+		case "keep-versions":
+			repo.KeepVersions = flag.Value.Get().(int)
+		default:
+			applyMirrorAccessFlag(repo, flag)
+			applyMirrorSectionFlag(repo, flag)
+			applyMirrorVerificationFlag(repo, flag)
 		}
 	})
 
+	if context.Flags().IsSet("snapshot-time") {
+		err = repo.ApplyDebianSnapshotTime(context.Flags().Lookup("snapshot-time").Value.String())
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+		fetchMirror = true
+	}
+
+	if repo.MinPriority != "" {
+		err = deb.ValidatePriority(repo.MinPriority)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+	}
+
+	err = deb.ValidateChecksumPolicy(repo.ChecksumPolicy)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	if repo.KeepVersions < 0 {
+		return fmt.Errorf("unable to edit: number of versions to keep should be non-negative")
+	}
+
 	if repo.IsFlat() && repo.DownloadUdebs {
 		return fmt.Errorf("unable to edit: flat mirrors don't support udebs")
 	}
 
+	if repo.IsFlat() && repo.DownloadInstaller {
+		return fmt.Errorf("unable to edit: flat mirrors don't support installer images")
+	}
+
 	if repo.Filter != "" {
-		_, err = query.Parse(repo.Filter)
+		_, err = query.ParseWithPackageSets(repo.Filter, collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to edit: %s", err)
 		}
@@ -67,12 +120,23 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 
 	if fetchMirror {
 		var verifier pgp.Verifier
-		verifier, err = getVerifier(context.Flags())
+		verifier, err = getVerifier(context.Flags(), repo)
 		if err != nil {
 			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 		}
 
-		err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+		var downloader aptly.Downloader
+		downloader, err = getRemoteDownloader(repo)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+
+		err = repo.Fetch(downloader, verifier, ignoreSignatures)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+
+		err = repo.FetchUpstreamSources(downloader)
 		if err != nil {
 			return fmt.Errorf("unable to edit: %s", err)
 		}
@@ -94,7 +158,8 @@ func makeCmdMirrorEdit() *commander.Command {
 		Short:     "edit mirror settings",
 		Long: `
 Command edit allows one to change settings of mirror:
-filters, list of architectures.
+filters, section and priority limits, list of architectures, proxy and credentials,
+upstream aptly.
 
 Example:
 
@@ -104,13 +169,22 @@ Example:
 	}
 
 	cmd.Flag.String("archive-url", "", "archive url is the root of archive")
+	cmd.Flag.String("alternate-urls", "", "comma-separated list of other mirrors of the archive to fail over to (empty to disable)")
+	cmd.Flag.String("aptly-api", "", "URL of upstream aptly API, if mirroring repository published by another aptly (empty to disable)")
+	cmd.Flag.String("aptly-prefix", "", "publishing prefix ([<storage>:]<prefix>) of the repository on upstream aptly")
 	cmd.Flag.String("filter", "", "filter packages in mirror")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
 	cmd.Flag.Bool("with-udebs", false, "download .udeb packages (Debian installer support)")
+	cmd.Flag.Bool("pdiffs", false, "update package indexes with pdiffs (Packages.diff) when available")
+	cmd.Flag.String("snapshot-time", "", "mirror state of the archive on snapshot.debian.org as of this date or time")
+	cmd.Flag.Int("keep-versions", 0, "number of latest versions of every package to keep in the mirror, even if upstream no longer lists them")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	addMirrorAccessFlags(cmd)
+	addMirrorSectionFlags(cmd)
+	addMirrorVerificationFlags(cmd)
 
 	return cmd
 }
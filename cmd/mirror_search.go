@@ -24,6 +24,7 @@ Example:
 
 	cmd.Flag.Bool("with-deps", false, "include dependencies into search results")
 	cmd.Flag.String("format", "", "custom format for result printing")
+	cmd.Flag.Bool("json", false, "display list in JSON format")
 
 	return cmd
 }
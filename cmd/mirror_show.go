@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/utils"
@@ -48,6 +49,12 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Status: In Update (PID %d)\n", repo.WorkerPID)
 	}
 	fmt.Printf("Archive Root URL: %s\n", repo.ArchiveRoot)
+	if repo.IsDebianSnapshot() {
+		fmt.Printf("snapshot.debian.org: %s as of %s\n", repo.DebianSnapshotArchive, repo.DebianSnapshotTime.Format(time.RFC3339))
+	}
+	if len(repo.AlternateURLs) > 0 {
+		fmt.Printf("Alternate URLs: %s\n", strings.Join(repo.AlternateURLs, ", "))
+	}
 	fmt.Printf("Distribution: %s\n", repo.Distribution)
 	fmt.Printf("Components: %s\n", strings.Join(repo.Components, ", "))
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, ", "))
@@ -61,6 +68,9 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		downloadUdebs = Yes
 	}
 	fmt.Printf("Download .udebs: %s\n", downloadUdebs)
+	if repo.DownloadInstaller {
+		fmt.Printf("Download Installer: %s\n", Yes)
+	}
 	if repo.Filter != "" {
 		fmt.Printf("Filter: %s\n", repo.Filter)
 		filterWithDeps := No
@@ -69,6 +79,47 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		}
 		fmt.Printf("Filter With Deps: %s\n", filterWithDeps)
 	}
+	if len(repo.IncludeSections) > 0 {
+		fmt.Printf("Include Sections: %s\n", strings.Join(repo.IncludeSections, ", "))
+	}
+	if len(repo.ExcludeSections) > 0 {
+		fmt.Printf("Exclude Sections: %s\n", strings.Join(repo.ExcludeSections, ", "))
+	}
+	if repo.MinPriority != "" {
+		fmt.Printf("Minimum Priority: %s\n", repo.MinPriority)
+	}
+	if repo.ChecksumPolicy != "" {
+		fmt.Printf("Checksum Policy: %s\n", repo.ChecksumPolicy)
+	}
+	if repo.Quarantine {
+		fmt.Printf("Quarantine: yes\n")
+	}
+	if repo.KeepVersions > 1 {
+		fmt.Printf("Keep Versions: %d\n", repo.KeepVersions)
+	}
+	if repo.UsePDiffs {
+		fmt.Printf("Use PDiffs: %s\n", Yes)
+	}
+	if repo.IsAptlyUpstream() {
+		fmt.Printf("Upstream aptly: %s (prefix %s)\n", repo.AptlyAPI, repo.AptlyPrefix)
+		if len(repo.UpstreamSources) > 0 {
+			fmt.Printf("Upstream sources (%s): %s\n", repo.UpstreamSourceKind, repo.UpstreamSourcesString())
+		}
+	}
+	if repo.Proxy != "" {
+		fmt.Printf("Proxy: %s\n", repo.Proxy)
+	}
+	if repo.Username != "" {
+		fmt.Printf("Username: %s\n", repo.Username)
+	}
+	if repo.PasswordFile != "" {
+		fmt.Printf("Password file: %s\n", repo.PasswordFile)
+	} else if repo.PasswordEnv != "" {
+		fmt.Printf("Password environment variable: %s\n", repo.PasswordEnv)
+	}
+	if repo.TLSClientCert != "" {
+		fmt.Printf("TLS Client Certificate: %s\n", repo.TLSClientCert)
+	}
 	if repo.LastDownloadDate.IsZero() {
 		fmt.Printf("Last update: never\n")
 	} else {
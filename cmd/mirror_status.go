@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// mirrorStaleAfter returns staleness window for mirrors, flag overrides configuration
+func mirrorStaleAfter(flags *flag.FlagSet) time.Duration {
+	if flags.IsSet("stale-after") {
+		return flags.Lookup("stale-after").Value.Get().(time.Duration)
+	}
+
+	return time.Duration(context.Config().MirrorStaleAfter) * time.Second
+}
+
+func aptlyMirrorStatus(cmd *commander.Command, args []string) error {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.RemoteRepoCollection()
+
+	var repos []*deb.RemoteRepo
+
+	if len(args) > 0 {
+		for _, name := range args {
+			repo, err := collection.ByName(name)
+			if err != nil {
+				return fmt.Errorf("unable to show status: %s", err)
+			}
+			repos = append(repos, repo)
+		}
+	} else {
+		_ = collection.ForEach(func(repo *deb.RemoteRepo) error {
+			repos = append(repos, repo)
+			return nil
+		})
+
+		sort.Slice(repos, func(i, j int) bool {
+			return repos[i].Name < repos[j].Name
+		})
+	}
+
+	context.CloseDatabase()
+
+	now := time.Now()
+	staleAfter := mirrorStaleAfter(context.Flags())
+
+	healths := make([]deb.MirrorHealth, len(repos))
+	stale := 0
+	for i, repo := range repos {
+		healths[i] = repo.Health(now, staleAfter)
+		if healths[i].Stale {
+			stale++
+		}
+	}
+
+	if cmd.Flag.Lookup("json").Value.Get().(bool) {
+		output, err := json.MarshalIndent(healths, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	} else {
+		formatTime := func(t time.Time) string {
+			if t.IsZero() {
+				return "never"
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		for i, health := range healths {
+			if i > 0 {
+				fmt.Printf("\n")
+			}
+
+			if health.Stale {
+				fmt.Printf("%s: STALE (%s)\n", health.Name, health.Reason)
+			} else {
+				fmt.Printf("%s: OK\n", health.Name)
+			}
+
+			fmt.Printf("  Last update: %s\n", formatTime(health.LastUpdate))
+			if !health.ReleaseDate.IsZero() {
+				fmt.Printf("  Upstream Release date: %s\n", formatTime(health.ReleaseDate))
+			}
+			if !health.ValidUntil.IsZero() {
+				fmt.Printf("  Upstream Release valid until: %s\n", formatTime(health.ValidUntil))
+			}
+			for _, update := range health.Updates {
+				fmt.Printf("  Updated at %s: +%d -%d packages, %d total\n", formatTime(update.Time), update.Added, update.Removed, update.Total)
+			}
+		}
+	}
+
+	if stale > 0 {
+		return fmt.Errorf("%d of %d mirrors are stale", stale, len(healths))
+	}
+
+	return nil
+}
+
+func makeCmdMirrorStatus() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyMirrorStatus,
+		UsageLine: "status [<name> ...]",
+		Short:     "report health of mirrors",
+		Long: `
+Command status reports health of mirrors (all the mirrors, if none are given): time
+of last successful update, Date and Valid-Until of upstream Release file and number
+of packages added and removed by latest updates.
+
+Mirror is reported as stale if it has never been updated, if it hasn't been updated
+successfully within -stale-after (defaults to mirrorStaleAfter from configuration)
+or if upstream Release file has expired. Command fails if any of the mirrors is
+stale, so it could be used in monitoring checks.
+
+Example:
+
+  $ aptly mirror status -stale-after=48h wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-mirror-status", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display status in JSON format")
+	cmd.Flag.Duration("stale-after", 0, "report mirrors which haven't been updated successfully for that long as stale (e.g. 48h)")
+
+	return cmd
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -47,38 +49,96 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		ignoreSignatures = context.Flags().Lookup("ignore-signatures").Value.Get().(bool)
 	}
 	ignoreChecksums := context.Flags().Lookup("ignore-checksums").Value.Get().(bool)
+	if ignoreChecksums && repo.ChecksumPolicy == deb.ChecksumPolicyStrict {
+		return fmt.Errorf("unable to update: checksums can't be ignored for mirror with strict checksum policy")
+	}
 
-	verifier, err := getVerifier(context.Flags())
+	verifier, err := getVerifier(context.Flags(), repo)
 	if err != nil {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+	var filterQuery deb.PackageQuery
+	if repo.Filter != "" {
+		filterQuery, err = query.ParseWithPackageSets(repo.Filter, collectionFactory.PackageSetCollection())
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+	}
+
+	forceIndexes := context.Flags().Lookup("force-indexes").Value.Get().(bool)
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+	downloadBudget := context.Flags().Lookup("download-budget").Value.Get().(int64) * 1024 * 1024
+
+	downloader, err := getRemoteDownloader(repo)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
-	context.Progress().Printf("Downloading & parsing package files...\n")
-	err = repo.DownloadPackageIndexes(context.Progress(), context.Downloader(), verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
+	var releaseModified bool
+	releaseModified, err = repo.FetchIfModified(downloader, verifier, ignoreSignatures)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
-	if repo.Filter != "" {
-		context.Progress().Printf("Applying filter...\n")
-		var filterQuery deb.PackageQuery
+	err = repo.FetchUpstreamSources(downloader)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	// package sets used in filter might have been changed since last update
+	if !forceIndexes && (filterQuery == nil || len(query.PackageSetNames(filterQuery)) == 0) && repo.IndexesUnchanged() {
+		if releaseModified {
+			context.Progress().Printf("Package indexes haven't changed since last update.\n")
+		} else {
+			context.Progress().Printf("Release file hasn't changed since last update.\n")
+		}
+
+		if dryRun {
+			context.Progress().Printf("\nMirror `%s` is up to date, nothing would be downloaded.\n", repo.Name)
+			return nil
+		}
 
-		filterQuery, err = query.Parse(repo.Filter)
+		repo.MarkAsChecked()
+		err = collectionFactory.RemoteRepoCollection().Update(repo)
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
 		}
 
-		var oldLen, newLen int
-		oldLen, newLen, err = repo.ApplyFilter(context.DependencyOptions(), filterQuery, context.Progress())
+		context.Progress().Printf("\nMirror `%s` is up to date, use -force-indexes to update it anyway.\n", repo.Name)
+		return nil
+	}
+
+	// previous update might have been interrupted while downloading package files
+	resumed := false
+	if !forceIndexes && (filterQuery == nil || len(query.PackageSetNames(filterQuery)) == 0) {
+		resumed, err = repo.ResumeDownload(collectionFactory, context.Progress())
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
 		}
-		context.Progress().Printf("Packages filtered: %d -> %d.\n", oldLen, newLen)
+	}
+
+	if resumed {
+		context.Progress().Printf("Resuming interrupted update, package indexes haven't changed since then...\n")
+	} else {
+		repo.SetIndexCache(context.IndexCachePath(repo))
+
+		context.Progress().Printf("Downloading & parsing package files...\n")
+		err = repo.DownloadPackageIndexes(context.Progress(), context.IndexCache().Downloader(downloader), verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+
+		if filterQuery != nil {
+			context.Progress().Printf("Applying filter...\n")
+
+			var oldLen, newLen int
+			oldLen, newLen, err = repo.ApplyFilter(context.DependencyOptions(), filterQuery, context.Progress())
+			if err != nil {
+				return fmt.Errorf("unable to update: %s", err)
+			}
+			context.Progress().Printf("Packages filtered: %d -> %d.\n", oldLen, newLen)
+		}
 	}
 
 	var (
@@ -96,6 +156,34 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	if dryRun {
+		var report *deb.UpdateReport
+		report, err = repo.BuildUpdateReport(queue, collectionFactory.PackageCollection())
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+
+		printPackages := func(title, mark string, packages []string) {
+			context.Progress().Printf("%s: %d\n", title, len(packages))
+			for _, p := range packages {
+				context.Progress().Printf("  %s %s\n", mark, p)
+			}
+		}
+
+		printPackages("Packages to be added", "+", report.Added)
+		printPackages("Packages to be removed", "-", report.Removed)
+		printPackages("Packages to be re-downloaded", "*", report.Redownloaded)
+
+		context.Progress().Printf("Download queue: %d items (%s)\n", len(queue), utils.HumanBytes(downloadSize))
+		context.Progress().Printf("\nDry run: mirror `%s` hasn't been updated, nothing has been downloaded.\n", repo.Name)
+		return nil
+	}
+
+	if downloadBudget > 0 && downloadSize > downloadBudget {
+		return fmt.Errorf("unable to update: download queue of %d items (%s) exceeds download budget of %s",
+			len(queue), utils.HumanBytes(downloadSize), utils.HumanBytes(downloadBudget))
+	}
+
 	defer func() {
 		// on any interruption, unlock the mirror
 		err = context.ReOpenDatabase()
@@ -111,6 +199,12 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	// save list of packages, so that interrupted update could be resumed without parsing indexes again
+	err = repo.SaveResumeState(collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	err = context.CloseDatabase()
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
@@ -118,6 +212,11 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 
 	context.GoContextHandleSignals()
 
+	// package files are downloaded to stable location, so that files downloaded before
+	// interruption are picked up by next update
+	partialPath := context.PartialDownloadPath(repo)
+	quarantinePath := context.QuarantinePath(repo)
+
 	count := len(queue)
 	context.Progress().Printf("Download queue: %d items (%s)\n", count, utils.HumanBytes(downloadSize))
 
@@ -150,7 +249,7 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 
 	var wg sync.WaitGroup
 
-	for i := 0; i < context.Config().DownloadConcurrency; i++ {
+	for i := 0; i < repo.DownloadConcurrency(context.Config().DownloadConcurrency); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -165,34 +264,26 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 
 					var e error
 
-					// provision download location
-					if pp, ok := context.PackagePool().(aptly.LocalPackagePool); ok {
-						task.TempDownPath, e = pp.GenerateTempPath(task.File.Filename)
-					} else {
-						var file *os.File
-						file, e = os.CreateTemp("", task.File.Filename)
-						if e == nil {
-							task.TempDownPath = file.Name()
-							file.Close()
-						}
-					}
-					if e != nil {
-						pushError(e)
+					task.TempDownPath = filepath.Join(partialPath, partialDownloadName(task.File))
+
+					// file might have been downloaded by interrupted update
+					if partialDownloadComplete(task.TempDownPath, &task.File.Checksums) {
+						context.Progress().AddBar(int(task.File.Checksums.Size))
+						task.Done = true
 						continue
 					}
 
 					// download file...
-					e = context.Downloader().DownloadWithChecksum(
-						context,
-						repo.PackageURL(task.File.DownloadURL()).String(),
-						task.TempDownPath,
-						&task.File.Checksums,
-						ignoreChecksums)
+					e = repo.DownloadPackageFile(context, downloader, task, ignoreChecksums, quarantinePath, context.Progress())
 					if e != nil {
 						pushError(e)
 						continue
 					}
 
+					if task.Quarantined {
+						continue
+					}
+
 					task.Done = true
 				case <-context.Done():
 					return
@@ -211,18 +302,6 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
-	defer func() {
-		for _, task := range queue {
-			if task.TempDownPath == "" {
-				continue
-			}
-
-			if err := os.Remove(task.TempDownPath); err != nil && !os.IsNotExist(err) {
-				fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", task.TempDownPath, err)
-			}
-		}
-	}()
-
 	// Import downloaded files
 	context.Progress().InitBar(int64(len(queue)), false, aptly.BarMirrorUpdateImportFiles)
 
@@ -261,16 +340,59 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
 	}
 
+	if skipped := repo.SkipQuarantined(queue); len(skipped) > 0 {
+		context.Progress().ColoredPrintf("@y[!]@| @!%d packages skipped, as their files failed verification (quarantined in %s):@|", len(skipped), quarantinePath)
+		for _, p := range skipped {
+			context.Progress().Printf("  %s\n", p)
+		}
+	}
+
 	repo.FinalizeDownload(collectionFactory, context.Progress())
 	err = collectionFactory.RemoteRepoCollection().Update(repo)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	if err = os.RemoveAll(partialPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", partialPath, err)
+	}
+
+	context.Notify(webhook.EventMirrorUpdated, map[string]interface{}{"mirror": repo}, nil)
+
 	context.Progress().Printf("\nMirror `%s` has been successfully updated.\n", repo.Name)
 	return err
 }
 
+// partialDownloadName is name of the file in partial downloads directory, which is
+// unique for file contents
+func partialDownloadName(file *deb.PackageFile) string {
+	checksum := file.Checksums.SHA256
+	if checksum == "" {
+		checksum = file.Checksums.MD5
+	}
+
+	return checksum + "_" + file.Filename
+}
+
+// partialDownloadComplete checks whether file has been completely downloaded before
+func partialDownloadComplete(path string, expected *utils.ChecksumInfo) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != expected.Size {
+		return false
+	}
+
+	actual, err := utils.ChecksumsForFile(path)
+	if err != nil {
+		return false
+	}
+
+	if expected.SHA256 != "" {
+		return actual.SHA256 == expected.SHA256
+	}
+
+	return expected.MD5 != "" && actual.MD5 == expected.MD5
+}
+
 func makeCmdMirrorUpdate() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyMirrorUpdate,
@@ -279,7 +401,29 @@ func makeCmdMirrorUpdate() *commander.Command {
 		Long: `
 Updates remote mirror (downloads package files and meta information). When mirror is created,
 this command should be run for the first time to fetch mirror contents. This command can be
-run multiple times to get updated repository contents. If interrupted, command can be safely restarted.
+run multiple times to get updated repository contents. If interrupted, command can be safely restarted:
+package files which have already been downloaded and verified are not downloaded again, and
+if package indexes haven't changed since then, they are not parsed again.
+
+If Release file (checked with conditional HTTP request) and package indexes haven't changed since
+last successful update, update is skipped, unless -force-indexes is specified. As update is skipped
+completely, package files are not verified either: package files missing from the package pool
+(e.g. removed by hand or lost with the storage) are downloaded again only with -force-indexes.
+
+Package indexes are cached in the aptly root directory by their SHA256 checksum and
+the cache is shared by all the mirrors, so mirrors of the same archive (e.g. with
+different filters) download identical package indexes only once.
+
+With -dry-run, package indexes are downloaded and aptly reports packages which would
+be added to the mirror, removed from the mirror or re-downloaded (as their files are missing
+from the package pool) along with the size of the download queue, but no package files are
+downloaded and neither the package pool nor the database are modified. Flag
+-download-budget aborts the update before downloading if the download queue is larger
+than the budget.
+
+Download speed could be capped with -download-rate (bytes/sec), e.g. to keep the link
+usable for others while mirror is updated, or with -download-limit (kbytes/sec).
+-download-rate takes precedence if both are set.
 
 Example:
 
@@ -291,8 +435,12 @@ Example:
 	cmd.Flag.Bool("force", false, "force update mirror even if it is locked by another process")
 	cmd.Flag.Bool("ignore-checksums", false, "ignore checksum mismatches while downloading package files and metadata")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
+	cmd.Flag.Bool("force-indexes", false, "download and parse package indexes and verify package files even if indexes haven't changed since last update")
 	cmd.Flag.Bool("skip-existing-packages", false, "do not check file existence for packages listed in the internal database of the mirror")
 	cmd.Flag.Int64("download-limit", 0, "limit download speed (kbytes/sec)")
+	cmd.Flag.Int64("download-rate", 0, "limit download speed (bytes/sec), takes precedence over -download-limit")
+	cmd.Flag.Int64("download-budget", 0, "abort update if package files to download exceed this size (MiB), 0 means no limit")
+	cmd.Flag.Bool("dry-run", false, "report changes and size of download queue without downloading package files or updating the mirror")
 	cmd.Flag.String("downloader", "default", "downloader to use (e.g. grab)")
 	cmd.Flag.Int("max-tries", 1, "max download tries till process fails with download error")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
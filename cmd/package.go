@@ -11,6 +11,8 @@ func makeCmdPackage() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdPackageSearch(),
 			makeCmdPackageShow(),
+			makeCmdPackageChangelog(),
+			makeCmdPackageSet(),
 		},
 	}
 }
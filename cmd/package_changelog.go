@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPackageChangelog(cmd *commander.Command, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	q, err := query.ParseWithPackageSets(args[0], collectionFactory.PackageSetCollection())
+	if err != nil {
+		return fmt.Errorf("unable to show changelog: %s", err)
+	}
+
+	since := context.Flags().Lookup("since").Value.String()
+
+	var published *deb.PublishedRepo
+	if len(args) > 1 {
+		if since != "" {
+			return fmt.Errorf("unable to show changelog: -since can't be used together with published repository")
+		}
+
+		param := "."
+		if len(args) == 3 {
+			param = args[2]
+		}
+		storage, prefix := deb.ParsePrefix(param)
+
+		published, err = collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, args[1])
+		if err != nil {
+			return fmt.Errorf("unable to show changelog: %s", err)
+		}
+
+		err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+		if err != nil {
+			return fmt.Errorf("unable to show changelog: %s", err)
+		}
+	}
+
+	result := q.Query(collectionFactory.PackageCollection())
+	result.PrepareIndex()
+
+	err = result.ForEachIndexed(func(p *deb.Package) error {
+		if p.IsSource {
+			return nil
+		}
+
+		changelog, e := p.Changelog(context.PackagePool())
+		if e != nil {
+			return e
+		}
+
+		packageSince := since
+		if published != nil {
+			packageSince = deb.PublishedVersion(published, p.Name, p.Architecture)
+		}
+
+		if packageSince != "" {
+			context.Progress().ColoredPrintf("@{w!}Changes in %s since %s:@|", p, packageSince)
+		} else {
+			context.Progress().ColoredPrintf("@{w!}Changes in %s:@|", p)
+		}
+
+		entries := deb.ChangelogEntriesSince(deb.ParseChangelog(changelog.Changelog), packageSince)
+		if len(entries) == 0 {
+			context.Progress().Printf("  no changelog entries\n")
+		}
+		for _, entry := range entries {
+			context.Progress().Printf("\n%s", entry.Text)
+		}
+
+		news := deb.ChangelogEntriesSince(deb.ParseChangelog(changelog.News), packageSince)
+		if len(news) > 0 {
+			context.Progress().ColoredPrintf("\n@{w!}News in %s:@|", p)
+			for _, entry := range news {
+				context.Progress().Printf("\n%s", entry.Text)
+			}
+		}
+
+		context.Progress().Printf("\n")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to show changelog: %s", err)
+	}
+
+	return nil
+}
+
+func makeCmdPackageChangelog() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageChangelog,
+		UsageLine: "changelog <package-query> [<distribution> [[<endpoint>:]<prefix>]]",
+		Short:     "show changelog of packages",
+		Long: `
+Command changelog shows Debian changelog (and NEWS file, if any) of binary packages
+matching package query. Changelog is extracted from package file when package is
+imported, or on first request for mirrored packages.
+
+With -since, only changes for versions newer than specified version are shown.
+If published repository is given (by distribution and optional prefix), only changes
+since version of the package currently published there are shown, which is handy to
+review candidate version before publishing it.
+
+Example:
+
+  $ aptly package changelog 'nginx (= 1.24.0-2)' bookworm
+`,
+		Flag: *flag.NewFlagSet("aptly-package-changelog", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("since", "", "show only changes for versions newer than this version")
+
+	return cmd
+}
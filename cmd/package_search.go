@@ -20,8 +20,9 @@ func aptlyPackageSearch(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	collectionFactory := context.NewCollectionFactory()
 	if len(args) == 1 {
-		q, err = query.Parse(args[0])
+		q, err = query.ParseWithPackageSets(args[0], collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to search: %s", err)
 		}
@@ -29,12 +30,15 @@ func aptlyPackageSearch(cmd *commander.Command, args []string) error {
 		q = &deb.MatchAllQuery{}
 	}
 
-	collectionFactory := context.NewCollectionFactory()
 	result := q.Query(collectionFactory.PackageCollection())
 	if result.Len() == 0 {
 		return fmt.Errorf("no results")
 	}
 
+	if context.Flags().Lookup("json").Value.Get().(bool) {
+		return PrintPackageListJSON(result)
+	}
+
 	format := context.Flags().Lookup("format").Value.String()
 	PrintPackageList(result, format, "")
 
@@ -59,6 +63,7 @@ Example:
 	}
 
 	cmd.Flag.String("format", "", "custom format for result printing")
+	cmd.Flag.Bool("json", false, "display list in JSON format")
 
 	return cmd
 }
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/smira/commander"
+)
+
+func makeCmdPackageSet() *commander.Command {
+	return &commander.Command{
+		UsageLine: "set",
+		Short:     "manage named package sets",
+		Subcommands: []*commander.Command{
+			makeCmdPackageSetCreate(),
+			makeCmdPackageSetDrop(),
+			makeCmdPackageSetEdit(),
+			makeCmdPackageSetList(),
+			makeCmdPackageSetShow(),
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPackageSetCreate(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	for _, arg := range args[1:] {
+		_, err = query.ParseWithPackageSets(arg, collectionFactory.PackageSetCollection())
+		if err != nil {
+			return fmt.Errorf("unable to create package set: %s", err)
+		}
+	}
+
+	set := deb.NewPackageSet(args[0], context.Flags().Lookup("comment").Value.String(), args[1:])
+
+	err = collectionFactory.PackageSetCollection().Add(set)
+	if err != nil {
+		return fmt.Errorf("unable to add package set: %s", err)
+	}
+
+	fmt.Printf("\nPackage set %s successfully added.\nYou can use it in package queries as '$PackageSet (%s)'.\n", set, set.Name)
+	return err
+}
+
+func makeCmdPackageSetCreate() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageSetCreate,
+		UsageLine: "create <name> <package-query> ...",
+		Short:     "create named package set",
+		Long: `
+Create named package set out of one or more package queries. Package
+matches the package set if it matches any of the queries.
+
+Package set could be referenced from any package query (filters,
+pulls, copies, searches, ...) as '$PackageSet (<name>)'.
+
+Example:
+
+  $ aptly package set create base-runtime libc6 'Name (% libssl*)'
+
+  $ aptly repo copy -with-deps stage prod '$PackageSet (base-runtime)'
+`,
+		Flag: *flag.NewFlagSet("aptly-package-set-create", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("comment", "", "any text that would be used to describe package set")
+
+	return cmd
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPackageSetDrop(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	set, err := collectionFactory.PackageSetCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
+	force := context.Flags().Lookup("force").Value.Get().(bool)
+	if !force {
+		references := query.PackageSetReferences(set.Name, collectionFactory)
+		if len(references) > 0 {
+			fmt.Printf("Package set `%s` is referenced by:\n", set.Name)
+			for _, reference := range references {
+				fmt.Printf(" * %s\n", reference)
+			}
+
+			return fmt.Errorf("won't delete package set which is referenced, use -force to override")
+		}
+	}
+
+	err = collectionFactory.PackageSetCollection().Drop(set)
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
+	fmt.Printf("Package set `%s` has been removed.\n", set.Name)
+
+	return err
+}
+
+func makeCmdPackageSetDrop() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageSetDrop,
+		UsageLine: "drop <name>",
+		Short:     "delete package set",
+		Long: `
+Drop deletes named package set. Package set can't be dropped while
+it is referenced by other package sets or mirror filters, unless
+-force is used.
+
+Example:
+
+  $ aptly package set drop base-runtime
+`,
+		Flag: *flag.NewFlagSet("aptly-package-set-drop", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("force", false, "force package set deletion even if it is referenced")
+
+	return cmd
+}
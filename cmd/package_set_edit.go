@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPackageSetEdit(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	set, err := collectionFactory.PackageSetCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	if len(args) > 1 {
+		oldQueries := set.Queries
+		set.Queries = args[1:]
+
+		_, err = query.ParseWithPackageSets(fmt.Sprintf("$PackageSet (%s)", set.Name), collectionFactory.PackageSetCollection())
+		if err != nil {
+			set.Queries = oldQueries
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+	}
+
+	context.Flags().Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "comment":
+			set.Comment = flag.Value.String()
+		}
+	})
+
+	err = collectionFactory.PackageSetCollection().Update(set)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	fmt.Printf("Package set %s successfully updated.\n", set)
+	return err
+}
+
+func makeCmdPackageSetEdit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageSetEdit,
+		UsageLine: "edit <name> [<package-query> ...]",
+		Short:     "edit properties of package set",
+		Long: `
+Command edit allows one to change comment of the package set. If
+package queries are specified, they replace the queries of the
+package set.
+
+Example:
+
+  $ aptly package set edit -comment="Runtime libraries" base-runtime libc6 libstdc++6
+`,
+		Flag: *flag.NewFlagSet("aptly-package-set-edit", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("comment", "", "any text that would be used to describe package set")
+
+	return cmd
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+func aptlyPackageSetList(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	jsonFlag := cmd.Flag.Lookup("json").Value.Get().(bool)
+
+	if jsonFlag {
+		return aptlyPackageSetListJSON(cmd, args)
+	}
+
+	return aptlyPackageSetListTxt(cmd, args)
+}
+
+func aptlyPackageSetListTxt(cmd *commander.Command, _ []string) error {
+	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
+
+	collectionFactory := context.NewCollectionFactory()
+	sets := []string{}
+	err := collectionFactory.PackageSetCollection().ForEach(func(set *deb.PackageSet) error {
+		if raw {
+			sets = append(sets, set.Name)
+		} else {
+			sets = append(sets, fmt.Sprintf(" * %s (queries: %d)", set.String(), len(set.Queries)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	context.CloseDatabase()
+
+	sort.Strings(sets)
+
+	if raw {
+		for _, set := range sets {
+			fmt.Printf("%s\n", set)
+		}
+	} else {
+		if len(sets) > 0 {
+			fmt.Printf("List of package sets:\n")
+			for _, set := range sets {
+				fmt.Println(set)
+			}
+
+			fmt.Printf("\nTo get more information about package set, run `aptly package set show <name>`.\n")
+		} else {
+			fmt.Printf("No package sets found, create one with `aptly package set create ...`.\n")
+		}
+	}
+
+	return nil
+}
+
+func aptlyPackageSetListJSON(_ *commander.Command, _ []string) error {
+	sets := []*deb.PackageSet{}
+	err := context.NewCollectionFactory().PackageSetCollection().ForEach(func(set *deb.PackageSet) error {
+		sets = append(sets, set)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	context.CloseDatabase()
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Name < sets[j].Name
+	})
+
+	output, err := json.MarshalIndent(sets, "", "  ")
+	if err == nil {
+		fmt.Println(string(output))
+	}
+
+	return err
+}
+
+func makeCmdPackageSetList() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageSetList,
+		UsageLine: "list",
+		Short:     "list package sets",
+		Long: `
+List command shows full list of named package sets.
+
+Example:
+
+  $ aptly package set list
+`,
+	}
+
+	cmd.Flag.Bool("json", false, "display list in JSON format")
+	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
+
+	return cmd
+}
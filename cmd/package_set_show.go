@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPackageSetShow(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	set, err := context.NewCollectionFactory().PackageSetCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to show: %s", err)
+	}
+
+	if cmd.Flag.Lookup("json").Value.Get().(bool) {
+		var output []byte
+		if output, err = json.MarshalIndent(set, "", "  "); err == nil {
+			fmt.Println(string(output))
+		}
+
+		return err
+	}
+
+	fmt.Printf("Name: %s\n", set.Name)
+	fmt.Printf("Comment: %s\n", set.Comment)
+	fmt.Printf("Queries:\n")
+	for _, q := range set.Queries {
+		fmt.Printf("  %s\n", q)
+	}
+
+	return err
+}
+
+func makeCmdPackageSetShow() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageSetShow,
+		UsageLine: "show <name>",
+		Short:     "show details about package set",
+		Long: `
+Show command shows full information about named package set.
+
+ex:
+  $ aptly package set show base-runtime
+`,
+		Flag: *flag.NewFlagSet("aptly-package-set-show", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display record in JSON format")
+
+	return cmd
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -12,7 +13,14 @@ import (
 	"github.com/smira/flag"
 )
 
-func printReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory) (err error) {
+// packageReferences is a list of mirrors, local repos and snapshots referencing package
+type packageReferences struct {
+	Mirrors    []*deb.RemoteRepo
+	LocalRepos []*deb.LocalRepo
+	Snapshots  []*deb.Snapshot
+}
+
+func collectReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory) (refs packageReferences, err error) {
 	err = collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
 		e := collectionFactory.RemoteRepoCollection().LoadComplete(repo)
 		if e != nil {
@@ -20,13 +28,13 @@ func printReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory)
 		}
 		if repo.RefList() != nil {
 			if repo.RefList().Has(p) {
-				fmt.Printf("  mirror %s\n", repo)
+				refs.Mirrors = append(refs.Mirrors, repo)
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		return err
+		return
 	}
 
 	err = collectionFactory.LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
@@ -36,13 +44,13 @@ func printReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory)
 		}
 		if repo.RefList() != nil {
 			if repo.RefList().Has(p) {
-				fmt.Printf("  local repo %s\n", repo)
+				refs.LocalRepos = append(refs.LocalRepos, repo)
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		return err
+		return
 	}
 
 	err = collectionFactory.SnapshotCollection().ForEach(func(snapshot *deb.Snapshot) error {
@@ -51,32 +59,83 @@ func printReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory)
 			return e
 		}
 		if snapshot.RefList().Has(p) {
-			fmt.Printf("  snapshot %s\n", snapshot)
+			refs.Snapshots = append(refs.Snapshots, snapshot)
 		}
 		return nil
 	})
 
-	return err
+	return
+}
+
+func printReferencesTo(p *deb.Package, collectionFactory *deb.CollectionFactory) error {
+	refs, err := collectReferencesTo(p, collectionFactory)
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range refs.Mirrors {
+		fmt.Printf("  mirror %s\n", repo)
+	}
+	for _, repo := range refs.LocalRepos {
+		fmt.Printf("  local repo %s\n", repo)
+	}
+	for _, snapshot := range refs.Snapshots {
+		fmt.Printf("  snapshot %s\n", snapshot)
+	}
+
+	return nil
 }
 
 func aptlyPackageShow(cmd *commander.Command, args []string) error {
-	var err error
 	if len(args) != 1 {
 		cmd.Usage()
 		return commander.ErrCommandError
 	}
 
-	q, err := query.Parse(args[0])
+	jsonFlag := cmd.Flag.Lookup("json").Value.Get().(bool)
+
+	if jsonFlag {
+		return aptlyPackageShowJSON(cmd, args)
+	}
+
+	return aptlyPackageShowTxt(cmd, args)
+}
+
+// packagePoolPaths returns paths to package files in the pool
+func packagePoolPaths(p *deb.Package) ([]string, error) {
+	packagePool := context.PackagePool()
+	paths := make([]string, 0, len(p.Files()))
+	for _, f := range p.Files() {
+		path, err := f.GetPoolPath(packagePool)
+		if err != nil {
+			return nil, err
+		}
+
+		if pp, ok := packagePool.(aptly.LocalPackagePool); ok {
+			path = pp.FullPath(path)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func aptlyPackageShowTxt(_ *commander.Command, args []string) error {
+	var err error
+
+	collectionFactory := context.NewCollectionFactory()
+	q, err := query.ParseWithPackageSets(args[0], collectionFactory.PackageSetCollection())
 	if err != nil {
 		return fmt.Errorf("unable to show: %s", err)
 	}
 
 	withFiles := context.Flags().Lookup("with-files").Value.Get().(bool)
+	withContents := context.Flags().Lookup("with-contents").Value.Get().(bool)
 	withReferences := context.Flags().Lookup("with-references").Value.Get().(bool)
 
 	w := bufio.NewWriter(os.Stdout)
 
-	collectionFactory := context.NewCollectionFactory()
 	result := q.Query(collectionFactory.PackageCollection())
 
 	err = result.ForEach(func(p *deb.Package) error {
@@ -86,19 +145,39 @@ func aptlyPackageShow(cmd *commander.Command, args []string) error {
 
 		if withFiles {
 			fmt.Printf("Files in the pool:\n")
-			packagePool := context.PackagePool()
-			for _, f := range p.Files() {
-				var path string
-				path, err = f.GetPoolPath(packagePool)
-				if err != nil {
-					return err
-				}
+			var paths []string
+			paths, err = packagePoolPaths(p)
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				fmt.Printf("  %s\n", path)
+			}
+			fmt.Printf("\n")
+		}
+
+		if withContents && !p.IsSource {
+			var inspection *deb.DebInspection
+			inspection, err = p.Inspect(context.PackagePool())
+			if err != nil {
+				return err
+			}
 
-				if pp, ok := packagePool.(aptly.LocalPackagePool); ok {
-					path = pp.FullPath(path)
+			if len(inspection.Conffiles) > 0 {
+				fmt.Printf("Conffiles:\n")
+				for _, conffile := range inspection.Conffiles {
+					fmt.Printf("  %s\n", conffile)
 				}
+				fmt.Printf("\n")
+			}
 
-				fmt.Printf("  %s\n", path)
+			fmt.Printf("Files in the package:\n")
+			for _, f := range inspection.Files {
+				if f.LinkTarget != "" {
+					fmt.Printf("  %s %s -> %s\n", f.Mode, f.Path, f.LinkTarget)
+				} else {
+					fmt.Printf("  %s %s (%d bytes)\n", f.Mode, f.Path, f.Size)
+				}
 			}
 			fmt.Printf("\n")
 		}
@@ -119,6 +198,107 @@ func aptlyPackageShow(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// packageShowJSON is JSON representation of package in `package show -json`
+type packageShowJSON struct {
+	*deb.Package
+	PoolFiles  []string
+	Conffiles  []string
+	Contents   []deb.DebFile
+	References *packageReferences
+}
+
+// MarshalJSON merges package stanza with extra information
+func (p packageShowJSON) MarshalJSON() ([]byte, error) {
+	result := map[string]interface{}{}
+	for k, v := range p.Package.ExtendedStanza() {
+		result[k] = v
+	}
+	if p.PoolFiles != nil {
+		result["PoolFiles"] = p.PoolFiles
+	}
+	if p.Contents != nil {
+		result["Conffiles"] = p.Conffiles
+		result["Contents"] = p.Contents
+	}
+	if p.References != nil {
+		refs := map[string][]string{"Mirrors": {}, "LocalRepos": {}, "Snapshots": {}}
+		for _, repo := range p.References.Mirrors {
+			refs["Mirrors"] = append(refs["Mirrors"], repo.Name)
+		}
+		for _, repo := range p.References.LocalRepos {
+			refs["LocalRepos"] = append(refs["LocalRepos"], repo.Name)
+		}
+		for _, snapshot := range p.References.Snapshots {
+			refs["Snapshots"] = append(refs["Snapshots"], snapshot.Name)
+		}
+		result["References"] = refs
+	}
+
+	return json.Marshal(result)
+}
+
+func aptlyPackageShowJSON(_ *commander.Command, args []string) error {
+	collectionFactory := context.NewCollectionFactory()
+	q, err := query.ParseWithPackageSets(args[0], collectionFactory.PackageSetCollection())
+	if err != nil {
+		return fmt.Errorf("unable to show: %s", err)
+	}
+
+	withFiles := context.Flags().Lookup("with-files").Value.Get().(bool)
+	withContents := context.Flags().Lookup("with-contents").Value.Get().(bool)
+	withReferences := context.Flags().Lookup("with-references").Value.Get().(bool)
+
+	result := q.Query(collectionFactory.PackageCollection())
+	result.PrepareIndex()
+
+	packages := make([]packageShowJSON, 0, result.Len())
+
+	err = result.ForEachIndexed(func(p *deb.Package) error {
+		entry := packageShowJSON{Package: p}
+
+		if withFiles {
+			paths, e := packagePoolPaths(p)
+			if e != nil {
+				return e
+			}
+			entry.PoolFiles = paths
+		}
+
+		if withContents && !p.IsSource {
+			inspection, e := p.Inspect(context.PackagePool())
+			if e != nil {
+				return e
+			}
+			entry.Conffiles = inspection.Conffiles
+			entry.Contents = inspection.Files
+			if entry.Contents == nil {
+				entry.Contents = []deb.DebFile{}
+			}
+		}
+
+		if withReferences {
+			refs, e := collectReferencesTo(p, collectionFactory)
+			if e != nil {
+				return e
+			}
+			entry.References = &refs
+		}
+
+		packages = append(packages, entry)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to show: %s", err)
+	}
+
+	var output []byte
+	if output, err = json.MarshalIndent(packages, "", "  "); err == nil {
+		fmt.Println(string(output))
+	}
+
+	return err
+}
+
 func makeCmdPackageShow() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyPackageShow,
@@ -127,8 +307,9 @@ func makeCmdPackageShow() *commander.Command {
 		Long: `
 Command shows displays detailed meta-information about packages
 matching query. Information from Debian control file is displayed.
-Optionally information about package files and
-inclusion into mirrors/snapshots/local repos is shown.
+Optionally information about package files, list of files
+installed by the package and inclusion into mirrors/snapshots/local
+repos is shown.
 
 Example:
 
@@ -138,7 +319,9 @@ Example:
 	}
 
 	cmd.Flag.Bool("with-files", false, "display information about files from package pool")
+	cmd.Flag.Bool("with-contents", false, "display list of files and conffiles installed by the package (binary packages only)")
 	cmd.Flag.Bool("with-references", false, "display information about mirrors, snapshots and local repos referencing this package")
+	cmd.Flag.Bool("json", false, "display record in JSON format")
 
 	return cmd
 }
@@ -1,6 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -16,6 +22,7 @@ func getSigner(flags *flag.FlagSet) (pgp.Signer, error) {
 	signer.SetKeyRing(flags.Lookup("keyring").Value.String(), flags.Lookup("secret-keyring").Value.String())
 	signer.SetPassphrase(flags.Lookup("passphrase").Value.String(), flags.Lookup("passphrase-file").Value.String())
 	signer.SetBatch(flags.Lookup("batch").Value.Get().(bool))
+	signer.SetDigestAlgorithm(flags.Lookup("gpg-digest-algo").Value.String())
 
 	err := signer.Init()
 	if err != nil {
@@ -26,11 +33,221 @@ func getSigner(flags *flag.FlagSet) (pgp.Signer, error) {
 
 }
 
+// releaseFieldsFlag collects custom Release fields specified as "Name: value"
+type releaseFieldsFlag struct {
+	fields map[string]string
+}
+
+func (r *releaseFieldsFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("release field should be specified as 'Name: value'")
+	}
+
+	if r.fields == nil {
+		r.fields = make(map[string]string)
+	}
+	r.fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	return nil
+}
+
+func (r *releaseFieldsFlag) Get() interface{} {
+	return r.fields
+}
+
+func (r *releaseFieldsFlag) String() string {
+	fields := make([]string, 0, len(r.fields))
+	for name, value := range r.fields {
+		fields = append(fields, name+": "+value)
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, ", ")
+}
+
+// componentRulesFlag collects component rules specified as "component=query"
+type componentRulesFlag struct {
+	rules []deb.ComponentRule
+}
+
+func (r *componentRulesFlag) Set(value string) error {
+	rule, err := deb.ParseComponentRule(value)
+	if err != nil {
+		return err
+	}
+
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *componentRulesFlag) Get() interface{} {
+	return r.rules
+}
+
+func (r *componentRulesFlag) String() string {
+	rules := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		rules[i] = rule.String()
+	}
+
+	return strings.Join(rules, "; ")
+}
+
+// applyReleaseFields updates custom Release fields of published repository from flags,
+// field with empty value is removed
+func applyReleaseFields(published *deb.PublishedRepo, flags *flag.FlagSet) error {
+	fields, _ := flags.Lookup("release-field").Value.Get().(map[string]string)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err := deb.ValidateReleaseFields(fields)
+	if err != nil {
+		return err
+	}
+
+	if published.ReleaseFields == nil {
+		published.ReleaseFields = make(map[string]string)
+	}
+
+	for name, value := range fields {
+		if value == "" {
+			delete(published.ReleaseFields, name)
+		} else {
+			published.ReleaseFields[name] = value
+		}
+	}
+
+	return nil
+}
+
+// applyOverrides replaces overrides of published repository with the ones from
+// override files, if specified, or clears them if requested
+func applyOverrides(published *deb.PublishedRepo, flags *flag.FlagSet) error {
+	if clearFlag := flags.Lookup("clear-overrides"); clearFlag != nil && clearFlag.Value.Get().(bool) {
+		published.Overrides = nil
+		published.SourceOverrides = nil
+	}
+
+	overrideFile := flags.Lookup("override-file").Value.String()
+	if overrideFile != "" {
+		overrides, err := readOverrides(overrideFile)
+		if err == nil {
+			err = overrides.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("unable to parse %s: %s", overrideFile, err)
+		}
+
+		published.Overrides = overrides
+	}
+
+	sourceOverrideFile := flags.Lookup("source-override-file").Value.String()
+	if sourceOverrideFile != "" {
+		overrides, err := readOverrides(sourceOverrideFile)
+		if err == nil {
+			err = overrides.ValidateSource()
+		}
+		if err != nil {
+			return fmt.Errorf("unable to parse %s: %s", sourceOverrideFile, err)
+		}
+
+		published.SourceOverrides = overrides
+	}
+
+	return nil
+}
+
+// readOverrides parses apt-ftparchive style override file
+func readOverrides(path string) (deb.OverrideTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return deb.ParseOverrides(f)
+}
+
+// applyKeyPublishing updates settings of signing key publishing from flags
+func applyKeyPublishing(published *deb.PublishedRepo, flags *flag.FlagSet) error {
+	if flags.IsSet("publish-key") {
+		published.PublishKey = flags.Lookup("publish-key").Value.Get().(bool)
+	}
+
+	if flags.IsSet("public-url") {
+		publicURL := flags.Lookup("public-url").Value.String()
+		if publicURL != "" {
+			err := deb.ValidatePublicURL(publicURL)
+			if err != nil {
+				return err
+			}
+		}
+		published.PublicURL = publicURL
+	}
+
+	return nil
+}
+
+// applyAliases updates distribution aliases of published repository from flags, verifying
+// that aliases are not used by another published repository
+func applyAliases(published *deb.PublishedRepo, collection *deb.PublishedRepoCollection, flags *flag.FlagSet) error {
+	if !flags.IsSet("alias") {
+		return nil
+	}
+
+	err := published.SetAliases(strings.Split(flags.Lookup("alias").Value.String(), ","))
+	if err != nil {
+		return err
+	}
+
+	duplicate := collection.CheckAliasDuplicate(published)
+	if duplicate != nil {
+		return fmt.Errorf("distribution alias already used by another published repo: %s/%s", duplicate.StoragePrefix(), duplicate.Distribution)
+	}
+
+	return nil
+}
+
+// applyReplicas updates list of published storages published repository is replicated to,
+// verifying that distribution is not published to replicas by another published repository
+func applyReplicas(published *deb.PublishedRepo, collection *deb.PublishedRepoCollection, flags *flag.FlagSet) error {
+	if !flags.IsSet("replicas") {
+		return nil
+	}
+
+	replicas := strings.Split(flags.Lookup("replicas").Value.String(), ",")
+	for _, replica := range replicas {
+		replica = strings.TrimSpace(replica)
+		if replica == "" {
+			continue
+		}
+
+		err := context.CheckPublishedStorage(replica)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := published.SetReplicas(replicas)
+	if err != nil {
+		return err
+	}
+
+	duplicate := collection.CheckAliasDuplicate(published)
+	if duplicate != nil {
+		return fmt.Errorf("distribution already published to replica by another published repo: %s/%s", duplicate.StoragePrefix(), duplicate.Distribution)
+	}
+
+	return nil
+}
+
 func makeCmdPublish() *commander.Command {
 	return &commander.Command{
 		UsageLine: "publish",
 		Short:     "manage published repositories",
 		Subcommands: []*commander.Command{
+			makeCmdPublishCleanup(),
 			makeCmdPublishDrop(),
 			makeCmdPublishList(),
 			makeCmdPublishRepo(),
@@ -38,6 +255,24 @@ func makeCmdPublish() *commander.Command {
 			makeCmdPublishSwitch(),
 			makeCmdPublishUpdate(),
 			makeCmdPublishShow(),
+			makeCmdPublishSources(),
+			makeCmdPublishRefresh(),
+			makeCmdPublishResume(),
 		},
 	}
 }
+
+// applyComponentRules replaces component rules of published repository with the ones from
+// flags, if specified, or clears them if requested
+func applyComponentRules(published *deb.PublishedRepo, collectionFactory *deb.CollectionFactory, flags *flag.FlagSet) error {
+	if clearFlag := flags.Lookup("clear-component-rules"); clearFlag != nil && clearFlag.Value.Get().(bool) {
+		published.ComponentRules = nil
+	}
+
+	rules, _ := flags.Lookup("component-rule").Value.Get().([]deb.ComponentRule)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	return published.SetComponentRules(rules, collectionFactory)
+}
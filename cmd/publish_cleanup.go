@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishCleanup(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) > 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	param := "."
+	if len(args) == 1 {
+		param = args[0]
+	}
+
+	storage, prefix := deb.ParsePrefix(param)
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	collectionFactory := context.NewCollectionFactory()
+	removed, err := collectionFactory.PublishedRepoCollection().CleanupPrefix(storage, prefix,
+		context.GetPublishedStorage(storage), collectionFactory, context.Progress(), dryRun)
+	if err != nil {
+		return fmt.Errorf("unable to cleanup: %s", err)
+	}
+
+	if dryRun {
+		if len(removed) == 0 {
+			context.Progress().Printf("\nNo files to remove.\n")
+		} else {
+			context.Progress().Printf("\nFiles to be removed (%d):\n", len(removed))
+			for _, file := range removed {
+				context.Progress().Printf("  %s\n", file)
+			}
+		}
+		context.Progress().ColoredPrintf("@{y!}Skipped file deletion, as -dry-run has been requested.@|")
+	} else {
+		context.Progress().Printf("\nRemoved %d unreferenced files.\n", len(removed))
+	}
+
+	return err
+}
+
+func makeCmdPublishCleanup() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishCleanup,
+		UsageLine: "cleanup [[<endpoint>:]<prefix>]",
+		Short:     "remove unreferenced files from published prefix",
+		Long: `
+Command removes files which are not referenced by any repository published
+under <prefix> and publishing <endpoint>: package files in pool/ which are not
+published anymore and indexes in dists/ left behind by dropped distributions
+and components. Such files might be left over after publish switch or drop
+with -skip-cleanup, or after failed publishing.
+
+Example:
+
+    $ aptly publish cleanup -dry-run ppa
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-cleanup", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't delete anything, just list files to be removed")
+
+	return cmd
+}
@@ -43,6 +43,8 @@ func aptlyPublishListTxt(cmd *commander.Command, _ []string) error {
 
 		if raw {
 			published = append(published, fmt.Sprintf("%s %s", repo.StoragePrefix(), repo.Distribution))
+		} else if repo.Description != "" {
+			published = append(published, fmt.Sprintf("%s\n    %s", repo.String(), repo.Description))
 		} else {
 			published = append(published, repo.String())
 		}
@@ -80,17 +82,23 @@ func aptlyPublishListTxt(cmd *commander.Command, _ []string) error {
 func aptlyPublishListJSON(_ *commander.Command, _ []string) error {
 	var err error
 
-	repos := make([]*deb.PublishedRepo, 0, context.NewCollectionFactory().PublishedRepoCollection().Len())
+	collectionFactory := context.NewCollectionFactory()
+	repos := make([]*deb.PublishedRepoDetail, 0, collectionFactory.PublishedRepoCollection().Len())
 
-	err = context.NewCollectionFactory().PublishedRepoCollection().ForEach(func(repo *deb.PublishedRepo) error {
-		e := context.NewCollectionFactory().PublishedRepoCollection().LoadComplete(repo, context.NewCollectionFactory())
+	err = collectionFactory.PublishedRepoCollection().ForEach(func(repo *deb.PublishedRepo) error {
+		e := collectionFactory.PublishedRepoCollection().LoadComplete(repo, collectionFactory)
 		if e != nil {
 			fmt.Fprintf(os.Stderr, "Error found on one publish (prefix:%s / distribution:%s / component:%s\n)",
 				repo.StoragePrefix(), repo.Distribution, repo.Components())
 			return e
 		}
 
-		repos = append(repos, repo)
+		detail, e := repo.Detail(context)
+		if e != nil {
+			return e
+		}
+
+		repos = append(repos, detail)
 
 		return nil
 	})
@@ -102,7 +110,7 @@ func aptlyPublishListJSON(_ *commander.Command, _ []string) error {
 	context.CloseDatabase()
 
 	sort.Slice(repos, func(i, j int) bool {
-		return repos[i].GetPath() < repos[j].GetPath()
+		return repos[i].Path < repos[j].Path
 	})
 	if output, e := json.MarshalIndent(repos, "", "  "); e == nil {
 		fmt.Println(string(output))
@@ -121,6 +129,9 @@ func makeCmdPublishList() *commander.Command {
 		Long: `
 Display list of currently published snapshots.
 
+With -json, each published repository is described with the same
+fields as aptly publish show -json.
+
 Example:
 
     $ aptly publish list
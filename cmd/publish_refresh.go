@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishRefresh(cmd *commander.Command, args []string) error {
+	var err error
+
+	daemon := context.Flags().Lookup("daemon").Value.Get().(bool)
+	if len(args) > 2 || (len(args) < 1 && !daemon) {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	var storage, prefix, distribution string
+	if len(args) > 0 {
+		distribution = args[0]
+		param := "."
+
+		if len(args) == 2 {
+			param = args[1]
+		}
+		storage, prefix = deb.ParsePrefix(param)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	if !daemon {
+		collectionFactory := context.NewCollectionFactory()
+
+		var published *deb.PublishedRepo
+		published, err = collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return fmt.Errorf("unable to refresh: %s", err)
+		}
+
+		return refreshPublishedRelease(collectionFactory, published, signer)
+	}
+
+	interval := context.Flags().Lookup("interval").Value.Get().(time.Duration)
+	if interval <= 0 {
+		return fmt.Errorf("unable to refresh: interval should be positive")
+	}
+
+	context.GoContextHandleSignals()
+
+	for {
+		err = context.ReOpenDatabase()
+		if err != nil {
+			return fmt.Errorf("unable to refresh: %s", err)
+		}
+
+		collectionFactory := context.NewCollectionFactory()
+		now := time.Now()
+
+		err = collectionFactory.PublishedRepoCollection().ForEach(func(published *deb.PublishedRepo) error {
+			if distribution != "" && (published.Storage != storage || published.Prefix != prefix || published.Distribution != distribution) {
+				return nil
+			}
+
+			if !published.RefreshDue(now) {
+				return nil
+			}
+
+			return refreshPublishedRelease(collectionFactory, published, signer)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = context.CloseDatabase()
+		if err != nil {
+			return fmt.Errorf("unable to refresh: %s", err)
+		}
+
+		select {
+		case <-context.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func refreshPublishedRelease(collectionFactory *deb.CollectionFactory, published *deb.PublishedRepo, signer pgp.Signer) error {
+	refreshErr := published.RefreshRelease(context, signer, context.Progress())
+	if _, ok := refreshErr.(*deb.ReplicaPublishError); refreshErr != nil && !ok {
+		return fmt.Errorf("unable to refresh %s/%s: %s", published.StoragePrefix(), published.Distribution, refreshErr)
+	}
+
+	err := collectionFactory.PublishedRepoCollection().Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	if refreshErr != nil {
+		return fmt.Errorf("unable to refresh %s/%s: %s, use aptly publish resume to retry", published.StoragePrefix(), published.Distribution, refreshErr)
+	}
+
+	if validUntil := published.ValidUntil(); !validUntil.IsZero() {
+		context.Progress().Printf("Release file for %s/%s has been refreshed, valid until %s.\n",
+			published.StoragePrefix(), published.Distribution, validUntil.Format("2006-01-02 15:04:05 MST"))
+	} else {
+		context.Progress().Printf("Release file for %s/%s has been refreshed.\n", published.StoragePrefix(), published.Distribution)
+	}
+
+	return nil
+}
+
+func makeCmdPublishRefresh() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishRefresh,
+		UsageLine: "refresh <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "re-generate and re-sign Release files of published repository",
+		Long: `
+Command re-generates and re-signs Release, InRelease and Release.gpg files
+of published repository with new Date (and Valid-Until, if published
+repository was published with -valid-for), package indexes are not touched.
+
+With -daemon, command keeps running and every -interval re-signs Release files
+of published repositories (or just one, if <distribution> is given) which are
+past half of their validity period, so that clients never see expired Release
+files unless aptly stops running.
+
+Example:
+
+    $ aptly publish refresh wheezy ppa
+
+    $ aptly publish refresh -daemon -interval=1h
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-refresh", flag.ExitOnError),
+	}
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+	cmd.Flag.Bool("daemon", false, "keep running, re-signing expiring Release files")
+	cmd.Flag.Duration("interval", time.Hour, "how often to check for expiring Release files in daemon mode")
+
+	return cmd
+}
@@ -26,6 +26,9 @@ repository is for testing purposes and changes happen frequently. For
 production usage please take snapshot of repository and publish it
 using publish snapshot command.
 
+List of published architectures could be adjusted with -include-architectures
+(published even without packages) and -exclude-architectures.
+
 Example:
 
     $ aptly publish repo testing
@@ -35,6 +38,7 @@ Example:
 	cmd.Flag.String("distribution", "", "distribution name to publish")
 	cmd.Flag.String("component", "", "component name to publish (for multi-component publishing, separate components with commas)")
 	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
 	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
 	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
@@ -43,6 +47,8 @@ Example:
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
 	cmd.Flag.Bool("skip-bz2", false, "don't generate bzipped indexes")
+	cmd.Flag.Bool("pdiffs", false, "generate pdiffs (Packages.diff) against previously published indexes")
+	cmd.Flag.Bool("debian-field-order", false, "write fields of packages in indexes in the order used by Debian archive tools")
 	cmd.Flag.String("origin", "", "origin name to publish")
 	cmd.Flag.String("notautomatic", "", "set value for NotAutomatic field")
 	cmd.Flag.String("butautomaticupgrades", "", "set  value for ButAutomaticUpgrades field")
@@ -51,7 +57,21 @@ Example:
 	cmd.Flag.String("codename", "", "codename to publish (defaults to distribution)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("acquire-by-hash", false, "provide index files by hash")
+	cmd.Flag.String("architecture-all", "", "how to publish Architecture: all packages: per-arch (default), separate or both")
+	cmd.Flag.String("include-architectures", "", "list of architectures to publish even if there are no packages for them (comma-separated)")
+	cmd.Flag.String("exclude-architectures", "", "list of architectures not to publish (comma-separated)")
 	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+	cmd.Flag.String("description", "", "free-form description of published repository")
+	cmd.Flag.String("provenance", "", "free-form record of what published repository was built from")
+	cmd.Flag.Duration("valid-for", 0, "stamp Release file with Valid-Until this far in the future (e.g. 168h), 0 means no expiry")
+	cmd.Flag.Var(&releaseFieldsFlag{}, "release-field", "custom field to add to Release file as 'Name: value' (could be specified multiple times)")
+	cmd.Flag.String("override-file", "", "apt-ftparchive style override file to correct Priority, Section, Maintainer and other fields of packages in indexes")
+	cmd.Flag.String("source-override-file", "", "apt-ftparchive style source override file to correct Priority, Section and Maintainer of source packages in indexes")
+	cmd.Flag.Bool("publish-key", false, "publish public signing key next to Release file")
+	cmd.Flag.String("public-url", "", "URL published repository is served from, client configuration (.sources and .list) is published if set")
+	cmd.Flag.String("alias", "", "comma-separated list of distribution aliases to publish under as well, sharing index files (e.g. stable)")
+	cmd.Flag.String("replicas", "", "comma-separated list of additional published storages to publish to (e.g. s3:mirror,filesystem:backup)")
+	cmd.Flag.Var(&componentRulesFlag{}, "component-rule", "route packages matching query into component as 'component=query' (could be specified multiple times)")
 
 	return cmd
 }
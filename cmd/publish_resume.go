@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/webhook"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishResume(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	collectionFactory := context.NewCollectionFactory()
+	published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to resume: %s", err)
+	}
+
+	err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to resume: %s", err)
+	}
+
+	if len(published.FailedReplicas) == 0 {
+		context.Progress().Printf("Published repository %s/%s has no failed replicas, nothing to resume.\n", published.StoragePrefix(), published.Distribution)
+		return nil
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	forceOverwrite := context.Flags().Lookup("force-overwrite").Value.Get().(bool)
+	if forceOverwrite {
+		context.Progress().ColoredPrintf("@rWARNING@|: force overwrite mode enabled, aptly might corrupt other published repositories sharing the same package pool.\n")
+	}
+
+	multiDist := context.Flags().Lookup("multi-dist").Value.Get().(bool)
+	replicas := strings.Join(published.FailedReplicas, ", ")
+
+	publishErr := published.ResumeReplicas(context.PackagePool(), context, collectionFactory, signer, context.Progress(), forceOverwrite, multiDist)
+
+	err = collectionFactory.PublishedRepoCollection().Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	if publishErr != nil {
+		context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+		return fmt.Errorf("unable to resume: %s", publishErr)
+	}
+
+	context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+
+	skipCleanup := context.Flags().Lookup("skip-cleanup").Value.Get().(bool)
+	if !skipCleanup {
+		err = collectionFactory.PublishedRepoCollection().CleanupPublishedComponentFiles(published, published.Components(),
+			context, collectionFactory, context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to resume: %s", err)
+		}
+	}
+
+	context.Progress().Printf("\nPublished repository %s/%s has been published to replicas %s.\n", published.StoragePrefix(), published.Distribution, replicas)
+
+	return err
+}
+
+func makeCmdPublishResume() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishResume,
+		UsageLine: "resume <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "publish repository to replicas which failed during last publish",
+		Long: `
+Command publishes repository again to replicas (additional published storages
+given with -replicas) which failed to be updated during last publish, switch or
+update. Published repository is always published to its own storage first: if
+that fails, nothing is changed, while if only some of the replicas fail,
+published repository is updated and failed replicas are recorded (see aptly
+publish show). Failed replicas keep serving previous version of published
+repository until resumed.
+
+Example:
+
+    $ aptly publish resume wheezy ppa
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-resume", flag.ExitOnError),
+	}
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
+	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
+	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+
+	return cmd
+}
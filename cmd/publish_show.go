@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
@@ -50,6 +51,76 @@ func aptlyPublishShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Distribution: %s\n", repo.Distribution)
 	}
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, " "))
+	if repo.ArchitectureAllMode != "" {
+		fmt.Printf("Architecture all: %s\n", repo.ArchitectureAllMode)
+	}
+	if len(repo.IncludeArchitectures) > 0 {
+		fmt.Printf("Included architectures: %s\n", strings.Join(repo.IncludeArchitectures, " "))
+	}
+	if len(repo.ExcludeArchitectures) > 0 {
+		fmt.Printf("Excluded architectures: %s\n", strings.Join(repo.ExcludeArchitectures, " "))
+	}
+	if !repo.CreatedAt.IsZero() {
+		fmt.Printf("Created At: %s\n", repo.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	if repo.Description != "" {
+		fmt.Printf("Description: %s\n", repo.Description)
+	}
+	if repo.Provenance != "" {
+		fmt.Printf("Provenance: %s\n", repo.Provenance)
+	}
+	if repo.ValidFor > 0 {
+		fmt.Printf("Valid For: %s\n", repo.ValidFor)
+		if validUntil := repo.ValidUntil(); !validUntil.IsZero() {
+			fmt.Printf("Valid Until: %s\n", validUntil.Format("2006-01-02 15:04:05 MST"))
+		}
+	}
+
+	if len(repo.ReleaseFields) > 0 {
+		fmt.Printf("Release Fields:\n")
+		names := make([]string, 0, len(repo.ReleaseFields))
+		for name := range repo.ReleaseFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, repo.ReleaseFields[name])
+		}
+	}
+
+	if len(repo.Overrides) > 0 {
+		fmt.Printf("Overrides: %d package(s)\n", len(repo.Overrides))
+	}
+
+	if len(repo.SourceOverrides) > 0 {
+		fmt.Printf("Source overrides: %d package(s)\n", len(repo.SourceOverrides))
+	}
+
+	if len(repo.Aliases) > 0 {
+		fmt.Printf("Aliases: %s\n", strings.Join(repo.Aliases, ", "))
+	}
+
+	if len(repo.Replicas) > 0 {
+		fmt.Printf("Replicas: %s\n", strings.Join(repo.Replicas, ", "))
+	}
+
+	if len(repo.FailedReplicas) > 0 {
+		fmt.Printf("Failed replicas: %s (use aptly publish resume to retry)\n", strings.Join(repo.FailedReplicas, ", "))
+	}
+
+	if len(repo.ComponentRules) > 0 {
+		fmt.Printf("Component Rules:\n")
+		for _, rule := range repo.ComponentRules {
+			fmt.Printf("  %s: %s\n", rule.Component, rule.Query)
+		}
+	}
+
+	if repo.PublishKey {
+		fmt.Printf("Published key: dists/%s/%s\n", repo.Distribution, deb.PublishedKeyArmored)
+	}
+	if repo.PublicURL != "" {
+		fmt.Printf("Public URL: %s\n", repo.PublicURL)
+	}
 
 	fmt.Printf("Sources:\n")
 	for component, sourceID := range repo.Sources {
@@ -88,18 +159,24 @@ func aptlyPublishShowJSON(_ *commander.Command, args []string) error {
 
 	storage, prefix := deb.ParsePrefix(param)
 
-	repo, err := context.NewCollectionFactory().PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
 	if err != nil {
 		return fmt.Errorf("unable to show: %s", err)
 	}
 
-	err = context.NewCollectionFactory().PublishedRepoCollection().LoadComplete(repo, context.NewCollectionFactory())
+	err = collectionFactory.PublishedRepoCollection().LoadComplete(repo, collectionFactory)
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to show: %s", err)
+	}
+
+	detail, err := repo.Detail(context)
+	if err != nil {
+		return fmt.Errorf("unable to show: %s", err)
 	}
 
 	var output []byte
-	if output, err = json.MarshalIndent(repo, "", "  "); err == nil {
+	if output, err = json.MarshalIndent(detail, "", "  "); err == nil {
 		fmt.Println(string(output))
 	}
 
@@ -114,6 +191,11 @@ func makeCmdPublishShow() *commander.Command {
 		Long: `
 Command show displays full information of a published repository.
 
+With -json, complete description is printed including sources of
+components (with number of packages), signing status, checksums of
+files listed in Release file and publishing options. Set of fields
+is the same for every published repository.
+
 Example:
 
     $ aptly publish show wheezy
@@ -3,10 +3,12 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -134,6 +136,8 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 	published.Label = context.Flags().Lookup("label").Value.String()
 	published.Suite = context.Flags().Lookup("suite").Value.String()
 	published.Codename = context.Flags().Lookup("codename").Value.String()
+	published.Description = context.Flags().Lookup("description").Value.String()
+	published.Provenance = context.Flags().Lookup("provenance").Value.String()
 
 	published.SkipContents = context.Config().SkipContentsPublishing
 
@@ -146,10 +150,62 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 		published.SkipBz2 = context.Flags().Lookup("skip-bz2").Value.Get().(bool)
 	}
 
+	if context.Flags().IsSet("pdiffs") {
+		published.PDiffs = context.Flags().Lookup("pdiffs").Value.Get().(bool)
+	}
+
+	if context.Flags().IsSet("debian-field-order") {
+		published.DebianFieldOrder = context.Flags().Lookup("debian-field-order").Value.Get().(bool)
+	}
+
 	if context.Flags().IsSet("acquire-by-hash") {
 		published.AcquireByHash = context.Flags().Lookup("acquire-by-hash").Value.Get().(bool)
 	}
 
+	published.ValidFor = context.Flags().Lookup("valid-for").Value.Get().(time.Duration)
+
+	err = applyReleaseFields(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = applyOverrides(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = applyKeyPublishing(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = applyAliases(published, collectionFactory.PublishedRepoCollection(), context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = applyReplicas(published, collectionFactory.PublishedRepoCollection(), context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = applyComponentRules(published, collectionFactory, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	published.ArchitectureAllMode = context.Flags().Lookup("architecture-all").Value.String()
+	if published.ArchitectureAllMode != "" && !utils.StrSliceHasItem(deb.ArchitectureAllModes, published.ArchitectureAllMode) {
+		return fmt.Errorf("unable to publish: unknown mode for architecture all: %s", published.ArchitectureAllMode)
+	}
+
+	if context.Flags().IsSet("include-architectures") {
+		published.IncludeArchitectures = strings.Split(context.Flags().Lookup("include-architectures").Value.String(), ",")
+	}
+	if context.Flags().IsSet("exclude-architectures") {
+		published.ExcludeArchitectures = strings.Split(context.Flags().Lookup("exclude-architectures").Value.String(), ",")
+	}
+
 	duplicate := collectionFactory.PublishedRepoCollection().CheckDuplicate(published)
 	if duplicate != nil {
 		collectionFactory.PublishedRepoCollection().LoadComplete(duplicate, collectionFactory)
@@ -166,15 +222,17 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 		context.Progress().ColoredPrintf("@rWARNING@|: force overwrite mode enabled, aptly might corrupt other published repositories sharing the same package pool.\n")
 	}
 
-	err = published.Publish(context.PackagePool(), context, collectionFactory, signer, context.Progress(), forceOverwrite, multiDist)
-	if err != nil {
-		return fmt.Errorf("unable to publish: %s", err)
+	saved, publishErr := collectionFactory.PublishedRepoCollection().PublishAndSave(published, true, context.PackagePool(), context, collectionFactory,
+		signer, context.Progress(), forceOverwrite, multiDist)
+	if publishErr != nil {
+		context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+		if saved {
+			return fmt.Errorf("unable to publish: %s, use aptly publish resume to retry", publishErr)
+		}
+		return fmt.Errorf("unable to publish: %s", publishErr)
 	}
 
-	err = collectionFactory.PublishedRepoCollection().Add(published)
-	if err != nil {
-		return fmt.Errorf("unable to save to DB: %s", err)
-	}
+	context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
 
 	var repoComponents string
 	prefix, repoComponents, distribution = published.Prefix, strings.Join(published.Components(), " "), published.Distribution
@@ -218,6 +276,65 @@ as the arguments:
 
     aptly publish snapshot -component=main,contrib snap-main snap-contrib
 
+By default, Architecture: all packages are included into index of every
+published architecture. With -architecture-all=separate such packages are
+published only in dedicated binary-all index, while -architecture-all=both
+publishes them in both places, marking Release file with
+No-Support-for-Architecture-all.
+
+List of published architectures (-architectures or all the architectures
+of the packages being published) could be adjusted with
+-include-architectures, which publishes architectures even without any
+packages (with empty indexes, as some apt clients expect indexes for every
+configured architecture), and -exclude-architectures, which skips
+architectures. List of architectures can't be changed after publishing.
+
+With -valid-for, Release file is stamped with Valid-Until field, so that
+clients reject stale repository; use aptly publish refresh to re-sign
+Release file before it expires.
+
+With -override-file, Priority, Section and Maintainer of binary packages in
+published indexes are replaced according to apt-ftparchive style override file
+(lines 'package priority section [maintainer]'), without repacking package files;
+overrides are kept and applied on every update of published repository. Lines
+'package Field: value' add or replace other fields of package in indexes, e.g.
+'hello Phased-Update-Percentage: 10' rolls out new version of hello to 10% of
+Ubuntu clients. Source packages in Sources indexes are overridden only by
+-source-override-file (lines 'package section'), as source and binary packages
+could share the same name.
+
+With -publish-key, public part of the signing key is published next to Release
+file as repo-key.asc (ASCII-armored) and repo-key.gpg (binary). With -public-url,
+client configuration is published next to Release file as well: repo.sources
+(deb822 format) and repo.list (one-line format), with Signed-By pointing to
+installed key, so that clients could bootstrap trust with one download. Client
+configuration could also be generated with 'aptly publish sources'.
+
+With -alias, published repository is made available under additional
+distributions (e.g. -distribution=bookworm -alias=stable): package pool and
+index files are shared with the distribution, only Release file is generated
+for every alias (with Suite set to the alias). Aliases could be changed with
+aptly publish update or aptly publish switch, aliases which are no longer
+listed are removed; -alias= removes all the aliases.
+
+With -replicas, published repository is published to additional published
+storages as well (e.g. -replicas=s3:mirror,filesystem:backup), keeping them in
+sync on every update. If publishing to some of the replicas fails, published
+repository is still updated, failed replicas are recorded and could be
+published again with aptly publish resume.
+
+With -component-rule, packages matching package query are routed into another
+component, so that single merged snapshot could be published as properly
+componentized distribution, e.g. -component-rule='non-free=Section (% non-free*)'
+publishes all the non-free packages into non-free component, while the rest
+stays in main. Package goes into the component of the first matching rule,
+rules are kept and applied on every update of published repository.
+
+Fields of packages unknown to aptly (e.g. vendor-specific ones) are published
+as they were in upstream indexes or control files. By default, fields of packages
+are written in aptly's order, with -debian-field-order they follow the order used
+by Debian archive tools (apt-ftparchive, dak), as some clients expect.
+
 Example:
 
     $ aptly publish snapshot wheezy-main
@@ -227,6 +344,7 @@ Example:
 	cmd.Flag.String("distribution", "", "distribution name to publish")
 	cmd.Flag.String("component", "", "component name to publish (for multi-component publishing, separate components with commas)")
 	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
 	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
 	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
@@ -235,6 +353,8 @@ Example:
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
 	cmd.Flag.Bool("skip-bz2", false, "don't generate bzipped indexes")
+	cmd.Flag.Bool("pdiffs", false, "generate pdiffs (Packages.diff) against previously published indexes")
+	cmd.Flag.Bool("debian-field-order", false, "write fields of packages in indexes in the order used by Debian archive tools")
 	cmd.Flag.String("origin", "", "overwrite origin name to publish")
 	cmd.Flag.String("notautomatic", "", "overwrite value for NotAutomatic field")
 	cmd.Flag.String("butautomaticupgrades", "", "overwrite value for ButAutomaticUpgrades field")
@@ -243,7 +363,21 @@ Example:
 	cmd.Flag.String("codename", "", "codename to publish (defaults to distribution)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("acquire-by-hash", false, "provide index files by hash")
+	cmd.Flag.String("architecture-all", "", "how to publish Architecture: all packages: per-arch (default), separate or both")
+	cmd.Flag.String("include-architectures", "", "list of architectures to publish even if there are no packages for them (comma-separated)")
+	cmd.Flag.String("exclude-architectures", "", "list of architectures not to publish (comma-separated)")
 	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+	cmd.Flag.String("description", "", "free-form description of published repository")
+	cmd.Flag.String("provenance", "", "free-form record of what published repository was built from")
+	cmd.Flag.Duration("valid-for", 0, "stamp Release file with Valid-Until this far in the future (e.g. 168h), 0 means no expiry")
+	cmd.Flag.Var(&releaseFieldsFlag{}, "release-field", "custom field to add to Release file as 'Name: value' (could be specified multiple times)")
+	cmd.Flag.String("override-file", "", "apt-ftparchive style override file to correct Priority, Section, Maintainer and other fields of packages in indexes")
+	cmd.Flag.String("source-override-file", "", "apt-ftparchive style source override file to correct Priority, Section and Maintainer of source packages in indexes")
+	cmd.Flag.Bool("publish-key", false, "publish public signing key next to Release file")
+	cmd.Flag.String("public-url", "", "URL published repository is served from, client configuration (.sources and .list) is published if set")
+	cmd.Flag.String("alias", "", "comma-separated list of distribution aliases to publish under as well, sharing index files (e.g. stable)")
+	cmd.Flag.String("replicas", "", "comma-separated list of additional published storages to publish to (e.g. s3:mirror,filesystem:backup)")
+	cmd.Flag.Var(&componentRulesFlag{}, "component-rule", "route packages matching query into component as 'component=query' (could be specified multiple times)")
 
 	return cmd
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishSources(cmd *commander.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+
+	storage, prefix := deb.ParsePrefix(param)
+
+	published, err := context.NewCollectionFactory().PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to generate sources: %s", err)
+	}
+
+	baseURL := context.Flags().Lookup("url").Value.String()
+	if baseURL == "" {
+		baseURL = published.PublicURL
+	}
+	if baseURL == "" {
+		return fmt.Errorf("unable to generate sources: URL published repository is served from is unknown, specify it with -url")
+	}
+
+	err = deb.ValidatePublicURL(baseURL)
+	if err != nil {
+		return fmt.Errorf("unable to generate sources: %s", err)
+	}
+
+	switch format := context.Flags().Lookup("format").Value.String(); format {
+	case "deb822":
+		fmt.Print(published.SourcesEntry(baseURL))
+	case "list":
+		fmt.Print(published.SourcesListEntry(baseURL))
+	default:
+		return fmt.Errorf("unable to generate sources: unknown format %s, expected deb822 or list", format)
+	}
+
+	return nil
+}
+
+func makeCmdPublishSources() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishSources,
+		UsageLine: "sources <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "generate client configuration for published repository",
+		Long: `
+Command sources prints APT configuration for clients of published repository,
+either in deb822 format (/etc/apt/sources.list.d/*.sources) or in one-line
+format (/etc/apt/sources.list.d/*.list). Configuration lists all published
+components and architectures, with Signed-By pointing to the keyring the
+signing key (published with -publish-key) should be installed to.
+
+URL published repository is served from is taken from -public-url of published
+repository, unless overridden with -url.
+
+Example:
+
+    $ aptly publish sources -url=https://apt.example.com/ wheezy > wheezy.sources
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-sources", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("url", "", "URL published repository is served from (defaults to -public-url of published repository)")
+	cmd.Flag.String("format", "deb822", "format of client configuration: deb822 or list")
+
+	return cmd
+}
@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -55,7 +57,7 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
-	publishedComponents := published.Components()
+	publishedComponents := published.SourceComponents()
 	if len(components) == 1 && len(publishedComponents) == 1 && components[0] == "" {
 		components = publishedComponents
 	}
@@ -101,25 +103,73 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		published.SkipBz2 = context.Flags().Lookup("skip-bz2").Value.Get().(bool)
 	}
 
-	err = published.Publish(context.PackagePool(), context, collectionFactory, signer, context.Progress(), forceOverwrite, multiDist)
+	if context.Flags().IsSet("pdiffs") {
+		published.PDiffs = context.Flags().Lookup("pdiffs").Value.Get().(bool)
+	}
+
+	if context.Flags().IsSet("debian-field-order") {
+		published.DebianFieldOrder = context.Flags().Lookup("debian-field-order").Value.Get().(bool)
+	}
+
+	if context.Flags().IsSet("valid-for") {
+		published.ValidFor = context.Flags().Lookup("valid-for").Value.Get().(time.Duration)
+	}
+
+	err = applyReleaseFields(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	err = applyOverrides(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	err = applyKeyPublishing(published, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	err = applyAliases(published, collectionFactory.PublishedRepoCollection(), context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	err = applyReplicas(published, collectionFactory.PublishedRepoCollection(), context.Flags())
 	if err != nil {
-		return fmt.Errorf("unable to publish: %s", err)
+		return fmt.Errorf("unable to update: %s", err)
 	}
 
-	err = collectionFactory.PublishedRepoCollection().Update(published)
+	err = applyComponentRules(published, collectionFactory, context.Flags())
 	if err != nil {
-		return fmt.Errorf("unable to save to DB: %s", err)
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	saved, publishErr := collectionFactory.PublishedRepoCollection().PublishAndSave(published, false, context.PackagePool(), context, collectionFactory,
+		signer, context.Progress(), forceOverwrite, multiDist)
+	if publishErr != nil {
+		context.Notify(webhook.EventPublishFailed, map[string]interface{}{"published": published}, publishErr)
+	} else {
+		context.Notify(webhook.EventPublishCompleted, map[string]interface{}{"published": published}, nil)
+	}
+
+	if !saved {
+		return fmt.Errorf("unable to publish: %s", publishErr)
 	}
 
 	skipCleanup := context.Flags().Lookup("skip-cleanup").Value.Get().(bool)
 	if !skipCleanup {
-		err = collectionFactory.PublishedRepoCollection().CleanupPrefixComponentFiles(published.Prefix, components,
-			context.GetPublishedStorage(storage), collectionFactory, context.Progress())
+		err = collectionFactory.PublishedRepoCollection().CleanupPublishedComponentFiles(published, components,
+			context, collectionFactory, context.Progress())
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
 		}
 	}
 
+	if publishErr != nil {
+		return fmt.Errorf("unable to publish: %s, use aptly publish resume to retry", publishErr)
+	}
+
 	context.Progress().Printf("\nPublish for snapshot %s has been successfully switched to new snapshot.\n", published.String())
 
 	return err
@@ -141,6 +191,10 @@ same order, e.g.:
 
 	aptly publish switch -component=main,contrib wheezy wh-main wh-contrib
 
+With -pdiffs, aptly generates Packages.diff/ pdiffs between previously
+published and new package indexes, so that apt clients could download
+only the changes (filesystem published storage only).
+
 Example:
 
     $ aptly publish switch wheezy ppa wheezy-7.5
@@ -151,6 +205,7 @@ This command would switch published repository (with one component) named ppa/wh
 		Flag: *flag.NewFlagSet("aptly-publish-switch", flag.ExitOnError),
 	}
 	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
 	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
 	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
@@ -159,6 +214,19 @@ This command would switch published repository (with one component) named ppa/wh
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
 	cmd.Flag.Bool("skip-bz2", false, "don't generate bzipped indexes")
+	cmd.Flag.Bool("pdiffs", false, "generate pdiffs (Packages.diff) against previously published indexes")
+	cmd.Flag.Bool("debian-field-order", false, "write fields of packages in indexes in the order used by Debian archive tools")
+	cmd.Flag.Duration("valid-for", 0, "stamp Release file with Valid-Until this far in the future (e.g. 168h), 0 means no expiry")
+	cmd.Flag.Var(&releaseFieldsFlag{}, "release-field", "custom field to add to Release file as 'Name: value' (could be specified multiple times)")
+	cmd.Flag.String("override-file", "", "apt-ftparchive style override file to correct Priority, Section, Maintainer and other fields of packages in indexes")
+	cmd.Flag.String("source-override-file", "", "apt-ftparchive style source override file to correct Priority, Section and Maintainer of source packages in indexes")
+	cmd.Flag.Bool("clear-overrides", false, "remove overrides of Priority, Section, Maintainer and other fields of binary and source packages")
+	cmd.Flag.Bool("publish-key", false, "publish public signing key next to Release file")
+	cmd.Flag.String("public-url", "", "URL published repository is served from, client configuration (.sources and .list) is published if set")
+	cmd.Flag.String("alias", "", "comma-separated list of distribution aliases to publish under as well, sharing index files (e.g. stable)")
+	cmd.Flag.String("replicas", "", "comma-separated list of additional published storages to publish to (e.g. s3:mirror,filesystem:backup)")
+	cmd.Flag.Var(&componentRulesFlag{}, "component-rule", "route packages matching query into component as 'component=query' (could be specified multiple times)")
+	cmd.Flag.Bool("clear-component-rules", false, "remove rules routing packages into components")
 	cmd.Flag.String("component", "", "component names to update (for multi-component publishing, separate components with commas)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
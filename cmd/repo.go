@@ -14,6 +14,7 @@ func makeCmdRepo() *commander.Command {
 			makeCmdRepoCreate(),
 			makeCmdRepoDrop(),
 			makeCmdRepoEdit(),
+			makeCmdRepoHold(),
 			makeCmdRepoImport(),
 			makeCmdRepoList(),
 			makeCmdRepoMove(),
@@ -22,6 +23,7 @@ func makeCmdRepo() *commander.Command {
 			makeCmdRepoRename(),
 			makeCmdRepoSearch(),
 			makeCmdRepoInclude(),
+			makeCmdRepoUnhold(),
 		},
 	}
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -39,16 +40,25 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
+	repo.HoldPackages(list)
 
-	forceReplace := context.Flags().Lookup("force-replace").Value.Get().(bool)
+	onConflict := context.Flags().Lookup("on-conflict").Value.String()
+	if !utils.StrSliceHasItem(deb.ImportConflictResolutions, onConflict) {
+		return fmt.Errorf("unable to add: unknown conflict resolution mode %s, supported: %s", onConflict,
+			strings.Join(deb.ImportConflictResolutions, ", "))
+	}
+	if context.Flags().Lookup("force-replace").Value.Get().(bool) {
+		onConflict = deb.ConflictReplace
+	}
 
 	var packageFiles, otherFiles, failedFiles []string
 
 	packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(args[1:], &aptly.ConsoleResultReporter{Progress: context.Progress()})
 
 	var processedFiles, failedFiles2 []string
+	var conflicts []deb.PackageConflict
 
-	processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
+	processedFiles, failedFiles2, conflicts, err = deb.ImportPackageFiles(list, packageFiles, onConflict, verifier, context.PackagePool(),
 		collectionFactory.PackageCollection(), &aptly.ConsoleResultReporter{Progress: context.Progress()}, nil,
 		collectionFactory.ChecksumCollection)
 	failedFiles = append(failedFiles, failedFiles2...)
@@ -76,6 +86,13 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	if len(conflicts) > 0 {
+		context.Progress().ColoredPrintf("@y[!]@| @!Conflicting packages:@|")
+		for _, conflict := range conflicts {
+			context.Progress().ColoredPrintf("  %s", conflict)
+		}
+	}
+
 	if len(failedFiles) > 0 {
 		context.Progress().ColoredPrintf("@y[!]@| @!Some files were skipped due to errors:@|")
 		for _, file := range failedFiles {
@@ -100,6 +117,12 @@ patterns. Every file discovered would be analyzed to extract metadata, package w
 to the database. Files would be imported to internal package pool. For source packages, all required files are
 added automatically as well. Extra files for source package should be in the same directory as *.dsc file.
 
+If repository already contains package with the same name, version and architecture, but different
+files, -on-conflict controls what happens: with -on-conflict=fail (default) such files are reported
+as failed, with -on-conflict=skip existing package is kept, with -on-conflict=replace existing package
+is replaced (same as -force-replace) and with -on-conflict=rename new package is added with version
+suffixed with +b1, +b2, ... All the conflicts are listed at the end.
+
 Example:
 
   $ aptly repo add testing myapp-0.1.2.deb incoming/
@@ -109,6 +132,8 @@ Example:
 
 	cmd.Flag.Bool("remove-files", false, "remove files that have been imported successfully into repository")
 	cmd.Flag.Bool("force-replace", false, "when adding package that conflicts with existing package, remove existing package")
+	cmd.Flag.String("on-conflict", deb.ConflictFail, "what to do when repository has different package with the same name, version and architecture: "+
+		strings.Join(deb.ImportConflictResolutions, ", "))
 
 	return cmd
 }
@@ -14,6 +14,11 @@ func makeCmdRepoCopy() *commander.Command {
 Command copy copies packages matching <package-query> from local repo
 <src-name> to local repo <dst-name>.
 
+If destination already contains package with the same name, version and
+architecture, but different files, command fails by default. With
+-on-conflict=skip such packages are skipped, with -on-conflict=replace
+package in destination is replaced.
+
 Example:
 
   $ aptly repo copy testing stable 'myapp (=0.1.12)'
@@ -23,6 +28,7 @@ Example:
 
 	cmd.Flag.Bool("dry-run", false, "don't copy, just show what would be copied")
 	cmd.Flag.Bool("with-deps", false, "follow dependencies when processing package-spec")
+	addConflictResolutionFlag(cmd)
 
 	return cmd
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -10,7 +11,7 @@ import (
 
 func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 	var err error
-	if !(len(args) == 1 || (len(args) == 4 && args[1] == "from" && args[2] == "snapshot")) { // nolint: goconst
+	if !(len(args) == 1 || (len(args) == 4 && args[1] == "from" && (args[2] == "snapshot" || args[2] == "directory"))) { // nolint: goconst
 		cmd.Usage()
 		return commander.ErrCommandError
 	}
@@ -27,8 +28,10 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	var failedFiles []string
+
 	collectionFactory := context.NewCollectionFactory()
-	if len(args) == 4 {
+	if len(args) == 4 && args[2] == "snapshot" {
 		var snapshot *deb.Snapshot
 
 		snapshot, err = collectionFactory.SnapshotCollection().ByName(args[3])
@@ -42,6 +45,36 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 		}
 
 		repo.UpdateRefList(snapshot.RefList())
+	} else if len(args) == 4 && args[2] == "directory" {
+		// check for duplicate name before importing the whole tree
+		if _, err = collectionFactory.LocalRepoCollection().ByName(repo.Name); err == nil {
+			return fmt.Errorf("unable to add local repo: local repo with name %s already exists", repo.Name)
+		}
+
+		root := args[3]
+		reporter := &aptly.ConsoleResultReporter{Progress: context.Progress()}
+		onConflict := deb.ConflictFail
+		if context.Flags().Lookup("force-replace").Value.Get().(bool) {
+			onConflict = deb.ConflictReplace
+		}
+
+		if components := deb.PoolComponents(root); len(components) == 1 && !context.Flags().IsSet("component") {
+			repo.DefaultComponent = components[0]
+		}
+
+		var packageFiles, failedFiles2 []string
+
+		packageFiles, _, failedFiles = deb.CollectPackageFiles([]string{root}, reporter)
+
+		list := deb.NewPackageList()
+		_, failedFiles2, _, err = deb.ImportPackageFiles(list, packageFiles, onConflict, context.GetVerifier(), context.PackagePool(),
+			collectionFactory.PackageCollection(), reporter, nil, collectionFactory.ChecksumCollection)
+		failedFiles = append(failedFiles, failedFiles2...)
+		if err != nil {
+			return fmt.Errorf("unable to import package files: %s", err)
+		}
+
+		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 	}
 
 	err = collectionFactory.LocalRepoCollection().Add(repo)
@@ -50,13 +83,23 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 	}
 
 	fmt.Printf("\nLocal repo %s successfully added.\nYou can run 'aptly repo add %s ...' to add packages to repository.\n", repo, repo.Name)
+
+	if len(failedFiles) > 0 {
+		context.Progress().ColoredPrintf("@y[!]@| @!Some files were skipped due to errors:@|")
+		for _, file := range failedFiles {
+			context.Progress().ColoredPrintf("  %s", file)
+		}
+
+		return fmt.Errorf("some files failed to be added")
+	}
+
 	return err
 }
 
 func makeCmdRepoCreate() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoCreate,
-		UsageLine: "create <name> [ from snapshot <snapshot> ]",
+		UsageLine: "create <name> [ from snapshot <snapshot> | from directory <directory> ]",
 		Short:     "create local repository",
 		Long: `
 Create local package repository. Repository would be empty when
@@ -66,11 +109,22 @@ another local repository or imported from the mirror.
 If local package repository is created from snapshot, repo initial
 contents are copied from snapsot contents.
 
+If local package repository is created from directory, directory is
+scanned recursively for package files (*.deb, *.udeb, *.dsc), which
+are imported into the package pool, so existing repository trees managed
+by reprepro, debarchiver or just a plain directory of packages could
+be converted into local repository. Identical packages found several
+times in the tree are stored only once. If the tree follows Debian pool/
+layout with single component, it is used as default component for
+publishing, unless -component is specified.
+
 Example:
 
   $ aptly repo create testing
 
   $ aptly repo create mysql35 from snapshot mysql-35-2017
+
+  $ aptly repo create legacy from directory /srv/reprepro/
 `,
 		Flag: *flag.NewFlagSet("aptly-repo-create", flag.ExitOnError),
 	}
@@ -79,6 +133,7 @@ Example:
 	cmd.Flag.String("distribution", "", "default distribution when publishing")
 	cmd.Flag.String("component", "main", "default component when publishing")
 	cmd.Flag.String("uploaders-file", "", "uploaders.json to be used when including .changes into this repository")
+	cmd.Flag.Bool("force-replace", false, "(only with from directory) when importing package that conflicts with already imported package, replace it")
 
 	return cmd
 }
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyRepoHoldUnhold(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	command := cmd.Name()
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.LocalRepoCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	context.Progress().Printf("Loading packages...\n")
+
+	list, err := deb.NewPackageListFromRefListWithFields(repo.RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	queries := make([]deb.PackageQuery, len(args)-1)
+	for i := 0; i < len(args)-1; i++ {
+		queries[i], err = query.ParseWithPackageSets(args[i+1], collectionFactory.PackageSetCollection())
+		if err != nil {
+			return fmt.Errorf("unable to %s: %s", command, err)
+		}
+		query.ResolveHeld(queries[i], repo)
+	}
+
+	list.PrepareIndex()
+	matching, err := list.Filter(queries, false, nil, 0, nil)
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	if matching.Len() == 0 {
+		return fmt.Errorf("unable to %s: no packages matching query", command)
+	}
+
+	matching.PrepareIndex()
+	changed := false
+	_ = matching.ForEachIndexed(func(p *deb.Package) error {
+		if command == "hold" { // nolint: goconst
+			if repo.Hold(p) {
+				changed = true
+				context.Progress().ColoredPrintf("@g[=]@| %s held", p)
+			} else {
+				context.Progress().ColoredPrintf("@y[!]@| %s is already held", p)
+			}
+		} else {
+			if repo.Unhold(p) {
+				changed = true
+				context.Progress().ColoredPrintf("@g[o]@| %s released", p)
+			} else {
+				context.Progress().ColoredPrintf("@y[!]@| %s is not held", p)
+			}
+		}
+		return nil
+	})
+
+	if !changed {
+		return nil
+	}
+
+	err = collectionFactory.LocalRepoCollection().Update(repo)
+	if err != nil {
+		return fmt.Errorf("unable to save: %s", err)
+	}
+
+	return nil
+}
+
+func makeCmdRepoHold() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoHoldUnhold,
+		UsageLine: "hold <name> <package-query> ...",
+		Short:     "hold package versions in local repository",
+		Long: `
+Command hold marks packages matching <package-query> in local repository
+<name> as held. While package version is held, other versions of the same
+package (and architecture) are skipped when packages are added, included,
+copied, moved or imported into the repository, so held version can't be
+replaced. Held package could still be removed from the repository explicitly.
+
+Held packages could be selected in queries against the repository with $Held,
+e.g. 'aptly repo search testing $Held'.
+
+Example:
+
+  $ aptly repo hold testing 'openssl (= 3.0.11-1~deb12u2)'
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-hold", flag.ExitOnError),
+	}
+
+	return cmd
+}
+
+func makeCmdRepoUnhold() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoHoldUnhold,
+		UsageLine: "unhold <name> <package-query> ...",
+		Short:     "release held package versions in local repository",
+		Long: `
+Command unhold releases hold of packages matching <package-query> in local
+repository <name>, so other versions of the packages could be added again.
+
+Example:
+
+  $ aptly repo unhold testing '$Held, openssl'
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-unhold", flag.ExitOnError),
+	}
+
+	return cmd
+}
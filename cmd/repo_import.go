@@ -14,6 +14,11 @@ func makeCmdRepoImport() *commander.Command {
 Command import looks up packages matching <package-query> in mirror <src-mirror>
 and copies them to local repo <dst-repo>.
 
+If destination already contains package with the same name, version and
+architecture, but different files, command fails by default. With
+-on-conflict=skip such packages are skipped, with -on-conflict=replace
+package in destination is replaced.
+
 Example:
 
   $ aptly repo import wheezy-main testing nginx
@@ -23,6 +28,7 @@ Example:
 
 	cmd.Flag.Bool("dry-run", false, "don't import, just show what would be imported")
 	cmd.Flag.Bool("with-deps", false, "follow dependencies when processing package-spec")
+	addConflictResolutionFlag(cmd)
 
 	return cmd
 }
@@ -18,7 +18,7 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
-	verifier, err := getVerifier(context.Flags())
+	verifier, err := getVerifier(context.Flags(), nil)
 	if err != nil {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
@@ -36,6 +36,9 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 	noRemoveFiles := context.Flags().Lookup("no-remove-files").Value.Get().(bool)
 	repoTemplateString := context.Flags().Lookup("repo").Value.Get().(string)
 	collectionFactory := context.NewCollectionFactory()
+	parseQuery := func(q string) (deb.PackageQuery, error) {
+		return query.ParseWithPackageSets(q, collectionFactory.PackageSetCollection())
+	}
 
 	var repoTemplate *template.Template
 	repoTemplate, err = template.New("repo").Parse(repoTemplateString)
@@ -52,7 +55,7 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 		}
 
 		for i := range uploaders.Rules {
-			uploaders.Rules[i].CompiledCondition, err = query.Parse(uploaders.Rules[i].Condition)
+			uploaders.Rules[i].CompiledCondition, err = parseQuery(uploaders.Rules[i].Condition)
 			if err != nil {
 				return fmt.Errorf("error parsing query %s: %s", uploaders.Rules[i].Condition, err)
 			}
@@ -68,7 +71,7 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 		changesFiles, reporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles, verifier, repoTemplate,
 		context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
 		context.PackagePool(), collectionFactory.ChecksumCollection,
-		uploaders, query.Parse)
+		uploaders, parseQuery)
 	failedFiles = append(failedFiles, failedFiles2...)
 
 	if len(failedFiles) > 0 {
@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -79,6 +81,7 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
+	dstRepo.HoldPackages(dstList)
 
 	srcList, err := deb.NewPackageListFromRefList(srcRefList, collectionFactory.PackageCollection(), context.Progress())
 	if err != nil {
@@ -108,12 +111,21 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	onConflict := context.Flags().Lookup("on-conflict").Value.String()
+	if !utils.StrSliceHasItem(deb.ConflictResolutions, onConflict) {
+		return fmt.Errorf("unable to %s: unknown conflict resolution mode %s, supported: %s", command, onConflict,
+			strings.Join(deb.ConflictResolutions, ", "))
+	}
+
 	queries := make([]deb.PackageQuery, len(args)-2)
 	for i := 0; i < len(args)-2; i++ {
-		queries[i], err = query.Parse(args[i+2])
+		queries[i], err = query.ParseWithPackageSets(args[i+2], collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to %s: %s", command, err)
 		}
+		if srcRepo != nil {
+			query.ResolveHeld(queries[i], srcRepo)
+		}
 	}
 
 	toProcess, err := srcList.FilterWithProgress(queries, withDeps, dstList, context.DependencyOptions(), architecturesList, context.Progress())
@@ -132,11 +144,25 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	}
 
 	err = toProcess.ForEach(func(p *deb.Package) error {
-		err = dstList.Add(p)
+		if held := dstList.HeldFor(p); held != nil {
+			context.Progress().ColoredPrintf("@y[!]@| %s skipped, as %s is held in destination", p, held)
+			return nil
+		}
+
+		var conflicting *deb.Package
+		conflicting, err = dstList.AddWithConflictResolution(p, onConflict)
 		if err != nil {
 			return err
 		}
 
+		if conflicting != nil {
+			if onConflict == deb.ConflictSkip {
+				context.Progress().ColoredPrintf("@y[!]@| %s skipped due to conflict with package in destination", p)
+				return nil
+			}
+			context.Progress().ColoredPrintf("@r[-]@| %s removed due to conflict with package being %s", conflicting, verb)
+		}
+
 		if command == "move" { // nolint: goconst
 			srcList.Remove(p)
 		}
@@ -170,6 +196,12 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// addConflictResolutionFlag adds flag controlling handling of packages conflicting with destination
+func addConflictResolutionFlag(cmd *commander.Command) {
+	cmd.Flag.String("on-conflict", deb.ConflictFail, "what to do when destination has different package with the same name, version and architecture: "+
+		strings.Join(deb.ConflictResolutions, ", "))
+}
+
 func makeCmdRepoMove() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoMoveCopyImport,
@@ -179,6 +211,11 @@ func makeCmdRepoMove() *commander.Command {
 Command move moves packages matching <package-query> from local repo
 <src-name> to local repo <dst-name>.
 
+If destination already contains package with the same name, version and
+architecture, but different files, command fails by default. With
+-on-conflict=skip such packages are left in the source, with
+-on-conflict=replace package in destination is replaced.
+
 Example:
 
   $ aptly repo move testing stable 'myapp (=0.1.12)'
@@ -188,6 +225,7 @@ Example:
 
 	cmd.Flag.Bool("dry-run", false, "don't move, just show what would be moved")
 	cmd.Flag.Bool("with-deps", false, "follow dependencies when processing package-spec")
+	addConflictResolutionFlag(cmd)
 
 	return cmd
 }
@@ -38,10 +38,11 @@ func aptlyRepoRemove(cmd *commander.Command, args []string) error {
 
 	queries := make([]deb.PackageQuery, len(args)-1)
 	for i := 0; i < len(args)-1; i++ {
-		queries[i], err = query.Parse(args[i+1])
+		queries[i], err = query.ParseWithPackageSets(args[i+1], collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to remove: %s", err)
 		}
+		query.ResolveHeld(queries[i], repo)
 	}
 
 	list.PrepareIndex()
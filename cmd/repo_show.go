@@ -49,6 +49,9 @@ func aptlyRepoShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Uploaders: %s\n", repo.Uploaders)
 	}
 	fmt.Printf("Number of packages: %d\n", repo.NumPackages())
+	if len(repo.Held) > 0 {
+		fmt.Printf("Held packages: %d\n", len(repo.Held))
+	}
 
 	withPackages := context.Flags().Lookup("with-packages").Value.Get().(bool)
 	if withPackages {
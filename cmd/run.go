@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -38,7 +39,21 @@ func Run(cmd *commander.Command, cmdArgs []string, initContext bool) (returnCode
 
 	context.UpdateFlags(flags)
 
+	auditEntry, auditRecorder := startAudit(cmd, flags, args)
+	defer func() {
+		// commands might abort with fatal error, which should be recorded as well
+		if r := recover(); r != nil {
+			if fatal, ok := r.(*ctx.FatalError); ok {
+				finishAudit(auditEntry, auditRecorder, errors.New(fatal.Message))
+			}
+			panic(r)
+		}
+	}()
+
 	err = cmd.Dispatch(args)
+	finishAudit(auditEntry, auditRecorder, err)
+	auditEntry = nil
+
 	if err != nil {
 		ctx.Fatal(err)
 	}
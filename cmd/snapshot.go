@@ -17,9 +17,15 @@ func makeCmdSnapshot() *commander.Command {
 			makeCmdSnapshotDiff(),
 			makeCmdSnapshotMerge(),
 			makeCmdSnapshotDrop(),
+			makeCmdSnapshotPrune(),
 			makeCmdSnapshotRename(),
 			makeCmdSnapshotSearch(),
 			makeCmdSnapshotFilter(),
+			makeCmdSnapshotRemove(),
+			makeCmdSnapshotExport(),
+			makeCmdSnapshotSign(),
+			makeCmdSnapshotManifest(),
+			makeCmdSnapshotVerifySignature(),
 		},
 	}
 }
@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
+	"github.com/smira/flag"
 )
 
 func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
@@ -59,6 +63,12 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create snapshot: %s", err)
 		}
+	} else if len(args) >= 5 && args[1] == "from" && args[2] == "query" {
+		// aptly snapshot create snap from query query source ...
+		snapshot, err = aptlySnapshotCreateFromQuery(collectionFactory, args[0], args[3], args[4:])
+		if err != nil {
+			return fmt.Errorf("unable to create snapshot: %s", err)
+		}
 	} else if len(args) == 2 && args[1] == "empty" {
 		// aptly snapshot create snap empty
 		snapshotName := args[0]
@@ -71,20 +81,77 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	if description := context.Flags().Lookup("description").Value.String(); description != "" {
+		snapshot.Description = description
+	}
+	snapshot.Provenance = context.Flags().Lookup("provenance").Value.String()
+
 	err = collectionFactory.SnapshotCollection().Add(snapshot)
 	if err != nil {
 		return fmt.Errorf("unable to add snapshot: %s", err)
 	}
 
+	context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": snapshot}, nil)
+
 	fmt.Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", snapshot.Name, snapshot.Name)
 
 	return err
 }
 
+// aptlySnapshotCreateFromQuery builds snapshot out of packages matching query across sources
+func aptlySnapshotCreateFromQuery(collectionFactory *deb.CollectionFactory, name string, queryString string, specs []string) (*deb.Snapshot, error) {
+	withDeps := context.Flags().Lookup("with-deps").Value.Get().(bool)
+
+	q, err := query.ParseWithPackageSets(queryString, collectionFactory.PackageSetCollection())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query: %s", err)
+	}
+
+	sources := make([]*deb.SnapshotSource, len(specs))
+	for i, spec := range specs {
+		sources[i], err = deb.LoadSnapshotSource(collectionFactory, spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	refList := deb.MergeSnapshotSources(sources)
+
+	context.Progress().Printf("Loading packages (%d)...\n", refList.Len())
+	packageList, err := deb.NewPackageListFromRefListWithFields(refList, collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
+	if err != nil {
+		return nil, fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	context.Progress().Printf("Building indexes...\n")
+	packageList.PrepareIndex()
+
+	var architecturesList []string
+	if len(context.ArchitecturesList()) > 0 {
+		architecturesList = context.ArchitecturesList()
+	} else {
+		architecturesList = packageList.Architectures(false)
+	}
+	sort.Strings(architecturesList)
+
+	if len(architecturesList) == 0 && withDeps {
+		return nil, fmt.Errorf("unable to determine list of architectures, please specify explicitly")
+	}
+
+	result, err := packageList.FilterWithProgress([]deb.PackageQuery{q}, withDeps, nil, context.DependencyOptions(), architecturesList, context.Progress())
+	if err != nil {
+		return nil, err
+	}
+
+	context.Progress().Printf("Packages matching query: %d\n", result.Len())
+
+	return deb.NewSnapshotFromQuery(name, queryString, sources, result), nil
+}
+
 func makeCmdSnapshotCreate() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlySnapshotCreate,
-		UsageLine: "create <name> from mirror <mirror-name> | from repo <repo-name> | empty",
+		UsageLine: "create <name> from mirror <mirror-name> | from repo <repo-name> | from query <package-query> <source> ... | empty",
 		Short:     "creates snapshot of mirror (local repository) contents",
 		Long: `
 Command create <name> from mirror makes persistent immutable snapshot of remote
@@ -95,6 +162,14 @@ Command create <name> from repo makes persistent immutable snapshot of local
 repository. Snapshot could be processed as mirror snapshots, and mixed with
 snapshots of remote mirrors.
 
+Command create <name> from query makes snapshot of packages matching
+<package-query> across several sources, each source is specified as
+mirror:<name>, repo:<name> or snapshot:<name>. If the same package comes
+from several sources with different files, package from the last source wins.
+Query could reference package sets ('$PackageSet (<name>)'), so that
+snapshots could be built repeatedly from the same saved query. With -with-deps,
+dependencies of matching packages are included as well.
+
 Command create <name> empty creates empty snapshot that could be used as a
 basis for snapshot pull operations, for example. As snapshots are immutable,
 creating one empty snapshot should be enough.
@@ -102,9 +177,18 @@ creating one empty snapshot should be enough.
 Example:
 
   $ aptly snapshot create wheezy-main-today from mirror wheezy-main
+
+  $ aptly snapshot create -provenance="ci build #421" app-1.2 from repo app-stage
+
+  $ aptly snapshot create kernels from query '$PackageSet (production-kernels)' mirror:bookworm-main repo:kernels-stage
 `,
+		Flag: *flag.NewFlagSet("aptly-snapshot-create", flag.ExitOnError),
 	}
 
+	cmd.Flag.String("description", "", "custom description of the snapshot (defaults to description of the source)")
+	cmd.Flag.String("provenance", "", "free-form record of what snapshot was built from")
+	cmd.Flag.Bool("with-deps", false, "(only with from query) include dependent packages as well")
+
 	return cmd
 
 }
@@ -22,32 +22,33 @@ func aptlySnapshotDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
-	published := collectionFactory.PublishedRepoCollection().BySnapshot(snapshot)
+	refs := collectionFactory.SnapshotReferences(snapshot)
 
-	if len(published) > 0 {
+	if len(refs.Published) > 0 {
 		fmt.Printf("Snapshot `%s` is published currently:\n", snapshot.Name)
-		for _, repo := range published {
+		for _, repo := range refs.Published {
 			err = collectionFactory.PublishedRepoCollection().LoadComplete(repo, collectionFactory)
 			if err != nil {
 				return fmt.Errorf("unable to load published: %s", err)
 			}
 			fmt.Printf(" * %s\n", repo)
 		}
-
-		return fmt.Errorf("unable to drop: snapshot is published")
 	}
 
 	force := context.Flags().Lookup("force").Value.Get().(bool)
-	if !force {
-		snapshots := collectionFactory.SnapshotCollection().BySnapshotSource(snapshot)
-		if len(snapshots) > 0 {
-			fmt.Printf("Snapshot `%s` was used as a source in following snapshots:\n", snapshot.Name)
-			for _, snap := range snapshots {
-				fmt.Printf(" * %s\n", snap)
-			}
+	if len(refs.Published) == 0 && len(refs.Snapshots) > 0 && !force {
+		fmt.Printf("Snapshot `%s` was used as a source in following snapshots:\n", snapshot.Name)
+		for _, snap := range refs.Snapshots {
+			fmt.Printf(" * %s\n", snap)
+		}
+	}
 
-			return fmt.Errorf("won't delete snapshot that was used as source for other snapshots, use -force to override")
+	err = refs.CheckDrop(force)
+	if err != nil {
+		if len(refs.Published) > 0 {
+			return fmt.Errorf("unable to drop: %s", err)
 		}
+		return fmt.Errorf("%s, use -force to override", err)
 	}
 
 	err = collectionFactory.SnapshotCollection().Drop(snapshot)
@@ -67,7 +68,13 @@ func makeCmdSnapshotDrop() *commander.Command {
 		Short:     "delete snapshot",
 		Long: `
 Drop removes information about a snapshot. If snapshot is published,
-it can't be dropped.
+it can't be dropped, even with -force: published repository should be
+switched to another snapshot or dropped first. Snapshot which was used as
+a source for other snapshots (merge, pull, filter) is dropped only with -force.
+
+Packages of the dropped snapshot are removed from the database by
+'aptly db cleanup' only when no other mirror, local repository, snapshot or
+published repository references them.
 
 Example:
 
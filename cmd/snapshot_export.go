@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// exportStorageProvider provides the only published storage, export target
+type exportStorageProvider struct {
+	storage aptly.PublishedStorage
+}
+
+func (provider *exportStorageProvider) GetPublishedStorage(name string) aptly.PublishedStorage {
+	return provider.storage
+}
+
+func aptlySnapshotExport(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name, destination := args[0], args[1]
+	asTar := context.Flags().Lookup("tar").Value.Get().(bool)
+
+	var volumeSize int64
+	if volumeSizeFlag := context.Flags().Lookup("volume-size").Value.String(); volumeSizeFlag != "" {
+		if !asTar {
+			return fmt.Errorf("unable to export: -volume-size requires -tar")
+		}
+
+		volumeSize, err = utils.ParseHumanBytes(volumeSizeFlag)
+		if err != nil {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	published, err := deb.NewPublishedRepo("", ".", context.Flags().Lookup("distribution").Value.String(),
+		context.ArchitecturesList(), []string{context.Flags().Lookup("component").Value.String()},
+		[]interface{}{snapshot}, collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+	published.Origin = context.Flags().Lookup("origin").Value.String()
+	published.Label = context.Flags().Lookup("label").Value.String()
+	published.SkipContents = context.Config().SkipContentsPublishing
+	if context.Flags().IsSet("skip-contents") {
+		published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+	}
+	published.SkipBz2 = context.Config().SkipBz2Publishing
+	if context.Flags().IsSet("skip-bz2") {
+		published.SkipBz2 = context.Flags().Lookup("skip-bz2").Value.Get().(bool)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	// repository tree is built in temporary directory next to the tarball
+	root := destination
+	if asTar {
+		root, err = os.MkdirTemp(filepath.Dir(destination), ".aptly-export-")
+		if err != nil {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+		defer os.RemoveAll(root)
+	} else {
+		var entries []os.DirEntry
+		entries, err = os.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("unable to export: directory %s is not empty", root)
+		}
+	}
+
+	provider := &exportStorageProvider{storage: files.NewPublishedStorage(root, "copy", "")}
+	err = published.Publish(context.PackagePool(), provider, collectionFactory, signer, context.Progress(), false, false)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	if asTar {
+		context.Progress().Printf("Writing tarball...\n")
+
+		writer := utils.NewVolumeWriter(destination, volumeSize)
+		err = utils.TarDirectory(root, writer)
+		if err == nil {
+			err = writer.Close()
+		} else {
+			_ = writer.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+
+		context.Progress().Printf("\nSnapshot %s has been exported to %s.\n", snapshot.Name, strings.Join(writer.Files(), ", "))
+		if volumeSize > 0 {
+			context.Progress().Printf("Volumes could be joined with: cat %s.* | tar -x\n", destination)
+		}
+	} else {
+		context.Progress().Printf("\nSnapshot %s has been exported to %s.\n", snapshot.Name, destination)
+	}
+
+	context.Progress().Printf("Now you can add following line to apt sources on target system:\n")
+	context.Progress().Printf("  deb file:/path/to/export %s %s\n", published.Distribution, strings.Join(published.Components(), " "))
+
+	return err
+}
+
+func makeCmdSnapshotExport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotExport,
+		UsageLine: "export <name> <destination>",
+		Short:     "export snapshot as standalone repository",
+		Long: `
+Command export writes snapshot <name> as complete standalone repository
+(indexes, signed Release files and package files) into directory <destination>,
+so that it could be copied to offline media for air-gapped systems. Directory
+should be empty or missing.
+
+With -tar, repository is written as tarball <destination> instead; with
+-volume-size (e.g. 4480MB for DVD), tarball is split into volumes
+<destination>.001, <destination>.002, ... of at most that size, which could be
+joined back with cat.
+
+Example:
+
+    $ aptly snapshot export -tar -volume-size=4480MB wheezy-main /media/wheezy.tar
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-export", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("distribution", "", "distribution name to export")
+	cmd.Flag.String("component", "", "component name to export")
+	cmd.Flag.String("origin", "", "origin name to publish")
+	cmd.Flag.String("label", "", "label to publish")
+	cmd.Flag.Bool("tar", false, "write repository as tarball <destination>")
+	cmd.Flag.String("volume-size", "", "split tarball into volumes of this size (e.g. 4480MB, 2GiB)")
+	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
+	cmd.Flag.Bool("skip-bz2", false, "don't generate bzipped indexes")
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for Release signatures: SHA256 (default), SHA384 or SHA512")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+
+	return cmd
+}
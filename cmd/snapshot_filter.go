@@ -7,6 +7,7 @@ import (
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -34,7 +35,7 @@ func aptlySnapshotFilter(cmd *commander.Command, args []string) error {
 
 	// Convert snapshot to package list
 	context.Progress().Printf("Loading packages (%d)...\n", source.RefList().Len())
-	packageList, err := deb.NewPackageListFromRefList(source.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	packageList, err := deb.NewPackageListFromRefListWithFields(source.RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
 	if err != nil {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
@@ -60,7 +61,7 @@ func aptlySnapshotFilter(cmd *commander.Command, args []string) error {
 	// Initial queries out of arguments
 	queries := make([]deb.PackageQuery, len(args)-2)
 	for i, arg := range args[2:] {
-		queries[i], err = query.Parse(arg)
+		queries[i], err = query.ParseWithPackageSets(arg, collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to parse query: %s", err)
 		}
@@ -81,6 +82,8 @@ func aptlySnapshotFilter(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to create snapshot: %s", err)
 	}
 
+	context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": destination}, nil)
+
 	context.Progress().Printf("\nSnapshot %s successfully filtered.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
 
 	return err
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotManifest(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to show manifest: %s", err)
+	}
+
+	if context.Flags().Lookup("signature").Value.Get().(bool) {
+		if snapshot.ManifestSignature == "" {
+			return fmt.Errorf("unable to show manifest signature: snapshot %s is not signed", snapshot.Name)
+		}
+
+		_, err = os.Stdout.WriteString(snapshot.ManifestSignature)
+		return err
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to show manifest: %s", err)
+	}
+
+	manifest, err := snapshot.Manifest(collectionFactory.PackageCollection())
+	if err != nil {
+		return fmt.Errorf("unable to show manifest: %s", err)
+	}
+
+	_, err = os.Stdout.Write(manifest)
+	return err
+}
+
+func makeCmdSnapshotManifest() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotManifest,
+		UsageLine: "manifest <name>",
+		Short:     "print snapshot manifest or its signature",
+		Long: `
+Command manifest prints manifest of the snapshot (metadata and list of packages
+with versions, architectures and SHA256 checksums), with -signature detached
+signature of the manifest created with 'aptly snapshot sign' is printed instead.
+
+Example:
+
+  $ aptly snapshot manifest wheezy-main > wheezy-main.manifest
+  $ aptly snapshot manifest -signature wheezy-main > wheezy-main.manifest.asc
+  $ gpgv wheezy-main.manifest.asc wheezy-main.manifest
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-manifest", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("signature", false, "print detached signature of the manifest")
+
+	return cmd
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
 	"github.com/smira/commander"
 )
 
@@ -39,9 +41,52 @@ func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 
 	overrideMatching := !latest && !noRemove
 
-	result := sources[0].RefList()
-	for i := 1; i < len(sources); i++ {
-		result = result.Merge(sources[i].RefList(), overrideMatching, false)
+	extraSourceOnly := context.Flags().Lookup("extra-source-only").Value.String()
+	if extraSourceOnly != deb.ExtraSourceOnlyRetain && extraSourceOnly != deb.ExtraSourceOnlyDrop {
+		return fmt.Errorf("unknown mode for Extra-Source-Only packages: %s, should be one of %s, %s", extraSourceOnly,
+			deb.ExtraSourceOnlyRetain, deb.ExtraSourceOnlyDrop)
+	}
+
+	onConflict := context.Flags().Lookup("on-conflict").Value.String()
+	if !utils.StrSliceHasItem(deb.ImportConflictResolutions, onConflict) {
+		return fmt.Errorf("unknown conflict resolution mode %s, supported: %s", onConflict,
+			strings.Join(deb.ImportConflictResolutions, ", "))
+	}
+
+	refLists := make([]*deb.PackageRefList, len(sources))
+	for i := range sources {
+		refLists[i] = sources[i].RefList()
+		if extraSourceOnly == deb.ExtraSourceOnlyDrop {
+			refLists[i], err = refLists[i].WithoutExtraSourceOnly(collectionFactory.PackageCollection())
+			if err != nil {
+				return fmt.Errorf("unable to merge: %s", err)
+			}
+		}
+	}
+
+	var conflicts []deb.PackageConflict
+
+	result := refLists[0]
+	for i := 1; i < len(refLists); i++ {
+		var (
+			right          *deb.PackageRefList
+			mergeConflicts []deb.PackageConflict
+		)
+
+		right, mergeConflicts, err = deb.ResolveMergeConflicts(result, refLists[i], onConflict, collectionFactory.PackageCollection())
+		if err != nil {
+			return fmt.Errorf("unable to merge: %s", err)
+		}
+		conflicts = append(conflicts, mergeConflicts...)
+
+		result = result.Merge(right, overrideMatching, false)
+	}
+
+	if len(conflicts) > 0 {
+		context.Progress().ColoredPrintf("@y[!]@| @!Conflicting packages:@|")
+		for _, conflict := range conflicts {
+			context.Progress().ColoredPrintf("  %s", conflict)
+		}
 	}
 
 	if latest {
@@ -62,6 +107,8 @@ func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to create snapshot: %s", err)
 	}
 
+	context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": destination}, nil)
+
 	fmt.Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
 
 	return err
@@ -79,6 +126,17 @@ name-architecture pair are replaced during merge (package from latest snapshot
 on the list wins).  If run with only one source snapshot, merge copies <source> into
 <destination>.
 
+Source packages marked with Extra-Source-Only: yes are listed in the archive only
+because other packages are built using them, with -extra-source-only=drop such
+packages are removed from every source snapshot before merge, so that they don't
+replace regular source packages and are not published.
+
+Packages with the same name, version and architecture, but different files are
+conflicting, -on-conflict controls how such conflicts are resolved: replace (default,
+package from latest snapshot on the list wins), skip (package from earlier snapshot
+is kept), rename (package from latest snapshot is added with version suffixed with
++b1, +b2, ...) or fail (merge is aborted). All the conflicts are listed.
+
 Example:
 
     $ aptly snapshot merge wheezy-w-backports wheezy-main wheezy-backports
@@ -87,6 +145,9 @@ Example:
 
 	cmd.Flag.Bool("latest", false, "use only the latest version of each package")
 	cmd.Flag.Bool("no-remove", false, "don't remove duplicate arch/name packages")
+	cmd.Flag.String("extra-source-only", deb.ExtraSourceOnlyRetain, "how to handle source packages marked with Extra-Source-Only: retain or drop")
+	cmd.Flag.String("on-conflict", deb.ConflictReplace, "what to do with packages with the same name, version and architecture, but different files: "+
+		strings.Join(deb.ImportConflictResolutions, ", "))
 
 	return cmd
 }
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotPrune(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	policy := &deb.SnapshotRetentionPolicy{
+		KeepLast:   context.Flags().Lookup("keep-last").Value.Get().(int),
+		KeepWithin: time.Duration(context.Flags().Lookup("keep-days").Value.Get().(int)) * 24 * time.Hour,
+	}
+
+	if policy.KeepLast <= 0 && policy.KeepWithin <= 0 {
+		return fmt.Errorf("unable to prune: at least one of -keep-last or -keep-days should be specified")
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshots := []*deb.Snapshot{}
+	err = collection.ForEach(func(snapshot *deb.Snapshot) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	candidates := policy.Candidates(snapshots, time.Now())
+	toDrop := map[string]bool{}
+
+	for _, snapshot := range candidates {
+		published := collectionFactory.PublishedRepoCollection().BySnapshot(snapshot)
+		if len(published) > 0 {
+			fmt.Printf("Snapshot `%s` is kept: it is published currently.\n", snapshot.Name)
+			continue
+		}
+
+		toDrop[snapshot.UUID] = true
+	}
+
+	// snapshots used as sources are kept as long as some snapshot which stays refers to them
+	for changed := true; changed; {
+		changed = false
+
+		for _, snapshot := range candidates {
+			if !toDrop[snapshot.UUID] {
+				continue
+			}
+
+			for _, user := range collection.BySnapshotSource(snapshot) {
+				if !toDrop[user.UUID] {
+					fmt.Printf("Snapshot `%s` is kept: it was used as a source in snapshot `%s`.\n", snapshot.Name, user.Name)
+					delete(toDrop, snapshot.UUID)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	if len(toDrop) == 0 {
+		fmt.Printf("No snapshots to prune.\n")
+		return nil
+	}
+
+	// drop newest snapshots first, so that sources are dropped after snapshots referring to them
+	for i := len(candidates) - 1; i >= 0; i-- {
+		snapshot := candidates[i]
+		if !toDrop[snapshot.UUID] {
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Snapshot `%s` would be dropped.\n", snapshot.Name)
+			continue
+		}
+
+		err = collection.Drop(snapshot)
+		if err != nil {
+			return fmt.Errorf("unable to drop %s: %s", snapshot.Name, err)
+		}
+
+		fmt.Printf("Snapshot `%s` has been dropped.\n", snapshot.Name)
+	}
+
+	if dryRun {
+		fmt.Printf("\nChanges not saved, as dry run has been requested.\n")
+	}
+
+	return err
+}
+
+func makeCmdSnapshotPrune() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotPrune,
+		UsageLine: "prune",
+		Short:     "drop snapshots according to retention policy",
+		Long: `
+Command prune drops snapshots which aren't retained by any of the rules:
+-keep-last keeps N most recent snapshots created from each source (mirror,
+local repo or set of snapshots), -keep-days keeps snapshots created within
+the last N days.
+
+Snapshots which are published or were used as a source for snapshots which
+are not dropped are always kept.
+
+Example:
+
+    $ aptly snapshot prune -keep-last=5 -keep-days=90
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-prune", flag.ExitOnError),
+	}
+
+	cmd.Flag.Int("keep-last", 0, "keep N most recent snapshots per source")
+	cmd.Flag.Int("keep-days", 0, "keep snapshots created within last N days")
+	cmd.Flag.Bool("dry-run", false, "don't drop snapshots, just show what would be dropped")
+
+	return cmd
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/webhook"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotRemove(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 3 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	// Load <source> snapshot
+	source, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to remove: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(source)
+	if err != nil {
+		return fmt.Errorf("unable to remove: %s", err)
+	}
+
+	context.Progress().Printf("Loading packages (%d)...\n", source.RefList().Len())
+	list, err := deb.NewPackageListFromRefList(source.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	queries := make([]deb.PackageQuery, len(args)-2)
+	for i, arg := range args[2:] {
+		queries[i], err = query.ParseWithPackageSets(arg, collectionFactory.PackageSetCollection())
+		if err != nil {
+			return fmt.Errorf("unable to parse query: %s", err)
+		}
+	}
+
+	list.PrepareIndex()
+	toRemove, err := list.Filter(queries, false, nil, 0, nil)
+	if err != nil {
+		return fmt.Errorf("unable to remove: %s", err)
+	}
+
+	err = toRemove.ForEach(func(p *deb.Package) error {
+		list.Remove(p)
+		context.Progress().ColoredPrintf("@r[-]@| %s removed", p)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to remove: %s", err)
+	}
+
+	if context.Flags().Lookup("dry-run").Value.Get().(bool) {
+		context.Progress().Printf("\nNot creating snapshot, as dry run was requested.\n")
+		return nil
+	}
+
+	// Create <destination> snapshot
+	destination := deb.NewSnapshotFromPackageList(args[1], []*deb.Snapshot{source}, list,
+		fmt.Sprintf("Removed packages from '%s', query was: '%s'", source.Name, strings.Join(args[2:], " ")))
+
+	err = collectionFactory.SnapshotCollection().Add(destination)
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
+	context.Notify(webhook.EventSnapshotCreated, map[string]interface{}{"snapshot": destination}, nil)
+
+	context.Progress().Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
+
+	return err
+}
+
+func makeCmdSnapshotRemove() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotRemove,
+		UsageLine: "remove <source> <destination> <package-query> ...",
+		Short:     "remove packages from snapshot producing another snapshot",
+		Long: `
+As snapshots are immutable, command remove produces new snapshot <destination>
+which contains all the packages from snapshot <source> except for packages
+matching <package-query>. Source snapshot is recorded as the origin of the
+new snapshot.
+
+Example:
+
+    $ aptly snapshot remove wheezy-main wheezy-main-nodbg 'Name (% *-dbg)'
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-remove", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't create destination snapshot, just show what would be removed")
+
+	return cmd
+}
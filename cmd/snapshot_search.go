@@ -25,7 +25,10 @@ func aptlySnapshotMirrorRepoSearch(cmd *commander.Command, args []string) error
 	command := cmd.Parent.Name()
 	collectionFactory := context.NewCollectionFactory()
 
-	var reflist *deb.PackageRefList
+	var (
+		reflist   *deb.PackageRefList
+		localRepo *deb.LocalRepo
+	)
 
 	if command == "snapshot" { // nolint: goconst
 		var snapshot *deb.Snapshot
@@ -66,11 +69,12 @@ func aptlySnapshotMirrorRepoSearch(cmd *commander.Command, args []string) error
 		}
 
 		reflist = repo.RefList()
+		localRepo = repo
 	} else {
 		panic("unknown command")
 	}
 
-	list, err := deb.NewPackageListFromRefList(reflist, collectionFactory.PackageCollection(), context.Progress())
+	list, err := deb.NewPackageListFromRefListWithFields(reflist, collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
 	if err != nil {
 		return fmt.Errorf("unable to search: %s", err)
 	}
@@ -78,10 +82,13 @@ func aptlySnapshotMirrorRepoSearch(cmd *commander.Command, args []string) error
 	list.PrepareIndex()
 
 	if len(args) == 2 {
-		q, err = query.Parse(args[1])
+		q, err = query.ParseWithPackageSets(args[1], collectionFactory.PackageSetCollection())
 		if err != nil {
 			return fmt.Errorf("unable to search: %s", err)
 		}
+		if localRepo != nil {
+			query.ResolveHeld(q, localRepo)
+		}
 	} else {
 		q = &deb.MatchAllQuery{}
 	}
@@ -113,6 +120,10 @@ func aptlySnapshotMirrorRepoSearch(cmd *commander.Command, args []string) error
 		return fmt.Errorf("no results")
 	}
 
+	if context.Flags().Lookup("json").Value.Get().(bool) {
+		return PrintPackageListJSON(result)
+	}
+
 	format := context.Flags().Lookup("format").Value.String()
 	PrintPackageList(result, format, "")
 
@@ -138,6 +149,7 @@ Example:
 
 	cmd.Flag.Bool("with-deps", false, "include dependencies into search results")
 	cmd.Flag.String("format", "", "custom format for result printing")
+	cmd.Flag.Bool("json", false, "display list in JSON format")
 
 	return cmd
 }
@@ -43,28 +43,37 @@ func aptlySnapshotShowTxt(_ *commander.Command, args []string) error {
 	fmt.Printf("Name: %s\n", snapshot.Name)
 	fmt.Printf("Created At: %s\n", snapshot.CreatedAt.Format("2006-01-02 15:04:05 MST"))
 	fmt.Printf("Description: %s\n", snapshot.Description)
+	if snapshot.Provenance != "" {
+		fmt.Printf("Provenance: %s\n", snapshot.Provenance)
+	}
+	if snapshot.Query != "" {
+		fmt.Printf("Query: %s\n", snapshot.Query)
+	}
 	fmt.Printf("Number of packages: %d\n", snapshot.NumPackages())
+	if snapshot.ManifestSignature != "" {
+		fmt.Printf("Manifest signed: yes\n")
+	}
 	if len(snapshot.SourceIDs) > 0 {
 		fmt.Printf("Sources:\n")
-		for _, sourceID := range snapshot.SourceIDs {
+		for _, sourceRef := range snapshot.Sources() {
 			var name string
-			if snapshot.SourceKind == deb.SourceSnapshot {
+			if sourceRef.Kind == deb.SourceSnapshot {
 				var source *deb.Snapshot
-				source, err = collectionFactory.SnapshotCollection().ByUUID(sourceID)
+				source, err = collectionFactory.SnapshotCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
 				name = source.Name
-			} else if snapshot.SourceKind == deb.SourceLocalRepo {
+			} else if sourceRef.Kind == deb.SourceLocalRepo {
 				var source *deb.LocalRepo
-				source, err = collectionFactory.LocalRepoCollection().ByUUID(sourceID)
+				source, err = collectionFactory.LocalRepoCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
 				name = source.Name
-			} else if snapshot.SourceKind == deb.SourceRemoteRepo {
+			} else if sourceRef.Kind == deb.SourceRemoteRepo {
 				var source *deb.RemoteRepo
-				source, err = collectionFactory.RemoteRepoCollection().ByUUID(sourceID)
+				source, err = collectionFactory.RemoteRepoCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
@@ -72,7 +81,7 @@ func aptlySnapshotShowTxt(_ *commander.Command, args []string) error {
 			}
 
 			if name != "" {
-				fmt.Printf("  %s [%s]\n", name, snapshot.SourceKind)
+				fmt.Printf("  %s [%s]\n", name, sourceRef.Kind)
 			}
 		}
 	}
@@ -102,24 +111,24 @@ func aptlySnapshotShowJSON(_ *commander.Command, args []string) error {
 
 	// include the sources
 	if len(snapshot.SourceIDs) > 0 {
-		for _, sourceID := range snapshot.SourceIDs {
-			if snapshot.SourceKind == deb.SourceSnapshot {
+		for _, sourceRef := range snapshot.Sources() {
+			if sourceRef.Kind == deb.SourceSnapshot {
 				var source *deb.Snapshot
-				source, err = context.NewCollectionFactory().SnapshotCollection().ByUUID(sourceID)
+				source, err = context.NewCollectionFactory().SnapshotCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
 				snapshot.Snapshots = append(snapshot.Snapshots, source)
-			} else if snapshot.SourceKind == deb.SourceLocalRepo {
+			} else if sourceRef.Kind == deb.SourceLocalRepo {
 				var source *deb.LocalRepo
-				source, err = context.NewCollectionFactory().LocalRepoCollection().ByUUID(sourceID)
+				source, err = context.NewCollectionFactory().LocalRepoCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
 				snapshot.LocalRepos = append(snapshot.LocalRepos, source)
-			} else if snapshot.SourceKind == deb.SourceRemoteRepo {
+			} else if sourceRef.Kind == deb.SourceRemoteRepo {
 				var source *deb.RemoteRepo
-				source, err = context.NewCollectionFactory().RemoteRepoCollection().ByUUID(sourceID)
+				source, err = context.NewCollectionFactory().RemoteRepoCollection().ByUUID(sourceRef.ID)
 				if err != nil {
 					continue
 				}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotSign(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to sign: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to sign: %s", err)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+	if signer == nil {
+		return fmt.Errorf("unable to sign: signing is disabled in configuration")
+	}
+
+	err = snapshot.SignManifest(signer, collectionFactory.PackageCollection())
+	if err != nil {
+		return fmt.Errorf("unable to sign: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().Update(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to sign: %s", err)
+	}
+
+	fmt.Printf("\nManifest of snapshot %s has been signed.\n", snapshot.Name)
+
+	return err
+}
+
+func makeCmdSnapshotSign() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotSign,
+		UsageLine: "sign <name>",
+		Short:     "sign snapshot manifest",
+		Long: `
+Command sign creates detached GPG signature of snapshot manifest and stores
+it with the snapshot. Manifest lists snapshot metadata (UUID, creation time,
+kind of source) and packages (name, version, architecture and SHA256 of the
+package file), so signature attests exact contents of the snapshot. Manifest
+and signature could be retrieved with 'aptly snapshot manifest' and checked
+with 'aptly snapshot verify-signature' or plain gpgv. When signed snapshot is
+published, manifest and signature are published next to Release file as
+snapshot-<component>.manifest and snapshot-<component>.manifest.asc. Signing
+snapshot again replaces the signature.
+
+Example:
+
+  $ aptly snapshot sign -gpg-key=release@example.com wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-sign", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the manifest")
+	cmd.Flag.String("gpg-digest-algo", "", "digest algorithm for signature: SHA256 (default), SHA384 or SHA512")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+
+	return cmd
+}
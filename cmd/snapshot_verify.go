@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
+	"github.com/smira/flag"
 )
 
 func aptlySnapshotVerify(cmd *commander.Command, args []string) error {
@@ -31,7 +33,7 @@ func aptlySnapshotVerify(cmd *commander.Command, args []string) error {
 
 	context.Progress().Printf("Loading packages...\n")
 
-	packageList, err := deb.NewPackageListFromRefList(snapshots[0].RefList(), collectionFactory.PackageCollection(), context.Progress())
+	packageList, err := deb.NewPackageListFromRefListWithFields(snapshots[0].RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
 	if err != nil {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
@@ -44,7 +46,7 @@ func aptlySnapshotVerify(cmd *commander.Command, args []string) error {
 
 	var pL *deb.PackageList
 	for i := 1; i < len(snapshots); i++ {
-		pL, err = deb.NewPackageListFromRefList(snapshots[i].RefList(), collectionFactory.PackageCollection(), context.Progress())
+		pL, err = deb.NewPackageListFromRefListWithFields(snapshots[i].RefList(), collectionFactory.PackageCollection(), deb.PackageFieldsDependencies, context.Progress())
 		if err != nil {
 			return fmt.Errorf("unable to load packages: %s", err)
 		}
@@ -71,6 +73,29 @@ func aptlySnapshotVerify(cmd *commander.Command, args []string) error {
 
 	context.Progress().Printf("Verifying...\n")
 
+	failOnMissing := context.Flags().Lookup("fail-on-missing").Value.Get().(bool)
+
+	if context.Flags().Lookup("json").Value.Get().(bool) {
+		var missing []deb.MissingDependency
+		missing, err = packageList.VerifyDependenciesRequiredBy(context.DependencyOptions(), architecturesList, sourcePackageList, context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to verify dependencies: %s", err)
+		}
+
+		var output []byte
+		output, err = json.MarshalIndent(missing, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+
+		if failOnMissing && len(missing) > 0 {
+			return fmt.Errorf("%d dependencies are missing", len(missing))
+		}
+
+		return nil
+	}
+
 	missing, err := packageList.VerifyDependencies(context.DependencyOptions(), architecturesList, sourcePackageList, context.Progress())
 	if err != nil {
 		return fmt.Errorf("unable to verify dependencies: %s", err)
@@ -92,6 +117,10 @@ func aptlySnapshotVerify(cmd *commander.Command, args []string) error {
 		for _, dep := range deps {
 			context.Progress().Printf("  %s\n", dep)
 		}
+
+		if failOnMissing {
+			return fmt.Errorf("%d dependencies are missing", len(missing))
+		}
 	}
 
 	return err
@@ -105,13 +134,19 @@ func makeCmdSnapshotVerify() *commander.Command {
 		Long: `
 Verify does dependency resolution in snapshot <name>, possibly using additional
 snapshots <source> as dependency sources. All unsatisfied dependencies are
-printed.
+printed. With -json, packages requiring each missing dependency are reported as
+well. Flag -fail-on-missing makes command exit with error if any dependency is
+missing (e.g. to check snapshot completeness before publishing).
 
 Example:
 
     $ aptly snapshot verify wheezy-main wheezy-contrib wheezy-non-free
 `,
+		Flag: *flag.NewFlagSet("aptly-snapshot-verify", flag.ExitOnError),
 	}
 
+	cmd.Flag.Bool("json", false, "display missing dependencies and packages requiring them in JSON format")
+	cmd.Flag.Bool("fail-on-missing", false, "exit with error if some dependencies are missing")
+
 	return cmd
 }
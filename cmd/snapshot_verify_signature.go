@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotVerifySignature(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	verifier := context.GetVerifier()
+	for _, keyRing := range context.Flags().Lookup("keyring").Value.Get().([]string) {
+		verifier.AddKeyring(keyRing)
+	}
+
+	err = verifier.InitKeyring(true)
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	err = snapshot.VerifyManifest(verifier, collectionFactory.PackageCollection())
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	fmt.Printf("\nSignature of snapshot %s manifest is valid.\n", snapshot.Name)
+
+	return nil
+}
+
+func makeCmdSnapshotVerifySignature() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotVerifySignature,
+		UsageLine: "verify-signature <name>",
+		Short:     "verify signature of snapshot manifest",
+		Long: `
+Command verify-signature checks that snapshot has been signed with 'aptly snapshot sign'
+and that signature matches current contents of the snapshot.
+
+Example:
+
+  $ aptly snapshot verify-signature -keyring=release.gpg wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-verify-signature", flag.ExitOnError),
+	}
+
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying signature (instead of default)")
+
+	return cmd
+}
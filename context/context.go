@@ -18,15 +18,21 @@ import (
 	"github.com/aptly-dev/aptly/azure"
 	"github.com/aptly-dev/aptly/console"
 	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/etcddb"
 	"github.com/aptly-dev/aptly/database/goleveldb"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/gcs"
 	"github.com/aptly-dev/aptly/http"
 	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/s3"
+	"github.com/aptly-dev/aptly/sftp"
 	"github.com/aptly-dev/aptly/swift"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/aptly-dev/aptly/webhook"
+	"github.com/rs/zerolog/log"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -43,12 +49,15 @@ type AptlyContext struct {
 	progress          aptly.Progress
 	downloader        aptly.Downloader
 	taskList          *task.List
+	notifier          *webhook.Notifier
 	database          database.Storage
 	packagePool       aptly.PackagePool
 	publishedStorages map[string]aptly.PublishedStorage
 	dependencyOptions int
 	architecturesList []string
 	structuredLogging bool
+	auditRecorder     *deb.AuditRecorder
+	packageCache      *deb.PackageCache
 	// Debug features
 	fileCPUProfile *os.File
 	fileMemProfile *os.File
@@ -87,6 +96,9 @@ func (context *AptlyContext) config() *utils.ConfigStructure {
 		var err error
 
 		configLocation := context.globalFlags.Lookup("config").Value.String()
+		if configLocation == "" {
+			configLocation = os.Getenv(utils.ConfigEnvPrefix + "CONFIG")
+		}
 		if configLocation != "" {
 			err = utils.LoadConfig(configLocation, &utils.Config)
 
@@ -118,12 +130,44 @@ func (context *AptlyContext) config() *utils.ConfigStructure {
 			}
 		}
 
+		// environment variables take precedence over config file, but not over command-line flags
+		err = utils.LoadConfigEnvironment(&utils.Config, os.LookupEnv)
+		if err != nil {
+			Fatal(err)
+		}
+
+		applyConfigFlags(&utils.Config, context.globalFlags)
+
 		context.configLoaded = true
 
 	}
 	return &utils.Config
 }
 
+// applyConfigFlags overrides configuration settings with global command-line flags which were set,
+// so that configuration passed around reflects file, environment & flags precedence
+func applyConfigFlags(config *utils.ConfigStructure, flags *flag.FlagSet) {
+	if flags.IsSet("architectures") {
+		config.Architectures = strings.Split(flags.Lookup("architectures").Value.String(), ",")
+	}
+
+	if flags.IsSet("gpg-provider") {
+		config.GpgProvider = flags.Lookup("gpg-provider").Value.String()
+	}
+
+	for name, setting := range map[string]*bool{
+		"dep-follow-suggests":     &config.DepFollowSuggests,
+		"dep-follow-recommends":   &config.DepFollowRecommends,
+		"dep-follow-all-variants": &config.DepFollowAllVariants,
+		"dep-follow-source":       &config.DepFollowSource,
+		"dep-verbose-resolve":     &config.DepVerboseResolve,
+	} {
+		if flags.IsSet(name) {
+			*setting = flags.Lookup(name).Value.Get().(bool)
+		}
+	}
+}
+
 // LookupOption checks boolean flag with default (usually config) and command-line
 // setting
 func (context *AptlyContext) LookupOption(defaultValue bool, name string) (result bool) {
@@ -208,12 +252,22 @@ func (context *AptlyContext) NewDownloader(progress aptly.Progress) aptly.Downlo
 	context.Lock()
 	defer context.Unlock()
 
-	return context.newDownloader(progress)
+	downloader, _ := context.newDownloader(progress, aptly.DownloaderOptions{})
+	return downloader
+}
+
+// NewDownloaderWithOptions returns instance of new downloader with given progress
+// which accesses remote repository with specified proxy, credentials and certificates
+func (context *AptlyContext) NewDownloaderWithOptions(progress aptly.Progress, options aptly.DownloaderOptions) (aptly.Downloader, error) {
+	context.Lock()
+	defer context.Unlock()
+
+	return context.newDownloader(progress, options)
 }
 
 // NewDownloader returns instance of new downloader with given progress without locking
 // so it can be used for internal usage.
-func (context *AptlyContext) newDownloader(progress aptly.Progress) aptly.Downloader {
+func (context *AptlyContext) newDownloader(progress aptly.Progress, options aptly.DownloaderOptions) (aptly.Downloader, error) {
 	var downloadLimit int64
 	limitFlag := context.flags.Lookup("download-limit")
 	if limitFlag != nil {
@@ -222,6 +276,12 @@ func (context *AptlyContext) newDownloader(progress aptly.Progress) aptly.Downlo
 	if downloadLimit == 0 {
 		downloadLimit = context.config().DownloadLimit
 	}
+	// download rate (bytes/sec) has precedence over download limit (kbytes/sec)
+	downloadRate := downloadLimit * 1024
+	rateFlag := context.flags.Lookup("download-rate")
+	if rateFlag != nil && rateFlag.Value.Get().(int64) > 0 {
+		downloadRate = rateFlag.Value.Get().(int64)
+	}
 	maxTries := context.config().DownloadRetries + 1
 	maxTriesFlag := context.flags.Lookup("max-tries")
 	if maxTriesFlag != nil {
@@ -235,9 +295,13 @@ func (context *AptlyContext) newDownloader(progress aptly.Progress) aptly.Downlo
 	}
 
 	if downloader == "grab" {
-		return http.NewGrabDownloader(downloadLimit*1024, maxTries, progress)
+		grabDownloader, err := http.NewGrabDownloaderWithOptions(downloadRate, maxTries, progress, options)
+		if err != nil {
+			return nil, err
+		}
+		return grabDownloader, nil
 	}
-	return http.NewDownloader(downloadLimit*1024, maxTries, progress)
+	return http.NewDownloaderWithOptions(downloadRate, maxTries, progress, options)
 }
 
 // Downloader returns instance of current downloader
@@ -246,12 +310,25 @@ func (context *AptlyContext) Downloader() aptly.Downloader {
 	defer context.Unlock()
 
 	if context.downloader == nil {
-		context.downloader = context.newDownloader(context._progress())
+		context.downloader, _ = context.newDownloader(context._progress(), aptly.DownloaderOptions{})
 	}
 
 	return context.downloader
 }
 
+// RemoteDownloader returns downloader for remote repository with given access options,
+// if options are empty, current downloader is returned
+func (context *AptlyContext) RemoteDownloader(options aptly.DownloaderOptions) (aptly.Downloader, error) {
+	if options.Empty() {
+		return context.Downloader(), nil
+	}
+
+	context.Lock()
+	defer context.Unlock()
+
+	return context.newDownloader(context._progress(), options)
+}
+
 // TaskList returns instance of current task list
 func (context *AptlyContext) TaskList() *task.List {
 	context.Lock()
@@ -263,6 +340,49 @@ func (context *AptlyContext) TaskList() *task.List {
 	return context.taskList
 }
 
+// Notify delivers event to webhooks configured, delivery failures are logged,
+// but don't affect the operation which triggered the event
+func (context *AptlyContext) Notify(event string, entities map[string]interface{}, eventErr error) {
+	context.Lock()
+	if context.notifier == nil {
+		context.notifier = webhook.NewNotifier(context.config().Webhooks)
+	}
+	notifier := context.notifier
+	context.Unlock()
+
+	err := notifier.Notify(event, entities, eventErr)
+	if err != nil {
+		log.Warn().Msgf("%s", err)
+	}
+}
+
+// Audit stores entry in the audit log (if audit log is enabled), entry is also
+// logged as structured log message if API is running with JSON log format
+func (context *AptlyContext) Audit(entry *deb.AuditEntry) {
+	if !context.Config().EnableAuditLog {
+		return
+	}
+
+	if context.structuredLogging {
+		log.Info().
+			Str("audit", entry.UUID).
+			Time("timestamp", entry.Timestamp).
+			Str("source", entry.Source).
+			Str("actor", entry.Actor).
+			Str("operation", entry.Operation).
+			Strs("arguments", entry.Arguments).
+			Interface("changes", entry.Changes).
+			Int("status", entry.Status).
+			Str("error", entry.Error).
+			Msg("audit")
+	}
+
+	err := context.NewCollectionFactory().AuditCollection().Add(entry)
+	if err != nil {
+		log.Error().Msgf("unable to store audit log entry: %s", err)
+	}
+}
+
 // DBPath builds path to database
 func (context *AptlyContext) DBPath() string {
 	context.Lock()
@@ -273,6 +393,10 @@ func (context *AptlyContext) DBPath() string {
 
 // DBPath builds path to database
 func (context *AptlyContext) dbPath() string {
+	if context.config().DatabaseBackend.DbPath != "" {
+		return context.config().DatabaseBackend.DbPath
+	}
+
 	return filepath.Join(context.config().RootDir, "db")
 }
 
@@ -288,7 +412,15 @@ func (context *AptlyContext) _database() (database.Storage, error) {
 	if context.database == nil {
 		var err error
 
-		context.database, err = goleveldb.NewDB(context.dbPath())
+		backend := context.config().DatabaseBackend
+		switch backend.Type {
+		case "", "leveldb":
+			context.database, err = goleveldb.NewDB(context.dbPath())
+		case "etcd":
+			context.database, err = etcddb.NewDB(backend.URL, backend.Prefix)
+		default:
+			err = fmt.Errorf("unknown database backend type: %s", backend.Type)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("can't instantiate database: %s", err)
 		}
@@ -352,7 +484,30 @@ func (context *AptlyContext) NewCollectionFactory() *deb.CollectionFactory {
 	if err != nil {
 		Fatal(err)
 	}
-	return deb.NewCollectionFactory(db)
+
+	// package cache is shared by all the collection factories, so that packages are
+	// reused across commands in API mode
+	if context.packageCache == nil && context.config().PackageCacheSize > 0 {
+		context.packageCache = deb.NewPackageCache(context.config().PackageCacheSize)
+	}
+
+	factory := deb.NewCollectionFactory(db)
+	factory.SetAuditRecorder(context.auditRecorder)
+	factory.SetSnapshotDeltaInterval(context.config().SnapshotDeltaInterval)
+	factory.SetPackageCache(context.packageCache)
+	factory.SetQueryParser(func(q string) (deb.PackageQuery, error) {
+		return query.ParseWithPackageSets(q, factory.PackageSetCollection())
+	})
+	return factory
+}
+
+// SetAuditRecorder sets recorder attached to collection factories built with NewCollectionFactory,
+// nil disables recording
+func (context *AptlyContext) SetAuditRecorder(recorder *deb.AuditRecorder) {
+	context.Lock()
+	defer context.Unlock()
+
+	context.auditRecorder = recorder
 }
 
 // PackagePool returns instance of PackagePool
@@ -441,6 +596,32 @@ func (context *AptlyContext) GetPublishedStorage(name string) aptly.PublishedSto
 			if err != nil {
 				Fatal(err)
 			}
+		} else if strings.HasPrefix(name, "gcs:") {
+			params, ok := context.config().GCSPublishRoots[name[4:]]
+			if !ok {
+				Fatal(fmt.Errorf("published GCS storage %v not configured", name[4:]))
+			}
+
+			var err error
+			publishedStorage, err = gcs.NewPublishedStorage(
+				params.Bucket, params.Prefix, params.CredentialsFile, params.ACL, params.UniformBucketLevelAccess,
+				params.IndexCacheControl, params.PoolCacheControl, params.Endpoint)
+			if err != nil {
+				Fatal(err)
+			}
+		} else if strings.HasPrefix(name, "sftp:") {
+			params, ok := context.config().SFTPPublishRoots[name[5:]]
+			if !ok {
+				Fatal(fmt.Errorf("published SFTP storage %v not configured", name[5:]))
+			}
+
+			var err error
+			publishedStorage, err = sftp.NewPublishedStorage(
+				params.Host, params.Port, params.User, params.Password, params.PrivateKeyFile, params.KnownHostsFile,
+				params.InsecureIgnoreHostKey, params.RootDir)
+			if err != nil {
+				Fatal(err)
+			}
 		} else {
 			Fatal(fmt.Errorf("unknown published storage format: %v", name))
 		}
@@ -450,6 +631,73 @@ func (context *AptlyContext) GetPublishedStorage(name string) aptly.PublishedSto
 	return publishedStorage
 }
 
+// CheckPublishedStorage verifies that published storage is configured
+func (context *AptlyContext) CheckPublishedStorage(name string) error {
+	config := context.Config()
+
+	var ok bool
+	switch {
+	case name == "":
+		ok = true
+	case strings.HasPrefix(name, "filesystem:"):
+		_, ok = config.FileSystemPublishRoots[name[11:]]
+	case strings.HasPrefix(name, "s3:"):
+		_, ok = config.S3PublishRoots[name[3:]]
+	case strings.HasPrefix(name, "swift:"):
+		_, ok = config.SwiftPublishRoots[name[6:]]
+	case strings.HasPrefix(name, "azure:"):
+		_, ok = config.AzurePublishRoots[name[6:]]
+	case strings.HasPrefix(name, "gcs:"):
+		_, ok = config.GCSPublishRoots[name[4:]]
+	case strings.HasPrefix(name, "sftp:"):
+		_, ok = config.SFTPPublishRoots[name[5:]]
+	default:
+		return fmt.Errorf("unknown published storage format: %v", name)
+	}
+
+	if !ok {
+		return fmt.Errorf("published storage %v not configured", name)
+	}
+
+	return nil
+}
+
+// IndexCachePath builds path to directory with copies of mirror package indexes
+func (context *AptlyContext) IndexCachePath(repo *deb.RemoteRepo) string {
+	return filepath.Join(context.Config().RootDir, "indexes", repo.UUID)
+}
+
+// IndexCache returns cache of package indexes shared by all the mirrors
+func (context *AptlyContext) IndexCache() *http.IndexCache {
+	return http.NewIndexCache(filepath.Join(context.Config().RootDir, "indexes", "by-hash"), 30*24*time.Hour)
+}
+
+// KeyStore returns aptly's own store of trusted keys
+func (context *AptlyContext) KeyStore() *pgp.KeyStore {
+	return pgp.NewKeyStore(filepath.Join(context.Config().RootDir, "keyrings"))
+}
+
+// TrustedKeyrings returns keyrings from key store to verify mirror signatures: global keys
+// and keys of the mirror (if repo is not nil)
+func (context *AptlyContext) TrustedKeyrings(repo *deb.RemoteRepo) []string {
+	namespaces := []string{pgp.GlobalKeyNamespace}
+	if repo != nil {
+		namespaces = append(namespaces, pgp.MirrorKeyNamespace(repo.UUID))
+	}
+
+	return context.KeyStore().Keyrings(namespaces...)
+}
+
+// PartialDownloadPath builds path to directory with package files downloaded by interrupted mirror update
+func (context *AptlyContext) PartialDownloadPath(repo *deb.RemoteRepo) string {
+	return filepath.Join(context.Config().RootDir, "partial", repo.UUID)
+}
+
+// QuarantinePath builds path to directory with package files which failed verification while updating mirror
+func (context *AptlyContext) QuarantinePath(repo *deb.RemoteRepo) string {
+	return filepath.Join(context.Config().RootDir, "quarantine", repo.UUID)
+}
+
 // UploadPath builds path to upload storage
 func (context *AptlyContext) UploadPath() string {
 	return filepath.Join(context.Config().RootDir, "upload")
@@ -469,6 +717,7 @@ func (context *AptlyContext) pgpProvider() string {
 	case "gpg1": // nolint: goconst
 	case "gpg2": // nolint: goconst
 	case "internal": // nolint: goconst
+	case "external": // nolint: goconst
 	default:
 		Fatal(fmt.Errorf("unknown gpg provider: %v", provider))
 	}
@@ -482,7 +731,8 @@ func (context *AptlyContext) getGPGFinder() pgp.GPGFinder {
 		return pgp.GPG1Finder()
 	case "gpg2":
 		return pgp.GPG2Finder()
-	case "gpg":
+	case "gpg", "external":
+		// with external signer, signatures are still verified with gpg
 		return pgp.GPGDefaultFinder()
 	}
 
@@ -499,6 +749,11 @@ func (context *AptlyContext) GetSigner() pgp.Signer {
 		return &pgp.GoSigner{}
 	}
 
+	if provider == "external" { // nolint: goconst
+		config := context.config().ExternalSigner
+		return pgp.NewExternalSigner(config.URL, config.Command, config.Token, time.Duration(config.Timeout)*time.Second)
+	}
+
 	return pgp.NewGpgSigner(context.getGPGFinder())
 }
 
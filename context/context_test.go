@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/flag"
 
 	. "gopkg.in/check.v1"
@@ -84,3 +85,25 @@ func (s *AptlyContextSuite) TestGetPublishedStorageBadFS(c *C) {
 		FatalErrorPanicMatches,
 		&FatalError{ReturnCode: 1, Message: "published local storage fuji not configured"})
 }
+
+func (s *AptlyContextSuite) TestApplyConfigFlags(c *C) {
+	flags := flag.NewFlagSet("fakeFlags", flag.ContinueOnError)
+	flags.String("architectures", "", "")
+	flags.String("gpg-provider", "", "")
+	flags.Bool("dep-follow-suggests", false, "")
+	flags.Bool("dep-follow-source", false, "")
+
+	config := utils.ConfigStructure{Architectures: []string{"amd64"}, GpgProvider: "gpg", DepFollowSuggests: true, DepFollowSource: true}
+
+	// flags which weren't set don't override config
+	applyConfigFlags(&config, flags)
+	c.Check(config.Architectures, DeepEquals, []string{"amd64"})
+	c.Check(config.GpgProvider, Equals, "gpg")
+
+	c.Assert(flags.Parse([]string{"-architectures=i386,arm64", "-gpg-provider=internal", "-dep-follow-suggests=false"}, true), IsNil)
+	applyConfigFlags(&config, flags)
+	c.Check(config.Architectures, DeepEquals, []string{"i386", "arm64"})
+	c.Check(config.GpgProvider, Equals, "internal")
+	c.Check(config.DepFollowSuggests, Equals, false)
+	c.Check(config.DepFollowSource, Equals, true)
+}
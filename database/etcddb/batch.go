@@ -0,0 +1,57 @@
+package etcddb
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/database"
+)
+
+// maxTxnOps is default limit of operations in single etcd transaction (--max-txn-ops)
+const maxTxnOps = 128
+
+type batch struct {
+	s   *storage
+	ops []requestOp
+}
+
+func (b *batch) Put(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	b.ops = append(b.ops, requestOp{RequestPut: &putRequest{Key: b.s.fullKey(key), Value: append([]byte(nil), value...)}})
+
+	return nil
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.ops = append(b.ops, requestOp{RequestDeleteRange: &deleteRangeRequest{Key: b.s.fullKey(key)}})
+
+	return nil
+}
+
+// Write sends accumulated writes to etcd as single transaction
+//
+// Batch is written atomically, so batches which don't fit into single etcd transaction
+// are refused without writing anything.
+func (b *batch) Write() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+
+	if len(b.ops) > maxTxnOps {
+		n := len(b.ops)
+		b.ops = nil
+		return fmt.Errorf("unable to write batch of %d operations: etcd allows at most %d operations in transaction", n, maxTxnOps)
+	}
+
+	err := b.s.client.call("kv/txn", &txnRequest{Success: b.ops}, nil)
+	b.ops = nil
+
+	return err
+}
+
+// batch should implement database.Batch
+var (
+	_ database.Batch = &batch{}
+)
@@ -0,0 +1,161 @@
+package etcddb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is minimal client of etcd v3 API exposed by etcd gRPC gateway (JSON over HTTP),
+// requests are sent to the endpoint which responded last, failing over to other endpoints
+// on connection errors
+type client struct {
+	sync.Mutex
+
+	endpoints []string
+	current   int
+	http      *http.Client
+}
+
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type responseHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	Limit    int64  `json:"limit,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+	KeysOnly bool   `json:"keys_only,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []keyValue     `json:"kvs"`
+	More   bool           `json:"more"`
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type requestOp struct {
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Success []requestOp `json:"success"`
+}
+
+type compactionRequest struct {
+	Revision int64 `json:"revision,string"`
+	Physical bool  `json:"physical"`
+}
+
+type errorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+func newClient(endpoints string) (*client, error) {
+	c := &client{
+		http: &http.Client{Timeout: 60 * time.Second},
+	}
+
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+		if endpoint == "" {
+			continue
+		}
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+		c.endpoints = append(c.endpoints, endpoint)
+	}
+
+	if len(c.endpoints) == 0 {
+		return nil, fmt.Errorf("no etcd endpoints configured")
+	}
+
+	return c, nil
+}
+
+// call performs request to etcd API method (e.g. kv/range) and decodes response
+func (c *client) call(method string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	start := c.current
+	c.Unlock()
+
+	for i := range c.endpoints {
+		current := (start + i) % len(c.endpoints)
+
+		var resp *http.Response
+		resp, err = c.http.Post(c.endpoints[current]+"/v3/"+method, "application/json", bytes.NewReader(body))
+		if err != nil {
+			// endpoint is not available, try next one
+			continue
+		}
+
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			err = fmt.Errorf("etcd endpoint %s is unavailable", c.endpoints[current])
+			continue
+		}
+
+		c.Lock()
+		c.current = current
+		c.Unlock()
+
+		if resp.StatusCode != http.StatusOK {
+			var etcdErr errorResponse
+			if json.Unmarshal(data, &etcdErr) == nil && (etcdErr.Message != "" || etcdErr.Error != "") {
+				if etcdErr.Message == "" {
+					etcdErr.Message = etcdErr.Error
+				}
+				if strings.Contains(etcdErr.Message, "request is too large") {
+					// value (e.g. large reflist) exceeds etcd server limit on request size
+					return fmt.Errorf("etcd request %s failed: %s (%d bytes), etcd limits request size with --max-request-bytes",
+						method, etcdErr.Message, len(body))
+				}
+				return fmt.Errorf("etcd request %s failed: %s", method, etcdErr.Message)
+			}
+			return fmt.Errorf("etcd request %s failed: HTTP code %d", method, resp.StatusCode)
+		}
+
+		if response == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, response)
+	}
+
+	return fmt.Errorf("unable to reach etcd: %s", err)
+}
@@ -0,0 +1,30 @@
+// Package etcddb implements metadata database stored in etcd, so that multiple aptly
+// instances could share the same database
+package etcddb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+)
+
+// NewDB creates new instance of DB, but doesn't open it (yet)
+//
+// endpoints is comma-separated list of etcd endpoints (URLs of etcd gRPC gateway, e.g.
+// http://127.0.0.1:2379), all the keys are stored with prefix
+func NewDB(endpoints string, prefix string) (database.Storage, error) {
+	c, err := newClient(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage{client: c, prefix: []byte(prefix)}, nil
+}
+
+// NewOpenDB creates new instance of DB and opens it
+func NewOpenDB(endpoints string, prefix string) (database.Storage, error) {
+	db, err := NewDB(endpoints, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, db.Open()
+}
@@ -0,0 +1,431 @@
+package etcddb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/etcddb"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+// fakeEtcd emulates subset of etcd gRPC gateway API in memory
+type fakeEtcd struct {
+	sync.Mutex
+
+	data     map[string][]byte
+	revision int64
+	txns     int
+	// maxRequestBytes emulates --max-request-bytes, if set
+	maxRequestBytes int
+}
+
+type fakeKV struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
+
+type fakeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+	Value    []byte `json:"value"`
+	Limit    int64  `json:"limit"`
+	KeysOnly bool   `json:"keys_only"`
+	Success  []struct {
+		RequestPut         *fakeRequest `json:"request_put"`
+		RequestDeleteRange *fakeRequest `json:"request_delete_range"`
+	} `json:"success"`
+}
+
+func (f *fakeEtcd) keys(req *fakeRequest) []string {
+	result := []string{}
+	for key := range f.data {
+		k := []byte(key)
+		if len(req.RangeEnd) == 0 {
+			if bytes.Equal(k, req.Key) {
+				result = append(result, key)
+			}
+		} else if bytes.Compare(k, req.Key) >= 0 && (bytes.Equal(req.RangeEnd, []byte{0}) || bytes.Compare(k, req.RangeEnd) < 0) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if f.maxRequestBytes > 0 && len(body) > f.maxRequestBytes {
+		http.Error(w, `{"error":"etcdserver: request is too large","code":3}`, http.StatusBadRequest)
+		return
+	}
+
+	var req fakeRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{}
+
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		keys := f.keys(&req)
+		more := false
+		if req.Limit > 0 && int64(len(keys)) > req.Limit {
+			keys, more = keys[:req.Limit], true
+		}
+		kvs := []fakeKV{}
+		for _, key := range keys {
+			kv := fakeKV{Key: []byte(key)}
+			if !req.KeysOnly {
+				kv.Value = f.data[key]
+			}
+			kvs = append(kvs, kv)
+		}
+		response["kvs"] = kvs
+		response["more"] = more
+	case "/v3/kv/put":
+		f.data[string(req.Key)] = req.Value
+		f.revision++
+	case "/v3/kv/deleterange":
+		for _, key := range f.keys(&req) {
+			delete(f.data, key)
+		}
+		f.revision++
+	case "/v3/kv/txn":
+		if len(req.Success) > 128 {
+			http.Error(w, `{"error":"too many operations in txn request","code":3}`, http.StatusBadRequest)
+			return
+		}
+		for _, op := range req.Success {
+			if op.RequestPut != nil {
+				f.data[string(op.RequestPut.Key)] = op.RequestPut.Value
+			} else {
+				for _, key := range f.keys(op.RequestDeleteRange) {
+					delete(f.data, key)
+				}
+			}
+		}
+		f.revision++
+		f.txns++
+	case "/v3/kv/compaction":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	response["header"] = map[string]string{"revision": strconv.FormatInt(f.revision, 10)}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+type EtcdDBSuite struct {
+	etcd   *fakeEtcd
+	server *httptest.Server
+	db     database.Storage
+}
+
+var _ = Suite(&EtcdDBSuite{})
+
+func (s *EtcdDBSuite) SetUpTest(c *C) {
+	var err error
+
+	s.etcd = &fakeEtcd{data: map[string][]byte{}}
+	s.server = httptest.NewServer(s.etcd)
+
+	// first endpoint is not available
+	s.db, err = etcddb.NewOpenDB("127.0.0.1:1,"+s.server.URL, "aptly/")
+	c.Assert(err, IsNil)
+}
+
+func (s *EtcdDBSuite) TearDownTest(c *C) {
+	err := s.db.Close()
+	c.Assert(err, IsNil)
+
+	s.server.Close()
+}
+
+func (s *EtcdDBSuite) TestOpen(c *C) {
+	_, err := etcddb.NewDB("", "")
+	c.Check(err, ErrorMatches, "no etcd endpoints configured")
+
+	_, err = etcddb.NewOpenDB("127.0.0.1:1", "")
+	c.Check(err, ErrorMatches, "unable to reach etcd: .*")
+}
+
+func (s *EtcdDBSuite) TestGetPut(c *C) {
+	var (
+		key   = []byte("key")
+		value = []byte("value")
+	)
+
+	_, err := s.db.Get(key)
+	c.Assert(err, ErrorMatches, "key not found")
+
+	err = s.db.Put(key, value)
+	c.Assert(err, IsNil)
+
+	result, err := s.db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, value)
+
+	c.Check(s.etcd.data["aptly/key"], DeepEquals, value)
+
+	err = s.db.Put(key, nil)
+	c.Assert(err, IsNil)
+
+	result, err = s.db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, []byte{})
+}
+
+func (s *EtcdDBSuite) TestTemporaryDelete(c *C) {
+	var (
+		key   = []byte("key")
+		value = []byte("value")
+	)
+
+	err := s.db.Put(key, value)
+	c.Assert(err, IsNil)
+
+	temp, err := s.db.CreateTemporary()
+	c.Assert(err, IsNil)
+
+	c.Check(s.db.HasPrefix([]byte(nil)), Equals, true)
+	c.Check(temp.HasPrefix([]byte(nil)), Equals, false)
+
+	err = temp.Put(key, value)
+	c.Assert(err, IsNil)
+	c.Check(temp.HasPrefix([]byte(nil)), Equals, true)
+
+	c.Assert(temp.Close(), IsNil)
+	c.Assert(temp.Drop(), IsNil)
+}
+
+func (s *EtcdDBSuite) TestRequestTooLarge(c *C) {
+	s.etcd.maxRequestBytes = 1024
+
+	err := s.db.Put([]byte("reflist"), bytes.Repeat([]byte("x"), 2048))
+	c.Check(err, ErrorMatches, "etcd request kv/put failed: etcdserver: request is too large \\(\\d+ bytes\\), etcd limits request size with --max-request-bytes")
+
+	c.Check(s.db.Put([]byte("reflist"), []byte("x")), IsNil)
+}
+
+func (s *EtcdDBSuite) TestDelete(c *C) {
+	var (
+		key   = []byte("key")
+		value = []byte("value")
+	)
+
+	err := s.db.Put(key, value)
+	c.Assert(err, IsNil)
+
+	err = s.db.Delete(key)
+	c.Assert(err, IsNil)
+
+	_, err = s.db.Get(key)
+	c.Assert(err, ErrorMatches, "key not found")
+
+	err = s.db.Delete(key)
+	c.Assert(err, IsNil)
+}
+
+func (s *EtcdDBSuite) TestByPrefix(c *C) {
+	c.Check(s.db.FetchByPrefix([]byte{0x80}), DeepEquals, [][]byte{})
+
+	s.db.Put([]byte{0x80, 0x01}, []byte{0x01})
+	s.db.Put([]byte{0x80, 0x03}, []byte{0x03})
+	s.db.Put([]byte{0x80, 0x02}, []byte{0x02})
+	c.Check(s.db.FetchByPrefix([]byte{0x80}), DeepEquals, [][]byte{{0x01}, {0x02}, {0x03}})
+	c.Check(s.db.KeysByPrefix([]byte{0x80}), DeepEquals, [][]byte{{0x80, 0x01}, {0x80, 0x02}, {0x80, 0x03}})
+
+	s.db.Put([]byte{0x90, 0x01}, []byte{0x04})
+	c.Check(s.db.FetchByPrefix([]byte{0x80}), DeepEquals, [][]byte{{0x01}, {0x02}, {0x03}})
+	c.Check(s.db.KeysByPrefix([]byte{0x80}), DeepEquals, [][]byte{{0x80, 0x01}, {0x80, 0x02}, {0x80, 0x03}})
+
+	s.db.Put([]byte{0xff, 0xff, 0x01}, []byte{0x06})
+	c.Check(s.db.KeysByPrefix([]byte{0xff, 0xff}), DeepEquals, [][]byte{{0xff, 0xff, 0x01}})
+
+	keys := [][]byte{}
+	values := [][]byte{}
+
+	c.Check(s.db.ProcessByPrefix([]byte{0x80}, func(k, v []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		values = append(values, append([]byte(nil), v...))
+		return nil
+	}), IsNil)
+
+	c.Check(values, DeepEquals, [][]byte{{0x01}, {0x02}, {0x03}})
+	c.Check(keys, DeepEquals, [][]byte{{0x80, 0x01}, {0x80, 0x02}, {0x80, 0x03}})
+
+	c.Check(s.db.ProcessByPrefix([]byte{0x80}, func(k, v []byte) error {
+		return database.ErrNotFound
+	}), Equals, database.ErrNotFound)
+
+	c.Check(s.db.ProcessByPrefix([]byte{0xa0}, func(k, v []byte) error {
+		return database.ErrNotFound
+	}), IsNil)
+
+	c.Check(s.db.FetchByPrefix([]byte{0xa0}), DeepEquals, [][]byte{})
+	c.Check(s.db.KeysByPrefix([]byte{0xa0}), DeepEquals, [][]byte{})
+
+	// keys outside of prefix are not visible
+	s.etcd.data["other/key"] = []byte("value")
+	c.Check(s.db.KeysByPrefix(nil), HasLen, 5)
+}
+
+func (s *EtcdDBSuite) TestByPrefixPaging(c *C) {
+	for i := 0; i < 2500; i++ {
+		c.Assert(s.db.Put([]byte("P"+strconv.Itoa(10000+i)), []byte(strconv.Itoa(i))), IsNil)
+	}
+
+	keys := s.db.KeysByPrefix([]byte("P"))
+	c.Assert(keys, HasLen, 2500)
+	c.Check(keys[0], DeepEquals, []byte("P10000"))
+	c.Check(keys[1000], DeepEquals, []byte("P11000"))
+	c.Check(keys[2499], DeepEquals, []byte("P12499"))
+}
+
+func (s *EtcdDBSuite) TestHasPrefix(c *C) {
+	c.Check(s.db.HasPrefix([]byte(nil)), Equals, false)
+	c.Check(s.db.HasPrefix([]byte{0x80}), Equals, false)
+
+	s.db.Put([]byte{0x80, 0x01}, []byte{0x01})
+
+	c.Check(s.db.HasPrefix([]byte(nil)), Equals, true)
+	c.Check(s.db.HasPrefix([]byte{0x80}), Equals, true)
+	c.Check(s.db.HasPrefix([]byte{0x79}), Equals, false)
+}
+
+func (s *EtcdDBSuite) TestBatch(c *C) {
+	var (
+		key    = []byte("key")
+		key2   = []byte("key2")
+		value  = []byte("value")
+		value2 = []byte("value2")
+	)
+
+	err := s.db.Put(key, value)
+	c.Assert(err, IsNil)
+
+	batch := s.db.CreateBatch()
+	batch.Put(key2, value2)
+	batch.Delete(key)
+
+	v, err := s.db.Get(key)
+	c.Check(err, IsNil)
+	c.Check(v, DeepEquals, value)
+
+	_, err = s.db.Get(key2)
+	c.Check(err, ErrorMatches, "key not found")
+
+	err = batch.Write()
+	c.Check(err, IsNil)
+	c.Check(s.etcd.txns, Equals, 1)
+
+	v2, err := s.db.Get(key2)
+	c.Check(err, IsNil)
+	c.Check(v2, DeepEquals, value2)
+
+	_, err = s.db.Get(key)
+	c.Check(err, ErrorMatches, "key not found")
+
+	// batch is written as single transaction
+	batch = s.db.CreateBatch()
+	for i := 0; i < 128; i++ {
+		batch.Put([]byte("B"+strconv.Itoa(i)), value)
+	}
+	c.Check(batch.Write(), IsNil)
+	c.Check(s.etcd.txns, Equals, 2)
+	c.Check(s.db.KeysByPrefix([]byte("B")), HasLen, 128)
+
+	// batch which doesn't fit into transaction is refused, nothing is written
+	batch = s.db.CreateBatch()
+	for i := 0; i < 129; i++ {
+		batch.Put([]byte("C"+strconv.Itoa(i)), value)
+	}
+	c.Check(batch.Write(), ErrorMatches, "unable to write batch of 129 operations: etcd allows at most 128 operations in transaction")
+	c.Check(s.etcd.txns, Equals, 2)
+	c.Check(s.db.KeysByPrefix([]byte("C")), HasLen, 0)
+}
+
+func (s *EtcdDBSuite) TestTransaction(c *C) {
+	var (
+		key    = []byte("key")
+		key2   = []byte("key2")
+		value  = []byte("value")
+		value2 = []byte("value2")
+	)
+
+	err := s.db.Put(key, value)
+	c.Assert(err, IsNil)
+
+	transaction, err := s.db.OpenTransaction()
+	c.Assert(err, IsNil)
+
+	c.Check(transaction.Put(key2, value2), IsNil)
+	c.Check(transaction.Delete(key), IsNil)
+
+	v, err := transaction.Get(key2)
+	c.Check(err, IsNil)
+	c.Check(v, DeepEquals, value2)
+
+	_, err = transaction.Get(key)
+	c.Check(err, ErrorMatches, "key not found")
+
+	// changes are not visible outside of transaction
+	v, err = s.db.Get(key)
+	c.Check(err, IsNil)
+	c.Check(v, DeepEquals, value)
+
+	c.Check(transaction.Commit(), IsNil)
+	transaction.Discard()
+
+	_, err = s.db.Get(key)
+	c.Check(err, ErrorMatches, "key not found")
+
+	v, err = s.db.Get(key2)
+	c.Check(err, IsNil)
+	c.Check(v, DeepEquals, value2)
+
+	transaction, err = s.db.OpenTransaction()
+	c.Assert(err, IsNil)
+	c.Check(transaction.Put(key, value), IsNil)
+	transaction.Discard()
+
+	_, err = s.db.Get(key)
+	c.Check(err, ErrorMatches, "key not found")
+}
+
+func (s *EtcdDBSuite) TestCompactDrop(c *C) {
+	s.db.Put([]byte{0x80, 0x01}, []byte{0x01})
+	s.db.Put([]byte{0x80, 0x03}, []byte{0x03})
+	s.etcd.data["other/key"] = []byte("value")
+
+	c.Check(s.db.CompactDB(), IsNil)
+
+	c.Check(s.db.Drop(), IsNil)
+	c.Check(s.db.HasPrefix(nil), Equals, false)
+	c.Check(s.etcd.data, HasLen, 1)
+}
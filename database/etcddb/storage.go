@@ -0,0 +1,213 @@
+package etcddb
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+)
+
+// pageSize is number of keys fetched from etcd with single request
+const pageSize = 1000
+
+type storage struct {
+	client *client
+	prefix []byte
+}
+
+// fullKey returns key with storage prefix
+func (s *storage) fullKey(key []byte) []byte {
+	return append(append([]byte(nil), s.prefix...), key...)
+}
+
+// prefixRange returns range of keys with prefix in etcd
+func (s *storage) prefixRange(prefix []byte) (key, rangeEnd []byte) {
+	key = s.fullKey(prefix)
+	if len(key) == 0 {
+		// all the keys
+		return []byte{0}, []byte{0}
+	}
+
+	rangeEnd = append([]byte(nil), key...)
+	for i := len(rangeEnd) - 1; i >= 0; i-- {
+		if rangeEnd[i] < 0xff {
+			rangeEnd[i]++
+			return key, rangeEnd[:i+1]
+		}
+	}
+
+	// prefix is all 0xff, so range is all keys >= key
+	return key, []byte{0}
+}
+
+// CreateTemporary creates new temporary DB, temporary DB is local to aptly instance
+func (s *storage) CreateTemporary() (database.Storage, error) {
+	tempdir, err := os.MkdirTemp("", "aptly")
+	if err != nil {
+		return nil, err
+	}
+
+	return goleveldb.NewOpenDB(tempdir)
+}
+
+// Get key value from database
+func (s *storage) Get(key []byte) ([]byte, error) {
+	var resp rangeResponse
+
+	err := s.client.call("kv/range", &rangeRequest{Key: s.fullKey(key)}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	if resp.Kvs[0].Value == nil {
+		return []byte{}, nil
+	}
+
+	return resp.Kvs[0].Value, nil
+}
+
+// Put saves key to database
+func (s *storage) Put(key []byte, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return s.client.call("kv/put", &putRequest{Key: s.fullKey(key), Value: value}, nil)
+}
+
+// Delete removes key from DB
+func (s *storage) Delete(key []byte) error {
+	return s.client.call("kv/deleterange", &deleteRangeRequest{Key: s.fullKey(key)}, nil)
+}
+
+// scan iterates through all entries where key starts with prefix, entries are fetched
+// page by page at the same revision, so that scan sees consistent state of DB
+func (s *storage) scan(prefix []byte, keysOnly bool, limit int64, proc database.StorageProcessor) error {
+	key, rangeEnd := s.prefixRange(prefix)
+	revision := int64(0)
+
+	if limit == 0 || limit > pageSize {
+		limit = pageSize
+	}
+
+	for {
+		var resp rangeResponse
+
+		err := s.client.call("kv/range", &rangeRequest{Key: key, RangeEnd: rangeEnd, Limit: limit, Revision: revision, KeysOnly: keysOnly}, &resp)
+		if err != nil {
+			return err
+		}
+
+		revision = resp.Header.Revision
+
+		for _, kv := range resp.Kvs {
+			value := kv.Value
+			if value == nil {
+				value = []byte{}
+			}
+
+			err = proc(bytes.TrimPrefix(kv.Key, s.prefix), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 || limit < pageSize {
+			return nil
+		}
+
+		// continue right after the last key
+		key = append(append([]byte(nil), resp.Kvs[len(resp.Kvs)-1].Key...), 0)
+	}
+}
+
+// KeysByPrefix returns all keys that start with prefix
+func (s *storage) KeysByPrefix(prefix []byte) [][]byte {
+	result := make([][]byte, 0, 20)
+
+	_ = s.scan(prefix, true, 0, func(key, _ []byte) error {
+		result = append(result, key)
+		return nil
+	})
+
+	return result
+}
+
+// FetchByPrefix returns all values with keys that start with prefix
+func (s *storage) FetchByPrefix(prefix []byte) [][]byte {
+	result := make([][]byte, 0, 20)
+
+	_ = s.scan(prefix, false, 0, func(_, value []byte) error {
+		result = append(result, value)
+		return nil
+	})
+
+	return result
+}
+
+// HasPrefix checks whether it can find any key with given prefix and returns true if one exists
+func (s *storage) HasPrefix(prefix []byte) bool {
+	found := false
+
+	_ = s.scan(prefix, true, 1, func(_, _ []byte) error {
+		found = true
+		return nil
+	})
+
+	return found
+}
+
+// ProcessByPrefix iterates through all entries where key starts with prefix and calls
+// StorageProcessor on key value pair
+func (s *storage) ProcessByPrefix(prefix []byte, proc database.StorageProcessor) error {
+	return s.scan(prefix, false, 0, proc)
+}
+
+// Close finishes DB work
+func (s *storage) Close() error {
+	return nil
+}
+
+// Open checks that etcd is reachable
+func (s *storage) Open() error {
+	return s.client.call("kv/range", &rangeRequest{Key: s.fullKey(nil), Limit: 1, KeysOnly: true}, &rangeResponse{})
+}
+
+// CreateBatch creates a Batch object
+func (s *storage) CreateBatch() database.Batch {
+	return &batch{s: s}
+}
+
+// OpenTransaction creates new transaction.
+func (s *storage) OpenTransaction() (database.Transaction, error) {
+	return &transaction{batch: batch{s: s}, pending: map[string][]byte{}}, nil
+}
+
+// CompactDB compacts etcd history up to current revision
+func (s *storage) CompactDB() error {
+	var resp rangeResponse
+
+	err := s.client.call("kv/range", &rangeRequest{Key: s.fullKey(nil), Limit: 1, KeysOnly: true}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return s.client.call("kv/compaction", &compactionRequest{Revision: resp.Header.Revision, Physical: true}, nil)
+}
+
+// Drop removes all the keys of the DB (DANGEROUS!)
+func (s *storage) Drop() error {
+	key, rangeEnd := s.prefixRange(nil)
+
+	return s.client.call("kv/deleterange", &deleteRangeRequest{Key: key, RangeEnd: rangeEnd}, nil)
+}
+
+// Check interface
+var (
+	_ database.Storage = &storage{}
+)
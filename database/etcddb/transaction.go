@@ -0,0 +1,62 @@
+package etcddb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+)
+
+// transaction accumulates writes which are sent to etcd on commit, reads see
+// changes made in the transaction
+type transaction struct {
+	batch
+
+	// pending holds values written in transaction, nil for deleted keys
+	pending map[string][]byte
+}
+
+// Get implements database.Reader interface.
+func (t *transaction) Get(key []byte) ([]byte, error) {
+	if value, ok := t.pending[string(key)]; ok {
+		if value == nil {
+			return nil, database.ErrNotFound
+		}
+		return value, nil
+	}
+
+	return t.s.Get(key)
+}
+
+// Put implements database.Writer interface.
+func (t *transaction) Put(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	t.pending[string(key)] = append([]byte(nil), value...)
+
+	return t.batch.Put(key, value)
+}
+
+// Delete implements database.Writer interface.
+func (t *transaction) Delete(key []byte) error {
+	t.pending[string(key)] = nil
+
+	return t.batch.Delete(key)
+}
+
+// Commit finalizes transaction and sends changes to etcd.
+func (t *transaction) Commit() error {
+	err := t.batch.Write()
+	t.pending = map[string][]byte{}
+
+	return err
+}
+
+// Discard any transaction changes.
+//
+// Discard is safe to call after Commit(), it would be no-op
+func (t *transaction) Discard() {
+	t.ops = nil
+	t.pending = map[string][]byte{}
+}
+
+// transaction should implement database.Transaction
+var _ database.Transaction = &transaction{}
@@ -0,0 +1,82 @@
+package deb
+
+import (
+	"runtime"
+	"strings"
+)
+
+// debianCPUAliases maps CPU part of Debian architecture name to base CPU name
+// which could be used in any-<cpu> wildcards
+var debianCPUAliases = map[string]string{
+	"armel":      "arm",
+	"armhf":      "arm",
+	"x32":        "amd64",
+	"powerpcspe": "powerpc",
+}
+
+// goArchToDebian maps Go architecture names to Debian ones
+var goArchToDebian = map[string]string{
+	"386":      "i386",
+	"arm":      "armhf",
+	"ppc64le":  "ppc64el",
+	"mipsle":   "mipsel",
+	"mips64le": "mips64el",
+}
+
+// NativeArchitecture returns Debian name of the architecture aptly is running on
+func NativeArchitecture() string {
+	if arch, ok := goArchToDebian[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+// IsArchitectureWildcard checks whether arch is Debian architecture wildcard
+// (any, linux-any, any-arm) or special value native, which should be matched
+// with ArchitectureMatches
+func IsArchitectureWildcard(arch string) bool {
+	return arch == ArchitectureAny || arch == ArchitectureNative ||
+		strings.HasPrefix(arch, ArchitectureAny+"-") || strings.HasSuffix(arch, "-"+ArchitectureAny)
+}
+
+// splitArchitecture splits Debian architecture name into OS and CPU parts,
+// e.g. hurd-i386 is (hurd, i386), while amd64 is (linux, amd64)
+func splitArchitecture(arch string) (system, cpu string) {
+	if i := strings.LastIndex(arch, "-"); i != -1 {
+		return arch[:i], arch[i+1:]
+	}
+	return "linux", arch
+}
+
+// ArchitectureMatches checks whether architecture arch matches pattern, which could be
+// architecture name or wildcard:
+//
+//   - any matches any architecture but source and all
+//   - <os>-any matches any architecture of the OS, e.g. linux-any
+//   - any-<cpu> matches any architecture on the CPU, e.g. any-arm or any-armhf
+//   - native matches architecture aptly is running on
+func ArchitectureMatches(pattern, arch string) bool {
+	if pattern == arch {
+		return true
+	}
+
+	if arch == ArchitectureSource || arch == ArchitectureAll {
+		return false
+	}
+
+	switch {
+	case pattern == ArchitectureAny:
+		return true
+	case pattern == ArchitectureNative:
+		return arch == NativeArchitecture()
+	case strings.HasSuffix(pattern, "-"+ArchitectureAny):
+		system, _ := splitArchitecture(arch)
+		return system == strings.TrimSuffix(pattern, "-"+ArchitectureAny)
+	case strings.HasPrefix(pattern, ArchitectureAny+"-"):
+		_, cpu := splitArchitecture(arch)
+		wanted := strings.TrimPrefix(pattern, ArchitectureAny+"-")
+		return cpu == wanted || debianCPUAliases[cpu] == wanted
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type ArchitectureSuite struct{}
+
+var _ = Suite(&ArchitectureSuite{})
+
+func (s *ArchitectureSuite) TestIsArchitectureWildcard(c *C) {
+	c.Check(IsArchitectureWildcard("any"), Equals, true)
+	c.Check(IsArchitectureWildcard("native"), Equals, true)
+	c.Check(IsArchitectureWildcard("linux-any"), Equals, true)
+	c.Check(IsArchitectureWildcard("any-armhf"), Equals, true)
+	c.Check(IsArchitectureWildcard("amd64"), Equals, false)
+	c.Check(IsArchitectureWildcard("all"), Equals, false)
+	c.Check(IsArchitectureWildcard("hurd-i386"), Equals, false)
+}
+
+func (s *ArchitectureSuite) TestArchitectureMatches(c *C) {
+	c.Check(ArchitectureMatches("amd64", "amd64"), Equals, true)
+	c.Check(ArchitectureMatches("amd64", "i386"), Equals, false)
+	c.Check(ArchitectureMatches("all", "all"), Equals, true)
+	c.Check(ArchitectureMatches("source", "source"), Equals, true)
+
+	c.Check(ArchitectureMatches("any", "amd64"), Equals, true)
+	c.Check(ArchitectureMatches("any", "hurd-i386"), Equals, true)
+	c.Check(ArchitectureMatches("any", "all"), Equals, false)
+	c.Check(ArchitectureMatches("any", "source"), Equals, false)
+
+	c.Check(ArchitectureMatches("linux-any", "amd64"), Equals, true)
+	c.Check(ArchitectureMatches("linux-any", "armhf"), Equals, true)
+	c.Check(ArchitectureMatches("linux-any", "hurd-i386"), Equals, false)
+	c.Check(ArchitectureMatches("kfreebsd-any", "kfreebsd-amd64"), Equals, true)
+	c.Check(ArchitectureMatches("kfreebsd-any", "amd64"), Equals, false)
+
+	c.Check(ArchitectureMatches("any-armhf", "armhf"), Equals, true)
+	c.Check(ArchitectureMatches("any-armhf", "armel"), Equals, false)
+	c.Check(ArchitectureMatches("any-arm", "armel"), Equals, true)
+	c.Check(ArchitectureMatches("any-arm", "arm64"), Equals, false)
+	c.Check(ArchitectureMatches("any-i386", "hurd-i386"), Equals, true)
+	c.Check(ArchitectureMatches("any-i386", "amd64"), Equals, false)
+
+	c.Check(ArchitectureMatches("native", NativeArchitecture()), Equals, true)
+	c.Check(ArchitectureMatches("native", "source"), Equals, false)
+}
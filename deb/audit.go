@@ -0,0 +1,261 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/pborman/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+// Audit sources
+const (
+	AuditSourceCLI = "cli"
+	AuditSourceAPI = "api"
+	// AuditSourceScheduler is used for scheduled mirror updates run by API server
+	AuditSourceScheduler = "scheduler"
+)
+
+// Audit actions on entities
+const (
+	AuditActionCreated = "created"
+	AuditActionUpdated = "updated"
+	AuditActionDropped = "dropped"
+)
+
+// Kinds of audited entities
+const (
+	AuditKindLocalRepo  = "local"
+	AuditKindMirror     = "mirror"
+	AuditKindSnapshot   = "snapshot"
+	AuditKindPublished  = "published"
+	AuditKindPackageSet = "package-set"
+)
+
+// AuditChange is a change of single entity made by audited operation
+type AuditChange struct {
+	Action string
+	Kind   string
+	Name   string
+	UUID   string
+}
+
+// AuditRecorder collects changes made via collections of CollectionFactory
+//
+// nil *AuditRecorder is valid and records nothing.
+type AuditRecorder struct {
+	sync.Mutex
+	changes []AuditChange
+}
+
+// NewAuditRecorder creates empty AuditRecorder
+func NewAuditRecorder() *AuditRecorder {
+	return &AuditRecorder{}
+}
+
+// Record registers change of entity, repeated changes of the same entity are folded
+// into single change (creation or removal takes precedence over update)
+func (r *AuditRecorder) Record(action, kind, name, uuid string) {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for i := range r.changes {
+		if r.changes[i].Kind == kind && r.changes[i].UUID == uuid {
+			if action != AuditActionUpdated {
+				r.changes[i].Action = action
+			}
+			r.changes[i].Name = name
+			return
+		}
+	}
+
+	r.changes = append(r.changes, AuditChange{Action: action, Kind: kind, Name: name, UUID: uuid})
+}
+
+// Changes returns list of recorded changes
+func (r *AuditRecorder) Changes() []AuditChange {
+	if r == nil {
+		return nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]AuditChange(nil), r.changes...)
+}
+
+// AuditEntry is a record of single mutating operation
+type AuditEntry struct {
+	UUID      string
+	Timestamp time.Time
+	// Source is one of AuditSourceCLI, AuditSourceAPI or AuditSourceScheduler
+	Source string
+	// Actor is the user who performed the operation: OS user for CLI, name of the
+	// API token (or remote address) for API
+	Actor string
+	// Operation is CLI command or API method and path
+	Operation string
+	// Arguments of CLI command or parameters of API route
+	Arguments []string `codec:",omitempty" json:",omitempty"`
+	// Changes is a list of affected entities with their UUIDs
+	Changes []AuditChange `codec:",omitempty" json:",omitempty"`
+	// Status is HTTP status code of API operation
+	Status int `codec:",omitempty" json:",omitempty"`
+	// Error is set if operation failed
+	Error string `codec:",omitempty" json:",omitempty"`
+}
+
+// NewAuditEntry creates new audit entry for the operation
+func NewAuditEntry(source, actor, operation string, arguments []string) *AuditEntry {
+	return &AuditEntry{
+		UUID:      uuid.New(),
+		Timestamp: time.Now().UTC(),
+		Source:    source,
+		Actor:     actor,
+		Operation: operation,
+		Arguments: arguments,
+	}
+}
+
+// Key is a unique id in DB, keys are ordered by time
+func (entry *AuditEntry) Key() []byte {
+	return []byte(fmt.Sprintf("H%020d%s", entry.Timestamp.UnixNano(), entry.UUID))
+}
+
+// Encode does msgpack encoding of AuditEntry
+func (entry *AuditEntry) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	encoder.Encode(entry)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into AuditEntry
+func (entry *AuditEntry) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(entry)
+}
+
+// AuditFilter selects audit entries
+type AuditFilter struct {
+	// Since and Until limit time range (zero means no limit)
+	Since, Until time.Time
+	// Actor should match exactly, if set
+	Actor string
+	// Operation should contain the string, if set
+	Operation string
+	// Entity is name or UUID of affected entity, if set
+	Entity string
+	// Limit is maximum number of entries returned (0 means no limit)
+	Limit int
+}
+
+// Matches checks whether entry passes the filter
+func (filter *AuditFilter) Matches(entry *AuditEntry) bool {
+	if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && entry.Timestamp.After(filter.Until) {
+		return false
+	}
+	if filter.Actor != "" && entry.Actor != filter.Actor {
+		return false
+	}
+	if filter.Operation != "" && !strings.Contains(entry.Operation, filter.Operation) {
+		return false
+	}
+	if filter.Entity != "" {
+		for _, change := range entry.Changes {
+			if change.Name == filter.Entity || change.UUID == filter.Entity {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// AuditCollection is a collection of audit entries
+type AuditCollection struct {
+	db database.Storage
+}
+
+// NewAuditCollection creates AuditCollection
+func NewAuditCollection(db database.Storage) *AuditCollection {
+	return &AuditCollection{db: db}
+}
+
+// Add appends entry to the audit log
+func (collection *AuditCollection) Add(entry *AuditEntry) error {
+	return collection.db.Put(entry.Key(), entry.Encode())
+}
+
+// Query returns entries matching the filter, most recent first
+func (collection *AuditCollection) Query(filter AuditFilter) ([]*AuditEntry, error) {
+	result := []*AuditEntry{}
+
+	err := collection.db.ProcessByPrefix([]byte("H"), func(_, blob []byte) error {
+		entry := &AuditEntry{}
+		if err := entry.Decode(blob); err != nil {
+			return fmt.Errorf("unable to decode audit entry: %s", err)
+		}
+
+		if filter.Matches(entry) {
+			result = append(result, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+// Prune removes entries recorded before the deadline, returns number of removed entries
+func (collection *AuditCollection) Prune(deadline time.Time) (int, error) {
+	// keys are ordered by time, so key of the entry recorded at the deadline is the limit
+	limit := []byte(fmt.Sprintf("H%020d", deadline.UnixNano()))
+
+	batch := collection.db.CreateBatch()
+	removed := 0
+
+	for _, key := range collection.db.KeysByPrefix([]byte("H")) {
+		if bytes.Compare(key, limit) >= 0 {
+			continue
+		}
+
+		err := batch.Delete(key)
+		if err != nil {
+			return 0, err
+		}
+		removed++
+	}
+
+	return removed, batch.Write()
+}
+
+// Len returns number of entries in the audit log
+func (collection *AuditCollection) Len() int {
+	return len(collection.db.KeysByPrefix([]byte("H")))
+}
@@ -0,0 +1,140 @@
+package deb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type AuditRecorderSuite struct{}
+
+var _ = Suite(&AuditRecorderSuite{})
+
+func (s *AuditRecorderSuite) TestRecord(c *C) {
+	var nilRecorder *AuditRecorder
+	nilRecorder.Record(AuditActionCreated, AuditKindSnapshot, "snap", "uuid0")
+	c.Check(nilRecorder.Changes(), IsNil)
+
+	recorder := NewAuditRecorder()
+	recorder.Record(AuditActionCreated, AuditKindLocalRepo, "repo", "uuid1")
+	recorder.Record(AuditActionUpdated, AuditKindLocalRepo, "repo2", "uuid1")
+	recorder.Record(AuditActionUpdated, AuditKindSnapshot, "snap", "uuid2")
+	recorder.Record(AuditActionDropped, AuditKindSnapshot, "snap", "uuid2")
+
+	c.Check(recorder.Changes(), DeepEquals, []AuditChange{
+		{Action: AuditActionCreated, Kind: AuditKindLocalRepo, Name: "repo2", UUID: "uuid1"},
+		{Action: AuditActionDropped, Kind: AuditKindSnapshot, Name: "snap", UUID: "uuid2"},
+	})
+}
+
+type AuditCollectionSuite struct {
+	db         database.Storage
+	collection *AuditCollection
+}
+
+var _ = Suite(&AuditCollectionSuite{})
+
+func (s *AuditCollectionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewAuditCollection(s.db)
+}
+
+func (s *AuditCollectionSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *AuditCollectionSuite) TestEncodeDecode(c *C) {
+	entry := NewAuditEntry(AuditSourceCLI, "john", "repo create", []string{"repo1"})
+	entry.Changes = []AuditChange{{Action: AuditActionCreated, Kind: AuditKindLocalRepo, Name: "repo1", UUID: "uuid1"}}
+
+	decoded := &AuditEntry{}
+	c.Assert(decoded.Decode(entry.Encode()), IsNil)
+	c.Check(decoded.Timestamp.Equal(entry.Timestamp), Equals, true)
+	decoded.Timestamp = entry.Timestamp
+	c.Check(decoded, DeepEquals, entry)
+}
+
+func (s *AuditCollectionSuite) TestQuery(c *C) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, spec := range []struct{ actor, operation, name string }{
+		{"john", "repo create", "repo1"},
+		{"jane", "snapshot create", "snap1"},
+		{"john", "POST /api/repos", "repo2"},
+		{"jane", "repo drop", "repo1"},
+	} {
+		entry := NewAuditEntry(AuditSourceCLI, spec.actor, spec.operation, nil)
+		entry.Timestamp = base.Add(time.Duration(i) * time.Hour)
+		entry.Changes = []AuditChange{{Action: AuditActionCreated, Kind: AuditKindLocalRepo, Name: spec.name, UUID: spec.name + "-uuid"}}
+		c.Assert(s.collection.Add(entry), IsNil)
+	}
+
+	c.Check(s.collection.Len(), Equals, 4)
+
+	operations := func(filter AuditFilter) (result []string) {
+		entries, err := s.collection.Query(filter)
+		c.Assert(err, IsNil)
+		for _, entry := range entries {
+			result = append(result, entry.Operation)
+		}
+		return
+	}
+
+	c.Check(operations(AuditFilter{}), DeepEquals, []string{"repo drop", "POST /api/repos", "snapshot create", "repo create"})
+	c.Check(operations(AuditFilter{Limit: 2}), DeepEquals, []string{"repo drop", "POST /api/repos"})
+	c.Check(operations(AuditFilter{Actor: "john"}), DeepEquals, []string{"POST /api/repos", "repo create"})
+	c.Check(operations(AuditFilter{Operation: "create"}), DeepEquals, []string{"snapshot create", "repo create"})
+	c.Check(operations(AuditFilter{Entity: "repo1"}), DeepEquals, []string{"repo drop", "repo create"})
+	c.Check(operations(AuditFilter{Entity: "snap1-uuid"}), DeepEquals, []string{"snapshot create"})
+	c.Check(operations(AuditFilter{Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)}), DeepEquals,
+		[]string{"POST /api/repos", "snapshot create"})
+	c.Check(operations(AuditFilter{Actor: "nobody"}), IsNil)
+}
+
+func (s *AuditCollectionSuite) TestPrune(c *C) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		entry := NewAuditEntry(AuditSourceCLI, "john", fmt.Sprintf("repo create repo%d", i), nil)
+		entry.Timestamp = base.Add(time.Duration(i) * time.Hour)
+		c.Assert(s.collection.Add(entry), IsNil)
+	}
+
+	removed, err := s.collection.Prune(base.Add(2 * time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(removed, Equals, 2)
+	c.Check(s.collection.Len(), Equals, 2)
+
+	entries, err := s.collection.Query(AuditFilter{})
+	c.Assert(err, IsNil)
+	c.Check(entries[1].Operation, Equals, "repo create repo2")
+
+	removed, err = s.collection.Prune(base)
+	c.Assert(err, IsNil)
+	c.Check(removed, Equals, 0)
+}
+
+func (s *AuditCollectionSuite) TestCollectionsRecordChanges(c *C) {
+	recorder := NewAuditRecorder()
+	factory := NewCollectionFactory(s.db)
+	factory.SetAuditRecorder(recorder)
+
+	repo := NewLocalRepo("repo1", "")
+	c.Assert(factory.LocalRepoCollection().Add(repo), IsNil)
+	repo.Comment = "updated"
+	c.Assert(factory.LocalRepoCollection().Update(repo), IsNil)
+
+	snapshot, err := NewSnapshotFromLocalRepo("snap1", repo)
+	c.Assert(err, IsNil)
+	c.Assert(factory.SnapshotCollection().Add(snapshot), IsNil)
+	c.Assert(factory.SnapshotCollection().Drop(snapshot), IsNil)
+
+	c.Check(recorder.Changes(), DeepEquals, []AuditChange{
+		{Action: AuditActionCreated, Kind: AuditKindLocalRepo, Name: "repo1", UUID: repo.UUID},
+		{Action: AuditActionDropped, Kind: AuditKindSnapshot, Name: "snap1", UUID: snapshot.UUID},
+	})
+}
@@ -0,0 +1,228 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	ar "github.com/mkrautz/goar"
+	"github.com/pkg/errors"
+)
+
+// maxChangelogSize limits size of (uncompressed) changelog kept in the database
+const maxChangelogSize = 4 * 1024 * 1024
+
+// PackageChangelog is Debian changelog and NEWS file shipped with binary package
+type PackageChangelog struct {
+	Changelog string
+	News      string
+}
+
+// ChangelogEntry is single entry of Debian changelog (or NEWS file)
+type ChangelogEntry struct {
+	Package      string
+	Version      string
+	Distribution string
+	Urgency      string
+	Maintainer   string
+	Date         string
+	// Text is complete entry as it appears in the changelog
+	Text string
+}
+
+var (
+	changelogHeaderRegexp  = regexp.MustCompile(`^(\S+) \(([^)]+)\) ([^;]*);\s*(.*)$`)
+	changelogTrailerRegexp = regexp.MustCompile(`^ -- (.*?)  (.*)$`)
+)
+
+// GetChangelogFromDeb extracts changelog and NEWS file of package name from .deb package,
+// missing files result in empty changelog
+func GetChangelogFromDeb(file io.Reader, packageFile string, name string) (*PackageChangelog, error) {
+	docDir := "usr/share/doc/" + name + "/"
+	result := &PackageChangelog{}
+
+	library := ar.NewReader(file)
+	for {
+		header, err := library.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("unable to find data.tar.* part in %s", packageFile)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
+		}
+
+		if !strings.HasPrefix(header.Name, "data.tar") {
+			continue
+		}
+
+		untar, closer, err := newDebTarReader(header.Name, library, packageFile)
+		if err != nil {
+			return nil, err
+		}
+		defer closer()
+
+		var nativeChangelog string
+		for {
+			tarHeader, err := untar.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+			}
+
+			if tarHeader.Typeflag != tar.TypeReg {
+				continue
+			}
+
+			var target *string
+			switch debTarPath(tarHeader.Name) {
+			case docDir + "changelog.Debian.gz":
+				target = &result.Changelog
+			case docDir + "changelog.gz":
+				target = &nativeChangelog
+			case docDir + "NEWS.Debian.gz":
+				target = &result.News
+			default:
+				continue
+			}
+
+			*target, err = readGzippedText(untar)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read %s from %s", tarHeader.Name, packageFile)
+			}
+		}
+
+		// native packages don't have separate Debian changelog
+		if result.Changelog == "" {
+			result.Changelog = nativeChangelog
+		}
+
+		return result, nil
+	}
+}
+
+func readGzippedText(r io.Reader) (string, error) {
+	ungzip, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer ungzip.Close()
+
+	data, err := io.ReadAll(io.LimitReader(ungzip, maxChangelogSize))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// ParseChangelog splits Debian changelog (or NEWS file) into entries, most recent first
+func ParseChangelog(text string) []ChangelogEntry {
+	result := []ChangelogEntry{}
+
+	var (
+		entry *ChangelogEntry
+		lines []string
+	)
+
+	finish := func() {
+		if entry != nil {
+			entry.Text = strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+			result = append(result, *entry)
+		}
+		entry, lines = nil, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(nil, maxChangelogSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if matches := changelogHeaderRegexp.FindStringSubmatch(line); matches != nil {
+			finish()
+
+			entry = &ChangelogEntry{
+				Package:      matches[1],
+				Version:      matches[2],
+				Distribution: strings.TrimSpace(matches[3]),
+			}
+			for _, field := range strings.Split(matches[4], ",") {
+				if kv := strings.SplitN(strings.TrimSpace(field), "=", 2); len(kv) == 2 && strings.EqualFold(kv[0], "urgency") {
+					entry.Urgency = kv[1]
+				}
+			}
+		}
+
+		if entry == nil {
+			// text outside of entries, e.g. trailing editor settings
+			continue
+		}
+
+		lines = append(lines, line)
+
+		if matches := changelogTrailerRegexp.FindStringSubmatch(line); matches != nil {
+			entry.Maintainer = matches[1]
+			entry.Date = matches[2]
+			finish()
+		}
+	}
+	finish()
+
+	return result
+}
+
+// ChangelogEntriesSince returns entries for versions newer than version since,
+// with empty since all the entries are returned
+func ChangelogEntriesSince(entries []ChangelogEntry, since string) []ChangelogEntry {
+	if since == "" {
+		return entries
+	}
+
+	result := []ChangelogEntry{}
+	for _, entry := range entries {
+		if CompareVersions(entry.Version, since) > 0 {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+// PublishedVersion returns most recent version of package name for architecture arch
+// in published repository, empty string is returned if package is not published
+//
+// Published repository should be loaded with LoadComplete.
+func PublishedVersion(published *PublishedRepo, name, arch string) string {
+	result := ""
+
+	for _, component := range published.Components() {
+		refs := published.RefList(component)
+		if refs == nil {
+			continue
+		}
+
+		_ = refs.ForEach(func(key []byte) error {
+			// package key is P<arch> <name> <version> [<hash>]
+			parts := strings.Split(string(key[1:]), " ")
+			if len(parts) < 3 || parts[1] != name {
+				return nil
+			}
+
+			if parts[0] != arch && parts[0] != ArchitectureAll && arch != ArchitectureAll {
+				return nil
+			}
+
+			if result == "" || CompareVersions(parts[2], result) > 0 {
+				result = parts[2]
+			}
+			return nil
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,139 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+
+	. "gopkg.in/check.v1"
+)
+
+type ChangelogSuite struct {
+	debFile string
+}
+
+var _ = Suite(&ChangelogSuite{})
+
+const testChangelog = `hello (2.0-1) unstable; urgency=medium
+
+  * New upstream release.
+
+ -- Jane Doe <jane@example.com>  Mon, 02 Jan 2023 10:00:00 +0000
+
+hello (1.1-1) unstable; urgency=low
+
+  * Fix crash on empty input.
+
+ -- Jane Doe <jane@example.com>  Sun, 01 Jan 2023 10:00:00 +0000
+
+hello (1.0-1) experimental; urgency=low, binary-only=yes
+
+  * Initial release.
+
+ -- John Doe <john@example.com>  Sat, 31 Dec 2022 10:00:00 +0000
+
+Local variables:
+mode: debian-changelog
+End:
+`
+
+func (s *ChangelogSuite) SetUpSuite(c *C) {
+	_, _File, _, _ := runtime.Caller(0)
+	s.debFile = filepath.Join(filepath.Dir(_File), "../system/changes/hardlink_0.2.1_amd64.deb")
+}
+
+func (s *ChangelogSuite) TestGetChangelogFromDeb(c *C) {
+	f, err := os.Open(s.debFile)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	changelog, err := GetChangelogFromDeb(f, s.debFile, "hardlink")
+	c.Assert(err, IsNil)
+
+	entries := ParseChangelog(changelog.Changelog)
+	c.Assert(entries, HasLen, 6)
+	c.Check(entries[0].Version, Equals, "0.2.1")
+	c.Check(entries[0].Maintainer, Equals, "Aptly Tester (don't use it) <test@aptly.info>")
+
+	news := ParseChangelog(changelog.News)
+	c.Assert(news, HasLen, 1)
+	c.Check(news[0].Version, Equals, "0.2.0~rc1")
+
+	_, err = f.Seek(0, 0)
+	c.Assert(err, IsNil)
+
+	changelog, err = GetChangelogFromDeb(f, s.debFile, "other")
+	c.Assert(err, IsNil)
+	c.Check(changelog.Changelog, Equals, "")
+	c.Check(changelog.News, Equals, "")
+}
+
+func (s *ChangelogSuite) TestParseChangelog(c *C) {
+	entries := ParseChangelog(testChangelog)
+	c.Assert(entries, HasLen, 3)
+
+	c.Check(entries[0].Package, Equals, "hello")
+	c.Check(entries[0].Version, Equals, "2.0-1")
+	c.Check(entries[0].Distribution, Equals, "unstable")
+	c.Check(entries[0].Urgency, Equals, "medium")
+	c.Check(entries[0].Maintainer, Equals, "Jane Doe <jane@example.com>")
+	c.Check(entries[0].Date, Equals, "Mon, 02 Jan 2023 10:00:00 +0000")
+	c.Check(entries[0].Text, Equals, "hello (2.0-1) unstable; urgency=medium\n\n  * New upstream release.\n\n"+
+		" -- Jane Doe <jane@example.com>  Mon, 02 Jan 2023 10:00:00 +0000\n")
+
+	c.Check(entries[2].Distribution, Equals, "experimental")
+	c.Check(entries[2].Urgency, Equals, "low")
+
+	c.Check(ParseChangelog(""), HasLen, 0)
+}
+
+func (s *ChangelogSuite) TestChangelogEntriesSince(c *C) {
+	entries := ParseChangelog(testChangelog)
+
+	c.Check(ChangelogEntriesSince(entries, ""), HasLen, 3)
+	c.Check(ChangelogEntriesSince(entries, "2.0-1"), HasLen, 0)
+
+	since := ChangelogEntriesSince(entries, "1.0-1")
+	c.Assert(since, HasLen, 2)
+	c.Check(since[0].Version, Equals, "2.0-1")
+	c.Check(since[1].Version, Equals, "1.1-1")
+}
+
+func (s *ChangelogSuite) TestPackageChangelog(c *C) {
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	defer db.Close()
+
+	collection := NewPackageCollection(db)
+	packagePool := files.NewPackagePool(c.MkDir(), false)
+
+	list := NewPackageList()
+	_, failed, _, err := ImportPackageFiles(list, []string{s.debFile}, ConflictFail, nil, packagePool, collection,
+		&aptly.RecordingResultReporter{}, nil, func(database.ReaderWriter) aptly.ChecksumStorage { return files.NewMockChecksumStorage() })
+	c.Assert(err, IsNil)
+	c.Assert(failed, HasLen, 0)
+
+	p, err := collection.ByKey([]byte(list.Strings()[0]))
+	c.Assert(err, IsNil)
+
+	changelog, err := p.Changelog(packagePool)
+	c.Assert(err, IsNil)
+	c.Check(ParseChangelog(changelog.Changelog), HasLen, 6)
+
+	// changelog is extracted from the pool if it's missing
+	c.Assert(db.Delete(p.Key("xL")), IsNil)
+	changelog, err = p.Changelog(packagePool)
+	c.Assert(err, IsNil)
+	c.Check(ParseChangelog(changelog.News), HasLen, 1)
+
+	_, err = db.Get(p.Key("xL"))
+	c.Check(err, IsNil)
+
+	c.Assert(collection.DeleteByKey(p.Key(""), db), IsNil)
+	_, err = db.Get(p.Key("xL"))
+	c.Check(err, Equals, database.ErrNotFound)
+}
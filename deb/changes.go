@@ -129,12 +129,17 @@ func (c *Changes) Prepare() error {
 		}
 	}
 
-	for _, file := range c.Files {
-		var info utils.ChecksumInfo
+	paths := make([]string, len(c.Files))
+	for i, file := range c.Files {
+		paths[i] = filepath.Join(c.TempDir, file.Filename)
+	}
 
-		info, err = utils.ChecksumsForFile(filepath.Join(c.TempDir, file.Filename))
-		if err != nil {
-			return err
+	infos, errs := utils.ChecksumsForFiles(paths, 0)
+
+	for i, file := range c.Files {
+		info := infos[i]
+		if errs[i] != nil {
+			return errs[i]
 		}
 
 		if info.Size != file.Checksums.Size {
@@ -369,13 +374,19 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to load packages: %s", err)
 		}
+		repo.HoldPackages(list)
 
 		packageFiles, otherFiles, _ := CollectPackageFiles([]string{changes.TempDir}, reporter)
 
 		restriction := changes.PackageQuery()
 		var processedFiles2, failedFiles2 []string
 
-		processedFiles2, failedFiles2, err = ImportPackageFiles(list, packageFiles, forceReplace, verifier, pool,
+		onConflict := ConflictFail
+		if forceReplace {
+			onConflict = ConflictReplace
+		}
+
+		processedFiles2, failedFiles2, _, err = ImportPackageFiles(list, packageFiles, onConflict, verifier, pool,
 			packageCollection, reporter, restriction, checksumStorageProvider)
 
 		if err != nil {
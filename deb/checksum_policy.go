@@ -0,0 +1,201 @@
+package deb
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Checksum policies define how files downloaded for the mirror are verified, by default
+// all the checksums listed for the file (in Release file or package index) should match
+const (
+	// ChecksumPolicyStrict requires SHA256 or SHA512 checksum to be listed for every file,
+	// all the checksums listed should match
+	ChecksumPolicyStrict = "strict"
+	// ChecksumPolicyPermissive verifies only the strongest checksum listed for the file,
+	// mismatches of weaker checksums and missing SHA256/SHA512 checksums produce warnings
+	ChecksumPolicyPermissive = "permissive"
+)
+
+// ValidateChecksumPolicy checks that policy is one of supported checksum policies
+func ValidateChecksumPolicy(policy string) error {
+	switch policy {
+	case "", ChecksumPolicyStrict, ChecksumPolicyPermissive:
+		return nil
+	}
+
+	return fmt.Errorf("unknown checksum policy %s, should be one of %s, %s", policy, ChecksumPolicyStrict, ChecksumPolicyPermissive)
+}
+
+// hasStrongChecksum checks whether SHA256 or SHA512 checksum is known
+func hasStrongChecksum(cksum utils.ChecksumInfo) bool {
+	return cksum.SHA256 != "" || cksum.SHA512 != ""
+}
+
+// strongestChecksum leaves only size and the strongest checksum known
+func strongestChecksum(cksum utils.ChecksumInfo) utils.ChecksumInfo {
+	result := utils.ChecksumInfo{Size: cksum.Size}
+
+	switch {
+	case cksum.SHA512 != "":
+		result.SHA512 = cksum.SHA512
+	case cksum.SHA256 != "":
+		result.SHA256 = cksum.SHA256
+	case cksum.SHA1 != "":
+		result.SHA1 = cksum.SHA1
+	default:
+		result.MD5 = cksum.MD5
+	}
+
+	return result
+}
+
+// ExpectedChecksums returns checksums downloaded file should be verified against according
+// to checksum policy of the mirror, listed are checksums from Release file or package index
+func (repo *RemoteRepo) ExpectedChecksums(listed utils.ChecksumInfo) (utils.ChecksumInfo, error) {
+	switch repo.ChecksumPolicy {
+	case ChecksumPolicyStrict:
+		if !hasStrongChecksum(listed) {
+			return listed, fmt.Errorf("no SHA256 or SHA512 checksum listed, required by strict checksum policy")
+		}
+	case ChecksumPolicyPermissive:
+		return strongestChecksum(listed), nil
+	}
+
+	return listed, nil
+}
+
+// releaseFilesForPolicy returns checksums of index files listed in Release file to verify
+// downloaded index files against according to checksum policy
+func (repo *RemoteRepo) releaseFilesForPolicy() (map[string]utils.ChecksumInfo, error) {
+	if repo.ChecksumPolicy == "" {
+		return repo.ReleaseFiles, nil
+	}
+
+	result := make(map[string]utils.ChecksumInfo, len(repo.ReleaseFiles))
+	for path, listed := range repo.ReleaseFiles {
+		expected, err := repo.ExpectedChecksums(listed)
+		if err != nil {
+			// file can't be verified, so it's not a candidate for download
+			continue
+		}
+		result[path] = expected
+	}
+
+	if len(result) == 0 && len(repo.ReleaseFiles) > 0 {
+		return nil, fmt.Errorf("Release file doesn't list SHA256 or SHA512 checksums, required by strict checksum policy")
+	}
+
+	return result, nil
+}
+
+// DownloadPackageFile downloads package file of the download task to task.TempDownPath,
+// verifying it according to checksum policy of the mirror
+//
+// If mirror has quarantine enabled, package file failing verification is moved to
+// quarantineDir and task is marked as quarantined instead of returning an error
+func (repo *RemoteRepo) DownloadPackageFile(ctx gocontext.Context, d aptly.Downloader, task *PackageDownloadTask, ignoreChecksums bool,
+	quarantineDir string, progress aptly.Progress) error {
+	url := repo.PackageURL(task.File.DownloadURL()).String()
+
+	expected, err := repo.ExpectedChecksums(task.File.Checksums)
+	if err != nil && !ignoreChecksums {
+		return repo.quarantine(task, quarantineDir, fmt.Errorf("%s: %s", url, err), progress)
+	}
+
+	if repo.Quarantine && !ignoreChecksums {
+		// downloaded file is verified separately, so that it is kept if verification fails
+		err = d.DownloadWithChecksum(ctx, url, task.TempDownPath, nil, false)
+		if err != nil {
+			return err
+		}
+
+		var actual utils.ChecksumInfo
+		actual, err = utils.ChecksumsForFile(task.TempDownPath)
+		if err != nil {
+			return err
+		}
+
+		if err = expected.Verify(actual); err != nil {
+			return repo.quarantine(task, quarantineDir, fmt.Errorf("%s: %s", url, err), progress)
+		}
+		expected = actual
+	} else {
+		err = d.DownloadWithChecksum(ctx, url, task.TempDownPath, &expected, ignoreChecksums)
+		if err != nil {
+			return err
+		}
+	}
+
+	if repo.ChecksumPolicy == ChecksumPolicyPermissive && progress != nil {
+		if !hasStrongChecksum(task.File.Checksums) {
+			progress.ColoredPrintf("@y[!]@| @!%s: no SHA256 or SHA512 checksum listed@|", url)
+		} else if err = task.File.Checksums.Verify(expected); err != nil && expected.Complete() {
+			progress.ColoredPrintf("@y[!]@| @!%s: %s@|", url, err)
+		}
+	}
+
+	if expected.Complete() {
+		// download process has filled in actual checksums
+		task.File.Checksums = expected
+	}
+
+	return nil
+}
+
+// quarantine marks download task as failing verification, downloaded file (if any) is moved
+// to quarantineDir; reason is returned as error if mirror doesn't have quarantine enabled
+func (repo *RemoteRepo) quarantine(task *PackageDownloadTask, quarantineDir string, reason error, progress aptly.Progress) error {
+	if !repo.Quarantine {
+		return reason
+	}
+
+	if _, err := os.Stat(task.TempDownPath); err == nil {
+		err = os.MkdirAll(quarantineDir, 0777)
+		if err != nil {
+			return fmt.Errorf("unable to create quarantine directory: %s", err)
+		}
+
+		quarantinePath := filepath.Join(quarantineDir, filepath.Base(task.File.Filename))
+		if err = os.Rename(task.TempDownPath, quarantinePath); err != nil {
+			if err = utils.CopyFile(task.TempDownPath, quarantinePath); err != nil {
+				return fmt.Errorf("unable to move %s to quarantine: %s", task.File.Filename, err)
+			}
+			_ = os.Remove(task.TempDownPath)
+		}
+	}
+
+	task.Quarantined = true
+	if progress != nil {
+		progress.ColoredPrintf("@y[!]@| @!%s, file quarantined@|", reason)
+	}
+
+	return nil
+}
+
+// SkipQuarantined removes packages which have files failing verification (see DownloadPackageFile)
+// from the mirror being updated, returns list of removed packages
+func (repo *RemoteRepo) SkipQuarantined(queue []PackageDownloadTask) []string {
+	var skipped []string
+
+	for i := range queue {
+		if !queue[i].Quarantined {
+			continue
+		}
+
+		for _, task := range append([]PackageDownloadTask{queue[i]}, queue[i].Additional...) {
+			if task.Package == nil || !repo.packageList.Has(task.Package) {
+				continue
+			}
+
+			repo.packageList.Remove(task.Package)
+			skipped = append(skipped, task.Package.String())
+		}
+	}
+
+	return skipped
+}
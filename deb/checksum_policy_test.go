@@ -0,0 +1,173 @@
+package deb
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type ChecksumPolicySuite struct {
+	repo     *RemoteRepo
+	tempDir  string
+	contents string
+	actual   utils.ChecksumInfo
+}
+
+var _ = Suite(&ChecksumPolicySuite{})
+
+func (s *ChecksumPolicySuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian", "squeeze", []string{"main"}, []string{}, false, false, false)
+	s.tempDir = c.MkDir()
+	s.contents = "package contents"
+
+	path := filepath.Join(s.tempDir, "reference")
+	c.Assert(os.WriteFile(path, []byte(s.contents), 0644), IsNil)
+	s.actual, _ = utils.ChecksumsForFile(path)
+}
+
+func (s *ChecksumPolicySuite) task(checksums utils.ChecksumInfo) *PackageDownloadTask {
+	return &PackageDownloadTask{
+		File: &PackageFile{
+			Filename:     "pkg_1.0_amd64.deb",
+			Checksums:    checksums,
+			downloadPath: "pool/main/p/pkg",
+		},
+		TempDownPath: filepath.Join(s.tempDir, "partial", "pkg_1.0_amd64.deb"),
+	}
+}
+
+func (s *ChecksumPolicySuite) downloader() *http.FakeDownloader {
+	return http.NewFakeDownloader().ExpectResponse("http://mirror.yandex.ru/debian/pool/main/p/pkg/pkg_1.0_amd64.deb", s.contents)
+}
+
+func (s *ChecksumPolicySuite) TestValidateChecksumPolicy(c *C) {
+	c.Check(ValidateChecksumPolicy(""), IsNil)
+	c.Check(ValidateChecksumPolicy("strict"), IsNil)
+	c.Check(ValidateChecksumPolicy("permissive"), IsNil)
+	c.Check(ValidateChecksumPolicy("paranoid"), ErrorMatches, "unknown checksum policy paranoid.*")
+}
+
+func (s *ChecksumPolicySuite) TestExpectedChecksums(c *C) {
+	weak := utils.ChecksumInfo{Size: 10, MD5: "md5", SHA1: "sha1"}
+	full := utils.ChecksumInfo{Size: 10, MD5: "md5", SHA1: "sha1", SHA256: "sha256"}
+
+	expected, err := s.repo.ExpectedChecksums(weak)
+	c.Check(err, IsNil)
+	c.Check(expected, DeepEquals, weak)
+
+	s.repo.ChecksumPolicy = ChecksumPolicyStrict
+	_, err = s.repo.ExpectedChecksums(weak)
+	c.Check(err, ErrorMatches, "no SHA256 or SHA512 checksum listed.*")
+	expected, err = s.repo.ExpectedChecksums(full)
+	c.Check(err, IsNil)
+	c.Check(expected, DeepEquals, full)
+
+	s.repo.ChecksumPolicy = ChecksumPolicyPermissive
+	expected, err = s.repo.ExpectedChecksums(weak)
+	c.Check(err, IsNil)
+	c.Check(expected, DeepEquals, utils.ChecksumInfo{Size: 10, SHA1: "sha1"})
+	expected, err = s.repo.ExpectedChecksums(full)
+	c.Check(err, IsNil)
+	c.Check(expected, DeepEquals, utils.ChecksumInfo{Size: 10, SHA256: "sha256"})
+}
+
+func (s *ChecksumPolicySuite) TestReleaseFilesForPolicy(c *C) {
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		"main/binary-amd64/Packages":    {Size: 10, MD5: "md5", SHA256: "sha256"},
+		"main/binary-amd64/Packages.gz": {Size: 5, MD5: "md5"},
+	}
+
+	files, err := s.repo.releaseFilesForPolicy()
+	c.Check(err, IsNil)
+	c.Check(files, HasLen, 2)
+
+	s.repo.ChecksumPolicy = ChecksumPolicyStrict
+	files, err = s.repo.releaseFilesForPolicy()
+	c.Check(err, IsNil)
+	c.Check(files, DeepEquals, map[string]utils.ChecksumInfo{
+		"main/binary-amd64/Packages": {Size: 10, MD5: "md5", SHA256: "sha256"},
+	})
+
+	delete(s.repo.ReleaseFiles, "main/binary-amd64/Packages")
+	_, err = s.repo.releaseFilesForPolicy()
+	c.Check(err, ErrorMatches, "Release file doesn't list SHA256 or SHA512 checksums.*")
+}
+
+func (s *ChecksumPolicySuite) TestDownloadPackageFileStrict(c *C) {
+	s.repo.ChecksumPolicy = ChecksumPolicyStrict
+
+	task := s.task(utils.ChecksumInfo{Size: s.actual.Size, MD5: s.actual.MD5})
+	err := s.repo.DownloadPackageFile(gocontext.TODO(), s.downloader(), task, false, filepath.Join(s.tempDir, "quarantine"), nil)
+	c.Check(err, ErrorMatches, ".*pkg_1.0_amd64.deb: no SHA256 or SHA512 checksum listed.*")
+
+	downloader := s.downloader()
+	task = s.task(utils.ChecksumInfo{Size: s.actual.Size, MD5: s.actual.MD5, SHA256: s.actual.SHA256})
+	err = s.repo.DownloadPackageFile(gocontext.TODO(), downloader, task, false, filepath.Join(s.tempDir, "quarantine"), nil)
+	c.Check(err, IsNil)
+	c.Check(downloader.Empty(), Equals, true)
+	c.Check(task.Quarantined, Equals, false)
+	c.Check(task.TempDownPath, PathExists)
+}
+
+func (s *ChecksumPolicySuite) TestDownloadPackageFilePermissive(c *C) {
+	s.repo.ChecksumPolicy = ChecksumPolicyPermissive
+
+	task := s.task(utils.ChecksumInfo{Size: s.actual.Size, MD5: "00000000000000000000000000000000", SHA256: s.actual.SHA256})
+	err := s.repo.DownloadPackageFile(gocontext.TODO(), s.downloader(), task, false, filepath.Join(s.tempDir, "quarantine"), nil)
+	c.Check(err, IsNil)
+	c.Check(task.TempDownPath, PathExists)
+
+	s.repo.ChecksumPolicy = ""
+	task = s.task(utils.ChecksumInfo{Size: s.actual.Size, MD5: "00000000000000000000000000000000", SHA256: s.actual.SHA256})
+	err = s.repo.DownloadPackageFile(gocontext.TODO(), s.downloader(), task, false, filepath.Join(s.tempDir, "quarantine"), nil)
+	c.Check(err, ErrorMatches, "checksums don't match.*")
+}
+
+func (s *ChecksumPolicySuite) TestDownloadPackageFileQuarantine(c *C) {
+	s.repo.Quarantine = true
+	quarantineDir := filepath.Join(s.tempDir, "quarantine")
+
+	task := s.task(s.actual)
+	err := s.repo.DownloadPackageFile(gocontext.TODO(), s.downloader(), task, false, quarantineDir, nil)
+	c.Check(err, IsNil)
+	c.Check(task.Quarantined, Equals, false)
+	c.Check(task.File.Checksums, DeepEquals, s.actual)
+	c.Check(task.TempDownPath, PathExists)
+
+	task = s.task(utils.ChecksumInfo{Size: s.actual.Size, SHA256: "0000"})
+	err = s.repo.DownloadPackageFile(gocontext.TODO(), s.downloader(), task, false, quarantineDir, nil)
+	c.Check(err, IsNil)
+	c.Check(task.Quarantined, Equals, true)
+	c.Check(task.TempDownPath, Not(PathExists))
+	c.Check(filepath.Join(quarantineDir, "pkg_1.0_amd64.deb"), PathExists)
+}
+
+func (s *ChecksumPolicySuite) TestSkipQuarantined(c *C) {
+	p1 := NewPackageFromControlFile(packageStanza.Copy())
+	stanza := packageStanza.Copy()
+	stanza["Package"] = "other"
+	p2 := NewPackageFromControlFile(stanza)
+	stanza = packageStanza.Copy()
+	stanza["Package"] = "third"
+	p3 := NewPackageFromControlFile(stanza)
+
+	s.repo.packageList = NewPackageList()
+	c.Assert(s.repo.packageList.Add(p1), IsNil)
+	c.Assert(s.repo.packageList.Add(p2), IsNil)
+	c.Assert(s.repo.packageList.Add(p3), IsNil)
+
+	queue := []PackageDownloadTask{
+		{Package: p1, Quarantined: true, Additional: []PackageDownloadTask{{Package: p2}}},
+		{Package: p3},
+	}
+
+	skipped := s.repo.SkipQuarantined(queue)
+	c.Check(skipped, DeepEquals, []string{p1.String(), p2.String()})
+	c.Check(s.repo.packageList.Len(), Equals, 1)
+	c.Check(s.repo.packageList.Has(p3), Equals, true)
+}
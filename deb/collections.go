@@ -17,6 +17,12 @@ type CollectionFactory struct {
 	localRepos     *LocalRepoCollection
 	publishedRepos *PublishedRepoCollection
 	checksums      *ChecksumCollection
+	packageSets    *PackageSetCollection
+	auditRecorder  *AuditRecorder
+	packageCache   *PackageCache
+	queryParser    parseQuery
+
+	snapshotDeltaInterval int
 }
 
 // NewCollectionFactory creates new factory
@@ -24,6 +30,54 @@ func NewCollectionFactory(db database.Storage) *CollectionFactory {
 	return &CollectionFactory{Mutex: &sync.Mutex{}, db: db}
 }
 
+// SetAuditRecorder sets recorder which is notified about changes made via collections
+//
+// Recorder should be set before collections are used for the first time.
+func (factory *CollectionFactory) SetAuditRecorder(recorder *AuditRecorder) {
+	factory.Lock()
+	defer factory.Unlock()
+
+	factory.auditRecorder = recorder
+}
+
+// SetSnapshotDeltaInterval enables storage of snapshot package reference lists as deltas
+// against previous snapshot of the same source, with full copy stored every interval snapshots
+//
+// Should be set before collections are used for the first time, 0 disables delta storage.
+func (factory *CollectionFactory) SetSnapshotDeltaInterval(interval int) {
+	factory.Lock()
+	defer factory.Unlock()
+
+	factory.snapshotDeltaInterval = interval
+}
+
+// SetPackageCache sets cache of packages used by PackageCollection, cache could be
+// shared by several factories
+//
+// Should be set before collections are used for the first time, nil disables caching.
+func (factory *CollectionFactory) SetPackageCache(cache *PackageCache) {
+	factory.Lock()
+	defer factory.Unlock()
+
+	factory.packageCache = cache
+}
+
+// SetQueryParser sets parser of package queries, which is used to evaluate component rules
+// of published repositories
+//
+// Parser is set from outside, as package queries are parsed by package query.
+func (factory *CollectionFactory) SetQueryParser(parser func(string) (PackageQuery, error)) {
+	factory.Lock()
+	defer factory.Unlock()
+
+	factory.queryParser = parser
+}
+
+// AuditCollection returns new AuditCollection
+func (factory *CollectionFactory) AuditCollection() *AuditCollection {
+	return NewAuditCollection(factory.db)
+}
+
 // TemporaryDB creates new temporary DB
 //
 // DB should be closed/droped after being used
@@ -38,6 +92,7 @@ func (factory *CollectionFactory) PackageCollection() *PackageCollection {
 
 	if factory.packages == nil {
 		factory.packages = NewPackageCollection(factory.db)
+		factory.packages.cache = factory.packageCache
 	}
 
 	return factory.packages
@@ -50,6 +105,7 @@ func (factory *CollectionFactory) RemoteRepoCollection() *RemoteRepoCollection {
 
 	if factory.remoteRepos == nil {
 		factory.remoteRepos = NewRemoteRepoCollection(factory.db)
+		factory.remoteRepos.recorder = factory.auditRecorder
 	}
 
 	return factory.remoteRepos
@@ -62,6 +118,8 @@ func (factory *CollectionFactory) SnapshotCollection() *SnapshotCollection {
 
 	if factory.snapshots == nil {
 		factory.snapshots = NewSnapshotCollection(factory.db)
+		factory.snapshots.recorder = factory.auditRecorder
+		factory.snapshots.deltaInterval = factory.snapshotDeltaInterval
 	}
 
 	return factory.snapshots
@@ -74,6 +132,7 @@ func (factory *CollectionFactory) LocalRepoCollection() *LocalRepoCollection {
 
 	if factory.localRepos == nil {
 		factory.localRepos = NewLocalRepoCollection(factory.db)
+		factory.localRepos.recorder = factory.auditRecorder
 	}
 
 	return factory.localRepos
@@ -86,11 +145,25 @@ func (factory *CollectionFactory) PublishedRepoCollection() *PublishedRepoCollec
 
 	if factory.publishedRepos == nil {
 		factory.publishedRepos = NewPublishedRepoCollection(factory.db)
+		factory.publishedRepos.recorder = factory.auditRecorder
 	}
 
 	return factory.publishedRepos
 }
 
+// PackageSetCollection returns (or creates) new PackageSetCollection
+func (factory *CollectionFactory) PackageSetCollection() *PackageSetCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.packageSets == nil {
+		factory.packageSets = NewPackageSetCollection(factory.db)
+		factory.packageSets.recorder = factory.auditRecorder
+	}
+
+	return factory.packageSets
+}
+
 // ChecksumCollection returns (or creates) new ChecksumCollection
 func (factory *CollectionFactory) ChecksumCollection(db database.ReaderWriter) aptly.ChecksumStorage {
 	factory.Lock()
@@ -118,4 +191,5 @@ func (factory *CollectionFactory) Flush() {
 	factory.publishedRepos = nil
 	factory.packages = nil
 	factory.checksums = nil
+	factory.packageSets = nil
 }
@@ -0,0 +1,197 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConflictRename adds conflicting package under new version with binNMU-style suffix (+b1, +b2, ...)
+const ConflictRename = "rename"
+
+// ImportConflictResolutions lists conflict resolution modes supported when adding packages
+// to local repos and merging snapshots
+var ImportConflictResolutions = []string{ConflictFail, ConflictSkip, ConflictReplace, ConflictRename}
+
+// PackageConflict describes package conflicting with another package with the same name,
+// version and architecture, but different files
+type PackageConflict struct {
+	// Package is conflicting package as name_version_arch
+	Package string
+	// Existing is key of the package which was there first
+	Existing string
+	// New is key of the package being added
+	New string
+	// Resolution is how conflict was resolved, one of ImportConflictResolutions
+	Resolution string
+	// RenamedTo is version package being added was renamed to (if Resolution is rename)
+	RenamedTo string `json:",omitempty"`
+}
+
+// String returns human-readable description of the conflict
+func (c PackageConflict) String() string {
+	switch c.Resolution {
+	case ConflictSkip:
+		return fmt.Sprintf("%s: kept existing package %s, skipped %s", c.Package, c.Existing, c.New)
+	case ConflictReplace:
+		return fmt.Sprintf("%s: replaced %s with %s", c.Package, c.Existing, c.New)
+	case ConflictRename:
+		return fmt.Sprintf("%s: %s added as version %s, as it conflicts with %s", c.Package, c.New, c.RenamedTo, c.Existing)
+	}
+
+	return fmt.Sprintf("%s: %s conflicts with %s", c.Package, c.New, c.Existing)
+}
+
+// PackageConflictsError is returned when conflicts are not resolved (mode fail)
+type PackageConflictsError struct {
+	Conflicts []PackageConflict
+}
+
+func (e *PackageConflictsError) Error() string {
+	descriptions := make([]string, len(e.Conflicts))
+	for i := range e.Conflicts {
+		descriptions[i] = e.Conflicts[i].String()
+	}
+
+	return fmt.Sprintf("conflicting packages with the same name, version and architecture, but different files:\n  %s",
+		strings.Join(descriptions, "\n  "))
+}
+
+// Conflicting returns package in the list with the same name, version and architecture as p,
+// but different files, nil if there is no such package
+func (l *PackageList) Conflicting(p *Package) *Package {
+	existing, ok := l.packages[l.keyFunc(p)]
+	if !ok || existing.Equals(p) {
+		return nil
+	}
+
+	return existing
+}
+
+// renameVersion changes version of the package to the first binNMU-style version (+b1, +b2, ...)
+// which is not taken yet
+func (p *Package) renameVersion(taken func(version string) bool) {
+	// offloaded fields are stored under package key, which includes version,
+	// so they should be loaded before version is changed
+	p.dropFields = 0
+	p.Files()
+	p.Deps()
+	p.Extra()
+
+	for i := 1; ; i++ {
+		version := fmt.Sprintf("%s+b%d", p.Version, i)
+		if !taken(version) {
+			p.Version = version
+			return
+		}
+	}
+}
+
+// RenameConflicting renames p, which conflicts with package already in the list, to the version
+// not used in the list yet
+func (l *PackageList) RenameConflicting(p *Package) {
+	p.renameVersion(func(version string) bool {
+		_, ok := l.packages[fmt.Sprintf("P%s %s %s", p.Architecture, p.Name, version)]
+		return ok
+	})
+}
+
+// refShortKey strips files hash from package reference
+func refShortKey(ref []byte) []byte {
+	if bytes.Count(ref, []byte(" ")) < 3 {
+		return ref
+	}
+
+	return ref[:bytes.LastIndexByte(ref, ' ')]
+}
+
+// ResolveMergeConflicts prepares r to be merged into l (see Merge), resolving conflicts between packages
+// with the same name, version and architecture, but different files according to mode:
+//
+// - ConflictFail: *PackageConflictsError is returned if there are any conflicts
+// - ConflictReplace: r is returned as is, as Merge replaces conflicting packages with packages from r
+// - ConflictSkip: conflicting packages are removed from r, so that packages from l are kept
+// - ConflictRename: conflicting packages from r are saved to collection with new version
+func ResolveMergeConflicts(l, r *PackageRefList, mode string, collection *PackageCollection) (*PackageRefList, []PackageConflict, error) {
+	existing := make(map[string][]byte, l.Len())
+	for _, ref := range l.Refs {
+		existing[string(refShortKey(ref))] = ref
+	}
+
+	var conflicts []PackageConflict
+	conflicting := make(map[int]bool)
+
+	for i, ref := range r.Refs {
+		if other, ok := existing[string(refShortKey(ref))]; ok && !bytes.Equal(other, ref) {
+			parts := strings.Split(string(ref), " ")
+			conflicts = append(conflicts, PackageConflict{
+				Package:    fmt.Sprintf("%s_%s_%s", parts[1], parts[2], parts[0][1:]),
+				Existing:   string(other),
+				New:        string(ref),
+				Resolution: mode,
+			})
+			conflicting[i] = true
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return r, nil, nil
+	}
+
+	switch mode {
+	case ConflictFail:
+		return nil, conflicts, &PackageConflictsError{Conflicts: conflicts}
+	case ConflictReplace:
+		return r, conflicts, nil
+	case ConflictSkip, ConflictRename:
+	default:
+		return nil, nil, fmt.Errorf("unknown conflict resolution mode: %s", mode)
+	}
+
+	used := make(map[string]bool, r.Len())
+	for _, ref := range r.Refs {
+		used[string(refShortKey(ref))] = true
+	}
+
+	result := &PackageRefList{Refs: make([][]byte, 0, r.Len())}
+	taken := func(p *Package) func(string) bool {
+		return func(version string) bool {
+			key := fmt.Sprintf("P%s %s %s", p.Architecture, p.Name, version)
+			_, ok := existing[key]
+			return ok || used[key]
+		}
+	}
+
+	j := 0
+	for i, ref := range r.Refs {
+		if !conflicting[i] {
+			result.Refs = append(result.Refs, ref)
+			continue
+		}
+
+		if mode == ConflictRename {
+			p, err := collection.ByKey(ref)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to load package %s: %s", ref, err)
+			}
+
+			p.renameVersion(taken(p))
+
+			err = collection.Update(p)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to save package %s: %s", p, err)
+			}
+
+			key := p.Key("")
+			existing[string(refShortKey(key))] = key
+			result.Refs = append(result.Refs, key)
+			conflicts[j].RenamedTo = p.Version
+		}
+		j++
+	}
+
+	sort.Sort(result)
+
+	return result, conflicts, nil
+}
@@ -0,0 +1,100 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConflictsSuite struct {
+	collection        *PackageCollection
+	p1, p1rebuilt, p2 *Package
+}
+
+var _ = Suite(&ConflictsSuite{})
+
+func (s *ConflictsSuite) SetUpTest(c *C) {
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageCollection(db)
+
+	s.p1 = NewPackageFromControlFile(packageStanza.Copy())
+	stanza := packageStanza.Copy()
+	stanza["Size"] = "1234"
+	s.p1rebuilt = NewPackageFromControlFile(stanza)
+	stanza = packageStanza.Copy()
+	stanza["Package"] = "mars-invaders"
+	s.p2 = NewPackageFromControlFile(stanza)
+
+	for _, p := range []*Package{s.p1, s.p1rebuilt, s.p2} {
+		c.Assert(s.collection.Update(p), IsNil)
+	}
+}
+
+func (s *ConflictsSuite) refList(packages ...*Package) *PackageRefList {
+	list := NewPackageList()
+	for _, p := range packages {
+		_ = list.Add(p)
+	}
+
+	return NewPackageRefListFromPackageList(list)
+}
+
+func (s *ConflictsSuite) TestListConflicting(c *C) {
+	list := NewPackageList()
+	c.Assert(list.Add(s.p1), IsNil)
+
+	c.Check(list.Conflicting(s.p1), IsNil)
+	c.Check(list.Conflicting(s.p2), IsNil)
+	c.Check(list.Conflicting(s.p1rebuilt), Equals, s.p1)
+
+	list.RenameConflicting(s.p1rebuilt)
+	c.Check(s.p1rebuilt.Version, Equals, "7.40-2+b1")
+	c.Check(list.Conflicting(s.p1rebuilt), IsNil)
+	c.Assert(list.Add(s.p1rebuilt), IsNil)
+	c.Check(list.Len(), Equals, 2)
+}
+
+func (s *ConflictsSuite) TestResolveMergeConflicts(c *C) {
+	l := s.refList(s.p1, s.p2)
+	r := s.refList(s.p1rebuilt)
+
+	result, conflicts, err := ResolveMergeConflicts(l, s.refList(s.p2), ConflictFail, s.collection)
+	c.Assert(err, IsNil)
+	c.Check(conflicts, HasLen, 0)
+	c.Check(result.Len(), Equals, 1)
+
+	_, conflicts, err = ResolveMergeConflicts(l, r, ConflictFail, s.collection)
+	c.Check(err, ErrorMatches, "(?s)conflicting packages .*alien-arena-common_7.40-2_i386: .*")
+	c.Assert(conflicts, HasLen, 1)
+	c.Check(conflicts[0].Package, Equals, "alien-arena-common_7.40-2_i386")
+	c.Check(conflicts[0].Existing, Equals, string(s.p1.Key("")))
+	c.Check(conflicts[0].New, Equals, string(s.p1rebuilt.Key("")))
+
+	result, conflicts, err = ResolveMergeConflicts(l, r, ConflictReplace, s.collection)
+	c.Assert(err, IsNil)
+	c.Check(conflicts, HasLen, 1)
+	c.Check(l.Merge(result, false, false).Has(s.p1rebuilt), Equals, true)
+
+	result, conflicts, err = ResolveMergeConflicts(l, r, ConflictSkip, s.collection)
+	c.Assert(err, IsNil)
+	c.Check(conflicts, HasLen, 1)
+	c.Check(result.Len(), Equals, 0)
+	c.Check(toStrSlice(l.Merge(result, false, false)), DeepEquals, toStrSlice(l))
+
+	result, conflicts, err = ResolveMergeConflicts(l, r, ConflictRename, s.collection)
+	c.Assert(err, IsNil)
+	c.Assert(conflicts, HasLen, 1)
+	c.Check(conflicts[0].RenamedTo, Equals, "7.40-2+b1")
+	c.Assert(result.Len(), Equals, 1)
+
+	renamed, err := s.collection.ByKey(result.Refs[0])
+	c.Assert(err, IsNil)
+	c.Check(renamed.Version, Equals, "7.40-2+b1")
+	c.Check(renamed.FilesHash, Equals, s.p1rebuilt.FilesHash)
+	c.Assert(renamed.Files(), HasLen, 1)
+	c.Check(renamed.Files()[0].Checksums, DeepEquals, s.p1rebuilt.Files()[0].Checksums)
+	c.Check(l.Merge(result, false, false).Len(), Equals, 3)
+
+	_, _, err = ResolveMergeConflicts(l, r, "ignore", s.collection)
+	c.Check(err, ErrorMatches, "unknown conflict resolution mode: ignore")
+}
@@ -0,0 +1,473 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// ConsistencyIssueKind classifies inconsistency found by ConsistencyChecker
+type ConsistencyIssueKind int
+
+// Kinds of inconsistencies
+const (
+	// IssueDanglingReference is reference to package which is missing from the database
+	IssueDanglingReference ConsistencyIssueKind = iota
+	// IssueMissingPoolFile is package file missing from the package pool
+	IssueMissingPoolFile
+	// IssueUnreferencedPoolFile is file in the package pool not used by any referenced package
+	IssueUnreferencedPoolFile
+	// IssueMissingPublishedFile is package file missing from the pool of published repository
+	IssueMissingPublishedFile
+	// IssueMissingSource is snapshot or local repo published repository is based on, which is missing from the database
+	IssueMissingSource
+)
+
+func (kind ConsistencyIssueKind) String() string {
+	switch kind {
+	case IssueDanglingReference:
+		return "dangling reference"
+	case IssueMissingPoolFile:
+		return "missing pool file"
+	case IssueUnreferencedPoolFile:
+		return "unreferenced pool file"
+	case IssueMissingPublishedFile:
+		return "missing published file"
+	case IssueMissingSource:
+		return "missing published source"
+	}
+	return fmt.Sprintf("unknown issue %d", int(kind))
+}
+
+// Repairable returns true if issues of this kind could be fixed automatically
+func (kind ConsistencyIssueKind) Repairable() bool {
+	return kind == IssueDanglingReference || kind == IssueMissingPublishedFile
+}
+
+// ConsistencyIssue is single inconsistency found by ConsistencyChecker
+type ConsistencyIssue struct {
+	Kind ConsistencyIssueKind
+	// Owner is object which contains broken reference (mirror, snapshot, ...), might be empty
+	Owner string
+	// Object is package key or file path
+	Object string
+	// Repaired is set if issue has been fixed
+	Repaired bool
+	// Error is failure reason if repair has been attempted but failed
+	Error string `json:",omitempty"`
+}
+
+func (issue ConsistencyIssue) String() string {
+	result := fmt.Sprintf("%s: %s", issue.Kind, issue.Object)
+	if issue.Owner != "" {
+		result += fmt.Sprintf(" (%s)", issue.Owner)
+	}
+	if issue.Repaired {
+		result += ", repaired"
+	} else if issue.Error != "" {
+		result += fmt.Sprintf(", repair failed: %s", issue.Error)
+	}
+	return result
+}
+
+// ConsistencyReport is result of consistency check
+type ConsistencyReport struct {
+	Issues []ConsistencyIssue
+}
+
+// Count returns number of issues of specified kind
+func (report *ConsistencyReport) Count(kind ConsistencyIssueKind) int {
+	result := 0
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			result++
+		}
+	}
+	return result
+}
+
+// Unrepaired returns number of issues which are still present
+func (report *ConsistencyReport) Unrepaired() int {
+	result := 0
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			result++
+		}
+	}
+	return result
+}
+
+func (report *ConsistencyReport) add(issue ConsistencyIssue) {
+	report.Issues = append(report.Issues, issue)
+}
+
+// ConsistencyChecker cross-checks references in the database, package pool
+// and published repositories
+type ConsistencyChecker struct {
+	collectionFactory        *CollectionFactory
+	packagePool              aptly.PackagePool
+	publishedStorageProvider aptly.PublishedStorageProvider
+	progress                 aptly.Progress
+
+	// Repair enables automatic repair of repairable issues
+	Repair bool
+
+	// brokenPublished is set of UUIDs of published repositories with missing sources
+	brokenPublished map[string]bool
+}
+
+// NewConsistencyChecker creates new ConsistencyChecker
+func NewConsistencyChecker(collectionFactory *CollectionFactory, packagePool aptly.PackagePool,
+	publishedStorageProvider aptly.PublishedStorageProvider, progress aptly.Progress) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		collectionFactory:        collectionFactory,
+		packagePool:              packagePool,
+		publishedStorageProvider: publishedStorageProvider,
+		progress:                 progress,
+	}
+}
+
+func (checker *ConsistencyChecker) printf(msg string, a ...interface{}) {
+	if checker.progress != nil {
+		checker.progress.Printf(msg, a...)
+	}
+}
+
+// Check runs all the checks in one pass, repairing issues if requested
+func (checker *ConsistencyChecker) Check() (*ConsistencyReport, error) {
+	report := &ConsistencyReport{}
+
+	checker.printf("Checking sources of published repositories...\n")
+	err := checker.checkPublishedSources(report)
+	if err != nil {
+		return nil, err
+	}
+
+	checker.printf("Checking package references...\n")
+	existingRefs, err := checker.checkReferences(report)
+	if err != nil {
+		return nil, err
+	}
+
+	checker.printf("Checking package pool...\n")
+	missingPoolFiles, err := checker.checkPool(report, existingRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	checker.printf("Checking published repositories...\n")
+	err = checker.checkPublished(report, missingPoolFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// checkPublishedSources verifies that snapshots and local repos published repositories
+// are based on are present in the database; such published repositories can't be
+// loaded, so they are skipped by other checks
+func (checker *ConsistencyChecker) checkPublishedSources(report *ConsistencyReport) error {
+	checker.brokenPublished = map[string]bool{}
+
+	return checker.collectionFactory.PublishedRepoCollection().ForEach(func(published *PublishedRepo) error {
+		for _, component := range published.SourceComponents() {
+			sourceUUID := published.Sources[component]
+
+			var err error
+			source := "snapshot"
+			if published.SourceKind == SourceSnapshot {
+				_, err = checker.collectionFactory.SnapshotCollection().ByUUID(sourceUUID)
+			} else {
+				source = "local repo"
+				_, err = checker.collectionFactory.LocalRepoCollection().ByUUID(sourceUUID)
+			}
+
+			if err != nil {
+				checker.brokenPublished[published.UUID] = true
+				report.add(ConsistencyIssue{
+					Kind:   IssueMissingSource,
+					Owner:  fmt.Sprintf("published repository %s component %s", published.GetPath(), component),
+					Object: fmt.Sprintf("%s %s", source, sourceUUID),
+				})
+			}
+		}
+
+		return nil
+	})
+}
+
+// pruneDangling reports references missing in the database, returns list of
+// valid references and flag whether anything has been pruned
+func (checker *ConsistencyChecker) pruneDangling(report *ConsistencyReport, owner string,
+	refs, allRefs *PackageRefList) (*PackageRefList, bool) {
+	if refs == nil {
+		return NewPackageRefList(), false
+	}
+
+	dangling := refs.Subtract(allRefs)
+	if dangling.Len() == 0 {
+		return refs, false
+	}
+
+	for _, key := range dangling.Refs {
+		report.add(ConsistencyIssue{Kind: IssueDanglingReference, Owner: owner, Object: string(key), Repaired: checker.Repair})
+	}
+
+	return refs.Subtract(dangling), true
+}
+
+// checkReferences verifies that every reference points to existing package,
+// returns list of all the valid references
+func (checker *ConsistencyChecker) checkReferences(report *ConsistencyReport) (*PackageRefList, error) {
+	allRefs := checker.collectionFactory.PackageCollection().AllPackageRefs()
+	existingRefs := NewPackageRefList()
+
+	remoteCollection := checker.collectionFactory.RemoteRepoCollection()
+	err := remoteCollection.ForEach(func(repo *RemoteRepo) error {
+		if err := remoteCollection.LoadComplete(repo); err != nil {
+			return err
+		}
+
+		valid, pruned := checker.pruneDangling(report, fmt.Sprintf("mirror %s", repo.Name), repo.packageRefs, allRefs)
+		existingRefs = existingRefs.Merge(valid, false, true)
+
+		if pruned && checker.Repair {
+			repo.packageRefs = valid
+			return remoteCollection.Update(repo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	localCollection := checker.collectionFactory.LocalRepoCollection()
+	err = localCollection.ForEach(func(repo *LocalRepo) error {
+		if err := localCollection.LoadComplete(repo); err != nil {
+			return err
+		}
+
+		valid, pruned := checker.pruneDangling(report, fmt.Sprintf("local repo %s", repo.Name), repo.packageRefs, allRefs)
+		existingRefs = existingRefs.Merge(valid, false, true)
+
+		if pruned && checker.Repair {
+			repo.packageRefs = valid
+			return localCollection.Update(repo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotCollection := checker.collectionFactory.SnapshotCollection()
+	err = snapshotCollection.ForEach(func(snapshot *Snapshot) error {
+		if err := snapshotCollection.LoadComplete(snapshot); err != nil {
+			return err
+		}
+
+		valid, pruned := checker.pruneDangling(report, fmt.Sprintf("snapshot %s", snapshot.Name), snapshot.packageRefs, allRefs)
+		existingRefs = existingRefs.Merge(valid, false, true)
+
+		if pruned && checker.Repair {
+			snapshot.packageRefs = valid
+			return snapshotCollection.Update(snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publishedCollection := checker.collectionFactory.PublishedRepoCollection()
+	err = publishedCollection.ForEach(func(published *PublishedRepo) error {
+		if published.SourceKind != SourceLocalRepo || checker.brokenPublished[published.UUID] {
+			return nil
+		}
+
+		if err := publishedCollection.LoadComplete(published, checker.collectionFactory); err != nil {
+			return err
+		}
+
+		changed := false
+		for _, component := range published.SourceComponents() {
+			item := published.sourceItems[component]
+			owner := fmt.Sprintf("published repository %s component %s", published.String(), component)
+
+			valid, pruned := checker.pruneDangling(report, owner, item.packageRefs, allRefs)
+			existingRefs = existingRefs.Merge(valid, false, true)
+
+			if pruned && checker.Repair {
+				item.packageRefs.Refs = valid.Refs
+				changed = true
+			}
+		}
+
+		if changed {
+			return publishedCollection.Update(published)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return existingRefs, nil
+}
+
+// checkPool verifies that files of referenced packages are present in the pool,
+// returns set of missing pool paths
+func (checker *ConsistencyChecker) checkPool(report *ConsistencyReport, existingRefs *PackageRefList) (map[string]bool, error) {
+	poolFiles, err := checker.packagePool.FilepathList(checker.progress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to collect file paths: %s", err)
+	}
+	sort.Strings(poolFiles)
+
+	referencedFiles := make([]string, 0, existingRefs.Len())
+	missing := map[string]bool{}
+
+	err = existingRefs.ForEach(func(key []byte) error {
+		pkg, err := checker.collectionFactory.PackageCollection().ByKey(key)
+		if err != nil {
+			return fmt.Errorf("unable to load package %s: %s", string(key), err)
+		}
+
+		paths, err := pkg.FilepathList(checker.packagePool)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			referencedFiles = append(referencedFiles, path)
+
+			i := sort.SearchStrings(poolFiles, path)
+			if (i >= len(poolFiles) || poolFiles[i] != path) && !missing[path] {
+				missing[path] = true
+				report.add(ConsistencyIssue{Kind: IssueMissingPoolFile, Owner: fmt.Sprintf("package %s", pkg), Object: path})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(referencedFiles)
+	for _, path := range utils.StrSlicesSubstract(poolFiles, referencedFiles) {
+		report.add(ConsistencyIssue{Kind: IssueUnreferencedPoolFile, Object: path})
+	}
+
+	return missing, nil
+}
+
+// checkPublished verifies that published repositories contain pool files for all
+// the packages, relinking missing files from the package pool
+func (checker *ConsistencyChecker) checkPublished(report *ConsistencyReport, missingPoolFiles map[string]bool) error {
+	publishedCollection := checker.collectionFactory.PublishedRepoCollection()
+
+	return publishedCollection.ForEach(func(published *PublishedRepo) error {
+		if checker.brokenPublished[published.UUID] {
+			return nil
+		}
+
+		if err := publishedCollection.LoadComplete(published, checker.collectionFactory); err != nil {
+			return err
+		}
+
+		publishedStorage := checker.publishedStorageProvider.GetPublishedStorage(published.Storage)
+
+		for _, component := range published.Components() {
+			// published repository might be published with -multi-dist, this is not
+			// recorded, so it is guessed from the layout of published pool
+			relRoot := filepath.Join("pool", component)
+			publishedFiles, err := publishedStorage.Filelist(filepath.Join(published.Prefix, relRoot))
+			if err != nil {
+				return err
+			}
+
+			multiDistFiles, err := publishedStorage.Filelist(filepath.Join(published.Prefix, "pool", published.Distribution, component))
+			if err != nil {
+				return err
+			}
+
+			if len(multiDistFiles) > 0 {
+				relRoot = filepath.Join("pool", published.Distribution, component)
+				publishedFiles = multiDistFiles
+			}
+			sort.Strings(publishedFiles)
+
+			list, err := NewPackageListFromRefList(published.RefList(component), checker.collectionFactory.PackageCollection(), nil)
+			if err != nil {
+				return err
+			}
+
+			err = list.ForEach(func(pkg *Package) error {
+				if pkg.IsInstaller {
+					return nil
+				}
+
+				matches := false
+				for _, arch := range published.Architectures {
+					if pkg.MatchesArchitecture(arch) {
+						matches = true
+						break
+					}
+				}
+				if !matches {
+					return nil
+				}
+
+				poolDir, err := pkg.PoolDirectory()
+				if err != nil {
+					return err
+				}
+
+				for _, f := range pkg.Files() {
+					path := filepath.Join(poolDir, f.Filename)
+					i := sort.SearchStrings(publishedFiles, path)
+					if i < len(publishedFiles) && publishedFiles[i] == path {
+						continue
+					}
+
+					issue := ConsistencyIssue{
+						Kind:   IssueMissingPublishedFile,
+						Owner:  fmt.Sprintf("published repository %s", published.String()),
+						Object: filepath.Join(published.Prefix, relRoot, path),
+					}
+
+					if checker.Repair {
+						poolPath, err := f.GetPoolPath(checker.packagePool)
+						if err == nil && missingPoolFiles[poolPath] {
+							err = fmt.Errorf("file is missing from the package pool")
+						}
+						if err == nil {
+							err = publishedStorage.LinkFromPool(published.Prefix, filepath.Join(relRoot, poolDir), f.Filename,
+								checker.packagePool, poolPath, f.Checksums, false)
+						}
+
+						if err != nil {
+							issue.Error = err.Error()
+						} else {
+							issue.Repaired = true
+						}
+					}
+
+					report.add(issue)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
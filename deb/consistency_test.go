@@ -0,0 +1,197 @@
+package deb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConsistencyCheckerSuite struct {
+	PackageListMixinSuite
+	db               database.Storage
+	factory          *CollectionFactory
+	root             string
+	publishedStorage *files.PublishedStorage
+	provider         *FakeStorageProvider
+	packagePool      aptly.PackagePool
+	cs               aptly.ChecksumStorage
+	localRepo        *LocalRepo
+	checker          *ConsistencyChecker
+}
+
+var _ = Suite(&ConsistencyCheckerSuite{})
+
+func (s *ConsistencyCheckerSuite) SetUpTest(c *C) {
+	s.SetUpPackages()
+
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+
+	s.root = c.MkDir()
+	s.publishedStorage = files.NewPublishedStorage(s.root, "", "")
+	s.provider = &FakeStorageProvider{map[string]aptly.PublishedStorage{"": s.publishedStorage}}
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+	s.cs = files.NewMockChecksumStorage()
+
+	tmpFilepath := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(tmpFilepath, nil, 0777), IsNil)
+
+	var err error
+	s.p1.Files()[0].PoolPath, err = s.packagePool.Import(tmpFilepath, s.p1.Files()[0].Filename, &s.p1.Files()[0].Checksums, false, s.cs)
+	c.Assert(err, IsNil)
+	s.p1.UpdateFiles(s.p1.Files())
+
+	c.Assert(s.factory.PackageCollection().Update(s.p1), IsNil)
+
+	s.localRepo = NewLocalRepo("local1", "comment1")
+	s.localRepo.UpdateRefList(NewPackageRefListFromPackageList(s.list))
+	c.Assert(s.factory.LocalRepoCollection().Add(s.localRepo), IsNil)
+
+	s.checker = NewConsistencyChecker(s.factory, s.packagePool, s.provider, nil)
+}
+
+func (s *ConsistencyCheckerSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *ConsistencyCheckerSuite) issues(report *ConsistencyReport, kind ConsistencyIssueKind) (result []ConsistencyIssue) {
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			result = append(result, issue)
+		}
+	}
+	return
+}
+
+func (s *ConsistencyCheckerSuite) TestDanglingReferences(c *C) {
+	report, err := s.checker.Check()
+	c.Assert(err, IsNil)
+
+	dangling := s.issues(report, IssueDanglingReference)
+	c.Assert(dangling, HasLen, 2)
+	c.Check(dangling[0].Owner, Equals, "local repo local1")
+	c.Check(dangling[0].Repaired, Equals, false)
+	c.Check(report.Count(IssueMissingPoolFile), Equals, 0)
+	c.Check(report.Unrepaired(), Equals, 2)
+
+	s.checker.Repair = true
+	report, err = s.checker.Check()
+	c.Assert(err, IsNil)
+	c.Check(report.Count(IssueDanglingReference), Equals, 2)
+	c.Check(report.Unrepaired(), Equals, 0)
+
+	collection := NewLocalRepoCollection(s.db)
+	repo, err := collection.ByName("local1")
+	c.Assert(err, IsNil)
+	c.Assert(collection.LoadComplete(repo), IsNil)
+	c.Check(repo.RefList().Len(), Equals, 1)
+
+	report, err = NewConsistencyChecker(NewCollectionFactory(s.db), s.packagePool, s.provider, nil).Check()
+	c.Assert(err, IsNil)
+	c.Check(report.Issues, HasLen, 0)
+}
+
+func (s *ConsistencyCheckerSuite) TestPoolFiles(c *C) {
+	c.Assert(s.factory.PackageCollection().Update(s.p2), IsNil)
+	c.Assert(s.factory.PackageCollection().Update(s.p3), IsNil)
+
+	tmpFilepath := filepath.Join(c.MkDir(), "extra.deb")
+	c.Assert(ioutil.WriteFile(tmpFilepath, []byte("extra"), 0777), IsNil)
+	extraPath, err := s.packagePool.Import(tmpFilepath, "extra.deb", &s.p2.Files()[0].Checksums, false, s.cs)
+	c.Assert(err, IsNil)
+
+	report, err := s.checker.Check()
+	c.Assert(err, IsNil)
+
+	c.Check(report.Count(IssueDanglingReference), Equals, 0)
+	c.Check(report.Count(IssueUnreferencedPoolFile), Equals, 1)
+	c.Check(report.Count(IssueMissingPoolFile), Equals, 2)
+	c.Check(s.issues(report, IssueUnreferencedPoolFile)[0].Object, Equals, extraPath)
+}
+
+func (s *ConsistencyCheckerSuite) TestPublishedFiles(c *C) {
+	list := NewPackageList()
+	list.Add(s.p1)
+	s.localRepo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.factory.LocalRepoCollection().Update(s.localRepo), IsNil)
+
+	published, err := NewPublishedRepo("", "ppa", "maverick", []string{"i386"}, []string{"main"}, []interface{}{s.localRepo}, s.factory)
+	c.Assert(err, IsNil)
+	published.SkipContents = true
+	c.Assert(published.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false), IsNil)
+	c.Assert(s.factory.PublishedRepoCollection().Add(published), IsNil)
+
+	report, err := s.checker.Check()
+	c.Assert(err, IsNil)
+	c.Check(report.Issues, HasLen, 0)
+
+	publishedFile := filepath.Join(s.publishedStorage.PublicPath(), "ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb")
+	c.Check(publishedFile, PathExists)
+	c.Assert(os.Remove(publishedFile), IsNil)
+
+	report, err = s.checker.Check()
+	c.Assert(err, IsNil)
+	c.Assert(report.Issues, HasLen, 1)
+	c.Check(report.Issues[0].Kind, Equals, IssueMissingPublishedFile)
+	c.Check(report.Issues[0].Object, Equals, "ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb")
+	c.Check(report.Issues[0].Owner, Equals, "published repository "+published.String())
+
+	s.checker.Repair = true
+	report, err = s.checker.Check()
+	c.Assert(err, IsNil)
+	c.Assert(report.Issues, HasLen, 1)
+	c.Check(report.Issues[0].Repaired, Equals, true)
+	c.Check(publishedFile, PathExists)
+}
+
+func (s *ConsistencyCheckerSuite) TestMissingSource(c *C) {
+	list := NewPackageList()
+	list.Add(s.p1)
+	s.localRepo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.factory.LocalRepoCollection().Update(s.localRepo), IsNil)
+
+	snapshot, err := NewSnapshotFromLocalRepo("snap", s.localRepo)
+	c.Assert(err, IsNil)
+	c.Assert(s.factory.SnapshotCollection().Add(snapshot), IsNil)
+
+	published, err := NewPublishedRepo("", "ppa", "maverick", []string{"i386"}, []string{"main"}, []interface{}{snapshot}, s.factory)
+	c.Assert(err, IsNil)
+	published.SkipContents = true
+	c.Assert(published.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false), IsNil)
+	c.Assert(s.factory.PublishedRepoCollection().Add(published), IsNil)
+
+	report, err := s.checker.Check()
+	c.Assert(err, IsNil)
+	c.Check(report.Issues, HasLen, 0)
+
+	c.Assert(s.factory.SnapshotCollection().Drop(snapshot), IsNil)
+
+	// missing source can't be repaired, even if repair is requested
+	checker := NewConsistencyChecker(NewCollectionFactory(s.db), s.packagePool, s.provider, nil)
+	checker.Repair = true
+	report, err = checker.Check()
+	c.Assert(err, IsNil)
+	c.Assert(report.Issues, HasLen, 1)
+	c.Check(report.Issues[0].Kind, Equals, IssueMissingSource)
+	c.Check(report.Issues[0].Object, Equals, "snapshot "+snapshot.UUID)
+	c.Check(report.Issues[0].Owner, Equals, "published repository ppa/maverick component main")
+	c.Check(report.Issues[0].Repaired, Equals, false)
+	c.Check(report.Unrepaired(), Equals, 1)
+}
+
+func (s *ConsistencyCheckerSuite) TestIssueString(c *C) {
+	c.Check(ConsistencyIssue{Kind: IssueDanglingReference, Owner: "mirror a", Object: "Pi386 a 1.0 1"}.String(),
+		Equals, "dangling reference: Pi386 a 1.0 1 (mirror a)")
+	c.Check(ConsistencyIssue{Kind: IssueMissingPublishedFile, Object: "pool/a.deb", Repaired: true}.String(),
+		Equals, "missing published file: pool/a.deb, repaired")
+	c.Check(IssueUnreferencedPoolFile.Repairable(), Equals, false)
+	c.Check(IssueMissingPublishedFile.Repairable(), Equals, true)
+	c.Check(IssueMissingSource.Repairable(), Equals, false)
+}
@@ -25,6 +25,7 @@ const (
 	SourceSnapshot   = "snapshot"
 	SourceLocalRepo  = "local"
 	SourceRemoteRepo = "repo"
+	SourceQuery      = "query"
 )
 
 type parseQuery func(string) (PackageQuery, error)
@@ -48,45 +49,19 @@ func GetControlFileFromDeb(packageFile string) (Stanza, error) {
 			return nil, fmt.Errorf("unable to read .deb archive %s: %s", packageFile, err)
 		}
 
-		// As per deb(5) version 1.19.0.4 the control file may be:
+		// As per deb(5) the control file may be:
 		// - control.tar (since 1.17.6)
 		// - control.tar.gz
 		// - control.tar.xz (since 1.17.6)
+		// - control.tar.zst (since 1.21.18, used by Ubuntu since 21.10)
 		// Look for all of the above and uncompress as necessary.
 		if strings.HasPrefix(header.Name, "control.tar") {
-			bufReader := bufio.NewReader(library)
-
-			var tarInput io.Reader
-
-			switch header.Name {
-			case "control.tar":
-				tarInput = bufReader
-			case "control.tar.gz":
-				ungzip, err := gzip.NewReader(bufReader)
-				if err != nil {
-					return nil, errors.Wrapf(err, "unable to ungzip %s from %s", header.Name, packageFile)
-				}
-				defer ungzip.Close()
-				tarInput = ungzip
-			case "control.tar.xz":
-				unxz, err := xz.NewReader(bufReader)
-				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unxz %s from %s", header.Name, packageFile)
-				}
-				defer unxz.Close()
-				tarInput = unxz
-			case "control.tar.zst":
-				unzstd, err := zstd.NewReader(bufReader)
-				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
-				}
-				defer unzstd.Close()
-				tarInput = unzstd
-			default:
-				return nil, fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
+			untar, closer, err := newDebTarReader(header.Name, library, packageFile)
+			if err != nil {
+				return nil, err
 			}
+			defer closer()
 
-			untar := tar.NewReader(tarInput)
 			for {
 				tarHeader, err := untar.Next()
 				if err == io.EOF {
@@ -97,7 +72,7 @@ func GetControlFileFromDeb(packageFile string) (Stanza, error) {
 				}
 
 				if tarHeader.Name == "./control" || tarHeader.Name == "control" {
-					reader := NewControlFileReader(untar, false, false)
+					reader := NewPackageFileReader(untar, false)
 					stanza, err := reader.ReadStanza()
 					if err != nil {
 						return nil, err
@@ -137,7 +112,7 @@ func GetControlFileFromDsc(dscFile string, verifier pgp.Verifier) (Stanza, error
 		text = file
 	}
 
-	reader := NewControlFileReader(text, false, false)
+	reader := NewPackageFileReader(text, false)
 	stanza, err := reader.ReadStanza()
 	if err != nil {
 		return nil, err
@@ -160,55 +135,12 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 		}
 
 		if strings.HasPrefix(header.Name, "data.tar") {
-			bufReader := bufio.NewReader(library)
-			signature, err := bufReader.Peek(270)
-
-			var isTar bool
-			if err == nil {
-				isTar = matchers.Tar(signature)
-			}
-
-			var tarInput io.Reader
-
-			switch header.Name {
-			case "data.tar":
-				tarInput = bufReader
-			case "data.tar.gz":
-				if isTar {
-					tarInput = bufReader
-				} else {
-					ungzip, err := gzip.NewReader(bufReader)
-					if err != nil {
-						return nil, errors.Wrapf(err, "unable to ungzip data.tar.gz from %s", packageFile)
-					}
-					defer ungzip.Close()
-					tarInput = ungzip
-				}
-			case "data.tar.bz2":
-				tarInput = bzip2.NewReader(bufReader)
-			case "data.tar.xz":
-				unxz, err := xz.NewReader(bufReader)
-				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unxz data.tar.xz from %s", packageFile)
-				}
-				defer unxz.Close()
-				tarInput = unxz
-			case "data.tar.lzma":
-				unlzma := lzma.NewReader(bufReader)
-				defer unlzma.Close()
-				tarInput = unlzma
-			case "data.tar.zst":
-				unzstd, err := zstd.NewReader(bufReader)
-				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
-				}
-				defer unzstd.Close()
-				tarInput = unzstd
-			default:
-				return nil, fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
+			untar, closer, err := newDebTarReader(header.Name, library, packageFile)
+			if err != nil {
+				return nil, err
 			}
+			defer closer()
 
-			untar := tar.NewReader(tarInput)
 			var results []string
 			for {
 				tarHeader, err := untar.Next()
@@ -229,3 +161,200 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 		}
 	}
 }
+
+// newDebTarReader opens (possibly compressed) tar member of .deb archive
+//
+// Returned function should be called to release resources of decompressor.
+func newDebTarReader(name string, member io.Reader, packageFile string) (*tar.Reader, func(), error) {
+	bufReader := bufio.NewReader(member)
+	signature, err := bufReader.Peek(270)
+
+	var isTar bool
+	if err == nil {
+		isTar = matchers.Tar(signature)
+	}
+
+	var (
+		tarInput io.Reader
+		closer   = func() {}
+	)
+
+	switch name[strings.Index(name, ".tar"):] {
+	case ".tar":
+		tarInput = bufReader
+	case ".tar.gz":
+		if isTar {
+			tarInput = bufReader
+		} else {
+			ungzip, err := gzip.NewReader(bufReader)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to ungzip %s from %s", name, packageFile)
+			}
+			closer = func() { ungzip.Close() }
+			tarInput = ungzip
+		}
+	case ".tar.bz2":
+		tarInput = bzip2.NewReader(bufReader)
+	case ".tar.xz":
+		unxz, err := xz.NewReader(bufReader)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to unxz %s from %s", name, packageFile)
+		}
+		closer = func() { unxz.Close() }
+		tarInput = unxz
+	case ".tar.lzma":
+		unlzma := lzma.NewReader(bufReader)
+		closer = func() { unlzma.Close() }
+		tarInput = unlzma
+	case ".tar.zst":
+		// members are read sequentially, so concurrent decoding doesn't pay off
+		unzstd, err := zstd.NewReader(bufReader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to unzstd %s from %s", name, packageFile)
+		}
+		closer = unzstd.Close
+		tarInput = unzstd
+	default:
+		return nil, nil, fmt.Errorf("unsupported tar compression in %s: %s", packageFile, name)
+	}
+
+	return tar.NewReader(tarInput), closer, nil
+}
+
+// DebMember is a member of .deb ar archive
+type DebMember struct {
+	Name string
+	Size int64
+}
+
+// DebFile is a file (or link) installed by .deb package
+type DebFile struct {
+	Path       string
+	Size       int64
+	Mode       os.FileMode
+	LinkTarget string `json:",omitempty"`
+}
+
+// DebInspection is the result of .deb package inspection
+type DebInspection struct {
+	// Format is the contents of debian-binary member
+	Format    string
+	Members   []DebMember
+	Control   Stanza
+	Conffiles []string
+	Files     []DebFile
+}
+
+// InspectDeb parses all the members of .deb package and returns control stanza,
+// list of conffiles and full listing of files installed by the package
+func InspectDeb(file io.Reader, packageFile string) (*DebInspection, error) {
+	result := &DebInspection{}
+
+	library := ar.NewReader(file)
+	for {
+		header, err := library.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
+		}
+
+		result.Members = append(result.Members, DebMember{Name: header.Name, Size: header.Size})
+
+		switch {
+		case header.Name == "debian-binary":
+			format, err := io.ReadAll(library)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read %s from %s", header.Name, packageFile)
+			}
+			result.Format = strings.TrimSpace(string(format))
+		case strings.HasPrefix(header.Name, "control.tar"):
+			err = result.parseControlMember(header.Name, library, packageFile)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(header.Name, "data.tar"):
+			err = result.parseDataMember(header.Name, library, packageFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if result.Control == nil {
+		return nil, fmt.Errorf("unable to find control file in %s", packageFile)
+	}
+
+	return result, nil
+}
+
+func (inspection *DebInspection) parseControlMember(name string, member io.Reader, packageFile string) error {
+	untar, closer, err := newDebTarReader(name, member, packageFile)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	for {
+		tarHeader, err := untar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+		}
+
+		switch debTarPath(tarHeader.Name) {
+		case "control":
+			inspection.Control, err = NewControlFileReader(untar, false, false).ReadStanza()
+			if err != nil {
+				return err
+			}
+		case "conffiles":
+			scanner := bufio.NewScanner(untar)
+			for scanner.Scan() {
+				if line := strings.TrimSpace(scanner.Text()); line != "" {
+					inspection.Conffiles = append(inspection.Conffiles, line)
+				}
+			}
+			if err = scanner.Err(); err != nil {
+				return errors.Wrapf(err, "unable to read conffiles from %s", packageFile)
+			}
+		}
+	}
+}
+
+func (inspection *DebInspection) parseDataMember(name string, member io.Reader, packageFile string) error {
+	untar, closer, err := newDebTarReader(name, member, packageFile)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	for {
+		tarHeader, err := untar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+		}
+
+		if tarHeader.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		inspection.Files = append(inspection.Files, DebFile{
+			Path:       debTarPath(tarHeader.Name),
+			Size:       tarHeader.Size,
+			Mode:       tarHeader.FileInfo().Mode(),
+			LinkTarget: tarHeader.Linkname,
+		})
+	}
+}
+
+// debTarPath strips leading "./" or "/" from path in .deb tar member
+func debTarPath(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "./"), "/")
+}
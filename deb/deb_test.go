@@ -1,11 +1,15 @@
 package deb
 
 import (
+	"archive/tar"
+	"bytes"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/aptly-dev/aptly/pgp"
+	ar "github.com/mkrautz/goar"
 
 	. "gopkg.in/check.v1"
 )
@@ -94,3 +98,115 @@ func (s *DebSuite) TestGetContentsFromDeb(c *C) {
 		"usr/share/doc/hardlink/changelog.gz", "usr/share/doc/hardlink/copyright", "usr/share/doc/hardlink/NEWS.Debian.gz"})
 	c.Assert(f.Close(), IsNil)
 }
+
+func (s *DebSuite) TestGetContentsFromDebWithZstdData(c *C) {
+	// Has data.tar.zst archive inside.
+	f, err := os.Open(s.debFileWithZstdControl)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	contents, err := GetContentsFromDeb(f, s.debFileWithZstdControl)
+	c.Check(err, IsNil)
+	c.Check(contents, DeepEquals, []string{"usr/lib/debug/.build-id/59/514d09f713f0b6c99ab4415f155d3279cddd14.debug",
+		"usr/share/doc/libqt5concurrent5-dbgsym"})
+
+	_, err = f.Seek(0, 0)
+	c.Assert(err, IsNil)
+
+	inspection, err := InspectDeb(f, s.debFileWithZstdControl)
+	c.Assert(err, IsNil)
+	c.Check(inspection.Members[2].Name, Equals, "data.tar.zst")
+	c.Check(inspection.Control["Version"], Equals, "5.15.2+dfsg-12")
+	c.Check(inspection.Files[1].LinkTarget, Equals, "libqt5concurrent5")
+}
+
+func (s *DebSuite) TestInspectDeb(c *C) {
+	f, err := os.Open(s.debFile)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	inspection, err := InspectDeb(f, s.debFile)
+	c.Assert(err, IsNil)
+	c.Check(inspection.Format, Equals, "2.0")
+	c.Check(inspection.Members, HasLen, 3)
+	c.Check(inspection.Members[1].Name, Equals, "control.tar.gz")
+	c.Check(inspection.Control["Package"], Equals, "libboost-program-options-dev")
+	c.Check(inspection.Conffiles, IsNil)
+	c.Check(inspection.Files, HasLen, 2)
+	c.Check(inspection.Files[0].Path, Equals, "usr/share/doc/libboost-program-options-dev/changelog.gz")
+	c.Check(inspection.Files[0].Mode.IsRegular(), Equals, true)
+	c.Check(inspection.Files[0].Size > 0, Equals, true)
+}
+
+func buildTestTar(c *C, files map[string]string, links map[string]string) []byte {
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755}), IsNil)
+	for _, name := range []string{"./control", "./conffiles", "./etc/foo.conf", "./usr/bin/foo"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}), IsNil)
+		_, err := tw.Write([]byte(content))
+		c.Assert(err, IsNil)
+	}
+	for name, target := range links {
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeSymlink, Mode: 0777, Linkname: target}), IsNil)
+	}
+
+	c.Assert(tw.Close(), IsNil)
+
+	return buf.Bytes()
+}
+
+func (s *DebSuite) TestInspectDebConffiles(c *C) {
+	control := buildTestTar(c, map[string]string{
+		"./control":   "Package: foo\nVersion: 1.0\nArchitecture: amd64\n",
+		"./conffiles": "/etc/foo.conf\n\n",
+	}, nil)
+	data := buildTestTar(c, map[string]string{
+		"./etc/foo.conf": "option=1\n",
+		"./usr/bin/foo":  "#!/bin/sh\n",
+	}, map[string]string{"./usr/bin/bar": "foo"})
+
+	var buf bytes.Buffer
+	w := ar.NewWriter(&buf)
+	for _, member := range []struct {
+		name    string
+		content []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar", control},
+		{"data.tar", data},
+	} {
+		c.Assert(w.WriteHeader(&ar.Header{Name: member.name, Size: int64(len(member.content)), Mode: 0644, Mtime: time.Now().Unix()}), IsNil)
+		_, err := w.Write(member.content)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	inspection, err := InspectDeb(&buf, "foo_1.0_amd64.deb")
+	c.Assert(err, IsNil)
+	c.Check(inspection.Control["Package"], Equals, "foo")
+	c.Check(inspection.Conffiles, DeepEquals, []string{"/etc/foo.conf"})
+	c.Assert(inspection.Files, HasLen, 3)
+	c.Check(inspection.Files[0], DeepEquals, DebFile{Path: "etc/foo.conf", Size: 9, Mode: 0644})
+	c.Check(inspection.Files[2].Path, Equals, "usr/bin/bar")
+	c.Check(inspection.Files[2].LinkTarget, Equals, "foo")
+	c.Check(inspection.Files[2].Mode&os.ModeSymlink, Equals, os.ModeSymlink)
+}
+
+func (s *DebSuite) TestInspectDebNoControl(c *C) {
+	var buf bytes.Buffer
+	w := ar.NewWriter(&buf)
+	c.Assert(w.WriteHeader(&ar.Header{Name: "debian-binary", Size: 4, Mode: 0644, Mtime: time.Now().Unix()}), IsNil)
+	_, err := w.Write([]byte("2.0\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	_, err = InspectDeb(&buf, "foo.deb")
+	c.Check(err, ErrorMatches, "unable to find control file in foo.deb")
+}
@@ -0,0 +1,108 @@
+package deb
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DebianSnapshotRoot is URL of snapshot.debian.org, which keeps every state of Debian archives
+const DebianSnapshotRoot = "https://snapshot.debian.org/archive/"
+
+// DebianSnapshotConcurrency limits number of parallel downloads from snapshot.debian.org,
+// as the service throttles aggressive clients
+const DebianSnapshotConcurrency = 2
+
+// debianSnapshotTimeFormat is format of timestamps in snapshot.debian.org URLs
+const debianSnapshotTimeFormat = "20060102T150405Z"
+
+var debianSnapshotArchiveRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+// ParseDebianSnapshotTime parses moment in time to mirror archive state as of: date (2023-06-01),
+// RFC 3339 time or snapshot.debian.org timestamp (20230601T000000Z)
+func ParseDebianSnapshotTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, debianSnapshotTimeFormat} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse snapshot time %q, expected date (2023-06-01), RFC 3339 time or timestamp (20230601T000000Z)", value)
+}
+
+// DebianSnapshotArchive figures out name of the archive on snapshot.debian.org (e.g. debian,
+// debian-security) from archive URL: either snapshot:<archive> or URL of the regular mirror
+// of the archive (last component of the path is used)
+func DebianSnapshotArchive(archiveURL string) (string, error) {
+	var archive string
+
+	if strings.HasPrefix(archiveURL, "snapshot:") {
+		archive = strings.Trim(strings.TrimPrefix(archiveURL, "snapshot:"), "/")
+	} else {
+		u, err := url.Parse(archiveURL)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse archive URL %s: %s", archiveURL, err)
+		}
+		archive = path.Base(strings.TrimSuffix(u.Path, "/"))
+	}
+
+	if !debianSnapshotArchiveRegexp.MatchString(archive) {
+		return "", fmt.Errorf("unable to figure out snapshot.debian.org archive name from %s, use snapshot:<archive>", archiveURL)
+	}
+
+	return archive, nil
+}
+
+// IsDebianSnapshot returns true if mirror follows archive on snapshot.debian.org
+func (repo *RemoteRepo) IsDebianSnapshot() bool {
+	return repo.DebianSnapshotArchive != ""
+}
+
+// SetDebianSnapshot points mirror to the state of archive on snapshot.debian.org as of at
+//
+// snapshot.debian.org redirects requests to the most recent state of the archive before
+// the requested time, so any moment in time could be used
+func (repo *RemoteRepo) SetDebianSnapshot(archive string, at time.Time) {
+	repo.DebianSnapshotArchive = archive
+	repo.DebianSnapshotTime = at.UTC()
+	repo.SetArchiveRoot(DebianSnapshotRoot + archive + "/" + repo.DebianSnapshotTime.Format(debianSnapshotTimeFormat) + "/")
+}
+
+// ApplyDebianSnapshotTime points mirror to the state of archive on snapshot.debian.org as of
+// snapshotTime, archive is kept if mirror already follows snapshot.debian.org, otherwise
+// it is figured out from archive root URL
+func (repo *RemoteRepo) ApplyDebianSnapshotTime(snapshotTime string) error {
+	at, err := ParseDebianSnapshotTime(snapshotTime)
+	if err != nil {
+		return err
+	}
+
+	archive := repo.DebianSnapshotArchive
+	if archive == "" {
+		archive, err = DebianSnapshotArchive(repo.ArchiveRoot)
+		if err != nil {
+			return err
+		}
+	}
+
+	repo.SetDebianSnapshot(archive, at)
+	return nil
+}
+
+// ClearDebianSnapshot makes mirror follow archive root URL as is
+func (repo *RemoteRepo) ClearDebianSnapshot() {
+	repo.DebianSnapshotArchive = ""
+	repo.DebianSnapshotTime = time.Time{}
+}
+
+// DownloadConcurrency returns number of parallel downloads to use while updating the mirror
+func (repo *RemoteRepo) DownloadConcurrency(configured int) int {
+	if repo.IsDebianSnapshot() && configured > DebianSnapshotConcurrency {
+		return DebianSnapshotConcurrency
+	}
+
+	return configured
+}
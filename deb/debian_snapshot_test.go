@@ -0,0 +1,73 @@
+package deb
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type DebianSnapshotSuite struct{}
+
+var _ = Suite(&DebianSnapshotSuite{})
+
+func (s *DebianSnapshotSuite) TestParseDebianSnapshotTime(c *C) {
+	expected := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"2023-06-01", "2023-06-01T00:00:00Z", "2023-06-01T02:00:00+02:00", "20230601T000000Z"} {
+		t, err := ParseDebianSnapshotTime(value)
+		c.Assert(err, IsNil)
+		c.Check(t.Equal(expected), Equals, true, Commentf("value: %s", value))
+	}
+
+	_, err := ParseDebianSnapshotTime("yesterday")
+	c.Check(err, ErrorMatches, "unable to parse snapshot time \"yesterday\".*")
+}
+
+func (s *DebianSnapshotSuite) TestDebianSnapshotArchive(c *C) {
+	for url, expected := range map[string]string{
+		"snapshot:debian":                            "debian",
+		"snapshot:debian-ports/":                     "debian-ports",
+		"http://deb.debian.org/debian/":              "debian",
+		"http://security.debian.org/debian-security": "debian-security",
+	} {
+		archive, err := DebianSnapshotArchive(url)
+		c.Assert(err, IsNil)
+		c.Check(archive, Equals, expected)
+	}
+
+	_, err := DebianSnapshotArchive("http://example.com/")
+	c.Check(err, ErrorMatches, "unable to figure out snapshot.debian.org archive name from http://example.com/.*")
+
+	_, err = DebianSnapshotArchive("snapshot:")
+	c.Check(err, ErrorMatches, "unable to figure out snapshot.debian.org archive name.*")
+}
+
+func (s *DebianSnapshotSuite) TestApplyDebianSnapshotTime(c *C) {
+	repo, err := NewRemoteRepo("bookworm", "http://deb.debian.org/debian/", "bookworm", []string{"main"}, []string{}, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.IsDebianSnapshot(), Equals, false)
+
+	c.Assert(repo.ApplyDebianSnapshotTime("2023-06-01"), IsNil)
+	c.Check(repo.IsDebianSnapshot(), Equals, true)
+	c.Check(repo.DebianSnapshotArchive, Equals, "debian")
+	c.Check(repo.ArchiveRoot, Equals, "https://snapshot.debian.org/archive/debian/20230601T000000Z/")
+
+	// archive is kept, only time is changed
+	c.Assert(repo.ApplyDebianSnapshotTime("2023-07-15T10:30:00Z"), IsNil)
+	c.Check(repo.ArchiveRoot, Equals, "https://snapshot.debian.org/archive/debian/20230715T103000Z/")
+
+	c.Check(repo.ApplyDebianSnapshotTime("never"), ErrorMatches, "unable to parse snapshot time.*")
+
+	repo.ClearDebianSnapshot()
+	c.Check(repo.IsDebianSnapshot(), Equals, false)
+	c.Check(repo.DebianSnapshotTime.IsZero(), Equals, true)
+}
+
+func (s *DebianSnapshotSuite) TestDownloadConcurrency(c *C) {
+	repo := &RemoteRepo{}
+	c.Check(repo.DownloadConcurrency(4), Equals, 4)
+
+	repo.SetDebianSnapshot("debian", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC))
+	c.Check(repo.DownloadConcurrency(4), Equals, DebianSnapshotConcurrency)
+	c.Check(repo.DownloadConcurrency(1), Equals, 1)
+}
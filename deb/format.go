@@ -26,8 +26,10 @@ var (
 		"Version",
 		"Codename",
 		"Date",
+		"Valid-Until",
 		"NotAutomatic",
 		"ButAutomaticUpgrades",
+		"No-Support-for-Architecture-all",
 		"Architectures",
 		"Architecture",
 		"Components",
@@ -92,8 +94,116 @@ var (
 	canonicalOrderInstaller = []string{
 		"",
 	}
+
+	// Order of fields in archive indexes generated by apt-ftparchive and dak, which apt and dpkg
+	// use when rewriting stanzas
+	// Taken from: https://salsa.debian.org/apt-team/apt/-/blob/main/apt-pkg/tagfile-order.c
+	debianOrderBinary = []string{
+		"Package",
+		"Package-Type",
+		"Architecture",
+		"Subarchitecture",
+		"Version",
+		"Kernel-Version",
+		"Built-Using",
+		"Static-Built-Using",
+		"Built-For-Profiles",
+		"Auto-Built-Package",
+		"Multi-Arch",
+		"Status",
+		"Priority",
+		"Essential",
+		"Protected",
+		"Build-Essential",
+		"Important",
+		"Installer-Menu-Item",
+		"Section",
+		"Source",
+		"Origin",
+		"Maintainer",
+		"Original-Maintainer",
+		"Bugs",
+		"Conffiles",
+		"Installed-Size",
+		"Provides",
+		"Pre-Depends",
+		"Depends",
+		"Recommends",
+		"Suggests",
+		"Conflicts",
+		"Breaks",
+		"Replaces",
+		"Enhances",
+		"Filename",
+		"Size",
+		"MD5sum",
+		"MD5Sum",
+		"SHA1",
+		"SHA256",
+		"SHA512",
+		"Homepage",
+		"Description",
+		"Tag",
+		"Task",
+	}
+
+	debianOrderSource = []string{
+		"Package",
+		"Source",
+		"Format",
+		"Binary",
+		"Architecture",
+		"Version",
+		"Priority",
+		"Section",
+		"Origin",
+		"Maintainer",
+		"Original-Maintainer",
+		"Uploaders",
+		"Standards-Version",
+		"Build-Depends",
+		"Build-Depends-Arch",
+		"Build-Depends-Indep",
+		"Build-Conflicts",
+		"Build-Conflicts-Arch",
+		"Build-Conflicts-Indep",
+		"Testsuite",
+		"Testsuite-Triggers",
+		"Homepage",
+		"Description",
+		"Vcs-Browser",
+		"Vcs-Arch",
+		"Vcs-Bzr",
+		"Vcs-Cvs",
+		"Vcs-Darcs",
+		"Vcs-Git",
+		"Vcs-Hg",
+		"Vcs-Mtn",
+		"Vcs-Svn",
+		"Directory",
+		"Package-List",
+		"Files",
+		"Checksums-Md5",
+		"Checksums-Sha1",
+		"Checksums-Sha256",
+		"Checksums-Sha512",
+	}
 )
 
+// knownFields are fields of packages interpreted by aptly: when reading package stanzas, names
+// of such fields are converted to canonical case and continuation lines of their values are joined
+// into single line, while names and values of other fields (e.g. vendor-specific ones) are
+// preserved as is
+var knownFields = func() map[string]bool {
+	result := map[string]bool{}
+	for _, order := range [][]string{canonicalOrderRelease, canonicalOrderBinary, canonicalOrderSource} {
+		for _, field := range order {
+			result[field] = true
+		}
+	}
+	return result
+}()
+
 // Copy returns copy of Stanza
 func (s Stanza) Copy() (result Stanza) {
 	result = make(Stanza, len(s))
@@ -134,12 +244,48 @@ func isMultilineField(field string, isRelease bool) bool {
 	return false
 }
 
+// needsFolding checks whether some continuation lines of the field don't start with whitespace
+func needsFolding(value string) bool {
+	for i := 0; i < len(value)-1; i++ {
+		if value[i] == '\n' && value[i+1] != ' ' && value[i+1] != '\t' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// foldField makes sure every continuation line of the field starts with whitespace, so that
+// values with line breaks (e.g. set via API) don't break stanza; empty lines are replaced
+// with " ." as in package descriptions
+func foldField(text string) string {
+	if !needsFolding(text) {
+		return text
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "" {
+			lines[i] = " ."
+		} else if lines[i][0] != ' ' && lines[i][0] != '\t' {
+			lines[i] = " " + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
 // Write single field from Stanza to writer.
 //
 // nolint: interfacer
 func writeField(w *bufio.Writer, field, value string, isRelease bool) (err error) {
 	if !isMultilineField(field, isRelease) {
-		_, err = w.WriteString(field + ": " + value + "\n")
+		if strings.HasPrefix(value, "\n") {
+			// preserved value of unknown field starting on continuation line
+			_, err = w.WriteString(foldField(field + ":" + value + "\n"))
+		} else {
+			_, err = w.WriteString(foldField(field + ": " + value + "\n"))
+		}
 	} else {
 		if field != "" && !strings.HasSuffix(value, "\n") {
 			value = value + "\n"
@@ -149,8 +295,12 @@ func writeField(w *bufio.Writer, field, value string, isRelease bool) (err error
 			value = "\n" + value
 		}
 
+		if field == "Description" && value[0] != ' ' && value[0] != '\t' && value[0] != '\n' {
+			value = " " + value
+		}
+
 		if field != "" {
-			_, err = w.WriteString(field + ":" + value)
+			_, err = w.WriteString(foldField(field + ":" + value))
 		} else {
 			_, err = w.WriteString(value)
 		}
@@ -172,6 +322,28 @@ func (s Stanza) WriteTo(w *bufio.Writer, isSource, isRelease, isInstaller bool)
 		canonicalOrder = canonicalOrderInstaller
 	}
 
+	return s.writeInOrder(w, canonicalOrder, isRelease, isInstaller)
+}
+
+// WriteInDebianOrderTo saves package stanza back to stream with fields in the order used
+// by Debian archive tools (apt-ftparchive, dak), modifying itself on the fly
+//
+// Fields unknown to archive tools follow in alphabetical order.
+func (s Stanza) WriteInDebianOrderTo(w *bufio.Writer, isSource, isInstaller bool) error {
+	if isInstaller {
+		return s.writeInOrder(w, canonicalOrderInstaller, false, true)
+	}
+
+	order := debianOrderBinary
+	if isSource {
+		order = debianOrderSource
+	}
+
+	return s.writeInOrder(w, order, false, false)
+}
+
+// writeInOrder saves fields listed in order first, followed by other fields in alphabetical order
+func (s Stanza) writeInOrder(w *bufio.Writer, canonicalOrder []string, isRelease, isInstaller bool) error {
 	for _, field := range canonicalOrder {
 		value, ok := s[field]
 		if ok {
@@ -240,9 +412,10 @@ func canonicalCase(field string) string {
 
 // ControlFileReader implements reading of control files stanza by stanza
 type ControlFileReader struct {
-	scanner     *bufio.Scanner
-	isRelease   bool
-	isInstaller bool
+	scanner         *bufio.Scanner
+	isRelease       bool
+	isInstaller     bool
+	preserveUnknown bool
 }
 
 // NewControlFileReader creates ControlFileReader, it wraps with buffering
@@ -257,6 +430,15 @@ func NewControlFileReader(r io.Reader, isRelease, isInstaller bool) *ControlFile
 	}
 }
 
+// NewPackageFileReader creates ControlFileReader for package indexes and control files of
+// packages, names and values of fields unknown to aptly are preserved as is
+func NewPackageFileReader(r io.Reader, isInstaller bool) *ControlFileReader {
+	result := NewControlFileReader(r, false, isInstaller)
+	result.preserveUnknown = true
+
+	return result
+}
+
 // ReadStanza reeads one stanza from control file
 func (c *ControlFileReader) ReadStanza() (Stanza, error) {
 	stanza := make(Stanza, 32)
@@ -277,6 +459,8 @@ func (c *ControlFileReader) ReadStanza() (Stanza, error) {
 		if line[0] == ' ' || line[0] == '\t' || c.isInstaller {
 			if lastFieldMultiline {
 				stanza[lastField] += line + "\n"
+			} else if c.preserveUnknown && !knownFields[lastField] {
+				stanza[lastField] += "\n" + line
 			} else {
 				stanza[lastField] += " " + strings.TrimSpace(line)
 			}
@@ -287,6 +471,9 @@ func (c *ControlFileReader) ReadStanza() (Stanza, error) {
 			}
 			lastField = canonicalCase(parts[0])
 			lastFieldMultiline = isMultilineField(lastField, c.isRelease)
+			if c.preserveUnknown && !knownFields[lastField] && !lastFieldMultiline {
+				lastField = parts[0]
+			}
 			if lastFieldMultiline {
 				stanza[lastField] = parts[1]
 				if parts[1] != "" {
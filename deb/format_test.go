@@ -148,6 +148,104 @@ func (s *ControlFileSuite) TestReadWriteInstallerStanza(c *C) {
 	c.Assert(stanza2, DeepEquals, stanza)
 }
 
+func (s *ControlFileSuite) TestWriteFoldsFields(c *C) {
+	stanza := Stanza{
+		"Package":     "hello",
+		"Description": "short\nlong description\n\nsecond paragraph\n\n",
+		"X-Note":      "first\nsecond\n",
+	}
+
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	c.Assert(stanza.Copy().WriteTo(w, false, false, false), IsNil)
+	c.Assert(w.Flush(), IsNil)
+
+	c.Check(buf.String(), Equals, "Package: hello\n"+
+		"Description: short\n long description\n .\n second paragraph\n"+
+		"X-Note: first\n second\n")
+
+	stanza2, err := NewControlFileReader(buf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(stanza2["Description"], Equals, " short\n long description\n .\n second paragraph\n")
+	c.Check(stanza2["X-Note"], Equals, "first second")
+}
+
+func (s *ControlFileSuite) TestPackageFileReaderPreservesFields(c *C) {
+	const stanzaText = "Package: rust-hello\n" +
+		"Version: 1.0-1\n" +
+		"Architecture: amd64\n" +
+		"Depends: libc6,\n" +
+		"  libgcc-s1\n" +
+		"XB-Vendor-Flavor: strawberry  \n" +
+		"X-Cargo-Built-Using:\n" +
+		" rust-foo (= 1.0),\n" +
+		"  rust-bar (= 2.0)\n" +
+		"Tag: devel::lang:rust,\n" +
+		" role::program\n" +
+		"Description-md5: 2be7e62f455351435b1e055745d3e81c\n"
+
+	stanza, err := NewPackageFileReader(bytes.NewBufferString(stanzaText), false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(stanza["Depends"], Equals, "libc6, libgcc-s1")
+	c.Check(stanza["XB-Vendor-Flavor"], Equals, "strawberry")
+	c.Check(stanza["X-Cargo-Built-Using"], Equals, "\n rust-foo (= 1.0),\n  rust-bar (= 2.0)")
+	c.Check(stanza["Tag"], Equals, "devel::lang:rust,\n role::program")
+	c.Check(stanza["Description-md5"], Equals, "2be7e62f455351435b1e055745d3e81c")
+
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	c.Assert(stanza.Copy().WriteTo(w, false, false, false), IsNil)
+	c.Assert(w.Flush(), IsNil)
+
+	c.Check(buf.String(), Equals, "Package: rust-hello\n"+
+		"Architecture: amd64\n"+
+		"Version: 1.0-1\n"+
+		"Depends: libc6, libgcc-s1\n"+
+		"Description-md5: 2be7e62f455351435b1e055745d3e81c\n"+
+		"Tag: devel::lang:rust,\n role::program\n"+
+		"X-Cargo-Built-Using:\n rust-foo (= 1.0),\n  rust-bar (= 2.0)\n"+
+		"XB-Vendor-Flavor: strawberry\n")
+
+	// control file reader still normalizes all the fields
+	stanza, err = NewControlFileReader(bytes.NewBufferString(stanzaText), false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(stanza["Xb-Vendor-Flavor"], Equals, "strawberry")
+	c.Check(stanza["Tag"], Equals, "devel::lang:rust, role::program")
+}
+
+func (s *ControlFileSuite) TestWriteInDebianOrder(c *C) {
+	stanza := Stanza{
+		"Package":      "hello",
+		"Version":      "2.10-3",
+		"Architecture": "amd64",
+		"Multi-Arch":   "foreign",
+		"Section":      "devel",
+		"Priority":     "optional",
+		"Filename":     "pool/main/h/hello/hello_2.10-3_amd64.deb",
+		"Homepage":     "https://www.gnu.org/software/hello/",
+		"Description":  " hello world\n",
+		"Tag":          "role::program",
+		"X-Custom":     "value",
+	}
+
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	c.Assert(stanza.Copy().WriteInDebianOrderTo(w, false, false), IsNil)
+	c.Assert(w.Flush(), IsNil)
+
+	c.Check(buf.String(), Equals, "Package: hello\n"+
+		"Architecture: amd64\n"+
+		"Version: 2.10-3\n"+
+		"Multi-Arch: foreign\n"+
+		"Priority: optional\n"+
+		"Section: devel\n"+
+		"Filename: pool/main/h/hello/hello_2.10-3_amd64.deb\n"+
+		"Homepage: https://www.gnu.org/software/hello/\n"+
+		"Description: hello world\n"+
+		"Tag: role::program\n"+
+		"X-Custom: value\n")
+}
+
 func (s *ControlFileSuite) TestCanonicalCase(c *C) {
 	c.Check(canonicalCase("Package"), Equals, "Package")
 	c.Check(canonicalCase("package"), Equals, "Package")
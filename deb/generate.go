@@ -0,0 +1,162 @@
+package deb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// ScanPackageFiles builds list of binary packages from .deb and .udeb files as
+// dpkg-scanpackages does: no package pool or database is involved, Filename field
+// of the package refers to the package file path as is
+//
+// If architectures are not empty, packages for other architectures are skipped
+// (except for packages for architecture "all")
+func ScanPackageFiles(packageFiles []string, architectures []string, reporter aptly.ResultReporter) (list *PackageList, failedFiles []string) {
+	list = NewPackageList()
+
+	var (
+		packages []*Package
+		files    []string
+	)
+
+	for _, file := range packageFiles {
+		if !strings.HasSuffix(file, ".deb") && !strings.HasSuffix(file, ".udeb") {
+			continue
+		}
+
+		stanza, err := GetControlFileFromDeb(file)
+		if err != nil {
+			reporter.Warning("Unable to read file %s: %s", file, err)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		var p *Package
+		if strings.HasSuffix(file, ".udeb") {
+			p = NewUdebPackageFromControlFile(stanza)
+		} else {
+			p = NewPackageFromControlFile(stanza)
+		}
+
+		if p.Name == "" || p.Version == "" || p.Architecture == "" {
+			reporter.Warning("Missing package name, version or architecture in %s", file)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		if len(architectures) > 0 && p.Architecture != ArchitectureAll && !utils.StrSliceHasItem(architectures, p.Architecture) {
+			continue
+		}
+
+		packages = append(packages, p)
+		files = append(files, file)
+	}
+
+	// package files are checksummed in parallel once all the packages are collected
+	checksums, errs := utils.ChecksumsForFiles(files, 0)
+
+	for i, p := range packages {
+		file := files[i]
+
+		if errs[i] != nil {
+			reporter.Warning("Unable to read file %s: %s", file, errs[i])
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		p.UpdateFiles(PackageFiles{PackageFile{
+			Filename:     filepath.Base(file),
+			Checksums:    checksums[i],
+			downloadPath: filepath.Dir(file),
+		}})
+
+		err := list.Add(p)
+		if err != nil {
+			reporter.Warning("Skipping %s: %s", file, err)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+	}
+
+	return
+}
+
+// isReleaseIndex checks whether file should be listed in Release file
+func isReleaseIndex(name string) bool {
+	for _, prefix := range []string{"Packages", "Sources", "Contents-", "Translation-", "Components-", "icons-"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return name == "Release" || name == "Index"
+}
+
+// BuildDirectoryRelease fills in Date and checksum fields of Release file stanza for
+// repository in directory root, as apt-ftparchive release does: all package indexes
+// found in root (recursively) are listed with paths relative to root
+func BuildDirectoryRelease(root string, release Stanza) error {
+	paths := []string{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !isReleaseIndex(info.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "Release" {
+			// top-level Release file is being generated
+			return nil
+		}
+
+		paths = append(paths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to scan %s: %s", root, err)
+	}
+
+	sort.Strings(paths)
+
+	release["Date"] = time.Now().UTC().Format(releaseDateFormat)
+	release["MD5Sum"] = ""
+	release["SHA1"] = ""
+	release["SHA256"] = ""
+	release["SHA512"] = ""
+
+	fullPaths := make([]string, len(paths))
+	for i, path := range paths {
+		fullPaths[i] = filepath.Join(root, filepath.FromSlash(path))
+	}
+
+	infos, errs := utils.ChecksumsForFiles(fullPaths, 0)
+
+	for i, path := range paths {
+		info := infos[i]
+		if errs[i] != nil {
+			return fmt.Errorf("unable to generate checksums for %s: %s", path, errs[i])
+		}
+
+		release["MD5Sum"] += fmt.Sprintf(" %s %8d %s\n", info.MD5, info.Size, path)
+		release["SHA1"] += fmt.Sprintf(" %s %8d %s\n", info.SHA1, info.Size, path)
+		release["SHA256"] += fmt.Sprintf(" %s %8d %s\n", info.SHA256, info.Size, path)
+		release["SHA512"] += fmt.Sprintf(" %s %8d %s\n", info.SHA512, info.Size, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+
+	. "gopkg.in/check.v1"
+)
+
+type GenerateSuite struct{}
+
+var _ = Suite(&GenerateSuite{})
+
+func (s *GenerateSuite) TestScanPackageFiles(c *C) {
+	reporter := &aptly.RecordingResultReporter{}
+
+	list, failedFiles := ScanPackageFiles([]string{
+		"testdata/changes/hardlink_0.2.0_i386.deb",
+		"testdata/changes/hardlink_0.2.1_amd64.deb",
+		"testdata/changes/hardlink_0.2.1.dsc",
+		"testdata/changes/missing.deb",
+	}, nil, reporter)
+
+	c.Check(failedFiles, DeepEquals, []string{"testdata/changes/missing.deb"})
+	c.Check(reporter.Warnings, HasLen, 1)
+	c.Assert(list.Len(), Equals, 2)
+
+	list.PrepareIndex()
+	stanzas := []Stanza{}
+	list.ForEachIndexed(func(p *Package) error {
+		stanzas = append(stanzas, p.Stanza())
+		return nil
+	})
+
+	c.Check(stanzas[0]["Filename"], Equals, "testdata/changes/hardlink_0.2.1_amd64.deb")
+	c.Check(stanzas[0]["SHA256"], Not(Equals), "")
+	c.Check(stanzas[1]["Filename"], Equals, "testdata/changes/hardlink_0.2.0_i386.deb")
+
+	list, failedFiles = ScanPackageFiles([]string{
+		"testdata/changes/hardlink_0.2.0_i386.deb",
+		"testdata/changes/hardlink_0.2.1_amd64.deb",
+	}, []string{"i386"}, reporter)
+	c.Check(failedFiles, HasLen, 0)
+	c.Check(list.Len(), Equals, 1)
+}
+
+func (s *GenerateSuite) TestBuildDirectoryRelease(c *C) {
+	root := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(root, "main", "binary-i386"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "main", "binary-i386", "Packages"), []byte("Package: a\n"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "main", "binary-i386", "Release"), []byte("Component: main\n"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "Release"), []byte("stale"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "hardlink_0.2.1_amd64.deb"), nil, 0644), IsNil)
+
+	release := Stanza{"Origin": "test"}
+	c.Assert(BuildDirectoryRelease(root, release), IsNil)
+
+	c.Check(release["Origin"], Equals, "test")
+	c.Check(release["Date"], Not(Equals), "")
+	c.Check(release["MD5Sum"], Equals,
+		" 51e6edca135dcb3909a88db45e8485a4       11 main/binary-i386/Packages\n"+
+			" 619007811a5cbd7f07bff820af1bbfee       16 main/binary-i386/Release\n")
+	c.Check(release["SHA512"], Matches, "(?s) [0-9a-f]{128}       11 main/binary-i386/Packages\n.*")
+
+	c.Check(BuildDirectoryRelease(filepath.Join(root, "missing"), Stanza{}), ErrorMatches, "unable to scan .*")
+}
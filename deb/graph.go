@@ -99,12 +99,10 @@ func BuildGraph(collectionFactory *CollectionFactory, layout string) (gographviz
 				snapshot.Name, description, snapshot.NumPackages(), labelEnd),
 		})
 
-		if snapshot.SourceKind == SourceRemoteRepo || snapshot.SourceKind == SourceLocalRepo || snapshot.SourceKind == SourceSnapshot {
-			for _, uuid := range snapshot.SourceIDs {
-				_, exists := existingNodes[uuid]
-				if exists {
-					graph.AddEdge(uuid, snapshot.UUID, true, nil)
-				}
+		for _, source := range snapshot.Sources() {
+			_, exists := existingNodes[source.ID]
+			if exists {
+				graph.AddEdge(source.ID, snapshot.UUID, true, nil)
 			}
 		}
 		return nil
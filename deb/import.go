@@ -69,17 +69,53 @@ func CollectPackageFiles(locations []string, reporter aptly.ResultReporter) (pac
 	return
 }
 
+// PoolComponents returns sorted list of components in Debian-style pool/<component>/
+// layout (as used by reprepro, dak and similar tools) under root directory
+//
+// Empty list is returned if root directory isn't structured this way
+func PoolComponents(root string) []string {
+	entries, err := os.ReadDir(filepath.Join(root, "pool"))
+	if err != nil {
+		return nil
+	}
+
+	components := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			components = append(components, entry.Name())
+		}
+	}
+
+	sort.Strings(components)
+	return components
+}
+
+func extractChangelogFromFile(packageFile, name string) (*PackageChangelog, error) {
+	file, err := os.Open(packageFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return GetChangelogFromDeb(file, packageFile, name)
+}
+
 // ImportPackageFiles imports files into local repository
-func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace bool, verifier pgp.Verifier,
+//
+// Packages conflicting with packages already in the list (same name, version and architecture, but
+// different files) are handled according to onConflict (one of ImportConflictResolutions), conflicts
+// are returned along with the way they were resolved.
+func ImportPackageFiles(list *PackageList, packageFiles []string, onConflict string, verifier pgp.Verifier,
 	pool aptly.PackagePool, collection *PackageCollection, reporter aptly.ResultReporter, restriction PackageQuery,
-	checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
-	if forceReplace {
-		list.PrepareIndex()
-	}
+	checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, conflicts []PackageConflict, err error) {
 
 	checksumStorage := checksumStorageProvider(collection.db)
 
-	for _, file := range packageFiles {
+	// checksums of package files are computed in parallel upfront, as hashing
+	// big package files one by one dominates import time
+	fileChecksums, checksumErrs := utils.ChecksumsForFiles(packageFiles, 0)
+
+	for i, file := range packageFiles {
 		var (
 			stanza Stanza
 			p      *Package
@@ -136,10 +172,10 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			files = p.Files()
 		}
 
-		var checksums utils.ChecksumInfo
-		checksums, err = utils.ChecksumsForFile(file)
+		checksums := fileChecksums[i]
+		err = checksumErrs[i]
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 
 		mainPackageFile := PackageFile{
@@ -201,6 +237,22 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			continue
 		}
 
+		var conflict *PackageConflict
+		if existing := list.Conflicting(p); existing != nil {
+			conflict = &PackageConflict{
+				Package:    p.String(),
+				Existing:   string(existing.Key("")),
+				New:        string(p.Key("")),
+				Resolution: onConflict,
+			}
+
+			if onConflict == ConflictRename {
+				list.RenameConflicting(p)
+				conflict.New = string(p.Key(""))
+				conflict.RenamedTo = p.Version
+			}
+		}
+
 		err = collection.Update(p)
 		if err != nil {
 			reporter.Warning("Unable to save package %s: %s", p, err)
@@ -208,22 +260,51 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			continue
 		}
 
-		if forceReplace {
-			conflictingPackages := list.Search(Dependency{Pkg: p.Name, Version: p.Version, Relation: VersionEqual, Architecture: p.Architecture}, true)
-			for _, cp := range conflictingPackages {
-				reporter.Removed("%s removed due to conflict with package being added", cp)
-				list.Remove(cp)
-			}
+		if held := list.HeldFor(p); held != nil {
+			reporter.Warning("%s skipped, as %s is held in repo", p, held)
+			continue
+		}
+
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
 		}
 
-		err = list.Add(p)
+		var existing *Package
+		existing, err = list.AddWithConflictResolution(p, onConflict)
 		if err != nil {
 			reporter.Warning("Unable to add package to repo %s: %s", p, err)
 			failedFiles = append(failedFiles, file)
 			continue
 		}
 
-		reporter.Added("%s added", p)
+		if existing != nil {
+			switch onConflict {
+			case ConflictSkip:
+				reporter.Warning("%s skipped, as it conflicts with package %s already in repo", file, existing)
+				continue
+			case ConflictReplace:
+				reporter.Removed("%s removed due to conflict with package being added", existing)
+			}
+		}
+
+		if !isSourcePackage {
+			// changelog is extracted while package file is at hand, but only for accepted packages;
+			// failure is not fatal as it could be extracted later from the pool
+			var changelog *PackageChangelog
+			changelog, err = extractChangelogFromFile(file, p.Name)
+			if err == nil {
+				err = collection.UpdateChangelog(p, changelog)
+			}
+			if err != nil {
+				reporter.Warning("Unable to extract changelog from %s: %s", file, err)
+			}
+		}
+
+		if conflict != nil && conflict.RenamedTo != "" {
+			reporter.Added("%s added (renamed, as it conflicts with package already in repo)", p)
+		} else {
+			reporter.Added("%s added", p)
+		}
 		processedFiles = append(processedFiles, candidateProcessedFiles...)
 	}
 
@@ -0,0 +1,23 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ImportSuite struct{}
+
+var _ = Suite(&ImportSuite{})
+
+func (s *ImportSuite) TestPoolComponents(c *C) {
+	root := c.MkDir()
+	c.Check(PoolComponents(root), HasLen, 0)
+
+	c.Assert(os.MkdirAll(filepath.Join(root, "pool", "main", "a", "app"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(root, "pool", "contrib"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "pool", "README"), nil, 0644), IsNil)
+
+	c.Check(PoolComponents(root), DeepEquals, []string{"contrib", "main"})
+}
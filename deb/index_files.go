@@ -6,7 +6,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/pgp"
@@ -23,6 +26,71 @@ type indexFiles struct {
 	indexes          map[string]*indexFile
 	acquireByHash    bool
 	skipBz2          bool
+	pdiffs           bool
+	// publishedMD5s are checksums of files already published under basePath,
+	// loaded for storages which report checksums (see LoadPublishedChecksums)
+	publishedMD5s map[string]string
+
+	// lock protects renameMap & generatedFiles while index files are finalized concurrently
+	lock sync.Mutex
+	// storageLock serializes access to published storage and signing: not all the
+	// storages are safe for concurrent use (e.g. path caches of S3 and GCS are plain
+	// maps), and signer might interact with the user
+	storageLock sync.Mutex
+}
+
+func (files *indexFiles) addGeneratedFile(relativePath string, checksumInfo utils.ChecksumInfo) {
+	files.lock.Lock()
+	defer files.lock.Unlock()
+
+	files.generatedFiles[relativePath] = checksumInfo
+}
+
+func (files *indexFiles) generatedFile(relativePath string) utils.ChecksumInfo {
+	files.lock.Lock()
+	defer files.lock.Unlock()
+
+	return files.generatedFiles[relativePath]
+}
+
+func (files *indexFiles) addRename(oldName, newName string) {
+	files.lock.Lock()
+	defer files.lock.Unlock()
+
+	files.renameMap[oldName] = newName
+}
+
+// putFile publishes local file as name+ext (relative to basePath), upload is skipped
+// if the same file has been published already
+//
+// Path the file could be found at in published storage right now is returned: it's the
+// temporary name (with suffix) if file was uploaded, or the final name if upload was skipped
+func (files *indexFiles) putFile(name, ext, sourceFilename string) (string, error) {
+	finalPath := filepath.Join(files.basePath, name+ext)
+
+	if publishedMD5, ok := files.publishedMD5s[name+ext]; ok {
+		sourceMD5, err := utils.MD5ChecksumForFile(sourceFilename)
+		if err != nil {
+			return "", fmt.Errorf("unable to collect checksums: %s", err)
+		}
+
+		if sourceMD5 == publishedMD5 {
+			return finalPath, nil
+		}
+	}
+
+	uploadPath := filepath.Join(files.basePath, name+files.suffix+ext)
+
+	err := files.publishedStorage.PutFile(uploadPath, sourceFilename)
+	if err != nil {
+		return "", fmt.Errorf("unable to publish file: %s", err)
+	}
+
+	if files.suffix != "" {
+		files.addRename(uploadPath, finalPath)
+	}
+
+	return uploadPath, nil
 }
 
 type indexFile struct {
@@ -33,6 +101,8 @@ type indexFile struct {
 	clearSign     bool
 	detachedSign  bool
 	acquireByHash bool
+	pdiff         bool
+	unlisted      bool
 	relativePath  string
 	tempFilename  string
 	tempFile      *os.File
@@ -93,14 +163,27 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 		}
 	}
 
-	for _, ext := range cksumExts {
-		var checksumInfo utils.ChecksumInfo
+	if !file.unlisted {
+		for _, ext := range cksumExts {
+			var checksumInfo utils.ChecksumInfo
 
-		checksumInfo, err = utils.ChecksumsForFile(file.tempFilename + ext)
+			checksumInfo, err = utils.ChecksumsForFile(file.tempFilename + ext)
+			if err != nil {
+				return fmt.Errorf("unable to collect checksums: %s", err)
+			}
+			file.parent.addGeneratedFile(file.relativePath+ext, checksumInfo)
+		}
+	}
+
+	// compression and checksums are done concurrently, the rest goes to published storage
+	file.parent.storageLock.Lock()
+	defer file.parent.storageLock.Unlock()
+
+	if file.pdiff {
+		err = file.publishPDiff()
 		if err != nil {
-			return fmt.Errorf("unable to collect checksums: %s", err)
+			return fmt.Errorf("unable to generate pdiff: %s", err)
 		}
-		file.parent.generatedFiles[file.relativePath+ext] = checksumInfo
 	}
 
 	filedir := filepath.Dir(filepath.Join(file.parent.basePath, file.relativePath))
@@ -120,21 +203,16 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 	}
 
 	for _, ext := range exts {
-		err = file.parent.publishedStorage.PutFile(filepath.Join(file.parent.basePath, file.relativePath+file.parent.suffix+ext),
-			file.tempFilename+ext)
+		var publishedPath string
+		publishedPath, err = file.parent.putFile(file.relativePath, ext, file.tempFilename+ext)
 		if err != nil {
-			return fmt.Errorf("unable to publish file: %s", err)
-		}
-
-		if file.parent.suffix != "" {
-			file.parent.renameMap[filepath.Join(file.parent.basePath, file.relativePath+file.parent.suffix+ext)] =
-				filepath.Join(file.parent.basePath, file.relativePath+ext)
+			return err
 		}
 
 		if file.acquireByHash {
-			sums := file.parent.generatedFiles[file.relativePath+ext]
+			sums := file.parent.generatedFile(file.relativePath + ext)
 			for hash, sum := range map[string]string{"SHA512": sums.SHA512, "SHA256": sums.SHA256, "SHA1": sums.SHA1, "MD5Sum": sums.MD5} {
-				err = packageIndexByHash(file, ext, hash, sum)
+				err = packageIndexByHash(file, publishedPath, ext, hash, sum)
 				if err != nil {
 					return fmt.Errorf("unable to build hash file: %s", err)
 				}
@@ -142,7 +220,7 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 		}
 	}
 
-	if signer != nil {
+	if signer != nil && (file.detachedSign || file.clearSign) {
 		gpgExt := ".gpg"
 		if file.detachedSign {
 			err = signer.DetachedSign(file.tempFilename, file.tempFilename+gpgExt)
@@ -150,17 +228,10 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 				return fmt.Errorf("unable to detached sign file: %s", err)
 			}
 
-			if file.parent.suffix != "" {
-				file.parent.renameMap[filepath.Join(file.parent.basePath, file.relativePath+file.parent.suffix+gpgExt)] =
-					filepath.Join(file.parent.basePath, file.relativePath+gpgExt)
-			}
-
-			err = file.parent.publishedStorage.PutFile(filepath.Join(file.parent.basePath, file.relativePath+file.parent.suffix+gpgExt),
-				file.tempFilename+gpgExt)
+			_, err = file.parent.putFile(file.relativePath, gpgExt, file.tempFilename+gpgExt)
 			if err != nil {
-				return fmt.Errorf("unable to publish file: %s", err)
+				return err
 			}
-
 		}
 
 		if file.clearSign {
@@ -169,15 +240,10 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 				return fmt.Errorf("unable to clearsign file: %s", err)
 			}
 
-			if file.parent.suffix != "" {
-				file.parent.renameMap[filepath.Join(file.parent.basePath, "In"+file.relativePath+file.parent.suffix)] =
-					filepath.Join(file.parent.basePath, "In"+file.relativePath)
-			}
-
-			err = file.parent.publishedStorage.PutFile(filepath.Join(file.parent.basePath, "In"+file.relativePath+file.parent.suffix),
+			_, err = file.parent.putFile("In"+file.relativePath, "",
 				filepath.Join(filepath.Dir(file.tempFilename), "In"+filepath.Base(file.tempFilename)))
 			if err != nil {
-				return fmt.Errorf("unable to publish file: %s", err)
+				return err
 			}
 		}
 	}
@@ -185,10 +251,9 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 	return nil
 }
 
-func packageIndexByHash(file *indexFile, ext string, hash string, sum string) error {
-	src := filepath.Join(file.parent.basePath, file.relativePath)
-	indexfile := path.Base(src + ext)
-	src = src + file.parent.suffix + ext
+// packageIndexByHash links published index file src into by-hash directory
+func packageIndexByHash(file *indexFile, src string, ext string, hash string, sum string) error {
+	indexfile := path.Base(file.relativePath + ext)
 	filedir := filepath.Dir(filepath.Join(file.parent.basePath, file.relativePath))
 	dst := filepath.Join(filedir, "by-hash", hash)
 	sumfilePath := filepath.Join(dst, sum)
@@ -235,7 +300,7 @@ func packageIndexByHash(file *indexFile, ext string, hash string, sum string) er
 	return nil
 }
 
-func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, suffix string, acquireByHash bool, skipBz2 bool) *indexFiles {
+func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, suffix string, acquireByHash bool, skipBz2 bool, pdiffs bool) *indexFiles {
 	return &indexFiles{
 		publishedStorage: publishedStorage,
 		basePath:         basePath,
@@ -246,6 +311,7 @@ func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, s
 		indexes:          make(map[string]*indexFile),
 		acquireByHash:    acquireByHash,
 		skipBz2:          skipBz2,
+		pdiffs:           pdiffs,
 	}
 }
 
@@ -281,6 +347,7 @@ func (files *indexFiles) PackageIndex(component, arch string, udeb bool, install
 			detachedSign:  installer,
 			clearSign:     false,
 			acquireByHash: files.acquireByHash,
+			pdiff:         files.pdiffs && !installer,
 			relativePath:  relativePath,
 		}
 
@@ -400,32 +467,120 @@ func (files *indexFiles) ReleaseFile() *indexFile {
 	}
 }
 
-func (files *indexFiles) FinalizeAll(progress aptly.Progress, signer pgp.Signer) (err error) {
+// PlainFile is a file published next to Release file, but not listed in it
+// (e.g. public key of the repository)
+func (files *indexFiles) PlainFile(relativePath string) *indexFile {
+	return &indexFile{
+		parent:       files,
+		discardable:  false,
+		compressable: false,
+		detachedSign: false,
+		clearSign:    false,
+		unlisted:     true,
+		relativePath: relativePath,
+	}
+}
+
+// FinalizeAll compresses, checksums, signs and publishes all the index files
+//
+// Index files are independent of each other, so they're compressed and checksummed
+// concurrently by a bounded pool of workers (one per CPU), while uploads to published
+// storage are done by one worker at a time
+func (files *indexFiles) FinalizeAll(progress aptly.Progress, signer pgp.Signer) error {
 	if progress != nil {
 		progress.InitBar(int64(len(files.indexes)), false, aptly.BarPublishFinalizeIndexes)
 		defer progress.ShutdownBar()
 	}
 
+	queue := make(chan *indexFile)
+	errCh := make(chan error, len(files.indexes))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(files.indexes) {
+		workers = len(files.indexes)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for file := range queue {
+				err := file.Finalize(signer)
+				if err != nil {
+					errCh <- err
+					continue
+				}
+				if progress != nil {
+					progress.AddBar(1)
+				}
+			}
+		}()
+	}
+
 	for _, file := range files.indexes {
-		err = file.Finalize(signer)
-		if err != nil {
-			return
-		}
-		if progress != nil {
-			progress.AddBar(1)
-		}
+		queue <- file
+	}
+	close(queue)
+
+	wg.Wait()
+	close(errCh)
+
+	// report first error, if any
+	if err, ok := <-errCh; ok {
+		return err
 	}
 
 	files.indexes = make(map[string]*indexFile)
 
-	return
+	return nil
 }
 
-func (files *indexFiles) RenameFiles() error {
+// LoadPublishedChecksums fetches checksums of already published files, so that
+// files which haven't changed are not uploaded again (if storage reports checksums)
+func (files *indexFiles) LoadPublishedChecksums() error {
+	storage, ok := files.publishedStorage.(aptly.ChecksumPublishedStorage)
+	if !ok {
+		return nil
+	}
+
 	var err error
+	files.publishedMD5s, err = storage.FileMD5s(files.basePath)
+	if err != nil {
+		return fmt.Errorf("unable to list published files: %s", err)
+	}
+
+	return nil
+}
 
-	for oldName, newName := range files.renameMap {
-		err = files.publishedStorage.RenameFile(oldName, newName)
+// isReleaseFile returns true if path is one of Release files of the distribution
+func (files *indexFiles) isReleaseFile(path string) bool {
+	switch path {
+	case filepath.Join(files.basePath, "Release"), filepath.Join(files.basePath, "Release.gpg"), filepath.Join(files.basePath, "InRelease"):
+		return true
+	}
+
+	return false
+}
+
+// RenameFiles moves published files into place, Release files are moved last,
+// so that clients don't see Release file before index files it lists
+func (files *indexFiles) RenameFiles() error {
+	oldNames := make([]string, 0, len(files.renameMap))
+	for oldName := range files.renameMap {
+		oldNames = append(oldNames, oldName)
+	}
+	sort.Slice(oldNames, func(i, j int) bool {
+		iName, jName := files.renameMap[oldNames[i]], files.renameMap[oldNames[j]]
+		if iRelease, jRelease := files.isReleaseFile(iName), files.isReleaseFile(jName); iRelease != jRelease {
+			return jRelease
+		}
+		return iName < jName
+	})
+
+	for _, oldName := range oldNames {
+		err := files.publishedStorage.RenameFile(oldName, files.renameMap[oldName])
 		if err != nil {
 			return fmt.Errorf("unable to rename: %s", err)
 		}
@@ -433,3 +588,46 @@ func (files *indexFiles) RenameFiles() error {
 
 	return nil
 }
+
+// indexWriter writes to index files in a separate goroutine: writes are run one by
+// one in the order they were queued, first error stops all the following writes
+//
+// Publish uses one writer per architecture, so that package indexes of different
+// architectures are generated concurrently.
+type indexWriter struct {
+	queue chan func() error
+	done  chan struct{}
+	err   error
+}
+
+func newIndexWriter() *indexWriter {
+	w := &indexWriter{
+		queue: make(chan func() error, 128),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+
+		for write := range w.queue {
+			if w.err == nil {
+				w.err = write()
+			}
+		}
+	}()
+
+	return w
+}
+
+// Write queues write operation
+func (w *indexWriter) Write(write func() error) {
+	w.queue <- write
+}
+
+// Close waits for queued writes to finish and returns first error
+func (w *indexWriter) Close() error {
+	close(w.queue)
+	<-w.done
+
+	return w.err
+}
@@ -0,0 +1,248 @@
+package deb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type IndexFilesSuite struct {
+	root string
+}
+
+var _ = Suite(&IndexFilesSuite{})
+
+func (s *IndexFilesSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+}
+
+func (s *IndexFilesSuite) TestFinalizeAllConcurrently(c *C) {
+	storage := files.NewPublishedStorage(s.root, "", "")
+	indexes := newIndexFiles(storage, "dists/sid", c.MkDir(), ".tmp", true, false, false)
+
+	archs := []string{"amd64", "arm64", "armel", "armhf", "i386", "mips64el", "mipsel", "ppc64el", "riscv64", "s390x", "source"}
+	for _, arch := range archs {
+		w, err := indexes.PackageIndex("main", arch, false, false, "sid").BufWriter()
+		c.Assert(err, IsNil)
+		_, err = fmt.Fprintf(w, "Package: test\nArchitecture: %s\n\n", arch)
+		c.Assert(err, IsNil)
+
+		_, err = indexes.ReleaseIndex("main", arch, false).BufWriter()
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(indexes.FinalizeAll(nil, &NullSigner{}), IsNil)
+
+	// Packages, Packages.gz, Packages.bz2 & Release for each architecture
+	c.Check(indexes.generatedFiles, HasLen, 4*len(archs))
+	c.Check(indexes.renameMap, HasLen, 4*len(archs))
+	c.Check(indexes.indexes, HasLen, 0)
+
+	c.Assert(indexes.RenameFiles(), IsNil)
+	for _, arch := range archs[:len(archs)-1] {
+		c.Check(filepath.Join(s.root, "dists/sid/main", "binary-"+arch, "Packages.gz"), PathExists)
+		c.Check(filepath.Join(s.root, "dists/sid/main", "binary-"+arch, "by-hash/SHA256",
+			indexes.generatedFiles[filepath.Join("main", "binary-"+arch, "Packages")].SHA256), PathExists)
+	}
+	c.Check(filepath.Join(s.root, "dists/sid/main/source/Sources.bz2"), PathExists)
+}
+
+func (s *IndexFilesSuite) TestFinalizeAllError(c *C) {
+	storage := files.NewPublishedStorage(s.root, "", "")
+	indexes := newIndexFiles(storage, "dists/sid", c.MkDir(), "", false, false, false)
+
+	for _, arch := range []string{"amd64", "i386"} {
+		_, err := indexes.PackageIndex("main", arch, false, false, "sid").BufWriter()
+		c.Assert(err, IsNil)
+	}
+
+	// published directory can't be created
+	c.Assert(os.WriteFile(filepath.Join(s.root, "dists"), nil, 0644), IsNil)
+
+	c.Check(indexes.FinalizeAll(nil, nil), ErrorMatches, "unable to create dir: .*")
+}
+
+// exclusiveStorage is published storage which fails if files are uploaded concurrently
+type exclusiveStorage struct {
+	*files.PublishedStorage
+	active int32
+}
+
+func (storage *exclusiveStorage) PutFile(path string, sourceFilename string) error {
+	if atomic.AddInt32(&storage.active, 1) != 1 {
+		return fmt.Errorf("concurrent upload of %s", path)
+	}
+	defer atomic.AddInt32(&storage.active, -1)
+
+	time.Sleep(time.Millisecond)
+
+	return storage.PublishedStorage.PutFile(path, sourceFilename)
+}
+
+func (s *IndexFilesSuite) TestFinalizeAllSerializesUploads(c *C) {
+	storage := &exclusiveStorage{PublishedStorage: files.NewPublishedStorage(s.root, "", "")}
+	indexes := newIndexFiles(storage, "dists/sid", c.MkDir(), "", false, false, false)
+
+	for _, arch := range []string{"amd64", "arm64", "armhf", "i386", "ppc64el", "s390x"} {
+		_, err := indexes.PackageIndex("main", arch, false, false, "sid").BufWriter()
+		c.Assert(err, IsNil)
+	}
+
+	c.Check(indexes.FinalizeAll(nil, nil), IsNil)
+}
+
+func (s *IndexFilesSuite) TestIndexWriter(c *C) {
+	var result []int
+
+	w := newIndexWriter()
+	for i := 0; i < 1000; i++ {
+		i := i
+		w.Write(func() error {
+			result = append(result, i)
+			return nil
+		})
+	}
+	c.Assert(w.Close(), IsNil)
+	c.Assert(result, HasLen, 1000)
+	c.Check(sort.IntsAreSorted(result), Equals, true)
+
+	result = nil
+
+	w = newIndexWriter()
+	for i := 0; i < 10; i++ {
+		i := i
+		w.Write(func() error {
+			result = append(result, i)
+			if i == 3 {
+				return fmt.Errorf("failed at %d", i)
+			}
+			return nil
+		})
+	}
+	c.Check(w.Close(), ErrorMatches, "failed at 3")
+	c.Check(result, DeepEquals, []int{0, 1, 2, 3})
+}
+
+// checksumStorage is published storage reporting checksums, which records uploads & renames
+type checksumStorage struct {
+	*files.PublishedStorage
+	uploaded []string
+	renamed  []string
+}
+
+func (storage *checksumStorage) PutFile(path string, sourceFilename string) error {
+	storage.uploaded = append(storage.uploaded, path)
+	return storage.PublishedStorage.PutFile(path, sourceFilename)
+}
+
+func (storage *checksumStorage) RenameFile(oldName, newName string) error {
+	storage.renamed = append(storage.renamed, newName)
+	return storage.PublishedStorage.RenameFile(oldName, newName)
+}
+
+func (storage *checksumStorage) FileMD5s(prefix string) (map[string]string, error) {
+	paths, err := storage.Filelist(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	for _, path := range paths {
+		result[path], err = utils.MD5ChecksumForFile(filepath.Join(storage.PublicPath(), prefix, path))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *IndexFilesSuite) publish(c *C, storage aptly.PublishedStorage, suffix string, acquireByHash bool, packages map[string]string) *indexFiles {
+	indexes := newIndexFiles(storage, "dists/sid", c.MkDir(), suffix, acquireByHash, true, false)
+	c.Assert(indexes.LoadPublishedChecksums(), IsNil)
+
+	for arch, contents := range packages {
+		w, err := indexes.PackageIndex("main", arch, false, false, "sid").BufWriter()
+		c.Assert(err, IsNil)
+		_, err = w.WriteString(contents)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(indexes.FinalizeAll(nil, nil), IsNil)
+
+	release := indexes.ReleaseFile()
+	w, err := release.BufWriter()
+	c.Assert(err, IsNil)
+	for _, contents := range packages {
+		_, err = w.WriteString(contents)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(release.Finalize(nil), IsNil)
+
+	return indexes
+}
+
+func (s *IndexFilesSuite) TestPublishOnlyChanged(c *C) {
+	storage := &checksumStorage{PublishedStorage: files.NewPublishedStorage(s.root, "", "")}
+
+	indexes := s.publish(c, storage, "", false, map[string]string{"amd64": "Package: a\n\n", "i386": "Package: b\n\n"})
+	c.Check(indexes.RenameFiles(), IsNil)
+	c.Check(storage.uploaded, HasLen, 5)
+
+	storage.uploaded = nil
+	indexes = s.publish(c, storage, ".tmp", false, map[string]string{"amd64": "Package: a\n\n", "i386": "Package: c\n\n"})
+	sort.Strings(storage.uploaded)
+	c.Check(storage.uploaded, DeepEquals, []string{"dists/sid/Release.tmp", "dists/sid/main/binary-i386/Packages.tmp", "dists/sid/main/binary-i386/Packages.tmp.gz"})
+
+	c.Assert(indexes.RenameFiles(), IsNil)
+	c.Check(storage.renamed, DeepEquals, []string{"dists/sid/main/binary-i386/Packages", "dists/sid/main/binary-i386/Packages.gz", "dists/sid/Release"})
+
+	contents, err := os.ReadFile(filepath.Join(s.root, "dists/sid/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "Package: c\n\n")
+}
+
+func (s *IndexFilesSuite) TestPublishUnchangedAcquireByHash(c *C) {
+	storage := &checksumStorage{PublishedStorage: files.NewPublishedStorage(s.root, "", "")}
+
+	indexes := s.publish(c, storage, "", false, map[string]string{"amd64": "Package: a\n\n"})
+	c.Check(indexes.RenameFiles(), IsNil)
+
+	// nothing changed, but by-hash files are published for the first time
+	storage.uploaded = nil
+	indexes = s.publish(c, storage, ".tmp", true, map[string]string{"amd64": "Package: a\n\n"})
+	c.Check(storage.uploaded, HasLen, 0)
+	c.Assert(indexes.RenameFiles(), IsNil)
+
+	sums := indexes.generatedFile("main/binary-amd64/Packages")
+	contents, err := os.ReadFile(filepath.Join(s.root, "dists/sid/main/binary-amd64/by-hash/SHA256", sums.SHA256))
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "Package: a\n\n")
+
+	c.Check(filepath.Join(s.root, "dists/sid/main/binary-amd64/by-hash/MD5Sum/Packages.gz"), PathExists)
+	c.Check(filepath.Join(s.root, "dists/sid/main/binary-amd64/Packages.tmp"), Not(PathExists))
+}
+
+func (s *IndexFilesSuite) TestRenameFilesReleaseLast(c *C) {
+	storage := &checksumStorage{PublishedStorage: files.NewPublishedStorage(s.root, "", "")}
+	indexes := newIndexFiles(storage, "dists/sid", c.MkDir(), ".tmp", false, false, false)
+
+	for _, name := range []string{"InRelease", "Release", "Release.gpg", "main/binary-amd64/Packages", "main/binary-amd64/Release", "Contents-amd64.gz"} {
+		path := filepath.Join(s.root, "dists/sid", name+".tmp")
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(os.WriteFile(path, nil, 0644), IsNil)
+		indexes.addRename(filepath.Join("dists/sid", name+".tmp"), filepath.Join("dists/sid", name))
+	}
+
+	c.Assert(indexes.RenameFiles(), IsNil)
+	c.Check(storage.renamed, DeepEquals, []string{"dists/sid/Contents-amd64.gz", "dists/sid/main/binary-amd64/Packages",
+		"dists/sid/main/binary-amd64/Release", "dists/sid/InRelease", "dists/sid/Release", "dists/sid/Release.gpg"})
+}
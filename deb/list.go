@@ -1,6 +1,7 @@
 package deb
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -44,6 +45,8 @@ type PackageList struct {
 	duplicatesAllowed bool
 	// Has index been prepared?
 	indexed bool
+	// Held packages by architecture and name, see Hold
+	held map[string]*Package
 }
 
 // PackageConflictError means that package can't be added to the list due to error
@@ -91,6 +94,13 @@ func NewPackageListWithDuplicates(duplicates bool, capacity int) *PackageList {
 
 // NewPackageListFromRefList loads packages list from PackageRefList
 func NewPackageListFromRefList(reflist *PackageRefList, collection *PackageCollection, progress aptly.Progress) (*PackageList, error) {
+	return NewPackageListFromRefListWithFields(reflist, collection, PackageFieldsAll, progress)
+}
+
+// NewPackageListFromRefListWithFields loads packages list from PackageRefList keeping in memory
+// only selected fields of packages (see PackageCollection.ByKeyWithFields)
+func NewPackageListFromRefListWithFields(reflist *PackageRefList, collection *PackageCollection, fields PackageFields,
+	progress aptly.Progress) (*PackageList, error) {
 	// empty reflist
 	if reflist == nil {
 		return NewPackageList(), nil
@@ -103,7 +113,7 @@ func NewPackageListFromRefList(reflist *PackageRefList, collection *PackageColle
 	}
 
 	err := reflist.ForEach(func(key []byte) error {
-		p, err2 := collection.ByKey(key)
+		p, err2 := collection.ByKeyWithFields(key, fields)
 		if err2 != nil {
 			return fmt.Errorf("unable to load package with key %s: %s", key, err2)
 		}
@@ -159,6 +169,61 @@ func (l *PackageList) Add(p *Package) error {
 	return nil
 }
 
+// Conflict resolution modes for AddWithConflictResolution
+const (
+	// ConflictFail fails to add conflicting package
+	ConflictFail = "fail"
+	// ConflictSkip keeps package already in the list
+	ConflictSkip = "skip"
+	// ConflictReplace replaces package already in the list with the new one
+	ConflictReplace = "replace"
+)
+
+// ConflictResolutions lists all supported conflict resolution modes
+var ConflictResolutions = []string{ConflictFail, ConflictSkip, ConflictReplace}
+
+// AddWithConflictResolution appends package to package list, resolving conflict with package
+// already in the list (same name, version and architecture, but different files) according to mode.
+//
+// Package which conflicted with p (skipped or replaced) is returned, nil if there was no conflict.
+func (l *PackageList) AddWithConflictResolution(p *Package, mode string) (*Package, error) {
+	existing, ok := l.packages[l.keyFunc(p)]
+	if !ok || existing.Equals(p) {
+		return nil, l.Add(p)
+	}
+
+	switch mode {
+	case ConflictSkip:
+		return existing, nil
+	case ConflictReplace:
+		l.Remove(existing)
+		return existing, l.Add(p)
+	case ConflictFail:
+		return existing, l.Add(p)
+	}
+
+	return existing, fmt.Errorf("unknown conflict resolution mode: %s", mode)
+}
+
+// Hold marks package as held: other versions of the package (with the same name
+// and architecture) shouldn't be added to the list, see HeldFor
+func (l *PackageList) Hold(p *Package) {
+	if l.held == nil {
+		l.held = make(map[string]*Package)
+	}
+	l.held[p.Architecture+" "+p.Name] = p
+}
+
+// HeldFor returns held package which prevents p from being added to the list,
+// nil is returned if p could be added
+func (l *PackageList) HeldFor(p *Package) *Package {
+	held := l.held[p.Architecture+" "+p.Name]
+	if held == nil || held.Equals(p) {
+		return nil
+	}
+	return held
+}
+
 // ForEach calls handler for each package in list
 func (l *PackageList) ForEach(handler func(*Package) error) error {
 	var err error
@@ -172,6 +237,9 @@ func (l *PackageList) ForEach(handler func(*Package) error) error {
 }
 
 // ForEachIndexed calls handler for each package in list in indexed order
+//
+// Indexed order is stable: packages are sorted by name, version and architecture,
+// so the same list is always iterated in the same order (e.g. when generating Packages files)
 func (l *PackageList) ForEachIndexed(handler func(*Package) error) error {
 	if !l.indexed {
 		panic("list not indexed, can't iterate")
@@ -279,6 +347,19 @@ func (l *PackageList) FullNames() []string {
 	return result
 }
 
+// depSliceApplicable filters out dependencies which don't apply to architecture
+func depSliceApplicable(s []Dependency, arch string) []Dependency {
+	j := 0
+	for i := range s {
+		if s[i].AppliesTo(arch) {
+			s[j] = s[i]
+			j++
+		}
+	}
+
+	return s[:j]
+}
+
 // depSliceDeduplicate removes dups in slice of Dependencies
 func depSliceDeduplicate(s []Dependency) []Dependency {
 	l := len(s)
@@ -310,11 +391,90 @@ func depSliceDeduplicate(s []Dependency) []Dependency {
 //
 // Analysis would be performed for each architecture, in specified sources
 func (l *PackageList) VerifyDependencies(options int, architectures []string, sources *PackageList, progress aptly.Progress) ([]Dependency, error) {
-	l.PrepareIndex()
 	missing := make([]Dependency, 0, 128)
 
+	err := l.walkMissingDependencies(options, architectures, sources, progress, func(_ *Package, dep Dependency) {
+		missing = append(missing, dep)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing = depSliceDeduplicate(missing)
+
+	if options&DepVerboseResolve == DepVerboseResolve && progress != nil {
+		missingStr := make([]string, len(missing))
+		for i := range missing {
+			missingStr[i] = missing[i].String()
+		}
+		progress.ColoredPrintf("@{y}Missing dependencies:@| %s", strings.Join(missingStr, ", "))
+	}
+
+	return missing, nil
+}
+
+// MissingDependency is unsatisfied dependency along with packages requiring it
+type MissingDependency struct {
+	Dependency Dependency
+	RequiredBy []string
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (m MissingDependency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Dependency string
+		RequiredBy []string
+	}{m.Dependency.String(), m.RequiredBy})
+}
+
+// VerifyDependenciesRequiredBy is like VerifyDependencies, but for each missing dependency
+// it also reports list of packages which require it
+//
+// Result is sorted by dependency
+func (l *PackageList) VerifyDependenciesRequiredBy(options int, architectures []string, sources *PackageList, progress aptly.Progress) ([]MissingDependency, error) {
+	missing := make(map[string]*MissingDependency)
+	seen := make(map[string]bool)
+
+	err := l.walkMissingDependencies(options, architectures, sources, progress, func(p *Package, dep Dependency) {
+		hash := dep.Hash()
+		entry, ok := missing[hash]
+		if !ok {
+			entry = &MissingDependency{Dependency: dep}
+			missing[hash] = entry
+		}
+
+		key := hash + " " + string(p.Key(""))
+		if !seen[key] {
+			seen[key] = true
+			entry.RequiredBy = append(entry.RequiredBy, p.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MissingDependency, 0, len(missing))
+	for _, entry := range missing {
+		sort.Strings(entry.RequiredBy)
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Dependency.String() < result[j].Dependency.String()
+	})
+
+	return result, nil
+}
+
+// walkMissingDependencies calls handler for every package and its dependency which can't
+// be satisfied from sources
+func (l *PackageList) walkMissingDependencies(options int, architectures []string, sources *PackageList, progress aptly.Progress,
+	handler func(p *Package, dep Dependency)) error {
+	l.PrepareIndex()
+
 	if progress != nil {
 		progress.InitBar(int64(l.Len())*int64(len(architectures)), false, aptly.BarGeneralVerifyDependencies)
+		defer progress.ShutdownBar()
 	}
 
 	for _, arch := range architectures {
@@ -332,7 +492,12 @@ func (l *PackageList) VerifyDependencies(options int, architectures []string, so
 			for _, dep := range p.GetDependencies(options) {
 				variants, err := ParseDependencyVariants(dep)
 				if err != nil {
-					return nil, fmt.Errorf("unable to process package %s: %s", p, err)
+					return fmt.Errorf("unable to process package %s: %s", p, err)
+				}
+
+				variants = depSliceApplicable(variants, arch)
+				if len(variants) == 0 {
+					continue
 				}
 
 				variants = depSliceDeduplicate(variants)
@@ -361,26 +526,14 @@ func (l *PackageList) VerifyDependencies(options int, architectures []string, so
 					}
 				}
 
-				missing = append(missing, variantsMissing...)
+				for _, dep := range variantsMissing {
+					handler(p, dep)
+				}
 			}
 		}
 	}
 
-	missing = depSliceDeduplicate(missing)
-
-	if progress != nil {
-		progress.ShutdownBar()
-	}
-
-	if options&DepVerboseResolve == DepVerboseResolve && progress != nil {
-		missingStr := make([]string, len(missing))
-		for i := range missing {
-			missingStr[i] = missing[i].String()
-		}
-		progress.ColoredPrintf("@{y}Missing dependencies:@| %s", strings.Join(missingStr, ", "))
-	}
-
-	return missing, nil
+	return nil
 }
 
 // Swap swaps two packages in index
@@ -392,6 +545,15 @@ func (l *PackageList) lessPackages(iPkg, jPkg *Package) bool {
 	if iPkg.Name == jPkg.Name {
 		cmp := CompareVersions(iPkg.Version, jPkg.Version)
 		if cmp == 0 {
+			if iPkg.Architecture == jPkg.Architecture {
+				// versions could be equal while spelled differently (1.0 vs 0:1.0), and list with
+				// duplicates might contain packages which differ only in files, break ties
+				// so that order doesn't depend on map iteration order
+				if iPkg.Version != jPkg.Version {
+					return iPkg.Version < jPkg.Version
+				}
+				return iPkg.FilesHash < jPkg.FilesHash
+			}
 			return iPkg.Architecture < jPkg.Architecture
 		}
 		return cmp == 1
@@ -400,7 +562,8 @@ func (l *PackageList) lessPackages(iPkg, jPkg *Package) bool {
 	return iPkg.Name < jPkg.Name
 }
 
-// Less compares two packages by name (lexographical) and version (latest to oldest)
+// Less compares two packages by name (lexographical), version (latest to oldest) and
+// architecture, so that order of packages in the list is always deterministic
 func (l *PackageList) Less(i, j int) bool {
 	return l.lessPackages(l.packagesIndex[i], l.packagesIndex[j])
 }
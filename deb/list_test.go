@@ -134,6 +134,41 @@ func (s *PackageListSuite) TestAddLen(c *C) {
 	c.Check(s.list.Add(s.p4), ErrorMatches, "conflict in package.*")
 }
 
+func (s *PackageListSuite) TestAddWithConflictResolution(c *C) {
+	c.Check(s.list.Add(s.p1), IsNil)
+
+	conflicting, err := s.list.AddWithConflictResolution(s.p2, ConflictFail)
+	c.Check(err, IsNil)
+	c.Check(conflicting, IsNil)
+
+	conflicting, err = s.list.AddWithConflictResolution(s.p4, ConflictFail)
+	c.Check(err, ErrorMatches, "conflict in package.*")
+	c.Check(conflicting, Equals, s.p1)
+
+	conflicting, err = s.list.AddWithConflictResolution(s.p4, "overwrite")
+	c.Check(err, ErrorMatches, "unknown conflict resolution mode: overwrite")
+	c.Check(conflicting, Equals, s.p1)
+
+	conflicting, err = s.list.AddWithConflictResolution(s.p4, ConflictSkip)
+	c.Check(err, IsNil)
+	c.Check(conflicting, Equals, s.p1)
+	c.Check(s.list.Len(), Equals, 1)
+	c.Check(s.list.Has(s.p1), Equals, true)
+
+	s.list.PrepareIndex()
+
+	conflicting, err = s.list.AddWithConflictResolution(s.p4, ConflictReplace)
+	c.Check(err, IsNil)
+	c.Check(conflicting, Equals, s.p1)
+	c.Check(s.list.Len(), Equals, 1)
+	c.Check(s.list.packagesIndex, DeepEquals, []*Package{s.p4})
+
+	conflicting, err = s.list.AddWithConflictResolution(s.p3, ConflictReplace)
+	c.Check(err, IsNil)
+	c.Check(conflicting, IsNil)
+	c.Check(s.list.Len(), Equals, 2)
+}
+
 func (s *PackageListSuite) TestRemove(c *C) {
 	c.Check(s.list.Add(s.p1), IsNil)
 	c.Check(s.list.Add(s.p3), IsNil)
@@ -231,6 +266,31 @@ func (s *PackageListSuite) TestIndex(c *C) {
 	c.Check(s.il.packagesIndex[0], Equals, s.packages[8])
 }
 
+func (s *PackageListSuite) TestIndexStableOrder(c *C) {
+	packages := []*Package{
+		{Name: "app", Version: "1.0", Architecture: "amd64", FilesHash: 2, V06Plus: true, deps: &PackageDependencies{}},
+		{Name: "app", Version: "0:1.0", Architecture: "amd64", FilesHash: 1, V06Plus: true, deps: &PackageDependencies{}},
+		{Name: "app", Version: "1.0", Architecture: "amd64", FilesHash: 1, V06Plus: true, deps: &PackageDependencies{}},
+		{Name: "app", Version: "1.0", Architecture: "i386", FilesHash: 1, V06Plus: true, deps: &PackageDependencies{}},
+	}
+
+	for i := 0; i < 10; i++ {
+		list := NewPackageListWithDuplicates(true, 0)
+		for j := range packages {
+			c.Assert(list.Add(packages[(i+j)%len(packages)]), IsNil)
+		}
+		list.PrepareIndex()
+
+		result := []*Package{}
+		list.ForEachIndexed(func(p *Package) error {
+			result = append(result, p)
+			return nil
+		})
+
+		c.Check(result, DeepEquals, []*Package{packages[1], packages[2], packages[0], packages[3]})
+	}
+}
+
 func (s *PackageListSuite) TestAppend(c *C) {
 	s.list.Add(s.p1)
 	s.list.Add(s.p3)
@@ -429,6 +489,45 @@ func (s *PackageListSuite) TestVerifyDependencies(c *C) {
 	c.Check(err, ErrorMatches, "unable to process package app_1.0_s390:.*")
 }
 
+func (s *PackageListSuite) TestVerifyDependenciesArchRestrictions(c *C) {
+	list := NewPackageList()
+	for _, p := range []*Package{
+		{Name: "tool", Version: "1.0", Architecture: "amd64", deps: &PackageDependencies{Depends: []string{"libcap2 [linux-any]", "libunwind8 [!armhf]"}}},
+		{Name: "tool", Version: "1.0", Architecture: "armhf", deps: &PackageDependencies{Depends: []string{"libcap2 [linux-any]", "libunwind8 [!armhf]"}}},
+		{Name: "tool", Version: "1.0", Architecture: "hurd-i386", deps: &PackageDependencies{Depends: []string{"libcap2 [linux-any]", "libunwind8 [!armhf]"}}},
+	} {
+		list.Add(p)
+	}
+	list.PrepareIndex()
+
+	missing, err := list.VerifyDependencies(0, []string{"amd64"}, list, nil)
+	c.Check(err, IsNil)
+	c.Check(missing, DeepEquals, []Dependency{{Pkg: "libcap2", Relation: VersionDontCare, Architecture: "amd64", ArchRestriction: "linux-any"},
+		{Pkg: "libunwind8", Relation: VersionDontCare, Architecture: "amd64", ArchRestriction: "!armhf"}})
+
+	missing, err = list.VerifyDependencies(0, []string{"armhf"}, list, nil)
+	c.Check(err, IsNil)
+	c.Check(missing, DeepEquals, []Dependency{{Pkg: "libcap2", Relation: VersionDontCare, Architecture: "armhf", ArchRestriction: "linux-any"}})
+
+	missing, err = list.VerifyDependencies(0, []string{"hurd-i386"}, list, nil)
+	c.Check(err, IsNil)
+	c.Check(missing, DeepEquals, []Dependency{{Pkg: "libunwind8", Relation: VersionDontCare, Architecture: "hurd-i386", ArchRestriction: "!armhf"}})
+}
+
+func (s *PackageListSuite) TestVerifyDependenciesRequiredBy(c *C) {
+	missing, err := s.il.VerifyDependenciesRequiredBy(0, []string{"i386"}, s.il, nil)
+	c.Check(err, IsNil)
+	c.Check(missing, DeepEquals, []MissingDependency{})
+
+	missing, err = s.il.VerifyDependenciesRequiredBy(DepFollowAllVariants, []string{"arm"}, s.il, nil)
+	c.Check(err, IsNil)
+	c.Assert(missing, HasLen, 2)
+	c.Check(missing[0].Dependency, DeepEquals, Dependency{Pkg: "lib", Relation: VersionGreater, Version: "0.9", Architecture: "arm"})
+	c.Check(missing[0].RequiredBy, DeepEquals, []string{"app_1.1~bp1_arm"})
+	c.Check(missing[1].Dependency, DeepEquals, Dependency{Pkg: "mail-agent", Relation: VersionDontCare, Version: "", Architecture: "arm"})
+	c.Check(missing[1].RequiredBy, DeepEquals, []string{"app_1.1~bp1_arm"})
+}
+
 func (s *PackageListSuite) TestArchitectures(c *C) {
 	archs := s.il.Architectures(true)
 	sort.Strings(archs)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/aptly-dev/aptly/database"
 	"github.com/pborman/uuid"
@@ -25,6 +26,9 @@ type LocalRepo struct {
 	DefaultComponent string `codec:",omitempty"`
 	// Uploaders configuration
 	Uploaders *Uploaders `codec:"Uploaders,omitempty" json:"-"`
+	// Keys of packages held in the repo: other versions of held packages are not
+	// added, copied or moved to the repo
+	Held []string `codec:",omitempty" json:",omitempty"`
 	// "Snapshot" of current list of packages
 	packageRefs *PackageRefList
 }
@@ -59,9 +63,71 @@ func (repo *LocalRepo) RefList() *PackageRefList {
 	return repo.packageRefs
 }
 
-// UpdateRefList changes package list for local repo
+// UpdateRefList changes package list for local repo, packages which are no longer
+// in the repo are not held anymore
 func (repo *LocalRepo) UpdateRefList(reflist *PackageRefList) {
 	repo.packageRefs = reflist
+
+	held := repo.Held[:0]
+	for _, key := range repo.Held {
+		if reflist != nil && reflist.HasKey([]byte(key)) {
+			held = append(held, key)
+		}
+	}
+	repo.Held = held
+	if len(repo.Held) == 0 {
+		repo.Held = nil
+	}
+}
+
+// IsHeld checks whether package is held in the repo
+func (repo *LocalRepo) IsHeld(p *Package) bool {
+	key := string(p.Key(""))
+	for _, held := range repo.Held {
+		if held == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold marks package in the repo as held, returns false if package is already held
+func (repo *LocalRepo) Hold(p *Package) bool {
+	if repo.IsHeld(p) {
+		return false
+	}
+	repo.Held = append(repo.Held, string(p.Key("")))
+	sort.Strings(repo.Held)
+	return true
+}
+
+// Unhold releases hold of package in the repo, returns false if package is not held
+func (repo *LocalRepo) Unhold(p *Package) bool {
+	key := string(p.Key(""))
+	for i, held := range repo.Held {
+		if held == key {
+			repo.Held = append(repo.Held[:i], repo.Held[i+1:]...)
+			if len(repo.Held) == 0 {
+				repo.Held = nil
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// HoldPackages marks packages held in the repo as held in list of repo packages
+func (repo *LocalRepo) HoldPackages(list *PackageList) {
+	if len(repo.Held) == 0 {
+		return
+	}
+
+	_ = list.ForEach(func(p *Package) error {
+		if repo.IsHeld(p) {
+			list.Hold(p)
+		}
+		return nil
+	})
 }
 
 // Encode does msgpack encoding of LocalRepo
@@ -92,8 +158,9 @@ func (repo *LocalRepo) RefKey() []byte {
 
 // LocalRepoCollection does listing, updating/adding/deleting of LocalRepos
 type LocalRepoCollection struct {
-	db    database.Storage
-	cache map[string]*LocalRepo
+	db       database.Storage
+	cache    map[string]*LocalRepo
+	recorder *AuditRecorder
 }
 
 // NewLocalRepoCollection loads LocalRepos from DB and makes up collection
@@ -153,6 +220,7 @@ func (collection *LocalRepoCollection) Add(repo *LocalRepo) error {
 	}
 
 	collection.cache[repo.UUID] = repo
+	collection.recorder.Record(AuditActionCreated, AuditKindLocalRepo, repo.Name, repo.UUID)
 	return nil
 }
 
@@ -163,7 +231,11 @@ func (collection *LocalRepoCollection) Update(repo *LocalRepo) error {
 	if repo.packageRefs != nil {
 		batch.Put(repo.RefKey(), repo.packageRefs.Encode())
 	}
-	return batch.Write()
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionUpdated, AuditKindLocalRepo, repo.Name, repo.UUID)
+	}
+	return err
 }
 
 // LoadComplete loads additional information for local repo
@@ -249,5 +321,9 @@ func (collection *LocalRepoCollection) Drop(repo *LocalRepo) error {
 	batch := collection.db.CreateBatch()
 	batch.Delete(repo.Key())
 	batch.Delete(repo.RefKey())
-	return batch.Write()
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionDropped, AuditKindLocalRepo, repo.Name, repo.UUID)
+	}
+	return err
 }
@@ -54,6 +54,37 @@ func (s *LocalRepoSuite) TestUpdateRefList(c *C) {
 	c.Check(s.repo.RefList(), IsNil)
 }
 
+func (s *LocalRepoSuite) TestHold(c *C) {
+	lib := &Package{Name: "lib", Version: "1.7", Architecture: "i386"}
+	app := &Package{Name: "app", Version: "1.9", Architecture: "amd64"}
+
+	c.Check(s.repo.IsHeld(lib), Equals, false)
+	c.Check(s.repo.Hold(lib), Equals, true)
+	c.Check(s.repo.Hold(lib), Equals, false)
+	c.Check(s.repo.Hold(app), Equals, true)
+	c.Check(s.repo.IsHeld(lib), Equals, true)
+	c.Check(s.repo.Held, DeepEquals, []string{"Pamd64 app 1.9", "Pi386 lib 1.7"})
+
+	repo := &LocalRepo{}
+	c.Assert(repo.Decode(s.repo.Encode()), IsNil)
+	c.Check(repo.Held, DeepEquals, s.repo.Held)
+
+	list := NewPackageList()
+	c.Assert(list.Add(lib), IsNil)
+	s.repo.HoldPackages(list)
+	c.Check(list.HeldFor(lib), IsNil)
+	c.Check(list.HeldFor(&Package{Name: "lib", Version: "1.8", Architecture: "i386"}), Equals, lib)
+	c.Check(list.HeldFor(&Package{Name: "lib", Version: "1.8", Architecture: "amd64"}), IsNil)
+
+	c.Check(s.repo.Unhold(app), Equals, true)
+	c.Check(s.repo.Unhold(app), Equals, false)
+
+	// packages removed from the repo are not held anymore
+	s.list.Remove(lib)
+	s.repo.UpdateRefList(NewPackageRefListFromPackageList(s.list))
+	c.Check(s.repo.Held, IsNil)
+}
+
 func (s *LocalRepoSuite) TestEncodeDecode(c *C) {
 	repo := &LocalRepo{}
 	err := repo.Decode(s.repo.Encode())
@@ -0,0 +1,276 @@
+package deb
+
+import (
+	"bufio"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// MirrorListPrefix marks archive URL which points to the list of mirrors
+// in apt-transport-mirror format, e.g. mirror+file:///etc/aptly/debian.list
+// or mirror+http://example.com/mirrors.txt
+const MirrorListPrefix = "mirror+"
+
+// mirrorProbeTimeout limits time spent probing single mirror
+const mirrorProbeTimeout = 10 * time.Second
+
+// IsMirrorList checks whether archive URL is a list of mirrors
+func IsMirrorList(archiveRoot string) bool {
+	return strings.HasPrefix(archiveRoot, MirrorListPrefix)
+}
+
+// ParseMirrorList parses list of mirrors in apt-transport-mirror format: one URL
+// per line optionally followed by tab-separated metadata, empty lines and
+// lines starting with # are ignored
+func ParseMirrorList(r io.Reader) ([]string, error) {
+	var result []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		root, err := normalizeMirrorURL(strings.Fields(line)[0])
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, root)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("list of mirrors is empty")
+	}
+
+	return result, nil
+}
+
+// normalizeMirrorURL validates mirror URL and brings it to the form used as archive root
+func normalizeMirrorURL(root string) (string, error) {
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+
+	parsed, err := url.Parse(root)
+	if err != nil || parsed.Scheme == "" {
+		return "", fmt.Errorf("invalid mirror URL: %s", root)
+	}
+
+	return parsed.String(), nil
+}
+
+// loadMirrors builds list of all the mirrors of the repository: mirrors from
+// the list (or archive root) followed by alternate URLs
+func (repo *RemoteRepo) loadMirrors(d aptly.Downloader) ([]string, error) {
+	var roots []string
+
+	if IsMirrorList(repo.ArchiveRoot) {
+		listURL, err := url.Parse(strings.TrimPrefix(repo.ArchiveRoot, MirrorListPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse list of mirrors URL: %s", err)
+		}
+
+		var list *os.File
+		if listURL.Scheme == "file" {
+			list, err = os.Open(listURL.Path)
+		} else {
+			list, err = http.DownloadTemp(gocontext.TODO(), d, listURL.String())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to load list of mirrors: %s", err)
+		}
+		defer list.Close()
+
+		roots, err = ParseMirrorList(list)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load list of mirrors: %s", err)
+		}
+	} else {
+		root, err := normalizeMirrorURL(repo.ArchiveRoot)
+		if err != nil {
+			return nil, err
+		}
+		roots = []string{root}
+	}
+
+	for _, alternate := range repo.AlternateURLs {
+		root, err := normalizeMirrorURL(alternate)
+		if err != nil {
+			return nil, err
+		}
+
+		if !utils.StrSliceHasItem(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+
+	return roots, nil
+}
+
+// rankMirrors orders mirrors by latency of Release file request, mirrors
+// which failed to respond go last
+func (repo *RemoteRepo) rankMirrors(d aptly.Downloader, roots []string) []string {
+	latency := make(map[string]time.Duration, len(roots))
+
+	for _, root := range roots {
+		rootURL, _ := url.Parse(root)
+
+		ctx, cancel := gocontext.WithTimeout(gocontext.TODO(), mirrorProbeTimeout)
+		start := time.Now()
+		_, err := d.GetLength(ctx, repo.indexesRootURL(rootURL).ResolveReference(&url.URL{Path: "Release"}).String())
+		cancel()
+
+		if err != nil {
+			latency[root] = -1
+		} else {
+			latency[root] = time.Since(start)
+		}
+	}
+
+	result := append([]string(nil), roots...)
+	sort.SliceStable(result, func(i, j int) bool {
+		li, lj := latency[result[i]], latency[result[j]]
+		if li < 0 || lj < 0 {
+			return lj < 0 && li >= 0
+		}
+		return li < lj
+	})
+
+	return result
+}
+
+// SelectMirror resolves list of mirrors (if repository has more than one mirror)
+// and switches archive root to the fastest one for the rest of the process
+func (repo *RemoteRepo) SelectMirror(d aptly.Downloader) error {
+	if !IsMirrorList(repo.ArchiveRoot) && len(repo.AlternateURLs) == 0 {
+		return nil
+	}
+
+	if m, ok := d.(*mirrorDownloader); ok {
+		// probes shouldn't fail over to other mirrors
+		d = m.Downloader
+	}
+
+	roots, err := repo.loadMirrors(d)
+	if err != nil {
+		return err
+	}
+
+	if len(roots) > 1 {
+		roots = repo.rankMirrors(d, roots)
+	}
+
+	repo.archiveRootURL, err = url.Parse(roots[0])
+	if err != nil {
+		return err
+	}
+	repo.mirrors = roots
+
+	return nil
+}
+
+// Mirrors returns list of mirrors ordered by preference, as resolved by SelectMirror
+func (repo *RemoteRepo) Mirrors() []string {
+	return repo.mirrors
+}
+
+// mirrorDownloader retries failed downloads from the archive root on other mirrors
+type mirrorDownloader struct {
+	aptly.Downloader
+	repo *RemoteRepo
+}
+
+// Check interface
+var (
+	_ aptly.ConditionalDownloader = (*mirrorDownloader)(nil)
+)
+
+// MirrorDownloader wraps downloader so that downloads failed on the selected mirror
+// are retried on other mirrors of the repository
+func (repo *RemoteRepo) MirrorDownloader(d aptly.Downloader) aptly.Downloader {
+	return &mirrorDownloader{Downloader: d, repo: repo}
+}
+
+// alternatives returns URL on all the mirrors, starting with selected mirror
+func (m *mirrorDownloader) alternatives(url string) []string {
+	mirrors := m.repo.mirrors
+	if len(mirrors) < 2 || !strings.HasPrefix(url, mirrors[0]) {
+		return []string{url}
+	}
+
+	path := strings.TrimPrefix(url, mirrors[0])
+	result := make([]string, len(mirrors))
+	for i := range mirrors {
+		result[i] = mirrors[i] + path
+	}
+
+	return result
+}
+
+func (m *mirrorDownloader) failover(ctx gocontext.Context, url string, download func(url string) error) (err error) {
+	for _, alternative := range m.alternatives(url) {
+		err = download(alternative)
+		if err == nil || errors.Is(ctx.Err(), gocontext.Canceled) {
+			return
+		}
+	}
+
+	return
+}
+
+// Download tries to download url from all the mirrors
+func (m *mirrorDownloader) Download(ctx gocontext.Context, url string, destination string) error {
+	return m.failover(ctx, url, func(url string) error {
+		return m.Downloader.Download(ctx, url, destination)
+	})
+}
+
+// DownloadWithChecksum tries to download url with checksum verification from all the mirrors
+func (m *mirrorDownloader) DownloadWithChecksum(ctx gocontext.Context, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	return m.failover(ctx, url, func(url string) error {
+		return m.Downloader.DownloadWithChecksum(ctx, url, destination, expected, ignoreMismatch)
+	})
+}
+
+// GetLength tries to get length of url from all the mirrors
+func (m *mirrorDownloader) GetLength(ctx gocontext.Context, url string) (length int64, err error) {
+	err = m.failover(ctx, url, func(url string) (e error) {
+		length, e = m.Downloader.GetLength(ctx, url)
+		return
+	})
+	return
+}
+
+// DownloadIfModified tries conditional download from all the mirrors, falling back to
+// regular download if wrapped downloader doesn't support conditional requests
+func (m *mirrorDownloader) DownloadIfModified(ctx gocontext.Context, url string, destination string,
+	validators *aptly.HTTPValidators) (modified bool, err error) {
+	conditional, ok := m.Downloader.(aptly.ConditionalDownloader)
+	if !ok {
+		return true, m.Download(ctx, url, destination)
+	}
+
+	err = m.failover(ctx, url, func(url string) (e error) {
+		modified, e = conditional.DownloadIfModified(ctx, url, destination, validators)
+		return
+	})
+	return
+}
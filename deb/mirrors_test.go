@@ -0,0 +1,90 @@
+package deb
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/http"
+
+	. "gopkg.in/check.v1"
+)
+
+type MirrorsSuite struct{}
+
+var _ = Suite(&MirrorsSuite{})
+
+func (s *MirrorsSuite) TestParseMirrorList(c *C) {
+	mirrors, err := ParseMirrorList(strings.NewReader(`# Debian mirrors
+http://deb.debian.org/debian	priority:1
+
+https://mirror.example.com/debian/	priority:2	arch:amd64
+`))
+	c.Assert(err, IsNil)
+	c.Check(mirrors, DeepEquals, []string{"http://deb.debian.org/debian/", "https://mirror.example.com/debian/"})
+
+	_, err = ParseMirrorList(strings.NewReader("# nothing here\n"))
+	c.Check(err, ErrorMatches, "list of mirrors is empty")
+
+	_, err = ParseMirrorList(strings.NewReader("deb.debian.org/debian\n"))
+	c.Check(err, ErrorMatches, "invalid mirror URL: deb.debian.org/debian/")
+}
+
+func (s *MirrorsSuite) TestSelectMirror(c *C) {
+	list := filepath.Join(c.MkDir(), "mirrors.list")
+	c.Assert(os.WriteFile(list, []byte("http://mirror1.example.com/debian\nhttp://mirror2.example.com/debian/\n"), 0644), IsNil)
+
+	repo, err := NewRemoteRepo("deb", "mirror+file://"+list, "bookworm", []string{"main"}, []string{"amd64"}, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.ArchiveRoot, Equals, "mirror+file://"+list)
+
+	repo.AlternateURLs = []string{"http://mirror3.example.com/debian/", "http://mirror1.example.com/debian/"}
+
+	downloader := http.NewFakeDownloader().
+		ExpectError("http://mirror1.example.com/debian/dists/bookworm/Release", &http.Error{Code: 503}).
+		ExpectResponse("http://mirror2.example.com/debian/dists/bookworm/Release", "Release").
+		ExpectResponse("http://mirror3.example.com/debian/dists/bookworm/Release", "Release")
+
+	c.Assert(repo.SelectMirror(repo.MirrorDownloader(downloader)), IsNil)
+	c.Check(downloader.Empty(), Equals, true)
+	c.Check(repo.Mirrors(), HasLen, 3)
+	c.Check(repo.Mirrors()[2], Equals, "http://mirror1.example.com/debian/")
+	c.Check(repo.PackageURL("pool/main/a/a.deb").String(), Equals, repo.Mirrors()[0]+"pool/main/a/a.deb")
+
+	repo, err = NewRemoteRepo("deb", "mirror+file:///nonexistent/mirrors.list", "bookworm", nil, nil, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.SelectMirror(downloader), ErrorMatches, "unable to load list of mirrors: .*no such file or directory")
+}
+
+func (s *MirrorsSuite) TestMirrorDownloaderFailover(c *C) {
+	repo, err := NewRemoteRepo("deb", "http://mirror1.example.com/debian/", "bookworm", nil, nil, false, false, false)
+	c.Assert(err, IsNil)
+	repo.AlternateURLs = []string{"http://mirror2.example.com/debian/"}
+
+	downloader := http.NewFakeDownloader().
+		ExpectResponse("http://mirror1.example.com/debian/dists/bookworm/Release", "Release").
+		ExpectError("http://mirror2.example.com/debian/dists/bookworm/Release", &http.Error{Code: 404})
+	c.Assert(repo.SelectMirror(downloader), IsNil)
+	c.Check(repo.Mirrors(), DeepEquals, []string{"http://mirror1.example.com/debian/", "http://mirror2.example.com/debian/"})
+
+	d := repo.MirrorDownloader(downloader)
+	destination := filepath.Join(c.MkDir(), "a.deb")
+
+	downloader.ExpectError("http://mirror1.example.com/debian/pool/main/a/a.deb", &http.Error{Code: 502}).
+		ExpectResponse("http://mirror2.example.com/debian/pool/main/a/a.deb", "package")
+	c.Check(d.Download(gocontext.Background(), repo.PackageURL("pool/main/a/a.deb").String(), destination), IsNil)
+	c.Check(downloader.Empty(), Equals, true)
+
+	contents, _ := os.ReadFile(destination)
+	c.Check(string(contents), Equals, "package")
+
+	downloader.ExpectError("http://mirror1.example.com/debian/pool/main/b/b.deb", &http.Error{Code: 404}).
+		ExpectError("http://mirror2.example.com/debian/pool/main/b/b.deb", &http.Error{Code: 404})
+	c.Check(d.Download(gocontext.Background(), repo.PackageURL("pool/main/b/b.deb").String(), destination), ErrorMatches, ".*404.*")
+
+	// URLs outside of the archive are not retried
+	downloader.ExpectError("http://other.example.com/file", &http.Error{Code: 404})
+	c.Check(d.Download(gocontext.Background(), "http://other.example.com/file", destination), NotNil)
+	c.Check(downloader.Empty(), Equals, true)
+}
@@ -0,0 +1,199 @@
+package deb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Override replaces fields of package stanza in published indexes, as apt-ftparchive
+// override files do
+type Override struct {
+	Priority string `codec:",omitempty" json:",omitempty"`
+	Section  string `codec:",omitempty" json:",omitempty"`
+	// Maintainer replaces Maintainer field, if OldMaintainer is set, only matching
+	// Maintainer field is replaced
+	Maintainer    string `codec:",omitempty" json:",omitempty"`
+	OldMaintainer string `codec:",omitempty" json:",omitempty"`
+	// Fields are added to package stanza or replace existing fields, e.g.
+	// Phased-Update-Percentage for staged rollouts
+	Fields map[string]string `codec:",omitempty" json:",omitempty"`
+}
+
+// fields which identify package or its files and can't be overridden
+var overrideProtectedFields = []string{
+	"Package", "Version", "Architecture", "Source", "Filename", "Size", "Directory", "Files",
+	"MD5sum", "SHA1", "SHA256", "SHA512", "Checksums-Sha1", "Checksums-Sha256", "Checksums-Sha512",
+}
+
+// OverrideTable is a set of overrides by package name
+type OverrideTable map[string]Override
+
+// ParseOverrides reads override file in apt-ftparchive format:
+//
+//	package priority section [maintainer]
+//	package section
+//
+//	package Field: value
+//
+// Second form is format of source override files, which are kept in separate
+// table, as source and binary packages could share the same name. Maintainer could be given as
+// `old => new` to replace only matching maintainer. Third form sets arbitrary field
+// of package stanza (e.g. Phased-Update-Percentage), package could have several
+// such lines in addition to priority and section override. Empty lines and lines
+// starting with # are ignored.
+func ParseOverrides(r io.Reader) (OverrideTable, error) {
+	result := OverrideTable{}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) > 1 && strings.HasSuffix(fields[1], ":") {
+			override := result[fields[0]]
+			field := canonicalCase(strings.TrimSuffix(fields[1], ":"))
+			value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(fields[0]):]), fields[1]))
+			if field == "" || value == "" {
+				return nil, fmt.Errorf("line %d: field override should contain field name and value", lineNo)
+			}
+
+			if override.Fields == nil {
+				override.Fields = map[string]string{}
+			}
+			override.Fields[field] = value
+			result[fields[0]] = override
+			continue
+		}
+
+		// field overrides are kept, everything else is replaced by the last line
+		override := Override{Fields: result[fields[0]].Fields}
+
+		switch len(fields) {
+		case 1:
+			return nil, fmt.Errorf("line %d: override should contain at least package name and section", lineNo)
+		case 2:
+			override.Section = fields[1]
+		default:
+			override.Priority = fields[1]
+			override.Section = fields[2]
+
+			if len(fields) > 3 {
+				// maintainer is the rest of the line
+				maintainer := line
+				for i := 0; i < 3; i++ {
+					maintainer = strings.TrimSpace(maintainer)
+					maintainer = maintainer[strings.IndexAny(maintainer, " \t"):]
+				}
+				maintainer = strings.TrimSpace(maintainer)
+
+				if parts := strings.SplitN(maintainer, "=>", 2); len(parts) == 2 {
+					override.OldMaintainer = strings.TrimSpace(parts[0])
+					override.Maintainer = strings.TrimSpace(parts[1])
+				} else {
+					override.Maintainer = maintainer
+				}
+			}
+		}
+
+		result[fields[0]] = override
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read overrides: %s", err)
+	}
+
+	return result, nil
+}
+
+// Validate checks that overrides could be applied to package stanzas
+func (table OverrideTable) Validate() error {
+	for name, override := range table {
+		if name == "" || strings.ContainsAny(name, " \t\n") {
+			return fmt.Errorf("invalid package name in override: %q", name)
+		}
+
+		for _, value := range []string{override.Priority, override.Section, override.Maintainer, override.OldMaintainer} {
+			if strings.ContainsAny(value, "\n") {
+				return fmt.Errorf("override for package %s should contain single line values", name)
+			}
+		}
+
+		for field, value := range override.Fields {
+			if field == "" || strings.ContainsAny(field, " \t\n:") {
+				return fmt.Errorf("override for package %s has invalid field name: %q", name, field)
+			}
+
+			for _, protected := range overrideProtectedFields {
+				if strings.EqualFold(field, protected) {
+					return fmt.Errorf("override for package %s can't change field %s", name, field)
+				}
+			}
+
+			if value == "" || strings.ContainsAny(value, "\n") {
+				return fmt.Errorf("override for package %s should contain single line values", name)
+			}
+
+			if strings.EqualFold(field, "Phased-Update-Percentage") {
+				percentage, err := strconv.Atoi(value)
+				if err != nil || percentage < 0 || percentage > 100 {
+					return fmt.Errorf("override for package %s: Phased-Update-Percentage should be a number from 0 to 100", name)
+				}
+			}
+		}
+
+		if override.Section == "" && override.Priority == "" && override.Maintainer == "" && len(override.Fields) == 0 {
+			return fmt.Errorf("override for package %s doesn't override anything", name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateSource checks that overrides could be applied to source package stanzas
+//
+// Source overrides are kept in separate table, as apt-ftparchive source override
+// files, and can't set arbitrary fields.
+func (table OverrideTable) ValidateSource() error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+
+	for name, override := range table {
+		if len(override.Fields) > 0 {
+			return fmt.Errorf("source override for package %s can't set fields", name)
+		}
+	}
+
+	return nil
+}
+
+// Apply overrides fields of package stanza, stanza is modified in place
+func (table OverrideTable) Apply(name string, stanza Stanza) {
+	override, ok := table[name]
+	if !ok {
+		return
+	}
+
+	if override.Priority != "" {
+		stanza["Priority"] = override.Priority
+	}
+	if override.Section != "" {
+		stanza["Section"] = override.Section
+	}
+	if override.Maintainer != "" && (override.OldMaintainer == "" || override.OldMaintainer == stanza["Maintainer"]) {
+		stanza["Maintainer"] = override.Maintainer
+	}
+	for field, value := range override.Fields {
+		stanza[canonicalCase(field)] = value
+	}
+}
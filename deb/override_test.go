@@ -0,0 +1,106 @@
+package deb
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type OverrideSuite struct{}
+
+var _ = Suite(&OverrideSuite{})
+
+func (s *OverrideSuite) TestParseOverrides(c *C) {
+	overrides, err := ParseOverrides(strings.NewReader(`# override.bookworm.main
+nginx optional httpd
+libfoo1 important libs Jane Doe <jane@example.com>
+bar extra utils  Old Maintainer <old@example.com> => New Maintainer <new@example.com>
+
+hello-src devel
+`))
+	c.Assert(err, IsNil)
+	c.Check(overrides, DeepEquals, OverrideTable{
+		"nginx":     {Priority: "optional", Section: "httpd"},
+		"libfoo1":   {Priority: "important", Section: "libs", Maintainer: "Jane Doe <jane@example.com>"},
+		"bar":       {Priority: "extra", Section: "utils", Maintainer: "New Maintainer <new@example.com>", OldMaintainer: "Old Maintainer <old@example.com>"},
+		"hello-src": {Section: "devel"},
+	})
+	c.Check(overrides.Validate(), IsNil)
+
+	_, err = ParseOverrides(strings.NewReader("nginx optional httpd\nbroken\n"))
+	c.Check(err, ErrorMatches, "line 2: override should contain at least package name and section")
+}
+
+func (s *OverrideSuite) TestParseFieldOverrides(c *C) {
+	overrides, err := ParseOverrides(strings.NewReader(`nginx Phased-Update-Percentage: 10
+nginx optional httpd
+nginx tag: role::program, interface::daemon
+hello phased-update-percentage: 50
+`))
+	c.Assert(err, IsNil)
+	c.Check(overrides, DeepEquals, OverrideTable{
+		"nginx": {Priority: "optional", Section: "httpd", Fields: map[string]string{
+			"Phased-Update-Percentage": "10",
+			"Tag":                      "role::program, interface::daemon",
+		}},
+		"hello": {Fields: map[string]string{"Phased-Update-Percentage": "50"}},
+	})
+	c.Check(overrides.Validate(), IsNil)
+
+	_, err = ParseOverrides(strings.NewReader("nginx Tag:\n"))
+	c.Check(err, ErrorMatches, "line 1: field override should contain field name and value")
+}
+
+func (s *OverrideSuite) TestApply(c *C) {
+	overrides := OverrideTable{
+		"nginx": {Priority: "optional", Section: "httpd"},
+		"bar":   {Section: "utils", Maintainer: "New <new@example.com>", OldMaintainer: "Old <old@example.com>"},
+	}
+
+	stanza := Stanza{"Package": "nginx", "Priority": "extra", "Section": "web", "Maintainer": "Someone"}
+	overrides.Apply("nginx", stanza)
+	c.Check(stanza, DeepEquals, Stanza{"Package": "nginx", "Priority": "optional", "Section": "httpd", "Maintainer": "Someone"})
+
+	stanza = Stanza{"Package": "bar", "Priority": "extra", "Section": "misc", "Maintainer": "Someone"}
+	overrides.Apply("bar", stanza)
+	c.Check(stanza, DeepEquals, Stanza{"Package": "bar", "Priority": "extra", "Section": "utils", "Maintainer": "Someone"})
+
+	stanza["Maintainer"] = "Old <old@example.com>"
+	overrides.Apply("bar", stanza)
+	c.Check(stanza["Maintainer"], Equals, "New <new@example.com>")
+
+	stanza = Stanza{"Package": "baz", "Section": "misc"}
+	overrides.Apply("baz", stanza)
+	c.Check(stanza, DeepEquals, Stanza{"Package": "baz", "Section": "misc"})
+
+	OverrideTable(nil).Apply("baz", stanza)
+	c.Check(stanza, DeepEquals, Stanza{"Package": "baz", "Section": "misc"})
+
+	overrides = OverrideTable{
+		"hello": {Fields: map[string]string{"Phased-Update-Percentage": "20", "tag": "role::program"}},
+	}
+	stanza = Stanza{"Package": "hello", "Section": "misc", "Tag": "role::shared-lib"}
+	overrides.Apply("hello", stanza)
+	c.Check(stanza, DeepEquals, Stanza{"Package": "hello", "Section": "misc", "Tag": "role::program", "Phased-Update-Percentage": "20"})
+}
+
+func (s *OverrideSuite) TestValidate(c *C) {
+	c.Check(OverrideTable(nil).Validate(), IsNil)
+	c.Check(OverrideTable{"a b": {Section: "utils"}}.Validate(), ErrorMatches, "invalid package name in override: \"a b\"")
+	c.Check(OverrideTable{"a": {Section: "utils\nmore"}}.Validate(), ErrorMatches, "override for package a should contain single line values")
+	c.Check(OverrideTable{"a": {}}.Validate(), ErrorMatches, "override for package a doesn't override anything")
+	c.Check(OverrideTable{"a": {Fields: map[string]string{"Tag": "role::program"}}}.Validate(), IsNil)
+	c.Check(OverrideTable{"a": {Fields: map[string]string{"Bad Field": "x"}}}.Validate(), ErrorMatches, "override for package a has invalid field name: \"Bad Field\"")
+	c.Check(OverrideTable{"a": {Fields: map[string]string{"version": "2.0"}}}.Validate(), ErrorMatches, "override for package a can't change field version")
+	c.Check(OverrideTable{"a": {Fields: map[string]string{"Tag": ""}}}.Validate(), ErrorMatches, "override for package a should contain single line values")
+	c.Check(OverrideTable{"a": {Fields: map[string]string{"Phased-Update-Percentage": "110"}}}.Validate(), ErrorMatches,
+		"override for package a: Phased-Update-Percentage should be a number from 0 to 100")
+}
+
+func (s *OverrideSuite) TestValidateSource(c *C) {
+	c.Check(OverrideTable(nil).ValidateSource(), IsNil)
+	c.Check(OverrideTable{"a": {Section: "utils"}}.ValidateSource(), IsNil)
+	c.Check(OverrideTable{"a": {}}.ValidateSource(), ErrorMatches, "override for package a doesn't override anything")
+	c.Check(OverrideTable{"a": {Section: "utils", Fields: map[string]string{"Tag": "role::program"}}}.ValidateSource(), ErrorMatches,
+		"source override for package a can't set fields")
+}
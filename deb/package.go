@@ -40,10 +40,29 @@ type Package struct {
 	extra    *Stanza
 	files    *PackageFiles
 	contents []string
+	// Offload fields which are not kept in memory once loaded
+	dropFields PackageFields
 	// Mother collection
 	collection *PackageCollection
 }
 
+// PackageFields selects offloaded parts of the package kept in memory once loaded
+// from the database, basic properties (name, version, architecture, source,
+// provides) are always available
+type PackageFields int
+
+// Offloaded parts of the package
+const (
+	PackageFieldsDependencies PackageFields = 1 << iota
+	PackageFieldsFiles
+	PackageFieldsExtra
+
+	// PackageFieldsBasic keeps only basic properties, everything else is loaded on each access
+	PackageFieldsBasic PackageFields = 0
+	// PackageFieldsAll keeps all the parts of the package once loaded
+	PackageFieldsAll = PackageFieldsDependencies | PackageFieldsFiles | PackageFieldsExtra
+)
+
 // Package types
 const (
 	PackageTypeBinary    = "deb"
@@ -57,6 +76,8 @@ const (
 	ArchitectureAll    = "all"
 	ArchitectureAny    = "any"
 	ArchitectureSource = "source"
+	// ArchitectureNative is architecture of the system aptly is running on
+	ArchitectureNative = "native"
 )
 
 // Check interface
@@ -164,6 +185,26 @@ func NewSourcePackageFromControlFile(input Stanza) (*Package, error) {
 	return result, nil
 }
 
+// NewPackageFromStanza creates Package from stanza in the format of Packages or Sources
+// index, source packages are recognized by Files field in place of Filename
+func NewPackageFromStanza(input Stanza) (*Package, error) {
+	for _, field := range []string{"Package", "Version", "Architecture"} {
+		if input[field] == "" {
+			return nil, fmt.Errorf("stanza is missing %s field", field)
+		}
+	}
+
+	if _, ok := input["Filename"]; !ok {
+		if _, ok = input["Files"]; ok {
+			return NewSourcePackageFromControlFile(input)
+		}
+
+		return nil, fmt.Errorf("stanza of %s_%s should contain either Filename or Files field", input["Package"], input["Version"])
+	}
+
+	return NewPackageFromControlFile(input), nil
+}
+
 // NewUdebPackageFromControlFile creates .udeb Package from parsed Debian control file
 func NewUdebPackageFromControlFile(input Stanza) *Package {
 	p := NewPackageFromControlFile(input)
@@ -272,6 +313,15 @@ func (p *Package) GetField(name string) string {
 			}
 		}
 		return p.Version
+	case "$Installed-Size":
+		// Installed-Size is in kilobytes, value is converted to bytes to be compared as $Size
+		installedSize, err := strconv.ParseInt(strings.TrimSpace(p.Extra()["Installed-Size"]), 10, 64)
+		if err != nil {
+			return ""
+		}
+		return strconv.FormatInt(installedSize*1024, 10)
+	case "$Depends-Count":
+		return strconv.Itoa(len(p.Deps().Depends))
 	case "$Architecture":
 		return p.Architecture
 	case "$PackageType":
@@ -308,17 +358,29 @@ func (p *Package) GetField(name string) string {
 	case "Build-Depends-Indep":
 		return strings.Join(p.Deps().BuildDependsInDep, ", ")
 	default:
-		return p.Extra()[name]
+		extra := p.Extra()
+		if value, ok := extra[name]; ok {
+			return value
+		}
+
+		// names of fields unknown to aptly are preserved as is, while field names are case-insensitive
+		for field, value := range extra {
+			if strings.EqualFold(field, name) {
+				return value
+			}
+		}
+		return ""
 	}
 }
 
-// MatchesArchitecture checks whether packages matches specified architecture
+// MatchesArchitecture checks whether packages matches specified architecture,
+// architecture could be a wildcard (see ArchitectureMatches)
 func (p *Package) MatchesArchitecture(arch string) bool {
 	if p.Architecture == ArchitectureAll && arch != ArchitectureSource {
 		return true
 	}
 
-	return p.Architecture == arch
+	return ArchitectureMatches(arch, p.Architecture)
 }
 
 // MatchesDependency checks whether package matches specified dependency
@@ -351,11 +413,8 @@ func (p *Package) MatchesDependency(dep Dependency) bool {
 		return r <= 0
 	case VersionGreaterOrEqual:
 		return r >= 0
-	case VersionPatternMatch:
-		matched, err := filepath.Match(dep.Version, p.Version)
-		return err == nil && matched
-	case VersionRegexp:
-		return dep.Regexp.FindStringIndex(p.Version) != nil
+	case VersionPatternMatch, VersionRegexp, VersionContains:
+		return matchPattern(dep.Regexp, dep.Relation, dep.Version, dep.CaseInsensitive, p.Version)
 	}
 
 	panic("unknown relation")
@@ -433,12 +492,29 @@ func (p *Package) Extra() Stanza {
 		if p.collection == nil {
 			panic("extra == nil && collection == nil")
 		}
-		p.extra = p.collection.loadExtra(p)
+
+		extra := p.collection.loadExtra(p)
+		if p.dropFields&PackageFieldsExtra != 0 {
+			return *extra
+		}
+		p.extra = extra
 	}
 
 	return *p.extra
 }
 
+// Modes of handling source packages marked with Extra-Source-Only: yes when snapshots are merged
+const (
+	ExtraSourceOnlyRetain = "retain"
+	ExtraSourceOnlyDrop   = "drop"
+)
+
+// IsExtraSourceOnly checks whether source package is marked with Extra-Source-Only: yes, such
+// stanzas are listed in archive only because other packages are built using them
+func (p *Package) IsExtraSourceOnly() bool {
+	return p.IsSource && strings.EqualFold(p.Extra()["Extra-Source-Only"], "yes")
+}
+
 // Deps returns parsed package dependencies (it may load it from collection)
 func (p *Package) Deps() *PackageDependencies {
 	if p.deps == nil {
@@ -446,7 +522,11 @@ func (p *Package) Deps() *PackageDependencies {
 			panic("deps == nil && collection == nil")
 		}
 
-		p.deps = p.collection.loadDependencies(p)
+		deps := p.collection.loadDependencies(p)
+		if p.dropFields&PackageFieldsDependencies != 0 {
+			return deps
+		}
+		p.deps = deps
 	}
 
 	return p.deps
@@ -459,7 +539,11 @@ func (p *Package) Files() PackageFiles {
 			panic("files == nil && collection == nil")
 		}
 
-		p.files = p.collection.loadFiles(p)
+		files := p.collection.loadFiles(p)
+		if p.dropFields&PackageFieldsFiles != 0 {
+			return *files
+		}
+		p.files = files
 	}
 
 	return *p.files
@@ -509,6 +593,54 @@ func (p *Package) CalculateContents(packagePool aptly.PackagePool, progress aptl
 	return contents, nil
 }
 
+// Inspect opens package file from the pool and parses it
+func (p *Package) Inspect(packagePool aptly.PackagePool) (*DebInspection, error) {
+	if p.IsSource {
+		return nil, fmt.Errorf("unable to inspect source package %s", p)
+	}
+
+	file := p.Files()[0]
+	poolPath, err := file.GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return InspectDeb(reader, file.Filename)
+}
+
+// Changelog returns changelog of the package, extracting it from the package file
+// in the pool if it hasn't been extracted yet
+func (p *Package) Changelog(packagePool aptly.PackagePool) (*PackageChangelog, error) {
+	if p.IsSource {
+		return nil, fmt.Errorf("unable to get changelog of source package %s", p)
+	}
+
+	return p.collection.loadChangelog(p, packagePool)
+}
+
+// ExtractChangelog opens package file from the pool and extracts changelog
+func (p *Package) ExtractChangelog(packagePool aptly.PackagePool) (*PackageChangelog, error) {
+	file := p.Files()[0]
+	poolPath, err := file.GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return GetChangelogFromDeb(reader, file.Filename, p.Name)
+}
+
 // UpdateFiles saves new state of files
 func (p *Package) UpdateFiles(files PackageFiles) {
 	p.files = &files
@@ -617,18 +749,30 @@ func (p *Package) Equals(p2 *Package) bool {
 }
 
 // LinkFromPool links package file from pool to dist's pool location
+//
+// If linked is not nil, it records files linked so far, and files which have been
+// already linked with the same checksum are not linked again (e.g. files shared by
+// several source packages or packages published to several architectures).
 func (p *Package) LinkFromPool(publishedStorage aptly.PublishedStorage, packagePool aptly.PackagePool,
-	prefix, relPath string, force bool) error {
+	prefix, relPath string, force bool, linked map[string]bool) error {
 
 	for i, f := range p.Files() {
-		sourcePoolPath, err := f.GetPoolPath(packagePool)
-		if err != nil {
-			return err
-		}
+		key := filepath.Join(prefix, relPath, f.Filename) + " " + f.Checksums.MD5 + " " + f.Checksums.SHA256
 
-		err = publishedStorage.LinkFromPool(prefix, relPath, f.Filename, packagePool, sourcePoolPath, f.Checksums, force)
-		if err != nil {
-			return err
+		if linked == nil || !linked[key] {
+			sourcePoolPath, err := f.GetPoolPath(packagePool)
+			if err != nil {
+				return err
+			}
+
+			err = publishedStorage.LinkFromPool(prefix, relPath, f.Filename, packagePool, sourcePoolPath, f.Checksums, force)
+			if err != nil {
+				return err
+			}
+
+			if linked != nil {
+				linked[key] = true
+			}
 		}
 
 		if p.IsSource {
@@ -668,9 +812,12 @@ func (p *Package) PoolDirectory() (string, error) {
 // PackageDownloadTask is a element of download queue for the package
 type PackageDownloadTask struct {
 	File         *PackageFile
+	Package      *Package
 	Additional   []PackageDownloadTask
 	TempDownPath string
 	Done         bool
+	// Quarantined is set if downloaded file failed verification and was moved to quarantine
+	Quarantined bool
 }
 
 // DownloadList returns list of missing package files for download in format
@@ -686,7 +833,7 @@ func (p *Package) DownloadList(packagePool aptly.PackagePool, checksumStorage ap
 		}
 
 		if !verified {
-			result = append(result, PackageDownloadTask{File: &files[idx]})
+			result = append(result, PackageDownloadTask{File: &files[idx], Package: p})
 		}
 	}
 
@@ -0,0 +1,210 @@
+package deb
+
+import (
+	"container/list"
+	"sync"
+)
+
+// PackageCache is LRU cache of packages loaded from the database
+//
+// Cache is shared by package collections (it outlives collection factories), so that
+// operations which load the same packages over and over again (e.g. diff, verify and
+// publish of the same snapshot) reuse decoded packages. Packages are stored by key,
+// and as package key includes hash of package files, cached packages don't go stale;
+// cache entry is dropped anyway once update or deletion of the package is written.
+//
+// Packages returned from the cache are copies, so they could be modified safely.
+type PackageCache struct {
+	sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits, misses int64
+}
+
+type packageCacheEntry struct {
+	key   string
+	pkg   Package
+	extra *Stanza
+	deps  *PackageDependencies
+	files *PackageFiles
+}
+
+// PackageCacheStats is a summary of cache usage
+type PackageCacheStats struct {
+	Size    int
+	Entries int
+	Hits    int64
+	Misses  int64
+}
+
+// NewPackageCache creates cache which keeps up to size packages
+func NewPackageCache(size int) *PackageCache {
+	return &PackageCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// entry looks up cache entry and marks it as recently used, should be called with lock held
+func (cache *PackageCache) entry(key []byte) *packageCacheEntry {
+	elem, ok := cache.entries[string(key)]
+	if !ok {
+		return nil
+	}
+
+	cache.lru.MoveToFront(elem)
+	return elem.Value.(*packageCacheEntry)
+}
+
+// get returns copy of cached package without offloaded fields
+func (cache *PackageCache) get(key []byte) (*Package, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry := cache.entry(key)
+	if entry == nil {
+		cache.misses++
+		return nil, false
+	}
+
+	cache.hits++
+	p := entry.pkg
+	p.Provides = append([]string(nil), p.Provides...)
+	return &p, true
+}
+
+// put adds package to the cache, evicting least recently used packages
+func (cache *PackageCache) put(key []byte, p *Package) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if elem, ok := cache.entries[string(key)]; ok {
+		cache.lru.Remove(elem)
+	}
+
+	entry := &packageCacheEntry{key: string(key), pkg: *p}
+	entry.pkg.Provides = append([]string(nil), p.Provides...)
+	entry.pkg.extra, entry.pkg.deps, entry.pkg.files, entry.pkg.contents = nil, nil, nil, nil
+	entry.pkg.collection = nil
+	entry.pkg.dropFields = 0
+
+	cache.entries[entry.key] = cache.lru.PushFront(entry)
+
+	for cache.lru.Len() > cache.size {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*packageCacheEntry).key)
+	}
+}
+
+// extra returns copy of cached extra fields of the package, if any
+func (cache *PackageCache) extra(key []byte) *Stanza {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry := cache.entry(key)
+	if entry == nil || entry.extra == nil {
+		return nil
+	}
+
+	extra := entry.extra.Copy()
+	return &extra
+}
+
+// setExtra caches extra fields of the package which is already in the cache
+func (cache *PackageCache) setExtra(key []byte, extra *Stanza) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if entry := cache.entry(key); entry != nil {
+		stanza := extra.Copy()
+		entry.extra = &stanza
+	}
+}
+
+// dependencies returns copy of cached dependencies of the package, if any
+func (cache *PackageCache) dependencies(key []byte) *PackageDependencies {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry := cache.entry(key)
+	if entry == nil || entry.deps == nil {
+		return nil
+	}
+
+	return copyDependencies(entry.deps)
+}
+
+// setDependencies caches dependencies of the package which is already in the cache
+func (cache *PackageCache) setDependencies(key []byte, deps *PackageDependencies) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if entry := cache.entry(key); entry != nil {
+		entry.deps = copyDependencies(deps)
+	}
+}
+
+// copyDependencies returns deep copy of dependencies, so that cached copy doesn't share slices
+func copyDependencies(deps *PackageDependencies) *PackageDependencies {
+	return &PackageDependencies{
+		Depends:           append([]string(nil), deps.Depends...),
+		BuildDepends:      append([]string(nil), deps.BuildDepends...),
+		BuildDependsInDep: append([]string(nil), deps.BuildDependsInDep...),
+		PreDepends:        append([]string(nil), deps.PreDepends...),
+		Suggests:          append([]string(nil), deps.Suggests...),
+		Recommends:        append([]string(nil), deps.Recommends...),
+	}
+}
+
+// files returns copy of cached files of the package, if any
+func (cache *PackageCache) files(key []byte) *PackageFiles {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry := cache.entry(key)
+	if entry == nil || entry.files == nil {
+		return nil
+	}
+
+	files := append(PackageFiles(nil), *entry.files...)
+	return &files
+}
+
+// setFiles caches files of the package which is already in the cache
+func (cache *PackageCache) setFiles(key []byte, files *PackageFiles) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if entry := cache.entry(key); entry != nil {
+		filesCopy := append(PackageFiles(nil), *files...)
+		entry.files = &filesCopy
+	}
+}
+
+// Invalidate drops package from the cache
+func (cache *PackageCache) Invalidate(key []byte) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if elem, ok := cache.entries[string(key)]; ok {
+		cache.lru.Remove(elem)
+		delete(cache.entries, string(key))
+	}
+}
+
+// Stats returns cache usage summary
+func (cache *PackageCache) Stats() PackageCacheStats {
+	cache.Lock()
+	defer cache.Unlock()
+
+	return PackageCacheStats{
+		Size:    cache.size,
+		Entries: cache.lru.Len(),
+		Hits:    cache.hits,
+		Misses:  cache.misses,
+	}
+}
@@ -0,0 +1,151 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type PackageCacheSuite struct {
+	cache      *PackageCache
+	collection *PackageCollection
+	p          *Package
+	db         database.Storage
+}
+
+var _ = Suite(&PackageCacheSuite{})
+
+func (s *PackageCacheSuite) SetUpTest(c *C) {
+	s.p = NewPackageFromControlFile(packageStanza.Copy())
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.cache = NewPackageCache(2)
+
+	factory := NewCollectionFactory(s.db)
+	factory.SetPackageCache(s.cache)
+	s.collection = factory.PackageCollection()
+}
+
+func (s *PackageCacheSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *PackageCacheSuite) TestByKey(c *C) {
+	c.Assert(s.collection.Update(s.p), IsNil)
+
+	p1, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p1.Extra()["Priority"], Equals, "extra")
+	c.Check(p1.Files()[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
+	c.Check(p1.GetDependencies(0), DeepEquals, []string{"libc6 (>= 2.7)", "alien-arena-data (>= 7.40)", "dpkg (>= 1.6)"})
+	c.Check(s.cache.Stats(), DeepEquals, PackageCacheStats{Size: 2, Entries: 1, Hits: 0, Misses: 1})
+
+	// packages are served from the cache, and are independent copies
+	p1.Extra()["Priority"] = "important"
+	p1.Files()[0].Filename = "renamed.deb"
+
+	p2, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p2.Equals(s.p), Equals, true)
+	c.Check(p2.collection, Equals, s.collection)
+	c.Check(p2.Extra()["Priority"], Equals, "extra")
+	c.Check(p2.Files()[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
+	c.Check(p2.GetDependencies(0), DeepEquals, []string{"libc6 (>= 2.7)", "alien-arena-data (>= 7.40)", "dpkg (>= 1.6)"})
+	c.Check(s.cache.Stats().Hits, Equals, int64(1))
+
+	// cached packages are dropped on update
+	p2.Source = "lala"
+	c.Assert(s.collection.Update(p2), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 0)
+
+	p3, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p3.Source, Equals, "lala")
+
+	// ... and on delete
+	c.Assert(s.collection.DeleteByKey(s.p.Key(""), s.db), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 0)
+
+	_, err = s.collection.ByKey(s.p.Key(""))
+	c.Check(err, ErrorMatches, "key not found")
+}
+
+func (s *PackageCacheSuite) TestInvalidateAfterWrite(c *C) {
+	c.Assert(s.collection.Update(s.p), IsNil)
+	_, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 1)
+
+	// package is dropped from the cache only once batch is written
+	batch := newPackageBatch(s.db)
+	c.Assert(s.collection.UpdateInTransaction(s.p, batch), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 1)
+	c.Assert(batch.Write(), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 0)
+
+	_, err = s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+
+	// with transaction, package is dropped by the caller after commit
+	transaction, err := s.db.OpenTransaction()
+	c.Assert(err, IsNil)
+	c.Assert(s.collection.UpdateInTransaction(s.p, transaction), IsNil)
+	transaction.Discard()
+	c.Check(s.cache.Stats().Entries, Equals, 1)
+
+	batch = newPackageBatch(s.db)
+	c.Assert(s.collection.DeleteByKey(s.p.Key(""), batch), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 1)
+	c.Assert(batch.Write(), IsNil)
+	c.Check(s.cache.Stats().Entries, Equals, 0)
+}
+
+func (s *PackageCacheSuite) TestDependenciesAreCopied(c *C) {
+	c.Assert(s.collection.Update(s.p), IsNil)
+
+	p1, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	p1.Deps().Depends[0] = "libc7"
+	p1.Provides = append(p1.Provides, "lala")
+
+	p2, err := s.collection.ByKey(s.p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p2.Deps().Depends, DeepEquals, []string{"libc6 (>= 2.7)", "alien-arena-data (>= 7.40)"})
+	c.Check(p2.Provides, HasLen, 0)
+}
+
+func (s *PackageCacheSuite) TestEviction(c *C) {
+	p1 := &Package{Name: "a", Version: "1", Architecture: "i386"}
+	p2 := &Package{Name: "b", Version: "1", Architecture: "i386"}
+	p3 := &Package{Name: "c", Version: "1", Architecture: "i386"}
+
+	s.cache.put(p1.Key(""), p1)
+	s.cache.put(p2.Key(""), p2)
+
+	// p1 is used recently, so p2 is evicted
+	_, ok := s.cache.get(p1.Key(""))
+	c.Check(ok, Equals, true)
+	s.cache.put(p3.Key(""), p3)
+
+	_, ok = s.cache.get(p2.Key(""))
+	c.Check(ok, Equals, false)
+
+	cached, ok := s.cache.get(p1.Key(""))
+	c.Check(ok, Equals, true)
+	c.Check(cached.Name, Equals, "a")
+
+	_, ok = s.cache.get(p3.Key(""))
+	c.Check(ok, Equals, true)
+	c.Check(s.cache.Stats().Entries, Equals, 2)
+
+	// offloaded fields are cached only along with the package
+	extra := Stanza{"Priority": "optional"}
+	s.cache.setExtra(p2.Key(""), &extra)
+	c.Check(s.cache.extra(p2.Key("")), IsNil)
+
+	s.cache.setExtra(p1.Key(""), &extra)
+	c.Check(*s.cache.extra(p1.Key("")), DeepEquals, extra)
+
+	s.cache.Invalidate(p1.Key(""))
+	c.Check(s.cache.extra(p1.Key("")), IsNil)
+}
@@ -14,6 +14,7 @@ import (
 type PackageCollection struct {
 	db          database.Storage
 	codecHandle *codec.MsgpackHandle
+	cache       *PackageCache
 }
 
 // Verify interface
@@ -51,6 +52,13 @@ type oldPackage struct {
 
 // ByKey find package in DB by its key
 func (collection *PackageCollection) ByKey(key []byte) (*Package, error) {
+	if collection.cache != nil {
+		if p, ok := collection.cache.get(key); ok {
+			p.collection = collection
+			return p, nil
+		}
+	}
+
 	encoded, err := collection.db.Get(key)
 	if err != nil {
 		return nil, err
@@ -101,6 +109,10 @@ func (collection *PackageCollection) ByKey(key []byte) (*Package, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		if collection.cache != nil {
+			collection.cache.put(key, p)
+		}
 	}
 
 	p.collection = collection
@@ -108,8 +120,29 @@ func (collection *PackageCollection) ByKey(key []byte) (*Package, error) {
 	return p, nil
 }
 
+// ByKeyWithFields finds package in DB by its key, only selected offloaded parts of the
+// package are kept in memory once loaded, other parts are re-loaded on every access
+//
+// This cuts memory usage for operations which touch only some of the package fields
+// (e.g. dependency resolution needs only dependencies) on large lists of packages
+func (collection *PackageCollection) ByKeyWithFields(key []byte, fields PackageFields) (*Package, error) {
+	p, err := collection.ByKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	p.dropFields = PackageFieldsAll &^ fields
+	return p, nil
+}
+
 // loadExtra loads Stanza with all the xtra information about the package
 func (collection *PackageCollection) loadExtra(p *Package) *Stanza {
+	if collection.cache != nil {
+		if stanza := collection.cache.extra(p.Key("")); stanza != nil {
+			return stanza
+		}
+	}
+
 	encoded, err := collection.db.Get(p.Key("xE"))
 	if err != nil {
 		panic("unable to load extra")
@@ -123,11 +156,21 @@ func (collection *PackageCollection) loadExtra(p *Package) *Stanza {
 		panic("unable to decode extra")
 	}
 
+	if collection.cache != nil {
+		collection.cache.setExtra(p.Key(""), stanza)
+	}
+
 	return stanza
 }
 
 // loadDependencies loads dependencies for the package
 func (collection *PackageCollection) loadDependencies(p *Package) *PackageDependencies {
+	if collection.cache != nil {
+		if deps := collection.cache.dependencies(p.Key("")); deps != nil {
+			return deps
+		}
+	}
+
 	encoded, err := collection.db.Get(p.Key("xD"))
 	if err != nil {
 		panic(fmt.Sprintf("unable to load deps: %s, %s", p, err))
@@ -141,11 +184,21 @@ func (collection *PackageCollection) loadDependencies(p *Package) *PackageDepend
 		panic("unable to decode deps")
 	}
 
+	if collection.cache != nil {
+		collection.cache.setDependencies(p.Key(""), deps)
+	}
+
 	return deps
 }
 
 // loadFiles loads additional PackageFiles record
 func (collection *PackageCollection) loadFiles(p *Package) *PackageFiles {
+	if collection.cache != nil {
+		if files := collection.cache.files(p.Key("")); files != nil {
+			return files
+		}
+	}
+
 	encoded, err := collection.db.Get(p.Key("xF"))
 	if err != nil {
 		panic("unable to load files")
@@ -159,6 +212,10 @@ func (collection *PackageCollection) loadFiles(p *Package) *PackageFiles {
 		panic("unable to decode files")
 	}
 
+	if collection.cache != nil {
+		collection.cache.setFiles(p.Key(""), files)
+	}
+
 	return files
 }
 
@@ -201,6 +258,44 @@ func (collection *PackageCollection) loadContents(p *Package, packagePool aptly.
 	return contents
 }
 
+// loadChangelog loads or extracts and saves package changelog
+func (collection *PackageCollection) loadChangelog(p *Package, packagePool aptly.PackagePool) (*PackageChangelog, error) {
+	encoded, err := collection.db.Get(p.Key("xL"))
+	if err == nil {
+		changelog := &PackageChangelog{}
+
+		decoder := codec.NewDecoderBytes(encoded, collection.codecHandle)
+		err = decoder.Decode(changelog)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode changelog: %s", err)
+		}
+
+		return changelog, nil
+	}
+
+	if err != database.ErrNotFound {
+		return nil, err
+	}
+
+	changelog, err := p.ExtractChangelog(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	return changelog, collection.UpdateChangelog(p, changelog)
+}
+
+// UpdateChangelog saves changelog of the package
+func (collection *PackageCollection) UpdateChangelog(p *Package, changelog *PackageChangelog) error {
+	var buf bytes.Buffer
+	err := codec.NewEncoder(&buf, collection.codecHandle).Encode(changelog)
+	if err != nil {
+		return err
+	}
+
+	return collection.db.Put(p.Key("xL"), buf.Bytes())
+}
+
 // Update adds or updates information about package in DB
 func (collection *PackageCollection) Update(p *Package) error {
 	transaction, err := collection.db.OpenTransaction()
@@ -213,11 +308,20 @@ func (collection *PackageCollection) Update(p *Package) error {
 		return err
 	}
 
-	return transaction.Commit()
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
+
+	collection.invalidate(p.Key(""))
+	return nil
 }
 
 // UpdateInTransaction updates/creates package info in the context of the outer transaction
-func (collection *PackageCollection) UpdateInTransaction(p *Package, transaction database.Transaction) error {
+// (or batch)
+//
+// Package is dropped from the cache once change is written, with transactions it's up to
+// the caller to invalidate it after commit (see Update).
+func (collection *PackageCollection) UpdateInTransaction(p *Package, transaction database.Writer) error {
 	var encodeBuffer bytes.Buffer
 
 	encoder := codec.NewEncoder(&encodeBuffer, collection.codecHandle)
@@ -234,6 +338,8 @@ func (collection *PackageCollection) UpdateInTransaction(p *Package, transaction
 		return err
 	}
 
+	collection.invalidateAfterWrite(transaction, p.Key(""))
+
 	// Encode offloaded fields one by one
 	if p.files != nil {
 		encodeBuffer.Reset()
@@ -287,17 +393,115 @@ func (collection *PackageCollection) AllPackageRefs() *PackageRefList {
 	return &PackageRefList{Refs: collection.db.KeysByPrefix([]byte("P"))}
 }
 
+// invalidate drops package from the cache, it should be called after changes are written
+func (collection *PackageCollection) invalidate(key []byte) {
+	if collection.cache != nil {
+		collection.cache.Invalidate(key)
+	}
+}
+
+// invalidateAfterWrite drops package from the cache once change made with w is written:
+// batches keep track of packages till they're written, direct writes to the storage are
+// written already, while transactions should be handled by the caller after commit
+func (collection *PackageCollection) invalidateAfterWrite(w database.Writer, key []byte) {
+	switch w := w.(type) {
+	case *packageBatch:
+		w.invalidateOnWrite(collection, key)
+	case database.Storage:
+		collection.invalidate(key)
+	}
+}
+
 // DeleteByKey deletes package in DB by key
 func (collection *PackageCollection) DeleteByKey(key []byte, dbw database.Writer) error {
-	for _, key := range [][]byte{key, append([]byte("xF"), key...), append([]byte("xD"), key...), append([]byte("xE"), key...)} {
+	for _, key := range [][]byte{key, append([]byte("xF"), key...), append([]byte("xD"), key...), append([]byte("xE"), key...), append([]byte("xL"), key...)} {
 		err := dbw.Delete(key)
 		if err != nil {
 			return err
 		}
 	}
+
+	collection.invalidateAfterWrite(dbw, key)
 	return nil
 }
 
+// packageBatchSize is number of packages written in single batch by packageBatch, some
+// databases (etcd) limit number of operations in one batch
+const packageBatchSize = 25
+
+// packageBatch is database batch which is written every packageBatchSize packages, it is
+// used for bulk updates of packages which don't have to be atomic
+type packageBatch struct {
+	database.Batch
+	pending int
+
+	// packages to drop from the cache of collection once batch is written
+	collection  *PackageCollection
+	invalidated [][]byte
+}
+
+func newPackageBatch(db database.Storage) *packageBatch {
+	return &packageBatch{Batch: db.CreateBatch()}
+}
+
+// invalidateOnWrite drops package from the cache of collection once batch is written
+func (b *packageBatch) invalidateOnWrite(collection *PackageCollection, key []byte) {
+	if collection.cache == nil {
+		return
+	}
+
+	b.collection = collection
+	b.invalidated = append(b.invalidated, key)
+}
+
+// Write writes the batch, dropping updated packages from the cache afterwards, so that
+// concurrent readers can't cache package contents which are about to be replaced
+func (b *packageBatch) Write() error {
+	err := b.Batch.Write()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range b.invalidated {
+		b.collection.invalidate(key)
+	}
+	b.invalidated = nil
+
+	return nil
+}
+
+// packageDone writes the batch if enough packages have been accumulated
+func (b *packageBatch) packageDone() error {
+	b.pending++
+	if b.pending < packageBatchSize {
+		return nil
+	}
+
+	b.pending = 0
+	return b.Write()
+}
+
+// DeleteByKeys deletes packages in DB by keys
+//
+// Packages are deleted in several batches, so deletion is not atomic.
+func (collection *PackageCollection) DeleteByKeys(refs *PackageRefList) error {
+	batch := newPackageBatch(collection.db)
+
+	err := refs.ForEach(func(key []byte) error {
+		err := collection.DeleteByKey(key, batch)
+		if err != nil {
+			return err
+		}
+
+		return batch.packageDone()
+	})
+	if err != nil {
+		return err
+	}
+
+	return batch.Write()
+}
+
 // Scan does full scan on all the packages
 func (collection *PackageCollection) Scan(q PackageQuery) (result *PackageList) {
 	result = NewPackageListWithDuplicates(true, 0)
@@ -1,6 +1,9 @@
 package deb
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/database/goleveldb"
 	"github.com/aptly-dev/aptly/utils"
@@ -65,6 +68,30 @@ func (s *PackageCollectionSuite) TestByKey(c *C) {
 	c.Check(p2.Files()[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
 }
 
+func (s *PackageCollectionSuite) TestByKeyWithFields(c *C) {
+	err := s.collection.Update(s.p)
+	c.Assert(err, IsNil)
+
+	p2, err := s.collection.ByKeyWithFields(s.p.Key(""), PackageFieldsDependencies)
+	c.Assert(err, IsNil)
+	c.Assert(p2.Equals(s.p), Equals, true)
+
+	c.Check(p2.GetDependencies(0), DeepEquals, []string{"libc6 (>= 2.7)", "alien-arena-data (>= 7.40)", "dpkg (>= 1.6)"})
+	c.Check(p2.deps, NotNil)
+
+	// fields not selected are loaded on demand, but not kept in memory
+	c.Check(p2.Extra()["Priority"], Equals, "extra")
+	c.Check(p2.extra, IsNil)
+	c.Check(p2.Files()[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
+	c.Check(p2.files, IsNil)
+	c.Check(p2.Stanza()["Filename"], Equals, "alien-arena-common_7.40-2_i386.deb")
+
+	p3, err := s.collection.ByKeyWithFields(s.p.Key(""), PackageFieldsBasic)
+	c.Assert(err, IsNil)
+	c.Check(p3.GetDependencies(0), HasLen, 3)
+	c.Check(p3.deps, IsNil)
+}
+
 func (s *PackageCollectionSuite) TestByKeyOld0_3(c *C) {
 	key := []byte("Pi386 vmware-view-open-client 4.5.0-297975+dfsg-4+b1")
 	s.db.Put(key, old0_3Package)
@@ -133,6 +160,28 @@ func (s *PackageCollectionSuite) TestDeleteByKey(c *C) {
 	c.Check(err, ErrorMatches, "key not found")
 }
 
+func (s *PackageCollectionSuite) TestDeleteByKeys(c *C) {
+	refs := NewPackageRefList()
+
+	for i := 0; i < 2*packageBatchSize+1; i++ {
+		p := NewPackageFromControlFile(packageStanza.Copy())
+		p.Version = fmt.Sprintf("1.%d", i)
+		c.Assert(s.collection.Update(p), IsNil)
+
+		if i%2 == 0 {
+			refs.Refs = append(refs.Refs, p.Key(""))
+		}
+	}
+	sort.Sort(refs)
+
+	c.Check(s.collection.DeleteByKeys(refs), IsNil)
+	c.Check(s.collection.AllPackageRefs().Len(), Equals, packageBatchSize)
+
+	_, err := s.db.Get(refs.Refs[0])
+	c.Check(err, ErrorMatches, "key not found")
+	c.Check(s.db.HasPrefix(append([]byte("xF"), refs.Refs[0]...)), Equals, false)
+}
+
 // This is old package (pre-0.4) that would habe to be converted
 var old0_3Package = []byte{0x8f, 0xac, 0x41, 0x72, 0x63, 0x68, 0x69, 0x74, 0x65, 0x63, 0x74, 0x75, 0x72, 0x65, 0xa4, 0x69, 0x33, 0x38, 0x36,
 	0xac, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x44, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x73, 0xc0, 0xb1, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x44, 0x65,
@@ -0,0 +1,198 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/pborman/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+// PackageSet is a named list of package queries, which could be
+// referenced from other queries as $PackageSet (name)
+type PackageSet struct {
+	// Permanent internal ID
+	UUID string `codec:"UUID" json:"-"`
+	// User-assigned name
+	Name string
+	// Comment
+	Comment string
+	// Queries, package matches package set if it matches any of the queries
+	Queries []string
+}
+
+// NewPackageSet creates new package set
+func NewPackageSet(name string, comment string, queries []string) *PackageSet {
+	return &PackageSet{
+		UUID:    uuid.New(),
+		Name:    name,
+		Comment: comment,
+		Queries: queries,
+	}
+}
+
+// String interface
+func (set *PackageSet) String() string {
+	if set.Comment != "" {
+		return fmt.Sprintf("[%s]: %s", set.Name, set.Comment)
+	}
+	return fmt.Sprintf("[%s]", set.Name)
+}
+
+// Query returns combined query string for the package set
+func (set *PackageSet) Query() string {
+	queries := make([]string, len(set.Queries))
+	for i := range set.Queries {
+		queries[i] = "(" + set.Queries[i] + ")"
+	}
+
+	return strings.Join(queries, " | ")
+}
+
+// Encode does msgpack encoding of PackageSet
+func (set *PackageSet) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	encoder.Encode(set)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into PackageSet
+func (set *PackageSet) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(set)
+}
+
+// Key is a unique id in DB
+func (set *PackageSet) Key() []byte {
+	return []byte("G" + set.UUID)
+}
+
+// PackageSetCollection does listing, updating/adding/deleting of PackageSets
+type PackageSetCollection struct {
+	db       database.Storage
+	cache    map[string]*PackageSet
+	recorder *AuditRecorder
+}
+
+// NewPackageSetCollection loads PackageSets from DB and makes up collection
+func NewPackageSetCollection(db database.Storage) *PackageSetCollection {
+	return &PackageSetCollection{
+		db:    db,
+		cache: make(map[string]*PackageSet),
+	}
+}
+
+func (collection *PackageSetCollection) search(filter func(*PackageSet) bool, unique bool) []*PackageSet {
+	result := []*PackageSet(nil)
+	for _, s := range collection.cache {
+		if filter(s) {
+			result = append(result, s)
+		}
+	}
+
+	if unique && len(result) > 0 {
+		return result
+	}
+
+	collection.db.ProcessByPrefix([]byte("G"), func(_, blob []byte) error {
+		s := &PackageSet{}
+		if err := s.Decode(blob); err != nil {
+			log.Printf("Error decoding package set: %s\n", err)
+			return nil
+		}
+
+		if filter(s) {
+			if _, exists := collection.cache[s.UUID]; !exists {
+				collection.cache[s.UUID] = s
+				result = append(result, s)
+				if unique {
+					return errors.New("abort")
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+// Add appends new package set to collection and saves it
+func (collection *PackageSetCollection) Add(set *PackageSet) error {
+	_, err := collection.ByName(set.Name)
+
+	if err == nil {
+		return fmt.Errorf("package set with name %s already exists", set.Name)
+	}
+
+	err = collection.Update(set)
+	if err != nil {
+		return err
+	}
+
+	collection.cache[set.UUID] = set
+	collection.recorder.Record(AuditActionCreated, AuditKindPackageSet, set.Name, set.UUID)
+	return nil
+}
+
+// Update stores updated information about package set in DB
+func (collection *PackageSetCollection) Update(set *PackageSet) error {
+	err := collection.db.Put(set.Key(), set.Encode())
+	if err == nil {
+		collection.recorder.Record(AuditActionUpdated, AuditKindPackageSet, set.Name, set.UUID)
+	}
+	return err
+}
+
+// ByName looks up package set by name
+func (collection *PackageSetCollection) ByName(name string) (*PackageSet, error) {
+	result := collection.search(func(s *PackageSet) bool { return s.Name == name }, true)
+	if len(result) == 0 {
+		return nil, fmt.Errorf("package set with name %s not found", name)
+	}
+
+	return result[0], nil
+}
+
+// ForEach runs method for each package set
+func (collection *PackageSetCollection) ForEach(handler func(*PackageSet) error) error {
+	return collection.db.ProcessByPrefix([]byte("G"), func(_, blob []byte) error {
+		s := &PackageSet{}
+		if err := s.Decode(blob); err != nil {
+			log.Printf("Error decoding package set: %s\n", err)
+			return nil
+		}
+
+		return handler(s)
+	})
+}
+
+// Len returns number of package sets
+func (collection *PackageSetCollection) Len() int {
+	return len(collection.db.KeysByPrefix([]byte("G")))
+}
+
+// Drop removes package set from collection
+func (collection *PackageSetCollection) Drop(set *PackageSet) error {
+	if _, err := collection.db.Get(set.Key()); err != nil {
+		if err == database.ErrNotFound {
+			return errors.New("package set not found")
+		}
+
+		return err
+	}
+	delete(collection.cache, set.UUID)
+
+	err := collection.db.Delete(set.Key())
+	if err == nil {
+		collection.recorder.Record(AuditActionDropped, AuditKindPackageSet, set.Name, set.UUID)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type PackageSetSuite struct {
+	set *PackageSet
+}
+
+var _ = Suite(&PackageSetSuite{})
+
+func (s *PackageSetSuite) SetUpTest(c *C) {
+	s.set = NewPackageSet("base-runtime", "Runtime libraries", []string{"libc6", "Name (% libssl*)"})
+}
+
+func (s *PackageSetSuite) TestString(c *C) {
+	c.Check(s.set.String(), Equals, "[base-runtime]: Runtime libraries")
+	c.Check(NewPackageSet("set2", "", nil).String(), Equals, "[set2]")
+}
+
+func (s *PackageSetSuite) TestQuery(c *C) {
+	c.Check(s.set.Query(), Equals, "(libc6) | (Name (% libssl*))")
+	c.Check(NewPackageSet("set2", "", []string{"bash"}).Query(), Equals, "(bash)")
+}
+
+func (s *PackageSetSuite) TestEncodeDecode(c *C) {
+	set := &PackageSet{}
+	err := set.Decode(s.set.Encode())
+	c.Assert(err, IsNil)
+
+	c.Check(set, DeepEquals, s.set)
+}
+
+func (s *PackageSetSuite) TestKey(c *C) {
+	c.Assert(len(s.set.Key()), Equals, 37)
+	c.Assert(s.set.Key()[0], Equals, byte('G'))
+}
+
+type PackageSetCollectionSuite struct {
+	db         database.Storage
+	collection *PackageSetCollection
+}
+
+var _ = Suite(&PackageSetCollectionSuite{})
+
+func (s *PackageSetCollectionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageSetCollection(s.db)
+}
+
+func (s *PackageSetCollectionSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *PackageSetCollectionSuite) TestAddByName(c *C) {
+	_, err := s.collection.ByName("set1")
+	c.Assert(err, ErrorMatches, "*.not found")
+
+	set := NewPackageSet("set1", "Comment 1", []string{"bash"})
+	c.Assert(s.collection.Add(set), IsNil)
+	c.Assert(s.collection.Add(set), ErrorMatches, ".*already exists")
+
+	r, err := s.collection.ByName("set1")
+	c.Assert(err, IsNil)
+	c.Assert(r, Equals, set)
+
+	collection := NewPackageSetCollection(s.db)
+	r, err = collection.ByName("set1")
+	c.Assert(err, IsNil)
+	c.Assert(r, DeepEquals, set)
+}
+
+func (s *PackageSetCollectionSuite) TestUpdate(c *C) {
+	set := NewPackageSet("set1", "Comment 1", []string{"bash"})
+	c.Assert(s.collection.Add(set), IsNil)
+
+	set.Queries = append(set.Queries, "zsh")
+	c.Assert(s.collection.Update(set), IsNil)
+
+	collection := NewPackageSetCollection(s.db)
+	r, err := collection.ByName("set1")
+	c.Assert(err, IsNil)
+	c.Check(r.Queries, DeepEquals, []string{"bash", "zsh"})
+}
+
+func (s *PackageSetCollectionSuite) TestForEachAndLen(c *C) {
+	s.collection.Add(NewPackageSet("set1", "", []string{"bash"}))
+	s.collection.Add(NewPackageSet("set2", "", []string{"zsh"}))
+
+	count := 0
+	err := s.collection.ForEach(func(*PackageSet) error {
+		count++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(count, Equals, 2)
+	c.Check(s.collection.Len(), Equals, 2)
+}
+
+func (s *PackageSetCollectionSuite) TestDrop(c *C) {
+	set1 := NewPackageSet("set1", "", []string{"bash"})
+	s.collection.Add(set1)
+
+	set2 := NewPackageSet("set2", "", []string{"zsh"})
+	s.collection.Add(set2)
+
+	c.Check(s.collection.Drop(set1), IsNil)
+
+	_, err := s.collection.ByName("set1")
+	c.Check(err, ErrorMatches, "package set .* not found")
+
+	collection := NewPackageSetCollection(s.db)
+	_, err = collection.ByName("set1")
+	c.Check(err, ErrorMatches, "package set .* not found")
+
+	r2, _ := collection.ByName("set2")
+	c.Check(r2.String(), Equals, set2.String())
+
+	c.Check(s.collection.Drop(set1), ErrorMatches, "package set not found")
+}
@@ -1,8 +1,10 @@
 package deb
 
 import (
+	"bufio"
 	"bytes"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 
@@ -161,6 +163,41 @@ func (s *PackageSuite) TestStanza(c *C) {
 	c.Assert(stanza, DeepEquals, s.sourceStanza)
 }
 
+func (s *PackageSuite) TestNewPackageFromStanza(c *C) {
+	input := packageStanza.Copy()
+	// descriptions read from indexes keep the space after colon
+	input["Description"] = " " + input["Description"]
+
+	p, err := NewPackageFromStanza(input.Copy())
+	c.Assert(err, IsNil)
+	c.Check(p.Equals(NewPackageFromControlFile(input.Copy())), Equals, true)
+
+	// stanza survives round-trip through deb822 format
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	c.Assert(p.Stanza().WriteTo(w, false, false, false), IsNil)
+	c.Assert(w.Flush(), IsNil)
+
+	stanza, err := NewControlFileReader(buf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	p2, err := NewPackageFromStanza(stanza)
+	c.Assert(err, IsNil)
+	c.Check(p2.Equals(p), Equals, true)
+	c.Check(p2.Stanza(), DeepEquals, p.Stanza())
+
+	stanza, _ = NewControlFileReader(bytes.NewBufferString(sourcePackageMeta), false, false).ReadStanza()
+	p, err = NewPackageFromStanza(stanza)
+	c.Assert(err, IsNil)
+	c.Check(p.IsSource, Equals, true)
+	c.Check(p.Name, Equals, "access-modifier-checker")
+
+	_, err = NewPackageFromStanza(Stanza{"Package": "hello", "Version": "1.0"})
+	c.Check(err, ErrorMatches, "stanza is missing Architecture field")
+
+	_, err = NewPackageFromStanza(Stanza{"Package": "hello", "Version": "1.0", "Architecture": "amd64"})
+	c.Check(err, ErrorMatches, "stanza of hello_1.0 should contain either Filename or Files field")
+}
+
 func (s *PackageSuite) TestString(c *C) {
 	p := NewPackageFromControlFile(s.stanza)
 	c.Assert(p.String(), Equals, "alien-arena-common_7.40-2_i386")
@@ -195,6 +232,12 @@ func (s *PackageSuite) TestGetField(c *C) {
 	c.Check(p4.GetField("$SourceVersion"), Equals, "")
 	c.Check(p5.GetField("$SourceVersion"), Equals, "2.11-9")
 
+	c.Check(p.GetField("$Installed-Size"), Equals, "466944")
+	c.Check(p4.GetField("$Installed-Size"), Equals, "")
+
+	c.Check(p.GetField("$Depends-Count"), Equals, "2")
+	c.Check(p4.GetField("$Depends-Count"), Equals, "0")
+
 	c.Check(p.GetField("$Architecture"), Equals, "i386")
 	c.Check(p4.GetField("$Architecture"), Equals, "source")
 	c.Check(p5.GetField("$Architecture"), Equals, "amd64")
@@ -223,6 +266,14 @@ func (s *PackageSuite) TestGetField(c *C) {
 
 	c.Check(p.GetField("Section"), Equals, "contrib/games")
 	c.Check(p.GetField("Priority"), Equals, "extra")
+
+	// names of unknown fields are matched ignoring case
+	stanza6 := s.stanza.Copy()
+	stanza6["XB-Vendor-Flavor"] = "strawberry"
+	p6 := NewPackageFromControlFile(stanza6)
+	c.Check(p6.GetField("XB-Vendor-Flavor"), Equals, "strawberry")
+	c.Check(p6.GetField("Xb-Vendor-Flavor"), Equals, "strawberry")
+	c.Check(p6.GetField("X-Missing"), Equals, "")
 }
 
 func (s *PackageSuite) TestEquals(c *C) {
@@ -272,6 +323,15 @@ func (s *PackageSuite) TestMatchesArchitecture(c *C) {
 	p, _ = NewSourcePackageFromControlFile(s.sourceStanza)
 	c.Check(p.MatchesArchitecture("source"), Equals, true)
 	c.Check(p.MatchesArchitecture("amd64"), Equals, false)
+	c.Check(p.MatchesArchitecture("any"), Equals, false)
+
+	s.stanza = packageStanza.Copy()
+	p = NewPackageFromControlFile(s.stanza)
+	c.Check(p.MatchesArchitecture("any"), Equals, true)
+	c.Check(p.MatchesArchitecture("linux-any"), Equals, true)
+	c.Check(p.MatchesArchitecture("hurd-any"), Equals, false)
+	c.Check(p.MatchesArchitecture("any-i386"), Equals, true)
+	c.Check(p.MatchesArchitecture("any-amd64"), Equals, false)
 }
 
 func (s *PackageSuite) TestMatchesDependency(c *C) {
@@ -396,17 +456,55 @@ func (s *PackageSuite) TestLinkFromPool(c *C) {
 
 	p.Files()[0].PoolPath, _ = packagePool.Import(tmpFilepath, p.Files()[0].Filename, &p.Files()[0].Checksums, false, cs)
 
-	err := p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false)
+	err := p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false, nil)
 	c.Check(err, IsNil)
 	c.Check(p.Files()[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
 	c.Check(p.Files()[0].downloadPath, Equals, "pool/non-free/a/alien-arena")
 
 	p.IsSource = true
-	err = p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false)
+	err = p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false, nil)
 	c.Check(err, IsNil)
 	c.Check(p.Extra()["Directory"], Equals, "pool/non-free/a/alien-arena")
 }
 
+func (s *PackageSuite) TestLinkFromPoolSkipsLinked(c *C) {
+	packagePool := files.NewPackagePool(c.MkDir(), false)
+	cs := files.NewMockChecksumStorage()
+	publishedStorage := files.NewPublishedStorage(c.MkDir(), "", "")
+	p := NewPackageFromControlFile(s.stanza)
+
+	tmpFilepath := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(tmpFilepath, nil, 0777), IsNil)
+
+	p.Files()[0].PoolPath, _ = packagePool.Import(tmpFilepath, p.Files()[0].Filename, &p.Files()[0].Checksums, false, cs)
+
+	linked := map[string]bool{}
+	publishedPath := filepath.Join(publishedStorage.PublicPath(), "pool/non-free/a/alien-arena/alien-arena-common_7.40-2_i386.deb")
+
+	err := p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false, linked)
+	c.Check(err, IsNil)
+	c.Check(linked, HasLen, 1)
+	_, err = os.Stat(publishedPath)
+	c.Check(err, IsNil)
+
+	// file already linked during this run isn't linked again
+	c.Assert(os.Remove(publishedPath), IsNil)
+	p.Files()[0].downloadPath = ""
+
+	err = p.LinkFromPool(publishedStorage, packagePool, "", "pool/non-free/a/alien-arena", false, linked)
+	c.Check(err, IsNil)
+	c.Check(p.Files()[0].downloadPath, Equals, "pool/non-free/a/alien-arena")
+	_, err = os.Stat(publishedPath)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	// other location is linked
+	err = p.LinkFromPool(publishedStorage, packagePool, "", "pool/main/a/alien-arena", false, linked)
+	c.Check(err, IsNil)
+	c.Check(linked, HasLen, 2)
+	_, err = os.Stat(filepath.Join(publishedStorage.PublicPath(), "pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb"))
+	c.Check(err, IsNil)
+}
+
 func (s *PackageSuite) TestFilepathList(c *C) {
 	packagePool := files.NewPackagePool(c.MkDir(), true)
 	p := NewPackageFromControlFile(s.stanza)
@@ -426,7 +524,8 @@ func (s *PackageSuite) TestDownloadList(c *C) {
 	c.Check(err, IsNil)
 	c.Check(list, DeepEquals, []PackageDownloadTask{
 		{
-			File: &p.Files()[0],
+			File:    &p.Files()[0],
+			Package: p,
 		},
 	})
 
@@ -462,6 +561,19 @@ func (s *PackageSuite) TestVerifyFiles(c *C) {
 	c.Check(result, Equals, true)
 }
 
+func (s *PackageSuite) TestIsExtraSourceOnly(c *C) {
+	p := NewPackageFromControlFile(s.stanza.Copy())
+	c.Check(p.IsExtraSourceOnly(), Equals, false)
+
+	p, _ = NewSourcePackageFromControlFile(s.sourceStanza.Copy())
+	c.Check(p.IsExtraSourceOnly(), Equals, false)
+
+	stanza := s.sourceStanza.Copy()
+	stanza["Extra-Source-Only"] = "yes"
+	p, _ = NewSourcePackageFromControlFile(stanza)
+	c.Check(p.IsExtraSourceOnly(), Equals, true)
+}
+
 var packageStanza = Stanza{"Source": "alien-arena", "Pre-Depends": "dpkg (>= 1.6)", "Suggests": "alien-arena-mars", "Recommends": "aliean-arena-luna", "Depends": "libc6 (>= 2.7), alien-arena-data (>= 7.40)", "Filename": "pool/contrib/a/alien-arena/alien-arena-common_7.40-2_i386.deb", "SHA1": "46955e48cad27410a83740a21d766ce362364024", "SHA256": "eb4afb9885cba6dc70cccd05b910b2dbccc02c5900578be5e99f0d3dbf9d76a5", "Priority": "extra", "Maintainer": "Debian Games Team <pkg-games-devel@lists.alioth.debian.org>", "Description": "Common files for Alien Arena client and server ALIEN ARENA is a standalone 3D first person online deathmatch shooter\n crafted from the original source code of Quake II and Quake III, released\n by id Software under the GPL license. With features including 32 bit\n graphics, new particle engine and effects, light blooms, reflective water,\n hi resolution textures and skins, hi poly models, stain maps, ALIEN ARENA\n pushes the envelope of graphical beauty rivaling today's top games.\n .\n This package installs the common files for Alien Arena.\n", "Homepage": "http://red.planetarena.org", "Tag": "role::app-data, role::shared-lib, special::auto-inst-parts", "Installed-Size": "456", "Version": "7.40-2", "Replaces": "alien-arena (<< 7.33-1)", "Size": "187518", "MD5sum": "1e8cba92c41420aa7baa8a5718d67122", "Package": "alien-arena-common", "Section": "contrib/games", "Architecture": "i386"}
 
 const sourcePackageMeta = `Package: access-modifier-checker
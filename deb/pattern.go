@@ -0,0 +1,65 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CompilePattern builds regular expression for text matching relations: regular
+// expression (VersionRegexp) and substring (VersionContains) match
+//
+// Shell patterns (VersionPatternMatch) are matched with filepath.Match, so for them
+// pattern is only validated and nil is returned.
+func CompilePattern(relation int, pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var expr string
+
+	switch relation {
+	case VersionPatternMatch:
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: %s", err, pattern)
+		}
+		return nil, nil
+	case VersionRegexp:
+		expr = pattern
+	case VersionContains:
+		expr = regexp.QuoteMeta(pattern)
+	default:
+		return nil, fmt.Errorf("relation %d doesn't support patterns", relation)
+	}
+
+	if caseInsensitive {
+		expr = "(?i)" + expr
+	}
+
+	return regexp.Compile(expr)
+}
+
+// IsPatternRelation returns true if relation is matched with CompilePattern
+func IsPatternRelation(relation int) bool {
+	return relation == VersionPatternMatch || relation == VersionRegexp || relation == VersionContains
+}
+
+// matchPattern matches value against pattern relation, compiling pattern if it
+// hasn't been compiled yet; invalid pattern doesn't match anything
+func matchPattern(re *regexp.Regexp, relation int, pattern string, caseInsensitive bool, value string) bool {
+	if relation == VersionPatternMatch {
+		if caseInsensitive {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+
+		matched, err := filepath.Match(pattern, value)
+		return err == nil && matched
+	}
+
+	if re == nil {
+		var err error
+		re, err = CompilePattern(relation, pattern, caseInsensitive)
+		if err != nil {
+			return false
+		}
+	}
+
+	return re.MatchString(value)
+}
@@ -0,0 +1,63 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type PatternSuite struct {
+}
+
+var _ = Suite(&PatternSuite{})
+
+func (s *PatternSuite) TestMatchShellPattern(c *C) {
+	for _, t := range []struct {
+		pattern, value  string
+		caseInsensitive bool
+		matches         bool
+	}{
+		{"lib*-dev", "libc6-dev", false, true},
+		{"lib*-dev", "libc6-dev-i386", false, false},
+		{"1.?", "1.2", false, true},
+		{"a[bc]d", "acd", false, true},
+		{"a[^0-9]", "a1", false, false},
+		{`a\*`, "a*", false, true},
+		{`a\*`, "ab", false, false},
+		{"non-free/*", "non-free/libs", false, true},
+		{"*/libs", "main/non-free/libs", false, false},
+		{"main/*", "main/non-free/libs", false, false},
+		{"Non-Free/*", "non-free/libs", false, false},
+		{"Non-Free/*", "non-free/libs", true, true},
+		{"a[bc", "ab", false, false},
+	} {
+		c.Check(matchPattern(nil, VersionPatternMatch, t.pattern, t.caseInsensitive, t.value), Equals, t.matches,
+			Commentf("pattern: %s, value: %s", t.pattern, t.value))
+	}
+}
+
+func (s *PatternSuite) TestCompilePattern(c *C) {
+	re, err := CompilePattern(VersionPatternMatch, "non-free/*", false)
+	c.Check(err, IsNil)
+	c.Check(re, IsNil)
+
+	_, err = CompilePattern(VersionPatternMatch, "a[bc", false)
+	c.Check(err, ErrorMatches, "syntax error in pattern: a\\[bc")
+
+	re, err = CompilePattern(VersionContains, "c++", false)
+	c.Assert(err, IsNil)
+	c.Check(re.MatchString("library for c++ programs"), Equals, true)
+	c.Check(re.MatchString("library for C++ programs"), Equals, false)
+
+	re, err = CompilePattern(VersionContains, "c++", true)
+	c.Assert(err, IsNil)
+	c.Check(re.MatchString("library for C++ programs"), Equals, true)
+
+	re, err = CompilePattern(VersionRegexp, "^lib", true)
+	c.Assert(err, IsNil)
+	c.Check(re.MatchString("LibFoo"), Equals, true)
+
+	_, err = CompilePattern(VersionEqual, "lib", false)
+	c.Check(err, ErrorMatches, "relation 3 doesn't support patterns")
+
+	c.Check(IsPatternRelation(VersionContains), Equals, true)
+	c.Check(IsPatternRelation(VersionGreater), Equals, false)
+}
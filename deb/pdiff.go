@@ -0,0 +1,692 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	gocontext "context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// pdiffEntry is file (or patch) name with checksum as listed in pdiff index
+type pdiffEntry struct {
+	Name     string
+	Checksum utils.ChecksumInfo
+}
+
+// pdiffIndex is parsed Packages.diff/Index file
+type pdiffIndex struct {
+	// Current version of the package index
+	Current utils.ChecksumInfo
+	// History lists versions of package index before applying each patch
+	History []pdiffEntry
+	// Patches lists checksums of uncompressed patches
+	Patches map[string]utils.ChecksumInfo
+	// Download lists checksums of compressed patches
+	Download map[string]utils.ChecksumInfo
+	// Merged is true if each patch upgrades index straight to the current version
+	Merged bool
+}
+
+// parsePDiffIndex parses Index file of pdiffs (only SHA256 checksums are supported)
+func parsePDiffIndex(r io.Reader) (*pdiffIndex, error) {
+	stanza, err := NewControlFileReader(r, false, false).ReadStanza()
+	if err != nil {
+		return nil, err
+	}
+	if stanza == nil {
+		return nil, fmt.Errorf("empty pdiff index")
+	}
+
+	parseEntries := func(field string) ([]pdiffEntry, error) {
+		parts := strings.Fields(stanza[field])
+		if len(parts)%3 != 0 {
+			return nil, fmt.Errorf("malformed field %s in pdiff index", field)
+		}
+
+		result := make([]pdiffEntry, 0, len(parts)/3)
+		for i := 0; i < len(parts); i += 3 {
+			size, e := strconv.ParseInt(parts[i+1], 10, 64)
+			if e != nil {
+				return nil, fmt.Errorf("unable to parse size: %s", e)
+			}
+			result = append(result, pdiffEntry{Name: parts[i+2], Checksum: utils.ChecksumInfo{SHA256: parts[i], Size: size}})
+		}
+
+		return result, nil
+	}
+
+	current := strings.Fields(stanza["Sha256-Current"])
+	if len(current) != 2 {
+		return nil, fmt.Errorf("pdiff index doesn't contain SHA256-Current")
+	}
+
+	index := &pdiffIndex{
+		Patches:  make(map[string]utils.ChecksumInfo),
+		Download: make(map[string]utils.ChecksumInfo),
+		Merged:   stanza["X-Patch-Precedence"] == "merged",
+	}
+
+	index.Current.SHA256 = current[0]
+	index.Current.Size, err = strconv.ParseInt(current[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse size: %s", err)
+	}
+
+	index.History, err = parseEntries("Sha256-History")
+	if err != nil {
+		return nil, err
+	}
+
+	for field, target := range map[string]map[string]utils.ChecksumInfo{"Sha256-Patches": index.Patches, "Sha256-Download": index.Download} {
+		var entries []pdiffEntry
+		entries, err = parseEntries(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			target[entry.Name] = entry.Checksum
+		}
+	}
+
+	return index, nil
+}
+
+// WriteTo writes pdiff index in Packages.diff/Index format
+func (index *pdiffIndex) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "SHA256-Current: %s %d\n", index.Current.SHA256, index.Current.Size)
+	buf.WriteString("SHA256-History:\n")
+	for _, entry := range index.History {
+		fmt.Fprintf(&buf, " %s %8d %s\n", entry.Checksum.SHA256, entry.Checksum.Size, entry.Name)
+	}
+	buf.WriteString("SHA256-Patches:\n")
+	for _, entry := range index.History {
+		sum := index.Patches[entry.Name]
+		fmt.Fprintf(&buf, " %s %8d %s\n", sum.SHA256, sum.Size, entry.Name)
+	}
+	buf.WriteString("SHA256-Download:\n")
+	for _, entry := range index.History {
+		sum := index.Download[entry.Name+".gz"]
+		fmt.Fprintf(&buf, " %s %8d %s.gz\n", sum.SHA256, sum.Size, entry.Name)
+	}
+
+	return buf.WriteTo(w)
+}
+
+// PatchesFrom returns list of patches to be applied to the version of package index
+// with specified SHA256 checksum to get the current version
+func (index *pdiffIndex) PatchesFrom(sha256 string) ([]string, error) {
+	for i, entry := range index.History {
+		if entry.Checksum.SHA256 != sha256 {
+			continue
+		}
+
+		if index.Merged {
+			return []string{entry.Name}, nil
+		}
+
+		result := make([]string, 0, len(index.History)-i)
+		for _, e := range index.History[i:] {
+			result = append(result, e.Name)
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("patch chain is broken: version %s not found in pdiff history", sha256)
+}
+
+// applyEdPatch applies patch in ed(1) format (as produced by diff --ed) to the list of lines
+func applyEdPatch(lines [][]byte, patch io.Reader) ([][]byte, error) {
+	scanner := bufio.NewScanner(patch)
+	scanner.Buffer(nil, MaxFieldSize)
+
+	current := len(lines)
+
+	for scanner.Scan() {
+		command := scanner.Text()
+		if command == "" {
+			continue
+		}
+
+		// diff --ed escapes lines consisting of single dot
+		if command == "s/.//" {
+			if current < 1 || current > len(lines) {
+				return nil, fmt.Errorf("invalid line %d for ed command %#v", current, command)
+			}
+			lines[current-1] = lines[current-1][1:]
+			continue
+		}
+
+		op := command[len(command)-1]
+		start, end := current, current
+
+		if address := command[:len(command)-1]; address != "" {
+			var err error
+			bounds := strings.SplitN(address, ",", 2)
+
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse ed command %#v", command)
+			}
+			end = start
+
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse ed command %#v", command)
+				}
+			}
+		}
+
+		if start < 0 || end < start || end > len(lines) || (op != 'a' && start < 1) {
+			return nil, fmt.Errorf("invalid address in ed command %#v", command)
+		}
+
+		var text [][]byte
+		if op == 'a' || op == 'c' {
+			terminated := false
+			for scanner.Scan() {
+				if scanner.Text() == "." {
+					terminated = true
+					break
+				}
+				text = append(text, append([]byte(nil), scanner.Bytes()...))
+			}
+			if !terminated {
+				return nil, fmt.Errorf("unterminated text for ed command %#v", command)
+			}
+		}
+
+		switch op {
+		case 'a':
+			lines = append(lines[:start], append(text, lines[start:]...)...)
+			current = start + len(text)
+		case 'c':
+			lines = append(lines[:start-1], append(text, lines[end:]...)...)
+			current = start - 1 + len(text)
+		case 'd':
+			lines = append(lines[:start-1], lines[end:]...)
+			current = start - 1
+		default:
+			return nil, fmt.Errorf("unsupported ed command %#v", command)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// pdiffHunk is replacement of lines [oldStart, oldEnd) with lines [newStart, newEnd)
+type pdiffHunk struct {
+	oldStart, oldEnd, newStart, newEnd int
+}
+
+// diffLines calculates difference between two lists of lines
+//
+// Patience diff is used: lines unique in both lists are used as anchors, as package
+// indexes consist mostly of unique lines, that gives compact diffs quickly
+func diffLines(a, b [][]byte) []pdiffHunk {
+	hunks := []pdiffHunk{}
+
+	var diffRange func(aLo, aHi, bLo, bHi int)
+	diffRange = func(aLo, aHi, bLo, bHi int) {
+		for aLo < aHi && bLo < bHi && bytes.Equal(a[aLo], b[bLo]) {
+			aLo++
+			bLo++
+		}
+		for aLo < aHi && bLo < bHi && bytes.Equal(a[aHi-1], b[bHi-1]) {
+			aHi--
+			bHi--
+		}
+
+		if aLo == aHi && bLo == bHi {
+			return
+		}
+
+		var anchors [][2]int
+		if aLo < aHi && bLo < bHi {
+			anchors = uniqueAnchors(a, b, aLo, aHi, bLo, bHi)
+		}
+		if len(anchors) == 0 {
+			hunks = append(hunks, pdiffHunk{aLo, aHi, bLo, bHi})
+			return
+		}
+
+		for _, anchor := range anchors {
+			diffRange(aLo, anchor[0], bLo, anchor[1])
+			aLo, bLo = anchor[0]+1, anchor[1]+1
+		}
+		diffRange(aLo, aHi, bLo, bHi)
+	}
+
+	diffRange(0, len(a), 0, len(b))
+
+	return hunks
+}
+
+// uniqueAnchors returns longest increasing sequence of pairs of line positions
+// for lines which are unique in both a[aLo:aHi] and b[bLo:bHi]
+func uniqueAnchors(a, b [][]byte, aLo, aHi, bLo, bHi int) [][2]int {
+	type occurrence struct {
+		countA, countB int
+		posA, posB     int
+	}
+
+	occurrences := make(map[string]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o := occurrences[string(a[i])]
+		if o == nil {
+			o = &occurrence{}
+			occurrences[string(a[i])] = o
+		}
+		o.countA++
+		o.posA = i
+	}
+	for i := bLo; i < bHi; i++ {
+		if o := occurrences[string(b[i])]; o != nil {
+			o.countB++
+			o.posB = i
+		}
+	}
+
+	candidates := [][2]int{}
+	for i := aLo; i < aHi; i++ {
+		if o := occurrences[string(a[i])]; o.countA == 1 && o.countB == 1 {
+			candidates = append(candidates, [2]int{o.posA, o.posB})
+		}
+	}
+
+	// longest increasing subsequence by position in b (patience sorting)
+	tails := []int{}
+	prev := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		j := sort.Search(len(tails), func(k int) bool { return candidates[tails[k]][1] >= candidate[1] })
+		if j > 0 {
+			prev[i] = tails[j-1]
+		} else {
+			prev[i] = -1
+		}
+		if j == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[j] = i
+		}
+	}
+
+	result := make([][2]int, len(tails))
+	if len(tails) > 0 {
+		for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+			result[i] = candidates[k]
+		}
+	}
+
+	return result
+}
+
+// writeEdPatch writes hunks as patch in ed(1) format, transforming a into b
+func writeEdPatch(w io.Writer, hunks []pdiffHunk, b [][]byte) error {
+	bw := bufio.NewWriter(w)
+
+	writeText := func(hunk pdiffHunk) error {
+		for _, line := range b[hunk.newStart:hunk.newEnd] {
+			if bytes.Equal(line, []byte(".")) {
+				return fmt.Errorf("unable to generate ed patch for line consisting of single dot")
+			}
+			bw.Write(line)
+			bw.WriteByte('\n')
+		}
+		bw.WriteString(".\n")
+		return nil
+	}
+
+	// commands are written from the end of file, so that line numbers stay valid
+	for i := len(hunks) - 1; i >= 0; i-- {
+		hunk := hunks[i]
+
+		address := strconv.Itoa(hunk.oldStart + 1)
+		if hunk.oldEnd-hunk.oldStart > 1 {
+			address += "," + strconv.Itoa(hunk.oldEnd)
+		}
+
+		switch {
+		case hunk.oldStart == hunk.oldEnd:
+			fmt.Fprintf(bw, "%da\n", hunk.oldStart)
+			if err := writeText(hunk); err != nil {
+				return err
+			}
+		case hunk.newStart == hunk.newEnd:
+			fmt.Fprintf(bw, "%sd\n", address)
+		default:
+			fmt.Fprintf(bw, "%sc\n", address)
+			if err := writeText(hunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// splitIndexLines splits contents of package index into lines
+func splitIndexLines(contents []byte) [][]byte {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i := range lines {
+		lines[i] = bytes.TrimSuffix(lines[i], []byte("\n"))
+	}
+
+	return lines
+}
+
+// SetIndexCache sets directory to keep copies of downloaded package indexes in,
+// which is required to update them with pdiffs
+func (repo *RemoteRepo) SetIndexCache(dir string) {
+	repo.indexCacheDir = dir
+}
+
+// writeCachedIndex stores copy of package index in the cache, verifying SHA256 if expected is not nil,
+// and returns cached file opened for reading
+func (repo *RemoteRepo) writeCachedIndex(path string, r io.Reader, expected *utils.ChecksumInfo) (*os.File, error) {
+	cachePath := filepath.Join(repo.indexCacheDir, path)
+
+	err := os.MkdirAll(filepath.Dir(cachePath), 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+
+	cks := utils.NewChecksumWriter()
+	_, err = io.Copy(io.MultiWriter(temp, cks), r)
+	if err1 := temp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expected != nil && (expected.SHA256 != cks.Sum().SHA256 || expected.Size != cks.Sum().Size) {
+		return nil, fmt.Errorf("checksum mismatch for %s after applying pdiffs", path)
+	}
+
+	err = os.Rename(temp.Name(), cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(cachePath)
+}
+
+// patchCachedIndex brings cached copy of package index up to date by applying pdiffs
+//
+// If there is no cached copy or pdiffs are not available, nil file is returned
+func (repo *RemoteRepo) patchCachedIndex(d aptly.Downloader, path string) (*os.File, error) {
+	target, ok := repo.ReleaseFiles[path]
+	if !ok || target.SHA256 == "" {
+		return nil, nil
+	}
+
+	cachePath := filepath.Join(repo.indexCacheDir, path)
+
+	current, err := utils.ChecksumsForFile(cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if current.SHA256 == target.SHA256 {
+		return os.Open(cachePath)
+	}
+
+	indexChecksum, ok := repo.ReleaseFiles[path+".diff/Index"]
+	if !ok {
+		return nil, nil
+	}
+
+	indexFile, err := http.DownloadTempWithChecksum(gocontext.TODO(), d,
+		repo.IndexesRootURL().ResolveReference(&url.URL{Path: path + ".diff/Index"}).String(), &indexChecksum, false)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Close()
+
+	index, err := parsePDiffIndex(indexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.Current.SHA256 != target.SHA256 {
+		return nil, fmt.Errorf("pdiff index for %s is out of sync with Release file", path)
+	}
+
+	patches, err := index.PatchesFrom(current.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitIndexLines(contents)
+
+	for _, patch := range patches {
+		var expected *utils.ChecksumInfo
+		if checksum, ok := index.Download[patch+".gz"]; ok {
+			expected = &checksum
+		}
+
+		var patchFile *os.File
+		patchFile, err = http.DownloadTempWithChecksum(gocontext.TODO(), d,
+			repo.IndexesRootURL().ResolveReference(&url.URL{Path: path + ".diff/" + patch + ".gz"}).String(), expected, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var gzReader *gzip.Reader
+		gzReader, err = gzip.NewReader(patchFile)
+		if err == nil {
+			lines, err = applyEdPatch(lines, gzReader)
+		}
+		patchFile.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply pdiff %s: %s", patch, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return repo.writeCachedIndex(path, &buf, &target)
+}
+
+// newPDiffName returns name for the next pdiff based on time of publishing, pdiffs
+// generated within the same second are disambiguated with a counter
+func (index *pdiffIndex) newPDiffName(now time.Time) string {
+	base := now.UTC().Format("2006-01-02-1504.05")
+
+	taken := make(map[string]bool, len(index.History))
+	for _, entry := range index.History {
+		taken[entry.Name] = true
+	}
+
+	name := base
+	for i := 1; taken[name]; i++ {
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+
+	return name
+}
+
+// maxPDiffHistory is number of pdiffs kept for each package index
+const maxPDiffHistory = 20
+
+// publishPDiff generates pdiff between currently published version of package index
+// and the new one, publishing updated Packages.diff/Index
+func (file *indexFile) publishPDiff() error {
+	storage, ok := file.parent.publishedStorage.(aptly.FileSystemPublishedStorage)
+	if !ok {
+		return fmt.Errorf("pdiffs are supported only for filesystem published storage")
+	}
+
+	diffDir := file.relativePath + ".diff"
+	publishedPath := filepath.Join(storage.PublicPath(), file.parent.basePath, file.relativePath)
+
+	oldContents, err := os.ReadFile(publishedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// nothing to diff against
+			return nil
+		}
+		return err
+	}
+
+	index := &pdiffIndex{
+		Patches:  make(map[string]utils.ChecksumInfo),
+		Download: make(map[string]utils.ChecksumInfo),
+	}
+
+	oldSum, err := utils.ChecksumsForReader(bytes.NewReader(oldContents))
+	if err != nil {
+		return err
+	}
+
+	indexFile, err := os.Open(filepath.Join(publishedPath+".diff", "Index"))
+	if err == nil {
+		var oldIndex *pdiffIndex
+		oldIndex, err = parsePDiffIndex(indexFile)
+		indexFile.Close()
+
+		// history is valid only if it leads to currently published version
+		if err == nil && oldIndex.Current.SHA256 == oldSum.SHA256 {
+			index = oldIndex
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	newSum := file.parent.generatedFile(file.relativePath)
+	index.Current = newSum
+
+	if oldSum.SHA256 != newSum.SHA256 {
+		var newContents []byte
+		newContents, err = os.ReadFile(file.tempFilename)
+		if err != nil {
+			return err
+		}
+
+		name := index.newPDiffName(time.Now())
+
+		newLines := splitIndexLines(newContents)
+
+		var patchFile *os.File
+		patchFile, err = os.Create(file.tempFilename + ".diff-" + name)
+		if err != nil {
+			return err
+		}
+
+		err = writeEdPatch(patchFile, diffLines(splitIndexLines(oldContents), newLines), newLines)
+		if err == nil {
+			err = utils.CompressFile(patchFile, true)
+		}
+		patchFile.Close()
+		if err != nil {
+			return err
+		}
+
+		index.Patches[name], err = utils.ChecksumsForFile(patchFile.Name())
+		if err != nil {
+			return err
+		}
+		index.Download[name+".gz"], err = utils.ChecksumsForFile(patchFile.Name() + ".gz")
+		if err != nil {
+			return err
+		}
+		index.History = append(index.History, pdiffEntry{Name: name, Checksum: oldSum})
+
+		err = file.parent.publishedStorage.MkDir(filepath.Join(file.parent.basePath, diffDir))
+		if err != nil {
+			return err
+		}
+
+		err = file.parent.publishedStorage.PutFile(filepath.Join(file.parent.basePath, diffDir, name+".gz"), patchFile.Name()+".gz")
+		if err != nil {
+			return err
+		}
+
+		for len(index.History) > maxPDiffHistory {
+			expired := index.History[0]
+			index.History = index.History[1:]
+			delete(index.Patches, expired.Name)
+			delete(index.Download, expired.Name+".gz")
+
+			err = file.parent.publishedStorage.Remove(filepath.Join(file.parent.basePath, diffDir, expired.Name+".gz"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(index.History) == 0 {
+		return nil
+	}
+
+	indexFile, err = os.Create(file.tempFilename + ".diff-Index")
+	if err != nil {
+		return err
+	}
+
+	_, err = index.WriteTo(indexFile)
+	indexFile.Close()
+	if err != nil {
+		return err
+	}
+
+	indexSum, err := utils.ChecksumsForFile(indexFile.Name())
+	if err != nil {
+		return err
+	}
+	file.parent.addGeneratedFile(diffDir+"/Index", indexSum)
+
+	err = file.parent.publishedStorage.PutFile(filepath.Join(file.parent.basePath, diffDir, "Index"+file.parent.suffix), indexFile.Name())
+	if err != nil {
+		return err
+	}
+
+	if file.parent.suffix != "" {
+		file.parent.addRename(filepath.Join(file.parent.basePath, diffDir, "Index"+file.parent.suffix),
+			filepath.Join(file.parent.basePath, diffDir, "Index"))
+	}
+
+	return nil
+}
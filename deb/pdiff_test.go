@@ -0,0 +1,318 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type PDiffSuite struct {
+	repo       *RemoteRepo
+	downloader *http.FakeDownloader
+}
+
+var _ = Suite(&PDiffSuite{})
+
+func (s *PDiffSuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{"i386"}, false, false, false)
+	s.repo.UsePDiffs = true
+	s.repo.SetIndexCache(c.MkDir())
+	s.downloader = http.NewFakeDownloader()
+}
+
+func checksum(data string) utils.ChecksumInfo {
+	result, _ := utils.ChecksumsForReader(strings.NewReader(data))
+	return result
+}
+
+func gzipped(data string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.String()
+}
+
+func splitLines(data string) [][]byte {
+	result := [][]byte{}
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+		result = append(result, []byte(line))
+	}
+	return result
+}
+
+func joinLines(lines [][]byte) string {
+	return string(bytes.Join(lines, []byte("\n"))) + "\n"
+}
+
+func (s *PDiffSuite) TestApplyEdPatch(c *C) {
+	lines, err := applyEdPatch(splitLines("a\nb\nc\nd\ne\n"), strings.NewReader("5c\nE\n.\n3,4d\n1a\na1\na2\n.\n0a\nfirst\n.\n"))
+	c.Assert(err, IsNil)
+	c.Check(joinLines(lines), Equals, "first\na\na1\na2\nb\nE\n")
+
+	lines, err = applyEdPatch(splitLines("a\n"), strings.NewReader("1a\n..\n.\ns/.//\na\nb\n.\n"))
+	c.Assert(err, IsNil)
+	c.Check(joinLines(lines), Equals, "a\n.\nb\n")
+
+	_, err = applyEdPatch(splitLines("a\n"), strings.NewReader("3d\n"))
+	c.Check(err, ErrorMatches, "invalid address in ed command \"3d\"")
+
+	_, err = applyEdPatch(splitLines("a\n"), strings.NewReader("1a\nb\n"))
+	c.Check(err, ErrorMatches, "unterminated text for ed command \"1a\"")
+
+	_, err = applyEdPatch(splitLines("a\n"), strings.NewReader("1w\n"))
+	c.Check(err, ErrorMatches, "unsupported ed command \"1w\"")
+}
+
+func (s *PDiffSuite) TestParsePDiffIndex(c *C) {
+	index, err := parsePDiffIndex(strings.NewReader(`SHA256-Current: cccc 300
+SHA256-History:
+ aaaa 100 T-2024-01-01-0000.00-F-2023-12-31-0000.00
+ bbbb 200 T-2024-01-02-0000.00-F-2024-01-01-0000.00
+SHA256-Patches:
+ 1111 10 T-2024-01-01-0000.00-F-2023-12-31-0000.00
+ 2222 20 T-2024-01-02-0000.00-F-2024-01-01-0000.00
+SHA256-Download:
+ 3333 15 T-2024-01-01-0000.00-F-2023-12-31-0000.00.gz
+ 4444 25 T-2024-01-02-0000.00-F-2024-01-01-0000.00.gz
+`))
+	c.Assert(err, IsNil)
+	c.Check(index.Current, DeepEquals, utils.ChecksumInfo{SHA256: "cccc", Size: 300})
+	c.Check(index.History, HasLen, 2)
+	c.Check(index.Download["T-2024-01-02-0000.00-F-2024-01-01-0000.00.gz"], DeepEquals, utils.ChecksumInfo{SHA256: "4444", Size: 25})
+	c.Check(index.Merged, Equals, false)
+
+	patches, err := index.PatchesFrom("aaaa")
+	c.Check(err, IsNil)
+	c.Check(patches, DeepEquals, []string{"T-2024-01-01-0000.00-F-2023-12-31-0000.00", "T-2024-01-02-0000.00-F-2024-01-01-0000.00"})
+
+	patches, err = index.PatchesFrom("bbbb")
+	c.Check(err, IsNil)
+	c.Check(patches, DeepEquals, []string{"T-2024-01-02-0000.00-F-2024-01-01-0000.00"})
+
+	_, err = index.PatchesFrom("dddd")
+	c.Check(err, ErrorMatches, "patch chain is broken.*")
+
+	index.Merged = true
+	patches, err = index.PatchesFrom("aaaa")
+	c.Check(err, IsNil)
+	c.Check(patches, DeepEquals, []string{"T-2024-01-01-0000.00-F-2023-12-31-0000.00"})
+
+	_, err = parsePDiffIndex(strings.NewReader("SHA1-Current: aaaa 100\n"))
+	c.Check(err, ErrorMatches, "pdiff index doesn't contain SHA256-Current")
+}
+
+func (s *PDiffSuite) TestPatchCachedIndex(c *C) {
+	const path = "main/binary-i386/Packages"
+	baseURL := "http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/"
+
+	oldIndex := "Package: a\nVersion: 1\n\nPackage: b\nVersion: 1\n"
+	midIndex := "Package: a\nVersion: 2\n\nPackage: b\nVersion: 1\n"
+	newIndex := "Package: a\nVersion: 2\n\nPackage: b\nVersion: 1\n\nPackage: c\nVersion: 1\n"
+
+	patch1 := gzipped("2c\nVersion: 2\n.\n")
+	patch2 := gzipped("5a\n\nPackage: c\nVersion: 1\n.\n")
+
+	index := fmt.Sprintf("SHA256-Current: %s %d\nSHA256-History:\n %s %d p1\n %s %d p2\nSHA256-Download:\n %s %d p1.gz\n %s %d p2.gz\n",
+		checksum(newIndex).SHA256, len(newIndex),
+		checksum(oldIndex).SHA256, len(oldIndex), checksum(midIndex).SHA256, len(midIndex),
+		checksum(patch1).SHA256, len(patch1), checksum(patch2).SHA256, len(patch2))
+
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		path:                 checksum(newIndex),
+		path + ".diff/Index": checksum(index),
+	}
+
+	// no cached copy
+	file, err := s.repo.patchCachedIndex(s.downloader, path)
+	c.Check(err, IsNil)
+	c.Check(file, IsNil)
+
+	cached, err := s.repo.writeCachedIndex(path, strings.NewReader(oldIndex), nil)
+	c.Assert(err, IsNil)
+	cached.Close()
+
+	s.downloader.ExpectResponse(baseURL+"Index", index)
+	s.downloader.ExpectResponse(baseURL+"p1.gz", patch1)
+	s.downloader.ExpectResponse(baseURL+"p2.gz", patch2)
+
+	file, err = s.repo.patchCachedIndex(s.downloader, path)
+	c.Assert(err, IsNil)
+	c.Assert(file, NotNil)
+	c.Check(s.downloader.Empty(), Equals, true)
+	file.Close()
+
+	contents, _ := os.ReadFile(filepath.Join(s.repo.indexCacheDir, path))
+	c.Check(string(contents), Equals, newIndex)
+
+	// cached copy is up to date
+	file, err = s.repo.patchCachedIndex(s.downloader, path)
+	c.Assert(err, IsNil)
+	c.Assert(file, NotNil)
+	file.Close()
+
+	// broken patch chain
+	os.WriteFile(filepath.Join(s.repo.indexCacheDir, path), []byte("Package: z\n"), 0644)
+	s.downloader.ExpectResponse(baseURL+"Index", index)
+
+	_, err = s.repo.patchCachedIndex(s.downloader, path)
+	c.Check(err, ErrorMatches, "patch chain is broken.*")
+
+	// pdiff index doesn't match Release file
+	os.WriteFile(filepath.Join(s.repo.indexCacheDir, path), []byte(midIndex), 0644)
+	s.repo.ReleaseFiles[path] = checksum(newIndex + "\n")
+	s.downloader.ExpectResponse(baseURL+"Index", index)
+
+	_, err = s.repo.patchCachedIndex(s.downloader, path)
+	c.Check(err, ErrorMatches, "pdiff index for .* is out of sync with Release file")
+}
+
+func (s *PDiffSuite) TestDownloadPackageIndexesKeepsCache(c *C) {
+	const path = "main/binary-i386/Packages"
+
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		path: checksum(examplePackagesFile),
+	}
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err := s.repo.DownloadPackageIndexes(nil, s.downloader, nil, nil, true, false)
+	c.Assert(err, IsNil)
+	c.Check(s.downloader.Empty(), Equals, true)
+	numPackages := s.repo.packageList.Len()
+	c.Check(numPackages, Not(Equals), 0)
+
+	contents, _ := os.ReadFile(filepath.Join(s.repo.indexCacheDir, path))
+	c.Check(string(contents), Equals, examplePackagesFile)
+
+	// index hasn't changed, cached copy is used
+	s.repo.packageList = nil
+	err = s.repo.DownloadPackageIndexes(nil, s.downloader, nil, nil, true, false)
+	c.Assert(err, IsNil)
+	c.Check(s.repo.packageList.Len(), Equals, numPackages)
+}
+
+func (s *PDiffSuite) TestDiffLines(c *C) {
+	for _, t := range []struct{ a, b string }{
+		{"a\nb\nc\n", "a\nb\nc\n"},
+		{"a\nb\nc\n", "a\nc\n"},
+		{"a\nb\nc\n", "x\na\nb\ny\nc\nz\n"},
+		{"a\nb\nc\nd\ne\n", "e\nd\nc\nb\na\n"},
+		{"Package: a\nVersion: 1\n\nPackage: b\nVersion: 1\n", "Package: a\nVersion: 2\n\nPackage: c\nVersion: 1\n\nPackage: b\nVersion: 1\n"},
+	} {
+		a, b := splitLines(t.a), splitLines(t.b)
+
+		var patch bytes.Buffer
+		c.Assert(writeEdPatch(&patch, diffLines(a, b), b), IsNil)
+
+		result, err := applyEdPatch(a, &patch)
+		c.Assert(err, IsNil)
+		c.Check(joinLines(result), Equals, t.b)
+	}
+
+	b := splitLines("a\n.\n")
+	var patch bytes.Buffer
+	c.Check(writeEdPatch(&patch, diffLines(splitLines("a\n"), b), b), ErrorMatches, "unable to generate ed patch .*")
+}
+
+func (s *PDiffSuite) TestWritePDiffIndex(c *C) {
+	index := &pdiffIndex{
+		Current: utils.ChecksumInfo{SHA256: "cccc", Size: 300},
+		History: []pdiffEntry{
+			{Name: "2024-01-01-0000.00", Checksum: utils.ChecksumInfo{SHA256: "aaaa", Size: 100}},
+			{Name: "2024-01-02-0000.00", Checksum: utils.ChecksumInfo{SHA256: "bbbb", Size: 200}},
+		},
+		Patches: map[string]utils.ChecksumInfo{
+			"2024-01-01-0000.00": {SHA256: "1111", Size: 10},
+			"2024-01-02-0000.00": {SHA256: "2222", Size: 20},
+		},
+		Download: map[string]utils.ChecksumInfo{
+			"2024-01-01-0000.00.gz": {SHA256: "3333", Size: 15},
+			"2024-01-02-0000.00.gz": {SHA256: "4444", Size: 25},
+		},
+	}
+
+	var buf bytes.Buffer
+	_, err := index.WriteTo(&buf)
+	c.Assert(err, IsNil)
+
+	parsed, err := parsePDiffIndex(&buf)
+	c.Assert(err, IsNil)
+	c.Check(parsed, DeepEquals, index)
+}
+
+func (s *PDiffSuite) TestPublishPDiff(c *C) {
+	root := c.MkDir()
+	storage := files.NewPublishedStorage(root, "", "")
+
+	oldIndex := "Package: a\nVersion: 1\n\nPackage: b\nVersion: 1\n"
+	newIndex := "Package: a\nVersion: 2\n\nPackage: b\nVersion: 1\n"
+
+	publish := func(contents string) {
+		indexes := newIndexFiles(storage, "dists/squeeze", c.MkDir(), "", false, false, true)
+		w, err := indexes.PackageIndex("main", "i386", false, false, "squeeze").BufWriter()
+		c.Assert(err, IsNil)
+		w.WriteString(contents)
+		c.Assert(indexes.FinalizeAll(nil, nil), IsNil)
+	}
+
+	publish(oldIndex)
+	c.Check(filepath.Join(root, "dists/squeeze/main/binary-i386/Packages.diff"), Not(PathExists))
+
+	publish(newIndex)
+
+	indexFile, err := os.Open(filepath.Join(root, "dists/squeeze/main/binary-i386/Packages.diff/Index"))
+	c.Assert(err, IsNil)
+	defer indexFile.Close()
+
+	index, err := parsePDiffIndex(indexFile)
+	c.Assert(err, IsNil)
+	c.Check(index.Current.SHA256, Equals, checksum(newIndex).SHA256)
+	c.Assert(index.History, HasLen, 1)
+	c.Check(index.History[0].Checksum.SHA256, Equals, checksum(oldIndex).SHA256)
+
+	patchFile, err := os.Open(filepath.Join(root, "dists/squeeze/main/binary-i386/Packages.diff", index.History[0].Name+".gz"))
+	c.Assert(err, IsNil)
+	defer patchFile.Close()
+
+	patch, err := gzip.NewReader(patchFile)
+	c.Assert(err, IsNil)
+
+	result, err := applyEdPatch(splitLines(oldIndex), patch)
+	c.Assert(err, IsNil)
+	c.Check(joinLines(result), Equals, newIndex)
+
+	// publishing again right away doesn't collide with previous pdiff
+	publish(oldIndex)
+
+	indexFile2, err := os.Open(filepath.Join(root, "dists/squeeze/main/binary-i386/Packages.diff/Index"))
+	c.Assert(err, IsNil)
+	defer indexFile2.Close()
+
+	index, err = parsePDiffIndex(indexFile2)
+	c.Assert(err, IsNil)
+	c.Assert(index.History, HasLen, 2)
+	c.Check(index.History[1].Name, Not(Equals), index.History[0].Name)
+	c.Check(filepath.Join(root, "dists/squeeze/main/binary-i386/Packages.diff", index.History[1].Name+".gz"), PathExists)
+}
+
+func (s *PDiffSuite) TestNewPDiffName(c *C) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	index := &pdiffIndex{}
+
+	c.Check(index.newPDiffName(now), Equals, "2024-01-02-0304.05")
+
+	index.History = []pdiffEntry{{Name: "2024-01-02-0304.05"}, {Name: "2024-01-02-0304.05.1"}}
+	c.Check(index.newPDiffName(now), Equals, "2024-01-02-0304.05.2")
+	c.Check(index.newPDiffName(now.Add(time.Second)), Equals, "2024-01-02-0304.06")
+}
@@ -0,0 +1,70 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxReportedViolations limits number of packages listed in validation error
+const maxReportedViolations = 10
+
+// ValidationViolation lists packages which don't match validation query
+type ValidationViolation struct {
+	Query    string
+	Packages []string
+}
+
+// ValidationError is returned when packages don't pass validation before promotion
+type ValidationError struct {
+	Violations []ValidationViolation
+}
+
+// Error returns human-readable description of violations
+func (e *ValidationError) Error() string {
+	var parts []string
+
+	for _, violation := range e.Violations {
+		packages := violation.Packages
+		more := ""
+		if len(packages) > maxReportedViolations {
+			more = fmt.Sprintf(" and %d more", len(packages)-maxReportedViolations)
+			packages = packages[:maxReportedViolations]
+		}
+
+		parts = append(parts, fmt.Sprintf("%s: %s%s", violation.Query, strings.Join(packages, ", "), more))
+	}
+
+	return fmt.Sprintf("packages failed validation: %s", strings.Join(parts, "; "))
+}
+
+// ValidatePackages checks that every package in the list matches every query
+//
+// Queries describe what is required from packages being promoted, e.g.
+// `!$Version (% *~rc*)` rejects release candidates; *ValidationError is returned
+// listing packages which don't match.
+func ValidatePackages(list *PackageList, queries []PackageQuery) error {
+	var violations []ValidationViolation
+
+	list.PrepareIndex()
+
+	for _, q := range queries {
+		violation := ValidationViolation{Query: q.String()}
+
+		_ = list.ForEachIndexed(func(p *Package) error {
+			if !q.Matches(p) {
+				violation.Packages = append(violation.Packages, p.String())
+			}
+			return nil
+		})
+
+		if len(violation.Packages) > 0 {
+			violations = append(violations, violation)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+
+	return nil
+}
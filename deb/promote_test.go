@@ -0,0 +1,32 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type PromoteSuite struct{}
+
+var _ = Suite(&PromoteSuite{})
+
+func (s *PromoteSuite) TestValidatePackages(c *C) {
+	list := NewPackageList()
+
+	for _, version := range []string{"1.0-1", "1.1~rc1-1", "1.2~rc2-1"} {
+		stanza := packageStanza.Copy()
+		stanza["Version"] = version
+		c.Assert(list.Add(NewPackageFromControlFile(stanza)), IsNil)
+	}
+
+	noRC := &NotQuery{Q: &FieldQuery{Field: "$Version", Relation: VersionPatternMatch, Value: "*~rc*"}}
+	contrib := &FieldQuery{Field: "Section", Relation: VersionPatternMatch, Value: "contrib/*"}
+
+	c.Check(ValidatePackages(list, nil), IsNil)
+	c.Check(ValidatePackages(list, []PackageQuery{contrib}), IsNil)
+
+	err := ValidatePackages(list, []PackageQuery{contrib, noRC})
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Check(err.(*ValidationError).Violations, DeepEquals, []ValidationViolation{
+		{Query: "!($Version (% *~rc*))", Packages: []string{"alien-arena-common_1.2~rc2-1_i386", "alien-arena-common_1.1~rc1-1_i386"}},
+	})
+	c.Check(err, ErrorMatches, "packages failed validation: !\\(\\$Version \\(% \\*~rc\\*\\)\\): alien-arena-common_1.2~rc2-1_i386, alien-arena-common_1.1~rc1-1_i386")
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -30,6 +31,20 @@ type repoSourceItem struct {
 	packageRefs *PackageRefList
 }
 
+// Modes of handling Architecture: all packages when publishing
+const (
+	// ArchitectureAllPerArch includes Architecture: all packages into every per-architecture index
+	ArchitectureAllPerArch = "per-arch"
+	// ArchitectureAllSeparate publishes Architecture: all packages only in binary-all index
+	ArchitectureAllSeparate = "separate"
+	// ArchitectureAllBoth publishes Architecture: all packages in binary-all index and in every
+	// per-architecture index, marking Release with No-Support-for-Architecture-all
+	ArchitectureAllBoth = "both"
+)
+
+// ArchitectureAllModes lists all valid values for PublishedRepo.ArchitectureAllMode
+var ArchitectureAllModes = []string{ArchitectureAllPerArch, ArchitectureAllSeparate, ArchitectureAllBoth}
+
 // PublishedRepo is a published for http/ftp representation of snapshot as Debian repository
 type PublishedRepo struct {
 	// Internal unique ID
@@ -70,8 +85,104 @@ type PublishedRepo struct {
 
 	// Provide index files per hash also
 	AcquireByHash bool
+
+	// Generate pdiffs (Packages.diff) when re-publishing
+	PDiffs bool `codec:",omitempty"`
+
+	// Write fields of packages in indexes in the order used by Debian archive tools
+	DebianFieldOrder bool `codec:",omitempty"`
+
+	// How Architecture: all packages are published, empty means ArchitectureAllPerArch
+	ArchitectureAllMode string `codec:",omitempty"`
+
+	// IncludeArchitectures are published even if there are no packages for them (with empty indexes)
+	IncludeArchitectures []string `codec:",omitempty"`
+	// ExcludeArchitectures are never published, even if there are packages for them
+	ExcludeArchitectures []string `codec:",omitempty"`
+
+	// Description is free-form operator's description of published repository
+	Description string `codec:",omitempty"`
+	// Date of creation
+	CreatedAt time.Time `codec:",omitempty"`
+	// Provenance is free-form record of what published repository was built from
+	Provenance string `codec:",omitempty"`
+
+	// ValidFor is validity period of Release file, stamped as Valid-Until (0 means no expiry)
+	ValidFor time.Duration `codec:",omitempty"`
+	// ReleasedAt is date of the last generation of Release file
+	ReleasedAt time.Time `codec:",omitempty"`
+	// ReleaseFiles are checksums of index files listed in Release file, kept to
+	// re-generate Release file without re-publishing package indexes
+	ReleaseFiles map[string]utils.ChecksumInfo `codec:",omitempty"`
+
+	// ReleaseFields are custom fields added to Release file verbatim (overriding generated values)
+	ReleaseFields map[string]string `codec:",omitempty"`
+
+	// Overrides replace Priority, Section, Maintainer and other fields of binary packages in published indexes
+	Overrides OverrideTable `codec:",omitempty"`
+	// SourceOverrides replace Priority, Section and Maintainer of source packages in Sources indexes
+	SourceOverrides OverrideTable `codec:",omitempty"`
+
+	// PublishKey enables publishing of public signing key next to Release file
+	PublishKey bool `codec:",omitempty"`
+	// PublicURL is URL published repository is served from, if set client configuration
+	// (.sources and .list) is published next to Release file
+	PublicURL string `codec:",omitempty"`
+
+	// Aliases are additional distributions published repository is available under, index files
+	// are shared with the distribution, while Release file is generated for every alias
+	Aliases []string `codec:",omitempty"`
+	// aliases which are no longer used, removed on next publish
+	removedAliases []string
+
+	// Replicas are additional published storages repository is published to (with the same prefix)
+	Replicas []string `codec:",omitempty"`
+	// FailedReplicas are replicas which failed to be updated during last publish
+	FailedReplicas []string `codec:",omitempty"`
+
+	// ComponentRules route packages matching queries into components on publish
+	ComponentRules []ComponentRule `codec:",omitempty"`
+	// package refs published in every component after routing by component rules
+	componentRefs map[string]*PackageRefList
+}
+
+// generatedReleaseFields are fields of Release file which are always generated by aptly
+var generatedReleaseFields = []string{"Date", "Valid-Until", "Architectures", "Components", "MD5Sum", "SHA1", "SHA256", "SHA512"}
+
+// ValidateReleaseFields checks that custom Release fields could be added to Release file
+func ValidateReleaseFields(fields map[string]string) error {
+	for name, value := range fields {
+		if name == "" || strings.ContainsAny(name, ": \t\n") {
+			return fmt.Errorf("invalid name of Release file field: %q", name)
+		}
+
+		for _, generated := range generatedReleaseFields {
+			if strings.EqualFold(name, generated) {
+				return fmt.Errorf("field %s of Release file is generated by aptly and can't be overridden", name)
+			}
+		}
+
+		if strings.ContainsAny(value, "\n") {
+			return fmt.Errorf("value of field %s of Release file should be single line", name)
+		}
+	}
+
+	return nil
+}
+
+// publishesPackageForArchitecture checks whether package should be published in the index
+// for the architecture, taking into account ArchitectureAllMode
+func (p *PublishedRepo) publishesPackageForArchitecture(pkg *Package, arch string) bool {
+	if p.ArchitectureAllMode == ArchitectureAllSeparate && pkg.Architecture == ArchitectureAll {
+		return arch == ArchitectureAll
+	}
+
+	return pkg.MatchesArchitecture(arch)
 }
 
+// releaseDateFormat is format of Date and Valid-Until fields in Release file
+const releaseDateFormat = "Mon, 2 Jan 2006 15:04:05 MST"
+
 // ParsePrefix splits [storage:]prefix into components
 func ParsePrefix(param string) (storage, prefix string) {
 	i := strings.LastIndex(param, ":")
@@ -105,21 +216,21 @@ func walkUpTree(source interface{}, collectionFactory *CollectionFactory) (rootD
 		head, current = current[0], current[1:]
 
 		if snapshot, ok := head.(*Snapshot); ok {
-			for _, uuid := range snapshot.SourceIDs {
-				if snapshot.SourceKind == SourceRemoteRepo {
-					remoteRepo, err := collectionFactory.RemoteRepoCollection().ByUUID(uuid)
+			for _, source := range snapshot.Sources() {
+				if source.Kind == SourceRemoteRepo {
+					remoteRepo, err := collectionFactory.RemoteRepoCollection().ByUUID(source.ID)
 					if err != nil {
 						continue
 					}
 					current = append(current, remoteRepo)
-				} else if snapshot.SourceKind == SourceLocalRepo {
-					localRepo, err := collectionFactory.LocalRepoCollection().ByUUID(uuid)
+				} else if source.Kind == SourceLocalRepo {
+					localRepo, err := collectionFactory.LocalRepoCollection().ByUUID(source.ID)
 					if err != nil {
 						continue
 					}
 					current = append(current, localRepo)
-				} else if snapshot.SourceKind == SourceSnapshot {
-					snap, err := collectionFactory.SnapshotCollection().ByUUID(uuid)
+				} else if source.Kind == SourceSnapshot {
+					snap, err := collectionFactory.SnapshotCollection().ByUUID(source.ID)
 					if err != nil {
 						continue
 					}
@@ -156,6 +267,7 @@ func NewPublishedRepo(storage, prefix, distribution string, architectures []stri
 	components []string, sources []interface{}, collectionFactory *CollectionFactory) (*PublishedRepo, error) {
 	result := &PublishedRepo{
 		UUID:          uuid.New(),
+		CreatedAt:     time.Now(),
 		Storage:       storage,
 		Architectures: architectures,
 		Sources:       make(map[string]string),
@@ -315,6 +427,21 @@ func (p *PublishedRepo) MarshalJSON() ([]byte, error) {
 		"Storage":              p.Storage,
 		"SkipContents":         p.SkipContents,
 		"AcquireByHash":        p.AcquireByHash,
+		"DebianFieldOrder":     p.DebianFieldOrder,
+		"Description":          p.Description,
+		"CreatedAt":            p.CreatedAt,
+		"Provenance":           p.Provenance,
+		"ValidFor":             p.ValidFor.String(),
+		"ValidUntil":           p.ValidUntil(),
+		"ReleaseFields":        p.ReleaseFields,
+		"Overrides":            p.Overrides,
+		"SourceOverrides":      p.SourceOverrides,
+		"PublishKey":           p.PublishKey,
+		"PublicURL":            p.PublicURL,
+		"Aliases":              p.Aliases,
+		"Replicas":             p.Replicas,
+		"FailedReplicas":       p.FailedReplicas,
+		"ComponentRules":       p.ComponentRules,
 	})
 }
 
@@ -322,7 +449,7 @@ func (p *PublishedRepo) MarshalJSON() ([]byte, error) {
 func (p *PublishedRepo) String() string {
 	var sources = []string{}
 
-	for _, component := range p.Components() {
+	for _, component := range p.SourceComponents() {
 		var source string
 
 		item := p.sourceItems[component]
@@ -364,6 +491,10 @@ func (p *PublishedRepo) String() string {
 		extras = append(extras, fmt.Sprintf("codename: %s", p.Codename))
 	}
 
+	if len(p.Aliases) > 0 {
+		extras = append(extras, fmt.Sprintf("aliases: %s", strings.Join(p.Aliases, " ")))
+	}
+
 	extra = strings.Join(extras, ", ")
 
 	if extra != "" {
@@ -388,13 +519,34 @@ func (p *PublishedRepo) Key() []byte {
 	return []byte("U" + p.StoragePrefix() + ">>" + p.Distribution)
 }
 
+// auditName identifies published repository in audit log
+func (p *PublishedRepo) auditName() string {
+	return p.StoragePrefix() + "/" + p.Distribution
+}
+
 // RefKey is a unique id for package reference list
 func (p *PublishedRepo) RefKey(component string) []byte {
 	return []byte("E" + p.UUID + component)
 }
 
-// RefList returns list of package refs in local repo
+// RefList returns list of package refs published in component
 func (p *PublishedRepo) RefList(component string) *PackageRefList {
+	if p.componentRefs != nil {
+		if refs := p.componentRefs[component]; refs != nil {
+			return refs
+		}
+		return NewPackageRefList()
+	}
+
+	if _, ok := p.sourceItems[component]; !ok {
+		return NewPackageRefList()
+	}
+
+	return p.sourceRefList(component)
+}
+
+// sourceRefList returns list of package refs of the source of component
+func (p *PublishedRepo) sourceRefList(component string) *PackageRefList {
 	item := p.sourceItems[component]
 	if p.SourceKind == SourceLocalRepo {
 		return item.packageRefs
@@ -405,22 +557,23 @@ func (p *PublishedRepo) RefList(component string) *PackageRefList {
 	panic("unknown source")
 }
 
-// Components returns sorted list of published repo components
+// Components returns sorted list of published repo components, including
+// components filled by component rules
 func (p *PublishedRepo) Components() []string {
-	result := make([]string, 0, len(p.Sources))
-	for component := range p.Sources {
-		result = append(result, component)
+	result := p.SourceComponents()
+	for _, rule := range p.ComponentRules {
+		result = append(result, rule.Component)
 	}
 
 	sort.Strings(result)
-	return result
+	return utils.StrSliceDeduplicate(result)
 }
 
 // Components returns sorted list of published repo source names
 func (p *PublishedRepo) SourceNames() []string {
 	var sources = []string{}
 
-	for _, component := range p.Components() {
+	for _, component := range p.SourceComponents() {
 		var source string
 
 		item := p.sourceItems[component]
@@ -543,9 +696,32 @@ func (p *PublishedRepo) GetCodename() string {
 }
 
 // Publish publishes snapshot (repository) contents, links package files, generates Packages & Release files, signs them
+//
+// Published repository is published to its storage first, and then to every replica. If publishing
+// to replicas fails, *ReplicaPublishError is returned: published repository is updated in its
+// storage and should be saved, failed replicas are listed in FailedReplicas.
 func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
 	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite, multiDist bool) error {
-	publishedStorage := publishedStorageProvider.GetPublishedStorage(p.Storage)
+	removedAliases := p.removedAliases
+
+	err := p.publishToStorage(p.Storage, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite, multiDist)
+	if err != nil {
+		return err
+	}
+
+	if len(p.Replicas) == 0 {
+		p.FailedReplicas = nil
+		return nil
+	}
+
+	p.removedAliases = removedAliases
+	return p.publishReplicas(p.Replicas, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite, multiDist)
+}
+
+// publishToStorage publishes repository to the published storage
+func (p *PublishedRepo) publishToStorage(storage string, packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
+	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite, multiDist bool) error {
+	publishedStorage := publishedStorageProvider.GetPublishedStorage(storage)
 
 	err := publishedStorage.MkDir(filepath.Join(p.Prefix, "pool"))
 	if err != nil {
@@ -580,12 +756,21 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 
 	for component := range p.sourceItems {
 		// Load all packages
-		lists[component], err = NewPackageListFromRefList(p.RefList(component), collectionFactory.PackageCollection(), progress)
+		lists[component], err = NewPackageListFromRefList(p.sourceRefList(component), collectionFactory.PackageCollection(), progress)
 		if err != nil {
 			return fmt.Errorf("unable to load packages: %s", err)
 		}
 	}
 
+	if len(p.ComponentRules) > 0 {
+		lists, err = p.splitComponents(lists, collectionFactory)
+		if err != nil {
+			return err
+		}
+	} else {
+		p.componentRefs = nil
+	}
+
 	if !p.rePublishing {
 		if len(p.Architectures) == 0 {
 			for _, list := range lists {
@@ -593,14 +778,33 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 			}
 		}
 
-		if len(p.Architectures) == 0 {
-			return fmt.Errorf("unable to figure out list of architectures, please supply explicit list")
+		for _, arch := range p.IncludeArchitectures {
+			if utils.StrSliceHasItem(p.ExcludeArchitectures, arch) {
+				return fmt.Errorf("architecture %s can't be both included and excluded", arch)
+			}
+		}
+
+		p.Architectures = append(p.Architectures, p.IncludeArchitectures...)
+
+		if p.ArchitectureAllMode == ArchitectureAllSeparate || p.ArchitectureAllMode == ArchitectureAllBoth {
+			p.Architectures = append(p.Architectures, ArchitectureAll)
 		}
 
 		sort.Strings(p.Architectures)
 		p.Architectures = utils.StrSliceDeduplicate(p.Architectures)
+
+		exclude := append([]string(nil), p.ExcludeArchitectures...)
+		sort.Strings(exclude)
+		p.Architectures = utils.StrSlicesSubstract(p.Architectures, exclude)
+
+		if len(p.Architectures) == 0 {
+			return fmt.Errorf("unable to figure out list of architectures, please supply explicit list")
+		}
 	}
 
+	// On re-publish index files are uploaded with temporary names and moved into place
+	// in the end (Release files last), package files are already in place by then. Storages
+	// which report checksums upload only files which have changed.
 	var suffix string
 	if p.rePublishing {
 		suffix = ".tmp"
@@ -617,9 +821,27 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 	}
 	defer os.RemoveAll(tempDir)
 
-	indexes := newIndexFiles(publishedStorage, basePath, tempDir, suffix, p.AcquireByHash, p.SkipBz2)
+	if p.ArchitectureAllMode != "" && !utils.StrSliceHasItem(ArchitectureAllModes, p.ArchitectureAllMode) {
+		return fmt.Errorf("unknown mode for architecture all: %s", p.ArchitectureAllMode)
+	}
+
+	if p.PDiffs {
+		if _, ok := publishedStorage.(aptly.FileSystemPublishedStorage); !ok {
+			return fmt.Errorf("pdiffs are supported only for filesystem published storage")
+		}
+	}
+
+	indexes := newIndexFiles(publishedStorage, basePath, tempDir, suffix, p.AcquireByHash, p.SkipBz2, p.PDiffs)
+	if p.rePublishing {
+		err = indexes.LoadPublishedChecksums()
+		if err != nil {
+			return err
+		}
+	}
 
 	legacyContentIndexes := map[string]*ContentsIndex{}
+	// files already linked from the pool during this publish
+	linkedFiles := map[string]bool{}
 	var count int64
 	for _, list := range lists {
 		count = count + int64(list.Len())
@@ -641,13 +863,19 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 
 		contentIndexes := map[string]*ContentsIndex{}
 
+		// stanzas are formatted and written to package indexes by one writer per architecture
+		writers := map[string]*indexWriter{}
+		for _, arch := range p.Architectures {
+			writers[arch] = newIndexWriter()
+		}
+
 		err = list.ForEachIndexed(func(pkg *Package) error {
 			if progress != nil {
 				progress.AddBar(1)
 			}
 
 			for _, arch := range p.Architectures {
-				if pkg.MatchesArchitecture(arch) {
+				if p.publishesPackageForArchitecture(pkg, arch) {
 					hadUdebs = hadUdebs || pkg.IsUdeb
 
 					var relPath string
@@ -670,7 +898,7 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 						}
 					}
 
-					err = pkg.LinkFromPool(publishedStorage, packagePool, p.Prefix, relPath, forceOverwrite)
+					err = pkg.LinkFromPool(publishedStorage, packagePool, p.Prefix, relPath, forceOverwrite, linkedFiles)
 					if err != nil {
 						return err
 					}
@@ -684,8 +912,10 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 			// amount of write() calls.
 			batch := tempDB.CreateBatch()
 
+			var stanza Stanza
+
 			for _, arch := range p.Architectures {
-				if pkg.MatchesArchitecture(arch) {
+				if p.publishesPackageForArchitecture(pkg, arch) {
 					var bufWriter *bufio.Writer
 
 					if !p.SkipContents && !pkg.IsInstaller {
@@ -710,14 +940,31 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 						return err
 					}
 
-					err = pkg.Stanza().WriteTo(bufWriter, pkg.IsSource, false, pkg.IsInstaller)
-					if err != nil {
-						return err
-					}
-					err = bufWriter.WriteByte('\n')
-					if err != nil {
-						return err
+					if stanza == nil {
+						stanza = pkg.Stanza()
+						if pkg.IsSource {
+							p.SourceOverrides.Apply(pkg.Name, stanza)
+						} else {
+							p.Overrides.Apply(pkg.Name, stanza)
+						}
 					}
+
+					// writing consumes the stanza, so every architecture gets a copy
+					archStanza := stanza.Copy()
+					isSource, isInstaller := pkg.IsSource, pkg.IsInstaller
+
+					writers[arch].Write(func() error {
+						var err error
+						if p.DebianFieldOrder {
+							err = archStanza.WriteInDebianOrderTo(bufWriter, isSource, isInstaller)
+						} else {
+							err = archStanza.WriteTo(bufWriter, isSource, false, isInstaller)
+						}
+						if err != nil {
+							return err
+						}
+						return bufWriter.WriteByte('\n')
+					})
 				}
 			}
 
@@ -729,6 +976,12 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 			return batch.Write()
 		})
 
+		for _, arch := range p.Architectures {
+			if err2 := writers[arch].Close(); err == nil {
+				err = err2
+			}
+		}
+
 		if err != nil {
 			return fmt.Errorf("unable to process packages: %s", err)
 		}
@@ -822,6 +1075,254 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		return err
 	}
 
+	p.ReleaseFiles = make(map[string]utils.ChecksumInfo, len(indexes.generatedFiles))
+	for path, info := range indexes.generatedFiles {
+		p.ReleaseFiles[path] = info
+	}
+
+	if p.PublishKey {
+		err = p.writeKeyFiles(indexes, signer, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.PublicURL != "" {
+		err = p.writeSourcesFiles(indexes)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.writeSnapshotManifests(indexes, collectionFactory)
+	if err != nil {
+		return err
+	}
+
+	err = p.writeRelease(indexes, p.GetSuite(), signer, progress)
+	if err != nil {
+		return err
+	}
+
+	err = indexes.RenameFiles()
+	if err != nil {
+		return err
+	}
+
+	return p.publishAliases(publishedStorage, tempDir, signer, progress)
+}
+
+// Names of files published next to Release file with PublishKey enabled (key files)
+// or PublicURL set (client configuration files)
+const (
+	PublishedKeyArmored  = "repo-key.asc"
+	PublishedKeyBinary   = "repo-key.gpg"
+	PublishedSources     = "repo.sources"
+	PublishedSourcesList = "repo.list"
+)
+
+// PublishedSnapshotManifest returns name of file with manifest of signed snapshot published
+// as component, detached signature is published next to it with .asc suffix
+func PublishedSnapshotManifest(component string) string {
+	return fmt.Sprintf("snapshot-%s.manifest", strings.Replace(component, "/", "_", -1))
+}
+
+// ValidatePublicURL checks that URL published repository is served from is absolute http(s) URL
+func ValidatePublicURL(publicURL string) error {
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return fmt.Errorf("invalid public URL %s: %s", publicURL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid public URL %s: should be absolute http:// or https:// URL", publicURL)
+	}
+
+	return nil
+}
+
+// KeyringName returns name of the keyring (without extension) suggested for
+// installation of published key on client systems
+func (p *PublishedRepo) KeyringName() string {
+	name := p.Origin
+	if name == "" {
+		name = p.Distribution
+		if p.Prefix != "." {
+			name = p.Prefix + "-" + name
+		}
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+
+	return strings.Trim(name, "-.")
+}
+
+// sourcesTypes returns types of sources (deb, deb-src) available in published repository
+func (p *PublishedRepo) sourcesTypes() []string {
+	types := []string{"deb"}
+	if utils.StrSliceHasItem(p.Architectures, ArchitectureSource) {
+		types = append(types, "deb-src")
+	}
+
+	return types
+}
+
+// KeyringPath returns path to published key installed on client systems, used as Signed-By
+func (p *PublishedRepo) KeyringPath() string {
+	return "/etc/apt/keyrings/" + p.KeyringName() + ".gpg"
+}
+
+// sourcesComment returns installation instructions for client configuration file
+func (p *PublishedRepo) sourcesComment(baseURL, file, target string) string {
+	distURL := baseURL + "/dists/" + p.Distribution
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s %s\n", p.GetOrigin(), p.Distribution)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# To install, run as root:\n")
+	if p.PublishKey {
+		fmt.Fprintf(&b, "#   curl -fsSL %s/%s -o %s\n", distURL, PublishedKeyBinary, p.KeyringPath())
+	}
+	fmt.Fprintf(&b, "#   curl -fsSL %s/%s -o /etc/apt/sources.list.d/%s.%s\n", distURL, file, p.KeyringName(), target)
+
+	return b.String()
+}
+
+// SourcesEntry returns deb822 .sources client configuration for published repository
+// served from baseURL, with Signed-By pointing to installed published key
+func (p *PublishedRepo) SourcesEntry(baseURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var b strings.Builder
+	b.WriteString(p.sourcesComment(baseURL, PublishedSources, "sources"))
+	fmt.Fprintf(&b, "Types: %s\n", strings.Join(p.sourcesTypes(), " "))
+	fmt.Fprintf(&b, "URIs: %s\n", baseURL)
+	fmt.Fprintf(&b, "Suites: %s\n", p.Distribution)
+	fmt.Fprintf(&b, "Components: %s\n", strings.Join(p.Components(), " "))
+	fmt.Fprintf(&b, "Architectures: %s\n", strings.Join(utils.StrSlicesSubstract(p.Architectures, []string{ArchitectureSource}), " "))
+	fmt.Fprintf(&b, "Signed-By: %s\n", p.KeyringPath())
+
+	return b.String()
+}
+
+// SourcesListEntry returns one-line sources.list client configuration for published repository
+// served from baseURL, with signed-by pointing to installed published key
+func (p *PublishedRepo) SourcesListEntry(baseURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	components := strings.Join(p.Components(), " ")
+
+	var b strings.Builder
+	b.WriteString(p.sourcesComment(baseURL, PublishedSourcesList, "list"))
+	for _, typ := range p.sourcesTypes() {
+		options := "signed-by=" + p.KeyringPath()
+		if typ == "deb" {
+			options = "arch=" + strings.Join(utils.StrSlicesSubstract(p.Architectures, []string{ArchitectureSource}), ",") + " " + options
+		}
+		fmt.Fprintf(&b, "%s [%s] %s %s %s\n", typ, options, baseURL, p.Distribution, components)
+	}
+
+	return b.String()
+}
+
+// writePlainFile publishes file with given contents next to Release file
+func writePlainFile(indexes *indexFiles, name string, data []byte) error {
+	file := indexes.PlainFile(name)
+	bufWriter, err := file.BufWriter()
+	if err != nil {
+		return err
+	}
+
+	_, err = bufWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %s", name, err)
+	}
+
+	return file.Finalize(nil)
+}
+
+// writeKeyFiles publishes public signing key next to Release file
+func (p *PublishedRepo) writeKeyFiles(indexes *indexFiles, signer pgp.Signer, progress aptly.Progress) error {
+	if signer == nil {
+		if progress != nil {
+			progress.ColoredPrintf("@y[!]@| @!Publishing is not signed, signing key is not published@|")
+		}
+		return nil
+	}
+
+	exporter, ok := signer.(pgp.PublicKeyExporter)
+	if !ok {
+		return fmt.Errorf("unable to publish signing key: signer doesn't support exporting public key")
+	}
+
+	for name, armored := range map[string]bool{PublishedKeyArmored: true, PublishedKeyBinary: false} {
+		data, err := exporter.ExportPublicKey(armored)
+		if err != nil {
+			return fmt.Errorf("unable to publish signing key: %s", err)
+		}
+
+		err = writePlainFile(indexes, name, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSourcesFiles publishes client configuration (.sources and .list) next to Release file
+func (p *PublishedRepo) writeSourcesFiles(indexes *indexFiles) error {
+	err := writePlainFile(indexes, PublishedSources, []byte(p.SourcesEntry(p.PublicURL)))
+	if err != nil {
+		return err
+	}
+
+	return writePlainFile(indexes, PublishedSourcesList, []byte(p.SourcesListEntry(p.PublicURL)))
+}
+
+// writeSnapshotManifests publishes manifests of signed snapshots along with their signatures
+// next to Release file, so that clients could verify published repository matches attested snapshot
+func (p *PublishedRepo) writeSnapshotManifests(indexes *indexFiles, collectionFactory *CollectionFactory) error {
+	if p.SourceKind != SourceSnapshot {
+		return nil
+	}
+
+	for _, component := range p.SourceComponents() {
+		snapshot := p.sourceItems[component].snapshot
+		if snapshot.ManifestSignature == "" {
+			continue
+		}
+
+		manifest, err := snapshot.Manifest(collectionFactory.PackageCollection())
+		if err != nil {
+			return fmt.Errorf("unable to publish manifest of snapshot %s: %s", snapshot.Name, err)
+		}
+
+		name := PublishedSnapshotManifest(component)
+
+		err = writePlainFile(indexes, name, manifest)
+		if err != nil {
+			return err
+		}
+
+		err = writePlainFile(indexes, name+".asc", []byte(snapshot.ManifestSignature))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRelease generates top-level Release file listing index files from ReleaseFiles and signs it,
+// suite differs from the one of published repository for distribution aliases
+func (p *PublishedRepo) writeRelease(indexes *indexFiles, suite string, signer pgp.Signer, progress aptly.Progress) error {
+	now := time.Now().UTC()
+
 	release := make(Stanza)
 	release["Origin"] = p.GetOrigin()
 	if p.NotAutomatic != "" {
@@ -831,13 +1332,19 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		release["ButAutomaticUpgrades"] = p.ButAutomaticUpgrades
 	}
 	release["Label"] = p.GetLabel()
-	release["Suite"] = p.GetSuite()
+	release["Suite"] = suite
 	release["Codename"] = p.GetCodename()
-	release["Date"] = time.Now().UTC().Format("Mon, 2 Jan 2006 15:04:05 MST")
+	release["Date"] = now.Format(releaseDateFormat)
+	if p.ValidFor > 0 {
+		release["Valid-Until"] = now.Add(p.ValidFor).Format(releaseDateFormat)
+	}
 	release["Architectures"] = strings.Join(utils.StrSlicesSubstract(p.Architectures, []string{ArchitectureSource}), " ")
 	if p.AcquireByHash {
 		release["Acquire-By-Hash"] = "yes"
 	}
+	if p.ArchitectureAllMode == ArchitectureAllBoth {
+		release["No-Support-for-Architecture-all"] = "Packages"
+	}
 	release["Description"] = " Generated by aptly\n"
 	release["MD5Sum"] = ""
 	release["SHA1"] = ""
@@ -846,14 +1353,28 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 
 	release["Components"] = strings.Join(p.Components(), " ")
 
-	sortedPaths := make([]string, 0, len(indexes.generatedFiles))
-	for path := range indexes.generatedFiles {
+	for name, value := range p.ReleaseFields {
+		for existing := range release {
+			if strings.EqualFold(existing, name) {
+				delete(release, existing)
+			}
+		}
+
+		if name == "Description" {
+			// multiline field, value is written right after the colon
+			value = " " + value
+		}
+		release[name] = value
+	}
+
+	sortedPaths := make([]string, 0, len(p.ReleaseFiles))
+	for path := range p.ReleaseFiles {
 		sortedPaths = append(sortedPaths, path)
 	}
 	sort.Strings(sortedPaths)
 
 	for _, path := range sortedPaths {
-		info := indexes.generatedFiles[path]
+		info := p.ReleaseFiles[path]
 		release["MD5Sum"] += fmt.Sprintf(" %s %8d %s\n", info.MD5, info.Size, path)
 		release["SHA1"] += fmt.Sprintf(" %s %8d %s\n", info.SHA1, info.Size, path)
 		release["SHA256"] += fmt.Sprintf(" %s %8d %s\n", info.SHA256, info.Size, path)
@@ -881,7 +1402,96 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		return err
 	}
 
-	return indexes.RenameFiles()
+	p.ReleasedAt = now
+	return nil
+}
+
+// ValidUntil returns expiry date of published Release file, zero time if Release file doesn't expire
+func (p *PublishedRepo) ValidUntil() time.Time {
+	if p.ValidFor <= 0 || p.ReleasedAt.IsZero() {
+		return time.Time{}
+	}
+
+	return p.ReleasedAt.Add(p.ValidFor)
+}
+
+// RefreshDue returns true if Release file is expiring and is past half of its validity period
+func (p *PublishedRepo) RefreshDue(now time.Time) bool {
+	if p.ValidFor <= 0 {
+		return false
+	}
+
+	return !now.Before(p.ReleasedAt.Add(p.ValidFor / 2))
+}
+
+// RefreshRelease re-generates and re-signs Release files of published repository
+// with new Date and Valid-Until, package indexes are left intact
+//
+// Release files are refreshed in published storage first, and then in every replica except failed ones
+// (their index files are stale, so they are left for ResumeReplicas). If refreshing replicas fails,
+// *ReplicaPublishError is returned and replicas are added to FailedReplicas, as with Publish.
+func (p *PublishedRepo) RefreshRelease(publishedStorageProvider aptly.PublishedStorageProvider, signer pgp.Signer, progress aptly.Progress) error {
+	if len(p.ReleaseFiles) == 0 {
+		return fmt.Errorf("list of published index files is unknown, please re-publish first")
+	}
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "aptly")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	err = p.refreshReleaseInStorage(publishedStorageProvider.GetPublishedStorage(p.Storage), tempDir, signer, progress)
+	if err != nil {
+		return err
+	}
+
+	errors := map[string]error{}
+	for _, replica := range p.Replicas {
+		if utils.StrSliceHasItem(p.FailedReplicas, replica) {
+			continue
+		}
+
+		err = p.refreshReleaseInStorage(publishedStorageProvider.GetPublishedStorage(replica), tempDir, signer, progress)
+		if err != nil {
+			if progress != nil {
+				progress.ColoredPrintf("@rERROR@|: refreshing replica %s failed: %s", replica, err)
+			}
+
+			errors[replica] = err
+			p.FailedReplicas = append(p.FailedReplicas, replica)
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ReplicaPublishError{Errors: errors}
+	}
+
+	return nil
+}
+
+// refreshReleaseInStorage re-generates Release files of every distribution (and alias) in the published storage
+func (p *PublishedRepo) refreshReleaseInStorage(publishedStorage aptly.PublishedStorage, tempDir string, signer pgp.Signer, progress aptly.Progress) error {
+	for _, distribution := range p.Distributions() {
+		suite := distribution
+		if distribution == p.Distribution {
+			suite = p.GetSuite()
+		}
+
+		indexes := newIndexFiles(publishedStorage, filepath.Join(p.Prefix, "dists", distribution), tempDir, ".tmp", false, false, false)
+
+		err := p.writeRelease(indexes, suite, signer, progress)
+		if err != nil {
+			return err
+		}
+
+		err = indexes.RenameFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // RemoveFiles removes files that were created by Publish
@@ -889,7 +1499,12 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 // It can remove prefix fully, and part of pool (for specific component)
 func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStorageProvider, removePrefix bool,
 	removePoolComponents []string, progress aptly.Progress) error {
-	publishedStorage := publishedStorageProvider.GetPublishedStorage(p.Storage)
+	return p.removeFilesFromStorage(publishedStorageProvider.GetPublishedStorage(p.Storage), removePrefix, removePoolComponents, progress)
+}
+
+// removeFilesFromStorage removes files that were created by Publish in the published storage
+func (p *PublishedRepo) removeFilesFromStorage(publishedStorage aptly.PublishedStorage, removePrefix bool,
+	removePoolComponents []string, progress aptly.Progress) error {
 
 	// I. Easy: remove whole prefix (meta+packages)
 	if removePrefix {
@@ -902,14 +1517,16 @@ func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStor
 	}
 
 	// II. Medium: remove metadata, it can't be shared as prefix/distribution as unique
-	err := publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "dists", p.Distribution), progress)
-	if err != nil {
-		return err
+	for _, distribution := range p.Distributions() {
+		err := publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "dists", distribution), progress)
+		if err != nil {
+			return err
+		}
 	}
 
 	// III. Complex: there are no other publishes with the same prefix + component
 	for _, component := range removePoolComponents {
-		err = publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "pool", component), progress)
+		err := publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "pool", component), progress)
 		if err != nil {
 			return err
 		}
@@ -920,8 +1537,9 @@ func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStor
 
 // PublishedRepoCollection does listing, updating/adding/deleting of PublishedRepos
 type PublishedRepoCollection struct {
-	db   database.Storage
-	list []*PublishedRepo
+	db       database.Storage
+	list     []*PublishedRepo
+	recorder *AuditRecorder
 }
 
 // NewPublishedRepoCollection loads PublishedRepos from DB and makes up collection
@@ -963,6 +1581,7 @@ func (collection *PublishedRepoCollection) Add(repo *PublishedRepo) error {
 	}
 
 	collection.list = append(collection.list, repo)
+	collection.recorder.Record(AuditActionCreated, AuditKindPublished, repo.auditName(), repo.UUID)
 	return nil
 }
 
@@ -976,6 +1595,31 @@ func (collection *PublishedRepoCollection) CheckDuplicate(repo *PublishedRepo) *
 		}
 	}
 
+	return collection.CheckAliasDuplicate(repo)
+}
+
+// CheckAliasDuplicate verifies that distribution and aliases of published repo are not used
+// (as distribution or alias) by another published repo under the same prefix in any of the
+// storages (including replicas)
+func (collection *PublishedRepoCollection) CheckAliasDuplicate(repo *PublishedRepo) *PublishedRepo {
+	collection.loadList()
+
+	for _, r := range collection.list {
+		if r.UUID == repo.UUID || r.Prefix != repo.Prefix {
+			continue
+		}
+
+		if !r.sharesStorage(repo) {
+			continue
+		}
+
+		for _, distribution := range repo.Distributions() {
+			if utils.StrSliceHasItem(r.Distributions(), distribution) {
+				return r
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -989,7 +1633,15 @@ func (collection *PublishedRepoCollection) Update(repo *PublishedRepo) error {
 			batch.Put(repo.RefKey(component), item.packageRefs.Encode())
 		}
 	}
-	return batch.Write()
+
+	for component, refs := range repo.componentRefs {
+		batch.Put(repo.componentRefKey(component), refs.Encode())
+	}
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionUpdated, AuditKindPublished, repo.auditName(), repo.UUID)
+	}
+	return err
 }
 
 // LoadShallow loads basic information on the repo's sources
@@ -1069,6 +1721,31 @@ func (collection *PublishedRepoCollection) LoadComplete(repo *PublishedRepo, col
 		panic("unknown SourceKind")
 	}
 
+	repo.componentRefs = nil
+	if len(repo.ComponentRules) > 0 {
+		componentRefs := map[string]*PackageRefList{}
+
+		for _, component := range repo.Components() {
+			var encoded []byte
+			encoded, err = collection.db.Get(repo.componentRefKey(component))
+			if err != nil {
+				// component rules haven't been applied yet
+				if err == database.ErrNotFound {
+					err = nil
+				}
+				return
+			}
+
+			componentRefs[component] = &PackageRefList{}
+			err = componentRefs[component].Decode(encoded)
+			if err != nil {
+				return
+			}
+		}
+
+		repo.componentRefs = componentRefs
+	}
+
 	return
 }
 
@@ -1268,6 +1945,151 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(prefix st
 	return nil
 }
 
+// CleanupPublishedComponentFiles removes unreferenced files of components in the storage of
+// published repository and in its replicas
+//
+// Replicas which failed to be updated are skipped, as their indexes might still reference
+// files which are not published anymore.
+func (collection *PublishedRepoCollection) CleanupPublishedComponentFiles(published *PublishedRepo, components []string,
+	publishedStorageProvider aptly.PublishedStorageProvider, collectionFactory *CollectionFactory, progress aptly.Progress) error {
+	// packages of any component might be routed into components filled by component rules
+	if len(published.ComponentRules) > 0 {
+		components = published.Components()
+	}
+
+	for _, storage := range published.Storages() {
+		if utils.StrSliceHasItem(published.FailedReplicas, storage) {
+			continue
+		}
+
+		err := collection.CleanupPrefixComponentFiles(published.Prefix, components,
+			publishedStorageProvider.GetPublishedStorage(storage), collectionFactory, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CleanupPrefix removes files under prefix in published storage which are not referenced
+// by any published repository with that storage & prefix: package files in the pool which
+// are not published anymore and index files of dropped distributions or components
+//
+// List of removed files (relative to prefix) is returned, with dryRun files are only listed.
+func (collection *PublishedRepoCollection) CleanupPrefix(storage, prefix string, publishedStorage aptly.PublishedStorage,
+	collectionFactory *CollectionFactory, progress aptly.Progress, dryRun bool) ([]string, error) {
+
+	collection.loadList()
+
+	if progress != nil {
+		progress.Printf("Cleaning up prefix %#v...\n", prefix)
+	}
+
+	var repos []*PublishedRepo
+	referencedPool := map[string]bool{}
+
+	for _, r := range collection.list {
+		if r.Prefix != prefix || !utils.StrSliceHasItem(r.Storages(), storage) {
+			continue
+		}
+
+		if err := collection.LoadComplete(r, collectionFactory); err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+
+		for _, component := range r.Components() {
+			packageList, err := NewPackageListFromRefList(r.RefList(component), collectionFactory.PackageCollection(), progress)
+			if err != nil {
+				return nil, err
+			}
+
+			err = packageList.ForEach(func(p *Package) error {
+				poolDir, err := p.PoolDirectory()
+				if err != nil {
+					return err
+				}
+
+				for _, f := range p.Files() {
+					// it's not known whether repository was published with multi-dist layout, so both are kept
+					referencedPool[filepath.Join("pool", component, poolDir, f.Filename)] = true
+					referencedPool[filepath.Join("pool", r.Distribution, component, poolDir, f.Filename)] = true
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no published repositories under prefix %s", prefix)
+	}
+
+	// index files are referenced if they belong to the root of published distribution (or
+	// one of its aliases) or to one of the published components
+	referencedDists := func(path string) bool {
+		for _, r := range repos {
+			for _, distribution := range r.Distributions() {
+				rest := strings.TrimPrefix(path, distribution+"/")
+				if rest == path {
+					continue
+				}
+
+				if !strings.Contains(rest, "/") {
+					return true
+				}
+
+				for _, component := range r.Components() {
+					if strings.HasPrefix(rest, component+"/") {
+						return true
+					}
+				}
+			}
+		}
+
+		return false
+	}
+
+	var filesToDelete []string
+
+	existingFiles, err := publishedStorage.Filelist(filepath.Join(prefix, "dists"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range existingFiles {
+		if !referencedDists(file) {
+			filesToDelete = append(filesToDelete, filepath.Join("dists", file))
+		}
+	}
+
+	existingFiles, err = publishedStorage.Filelist(filepath.Join(prefix, "pool"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range existingFiles {
+		if !referencedPool[filepath.Join("pool", file)] {
+			filesToDelete = append(filesToDelete, filepath.Join("pool", file))
+		}
+	}
+
+	sort.Strings(filesToDelete)
+
+	if !dryRun {
+		for _, file := range filesToDelete {
+			err = publishedStorage.Remove(filepath.Join(prefix, file))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return filesToDelete, nil
+}
+
 // Remove removes published repository, cleaning up directories, files
 func (collection *PublishedRepoCollection) Remove(publishedStorageProvider aptly.PublishedStorageProvider,
 	storage, prefix, distribution string, collectionFactory *CollectionFactory, progress aptly.Progress,
@@ -1281,43 +2103,59 @@ func (collection *PublishedRepoCollection) Remove(publishedStorageProvider aptly
 		return err
 	}
 
-	removePrefix := true
-	removePoolComponents := repo.Components()
-	cleanComponents := []string{}
 	repoPosition := -1
-
 	for i, r := range collection.list {
 		if r == repo {
 			repoPosition = i
-			continue
 		}
-		if r.Storage == repo.Storage && r.Prefix == repo.Prefix {
-			removePrefix = false
+	}
 
-			rComponents := r.Components()
-			for _, component := range rComponents {
-				if utils.StrSliceHasItem(removePoolComponents, component) {
-					removePoolComponents = utils.StrSlicesSubstract(removePoolComponents, []string{component})
-					cleanComponents = append(cleanComponents, component)
+	// files are removed from the storage and every replica, components shared with
+	// other published repositories in the same storage & prefix are cleaned up instead
+	storages := repo.Storages()
+	cleanComponents := make([][]string, len(storages))
+
+	for i, repoStorage := range storages {
+		removePrefix := true
+		removePoolComponents := repo.Components()
+		cleanComponents[i] = []string{}
+
+		for _, r := range collection.list {
+			if r == repo {
+				continue
+			}
+			if r.Prefix == repo.Prefix && utils.StrSliceHasItem(r.Storages(), repoStorage) {
+				removePrefix = false
+
+				rComponents := r.Components()
+				for _, component := range rComponents {
+					if utils.StrSliceHasItem(removePoolComponents, component) {
+						removePoolComponents = utils.StrSlicesSubstract(removePoolComponents, []string{component})
+						cleanComponents[i] = append(cleanComponents[i], component)
+					}
 				}
 			}
 		}
-	}
 
-	err = repo.RemoveFiles(publishedStorageProvider, removePrefix, removePoolComponents, progress)
-	if err != nil {
-		if !force {
-			return fmt.Errorf("published files removal failed, use -force-drop to override: %s", err)
+		err = repo.removeFilesFromStorage(publishedStorageProvider.GetPublishedStorage(repoStorage), removePrefix, removePoolComponents, progress)
+		if err != nil {
+			if !force {
+				return fmt.Errorf("published files removal failed, use -force-drop to override: %s", err)
+			}
+			// ignore error with -force-drop
 		}
-		// ignore error with -force-drop
 	}
 
 	collection.list[len(collection.list)-1], collection.list[repoPosition], collection.list =
 		nil, collection.list[len(collection.list)-1], collection.list[:len(collection.list)-1]
 
-	if !skipCleanup && len(cleanComponents) > 0 {
-		err = collection.CleanupPrefixComponentFiles(repo.Prefix, cleanComponents,
-			publishedStorageProvider.GetPublishedStorage(storage), collectionFactory, progress)
+	for i, repoStorage := range storages {
+		if skipCleanup || len(cleanComponents[i]) == 0 {
+			continue
+		}
+
+		err = collection.CleanupPrefixComponentFiles(repo.Prefix, cleanComponents[i],
+			publishedStorageProvider.GetPublishedStorage(repoStorage), collectionFactory, progress)
 		if err != nil {
 			if !force {
 				return fmt.Errorf("cleanup failed, use -force-drop to override: %s", err)
@@ -1330,7 +2168,12 @@ func (collection *PublishedRepoCollection) Remove(publishedStorageProvider aptly
 
 	for _, component := range repo.Components() {
 		batch.Delete(repo.RefKey(component))
+		batch.Delete(repo.componentRefKey(component))
 	}
 
-	return batch.Write()
+	err = batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionDropped, AuditKindPublished, repo.auditName(), repo.UUID)
+	}
+	return err
 }
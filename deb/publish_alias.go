@@ -0,0 +1,154 @@
+package deb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Distributions returns distribution of published repository followed by its aliases
+func (p *PublishedRepo) Distributions() []string {
+	return append([]string{p.Distribution}, p.Aliases...)
+}
+
+// SetAliases replaces list of distributions published repository is additionally published under,
+// aliases which are no longer used are removed on next publish
+func (p *PublishedRepo) SetAliases(aliases []string) error {
+	seen := map[string]bool{p.Distribution: true}
+	result := []string(nil)
+
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		if strings.Contains(alias, "..") || strings.HasPrefix(alias, "/") {
+			return fmt.Errorf("invalid distribution alias %s", alias)
+		}
+
+		if seen[alias] {
+			if alias == p.Distribution {
+				return fmt.Errorf("distribution alias %s is the same as distribution", alias)
+			}
+			continue
+		}
+		seen[alias] = true
+
+		result = append(result, alias)
+	}
+
+	for _, alias := range p.Aliases {
+		if !seen[alias] {
+			p.removedAliases = append(p.removedAliases, alias)
+		}
+	}
+
+	p.Aliases = result
+	return nil
+}
+
+// aliasOwnFiles are files generated separately for every alias, other files are shared with distribution
+var aliasOwnFiles = []string{"Release", "Release.gpg", "InRelease", PublishedSources, PublishedSourcesList}
+
+// publishAliases makes files of published distribution available under every alias: index files are
+// linked, while Release file is generated for each alias (with Suite set to the alias)
+func (p *PublishedRepo) publishAliases(publishedStorage aptly.PublishedStorage, tempDir string, signer pgp.Signer, progress aptly.Progress) error {
+	for _, alias := range p.removedAliases {
+		err := publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "dists", alias), progress)
+		if err != nil {
+			return fmt.Errorf("unable to remove distribution alias %s: %s", alias, err)
+		}
+	}
+	p.removedAliases = nil
+
+	if len(p.Aliases) == 0 {
+		return nil
+	}
+
+	basePath := filepath.Join(p.Prefix, "dists", p.Distribution)
+	files, err := publishedStorage.Filelist(basePath)
+	if err != nil {
+		return err
+	}
+
+	shared := make([]string, 0, len(files))
+	for _, file := range files {
+		if !utils.StrSliceHasItem(aliasOwnFiles, file) && !strings.HasSuffix(file, ".tmp") {
+			shared = append(shared, file)
+		}
+	}
+
+	for _, alias := range p.Aliases {
+		if progress != nil {
+			progress.Printf("Publishing distribution alias %s...\n", alias)
+		}
+
+		aliasPath := filepath.Join(p.Prefix, "dists", alias)
+
+		var existing []string
+		existing, err = publishedStorage.Filelist(aliasPath)
+		if err != nil {
+			return err
+		}
+
+		indexes := newIndexFiles(publishedStorage, aliasPath, tempDir, ".tmp", false, false, false)
+
+		dirs := map[string]bool{}
+		for _, file := range shared {
+			dir := filepath.Dir(filepath.Join(aliasPath, file))
+			if !dirs[dir] {
+				err = publishedStorage.MkDir(dir)
+				if err != nil {
+					return fmt.Errorf("unable to create dir: %s", err)
+				}
+				dirs[dir] = true
+			}
+
+			// files are linked with temporary names and moved into place along with Release file
+			tempName := filepath.Join(aliasPath, file+".tmp")
+			_ = publishedStorage.Remove(tempName)
+
+			err = publishedStorage.HardLink(filepath.Join(basePath, file), tempName)
+			if err != nil {
+				return fmt.Errorf("unable to link %s to distribution alias %s: %s", file, alias, err)
+			}
+			indexes.addRename(tempName, filepath.Join(aliasPath, file))
+		}
+
+		err = p.writeRelease(indexes, alias, signer, progress)
+		if err != nil {
+			return err
+		}
+
+		err = indexes.RenameFiles()
+		if err != nil {
+			return err
+		}
+
+		// remove files which are no longer published
+		sort.Strings(shared)
+		for _, file := range existing {
+			if utils.StrSliceHasItem(aliasOwnFiles, file) {
+				continue
+			}
+
+			if i := sort.SearchStrings(shared, file); i < len(shared) && shared[i] == file {
+				continue
+			}
+
+			err = publishedStorage.Remove(filepath.Join(aliasPath, file))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ComponentRule routes packages matching Query into Component on publish
+type ComponentRule struct {
+	Component string
+	Query     string
+}
+
+// String returns rule as it is specified on command line
+func (rule ComponentRule) String() string {
+	return rule.Component + "=" + rule.Query
+}
+
+// ParseComponentRule parses component rule specified as <component>=<query>
+func ParseComponentRule(value string) (ComponentRule, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return ComponentRule{}, fmt.Errorf("component rule should be specified as 'component=query': %q", value)
+	}
+
+	return ComponentRule{Component: strings.TrimSpace(parts[0]), Query: strings.TrimSpace(parts[1])}, nil
+}
+
+// SetComponentRules replaces rules which route packages into components on publish
+//
+// Packages are routed into the component of the first rule with matching query, packages
+// which don't match any rule stay in the component of their source.
+func (p *PublishedRepo) SetComponentRules(rules []ComponentRule, collectionFactory *CollectionFactory) error {
+	for _, rule := range rules {
+		if rule.Component == "" || strings.ContainsAny(rule.Component, " \t\n") || strings.Contains(rule.Component, "..") {
+			return fmt.Errorf("invalid component in component rule: %q", rule.Component)
+		}
+
+		if _, ok := p.Sources[rule.Component]; ok {
+			return fmt.Errorf("component %s of component rule is already published from source", rule.Component)
+		}
+	}
+
+	p.ComponentRules = rules
+	p.componentRefs = nil
+
+	_, err := p.componentQueries(collectionFactory)
+	return err
+}
+
+// SourceComponents returns sorted list of components published repo sources are published to,
+// without components filled by component rules
+func (p *PublishedRepo) SourceComponents() []string {
+	result := make([]string, 0, len(p.Sources))
+	for component := range p.Sources {
+		result = append(result, component)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// componentQueries parses queries of component rules
+func (p *PublishedRepo) componentQueries(collectionFactory *CollectionFactory) ([]PackageQuery, error) {
+	if len(p.ComponentRules) == 0 {
+		return nil, nil
+	}
+
+	if collectionFactory.queryParser == nil {
+		return nil, fmt.Errorf("component rules are not supported: no query parser")
+	}
+
+	queries := make([]PackageQuery, len(p.ComponentRules))
+	for i, rule := range p.ComponentRules {
+		var err error
+		queries[i], err = collectionFactory.queryParser(rule.Query)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse query of component rule %s: %s", rule, err)
+		}
+	}
+
+	return queries, nil
+}
+
+// splitComponents routes packages of source components into components according to component
+// rules, package references of all the components are recorded to be saved with published repo
+func (p *PublishedRepo) splitComponents(lists map[string]*PackageList, collectionFactory *CollectionFactory) (map[string]*PackageList, error) {
+	queries, err := p.componentQueries(collectionFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]*PackageList{}
+	for component := range lists {
+		result[component] = NewPackageList()
+	}
+	for _, rule := range p.ComponentRules {
+		result[rule.Component] = NewPackageList()
+	}
+
+	for _, component := range p.SourceComponents() {
+		err = lists[component].ForEach(func(pkg *Package) error {
+			target := component
+			for i, q := range queries {
+				if q.Matches(pkg) {
+					target = p.ComponentRules[i].Component
+					break
+				}
+			}
+
+			if e := result[target].Add(pkg); e != nil {
+				return fmt.Errorf("unable to route package %s into component %s: %s", pkg, target, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.componentRefs = make(map[string]*PackageRefList, len(result))
+	for component, list := range result {
+		p.componentRefs[component] = NewPackageRefListFromPackageList(list)
+	}
+
+	return result, nil
+}
+
+// componentRefKey is a key to store package references published in component after routing
+// by component rules
+func (p *PublishedRepo) componentRefKey(component string) []byte {
+	return []byte("EC" + p.UUID + component)
+}
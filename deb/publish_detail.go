@@ -0,0 +1,177 @@
+package deb
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// PublishedSourceDetail describes source of one component of published repository
+type PublishedSourceDetail struct {
+	Component string
+	Kind      string
+	Name      string
+	UUID      string
+	// Packages is number of package references published in component
+	Packages int
+}
+
+// PublishedRepoDetail is machine-readable description of published repository
+//
+// Unlike PublishedRepo JSON representation used by the API, set of fields is fixed
+// and all of them are always present, so that scripts could rely on them.
+type PublishedRepoDetail struct {
+	UUID                 string
+	Storage              string
+	Prefix               string
+	Distribution         string
+	Path                 string
+	Origin               string
+	Label                string
+	Suite                string
+	Codename             string
+	NotAutomatic         string
+	ButAutomaticUpgrades string
+	Architectures        []string
+	ArchitectureAllMode  string
+	IncludeArchitectures []string
+	ExcludeArchitectures []string
+	Components           []string
+	SourceKind           string
+	Sources              []PublishedSourceDetail
+	SkipContents         bool
+	SkipBz2              bool
+	AcquireByHash        bool
+	PDiffs               bool
+	DebianFieldOrder     bool
+	Description          string
+	Provenance           string
+	CreatedAt            time.Time
+	ReleasedAt           time.Time
+	ValidFor             string
+	ValidUntil           time.Time
+	// Signed is true if InRelease or Release.gpg is present in published storage
+	Signed          bool
+	ReleaseFields   map[string]string
+	ReleaseFiles    map[string]utils.ChecksumInfo
+	Overrides       OverrideTable
+	SourceOverrides OverrideTable
+	PublishKey      bool
+	PublicURL       string
+	Aliases         []string
+	Replicas        []string
+	FailedReplicas  []string
+	ComponentRules  []ComponentRule
+}
+
+// Detail builds machine-readable description of published repository
+//
+// Published repository should be loaded with LoadComplete. Published storage
+// is consulted to find out whether Release file is signed.
+func (p *PublishedRepo) Detail(publishedStorageProvider aptly.PublishedStorageProvider) (*PublishedRepoDetail, error) {
+	detail := &PublishedRepoDetail{
+		UUID:                 p.UUID,
+		Storage:              p.Storage,
+		Prefix:               p.Prefix,
+		Distribution:         p.Distribution,
+		Path:                 p.GetPath(),
+		Origin:               p.Origin,
+		Label:                p.Label,
+		Suite:                p.Suite,
+		Codename:             p.Codename,
+		NotAutomatic:         p.NotAutomatic,
+		ButAutomaticUpgrades: p.ButAutomaticUpgrades,
+		Architectures:        nonNilStrings(p.Architectures),
+		ArchitectureAllMode:  p.ArchitectureAllMode,
+		IncludeArchitectures: nonNilStrings(p.IncludeArchitectures),
+		ExcludeArchitectures: nonNilStrings(p.ExcludeArchitectures),
+		Components:           p.Components(),
+		SourceKind:           p.SourceKind,
+		Sources:              []PublishedSourceDetail{},
+		SkipContents:         p.SkipContents,
+		SkipBz2:              p.SkipBz2,
+		AcquireByHash:        p.AcquireByHash,
+		PDiffs:               p.PDiffs,
+		DebianFieldOrder:     p.DebianFieldOrder,
+		Description:          p.Description,
+		Provenance:           p.Provenance,
+		CreatedAt:            p.CreatedAt,
+		ReleasedAt:           p.ReleasedAt,
+		ValidFor:             p.ValidFor.String(),
+		ValidUntil:           p.ValidUntil(),
+		ReleaseFields:        p.ReleaseFields,
+		ReleaseFiles:         p.ReleaseFiles,
+		Overrides:            p.Overrides,
+		SourceOverrides:      p.SourceOverrides,
+		PublishKey:           p.PublishKey,
+		PublicURL:            p.PublicURL,
+		Aliases:              nonNilStrings(p.Aliases),
+		Replicas:             nonNilStrings(p.Replicas),
+		FailedReplicas:       nonNilStrings(p.FailedReplicas),
+		ComponentRules:       p.ComponentRules,
+	}
+
+	if detail.ReleaseFields == nil {
+		detail.ReleaseFields = map[string]string{}
+	}
+	if detail.ReleaseFiles == nil {
+		detail.ReleaseFiles = map[string]utils.ChecksumInfo{}
+	}
+	if detail.Overrides == nil {
+		detail.Overrides = OverrideTable{}
+	}
+	if detail.SourceOverrides == nil {
+		detail.SourceOverrides = OverrideTable{}
+	}
+	if detail.ComponentRules == nil {
+		detail.ComponentRules = []ComponentRule{}
+	}
+
+	for _, component := range p.SourceComponents() {
+		item := p.sourceItems[component]
+
+		source := PublishedSourceDetail{
+			Component: component,
+			Kind:      p.SourceKind,
+			UUID:      p.Sources[component],
+			Packages:  p.RefList(component).Len(),
+		}
+
+		if item.snapshot != nil {
+			source.Name = item.snapshot.Name
+		} else if item.localRepo != nil {
+			source.Name = item.localRepo.Name
+		} else {
+			panic("no snapshot/local repo")
+		}
+
+		detail.Sources = append(detail.Sources, source)
+	}
+
+	publishedStorage := publishedStorageProvider.GetPublishedStorage(p.Storage)
+	basePath := filepath.Join(p.Prefix, "dists", p.Distribution)
+
+	for _, name := range []string{"InRelease", "Release.gpg"} {
+		exists, err := publishedStorage.FileExists(filepath.Join(basePath, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			detail.Signed = true
+			break
+		}
+	}
+
+	return detail, nil
+}
+
+// nonNilStrings replaces nil slice with empty one, so that it is rendered as [] in JSON
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
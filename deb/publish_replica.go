@@ -0,0 +1,158 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Storages returns published storage of the repository followed by its replicas
+func (p *PublishedRepo) Storages() []string {
+	return append([]string{p.Storage}, p.Replicas...)
+}
+
+// sharesStorage checks whether published repositories are published to the same storage
+// (as storage or replica)
+func (p *PublishedRepo) sharesStorage(other *PublishedRepo) bool {
+	for _, storage := range other.Storages() {
+		if utils.StrSliceHasItem(p.Storages(), storage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetReplicas replaces list of published storages published repository is replicated to,
+// files are not removed from storages which are no longer listed
+func (p *PublishedRepo) SetReplicas(replicas []string) error {
+	seen := map[string]bool{p.Storage: true}
+	result := []string(nil)
+
+	for _, replica := range replicas {
+		replica = strings.TrimSpace(replica)
+		if replica == "" {
+			continue
+		}
+
+		if seen[replica] {
+			if replica == p.Storage {
+				return fmt.Errorf("replica %s is the same as storage of published repository", replica)
+			}
+			continue
+		}
+		seen[replica] = true
+
+		result = append(result, replica)
+	}
+
+	failed := []string(nil)
+	for _, replica := range p.FailedReplicas {
+		if seen[replica] && replica != p.Storage {
+			failed = append(failed, replica)
+		}
+	}
+
+	p.Replicas = result
+	p.FailedReplicas = failed
+	return nil
+}
+
+// ReplicaPublishError is returned when published repository has been published to its
+// storage, but publishing to some of the replicas failed
+//
+// Published repository should be saved anyway, failed replicas are recorded in FailedReplicas
+// and could be published again with ResumeReplicas.
+type ReplicaPublishError struct {
+	Errors map[string]error
+}
+
+func (e *ReplicaPublishError) Error() string {
+	replicas := make([]string, 0, len(e.Errors))
+	for replica := range e.Errors {
+		replicas = append(replicas, replica)
+	}
+	sort.Strings(replicas)
+
+	messages := make([]string, len(replicas))
+	for i, replica := range replicas {
+		messages[i] = fmt.Sprintf("%s: %s", replica, e.Errors[replica])
+	}
+
+	return fmt.Sprintf("publishing to replicas failed (%s)", strings.Join(messages, "; "))
+}
+
+// publishReplicas publishes repository to the listed replicas, replicas which failed are recorded
+// in FailedReplicas
+func (p *PublishedRepo) publishReplicas(replicas []string, packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
+	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite, multiDist bool) error {
+	// aliases are removed from every storage
+	removedAliases := p.removedAliases
+	errors := map[string]error{}
+	p.FailedReplicas = nil
+
+	for _, replica := range replicas {
+		if progress != nil {
+			progress.Printf("Publishing to replica %s...\n", replica)
+		}
+
+		p.removedAliases = removedAliases
+		err := p.publishToStorage(replica, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite, multiDist)
+		if err != nil {
+			if progress != nil {
+				progress.ColoredPrintf("@rERROR@|: publishing to replica %s failed: %s", replica, err)
+			}
+
+			errors[replica] = err
+			p.FailedReplicas = append(p.FailedReplicas, replica)
+		}
+	}
+	p.removedAliases = nil
+
+	if len(errors) > 0 {
+		return &ReplicaPublishError{Errors: errors}
+	}
+
+	return nil
+}
+
+// PublishAndSave publishes repository and saves it to DB, new repository (add is true) is added
+// to the collection
+//
+// Repository is saved as long as it has been published to its storage, even if publishing to some
+// of the replicas failed, so that replicas could be resumed later. saved reports whether repository
+// has been saved, in that case err (if not nil) is *ReplicaPublishError.
+func (collection *PublishedRepoCollection) PublishAndSave(repo *PublishedRepo, add bool, packagePool aptly.PackagePool,
+	publishedStorageProvider aptly.PublishedStorageProvider, collectionFactory *CollectionFactory, signer pgp.Signer,
+	progress aptly.Progress, forceOverwrite, multiDist bool) (saved bool, err error) {
+	publishErr := repo.Publish(packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite, multiDist)
+	if _, ok := publishErr.(*ReplicaPublishError); publishErr != nil && !ok {
+		return false, publishErr
+	}
+
+	if add {
+		err = collection.Add(repo)
+	} else {
+		err = collection.Update(repo)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	return true, publishErr
+}
+
+// ResumeReplicas publishes repository to the replicas which failed during last publish
+func (p *PublishedRepo) ResumeReplicas(packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
+	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite, multiDist bool) error {
+	if len(p.FailedReplicas) == 0 {
+		return fmt.Errorf("published repository %s/%s has no failed replicas", p.StoragePrefix(), p.Distribution)
+	}
+
+	p.rePublishing = true
+	return p.publishReplicas(p.FailedReplicas, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite, multiDist)
+}
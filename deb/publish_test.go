@@ -2,12 +2,14 @@ package deb
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
@@ -43,6 +45,9 @@ func (n *NullSigner) SetKey(keyRef string) {
 func (n *NullSigner) SetBatch(batch bool) {
 }
 
+func (n *NullSigner) SetDigestAlgorithm(algo string) {
+}
+
 func (n *NullSigner) SetKeyRing(keyring, secretKeyring string) {
 }
 
@@ -57,6 +62,17 @@ func (n *NullSigner) ClearSign(source string, destination string) error {
 	return ioutil.WriteFile(destination, []byte{}, 0644)
 }
 
+type KeyExportingSigner struct {
+	NullSigner
+}
+
+func (n *KeyExportingSigner) ExportPublicKey(armored bool) ([]byte, error) {
+	if armored {
+		return []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n"), nil
+	}
+	return []byte{0x99}, nil
+}
+
 type FakeStorageProvider struct {
 	storages map[string]aptly.PublishedStorage
 }
@@ -406,6 +422,24 @@ func (s *PublishedRepoSuite) TestPublish(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *PublishedRepoSuite) TestPublishReproducible(c *C) {
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	packagesPath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-i386/Packages")
+	first, err := os.ReadFile(packagesPath)
+	c.Assert(err, IsNil)
+	firstSums := s.repo.ReleaseFiles
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	second, err := os.ReadFile(packagesPath)
+	c.Assert(err, IsNil)
+	c.Check(second, DeepEquals, first)
+	c.Check(s.repo.ReleaseFiles, DeepEquals, firstSums)
+}
+
 func (s *PublishedRepoSuite) TestPublishNoSigner(c *C) {
 	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
 	c.Assert(err, IsNil)
@@ -461,6 +495,47 @@ func (s *PublishedRepoSuite) TestString(c *C) {
 		"files:other:ppa/maverick [source] publishes {main: [local1]: comment1}")
 }
 
+func (s *PublishedRepoSuite) TestDetail(c *C) {
+	detail, err := s.repo3.Detail(s.provider)
+	c.Assert(err, IsNil)
+	c.Check(detail.Path, Equals, "linux/natty")
+	c.Check(detail.Components, DeepEquals, []string{"contrib", "main"})
+	c.Check(detail.Sources, DeepEquals, []PublishedSourceDetail{
+		{Component: "contrib", Kind: SourceSnapshot, Name: "snap", UUID: s.snapshot2.UUID, Packages: 3},
+		{Component: "main", Kind: SourceSnapshot, Name: "snap", UUID: s.snapshot.UUID, Packages: 3},
+	})
+	c.Check(detail.Signed, Equals, false)
+	c.Check(detail.Aliases, DeepEquals, []string{})
+	c.Check(detail.ReleaseFiles, HasLen, 0)
+
+	// fields are present even if they are empty
+	output, err := json.Marshal(detail)
+	c.Assert(err, IsNil)
+	var fields map[string]interface{}
+	c.Assert(json.Unmarshal(output, &fields), IsNil)
+	c.Check(fields["Replicas"], DeepEquals, []interface{}{})
+	c.Check(fields["ReleaseFields"], DeepEquals, map[string]interface{}{})
+	c.Check(fields["Sources"], HasLen, 2)
+
+	c.Assert(s.repo2.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false), IsNil)
+
+	detail, err = s.repo2.Detail(s.provider)
+	c.Assert(err, IsNil)
+	c.Check(detail.Signed, Equals, true)
+	c.Check(detail.SourceKind, Equals, SourceLocalRepo)
+	c.Check(detail.Sources, DeepEquals, []PublishedSourceDetail{
+		{Component: "main", Kind: SourceLocalRepo, Name: "local1", UUID: s.localRepo.UUID, Packages: 3},
+	})
+	c.Check(detail.ReleaseFiles["main/binary-i386/Packages"].SHA256, Not(Equals), "")
+	c.Check(detail.ReleasedAt.IsZero(), Equals, false)
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false), IsNil)
+
+	detail, err = s.repo.Detail(s.provider)
+	c.Assert(err, IsNil)
+	c.Check(detail.Signed, Equals, false)
+}
+
 func (s *PublishedRepoSuite) TestKey(c *C) {
 	c.Check(s.repo.Key(), DeepEquals, []byte("Uppa>>squeeze"))
 	c.Check(s.repo5.Key(), DeepEquals, []byte("Ufiles:other:ppa>>maverick"))
@@ -472,12 +547,17 @@ func (s *PublishedRepoSuite) TestRefKey(c *C) {
 }
 
 func (s *PublishedRepoSuite) TestEncodeDecode(c *C) {
+	s.repo.Description = "Nightly builds"
+	s.repo.Provenance = "CI pipeline #42"
+
 	encoded := s.repo.Encode()
 	repo := &PublishedRepo{}
 	err := repo.Decode(encoded)
 
 	s.repo.sourceItems = nil
 	c.Assert(err, IsNil)
+	c.Assert(repo.CreatedAt.Equal(s.repo.CreatedAt), Equals, true)
+	repo.CreatedAt = s.repo.CreatedAt
 	c.Assert(repo, DeepEquals, s.repo)
 
 	encoded2 := s.repo2.Encode()
@@ -486,6 +566,8 @@ func (s *PublishedRepoSuite) TestEncodeDecode(c *C) {
 
 	s.repo2.sourceItems = nil
 	c.Assert(err, IsNil)
+	c.Assert(repo2.CreatedAt.Equal(s.repo2.CreatedAt), Equals, true)
+	repo2.CreatedAt = s.repo2.CreatedAt
 	c.Assert(repo2, DeepEquals, s.repo2)
 }
 
@@ -549,6 +631,10 @@ func (s *PublishedRepoCollectionSuite) TestAddByStoragePrefixDistribution(c *C)
 	c.Assert(s.collection.Add(s.repo2), IsNil)
 	c.Assert(s.collection.Add(s.repo3), ErrorMatches, ".*already exists")
 	c.Assert(s.collection.CheckDuplicate(s.repo3), Equals, s.repo1)
+
+	c.Assert(s.repo4.SetAliases([]string{"anaconda"}), IsNil)
+	c.Assert(s.collection.CheckDuplicate(s.repo4), Equals, s.repo1)
+	c.Assert(s.repo4.SetAliases(nil), IsNil)
 	c.Assert(s.collection.Add(s.repo4), IsNil)
 	c.Assert(s.collection.Add(s.repo5), IsNil)
 
@@ -982,3 +1068,717 @@ func (s *PublishedRepoRemoveSuite) TestRemoveRepo5(c *C) {
 	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists/osminog"), Not(PathExists))
 	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/pool/contrib"), Not(PathExists))
 }
+
+func (s *PublishedRepoRemoveSuite) TestRemoveRepoWithReplicas(c *C) {
+	c.Assert(s.repo3.SetReplicas([]string{"files:other"}), IsNil)
+	c.Assert(s.collection.Update(s.repo3), IsNil)
+
+	s.publishedStorage2.MkDir("ppa/dists/meduza")
+	s.publishedStorage2.MkDir("ppa/pool/main")
+
+	err := s.collection.Remove(s.provider, "", "ppa", "meduza", s.factory, nil, false, false)
+	c.Check(err, IsNil)
+
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/meduza"), Not(PathExists))
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/pool/main"), PathExists)
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists/meduza"), Not(PathExists))
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/pool/main"), Not(PathExists))
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists/osminog"), PathExists)
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/pool/contrib"), PathExists)
+}
+
+func (s *PublishedRepoRemoveSuite) TestCheckDuplicateReplicas(c *C) {
+	c.Assert(s.repo3.SetReplicas([]string{"files:other"}), IsNil)
+	c.Check(s.collection.CheckAliasDuplicate(s.repo3), IsNil)
+
+	c.Assert(s.repo4.SetReplicas([]string{"files:other"}), IsNil)
+	c.Check(s.collection.CheckAliasDuplicate(s.repo4), Equals, s.repo5)
+}
+
+func (s *PublishedRepoRemoveSuite) TestCleanupPrefix(c *C) {
+	s.SetUpPackages()
+	c.Assert(s.factory.PackageCollection().Update(s.p1), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(s.p1), IsNil)
+	snap2 := NewSnapshotFromPackageList("snap2", nil, list, "")
+	c.Assert(s.snapshotCollection.Add(snap2), IsNil)
+
+	repo6, _ := NewPublishedRepo("", "ppa", "bookworm", []string{}, []string{"main"}, []interface{}{snap2}, s.factory)
+	c.Assert(s.collection.Add(repo6), IsNil)
+
+	for _, path := range []string{
+		"ppa/dists/anaconda/Release",
+		"ppa/dists/anaconda/main/binary-i386/Packages",
+		"ppa/dists/anaconda/contrib/binary-i386/Packages",
+		"ppa/dists/dropped/Release",
+		"ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb",
+		"ppa/pool/main/m/mars-invaders/mars-invaders_7.40-2_i386.deb",
+		"pool/main/m/mars-invaders/mars-invaders_7.40-2_i386.deb",
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(filepath.Join(s.root, path)), 0755), IsNil)
+		c.Assert(os.WriteFile(filepath.Join(s.root, path), nil, 0644), IsNil)
+	}
+
+	expected := []string{
+		"dists/anaconda/contrib/binary-i386/Packages",
+		"dists/dropped/Release",
+		"pool/main/m/mars-invaders/mars-invaders_7.40-2_i386.deb",
+	}
+
+	removed, err := s.collection.CleanupPrefix("", "ppa", s.publishedStorage, s.factory, nil, true)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, expected)
+	c.Check(filepath.Join(s.root, "ppa/dists/dropped/Release"), PathExists)
+
+	removed, err = s.collection.CleanupPrefix("", "ppa", s.publishedStorage, s.factory, nil, false)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, expected)
+
+	c.Check(filepath.Join(s.root, "ppa/dists/anaconda/Release"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/anaconda/main/binary-i386/Packages"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/anaconda/contrib/binary-i386/Packages"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "ppa/dists/dropped/Release"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/pool/main/m/mars-invaders/mars-invaders_7.40-2_i386.deb"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "pool/main/m/mars-invaders/mars-invaders_7.40-2_i386.deb"), PathExists)
+
+	_, err = s.collection.CleanupPrefix("", "nothing", s.publishedStorage, s.factory, nil, false)
+	c.Check(err, ErrorMatches, "no published repositories under prefix nothing")
+}
+
+func (s *PublishedRepoSuite) TestPublishArchitectureAll(c *C) {
+	s.repo.ArchitectureAllMode = ArchitectureAllBoth
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	c.Check(s.repo.Architectures, DeepEquals, []string{"all", "i386"})
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-all/Packages"), PathExists)
+
+	rf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["No-Support-For-Architecture-All"], Equals, "Packages")
+
+	s.repo3.ArchitectureAllMode = "sometimes"
+	err = s.repo3.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Check(err, ErrorMatches, "unknown mode for architecture all: sometimes")
+}
+
+func (s *PublishedRepoSuite) TestPublishForceExcludeArchitectures(c *C) {
+	s.repo.IncludeArchitectures = []string{"arm64", "i386"}
+	s.repo.ExcludeArchitectures = []string{"source"}
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	c.Check(s.repo.Architectures, DeepEquals, []string{"arm64", "i386"})
+
+	packages, err := os.ReadFile(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-arm64/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(packages, HasLen, 0)
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-arm64/Release"), PathExists)
+
+	s.repo3.ExcludeArchitectures = []string{"i386"}
+	err = s.repo3.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Check(err, ErrorMatches, "unable to figure out list of architectures, please supply explicit list")
+
+	s.repo3.IncludeArchitectures = []string{"i386"}
+	err = s.repo3.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Check(err, ErrorMatches, "architecture i386 can't be both included and excluded")
+}
+
+func (s *PublishedRepoSuite) TestPublishValidUntil(c *C) {
+	s.repo.ValidFor = 7 * 24 * time.Hour
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	c.Check(s.repo.ReleasedAt.IsZero(), Equals, false)
+	c.Check(s.repo.ValidUntil(), Equals, s.repo.ReleasedAt.Add(7*24*time.Hour))
+	c.Check(s.repo.ReleaseFiles["main/binary-i386/Packages"].Size, Not(Equals), int64(0))
+
+	rf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+
+	date, err := time.Parse(releaseDateFormat, st["Date"])
+	c.Assert(err, IsNil)
+	validUntil, err := time.Parse(releaseDateFormat, st["Valid-Until"])
+	c.Assert(err, IsNil)
+	c.Check(validUntil.Sub(date), Equals, 7*24*time.Hour)
+}
+
+func (s *PublishedRepoSuite) TestPublishReleaseFields(c *C) {
+	s.repo.ReleaseFields = map[string]string{
+		"X-Vendor":    "Example Corp",
+		"origin":      "custom",
+		"Description": "Example packages",
+	}
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	release, err := os.ReadFile(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	c.Check(string(release), Matches, "(?s).*\nDescription: Example packages\n.*")
+	c.Check(string(release), Not(Matches), "(?s).*Origin: .*")
+
+	st, err := NewControlFileReader(bytes.NewReader(release), true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["X-Vendor"], Equals, "Example Corp")
+	c.Check(st["Origin"], Equals, "custom")
+	c.Check(st["SHA256"], Not(Equals), "")
+}
+
+func (s *PublishedRepoSuite) TestPublishOverrides(c *C) {
+	s.repo.Overrides = OverrideTable{
+		"alien-arena-common": {Priority: "important", Section: "non-free/games",
+			Fields: map[string]string{"Phased-Update-Percentage": "10"}},
+		"other": {Section: "utils"},
+	}
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	pf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	defer pf.Close()
+
+	st, err := NewControlFileReader(pf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Package"], Equals, "alien-arena-common")
+	c.Check(st["Priority"], Equals, "important")
+	c.Check(st["Section"], Equals, "non-free/games")
+	c.Check(st["Phased-Update-Percentage"], Equals, "10")
+
+	// package itself is not modified
+	c.Check(s.p1.Extra()["Priority"], Equals, "extra")
+	c.Check(s.p1.Extra()["Phased-Update-Percentage"], Equals, "")
+}
+
+func (s *PublishedRepoSuite) TestPublishSourceOverrides(c *C) {
+	// source package shares the name with binary package, binary overrides shouldn't apply to it
+	stanza, err := NewControlFileReader(bytes.NewBufferString(sourcePackageMeta), false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	stanza["Package"] = "alien-arena-common"
+	src, err := NewSourcePackageFromControlFile(stanza)
+	c.Assert(err, IsNil)
+	src.UpdateFiles(s.p1.Files())
+	c.Assert(s.packageCollection.Update(src), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(s.p1), IsNil)
+	c.Assert(list.Add(src), IsNil)
+
+	localRepo := NewLocalRepo("local2", "")
+	localRepo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.factory.LocalRepoCollection().Add(localRepo), IsNil)
+
+	repo, err := NewPublishedRepo("", "ppa", "sid", []string{"i386", "source"}, []string{"main"}, []interface{}{localRepo}, s.factory)
+	c.Assert(err, IsNil)
+	repo.SkipContents = true
+	repo.Overrides = OverrideTable{
+		"alien-arena-common": {Priority: "important", Section: "non-free/games",
+			Fields: map[string]string{"Phased-Update-Percentage": "10"}},
+	}
+	repo.SourceOverrides = OverrideTable{
+		"alien-arena-common": {Section: "contrib/games-src"},
+	}
+
+	err = repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	pf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/sid/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	defer pf.Close()
+
+	st, err := NewControlFileReader(pf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Section"], Equals, "non-free/games")
+	c.Check(st["Phased-Update-Percentage"], Equals, "10")
+
+	sf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/sid/main/source/Sources"))
+	c.Assert(err, IsNil)
+	defer sf.Close()
+
+	st, err = NewControlFileReader(sf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Package"], Equals, "alien-arena-common")
+	c.Check(st["Section"], Equals, "contrib/games-src")
+	c.Check(st["Priority"], Equals, "source")
+	c.Check(st["Phased-Update-Percentage"], Equals, "")
+}
+
+func (s *PublishedRepoSuite) TestPublishDebianFieldOrder(c *C) {
+	s.repo.DebianFieldOrder = true
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	packages, err := ioutil.ReadFile(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(bytes.HasPrefix(packages, []byte("Package: alien-arena-common\nArchitecture: i386\nVersion: 7.40-2\nPriority: extra\n")), Equals, true)
+}
+
+func (s *PublishedRepoSuite) TestPublishKey(c *C) {
+	s.repo.PublishKey = true
+	distPath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze")
+
+	// unsigned publishing doesn't publish the key
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(distPath, "repo-key.asc"), Not(PathExists))
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Check(err, ErrorMatches, "unable to publish signing key: signer doesn't support exporting public key")
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, &KeyExportingSigner{}, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(distPath, "repo-key.asc"), PathExists)
+	c.Check(filepath.Join(distPath, "repo-key.gpg"), PathExists)
+	c.Check(filepath.Join(distPath, "repo.sources"), Not(PathExists))
+
+	// published key is not listed in Release file
+	_, listed := s.repo.ReleaseFiles["repo-key.asc"]
+	c.Check(listed, Equals, false)
+
+	s.repo.PublicURL = "https://apt.example.com/ppa/"
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, &KeyExportingSigner{}, nil, false, false)
+	c.Assert(err, IsNil)
+
+	sources, err := os.ReadFile(filepath.Join(distPath, "repo.sources"))
+	c.Assert(err, IsNil)
+	c.Check(string(sources), Matches, "(?s).*curl -fsSL https://apt.example.com/ppa/dists/squeeze/repo-key.gpg -o /etc/apt/keyrings/ppa-squeeze.gpg\n.*")
+	c.Check(string(sources), Matches, "(?s).*\nTypes: deb\nURIs: https://apt.example.com/ppa\nSuites: squeeze\nComponents: main\n"+
+		"Architectures: i386\nSigned-By: /etc/apt/keyrings/ppa-squeeze.gpg\n")
+	c.Check(filepath.Join(distPath, "repo.list"), PathExists)
+
+	// client configuration is published without the key as well
+	s.repo.PublishKey = false
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(distPath, "repo.sources"), PathExists)
+	c.Check(filepath.Join(distPath, "repo.list"), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestPublishSnapshotManifest(c *C) {
+	distPath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze")
+
+	// unsigned snapshot has no manifest published
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(distPath, "snapshot-main.manifest"), Not(PathExists))
+
+	s.snapshot.ManifestSignature = "-----BEGIN PGP SIGNATURE-----\n"
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	expected, err := s.snapshot.Manifest(s.packageCollection)
+	c.Assert(err, IsNil)
+
+	manifest, err := os.ReadFile(filepath.Join(distPath, "snapshot-main.manifest"))
+	c.Assert(err, IsNil)
+	c.Check(manifest, DeepEquals, expected)
+
+	signature, err := os.ReadFile(filepath.Join(distPath, "snapshot-main.manifest.asc"))
+	c.Assert(err, IsNil)
+	c.Check(string(signature), Equals, s.snapshot.ManifestSignature)
+
+	// manifest is not listed in Release file
+	_, listed := s.repo.ReleaseFiles["snapshot-main.manifest"]
+	c.Check(listed, Equals, false)
+
+	// local repos have no manifests
+	err = s.repo2.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/maverick/snapshot-main.manifest"), Not(PathExists))
+
+	c.Check(PublishedSnapshotManifest("updates/main"), Equals, "snapshot-updates_main.manifest")
+}
+
+func (s *PublishedRepoSuite) TestSourcesEntries(c *C) {
+	s.repo.Architectures = []string{"amd64", "i386", "source"}
+
+	c.Check(s.repo.SourcesEntry("http://apt.example.com/ppa/"), Equals, `# ppa squeeze squeeze
+#
+# To install, run as root:
+#   curl -fsSL http://apt.example.com/ppa/dists/squeeze/repo.sources -o /etc/apt/sources.list.d/ppa-squeeze.sources
+Types: deb deb-src
+URIs: http://apt.example.com/ppa
+Suites: squeeze
+Components: main
+Architectures: amd64 i386
+Signed-By: /etc/apt/keyrings/ppa-squeeze.gpg
+`)
+
+	s.repo.PublishKey = true
+	c.Check(s.repo.SourcesListEntry("http://apt.example.com/ppa"), Equals, `# ppa squeeze squeeze
+#
+# To install, run as root:
+#   curl -fsSL http://apt.example.com/ppa/dists/squeeze/repo-key.gpg -o /etc/apt/keyrings/ppa-squeeze.gpg
+#   curl -fsSL http://apt.example.com/ppa/dists/squeeze/repo.list -o /etc/apt/sources.list.d/ppa-squeeze.list
+deb [arch=amd64,i386 signed-by=/etc/apt/keyrings/ppa-squeeze.gpg] http://apt.example.com/ppa squeeze main
+deb-src [signed-by=/etc/apt/keyrings/ppa-squeeze.gpg] http://apt.example.com/ppa squeeze main
+`)
+}
+
+func (s *PublishedRepoSuite) TestKeyringName(c *C) {
+	c.Check(s.repo.KeyringName(), Equals, "ppa-squeeze")
+
+	s.repo.Prefix = "."
+	c.Check(s.repo.KeyringName(), Equals, "squeeze")
+
+	s.repo.Origin = "Example Corp."
+	c.Check(s.repo.KeyringName(), Equals, "example-corp")
+}
+
+func (s *PublishedRepoSuite) TestValidatePublicURL(c *C) {
+	c.Check(ValidatePublicURL("https://apt.example.com/"), IsNil)
+	c.Check(ValidatePublicURL("apt.example.com"), ErrorMatches, "invalid public URL apt.example.com: should be absolute .*")
+	c.Check(ValidatePublicURL("ftp://apt.example.com/"), ErrorMatches, "invalid public URL .*")
+}
+
+func (s *PublishedRepoSuite) TestValidateReleaseFields(c *C) {
+	c.Check(ValidateReleaseFields(nil), IsNil)
+	c.Check(ValidateReleaseFields(map[string]string{"Acquire-By-Hash": "yes", "X-Custom": "value"}), IsNil)
+	c.Check(ValidateReleaseFields(map[string]string{"X Custom": "value"}), ErrorMatches, "invalid name of Release file field.*")
+	c.Check(ValidateReleaseFields(map[string]string{"sha256": "value"}), ErrorMatches, "field sha256 of Release file is generated by aptly.*")
+	c.Check(ValidateReleaseFields(map[string]string{"X-Custom": "a\nb"}), ErrorMatches, ".*should be single line")
+}
+
+func (s *PublishedRepoSuite) TestRefreshDue(c *C) {
+	now := time.Now()
+
+	c.Check(s.repo.RefreshDue(now), Equals, false)
+	c.Check(s.repo.ValidUntil().IsZero(), Equals, true)
+
+	s.repo.ValidFor = 48 * time.Hour
+	s.repo.ReleasedAt = now.Add(-23 * time.Hour)
+	c.Check(s.repo.RefreshDue(now), Equals, false)
+
+	s.repo.ReleasedAt = now.Add(-25 * time.Hour)
+	c.Check(s.repo.RefreshDue(now), Equals, true)
+}
+
+func (s *PublishedRepoSuite) TestRefreshRelease(c *C) {
+	err := s.repo.RefreshRelease(s.provider, nil, nil)
+	c.Check(err, ErrorMatches, "list of published index files is unknown.*")
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	releasePath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release")
+	packagesPath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-i386/Packages")
+
+	original, err := os.ReadFile(releasePath)
+	c.Assert(err, IsNil)
+	packagesStat, err := os.Stat(packagesPath)
+	c.Assert(err, IsNil)
+
+	s.repo.ValidFor = time.Hour
+	err = s.repo.RefreshRelease(s.provider, nil, nil)
+	c.Assert(err, IsNil)
+
+	refreshed, err := os.ReadFile(releasePath)
+	c.Assert(err, IsNil)
+	c.Check(string(refreshed), Not(Equals), string(original))
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release.tmp"), Not(PathExists))
+
+	st, err := NewControlFileReader(bytes.NewReader(refreshed), true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Valid-Until"], Not(Equals), "")
+	c.Check(st["SHA256"], Matches, "(?s).*main/binary-i386/Packages\n.*")
+
+	newPackagesStat, err := os.Stat(packagesPath)
+	c.Assert(err, IsNil)
+	c.Check(newPackagesStat.ModTime(), Equals, packagesStat.ModTime())
+}
+
+func (s *PublishedRepoSuite) TestSetAliases(c *C) {
+	c.Check(s.repo.SetAliases([]string{"stable", " ", "stable", "testing"}), IsNil)
+	c.Check(s.repo.Aliases, DeepEquals, []string{"stable", "testing"})
+	c.Check(s.repo.Distributions(), DeepEquals, []string{"squeeze", "stable", "testing"})
+
+	c.Check(s.repo.SetAliases([]string{"squeeze"}), ErrorMatches, "distribution alias squeeze is the same as distribution")
+	c.Check(s.repo.SetAliases([]string{"../stable"}), ErrorMatches, "invalid distribution alias .*")
+
+	c.Check(s.repo.SetAliases([]string{"testing"}), IsNil)
+	c.Check(s.repo.Aliases, DeepEquals, []string{"testing"})
+	c.Check(s.repo.removedAliases, DeepEquals, []string{"stable"})
+}
+
+func (s *PublishedRepoSuite) TestPublishAliases(c *C) {
+	c.Assert(s.repo.SetAliases([]string{"stable"}), IsNil)
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	publicPath := s.publishedStorage.PublicPath()
+
+	rf, err := os.Open(filepath.Join(publicPath, "ppa/dists/stable/Release"))
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Suite"], Equals, "stable")
+	c.Check(st["Codename"], Equals, "squeeze")
+	c.Check(st["SHA256"], Matches, "(?s).*main/binary-i386/Packages\n.*")
+
+	original, err := os.Stat(filepath.Join(publicPath, "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	alias, err := os.Stat(filepath.Join(publicPath, "ppa/dists/stable/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(os.SameFile(original, alias), Equals, true)
+	c.Check(filepath.Join(publicPath, "ppa/dists/stable/main/binary-i386/Packages.tmp"), Not(PathExists))
+
+	c.Assert(s.repo.SetAliases(nil), IsNil)
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(publicPath, "ppa/dists/stable"), Not(PathExists))
+	c.Check(filepath.Join(publicPath, "ppa/dists/squeeze/Release"), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestCleanupPrefixKeepsAliases(c *C) {
+	c.Assert(s.repo.SetAliases([]string{"stable"}), IsNil)
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	removed, err := collection.CleanupPrefix("", "ppa", s.publishedStorage, s.factory, nil, false)
+	c.Assert(err, IsNil)
+	c.Check(removed, HasLen, 0)
+
+	publicPath := s.publishedStorage.PublicPath()
+	c.Check(filepath.Join(publicPath, "ppa/dists/stable/Release"), PathExists)
+	c.Check(filepath.Join(publicPath, "ppa/dists/stable/main/binary-i386/Packages"), PathExists)
+	c.Check(filepath.Join(publicPath, "ppa/dists/squeeze/Release"), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestSetReplicas(c *C) {
+	s.repo.FailedReplicas = []string{"files:other", "s3:mirror"}
+
+	c.Check(s.repo.SetReplicas([]string{"files:other", " ", "files:other", "files:backup"}), IsNil)
+	c.Check(s.repo.Replicas, DeepEquals, []string{"files:other", "files:backup"})
+	c.Check(s.repo.FailedReplicas, DeepEquals, []string{"files:other"})
+	c.Check(s.repo.Storages(), DeepEquals, []string{"", "files:other", "files:backup"})
+
+	c.Check(s.repo.SetReplicas([]string{""}), IsNil)
+	c.Check(s.repo.Replicas, IsNil)
+	c.Check(s.repo.FailedReplicas, IsNil)
+
+	c.Check(s.repo5.SetReplicas([]string{"files:other"}), ErrorMatches, "replica files:other is the same as storage of published repository")
+}
+
+func (s *PublishedRepoSuite) TestPublishReplicas(c *C) {
+	brokenRoot := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(brokenRoot, nil, 0644), IsNil)
+	s.provider.storages["files:broken"] = files.NewPublishedStorage(filepath.Join(brokenRoot, "public"), "", "")
+
+	c.Assert(s.repo.SetReplicas([]string{"files:other", "files:broken"}), IsNil)
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Assert(err, FitsTypeOf, &ReplicaPublishError{})
+	c.Check(err, ErrorMatches, "publishing to replicas failed \\(files:broken: .*\\)")
+	c.Check(s.repo.FailedReplicas, DeepEquals, []string{"files:broken"})
+
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"), PathExists)
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists/squeeze/Release"), PathExists)
+	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb"), PathExists)
+
+	// once replica is fixed, publishing could be resumed
+	s.provider.storages["files:broken"] = files.NewPublishedStorage(c.MkDir(), "", "")
+
+	err = s.repo.ResumeReplicas(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Assert(err, IsNil)
+	c.Check(s.repo.FailedReplicas, IsNil)
+	c.Check(filepath.Join(s.provider.storages["files:broken"].(*files.PublishedStorage).PublicPath(), "ppa/dists/squeeze/Release"), PathExists)
+
+	c.Check(s.repo.ResumeReplicas(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false),
+		ErrorMatches, "published repository ppa/squeeze has no failed replicas")
+}
+
+func (s *PublishedRepoSuite) TestRefreshReleaseReplicas(c *C) {
+	s.provider.storages["files:failed"] = files.NewPublishedStorage(c.MkDir(), "", "")
+	c.Assert(s.repo.SetReplicas([]string{"files:other", "files:failed"}), IsNil)
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	replicaRelease := filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists/squeeze/Release")
+	failedRelease := filepath.Join(s.provider.storages["files:failed"].(*files.PublishedStorage).PublicPath(), "ppa/dists/squeeze/Release")
+	original, err := os.ReadFile(failedRelease)
+	c.Assert(err, IsNil)
+
+	// failed replica is left for resume, its Release file is not refreshed
+	s.repo.FailedReplicas = []string{"files:failed"}
+	s.repo.ValidFor = time.Hour
+	c.Assert(s.repo.RefreshRelease(s.provider, nil, nil), IsNil)
+
+	refreshed, err := os.ReadFile(replicaRelease)
+	c.Assert(err, IsNil)
+	c.Check(string(refreshed), Matches, "(?s).*Valid-Until: .*")
+	notRefreshed, err := os.ReadFile(failedRelease)
+	c.Assert(err, IsNil)
+	c.Check(string(notRefreshed), Equals, string(original))
+
+	// replica which couldn't be refreshed is recorded as failed
+	c.Assert(os.RemoveAll(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists")), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/dists"), nil, 0644), IsNil)
+
+	err = s.repo.RefreshRelease(s.provider, nil, nil)
+	c.Assert(err, FitsTypeOf, &ReplicaPublishError{})
+	c.Check(err, ErrorMatches, "publishing to replicas failed \\(files:other: .*\\)")
+	c.Check(s.repo.FailedReplicas, DeepEquals, []string{"files:failed", "files:other"})
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestPublishAndSave(c *C) {
+	brokenRoot := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(brokenRoot, nil, 0644), IsNil)
+	s.provider.storages["files:broken"] = files.NewPublishedStorage(filepath.Join(brokenRoot, "public"), "", "")
+
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(s.repo.SetReplicas([]string{"files:broken"}), IsNil)
+
+	// repository is saved if only replicas failed
+	saved, err := collection.PublishAndSave(s.repo, true, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Check(saved, Equals, true)
+	c.Assert(err, FitsTypeOf, &ReplicaPublishError{})
+
+	r, err := collection.ByStoragePrefixDistribution("", "ppa", "squeeze")
+	c.Assert(err, IsNil)
+	c.Check(r.FailedReplicas, DeepEquals, []string{"files:broken"})
+
+	// nothing is saved if publishing to storage fails
+	s.repo2.Storage = "files:broken"
+	saved, err = collection.PublishAndSave(s.repo2, true, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Check(saved, Equals, false)
+	c.Check(err, NotNil)
+	_, err = collection.ByStoragePrefixDistribution("files:broken", s.repo2.Prefix, s.repo2.Distribution)
+	c.Check(err, NotNil)
+
+	saved, err = collection.PublishAndSave(s.repo, true, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, false)
+	c.Check(saved, Equals, false)
+	c.Check(err, ErrorMatches, "unable to save to DB: published repo with storage/prefix/distribution /ppa/squeeze already exists")
+}
+
+func (s *PublishedRepoSuite) TestSetComponentRules(c *C) {
+	rules := []ComponentRule{{Component: "non-free", Query: "mars-invaders"}}
+
+	c.Check(s.repo.SetComponentRules(rules, s.factory), ErrorMatches, "component rules are not supported: no query parser")
+
+	s.factory.SetQueryParser(func(q string) (PackageQuery, error) {
+		if q == "" {
+			return nil, fmt.Errorf("empty query")
+		}
+		return &FieldQuery{Field: "Name", Relation: VersionEqual, Value: q}, nil
+	})
+
+	c.Check(s.repo.SetComponentRules(rules, s.factory), IsNil)
+	c.Check(s.repo.Components(), DeepEquals, []string{"main", "non-free"})
+	c.Check(s.repo.SourceComponents(), DeepEquals, []string{"main"})
+
+	c.Check(s.repo.SetComponentRules([]ComponentRule{{Component: "main", Query: "mars-invaders"}}, s.factory),
+		ErrorMatches, "component main of component rule is already published from source")
+	c.Check(s.repo.SetComponentRules([]ComponentRule{{Component: "non free", Query: "mars-invaders"}}, s.factory),
+		ErrorMatches, "invalid component in component rule: \"non free\"")
+	c.Check(s.repo.SetComponentRules([]ComponentRule{{Component: "non-free", Query: ""}}, s.factory),
+		ErrorMatches, "unable to parse query of component rule non-free=: empty query")
+
+	rule, err := ParseComponentRule("non-free = Section (% non-free*)")
+	c.Check(err, IsNil)
+	c.Check(rule, DeepEquals, ComponentRule{Component: "non-free", Query: "Section (% non-free*)"})
+
+	_, err = ParseComponentRule("non-free")
+	c.Check(err, ErrorMatches, "component rule should be specified as 'component=query': \"non-free\"")
+}
+
+func (s *PublishedRepoSuite) TestPublishComponentRules(c *C) {
+	s.factory.SetQueryParser(func(q string) (PackageQuery, error) {
+		return &FieldQuery{Field: "Name", Relation: VersionEqual, Value: q}, nil
+	})
+	c.Assert(s.repo.SetComponentRules([]ComponentRule{
+		{Component: "non-free", Query: "mars-invaders"},
+		{Component: "contrib", Query: "mars-invaders"},
+	}, s.factory), IsNil)
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false, false)
+	c.Assert(err, IsNil)
+
+	readNames := func(component string) []string {
+		pf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze", component, "binary-i386/Packages"))
+		c.Assert(err, IsNil)
+		defer pf.Close()
+
+		names := []string{}
+		reader := NewControlFileReader(pf, false, false)
+		for {
+			st, err := reader.ReadStanza()
+			c.Assert(err, IsNil)
+			if st == nil {
+				break
+			}
+			names = append(names, st["Package"])
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// package is routed into component of the first matching rule
+	c.Check(readNames("main"), DeepEquals, []string{"alien-arena-common", "lonely-strangers"})
+	c.Check(readNames("non-free"), DeepEquals, []string{"mars-invaders"})
+	c.Check(readNames("contrib"), DeepEquals, []string{})
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/pool/non-free/a/alien-arena/alien-arena-common_7.40-2_i386.deb"), PathExists)
+
+	rf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Components"], Equals, "contrib main non-free")
+
+	// packages published in components are kept with published repo
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	repo, err := NewPublishedRepoCollection(s.db).ByUUID(s.repo.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(NewPublishedRepoCollection(s.db).LoadComplete(repo, s.factory), IsNil)
+	c.Check(repo.ComponentRules, DeepEquals, s.repo.ComponentRules)
+	c.Check(repo.RefList("main").Len(), Equals, 2)
+	c.Check(repo.RefList("non-free").Len(), Equals, 1)
+	c.Check(repo.RefList("contrib").Len(), Equals, 0)
+}
+
+func (s *PublishedRepoSuite) TestPublishesPackageForArchitecture(c *C) {
+	stanza := packageStanza.Copy()
+	stanza["Architecture"] = ArchitectureAll
+	pkg := NewPackageFromControlFile(stanza)
+
+	for _, mode := range []string{"", ArchitectureAllPerArch, ArchitectureAllBoth} {
+		s.repo.ArchitectureAllMode = mode
+		c.Check(s.repo.publishesPackageForArchitecture(pkg, "i386"), Equals, true)
+		c.Check(s.repo.publishesPackageForArchitecture(pkg, ArchitectureAll), Equals, true)
+		c.Check(s.repo.publishesPackageForArchitecture(pkg, ArchitectureSource), Equals, false)
+	}
+
+	s.repo.ArchitectureAllMode = ArchitectureAllSeparate
+	c.Check(s.repo.publishesPackageForArchitecture(pkg, "i386"), Equals, false)
+	c.Check(s.repo.publishesPackageForArchitecture(pkg, ArchitectureAll), Equals, true)
+
+	c.Check(s.repo.publishesPackageForArchitecture(NewPackageFromControlFile(packageStanza.Copy()), "i386"), Equals, true)
+}
@@ -2,9 +2,11 @@ package deb
 
 import (
 	"fmt"
-	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/aptly-dev/aptly/utils"
 )
 
 // PackageLike is something like Package :) To be refined later
@@ -58,6 +60,44 @@ type FieldQuery struct {
 	Relation int
 	Value    string
 	Regexp   *regexp.Regexp `codec:"-"`
+	// CaseInsensitive is set for pattern relations matching ignoring case
+	CaseInsensitive bool `codec:",omitempty"`
+	// Values lists values for VersionIn relation
+	Values []string `codec:",omitempty"`
+}
+
+// packageWithFiles is implemented by packages which could be matched
+// against file-level fields
+type packageWithFiles interface {
+	Files() PackageFiles
+}
+
+// IsFileField checks whether special field is matched against package files
+// rather than package stanza
+func IsFileField(field string) bool {
+	switch field {
+	case "$PackageFile", "$Size", "$MD5", "$SHA1", "$SHA256", "$SHA512":
+		return true
+	}
+	return false
+}
+
+// IsNumericField checks whether special field is compared numerically
+func IsNumericField(field string) bool {
+	switch field {
+	case "$Size", "$Installed-Size", "$Depends-Count":
+		return true
+	}
+	return false
+}
+
+// ParseNumericValue parses value to compare numeric special field against:
+// sizes could have unit suffix, counts are plain integers
+func ParseNumericValue(field, value string) (int64, error) {
+	if field == "$Depends-Count" {
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	}
+	return utils.ParseHumanBytes(value)
 }
 
 // PkgQuery is search request against specific package
@@ -75,6 +115,22 @@ type DependencyQuery struct {
 // MatchAllQuery is query that matches all the packages
 type MatchAllQuery struct{}
 
+// HeldQuery matches packages held in local repo ($Held)
+//
+// Keys of held packages should be resolved from local repo before query is evaluated,
+// unresolved query (e.g. in query against snapshot) doesn't match any package
+type HeldQuery struct {
+	Keys map[string]bool
+}
+
+// PackageSetQuery is a reference to named package set
+//
+// Query Q should be resolved from PackageSet before query is evaluated
+type PackageSetQuery struct {
+	Name string
+	Q    PackageQuery
+}
+
 // Matches if any of L, R matches
 func (q *OrQuery) Matches(pkg PackageLike) bool {
 	return q.L.Matches(pkg) || q.R.Matches(pkg)
@@ -155,15 +211,44 @@ func (q *NotQuery) String() string {
 
 // Matches on generic field
 func (q *FieldQuery) Matches(pkg PackageLike) bool {
+	switch q.Relation {
+	case VersionNotEqual:
+		return !q.equalTo(q.Value).Matches(pkg)
+	case VersionIn:
+		for _, value := range q.Values {
+			if q.equalTo(value).Matches(pkg) {
+				return true
+			}
+		}
+		return false
+	}
+
 	if q.Field == "$Version" {
-		return pkg.MatchesDependency(Dependency{Pkg: pkg.GetName(), Relation: q.Relation, Version: q.Value, Regexp: q.Regexp})
+		return pkg.MatchesDependency(Dependency{Pkg: pkg.GetName(), Relation: q.Relation, Version: q.Value, Regexp: q.Regexp,
+			CaseInsensitive: q.CaseInsensitive})
 	}
 	if q.Field == "$Architecture" && q.Relation == VersionEqual {
 		return pkg.MatchesArchitecture(q.Value)
 	}
 
-	field := pkg.GetField(q.Field)
+	if IsFileField(q.Field) {
+		return q.matchesFiles(pkg)
+	}
+
+	if IsNumericField(q.Field) {
+		return q.matchesNumber(pkg.GetField(q.Field))
+	}
+
+	return q.matchesValue(pkg.GetField(q.Field))
+}
+
+// equalTo returns query matching the same field against value with equal relation
+func (q *FieldQuery) equalTo(value string) *FieldQuery {
+	return &FieldQuery{Field: q.Field, Relation: VersionEqual, Value: value}
+}
 
+// matchesValue matches single field value against condition
+func (q *FieldQuery) matchesValue(field string) bool {
 	switch q.Relation {
 	case VersionDontCare:
 		return field != ""
@@ -177,15 +262,118 @@ func (q *FieldQuery) Matches(pkg PackageLike) bool {
 		return CompareVersions(field, q.Value) < 0
 	case VersionLessOrEqual:
 		return CompareVersions(field, q.Value) <= 0
-	case VersionPatternMatch:
-		matched, err := filepath.Match(q.Value, field)
-		return err == nil && matched
-	case VersionRegexp:
-		if q.Regexp == nil {
-			q.Regexp = regexp.MustCompile(q.Value)
+	case VersionPatternMatch, VersionRegexp, VersionContains:
+		if q.Regexp == nil && q.Relation != VersionPatternMatch {
+			q.Regexp, _ = CompilePattern(q.Relation, q.Value, q.CaseInsensitive)
+		}
+		return matchPattern(q.Regexp, q.Relation, q.Value, q.CaseInsensitive, field)
+	}
+	panic("unknown relation")
+}
+
+// matchesFiles matches special fields against package files: package matches
+// if any of its files matches, $Size is matched against total size of the files
+func (q *FieldQuery) matchesFiles(pkg PackageLike) bool {
+	withFiles, ok := pkg.(packageWithFiles)
+	if !ok {
+		return false
+	}
+
+	files := withFiles.Files()
+
+	if q.Field == "$Size" {
+		var total int64
+		for _, f := range files {
+			total += f.Checksums.Size
+		}
+
+		switch q.Relation {
+		case VersionDontCare:
+			return len(files) > 0
+		case VersionPatternMatch, VersionRegexp, VersionContains:
+			return q.matchesValue(strconv.FormatInt(total, 10))
 		}
-		return q.Regexp.FindStringIndex(field) != nil
 
+		size, err := utils.ParseHumanBytes(q.Value)
+		if err != nil {
+			return false
+		}
+
+		return q.compareNumbers(total, size)
+	}
+
+	for _, f := range files {
+		var value string
+
+		switch q.Field {
+		case "$PackageFile":
+			value = f.Filename
+		case "$MD5":
+			value = f.Checksums.MD5
+		case "$SHA1":
+			value = f.Checksums.SHA1
+		case "$SHA256":
+			value = f.Checksums.SHA256
+		case "$SHA512":
+			value = f.Checksums.SHA512
+		}
+
+		if value == "" {
+			continue
+		}
+
+		if q.Field != "$PackageFile" && q.Relation == VersionEqual {
+			// checksums are compared ignoring case of hex digits
+			if strings.EqualFold(value, q.Value) {
+				return true
+			}
+			continue
+		}
+
+		if q.matchesValue(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesNumber matches numeric field value against condition, packages
+// without the value (or with zero value) match only pattern relations
+func (q *FieldQuery) matchesNumber(field string) bool {
+	switch q.Relation {
+	case VersionDontCare:
+		return field != "" && field != "0"
+	case VersionPatternMatch, VersionRegexp, VersionContains:
+		return q.matchesValue(field)
+	}
+
+	actual, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	expected, err := ParseNumericValue(q.Field, q.Value)
+	if err != nil {
+		return false
+	}
+
+	return q.compareNumbers(actual, expected)
+}
+
+// compareNumbers compares numbers according to relation
+func (q *FieldQuery) compareNumbers(actual, expected int64) bool {
+	switch q.Relation {
+	case VersionEqual:
+		return actual == expected
+	case VersionGreater:
+		return actual > expected
+	case VersionGreaterOrEqual:
+		return actual >= expected
+	case VersionLess:
+		return actual < expected
+	case VersionLessOrEqual:
+		return actual <= expected
 	}
 	panic("unknown relation")
 }
@@ -222,10 +410,23 @@ func (q *FieldQuery) String() string {
 		op = "~"
 	case VersionPatternMatch:
 		op = "%"
+	case VersionContains:
+		op = "*="
 	case VersionGreaterOrEqual:
 		op = ">="
 	case VersionLessOrEqual:
 		op = "<="
+	case VersionNotEqual:
+		op = "!="
+	case VersionIn:
+		values := make([]string, len(q.Values))
+		for i := range q.Values {
+			values[i] = escape(q.Values[i])
+		}
+		return fmt.Sprintf("%s (in (%s))", escape(q.Field), strings.Join(values, "|"))
+	}
+	if q.CaseInsensitive {
+		return fmt.Sprintf("%s (%s %s i)", escape(q.Field), op, escape(q.Value))
 	}
 	return fmt.Sprintf("%s (%s %s)", escape(q.Field), op, escape(q.Value))
 }
@@ -261,17 +462,21 @@ func (q *DependencyQuery) String() string {
 
 // Matches on specific properties
 func (q *PkgQuery) Matches(pkg PackageLike) bool {
-	return pkg.GetName() == q.Pkg && pkg.GetVersion() == q.Version && pkg.GetArchitecture() == q.Arch
+	return pkg.GetName() == q.Pkg && pkg.GetVersion() == q.Version && ArchitectureMatches(q.Arch, pkg.GetArchitecture())
 }
 
-// Fast is always true for package query
+// Fast is true for package query, unless architecture is a wildcard
 func (q *PkgQuery) Fast(_ PackageCatalog) bool {
-	return true
+	return !IsArchitectureWildcard(q.Arch)
 }
 
 // Query looks up specific package
 func (q *PkgQuery) Query(list PackageCatalog) (result *PackageList) {
-	return list.SearchByKey(q.Arch, q.Pkg, q.Version)
+	if q.Fast(list) {
+		return list.SearchByKey(q.Arch, q.Pkg, q.Version)
+	}
+
+	return list.Scan(q)
 }
 
 // String interface
@@ -298,3 +503,59 @@ func (q *MatchAllQuery) Query(list PackageCatalog) (result *PackageList) {
 func (q *MatchAllQuery) String() string {
 	return ""
 }
+
+// Matches if package matches any query of the package set, unresolved package set
+// doesn't match anything (query.Parse refuses queries with package sets, so that
+// shouldn't happen)
+func (q *PackageSetQuery) Matches(pkg PackageLike) bool {
+	return q.Q != nil && q.Q.Matches(pkg)
+}
+
+// Fast depends on the queries of the package set
+func (q *PackageSetQuery) Fast(list PackageCatalog) bool {
+	return q.Q != nil && q.Q.Fast(list)
+}
+
+// Query delegates to the queries of the package set
+func (q *PackageSetQuery) Query(list PackageCatalog) (result *PackageList) {
+	if q.Fast(list) {
+		result = q.Q.Query(list)
+	} else {
+		result = list.Scan(q)
+	}
+	return
+}
+
+// String interface
+func (q *PackageSetQuery) String() string {
+	return fmt.Sprintf("$PackageSet (%s)", q.Name)
+}
+
+// Resolve fills in keys of packages held in local repo
+func (q *HeldQuery) Resolve(repo *LocalRepo) {
+	q.Keys = make(map[string]bool, len(repo.Held))
+	for _, key := range repo.Held {
+		q.Keys[key] = true
+	}
+}
+
+// Matches if package is held
+func (q *HeldQuery) Matches(pkg PackageLike) bool {
+	p, ok := pkg.(*Package)
+	return ok && q.Keys[string(p.Key(""))]
+}
+
+// Fast is false, as list of packages is scanned
+func (q *HeldQuery) Fast(_ PackageCatalog) bool {
+	return false
+}
+
+// Query scans list of packages for held packages
+func (q *HeldQuery) Query(list PackageCatalog) (result *PackageList) {
+	return list.Scan(q)
+}
+
+// String interface
+func (q *HeldQuery) String() string {
+	return "$Held"
+}
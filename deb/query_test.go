@@ -10,7 +10,7 @@ type QuerySuite struct {
 var _ = Suite(&QuerySuite{})
 
 func (s *QuerySuite) TestVersionCompare(c *C) {
-	q := FieldQuery{"Version", VersionLess, "5.0.0.2", nil}
+	q := FieldQuery{Field: "Version", Relation: VersionLess, Value: "5.0.0.2"}
 
 	p100 := Package{}
 	p100.Version = "5.0.0.100"
@@ -21,3 +21,117 @@ func (s *QuerySuite) TestVersionCompare(c *C) {
 	c.Check(q.Matches(&p100), Equals, false)
 	c.Check(q.Matches(&p1), Equals, true)
 }
+
+func (s *QuerySuite) TestFieldPatterns(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionPatternMatch, Value: "contrib/*"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionPatternMatch, Value: "Contrib/*"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionPatternMatch, Value: "Contrib/*", CaseInsensitive: true}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionPatternMatch, Value: "contrib/[g"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "Description", Relation: VersionContains, Value: "deathmatch"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "Description", Relation: VersionContains, Value: "DEATHMATCH"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "Description", Relation: VersionContains, Value: "DEATHMATCH", CaseInsensitive: true}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Version", Relation: VersionContains, Value: "40-"}).Matches(p), Equals, true)
+
+	c.Check((&FieldQuery{Field: "Name", Relation: VersionContains, Value: "foo"}).String(), Equals, "Name (*= foo)")
+	c.Check((&FieldQuery{Field: "Name", Relation: VersionPatternMatch, Value: "FOO*", CaseInsensitive: true}).String(), Equals, "Name (% FOO* i)")
+}
+
+func (s *QuerySuite) TestNotEqualAndIn(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+
+	c.Check((&FieldQuery{Field: "Priority", Relation: VersionNotEqual, Value: "extra"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "Priority", Relation: VersionNotEqual, Value: "optional"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "Essential", Relation: VersionNotEqual, Value: "yes"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Version", Relation: VersionNotEqual, Value: "7.40-2"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Architecture", Relation: VersionNotEqual, Value: "amd64"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionNotEqual, Value: "187518"}).Matches(p), Equals, false)
+
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionIn, Values: []string{"libs", "contrib/games"}}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionIn, Values: []string{"libs", "libdevel"}}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionIn}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Architecture", Relation: VersionIn, Values: []string{"amd64", "linux-any"}}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionIn, Values: []string{"456K", "1M"}}).Matches(p), Equals, true)
+
+	c.Check((&FieldQuery{Field: "Priority", Relation: VersionNotEqual, Value: "extra"}).String(), Equals, "Priority (!= extra)")
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionIn, Values: []string{"libs", "non-free/libs"}}).String(), Equals,
+		"Section (in (libs|non-free/libs))")
+	c.Check((&FieldQuery{Field: "Section", Relation: VersionIn, Values: []string{"a b", "c"}}).String(), Equals,
+		"Section (in ('a b'|c))")
+}
+
+func (s *QuerySuite) TestFileFields(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+
+	c.Check((&FieldQuery{Field: "$PackageFile", Relation: VersionPatternMatch, Value: "alien-arena-common_*.deb"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$PackageFile", Relation: VersionPatternMatch, Value: "*dbgsym*"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$PackageFile"}).Matches(p), Equals, true)
+
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionEqual, Value: "187518"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionGreaterOrEqual, Value: "100KB"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionGreater, Value: "1MB"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionLess, Value: "1MiB"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionLessOrEqual, Value: "183K"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionPatternMatch, Value: "187*"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Size", Relation: VersionEqual, Value: "lots"}).Matches(p), Equals, false)
+
+	c.Check((&FieldQuery{Field: "$MD5", Relation: VersionEqual, Value: "1e8cba92c41420aa7baa8a5718d67122"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$MD5", Relation: VersionEqual, Value: "1E8CBA92C41420AA7BAA8A5718D67122"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$MD5", Relation: VersionEqual, Value: "1e8cba92c41420aa7baa8a5718d67123"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$SHA256", Relation: VersionContains, Value: "eb4afb9885cba6dc70cccd05b910b2dbccc02c5900578be5e99f0d3dbf9d76a5"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$SHA512"}).Matches(p), Equals, false)
+
+	c.Check(IsFileField("$SHA1"), Equals, true)
+	c.Check(IsFileField("SHA1"), Equals, false)
+}
+
+func (s *QuerySuite) TestNumericFields(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+
+	c.Check((&FieldQuery{Field: "$Installed-Size"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionEqual, Value: "456KiB"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionGreaterOrEqual, Value: "400KB"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionGreaterOrEqual, Value: "500MB"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionLess, Value: "1MB"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionGreater, Value: "lots"}).Matches(p), Equals, false)
+
+	c.Check((&FieldQuery{Field: "$Depends-Count"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Depends-Count", Relation: VersionEqual, Value: "2"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Depends-Count", Relation: VersionGreater, Value: "2"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$Depends-Count", Relation: VersionLessOrEqual, Value: "10"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Depends-Count", Relation: VersionPatternMatch, Value: "[0-2]"}).Matches(p), Equals, true)
+
+	source, _ := NewSourcePackageFromControlFile(Stanza{"Package": "alien-arena", "Version": "7.40-2", "Architecture": "any"})
+	c.Check((&FieldQuery{Field: "$Installed-Size"}).Matches(source), Equals, false)
+	c.Check((&FieldQuery{Field: "$Installed-Size", Relation: VersionLess, Value: "1MB"}).Matches(source), Equals, false)
+	c.Check((&FieldQuery{Field: "$Depends-Count"}).Matches(source), Equals, false)
+	c.Check((&FieldQuery{Field: "$Depends-Count", Relation: VersionEqual, Value: "0"}).Matches(source), Equals, true)
+
+	c.Check(IsNumericField("$Installed-Size"), Equals, true)
+	c.Check(IsNumericField("Installed-Size"), Equals, false)
+}
+
+func (s *QuerySuite) TestArchitectureWildcards(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+
+	c.Check((&FieldQuery{Field: "$Architecture", Relation: VersionEqual, Value: "linux-any"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$Architecture", Relation: VersionEqual, Value: "any-amd64"}).Matches(p), Equals, false)
+
+	list := NewPackageList()
+	_ = list.Add(p)
+	list.PrepareIndex()
+
+	q := &PkgQuery{Pkg: "alien-arena-common", Version: "7.40-2", Arch: "i386"}
+	c.Check(q.Fast(list), Equals, true)
+	c.Check(q.Query(list).Len(), Equals, 1)
+
+	q = &PkgQuery{Pkg: "alien-arena-common", Version: "7.40-2", Arch: "any-i386"}
+	c.Check(q.Fast(list), Equals, false)
+	c.Check(q.Matches(p), Equals, true)
+	c.Check(q.Query(list).Len(), Equals, 1)
+
+	q = &PkgQuery{Pkg: "alien-arena-common", Version: "7.40-2", Arch: "hurd-any"}
+	c.Check(q.Matches(p), Equals, false)
+	c.Check(q.Query(list).Len(), Equals, 0)
+}
@@ -0,0 +1,103 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SnapshotReferences lists objects which depend on the snapshot
+type SnapshotReferences struct {
+	// Published repositories which publish the snapshot
+	Published []*PublishedRepo
+	// Snapshots which were created with the snapshot as a source (merge, pull, filter, ...)
+	Snapshots []*Snapshot
+}
+
+// SnapshotReferences looks up published repositories and snapshots referencing the snapshot
+func (factory *CollectionFactory) SnapshotReferences(snapshot *Snapshot) *SnapshotReferences {
+	return &SnapshotReferences{
+		Published: factory.PublishedRepoCollection().BySnapshot(snapshot),
+		Snapshots: factory.SnapshotCollection().BySnapshotSource(snapshot),
+	}
+}
+
+// Empty returns true if snapshot is not referenced at all
+func (refs *SnapshotReferences) Empty() bool {
+	return len(refs.Published) == 0 && len(refs.Snapshots) == 0
+}
+
+// CheckDrop verifies whether snapshot could be dropped
+//
+// Published snapshot can't be dropped even with force, as published repository
+// depends on the snapshot to be updated or switched; snapshot which was used
+// as a source for other snapshots can be dropped only with force.
+func (refs *SnapshotReferences) CheckDrop(force bool) error {
+	if len(refs.Published) > 0 {
+		return fmt.Errorf("snapshot is published")
+	}
+
+	if len(refs.Snapshots) > 0 && !force {
+		return fmt.Errorf("won't delete snapshot that was used as source for other snapshots")
+	}
+
+	return nil
+}
+
+// PackageRefCounter counts references to packages from mirrors, local repos,
+// snapshots and published repositories
+//
+// Package is safe to be removed only if its reference count drops to zero.
+type PackageRefCounter struct {
+	counts map[string]int
+}
+
+// NewPackageRefCounter creates empty PackageRefCounter
+func NewPackageRefCounter() *PackageRefCounter {
+	return &PackageRefCounter{counts: make(map[string]int)}
+}
+
+// Add increments reference count for every package in the list
+func (c *PackageRefCounter) Add(list *PackageRefList) {
+	if list == nil {
+		return
+	}
+
+	for _, ref := range list.Refs {
+		c.counts[string(ref)]++
+	}
+}
+
+// Count returns number of references to the package
+func (c *PackageRefCounter) Count(ref []byte) int {
+	return c.counts[string(ref)]
+}
+
+// Len returns number of referenced packages
+func (c *PackageRefCounter) Len() int {
+	return len(c.counts)
+}
+
+// Referenced returns list of all packages with non-zero reference count
+func (c *PackageRefCounter) Referenced() *PackageRefList {
+	result := &PackageRefList{Refs: make([][]byte, 0, len(c.counts))}
+
+	for key := range c.counts {
+		result.Refs = append(result.Refs, []byte(key))
+	}
+
+	sort.Sort(result)
+	return result
+}
+
+// Unreferenced returns all packages in the list which are not referenced
+func (c *PackageRefCounter) Unreferenced(list *PackageRefList) *PackageRefList {
+	result := &PackageRefList{Refs: make([][]byte, 0, 128)}
+
+	for _, ref := range list.Refs {
+		if c.counts[string(ref)] == 0 {
+			result.Refs = append(result.Refs, ref)
+		}
+	}
+
+	return result
+}
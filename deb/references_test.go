@@ -0,0 +1,71 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotReferencesSuite struct {
+	db      database.Storage
+	factory *CollectionFactory
+}
+
+var _ = Suite(&SnapshotReferencesSuite{})
+
+func (s *SnapshotReferencesSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+}
+
+func (s *SnapshotReferencesSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *SnapshotReferencesSuite) TestSnapshotReferences(c *C) {
+	snap1 := NewSnapshotFromRefList("snap1", nil, NewPackageRefList(), "")
+	snap2 := NewSnapshotFromRefList("snap2", []*Snapshot{snap1}, NewPackageRefList(), "Merged from sources: 'snap1'")
+	c.Assert(s.factory.SnapshotCollection().Add(snap1), IsNil)
+	c.Assert(s.factory.SnapshotCollection().Add(snap2), IsNil)
+
+	refs := s.factory.SnapshotReferences(snap2)
+	c.Check(refs.Empty(), Equals, true)
+	c.Check(refs.CheckDrop(false), IsNil)
+
+	refs = s.factory.SnapshotReferences(snap1)
+	c.Check(refs.Empty(), Equals, false)
+	c.Check(refs.Snapshots, HasLen, 1)
+	c.Check(refs.CheckDrop(false), ErrorMatches, "won't delete snapshot that was used as source for other snapshots")
+	c.Check(refs.CheckDrop(true), IsNil)
+
+	published, err := NewPublishedRepo("", "ppa", "squeeze", []string{"i386"}, []string{"main"}, []interface{}{snap1}, s.factory)
+	c.Assert(err, IsNil)
+	c.Assert(s.factory.PublishedRepoCollection().Add(published), IsNil)
+
+	refs = s.factory.SnapshotReferences(snap1)
+	c.Check(refs.Published, HasLen, 1)
+	c.Check(refs.CheckDrop(false), ErrorMatches, "snapshot is published")
+	c.Check(refs.CheckDrop(true), ErrorMatches, "snapshot is published")
+}
+
+type PackageRefCounterSuite struct{}
+
+var _ = Suite(&PackageRefCounterSuite{})
+
+func (s *PackageRefCounterSuite) TestCounting(c *C) {
+	list1 := &PackageRefList{Refs: [][]byte{[]byte("Pall a 1"), []byte("Pi386 b 1")}}
+	list2 := &PackageRefList{Refs: [][]byte{[]byte("Pi386 b 1"), []byte("Pi386 c 1")}}
+	all := &PackageRefList{Refs: [][]byte{[]byte("Pall a 1"), []byte("Pi386 b 1"), []byte("Pi386 c 1"), []byte("Pi386 d 1")}}
+
+	counter := NewPackageRefCounter()
+	counter.Add(list1)
+	counter.Add(list2)
+	counter.Add(nil)
+
+	c.Check(counter.Len(), Equals, 3)
+	c.Check(counter.Count([]byte("Pi386 b 1")), Equals, 2)
+	c.Check(counter.Count([]byte("Pi386 d 1")), Equals, 0)
+	c.Check(counter.Referenced().Strings(), DeepEquals, []string{"Pall a 1", "Pi386 b 1", "Pi386 c 1"})
+	c.Check(counter.Unreferenced(all).Strings(), DeepEquals, []string{"Pi386 d 1"})
+}
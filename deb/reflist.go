@@ -3,6 +3,7 @@ package deb
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"sort"
 
 	"github.com/AlekSi/pointer"
@@ -91,8 +92,11 @@ func (l *PackageRefList) ForEach(handler func([]byte) error) error {
 
 // Has checks whether package is part of reflist
 func (l *PackageRefList) Has(p *Package) bool {
-	key := p.Key("")
+	return l.HasKey(p.Key(""))
+}
 
+// HasKey checks whether package with key is part of reflist
+func (l *PackageRefList) HasKey(key []byte) bool {
 	i := sort.Search(len(l.Refs), func(j int) bool { return bytes.Compare(l.Refs[j], key) >= 0 })
 	return i < len(l.Refs) && bytes.Equal(l.Refs[i], key)
 }
@@ -391,3 +395,92 @@ func (l *PackageRefList) FilterLatestRefs() {
 		lastArch, lastName, lastVer = arch, name, ver
 	}
 }
+
+// RetainVersions returns refs of l (current packages) extended with refs from previous list
+// which are among keep latest versions of the same package (name and architecture); previous
+// versions are retained only for packages which are still present in l
+func (l *PackageRefList) RetainVersions(previous *PackageRefList, keep int) *PackageRefList {
+	if keep <= 1 || previous == nil {
+		return l
+	}
+
+	merged := l.Merge(previous, false, true)
+	result := &PackageRefList{Refs: make([][]byte, 0, merged.Len())}
+
+	// refs are sorted, so all the versions of package with the same name and
+	// architecture follow each other
+	for start := 0; start < len(merged.Refs); {
+		parts := bytes.SplitN(merged.Refs[start], []byte(" "), 3)
+		prefix := append(append([]byte(nil), parts[0]...), ' ')
+		prefix = append(append(prefix, parts[1]...), ' ')
+
+		end := start + 1
+		for end < len(merged.Refs) && bytes.HasPrefix(merged.Refs[end], prefix) {
+			end++
+		}
+
+		group := merged.Refs[start:end]
+		start = end
+
+		current := false
+		for _, ref := range group {
+			if l.HasKey(ref) {
+				current = true
+				break
+			}
+		}
+
+		if !current {
+			continue
+		}
+
+		versions := make([]string, 0, len(group))
+		for _, ref := range group {
+			version := string(bytes.SplitN(ref, []byte(" "), 4)[2])
+			if len(versions) == 0 || versions[len(versions)-1] != version {
+				versions = append(versions, version)
+			}
+		}
+
+		sort.Slice(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+		if len(versions) > keep {
+			versions = versions[:keep]
+		}
+
+		retained := make(map[string]bool, len(versions))
+		for _, version := range versions {
+			retained[version] = true
+		}
+
+		for _, ref := range group {
+			version := string(bytes.SplitN(ref, []byte(" "), 4)[2])
+			if l.HasKey(ref) || retained[version] {
+				result.Refs = append(result.Refs, ref)
+			}
+		}
+	}
+
+	return result
+}
+
+// WithoutExtraSourceOnly returns reflist without source packages marked with Extra-Source-Only: yes
+func (l *PackageRefList) WithoutExtraSourceOnly(packageCollection *PackageCollection) (*PackageRefList, error) {
+	result := &PackageRefList{Refs: make([][]byte, 0, l.Len())}
+
+	for _, ref := range l.Refs {
+		if bytes.HasPrefix(ref, []byte("P"+ArchitectureSource+" ")) {
+			p, err := packageCollection.ByKey(ref)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load package %s: %s", ref, err)
+			}
+
+			if p.IsExtraSourceOnly() {
+				continue
+			}
+		}
+
+		result.Refs = append(result.Refs, ref)
+	}
+
+	return result, nil
+}
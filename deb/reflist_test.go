@@ -1,6 +1,7 @@
 package deb
 
 import (
+	"bytes"
 	"errors"
 
 	"github.com/aptly-dev/aptly/database/goleveldb"
@@ -387,3 +388,66 @@ func (s *PackageRefListSuite) TestFilterLatestRefs(c *C) {
 	c.Check(toStrSlice(result), DeepEquals,
 		[]string{"Pi386 dpkg 1.6", "Pi386 lib 1.2"})
 }
+
+func (s *PackageRefListSuite) TestRetainVersions(c *C) {
+	previous := NewPackageList()
+	for _, p := range []*Package{
+		{Name: "lib", Version: "1.0", Architecture: "i386"},
+		{Name: "lib", Version: "1.1", Architecture: "i386"},
+		{Name: "lib", Version: "1.2~rc1", Architecture: "i386"},
+		{Name: "lib", Version: "1.1", Architecture: "amd64"},
+		{Name: "lib-dev", Version: "1.1", Architecture: "i386"},
+		{Name: "gone", Version: "0.9", Architecture: "i386"},
+	} {
+		previous.Add(p)
+	}
+
+	current := NewPackageList()
+	for _, p := range []*Package{
+		{Name: "lib", Version: "1.2", Architecture: "i386"},
+		{Name: "lib", Version: "1.2", Architecture: "amd64"},
+		{Name: "lib-dev", Version: "1.2", Architecture: "i386"},
+	} {
+		current.Add(p)
+	}
+
+	currentRefs := NewPackageRefListFromPackageList(current)
+	previousRefs := NewPackageRefListFromPackageList(previous)
+
+	c.Check(currentRefs.RetainVersions(previousRefs, 0), Equals, currentRefs)
+	c.Check(currentRefs.RetainVersions(previousRefs, 1), Equals, currentRefs)
+
+	c.Check(toStrSlice(currentRefs.RetainVersions(previousRefs, 2)), DeepEquals,
+		[]string{"Pamd64 lib 1.1", "Pamd64 lib 1.2", "Pi386 lib 1.2", "Pi386 lib 1.2~rc1", "Pi386 lib-dev 1.1", "Pi386 lib-dev 1.2"})
+	c.Check(toStrSlice(currentRefs.RetainVersions(previousRefs, 3)), DeepEquals,
+		[]string{"Pamd64 lib 1.1", "Pamd64 lib 1.2", "Pi386 lib 1.1", "Pi386 lib 1.2", "Pi386 lib 1.2~rc1", "Pi386 lib-dev 1.1", "Pi386 lib-dev 1.2"})
+}
+
+func (s *PackageRefListSuite) TestWithoutExtraSourceOnly(c *C) {
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	coll := NewPackageCollection(db)
+
+	stanza, _ := NewControlFileReader(bytes.NewBufferString(sourcePackageMeta), false, false).ReadStanza()
+	regular, _ := NewSourcePackageFromControlFile(stanza.Copy())
+
+	stanza["Version"] = "0.1-1"
+	stanza["Extra-Source-Only"] = "yes"
+	extra, _ := NewSourcePackageFromControlFile(stanza)
+
+	for _, p := range []*Package{s.p1, s.p3, regular, extra} {
+		c.Assert(coll.Update(p), IsNil)
+		s.list.Add(p)
+	}
+
+	reflist := NewPackageRefListFromPackageList(s.list)
+	result, err := reflist.WithoutExtraSourceOnly(coll)
+	c.Assert(err, IsNil)
+	c.Check(result.Len(), Equals, 3)
+	c.Check(result.Has(extra), Equals, false)
+	c.Check(result.Has(regular), Equals, true)
+	c.Check(result.Has(s.p1), Equals, true)
+
+	reflist.Refs = append(reflist.Refs, []byte("Psource unknown 1.0 00000000"))
+	_, err = reflist.WithoutExtraSourceOnly(coll)
+	c.Check(err, ErrorMatches, "unable to load package Psource unknown.*")
+}
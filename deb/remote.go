@@ -3,8 +3,10 @@ package deb
 import (
 	"bytes"
 	gocontext "context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
@@ -38,8 +40,10 @@ type RemoteRepo struct {
 	UUID string
 	// User-assigned name
 	Name string
-	// Root of Debian archive, URL
+	// Root of Debian archive, URL (or list of mirrors, prefixed with mirror+)
 	ArchiveRoot string
+	// AlternateURLs are roots of other mirrors of the same archive, used for fail-over
+	AlternateURLs []string `codec:",omitempty" json:",omitempty"`
 	// Distribution name, e.g. squeeze
 	Distribution string
 	// List of components to fetch, if empty, then fetch all components
@@ -52,8 +56,17 @@ type RemoteRepo struct {
 	LastDownloadDate time.Time
 	// Checksums for release files
 	ReleaseFiles map[string]utils.ChecksumInfo `json:"-"` // exclude from json output
+	// HTTP cache validators of Release files downloaded during last fetch
+	ReleaseValidators map[string]aptly.HTTPValidators `codec:",omitempty" json:"-"`
+	// Digest of package indexes and settings used during last successful update
+	IndexesDigest string `codec:",omitempty" json:"-"`
 	// Filter for packages
 	Filter string
+	// IncludeSections and ExcludeSections limit packages being mirrored by Section
+	IncludeSections []string `codec:",omitempty" json:",omitempty"`
+	ExcludeSections []string `codec:",omitempty" json:",omitempty"`
+	// MinPriority skips packages with lower Priority while parsing package indexes
+	MinPriority string `codec:",omitempty" json:",omitempty"`
 	// Status marks state of repository (being updated, no action)
 	Status int
 	// WorkerPID is PID of the process modifying the mirror (if any)
@@ -70,14 +83,54 @@ type RemoteRepo struct {
 	DownloadUdebs bool
 	// Should we download installer files?
 	DownloadInstaller bool
+	// UsePDiffs enables updating package indexes with pdiffs
+	UsePDiffs bool `codec:",omitempty" json:",omitempty"`
+	// Proxy is URL of HTTP(S) proxy used to access repository
+	Proxy string `codec:",omitempty" json:",omitempty"`
+	// Username for HTTP authentication
+	Username string `codec:",omitempty" json:",omitempty"`
+	// PasswordFile is path to file with password for HTTP authentication,
+	// PasswordEnv is name of environment variable with the password; password
+	// itself is never stored in the database
+	PasswordFile string `codec:",omitempty" json:",omitempty"`
+	PasswordEnv  string `codec:",omitempty" json:",omitempty"`
+	// Client TLS certificate, key and CA certificates (paths to PEM files)
+	TLSClientCert string `codec:",omitempty" json:",omitempty"`
+	TLSClientKey  string `codec:",omitempty" json:",omitempty"`
+	TLSCACert     string `codec:",omitempty" json:",omitempty"`
+	// AptlyAPI is URL of upstream aptly API, if mirror replicates repository published by another aptly
+	AptlyAPI string `codec:",omitempty" json:",omitempty"`
+	// AptlyPrefix is [storage:]prefix of repository published by upstream aptly
+	AptlyPrefix string `codec:",omitempty" json:",omitempty"`
+	// UpstreamSourceKind and UpstreamSources describe what upstream aptly has published (as of last fetch):
+	// kind of sources (snapshot, local) and names of sources per component
+	UpstreamSourceKind string            `codec:",omitempty" json:",omitempty"`
+	UpstreamSources    map[string]string `codec:",omitempty" json:",omitempty"`
+	// DebianSnapshotArchive and DebianSnapshotTime are set if mirror follows state of the archive
+	// on snapshot.debian.org as of some moment in time
+	DebianSnapshotArchive string    `codec:",omitempty" json:",omitempty"`
+	DebianSnapshotTime    time.Time `codec:",omitempty" json:",omitempty"`
+	// ChecksumPolicy defines how downloaded files are verified: strict, permissive or default (empty)
+	ChecksumPolicy string `codec:",omitempty" json:",omitempty"`
+	// Quarantine keeps files failing verification in quarantine directory, skipping packages they belong to
+	Quarantine bool `codec:",omitempty" json:",omitempty"`
+	// KeepVersions (if greater than one) is number of latest versions of every package to keep
+	// in the mirror on update, even if upstream no longer lists them
+	KeepVersions int `codec:",omitempty" json:",omitempty"`
+	// UpdateHistory summarizes latest updates of the mirror, most recent first
+	UpdateHistory []MirrorUpdateRecord `codec:",omitempty" json:",omitempty"`
 	// Packages for json output
 	Packages []string `codec:"-" json:",omitempty"`
 	// "Snapshot" of current list of packages
 	packageRefs *PackageRefList
 	// Parsed archived root
 	archiveRootURL *url.URL
+	// Mirrors of the archive ordered by preference (filled by SelectMirror)
+	mirrors []string
 	// Current list of packages (filled while updating mirror)
 	packageList *PackageList
+	// Directory to keep copies of package indexes in (for pdiffs)
+	indexCacheDir string
 }
 
 // NewRemoteRepo creates new instance of Debian remote repository with specified params
@@ -112,6 +165,9 @@ func NewRemoteRepo(name string, archiveRoot string, distribution string, compone
 		if result.DownloadUdebs {
 			return nil, fmt.Errorf("debian-installer udebs aren't supported for flat repos")
 		}
+		if result.DownloadInstaller {
+			return nil, fmt.Errorf("debian-installer images aren't supported for flat repos")
+		}
 		result.Components = nil
 	}
 
@@ -128,7 +184,7 @@ func (repo *RemoteRepo) prepare() error {
 	var err error
 
 	// Add final / to URL
-	if !strings.HasSuffix(repo.ArchiveRoot, "/") {
+	if !IsMirrorList(repo.ArchiveRoot) && !strings.HasSuffix(repo.ArchiveRoot, "/") {
 		repo.ArchiveRoot = repo.ArchiveRoot + "/"
 	}
 
@@ -155,6 +211,33 @@ func (repo *RemoteRepo) String() string {
 	return fmt.Sprintf("[%s]: %s %s%s", repo.Name, repo.ArchiveRoot, distribution, srcFlag)
 }
 
+// DownloaderOptions returns proxy, credentials and certificates used to access repository
+func (repo *RemoteRepo) DownloaderOptions() (aptly.DownloaderOptions, error) {
+	options := aptly.DownloaderOptions{
+		Proxy:         repo.Proxy,
+		Username:      repo.Username,
+		TLSClientCert: repo.TLSClientCert,
+		TLSClientKey:  repo.TLSClientKey,
+		TLSCACert:     repo.TLSCACert,
+	}
+
+	if repo.PasswordFile != "" {
+		password, err := os.ReadFile(repo.PasswordFile)
+		if err != nil {
+			return options, fmt.Errorf("unable to read password file: %s", err)
+		}
+		options.Password = strings.TrimRight(string(password), "\r\n")
+	} else if repo.PasswordEnv != "" {
+		password, ok := os.LookupEnv(repo.PasswordEnv)
+		if !ok {
+			return options, fmt.Errorf("environment variable %s with password is not set", repo.PasswordEnv)
+		}
+		options.Password = password
+	}
+
+	return options, nil
+}
+
 // IsFlat determines if repository is flat
 func (repo *RemoteRepo) IsFlat() bool {
 	// aptly < 0.5.1 had Distribution = "" for flat repos
@@ -213,6 +296,10 @@ func (repo *RemoteRepo) CheckLock() error {
 
 // IndexesRootURL builds URL for various indexes
 func (repo *RemoteRepo) IndexesRootURL() *url.URL {
+	return repo.indexesRootURL(repo.archiveRootURL)
+}
+
+func (repo *RemoteRepo) indexesRootURL(archiveRootURL *url.URL) *url.URL {
 	var path *url.URL
 
 	if !repo.IsFlat() {
@@ -221,7 +308,7 @@ func (repo *RemoteRepo) IndexesRootURL() *url.URL {
 		path = &url.URL{Path: repo.Distribution}
 	}
 
-	return repo.archiveRootURL.ResolveReference(path)
+	return archiveRootURL.ResolveReference(path)
 }
 
 // ReleaseURL returns URL to Release* files in repo root
@@ -274,32 +361,117 @@ func (repo *RemoteRepo) PackageURL(filename string) *url.URL {
 
 // Fetch updates information about repository
 func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreSignatures bool) error {
+	_, err := repo.fetch(d, verifier, ignoreSignatures, false)
+	return err
+}
+
+// FetchIfModified is like Fetch, but it skips download of Release files if they haven't been
+// modified since last fetch (according to HTTP conditional requests)
+//
+// Returns false if Release files haven't been modified
+func (repo *RemoteRepo) FetchIfModified(d aptly.Downloader, verifier pgp.Verifier, ignoreSignatures bool) (bool, error) {
+	return repo.fetch(d, verifier, ignoreSignatures, true)
+}
+
+// releaseModified performs conditional requests for Release files fetched last time,
+// files downloaded while checking are returned to be re-used
+func (repo *RemoteRepo) releaseModified(d aptly.Downloader) (bool, map[string]*os.File, map[string]aptly.HTTPValidators) {
+	downloaded := map[string]*os.File{}
+	validators := map[string]aptly.HTTPValidators{}
+
+	// validators are recorded only if signature has been verified during last fetch
+	if len(repo.ReleaseValidators) == 0 || repo.ReleaseFiles == nil || repo.Meta == nil {
+		return true, downloaded, validators
+	}
+
+	modified := false
+	for name, stored := range repo.ReleaseValidators {
+		if stored.Empty() {
+			modified = true
+			continue
+		}
+
+		v := stored
+		file, fileModified, err := http.DownloadTempIfModified(gocontext.TODO(), d, repo.ReleaseURL(name).String(), &v)
+		if err != nil {
+			modified = true
+			continue
+		}
+		if fileModified {
+			modified = true
+			downloaded[name] = file
+			validators[name] = v
+		}
+	}
+
+	return modified, downloaded, validators
+}
+
+func (repo *RemoteRepo) fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreSignatures bool, conditional bool) (bool, error) {
 	var (
 		release, inrelease, releasesig *os.File
 		err                            error
 	)
 
+	downloaded := map[string]*os.File{}
+	validators := map[string]aptly.HTTPValidators{}
+
+	err = repo.SelectMirror(d)
+	if err != nil {
+		return false, err
+	}
+
+	if conditional {
+		var modified bool
+
+		modified, downloaded, validators = repo.releaseModified(d)
+		if !modified {
+			// Release hasn't changed, but settings of the mirror might have changed
+			return false, repo.checkArchitecturesComponents(repo.Meta)
+		}
+	}
+
+	defer func() {
+		for _, file := range downloaded {
+			file.Close()
+		}
+	}()
+
+	downloadRelease := func(name string) (*os.File, error) {
+		if file, ok := downloaded[name]; ok {
+			delete(downloaded, name)
+			return file, nil
+		}
+
+		v := aptly.HTTPValidators{}
+		file, _, e := http.DownloadTempIfModified(gocontext.TODO(), d, repo.ReleaseURL(name).String(), &v)
+		if e == nil {
+			validators[name] = v
+		}
+		return file, e
+	}
+
 	if ignoreSignatures {
 		// 0. Just download release file to temporary URL
-		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release").String())
+		release, err = downloadRelease("Release")
 		if err != nil {
 			// 0.1 try downloading InRelease, ignore and strip signature
-			inrelease, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("InRelease").String())
+			inrelease, err = downloadRelease("InRelease")
 			if err != nil {
-				return err
+				return true, err
 			}
 			if verifier == nil {
-				return fmt.Errorf("no verifier specified")
+				return true, fmt.Errorf("no verifier specified")
 			}
 			release, err = verifier.ExtractClearsigned(inrelease)
 			if err != nil {
-				return err
+				return true, err
 			}
 			goto ok
 		}
 	} else {
 		// 1. try InRelease file
-		inrelease, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("InRelease").String())
+		inrelease, err = downloadRelease("InRelease")
 		if err != nil {
 			goto splitsignature
 		}
@@ -321,24 +493,24 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreS
 
 	splitsignature:
 		// 2. try Release + Release.gpg
-		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release").String())
+		release, err = downloadRelease("Release")
 		if err != nil {
-			return err
+			return true, err
 		}
 
-		releasesig, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release.gpg").String())
+		releasesig, err = downloadRelease("Release.gpg")
 		if err != nil {
-			return err
+			return true, err
 		}
 
 		err = verifier.VerifyDetachedSignature(releasesig, release, true)
 		if err != nil {
-			return err
+			return true, err
 		}
 
 		_, err = release.Seek(0, 0)
 		if err != nil {
-			return err
+			return true, err
 		}
 	}
 ok:
@@ -348,9 +520,34 @@ ok:
 	sreader := NewControlFileReader(release, true, false)
 	stanza, err := sreader.ReadStanza()
 	if err != nil {
-		return err
+		return true, err
+	}
+
+	err = repo.checkArchitecturesComponents(stanza)
+	if err != nil {
+		return true, err
 	}
 
+	err = repo.parseReleaseFiles(stanza)
+	if err != nil {
+		return true, err
+	}
+
+	repo.Meta = stanza
+	if ignoreSignatures {
+		// Release which hasn't been verified shouldn't be trusted later on
+		repo.ReleaseValidators = nil
+	} else {
+		repo.ReleaseValidators = validators
+	}
+
+	return true, nil
+}
+
+// checkArchitecturesComponents verifies list of architectures & components against Release file
+func (repo *RemoteRepo) checkArchitecturesComponents(stanza Stanza) error {
+	var err error
+
 	if !repo.IsFlat() {
 		architectures := strings.Split(stanza["Architectures"], " ")
 		sort.Strings(architectures)
@@ -384,6 +581,13 @@ ok:
 		}
 	}
 
+	return nil
+}
+
+// parseReleaseFiles fills checksums of files listed in Release file, removing them from stanza
+func (repo *RemoteRepo) parseReleaseFiles(stanza Stanza) error {
+	var err error
+
 	repo.ReleaseFiles = make(map[string]utils.ChecksumInfo)
 
 	parseSums := func(field string, setter func(sum *utils.ChecksumInfo, data string)) error {
@@ -432,24 +636,11 @@ ok:
 		return err
 	}
 
-	err = parseSums("SHA512", func(sum *utils.ChecksumInfo, data string) { sum.SHA512 = data })
-	if err != nil {
-		return err
-	}
-
-	repo.Meta = stanza
-
-	return nil
+	return parseSums("SHA512", func(sum *utils.ChecksumInfo, data string) { sum.SHA512 = data })
 }
 
-// DownloadPackageIndexes downloads & parses package index files
-func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.Downloader, verifier pgp.Verifier, _ *CollectionFactory, ignoreSignatures bool, ignoreChecksums bool) error {
-	if repo.packageList != nil {
-		panic("packageList != nil")
-	}
-	repo.packageList = NewPackageList()
-
-	// Download and parse all Packages & Source files
+// packageIndexPaths returns list of package indexes to be downloaded as tuples (path, kind, component, architecture)
+func (repo *RemoteRepo) packageIndexPaths() [][]string {
 	packagesPaths := [][]string{}
 
 	if repo.IsFlat() {
@@ -474,11 +665,102 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 		}
 	}
 
-	for _, info := range packagesPaths {
+	return packagesPaths
+}
+
+// indexesDigest calculates digest of package indexes checksums (as listed in Release file) and
+// settings of the mirror which affect list of packages
+//
+// Empty string is returned if checksum is not available for some package index
+func (repo *RemoteRepo) indexesDigest() string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%s\n%v %v %v %v\n%s\n%s\n", repo.Filter, repo.FilterWithDeps, repo.DownloadSources, repo.DownloadUdebs,
+		repo.DownloadInstaller, strings.Join(repo.Components, " "), strings.Join(repo.Architectures, " "))
+	if len(repo.IncludeSections) > 0 || len(repo.ExcludeSections) > 0 || repo.MinPriority != "" {
+		fmt.Fprintf(h, "%s\n%s\n%s\n", strings.Join(repo.IncludeSections, " "), strings.Join(repo.ExcludeSections, " "), repo.MinPriority)
+	}
+
+	for _, info := range repo.packageIndexPaths() {
+		found := false
+
+		for _, ext := range []string{"", ".bz2", ".gz", ".xz"} {
+			if sum, ok := repo.ReleaseFiles[info[0]+ext]; ok {
+				found = true
+				fmt.Fprintf(h, "%s %d %s %s\n", info[0]+ext, sum.Size, sum.MD5, sum.SHA256)
+			}
+		}
+
+		if !found {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// IndexesUnchanged returns true if package indexes and mirror settings are the same as
+// during last successful update, so that mirror contents would stay the same
+func (repo *RemoteRepo) IndexesUnchanged() bool {
+	if repo.packageRefs == nil || repo.IndexesDigest == "" {
+		return false
+	}
+
+	return repo.indexesDigest() == repo.IndexesDigest
+}
+
+// MarkAsChecked records that mirror has been checked against remote repository without any changes
+func (repo *RemoteRepo) MarkAsChecked() {
+	repo.LastDownloadDate = time.Now()
+}
+
+// DownloadPackageIndexes downloads & parses package index files
+func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.Downloader, verifier pgp.Verifier, _ *CollectionFactory, ignoreSignatures bool, ignoreChecksums bool) error {
+	if repo.packageList != nil {
+		panic("packageList != nil")
+	}
+	repo.packageList = NewPackageList()
+
+	releaseFiles := repo.ReleaseFiles
+	if !ignoreChecksums {
+		var err error
+		releaseFiles, err = repo.releaseFilesForPolicy()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Download and parse all Packages & Source files
+	for _, info := range repo.packageIndexPaths() {
 		path, kind, component, architecture := info[0], info[1], info[2], info[3]
-		packagesReader, packagesFile, err := http.DownloadTryCompression(gocontext.TODO(), d, repo.IndexesRootURL(), path, repo.ReleaseFiles, ignoreChecksums)
+
+		var (
+			packagesReader io.Reader
+			packagesFile   *os.File
+			fromCache      bool
+			err            error
+		)
 
 		isInstaller := kind == PackageTypeInstaller
+		usePDiffs := repo.UsePDiffs && repo.indexCacheDir != "" && !isInstaller
+
+		if usePDiffs {
+			packagesFile, err = repo.patchCachedIndex(d, path)
+			if err != nil {
+				if progress != nil {
+					progress.ColoredPrintf("@y[!]@| @!unable to use pdiffs for %s, falling back to full download: %s@|", path, err)
+				}
+				packagesFile = nil
+			}
+			if packagesFile != nil {
+				packagesReader, fromCache = packagesFile, true
+			}
+		}
+
+		if packagesFile == nil {
+			packagesReader, packagesFile, err = http.DownloadTryCompression(gocontext.TODO(), d, repo.IndexesRootURL(), path, releaseFiles, ignoreChecksums)
+		}
+
 		if err != nil {
 			if _, ok := err.(*http.NoCandidateFoundError); isInstaller && ok {
 				// checking if gpg file is only needed when checksums matches are required.
@@ -522,6 +804,15 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 			if err != nil {
 				return err
 			}
+		} else if usePDiffs && !fromCache {
+			// keep copy of freshly downloaded package index to apply pdiffs to it next time
+			var cachedFile *os.File
+			cachedFile, err = repo.writeCachedIndex(path, packagesReader, nil)
+			packagesFile.Close()
+			if err != nil {
+				return err
+			}
+			packagesFile, packagesReader = cachedFile, cachedFile
 		}
 		defer packagesFile.Close()
 
@@ -530,7 +821,7 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 			progress.InitBar(stat.Size(), true, aptly.BarMirrorUpdateBuildPackageList)
 		}
 
-		sreader := NewControlFileReader(packagesReader, false, isInstaller)
+		sreader := NewPackageFileReader(packagesReader, isInstaller)
 
 		for {
 			stanza, err := sreader.ReadStanza()
@@ -546,6 +837,10 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 				progress.SetBar(int(off))
 			}
 
+			if !isInstaller && !repo.sectionPriorityMatches(stanza) {
+				continue
+			}
+
 			var p *Package
 
 			if kind == PackageTypeBinary {
@@ -563,13 +858,20 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 					return err
 				}
 			}
-			err = repo.packageList.Add(p)
+			var existing *Package
+			existing, err = repo.packageList.AddWithConflictResolution(p, ConflictFail)
 			if err != nil {
 				if _, ok := err.(*PackageConflictError); ok {
-					if progress != nil {
+					if kind == PackageTypeSource && existing.IsExtraSourceOnly() && !p.IsExtraSourceOnly() {
+						// regular stanza takes precedence over Extra-Source-Only one
+						_, err = repo.packageList.AddWithConflictResolution(p, ConflictReplace)
+						if err != nil {
+							return err
+						}
+					} else if progress != nil && !p.IsExtraSourceOnly() {
 						progress.ColoredPrintf("@y[!]@| @!skipping package %s: duplicate in packages index@|", p)
 					}
-				} else if err != nil {
+				} else {
 					return err
 				}
 			}
@@ -583,6 +885,62 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 	return nil
 }
 
+// priorityRanks orders Debian package priorities starting with the most important one
+var priorityRanks = map[string]int{"required": 0, "important": 1, "standard": 2, "optional": 3, "extra": 4}
+
+// ValidatePriority checks that priority is one of Debian package priorities
+func ValidatePriority(priority string) error {
+	if _, ok := priorityRanks[strings.ToLower(priority)]; !ok {
+		return fmt.Errorf("unknown priority %s, should be one of required, important, standard, optional, extra", priority)
+	}
+
+	return nil
+}
+
+// sectionInList checks whether section is in the list, area prefix of the section (as in contrib/games)
+// could be omitted in the list
+func sectionInList(section string, sections []string) bool {
+	section = strings.ToLower(section)
+
+	for _, item := range sections {
+		item = strings.ToLower(item)
+		if item == section || (!strings.Contains(item, "/") && strings.HasSuffix(section, "/"+item)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sectionPriorityMatches checks package stanza against section and priority limits of the mirror
+func (repo *RemoteRepo) sectionPriorityMatches(stanza Stanza) bool {
+	if len(repo.IncludeSections) > 0 || len(repo.ExcludeSections) > 0 {
+		section := stanza["Section"]
+
+		if len(repo.IncludeSections) > 0 && !sectionInList(section, repo.IncludeSections) {
+			return false
+		}
+
+		if sectionInList(section, repo.ExcludeSections) {
+			return false
+		}
+	}
+
+	if repo.MinPriority != "" {
+		priority := stanza["Priority"]
+		if priority == "" {
+			// Debian policy: default priority is optional
+			priority = "optional"
+		}
+
+		if rank, ok := priorityRanks[strings.ToLower(priority)]; ok && rank > priorityRanks[strings.ToLower(repo.MinPriority)] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ApplyFilter applies filtering to already built PackageList
 func (repo *RemoteRepo) ApplyFilter(dependencyOptions int, filterQuery PackageQuery, progress aptly.Progress) (oldLen, newLen int, err error) {
 	repo.packageList.PrepareIndex()
@@ -645,15 +1003,183 @@ func (repo *RemoteRepo) BuildDownloadQueue(packagePool aptly.PackagePool, packag
 	return
 }
 
-// FinalizeDownload swaps for final value of package refs
-func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, progress aptly.Progress) error {
-	transaction, err := collectionFactory.PackageCollection().db.OpenTransaction()
+// UpdateReport describes changes to the mirror which would be made by the update
+type UpdateReport struct {
+	// Packages which are not tracked by the mirror yet
+	Added []string
+	// Packages which would be no longer tracked by the mirror
+	Removed []string
+	// Packages tracked by the mirror which have files missing from the package pool
+	Redownloaded []string
+}
+
+// BuildUpdateReport compares package list built from downloaded indexes with
+// current contents of the mirror, queue is download queue built by BuildDownloadQueue
+func (repo *RemoteRepo) BuildUpdateReport(queue []PackageDownloadTask, packageCollection *PackageCollection) (*UpdateReport, error) {
+	report := &UpdateReport{}
+
+	queued := make(map[string]bool, len(queue))
+	for _, task := range queue {
+		queued[task.File.DownloadURL()] = true
+	}
+
+	oldRefs := repo.packageRefs
+	if oldRefs == nil {
+		oldRefs = NewPackageRefList()
+	}
+
+	err := repo.packageList.ForEach(func(p *Package) error {
+		if !oldRefs.Has(p) {
+			report.Added = append(report.Added, p.String())
+			return nil
+		}
+
+		for _, f := range p.Files() {
+			if queued[f.DownloadURL()] {
+				report.Redownloaded = append(report.Redownloaded, p.String())
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = oldRefs.Subtract(NewPackageRefListFromPackageList(repo.packageList)).ForEach(func(key []byte) error {
+		p, err2 := packageCollection.ByKey(key)
+		if err2 != nil {
+			return fmt.Errorf("unable to load package %s: %s", key, err2)
+		}
+
+		report.Removed = append(report.Removed, p.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Strings(report.Redownloaded)
+
+	return report, nil
+}
+
+// remoteRepoResumeState is state of mirror update saved before package files are downloaded
+type remoteRepoResumeState struct {
+	// IndexesDigest identifies package indexes and mirror settings list of packages was built from
+	IndexesDigest string
+	// Refs is list of packages being downloaded
+	Refs *PackageRefList
+	// DownloadPaths are paths of package files relative to mirror root (not stored with packages)
+	DownloadPaths map[string][]string
+}
+
+// SaveResumeState saves list of packages being downloaded, so that update could be resumed
+// after interruption without downloading and parsing package indexes again
+func (repo *RemoteRepo) SaveResumeState(collectionFactory *CollectionFactory) error {
+	digest := repo.indexesDigest()
+	if digest == "" {
+		// there's no way to check later whether package indexes have changed
+		return nil
+	}
+
+	// packages are written before resume state which refers to them
+	batch := newPackageBatch(collectionFactory.PackageCollection().db)
+
+	state := &remoteRepoResumeState{
+		IndexesDigest: digest,
+		Refs:          NewPackageRefListFromPackageList(repo.packageList),
+		DownloadPaths: make(map[string][]string, repo.packageList.Len()),
+	}
+
+	err := repo.packageList.ForEach(func(p *Package) error {
+		files := p.Files()
+		paths := make([]string, len(files))
+		for i := range files {
+			paths[i] = files[i].downloadPath
+		}
+		state.DownloadPaths[string(p.Key(""))] = paths
+
+		err := collectionFactory.PackageCollection().UpdateInTransaction(p, batch)
+		if err != nil {
+			return err
+		}
+
+		return batch.packageDone()
+	})
+	if err == nil {
+		err = batch.Write()
+	}
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	err = encoder.Encode(state)
 	if err != nil {
 		return err
 	}
-	defer transaction.Discard()
+
+	return collectionFactory.PackageCollection().db.Put(repo.ResumeKey(), buf.Bytes())
+}
+
+// ResumeDownload restores list of packages saved by interrupted update if package indexes
+// and mirror settings haven't changed since then, false is returned if there's nothing to resume
+func (repo *RemoteRepo) ResumeDownload(collectionFactory *CollectionFactory, progress aptly.Progress) (bool, error) {
+	if repo.packageList != nil {
+		panic("packageList != nil")
+	}
+
+	encoded, err := collectionFactory.PackageCollection().db.Get(repo.ResumeKey())
+	if err == database.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	state := &remoteRepoResumeState{}
+	decoder := codec.NewDecoderBytes(encoded, &codec.MsgpackHandle{})
+	if err = decoder.Decode(state); err != nil {
+		return false, nil
+	}
+
+	if state.IndexesDigest == "" || state.IndexesDigest != repo.indexesDigest() {
+		return false, nil
+	}
+
+	list, err := NewPackageListFromRefList(state.Refs, collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		// packages might have been removed by db cleanup since then
+		return false, nil
+	}
+
+	list.ForEach(func(p *Package) error {
+		paths := state.DownloadPaths[string(p.Key(""))]
+		for i := range p.Files() {
+			if i < len(paths) {
+				p.Files()[i].downloadPath = paths[i]
+			}
+		}
+		return nil
+	})
+
+	repo.packageList = list
+	return true, nil
+}
+
+// FinalizeDownload swaps for final value of package refs
+func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, progress aptly.Progress) error {
+	// packages are written in several batches, resume state is removed with the last one
+	batch := newPackageBatch(collectionFactory.PackageCollection().db)
 
 	repo.LastDownloadDate = time.Now()
+	repo.IndexesDigest = repo.indexesDigest()
 
 	if progress != nil {
 		progress.InitBar(int64(repo.packageList.Len()), true, aptly.BarMirrorUpdateFinalizeDownload)
@@ -662,18 +1188,35 @@ func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, p
 	var i int
 
 	// update all the packages in collection
-	err = repo.packageList.ForEach(func(p *Package) error {
+	err := repo.packageList.ForEach(func(p *Package) error {
 		i++
 		if progress != nil {
 			progress.SetBar(i)
 		}
 		// download process might have updated checksums
 		p.UpdateFiles(p.Files())
-		return collectionFactory.PackageCollection().UpdateInTransaction(p, transaction)
+		err := collectionFactory.PackageCollection().UpdateInTransaction(p, batch)
+		if err != nil {
+			return err
+		}
+
+		return batch.packageDone()
 	})
 
 	if err == nil {
-		repo.packageRefs = NewPackageRefListFromPackageList(repo.packageList)
+		err = batch.Delete(repo.ResumeKey())
+	}
+
+	if err == nil {
+		err = batch.Write()
+	}
+
+	if err == nil {
+		// previous versions of packages are still in the database and package pool,
+		// so they could be retained without downloading
+		refs := NewPackageRefListFromPackageList(repo.packageList).RetainVersions(repo.packageRefs, repo.KeepVersions)
+		repo.recordUpdate(repo.packageRefs, refs)
+		repo.packageRefs = refs
 		repo.packageList = nil
 	}
 
@@ -681,10 +1224,7 @@ func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, p
 		progress.ShutdownBar()
 	}
 
-	if err != nil {
-		return err
-	}
-	return transaction.Commit()
+	return err
 }
 
 // Encode does msgpack encoding of RemoteRepo
@@ -765,10 +1305,16 @@ func (repo *RemoteRepo) RefKey() []byte {
 	return []byte("E" + repo.UUID)
 }
 
+// ResumeKey is a unique id for saved state of interrupted update
+func (repo *RemoteRepo) ResumeKey() []byte {
+	return []byte("N" + repo.UUID)
+}
+
 // RemoteRepoCollection does listing, updating/adding/deleting of RemoteRepos
 type RemoteRepoCollection struct {
-	db    database.Storage
-	cache map[string]*RemoteRepo
+	db       database.Storage
+	cache    map[string]*RemoteRepo
+	recorder *AuditRecorder
 }
 
 // NewRemoteRepoCollection loads RemoteRepos from DB and makes up collection
@@ -828,6 +1374,7 @@ func (collection *RemoteRepoCollection) Add(repo *RemoteRepo) error {
 	}
 
 	collection.cache[repo.UUID] = repo
+	collection.recorder.Record(AuditActionCreated, AuditKindMirror, repo.Name, repo.UUID)
 	return nil
 }
 
@@ -839,7 +1386,11 @@ func (collection *RemoteRepoCollection) Update(repo *RemoteRepo) error {
 	if repo.packageRefs != nil {
 		batch.Put(repo.RefKey(), repo.packageRefs.Encode())
 	}
-	return batch.Write()
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionUpdated, AuditKindMirror, repo.Name, repo.UUID)
+	}
+	return err
 }
 
 // LoadComplete loads additional information for remote repo
@@ -925,5 +1476,10 @@ func (collection *RemoteRepoCollection) Drop(repo *RemoteRepo) error {
 	batch := collection.db.CreateBatch()
 	batch.Delete(repo.Key())
 	batch.Delete(repo.RefKey())
-	return batch.Write()
+	batch.Delete(repo.ResumeKey())
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionDropped, AuditKindMirror, repo.Name, repo.UUID)
+	}
+	return err
 }
@@ -0,0 +1,106 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mirrorUpdateHistorySize is number of latest updates kept in mirror update history
+const mirrorUpdateHistorySize = 10
+
+// MirrorUpdateRecord summarizes changes to the list of packages made by mirror update
+type MirrorUpdateRecord struct {
+	// Time update has been finished at
+	Time time.Time
+	// Number of packages added and removed by the update
+	Added   int
+	Removed int
+	// Number of packages in the mirror after update
+	Total int
+}
+
+// MirrorHealth is a summary of mirror health: update history and staleness
+type MirrorHealth struct {
+	Name string
+	// LastUpdate is time of last successful update (or check that mirror is up to date)
+	LastUpdate time.Time
+	// ReleaseDate and ValidUntil are Date and Valid-Until fields of upstream Release file
+	ReleaseDate time.Time
+	ValidUntil  time.Time
+	// Updates are latest updates which changed the mirror, most recent first
+	Updates []MirrorUpdateRecord
+	// Stale is set if mirror hasn't been updated successfully within staleness window or
+	// upstream Release file has expired, Reason explains why
+	Stale  bool
+	Reason string `json:",omitempty"`
+}
+
+// parseReleaseDate parses Date and Valid-Until fields of Release file, some archives
+// pad day and hour with spaces
+func parseReleaseDate(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+
+	for _, layout := range []string{releaseDateFormat, "Mon, 2 Jan 2006 15:04:05 -0700"} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse release date %#v", value)
+}
+
+// recordUpdate adds update to the mirror update history
+func (repo *RemoteRepo) recordUpdate(previous, current *PackageRefList) {
+	if previous == nil {
+		previous = NewPackageRefList()
+	}
+
+	record := MirrorUpdateRecord{
+		Time:    repo.LastDownloadDate,
+		Added:   current.Subtract(previous).Len(),
+		Removed: previous.Subtract(current).Len(),
+		Total:   current.Len(),
+	}
+
+	repo.UpdateHistory = append([]MirrorUpdateRecord{record}, repo.UpdateHistory...)
+	if len(repo.UpdateHistory) > mirrorUpdateHistorySize {
+		repo.UpdateHistory = repo.UpdateHistory[:mirrorUpdateHistorySize]
+	}
+}
+
+// Health reports health of the mirror as of now, mirror is considered stale if it hasn't been
+// updated successfully within staleAfter (if positive) or if upstream Release file has expired
+func (repo *RemoteRepo) Health(now time.Time, staleAfter time.Duration) MirrorHealth {
+	health := MirrorHealth{
+		Name:       repo.Name,
+		LastUpdate: repo.LastDownloadDate,
+		Updates:    repo.UpdateHistory,
+	}
+
+	if health.Updates == nil {
+		health.Updates = []MirrorUpdateRecord{}
+	}
+
+	if date, ok := repo.Meta["Date"]; ok {
+		health.ReleaseDate, _ = parseReleaseDate(date)
+	}
+	if validUntil, ok := repo.Meta["Valid-Until"]; ok {
+		health.ValidUntil, _ = parseReleaseDate(validUntil)
+	}
+
+	switch {
+	case repo.LastDownloadDate.IsZero():
+		health.Stale = true
+		health.Reason = "mirror has never been updated"
+	case staleAfter > 0 && now.Sub(repo.LastDownloadDate) > staleAfter:
+		health.Stale = true
+		health.Reason = fmt.Sprintf("mirror hasn't been updated for %s", now.Sub(repo.LastDownloadDate).Round(time.Minute))
+	case !health.ValidUntil.IsZero() && now.After(health.ValidUntil):
+		health.Stale = true
+		health.Reason = fmt.Sprintf("upstream Release file has expired at %s", health.ValidUntil.Format(time.RFC3339))
+	}
+
+	return health
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/console"
@@ -107,6 +109,38 @@ func (s *RemoteRepoSuite) TearDownTest(c *C) {
 	s.db.Close()
 }
 
+func (s *RemoteRepoSuite) TestHealth(c *C) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	health := s.repo.Health(now, 0)
+	c.Check(health.Stale, Equals, true)
+	c.Check(health.Reason, Equals, "mirror has never been updated")
+	c.Check(health.Updates, HasLen, 0)
+
+	s.repo.LastDownloadDate = now.Add(-72 * time.Hour)
+	s.repo.Meta = Stanza{"Date": "Thu, 04 Jan 2024  8:14:32 UTC", "Valid-Until": "Thu, 11 Jan 2024 08:14:32 UTC"}
+
+	health = s.repo.Health(now, 0)
+	c.Check(health.Stale, Equals, false)
+	c.Check(health.ReleaseDate, Equals, time.Date(2024, 1, 4, 8, 14, 32, 0, time.UTC))
+	c.Check(health.ValidUntil, Equals, time.Date(2024, 1, 11, 8, 14, 32, 0, time.UTC))
+
+	health = s.repo.Health(now, 48*time.Hour)
+	c.Check(health.Stale, Equals, true)
+	c.Check(health.Reason, Equals, "mirror hasn't been updated for 72h0m0s")
+
+	health = s.repo.Health(now.Add(48*time.Hour), 0)
+	c.Check(health.Stale, Equals, true)
+	c.Check(health.Reason, Equals, "upstream Release file has expired at 2024-01-11T08:14:32Z")
+
+	for i := 0; i < 12; i++ {
+		s.repo.LastDownloadDate = now.Add(time.Duration(i) * time.Hour)
+		s.repo.recordUpdate(nil, NewPackageRefListFromPackageList(s.list))
+	}
+	c.Assert(s.repo.UpdateHistory, HasLen, 10)
+	c.Check(s.repo.UpdateHistory[0], DeepEquals, MirrorUpdateRecord{Time: now.Add(11 * time.Hour), Added: 3, Removed: 0, Total: 3})
+}
+
 func (s *RemoteRepoSuite) TestInvalidURL(c *C) {
 	_, err := NewRemoteRepo("s", "http://lolo%2", "squeeze", []string{"main"}, []string{}, false, false, false)
 	c.Assert(err, ErrorMatches, ".*(hexadecimal escape in host|percent-encoded characters in host|invalid URL escape).*")
@@ -124,6 +158,42 @@ func (s *RemoteRepoSuite) TestFlatCreation(c *C) {
 
 	_, err := NewRemoteRepo("fl", "http://some.repo/", "./", []string{"main"}, []string{}, false, false, false)
 	c.Check(err, ErrorMatches, "components aren't supported for flat repos")
+
+	_, err = NewRemoteRepo("fl", "http://some.repo/", "./", []string{}, []string{}, false, false, true)
+	c.Check(err, ErrorMatches, "debian-installer images aren't supported for flat repos")
+}
+
+func (s *RemoteRepoSuite) TestDownloaderOptions(c *C) {
+	options, err := s.repo.DownloaderOptions()
+	c.Assert(err, IsNil)
+	c.Check(options.Empty(), Equals, true)
+
+	passwordFile := filepath.Join(c.MkDir(), "password")
+	c.Assert(os.WriteFile(passwordFile, []byte("secret\n"), 0600), IsNil)
+
+	s.repo.Proxy = "http://proxy:3128/"
+	s.repo.Username = "user"
+	s.repo.PasswordFile = passwordFile
+
+	options, err = s.repo.DownloaderOptions()
+	c.Assert(err, IsNil)
+	c.Check(options, DeepEquals, aptly.DownloaderOptions{Proxy: "http://proxy:3128/", Username: "user", Password: "secret"})
+
+	s.repo.PasswordFile = passwordFile + ".missing"
+	_, err = s.repo.DownloaderOptions()
+	c.Check(err, ErrorMatches, "unable to read password file: .*")
+
+	s.repo.PasswordFile = ""
+	s.repo.PasswordEnv = "APTLY_TEST_MIRROR_PASSWORD"
+	_, err = s.repo.DownloaderOptions()
+	c.Check(err, ErrorMatches, "environment variable APTLY_TEST_MIRROR_PASSWORD with password is not set")
+
+	os.Setenv("APTLY_TEST_MIRROR_PASSWORD", "env-secret")
+	defer os.Unsetenv("APTLY_TEST_MIRROR_PASSWORD")
+
+	options, err = s.repo.DownloaderOptions()
+	c.Assert(err, IsNil)
+	c.Check(options.Password, Equals, "env-secret")
 }
 
 func (s *RemoteRepoSuite) TestString(c *C) {
@@ -236,6 +306,48 @@ func (s *RemoteRepoSuite) TestFetchNullVerifier2(c *C) {
 	c.Assert(downloader.Empty(), Equals, true)
 }
 
+func (s *RemoteRepoSuite) TestFetchIfModified(c *C) {
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", exampleReleaseFile)
+
+	modified, err := s.repo.FetchIfModified(downloader, &NullVerifier{}, false)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Check(s.repo.ReleaseValidators, HasLen, 1)
+	c.Check(s.repo.ReleaseValidators["InRelease"].ETag, Not(Equals), "")
+	c.Assert(downloader.Empty(), Equals, true)
+
+	downloader.ExpectNotModified("http://mirror.yandex.ru/debian/dists/squeeze/InRelease")
+
+	modified, err = s.repo.FetchIfModified(downloader, &NullVerifier{}, false)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, false)
+	c.Check(s.repo.ReleaseFiles, HasLen, 39)
+	c.Assert(downloader.Empty(), Equals, true)
+
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", exampleReleaseFile)
+
+	modified, err = s.repo.FetchIfModified(downloader, &NullVerifier{}, false)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Assert(downloader.Empty(), Equals, true)
+}
+
+func (s *RemoteRepoSuite) TestFetchIfModifiedIgnoreSignatures(c *C) {
+	modified, err := s.repo.FetchIfModified(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Check(s.repo.ReleaseValidators, IsNil)
+
+	// without verified signature Release is always downloaded
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+
+	modified, err = s.repo.FetchIfModified(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Assert(s.downloader.Empty(), Equals, true)
+}
+
 func (s *RemoteRepoSuite) TestFetchWrongArchitecture(c *C) {
 	s.repo, _ = NewRemoteRepo("s", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{"xyz"}, false, false, false)
 	err := s.repo.Fetch(s.downloader, nil, true)
@@ -268,6 +380,66 @@ func (s *RemoteRepoSuite) TestRefKey(c *C) {
 	c.Assert(s.repo.RefKey()[1:], DeepEquals, s.repo.Key()[1:])
 }
 
+func (s *RemoteRepoSuite) TestBuildUpdateReport(c *C) {
+	s.repo.Architectures = []string{"i386"}
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false)
+	c.Assert(err, IsNil)
+
+	queue, _, err := s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+
+	report, err := s.repo.BuildUpdateReport(queue, s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+	c.Check(report.Added, DeepEquals, []string{"amanda-client_1:3.3.1-3~bpo60+1_i386"})
+	c.Check(report.Removed, HasLen, 0)
+	c.Check(report.Redownloaded, HasLen, 0)
+
+	c.Assert(s.repo.FinalizeDownload(s.collectionFactory, nil), IsNil)
+	c.Assert(s.repo.UpdateHistory, HasLen, 1)
+	c.Check(s.repo.UpdateHistory[0].Time, Equals, s.repo.LastDownloadDate)
+	c.Check(s.repo.UpdateHistory[0].Added, Equals, 1)
+	c.Check(s.repo.UpdateHistory[0].Removed, Equals, 0)
+	c.Check(s.repo.UpdateHistory[0].Total, Equals, 1)
+
+	// package is tracked now, but its file is still missing from the pool
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	err = s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false)
+	c.Assert(err, IsNil)
+
+	queue, _, err = s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+
+	report, err = s.repo.BuildUpdateReport(queue, s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+	c.Check(report.Added, HasLen, 0)
+	c.Check(report.Removed, HasLen, 0)
+	c.Check(report.Redownloaded, DeepEquals, []string{"amanda-client_1:3.3.1-3~bpo60+1_i386"})
+
+	// package disappeared from upstream indexes
+	s.repo.packageList = NewPackageList()
+
+	report, err = s.repo.BuildUpdateReport(nil, s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+	c.Check(report.Added, HasLen, 0)
+	c.Check(report.Removed, DeepEquals, []string{"amanda-client_1:3.3.1-3~bpo60+1_i386"})
+	c.Check(report.Redownloaded, HasLen, 0)
+}
+
 func (s *RemoteRepoSuite) TestDownload(c *C) {
 	s.repo.Architectures = []string{"i386"}
 
@@ -288,8 +460,12 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	c.Check(queue, HasLen, 1)
 	c.Check(queue[0].File.DownloadURL(), Equals, "pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb")
 
+	c.Check(s.repo.IndexesUnchanged(), Equals, false)
+
 	s.repo.FinalizeDownload(s.collectionFactory, nil)
 	c.Assert(s.repo.packageRefs, NotNil)
+	c.Check(s.repo.IndexesDigest, Not(Equals), "")
+	c.Check(s.repo.IndexesUnchanged(), Equals, true)
 
 	pkg, err := s.collectionFactory.PackageCollection().ByKey(s.repo.packageRefs.Refs[0])
 	c.Assert(err, IsNil)
@@ -340,6 +516,81 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	c.Assert(s.repo.packageRefs, NotNil)
 }
 
+func (s *RemoteRepoSuite) TestIndexesUnchanged(c *C) {
+	s.repo.Architectures = []string{"i386"}
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.repo.packageList = NewPackageList()
+	c.Check(s.repo.IndexesUnchanged(), Equals, false)
+
+	s.repo.FinalizeDownload(s.collectionFactory, nil)
+	c.Check(s.repo.IndexesUnchanged(), Equals, true)
+
+	s.repo.Architectures = []string{"i386", "amd64"}
+	c.Check(s.repo.IndexesUnchanged(), Equals, false)
+
+	s.repo.Architectures = []string{"i386"}
+	s.repo.Filter = "nginx"
+	c.Check(s.repo.IndexesUnchanged(), Equals, false)
+
+	s.repo.Filter = ""
+	c.Check(s.repo.IndexesUnchanged(), Equals, true)
+
+	checksums := s.repo.ReleaseFiles["main/binary-i386/Packages.bz2"]
+	checksums.SHA256 = "abcd"
+	s.repo.ReleaseFiles["main/binary-i386/Packages.bz2"] = checksums
+	c.Check(s.repo.IndexesUnchanged(), Equals, false)
+}
+
+func (s *RemoteRepoSuite) TestResumeDownload(c *C) {
+	s.repo.Architectures = []string{"i386"}
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.repo.SaveResumeState(s.collectionFactory), IsNil)
+
+	// update was interrupted, package list is restored without downloading indexes
+	s.repo.packageList = nil
+	resumed, err := s.repo.ResumeDownload(s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+	c.Check(resumed, Equals, true)
+	c.Check(s.repo.packageList.Len(), Equals, 1)
+
+	queue, _, err := s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+	c.Check(queue, HasLen, 1)
+	c.Check(queue[0].File.DownloadURL(), Equals, "pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb")
+
+	// mirror settings have changed since then
+	s.repo.packageList = nil
+	s.repo.Filter = "nginx"
+	resumed, err = s.repo.ResumeDownload(s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+	c.Check(resumed, Equals, false)
+	c.Check(s.repo.packageList, IsNil)
+
+	s.repo.Filter = ""
+	resumed, err = s.repo.ResumeDownload(s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+	c.Check(resumed, Equals, true)
+
+	// successful update clears saved state
+	c.Assert(s.repo.FinalizeDownload(s.collectionFactory, nil), IsNil)
+	resumed, err = s.repo.ResumeDownload(s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+	c.Check(resumed, Equals, false)
+}
+
 func (s *RemoteRepoSuite) TestDownloadWithInstaller(c *C) {
 	s.repo.Architectures = []string{"i386"}
 	s.repo.DownloadInstaller = true
@@ -383,6 +634,56 @@ func (s *RemoteRepoSuite) TestDownloadWithInstaller(c *C) {
 	c.Check(pkg.Name, Equals, "installer")
 }
 
+func (s *RemoteRepoSuite) TestDownloadSectionPriority(c *C) {
+	s.repo.Architectures = []string{"i386"}
+	s.repo.ExcludeSections = []string{"utils"}
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.packageList.Len(), Equals, 0)
+}
+
+func (s *RemoteRepoSuite) TestSectionPriorityMatches(c *C) {
+	stanza := Stanza{"Package": "xbill", "Section": "contrib/games", "Priority": "optional"}
+
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, true)
+
+	s.repo.IncludeSections = []string{"games"}
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, true)
+	s.repo.IncludeSections = []string{"contrib/games", "doc"}
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, true)
+	s.repo.IncludeSections = []string{"non-free/games", "doc"}
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, false)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "nosection"}), Equals, false)
+
+	s.repo.IncludeSections = nil
+	s.repo.ExcludeSections = []string{"Games"}
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, false)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "nosection"}), Equals, true)
+
+	s.repo.ExcludeSections = nil
+	s.repo.MinPriority = "optional"
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, true)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "old", "Priority": "extra"}), Equals, false)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "nopriority"}), Equals, true)
+
+	s.repo.MinPriority = "standard"
+	c.Check(s.repo.sectionPriorityMatches(stanza), Equals, false)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "base", "Priority": "required"}), Equals, true)
+	c.Check(s.repo.sectionPriorityMatches(Stanza{"Package": "nopriority"}), Equals, false)
+
+	c.Check(ValidatePriority("Important"), IsNil)
+	c.Check(ValidatePriority("low"), ErrorMatches, "unknown priority low.*")
+}
+
 func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.repo.Architectures = []string{"i386"}
 	s.repo.DownloadSources = true
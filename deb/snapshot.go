@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/aptly-dev/aptly/database"
-	"github.com/aptly-dev/aptly/utils"
 	"github.com/pborman/uuid"
 	"github.com/ugorji/go/codec"
 )
@@ -35,11 +34,23 @@ type Snapshot struct {
 
 	// Description of how snapshot was created
 	Description string
+	// Provenance is free-form record of what snapshot was built from
+	Provenance string `codec:",omitempty" json:",omitempty"`
+	// Query is package query snapshot was created from (SourceKind is "query")
+	Query string `codec:",omitempty" json:",omitempty"`
+	// ManifestSignature is armored detached signature of snapshot manifest (see Manifest)
+	ManifestSignature string `codec:",omitempty" json:",omitempty"`
 
 	Origin               string
 	NotAutomatic         string
 	ButAutomaticUpgrades string
 
+	// RefBaseUUID is UUID of snapshot package reference list is stored as delta against,
+	// empty if list is stored in full
+	RefBaseUUID string `codec:",omitempty" json:"-"`
+	// RefDeltaDepth is number of deltas to apply to full list to get package reference list
+	RefDeltaDepth int `codec:",omitempty" json:"-"`
+
 	packageRefs *PackageRefList
 }
 
@@ -49,13 +60,23 @@ func NewSnapshotFromRepository(name string, repo *RemoteRepo) (*Snapshot, error)
 		return nil, errors.New("mirror not updated")
 	}
 
+	name, err := repo.ExpandSnapshotName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Snapshot from mirror %s", repo)
+	if len(repo.UpstreamSources) > 0 {
+		description += fmt.Sprintf(", upstream %s: %s", repo.UpstreamSourceKind, repo.UpstreamSourcesString())
+	}
+
 	return &Snapshot{
 		UUID:                 uuid.New(),
 		Name:                 name,
 		CreatedAt:            time.Now(),
 		SourceKind:           SourceRemoteRepo,
 		SourceIDs:            []string{repo.UUID},
-		Description:          fmt.Sprintf("Snapshot from mirror %s", repo),
+		Description:          description,
 		Origin:               repo.Meta["Origin"],
 		NotAutomatic:         repo.Meta["NotAutomatic"],
 		ButAutomaticUpgrades: repo.Meta["ButAutomaticUpgrades"],
@@ -137,6 +158,11 @@ func (s *Snapshot) RefKey() []byte {
 	return []byte("E" + s.UUID)
 }
 
+// RefDeltaKey is a unique id for package reference list stored as delta
+func (s *Snapshot) RefDeltaKey() []byte {
+	return []byte("D" + s.UUID)
+}
+
 // Encode does msgpack encoding of Snapshot
 func (s *Snapshot) Encode() []byte {
 	var buf bytes.Buffer
@@ -194,10 +220,21 @@ func (s *Snapshot) Decode(input []byte) error {
 	return nil
 }
 
+// snapshotRefDelta is difference between package reference lists of snapshot and its base
+type snapshotRefDelta struct {
+	Added   [][]byte
+	Removed [][]byte
+}
+
 // SnapshotCollection does listing, updating/adding/deleting of Snapshots
 type SnapshotCollection struct {
-	db    database.Storage
-	cache map[string]*Snapshot
+	db       database.Storage
+	cache    map[string]*Snapshot
+	recorder *AuditRecorder
+
+	// deltaInterval is how often package reference lists are stored in full, other snapshots
+	// of the same source are stored as deltas; 0 disables delta storage
+	deltaInterval int
 }
 
 // NewSnapshotCollection loads Snapshots from DB and makes up collection
@@ -215,12 +252,80 @@ func (collection *SnapshotCollection) Add(snapshot *Snapshot) error {
 		return fmt.Errorf("snapshot with name %s already exists", snapshot.Name)
 	}
 
+	collection.chooseRefBase(snapshot)
+
 	err = collection.Update(snapshot)
 	if err != nil {
 		return err
 	}
 
 	collection.cache[snapshot.UUID] = snapshot
+	collection.recorder.Record(AuditActionCreated, AuditKindSnapshot, snapshot.Name, snapshot.UUID)
+	return nil
+}
+
+// chooseRefBase picks most recent snapshot of the same source as base for delta storage
+// of package reference list of new snapshot
+func (collection *SnapshotCollection) chooseRefBase(snapshot *Snapshot) {
+	snapshot.RefBaseUUID, snapshot.RefDeltaDepth = "", 0
+
+	if collection.deltaInterval <= 0 || snapshot.packageRefs == nil {
+		return
+	}
+
+	sourceKey := snapshotSourceKey(snapshot)
+
+	var base *Snapshot
+	for _, candidate := range collection.search(func(s *Snapshot) bool {
+		return s.UUID != snapshot.UUID && snapshotSourceKey(s) == sourceKey
+	}, false) {
+		if base == nil || candidate.CreatedAt.After(base.CreatedAt) {
+			base = candidate
+		}
+	}
+
+	if base == nil || base.RefDeltaDepth+1 >= collection.deltaInterval {
+		// time for full checkpoint
+		return
+	}
+
+	snapshot.RefBaseUUID, snapshot.RefDeltaDepth = base.UUID, base.RefDeltaDepth+1
+}
+
+// putRefs stores package reference list of snapshot either as delta against base or in full
+func (collection *SnapshotCollection) putRefs(batch database.Batch, snapshot *Snapshot) error {
+	if snapshot.RefBaseUUID != "" {
+		base, err := collection.ByUUID(snapshot.RefBaseUUID)
+		if err == nil && base.packageRefs == nil {
+			err = collection.LoadComplete(base)
+		}
+
+		if err == nil {
+			delta := snapshotRefDelta{
+				Added:   snapshot.packageRefs.Subtract(base.packageRefs).Refs,
+				Removed: base.packageRefs.Subtract(snapshot.packageRefs).Refs,
+			}
+
+			// delta is worth it only if it's much smaller than the full list
+			if 2*(len(delta.Added)+len(delta.Removed)) < snapshot.packageRefs.Len() {
+				var buf bytes.Buffer
+
+				encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+				if err = encoder.Encode(&delta); err != nil {
+					return err
+				}
+
+				batch.Put(snapshot.RefDeltaKey(), buf.Bytes())
+				batch.Delete(snapshot.RefKey())
+				return nil
+			}
+		}
+
+		snapshot.RefBaseUUID, snapshot.RefDeltaDepth = "", 0
+	}
+
+	batch.Put(snapshot.RefKey(), snapshot.packageRefs.Encode())
+	batch.Delete(snapshot.RefDeltaKey())
 	return nil
 }
 
@@ -228,16 +333,27 @@ func (collection *SnapshotCollection) Add(snapshot *Snapshot) error {
 func (collection *SnapshotCollection) Update(snapshot *Snapshot) error {
 	batch := collection.db.CreateBatch()
 
-	batch.Put(snapshot.Key(), snapshot.Encode())
 	if snapshot.packageRefs != nil {
-		batch.Put(snapshot.RefKey(), snapshot.packageRefs.Encode())
+		// storage of package refs is decided first, as it's recorded in the snapshot
+		if err := collection.putRefs(batch, snapshot); err != nil {
+			return err
+		}
 	}
+	batch.Put(snapshot.Key(), snapshot.Encode())
 
-	return batch.Write()
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionUpdated, AuditKindSnapshot, snapshot.Name, snapshot.UUID)
+	}
+	return err
 }
 
 // LoadComplete loads additional information about snapshot
 func (collection *SnapshotCollection) LoadComplete(snapshot *Snapshot) error {
+	if snapshot.RefBaseUUID != "" {
+		return collection.loadDelta(snapshot)
+	}
+
 	encoded, err := collection.db.Get(snapshot.RefKey())
 	if err != nil {
 		return err
@@ -247,6 +363,39 @@ func (collection *SnapshotCollection) LoadComplete(snapshot *Snapshot) error {
 	return snapshot.packageRefs.Decode(encoded)
 }
 
+// loadDelta restores package reference list of snapshot from delta against its base
+func (collection *SnapshotCollection) loadDelta(snapshot *Snapshot) error {
+	encoded, err := collection.db.Get(snapshot.RefDeltaKey())
+	if err != nil {
+		return err
+	}
+
+	var delta snapshotRefDelta
+
+	decoder := codec.NewDecoderBytes(encoded, &codec.MsgpackHandle{})
+	if err = decoder.Decode(&delta); err != nil {
+		return err
+	}
+
+	base, err := collection.ByUUID(snapshot.RefBaseUUID)
+	if err != nil {
+		return fmt.Errorf("unable to load base of snapshot %s: %s", snapshot.Name, err)
+	}
+
+	if base.packageRefs == nil {
+		if err = collection.LoadComplete(base); err != nil {
+			return err
+		}
+	}
+
+	refs := base.packageRefs.Subtract(&PackageRefList{Refs: delta.Removed})
+	refs.Refs = append(refs.Refs, delta.Added...)
+	sort.Sort(refs)
+
+	snapshot.packageRefs = refs
+	return nil
+}
+
 func (collection *SnapshotCollection) search(filter func(*Snapshot) bool, unique bool) []*Snapshot {
 	result := []*Snapshot(nil)
 	for _, s := range collection.cache {
@@ -321,21 +470,21 @@ func (collection *SnapshotCollection) ByUUID(uuid string) (*Snapshot, error) {
 // ByRemoteRepoSource looks up snapshots that have specified RemoteRepo as a source
 func (collection *SnapshotCollection) ByRemoteRepoSource(repo *RemoteRepo) []*Snapshot {
 	return collection.search(func(s *Snapshot) bool {
-		return s.SourceKind == SourceRemoteRepo && utils.StrSliceHasItem(s.SourceIDs, repo.UUID)
+		return s.HasSource(SourceRemoteRepo, repo.UUID)
 	}, false)
 }
 
 // ByLocalRepoSource looks up snapshots that have specified LocalRepo as a source
 func (collection *SnapshotCollection) ByLocalRepoSource(repo *LocalRepo) []*Snapshot {
 	return collection.search(func(s *Snapshot) bool {
-		return s.SourceKind == SourceLocalRepo && utils.StrSliceHasItem(s.SourceIDs, repo.UUID)
+		return s.HasSource(SourceLocalRepo, repo.UUID)
 	}, false)
 }
 
 // BySnapshotSource looks up snapshots that have specified snapshot as a source
 func (collection *SnapshotCollection) BySnapshotSource(snapshot *Snapshot) []*Snapshot {
 	return collection.search(func(s *Snapshot) bool {
-		return s.SourceKind == "snapshot" && utils.StrSliceHasItem(s.SourceIDs, snapshot.UUID)
+		return s.HasSource(SourceSnapshot, snapshot.UUID)
 	}, false)
 }
 
@@ -397,12 +546,87 @@ func (collection *SnapshotCollection) Drop(snapshot *Snapshot) error {
 		return err
 	}
 
+	// snapshots stored as delta against this one are stored in full from now on
+	dependents := collection.search(func(s *Snapshot) bool { return s.RefBaseUUID == snapshot.UUID }, false)
+	for _, dependent := range dependents {
+		if dependent.packageRefs == nil {
+			if err := collection.LoadComplete(dependent); err != nil {
+				return err
+			}
+		}
+	}
+
 	delete(collection.cache, snapshot.UUID)
 
 	batch := collection.db.CreateBatch()
+	for _, dependent := range dependents {
+		dependent.RefBaseUUID, dependent.RefDeltaDepth = "", 0
+		if err := collection.putRefs(batch, dependent); err != nil {
+			return err
+		}
+		batch.Put(dependent.Key(), dependent.Encode())
+	}
+
 	batch.Delete(snapshot.Key())
 	batch.Delete(snapshot.RefKey())
-	return batch.Write()
+	batch.Delete(snapshot.RefDeltaKey())
+	err := batch.Write()
+	if err == nil {
+		collection.recorder.Record(AuditActionDropped, AuditKindSnapshot, snapshot.Name, snapshot.UUID)
+	}
+	return err
+}
+
+// SnapshotRetentionPolicy describes which snapshots are kept when pruning
+//
+// Snapshot is pruned if it isn't retained by any of the enabled rules
+type SnapshotRetentionPolicy struct {
+	// KeepLast keeps N most recent snapshots per source (mirror, local repo, ...), 0 disables the rule
+	KeepLast int
+	// KeepWithin keeps snapshots created within the duration, 0 disables the rule
+	KeepWithin time.Duration
+}
+
+// snapshotSourceKey groups snapshots created from the same source
+func snapshotSourceKey(snapshot *Snapshot) string {
+	ids := append([]string(nil), snapshot.SourceIDs...)
+	sort.Strings(ids)
+	return snapshot.SourceKind + ":" + strings.Join(ids, ",")
+}
+
+// Candidates returns snapshots not retained by the policy, sorted by creation time
+//
+// Snapshots which are published or used as sources are not filtered out, that
+// should be checked by the caller
+func (policy *SnapshotRetentionPolicy) Candidates(snapshots []*Snapshot, now time.Time) []*Snapshot {
+	if policy.KeepLast <= 0 && policy.KeepWithin <= 0 {
+		return nil
+	}
+
+	sorted := append([]*Snapshot(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+	result := []*Snapshot{}
+	perSource := map[string]int{}
+
+	for _, snapshot := range sorted {
+		key := snapshotSourceKey(snapshot)
+		perSource[key]++
+
+		if policy.KeepLast > 0 && perSource[key] <= policy.KeepLast {
+			continue
+		}
+
+		if policy.KeepWithin > 0 && now.Sub(snapshot.CreatedAt) <= policy.KeepWithin {
+			continue
+		}
+
+		result = append(result, snapshot)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+
+	return result
 }
 
 // Snapshot sorting methods
@@ -0,0 +1,117 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/pgp"
+)
+
+// SnapshotManifestFormat is version of snapshot manifest format
+const SnapshotManifestFormat = "aptly-snapshot-manifest/1"
+
+// Manifest returns canonical description of snapshot contents: snapshot metadata followed
+// by list of packages sorted by package reference, one per line as
+//
+//	<package> <version> <architecture> <SHA256 of .deb or .dsc file>
+//
+// Snapshot name and description are not part of the manifest, so they could be changed
+// without invalidating the signature.
+func (s *Snapshot) Manifest(packageCollection *PackageCollection) ([]byte, error) {
+	if s.packageRefs == nil {
+		return nil, fmt.Errorf("package list of snapshot %s is not loaded", s.Name)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Format: %s\n", SnapshotManifestFormat)
+	fmt.Fprintf(&buf, "UUID: %s\n", s.UUID)
+	fmt.Fprintf(&buf, "Created-At: %s\n", s.CreatedAt.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&buf, "Source-Kind: %s\n", s.SourceKind)
+	fmt.Fprintf(&buf, "Packages: %d\n", s.packageRefs.Len())
+	buf.WriteString("\n")
+
+	err := s.packageRefs.ForEach(func(key []byte) error {
+		p, err := packageCollection.ByKey(key)
+		if err != nil {
+			return fmt.Errorf("unable to load package %s: %s", key, err)
+		}
+
+		fmt.Fprintf(&buf, "%s %s %s %s\n", p.Name, p.Version, p.Architecture, manifestChecksum(p))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// manifestChecksum returns SHA256 of main file of the package (.deb or .dsc),
+// "-" if checksum is not known
+func manifestChecksum(p *Package) string {
+	for _, f := range p.Files() {
+		if p.IsSource && !strings.HasSuffix(f.Filename, ".dsc") {
+			continue
+		}
+
+		if f.Checksums.SHA256 != "" {
+			return f.Checksums.SHA256
+		}
+		break
+	}
+
+	return "-"
+}
+
+// SignManifest signs manifest of the snapshot with detached signature, which is stored
+// in the snapshot
+func (s *Snapshot) SignManifest(signer pgp.Signer, packageCollection *PackageCollection) error {
+	manifest, err := s.Manifest(packageCollection)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "aptly")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifestPath := filepath.Join(tempDir, "manifest")
+	err = os.WriteFile(manifestPath, manifest, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = signer.DetachedSign(manifestPath, manifestPath+".asc")
+	if err != nil {
+		return fmt.Errorf("unable to sign manifest: %s", err)
+	}
+
+	signature, err := os.ReadFile(manifestPath + ".asc")
+	if err != nil {
+		return err
+	}
+
+	s.ManifestSignature = string(signature)
+	return nil
+}
+
+// VerifyManifest checks that stored signature matches manifest of the snapshot
+func (s *Snapshot) VerifyManifest(verifier pgp.Verifier, packageCollection *PackageCollection) error {
+	if s.ManifestSignature == "" {
+		return fmt.Errorf("snapshot %s is not signed", s.Name)
+	}
+
+	manifest, err := s.Manifest(packageCollection)
+	if err != nil {
+		return err
+	}
+
+	return verifier.VerifyDetachedSignature(bytes.NewBufferString(s.ManifestSignature), bytes.NewReader(manifest), false)
+}
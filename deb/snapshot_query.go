@@ -0,0 +1,213 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+// SnapshotSource is source of the snapshot: mirror, local repo or another snapshot
+type SnapshotSource struct {
+	// Kind is one of SourceRemoteRepo, SourceLocalRepo, SourceSnapshot
+	Kind string
+	// ID is UUID of the source
+	ID string
+	// Name is filled only for sources found with FindSnapshotSource
+	Name string
+
+	resourceKey []byte
+	refList     *PackageRefList
+}
+
+// ResourceKey returns key of the source to lock while snapshot is being created
+func (source *SnapshotSource) ResourceKey() []byte {
+	return source.resourceKey
+}
+
+// RefList returns packages of the source
+func (source *SnapshotSource) RefList() *PackageRefList {
+	return source.refList
+}
+
+// String returns source as it is specified on command line, e.g. mirror:wheezy-main
+func (source *SnapshotSource) String() string {
+	switch source.Kind {
+	case SourceRemoteRepo:
+		return "mirror:" + source.Name
+	case SourceLocalRepo:
+		return "repo:" + source.Name
+	}
+
+	return source.Kind + ":" + source.Name
+}
+
+// FindSnapshotSource finds source specified as mirror:<name>, repo:<name> or snapshot:<name>,
+// list of packages of the source is not loaded (see Load)
+func FindSnapshotSource(collectionFactory *CollectionFactory, spec string) (*SnapshotSource, error) {
+	kind, name, found := strings.Cut(spec, ":")
+	if !found || name == "" {
+		return nil, fmt.Errorf("source %s should be specified as mirror:<name>, repo:<name> or snapshot:<name>", spec)
+	}
+
+	switch kind {
+	case "mirror":
+		repo, err := collectionFactory.RemoteRepoCollection().ByName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SnapshotSource{Kind: SourceRemoteRepo, ID: repo.UUID, Name: repo.Name, resourceKey: repo.Key()}, nil
+	case "repo":
+		repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SnapshotSource{Kind: SourceLocalRepo, ID: repo.UUID, Name: repo.Name, resourceKey: repo.Key()}, nil
+	case SourceSnapshot:
+		snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SnapshotSource{Kind: SourceSnapshot, ID: snapshot.UUID, Name: snapshot.Name, resourceKey: snapshot.ResourceKey()}, nil
+	}
+
+	return nil, fmt.Errorf("unknown kind of source %s, should be one of mirror, repo, snapshot", kind)
+}
+
+// Load loads list of packages of the source found with FindSnapshotSource, source
+// should be locked (see ResourceKey) while it is loaded
+func (source *SnapshotSource) Load(collectionFactory *CollectionFactory) error {
+	switch source.Kind {
+	case SourceRemoteRepo:
+		repo, err := collectionFactory.RemoteRepoCollection().ByUUID(source.ID)
+		if err != nil {
+			return err
+		}
+
+		err = repo.CheckLock()
+		if err != nil {
+			return err
+		}
+
+		err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return err
+		}
+
+		if repo.RefList() == nil {
+			return fmt.Errorf("mirror %s not updated", repo.Name)
+		}
+
+		source.refList = repo.RefList()
+	case SourceLocalRepo:
+		repo, err := collectionFactory.LocalRepoCollection().ByUUID(source.ID)
+		if err != nil {
+			return err
+		}
+
+		err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return err
+		}
+
+		source.refList = repo.RefList()
+		if source.refList == nil {
+			source.refList = NewPackageRefList()
+		}
+	case SourceSnapshot:
+		snapshot, err := collectionFactory.SnapshotCollection().ByUUID(source.ID)
+		if err != nil {
+			return err
+		}
+
+		err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if err != nil {
+			return err
+		}
+
+		source.refList = snapshot.RefList()
+	}
+
+	return nil
+}
+
+// LoadSnapshotSource finds and loads source specified as mirror:<name>, repo:<name>
+// or snapshot:<name>
+func LoadSnapshotSource(collectionFactory *CollectionFactory, spec string) (*SnapshotSource, error) {
+	source, err := FindSnapshotSource(collectionFactory, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = source.Load(collectionFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// MergeSnapshotSources combines packages of all the sources into single list, if same package
+// (name, version and architecture) comes from several sources with different files, package
+// from the last source wins
+func MergeSnapshotSources(sources []*SnapshotSource) *PackageRefList {
+	result := NewPackageRefList()
+	for _, source := range sources {
+		result = result.Merge(source.RefList(), false, false)
+	}
+
+	return result
+}
+
+// NewSnapshotFromQuery creates snapshot with packages matching query across several sources,
+// list is result of the query
+func NewSnapshotFromQuery(name string, query string, sources []*SnapshotSource, list *PackageList) *Snapshot {
+	sourceIDs := make([]string, len(sources))
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		sourceIDs[i] = source.Kind + ":" + source.ID
+		names[i] = source.String()
+	}
+
+	return &Snapshot{
+		UUID:        uuid.New(),
+		Name:        name,
+		CreatedAt:   time.Now(),
+		SourceKind:  SourceQuery,
+		SourceIDs:   sourceIDs,
+		Description: fmt.Sprintf("Query '%s' from %s", query, strings.Join(names, ", ")),
+		Query:       query,
+		packageRefs: NewPackageRefListFromPackageList(list),
+	}
+}
+
+// Sources returns sources of the snapshot, for snapshots created from query sources
+// could be of different kinds
+func (s *Snapshot) Sources() []SnapshotSource {
+	result := make([]SnapshotSource, 0, len(s.SourceIDs))
+	for _, id := range s.SourceIDs {
+		if s.SourceKind == SourceQuery {
+			kind, sourceID, _ := strings.Cut(id, ":")
+			result = append(result, SnapshotSource{Kind: kind, ID: sourceID})
+		} else {
+			result = append(result, SnapshotSource{Kind: s.SourceKind, ID: id})
+		}
+	}
+
+	return result
+}
+
+// HasSource checks whether snapshot has source of the kind with UUID id
+func (s *Snapshot) HasSource(kind string, id string) bool {
+	for _, source := range s.Sources() {
+		if source.Kind == kind && source.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
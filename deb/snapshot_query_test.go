@@ -0,0 +1,137 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotQuerySuite struct {
+	PackageListMixinSuite
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	mirror            *RemoteRepo
+	local             *LocalRepo
+	snapshot          *Snapshot
+}
+
+var _ = Suite(&SnapshotQuerySuite{})
+
+func (s *SnapshotQuerySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.SetUpPackages()
+
+	list := NewPackageList()
+	_ = list.Add(s.p1)
+	_ = list.Add(s.p2)
+
+	s.mirror, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false)
+	s.mirror.packageRefs = NewPackageRefListFromPackageList(list)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(s.mirror), IsNil)
+
+	list = NewPackageList()
+	_ = list.Add(s.p3)
+
+	s.local = NewLocalRepo("stage", "")
+	s.local.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(s.local), IsNil)
+
+	s.snapshot = NewSnapshotFromPackageList("snap", nil, s.list, "")
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(s.snapshot), IsNil)
+}
+
+func (s *SnapshotQuerySuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *SnapshotQuerySuite) TestLoadSnapshotSource(c *C) {
+	source, err := LoadSnapshotSource(s.collectionFactory, "mirror:yandex")
+	c.Assert(err, IsNil)
+	c.Check(source.Kind, Equals, SourceRemoteRepo)
+	c.Check(source.ID, Equals, s.mirror.UUID)
+	c.Check(source.RefList().Len(), Equals, 2)
+	c.Check(source.ResourceKey(), DeepEquals, s.mirror.Key())
+	c.Check(source.String(), Equals, "mirror:yandex")
+
+	source, err = LoadSnapshotSource(s.collectionFactory, "repo:stage")
+	c.Assert(err, IsNil)
+	c.Check(source.Kind, Equals, SourceLocalRepo)
+	c.Check(source.RefList().Len(), Equals, 1)
+	c.Check(source.String(), Equals, "repo:stage")
+
+	source, err = LoadSnapshotSource(s.collectionFactory, "snapshot:snap")
+	c.Assert(err, IsNil)
+	c.Check(source.Kind, Equals, SourceSnapshot)
+	c.Check(source.RefList().Len(), Equals, 3)
+	c.Check(source.String(), Equals, "snapshot:snap")
+
+	_, err = LoadSnapshotSource(s.collectionFactory, "yandex")
+	c.Check(err, ErrorMatches, "source yandex should be specified as mirror:<name>, repo:<name> or snapshot:<name>")
+
+	_, err = LoadSnapshotSource(s.collectionFactory, "ppa:yandex")
+	c.Check(err, ErrorMatches, "unknown kind of source ppa.*")
+
+	_, err = LoadSnapshotSource(s.collectionFactory, "mirror:none")
+	c.Check(err, ErrorMatches, ".*not found")
+}
+
+func (s *SnapshotQuerySuite) TestFindSnapshotSource(c *C) {
+	source, err := FindSnapshotSource(s.collectionFactory, "mirror:yandex")
+	c.Assert(err, IsNil)
+	c.Check(source.ID, Equals, s.mirror.UUID)
+	c.Check(source.ResourceKey(), DeepEquals, s.mirror.Key())
+	c.Check(source.RefList(), IsNil)
+
+	c.Assert(source.Load(s.collectionFactory), IsNil)
+	c.Check(source.RefList().Len(), Equals, 2)
+
+	_, err = FindSnapshotSource(s.collectionFactory, "snapshot:none")
+	c.Check(err, ErrorMatches, ".*not found")
+
+	// mirror which is being updated is found, but can't be loaded until update finishes
+	s.mirror.MarkAsUpdating()
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Update(s.mirror), IsNil)
+
+	source, err = FindSnapshotSource(s.collectionFactory, "mirror:yandex")
+	c.Assert(err, IsNil)
+	c.Check(source.Load(s.collectionFactory), ErrorMatches, "mirror is locked by update operation.*")
+}
+
+func (s *SnapshotQuerySuite) TestNewSnapshotFromQuery(c *C) {
+	mirror, _ := LoadSnapshotSource(s.collectionFactory, "mirror:yandex")
+	local, _ := LoadSnapshotSource(s.collectionFactory, "repo:stage")
+	sources := []*SnapshotSource{mirror, local}
+
+	refList := MergeSnapshotSources(sources)
+	c.Check(refList.Len(), Equals, 3)
+
+	list := NewPackageList()
+	_ = list.Add(s.p1)
+	_ = list.Add(s.p3)
+
+	snapshot := NewSnapshotFromQuery("kernels", "Name (% *-invaders)", sources, list)
+	c.Check(snapshot.SourceKind, Equals, SourceQuery)
+	c.Check(snapshot.Query, Equals, "Name (% *-invaders)")
+	c.Check(snapshot.Description, Equals, "Query 'Name (% *-invaders)' from mirror:yandex, repo:stage")
+	c.Check(snapshot.NumPackages(), Equals, 2)
+	c.Check(snapshot.Sources(), DeepEquals, []SnapshotSource{
+		{Kind: SourceRemoteRepo, ID: s.mirror.UUID},
+		{Kind: SourceLocalRepo, ID: s.local.UUID},
+	})
+	c.Check(snapshot.HasSource(SourceRemoteRepo, s.mirror.UUID), Equals, true)
+	c.Check(snapshot.HasSource(SourceLocalRepo, s.mirror.UUID), Equals, false)
+
+	collection := s.collectionFactory.SnapshotCollection()
+	c.Assert(collection.Add(snapshot), IsNil)
+
+	c.Check(collection.ByRemoteRepoSource(s.mirror), DeepEquals, []*Snapshot{snapshot})
+	c.Check(collection.ByLocalRepoSource(s.local), DeepEquals, []*Snapshot{snapshot})
+	c.Check(collection.BySnapshotSource(s.snapshot), HasLen, 0)
+
+	snapshot, err := collection.ByName("kernels")
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Query, Equals, "Name (% *-invaders)")
+	c.Check(snapshot.Sources(), HasLen, 2)
+}
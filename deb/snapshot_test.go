@@ -2,10 +2,13 @@ package deb
 
 import (
 	"errors"
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/pgp"
 
 	. "gopkg.in/check.v1"
 )
@@ -78,6 +81,113 @@ func (s *SnapshotSuite) TestNewSnapshotFromRefList(c *C) {
 	c.Check(snapshot.SourceIDs, DeepEquals, []string{snap.UUID})
 }
 
+func (s *SnapshotSuite) manifestPackages(c *C) *PackageCollection {
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	collection := NewPackageCollection(db)
+	for _, p := range []*Package{s.p1, s.p2, s.p3} {
+		c.Assert(collection.Update(p), IsNil)
+	}
+
+	return collection
+}
+
+func (s *SnapshotSuite) TestManifest(c *C) {
+	packageCollection := s.manifestPackages(c)
+
+	snapshot, _ := NewSnapshotFromRepository("snap1", s.repo)
+	snapshot.CreatedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sha256 := "eb4afb9885cba6dc70cccd05b910b2dbccc02c5900578be5e99f0d3dbf9d76a5"
+
+	manifest, err := snapshot.Manifest(packageCollection)
+	c.Assert(err, IsNil)
+	c.Check(string(manifest), Equals, "Format: aptly-snapshot-manifest/1\n"+
+		"UUID: "+snapshot.UUID+"\n"+
+		"Created-At: 2024-01-02T03:04:05Z\n"+
+		"Source-Kind: repo\n"+
+		"Packages: 3\n\n"+
+		"alien-arena-common 7.40-2 i386 "+sha256+"\n"+
+		"lonely-strangers 7.40-2 i386 "+sha256+"\n"+
+		"mars-invaders 7.40-2 i386 "+sha256+"\n")
+
+	// renaming snapshot doesn't change manifest
+	snapshot.Name = "snap2"
+	snapshot.Description = "Renamed"
+	renamed, _ := snapshot.Manifest(packageCollection)
+	c.Check(renamed, DeepEquals, manifest)
+
+	_, err = (&Snapshot{Name: "empty"}).Manifest(packageCollection)
+	c.Check(err, ErrorMatches, "package list of snapshot empty is not loaded")
+
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	_, err = snapshot.Manifest(NewPackageCollection(db))
+	c.Check(err, ErrorMatches, "unable to load package Pi386 alien-arena-common .*")
+}
+
+func (s *SnapshotSuite) TestSignManifest(c *C) {
+	packageCollection := s.manifestPackages(c)
+
+	snapshot, _ := NewSnapshotFromRepository("snap1", s.repo)
+
+	verifier := &pgp.GoVerifier{}
+	verifier.AddKeyring("../pgp/keyrings/aptly.pub")
+	c.Assert(verifier.InitKeyring(false), IsNil)
+
+	c.Check(snapshot.VerifyManifest(verifier, packageCollection), ErrorMatches, "snapshot snap1 is not signed")
+
+	signer := &pgp.GoSigner{}
+	signer.SetBatch(true)
+	signer.SetKeyRing("../pgp/keyrings/aptly.pub", "../pgp/keyrings/aptly.sec")
+	c.Assert(signer.Init(), IsNil)
+
+	c.Assert(snapshot.SignManifest(signer, packageCollection), IsNil)
+	c.Check(snapshot.ManifestSignature, Matches, "(?s)-----BEGIN PGP SIGNATURE-----.*")
+	c.Check(snapshot.VerifyManifest(verifier, packageCollection), IsNil)
+
+	// signature doesn't match once package list changes
+	snapshot.packageRefs = NewPackageRefList()
+	c.Check(snapshot.VerifyManifest(verifier, packageCollection), NotNil)
+}
+
+func (s *SnapshotSuite) TestRetentionPolicyCandidates(c *C) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	mirror1 := []*Snapshot{
+		{Name: "m1-1", SourceKind: SourceRemoteRepo, SourceIDs: []string{"m1"}, CreatedAt: now.Add(-100 * day)},
+		{Name: "m1-2", SourceKind: SourceRemoteRepo, SourceIDs: []string{"m1"}, CreatedAt: now.Add(-50 * day)},
+		{Name: "m1-3", SourceKind: SourceRemoteRepo, SourceIDs: []string{"m1"}, CreatedAt: now.Add(-10 * day)},
+	}
+	mirror2 := []*Snapshot{
+		{Name: "m2-1", SourceKind: SourceRemoteRepo, SourceIDs: []string{"m2"}, CreatedAt: now.Add(-200 * day)},
+	}
+	merge := []*Snapshot{
+		{Name: "merge-1", SourceKind: "snapshot", SourceIDs: []string{"a", "b"}, CreatedAt: now.Add(-120 * day)},
+		{Name: "merge-2", SourceKind: "snapshot", SourceIDs: []string{"b", "a"}, CreatedAt: now.Add(-5 * day)},
+	}
+
+	snapshots := []*Snapshot{mirror1[2], merge[0], mirror2[0], mirror1[0], merge[1], mirror1[1]}
+
+	names := func(list []*Snapshot) (result []string) {
+		for _, snapshot := range list {
+			result = append(result, snapshot.Name)
+		}
+		return
+	}
+
+	policy := &SnapshotRetentionPolicy{}
+	c.Check(policy.Candidates(snapshots, now), HasLen, 0)
+
+	policy = &SnapshotRetentionPolicy{KeepLast: 1}
+	c.Check(names(policy.Candidates(snapshots, now)), DeepEquals, []string{"merge-1", "m1-1", "m1-2"})
+
+	policy = &SnapshotRetentionPolicy{KeepWithin: 90 * day}
+	c.Check(names(policy.Candidates(snapshots, now)), DeepEquals, []string{"m2-1", "merge-1", "m1-1"})
+
+	policy = &SnapshotRetentionPolicy{KeepLast: 1, KeepWithin: 90 * day}
+	c.Check(names(policy.Candidates(snapshots, now)), DeepEquals, []string{"merge-1", "m1-1"})
+}
+
 func (s *SnapshotSuite) TestKey(c *C) {
 	snapshot, _ := NewSnapshotFromRepository("snap1", s.repo)
 	c.Assert(len(snapshot.Key()), Equals, 37)
@@ -93,11 +203,13 @@ func (s *SnapshotSuite) TestRefKey(c *C) {
 
 func (s *SnapshotSuite) TestEncodeDecode(c *C) {
 	snapshot, _ := NewSnapshotFromRepository("snap1", s.repo)
+	snapshot.Provenance = "git commit 1234abcd"
 	s.repo.packageRefs = s.reflist
 
 	snapshot2 := &Snapshot{}
 	c.Assert(snapshot2.Decode(snapshot.Encode()), IsNil)
 	c.Assert(snapshot2.Name, Equals, snapshot.Name)
+	c.Assert(snapshot2.Provenance, Equals, snapshot.Provenance)
 	c.Assert(snapshot2.packageRefs, IsNil)
 }
 
@@ -262,6 +374,64 @@ func (s *SnapshotCollectionSuite) TestFindSnapshotSource(c *C) {
 	c.Check(s.collection.BySnapshotSource(snapshot5), DeepEquals, []*Snapshot(nil))
 }
 
+func (s *SnapshotCollectionSuite) TestDeltaStorage(c *C) {
+	s.collection.deltaInterval = 3
+
+	refs := func(names ...string) *PackageRefList {
+		list := &PackageRefList{}
+		for _, name := range names {
+			list.Refs = append(list.Refs, []byte("Pi386 "+name+" 1.0 00000000"))
+		}
+		sort.Sort(list)
+		return list
+	}
+
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	snapshots := []*Snapshot{}
+	for i, list := range []*PackageRefList{
+		refs(names...),
+		refs(append(names[1:], "k")...),
+		refs(append(names[2:], "k", "l")...),
+		refs(append(names[2:], "k", "l", "m")...),
+		refs("x", "y", "z"),
+	} {
+		s.repo1.packageRefs = list
+		snapshot, _ := NewSnapshotFromRepository(fmt.Sprintf("daily%d", i), s.repo1)
+		snapshot.CreatedAt = snapshot.CreatedAt.Add(time.Duration(i) * time.Hour)
+		c.Assert(s.collection.Add(snapshot), IsNil)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	// full copy, two deltas, full checkpoint, full as delta is too large
+	c.Check(snapshots[0].RefBaseUUID, Equals, "")
+	c.Check(snapshots[1].RefBaseUUID, Equals, snapshots[0].UUID)
+	c.Check(snapshots[2].RefBaseUUID, Equals, snapshots[1].UUID)
+	c.Check(snapshots[2].RefDeltaDepth, Equals, 2)
+	c.Check(snapshots[3].RefBaseUUID, Equals, "")
+	c.Check(snapshots[4].RefBaseUUID, Equals, "")
+
+	_, err := s.db.Get(snapshots[2].RefKey())
+	c.Check(err, Equals, database.ErrNotFound)
+	_, err = s.db.Get(snapshots[2].RefDeltaKey())
+	c.Check(err, IsNil)
+
+	collection := NewSnapshotCollection(s.db)
+	snapshot, err := collection.ByName("daily2")
+	c.Assert(err, IsNil)
+	c.Assert(collection.LoadComplete(snapshot), IsNil)
+	c.Check(snapshot.RefList().Strings(), DeepEquals, refs(append(names[2:], "k", "l")...).Strings())
+
+	// dropping base stores dependent snapshot in full
+	c.Assert(collection.Drop(snapshots[1]), IsNil)
+
+	collection = NewSnapshotCollection(s.db)
+	snapshot, err = collection.ByName("daily2")
+	c.Assert(err, IsNil)
+	c.Check(snapshot.RefBaseUUID, Equals, "")
+	c.Assert(collection.LoadComplete(snapshot), IsNil)
+	c.Check(snapshot.RefList().Strings(), DeepEquals, refs(append(names[2:], "k", "l")...).Strings())
+}
+
 func (s *SnapshotCollectionSuite) TestDrop(c *C) {
 	s.collection.Add(s.snapshot1)
 	s.collection.Add(s.snapshot2)
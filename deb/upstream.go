@@ -0,0 +1,110 @@
+package deb
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// UpstreamSnapshotPlaceholder is replaced in names of snapshots created from mirror
+// with names of snapshots published by upstream aptly
+const UpstreamSnapshotPlaceholder = "{upstream}"
+
+// upstreamPublishedRepo is published repository as listed by aptly API
+type upstreamPublishedRepo struct {
+	Storage      string
+	Prefix       string
+	Distribution string
+	SourceKind   string
+	Sources      []struct {
+		Component string
+		Name      string
+	}
+}
+
+// IsAptlyUpstream returns true if mirror is replicating repository published by another aptly
+func (repo *RemoteRepo) IsAptlyUpstream() bool {
+	return repo.AptlyAPI != ""
+}
+
+// FetchUpstreamSources looks up published repository being mirrored with the API of upstream
+// aptly, and records names of snapshots (or local repos) it has been published from
+func (repo *RemoteRepo) FetchUpstreamSources(d aptly.Downloader) error {
+	if !repo.IsAptlyUpstream() {
+		return nil
+	}
+
+	apiURL := strings.TrimSuffix(repo.AptlyAPI, "/") + "/api/publish"
+
+	file, err := http.DownloadTemp(gocontext.TODO(), d, apiURL)
+	if err != nil {
+		return fmt.Errorf("unable to query upstream aptly: %s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	var list []upstreamPublishedRepo
+	err = json.NewDecoder(file).Decode(&list)
+	if err != nil {
+		return fmt.Errorf("unable to parse response of upstream aptly: %s", err)
+	}
+
+	storage, prefix := ParsePrefix(repo.AptlyPrefix)
+	if prefix == "" {
+		prefix = "."
+	}
+
+	for _, published := range list {
+		if published.Storage != storage || published.Prefix != prefix || published.Distribution != repo.Distribution {
+			continue
+		}
+
+		repo.UpstreamSourceKind = published.SourceKind
+		repo.UpstreamSources = make(map[string]string, len(published.Sources))
+		for _, source := range published.Sources {
+			repo.UpstreamSources[source.Component] = source.Name
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("published repository %s/%s not found in upstream aptly %s", repo.AptlyPrefix, repo.Distribution, repo.AptlyAPI)
+}
+
+// UpstreamSourcesString returns human-readable list of upstream sources, e.g. "main: wheezy-main-2024"
+func (repo *RemoteRepo) UpstreamSourcesString() string {
+	result := make([]string, 0, len(repo.UpstreamSources))
+	for _, component := range utils.StrMapSortedKeys(repo.UpstreamSources) {
+		result = append(result, fmt.Sprintf("%s: %s", component, repo.UpstreamSources[component]))
+	}
+
+	return strings.Join(result, ", ")
+}
+
+// ExpandSnapshotName replaces UpstreamSnapshotPlaceholder in the snapshot name with names
+// of snapshots published by upstream aptly (sorted by component and joined with '+')
+func (repo *RemoteRepo) ExpandSnapshotName(name string) (string, error) {
+	if !strings.Contains(name, UpstreamSnapshotPlaceholder) {
+		return name, nil
+	}
+
+	if len(repo.UpstreamSources) == 0 {
+		return "", fmt.Errorf("mirror %s doesn't track upstream aptly snapshots, %s can't be used in snapshot name",
+			repo.Name, UpstreamSnapshotPlaceholder)
+	}
+
+	names := []string{}
+	for _, component := range utils.StrMapSortedKeys(repo.UpstreamSources) {
+		if !utils.StrSliceHasItem(names, repo.UpstreamSources[component]) {
+			names = append(names, repo.UpstreamSources[component])
+		}
+	}
+
+	return strings.ReplaceAll(name, UpstreamSnapshotPlaceholder, strings.Join(names, "+")), nil
+}
@@ -0,0 +1,79 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/http"
+
+	. "gopkg.in/check.v1"
+)
+
+type UpstreamSuite struct {
+	repo       *RemoteRepo
+	downloader *http.FakeDownloader
+}
+
+var _ = Suite(&UpstreamSuite{})
+
+const upstreamPublishList = `[
+  {"Storage": "", "Prefix": ".", "Distribution": "stable", "SourceKind": "snapshot",
+   "Sources": [{"Component": "main", "Name": "main-2024-06-01"}, {"Component": "contrib", "Name": "contrib-2024-05-20"}]},
+  {"Storage": "s3:edge", "Prefix": "debian", "Distribution": "stable", "SourceKind": "local",
+   "Sources": [{"Component": "main", "Name": "edge-repo"}]}
+]`
+
+func (s *UpstreamSuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("edge", "http://repo.example.com/", "stable", []string{"main"}, []string{}, false, false, false)
+	s.repo.AptlyAPI = "http://aptly.example.com:8080/"
+	s.downloader = http.NewFakeDownloader()
+}
+
+func (s *UpstreamSuite) TestFetchUpstreamSources(c *C) {
+	s.downloader.ExpectResponse("http://aptly.example.com:8080/api/publish", upstreamPublishList)
+
+	c.Assert(s.repo.FetchUpstreamSources(s.downloader), IsNil)
+	c.Check(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.UpstreamSourceKind, Equals, "snapshot")
+	c.Check(s.repo.UpstreamSources, DeepEquals, map[string]string{"main": "main-2024-06-01", "contrib": "contrib-2024-05-20"})
+	c.Check(s.repo.UpstreamSourcesString(), Equals, "contrib: contrib-2024-05-20, main: main-2024-06-01")
+
+	s.repo.AptlyPrefix = "s3:edge:debian/"
+	s.downloader.ExpectResponse("http://aptly.example.com:8080/api/publish", upstreamPublishList)
+
+	c.Assert(s.repo.FetchUpstreamSources(s.downloader), IsNil)
+	c.Check(s.repo.UpstreamSourceKind, Equals, "local")
+	c.Check(s.repo.UpstreamSources, DeepEquals, map[string]string{"main": "edge-repo"})
+
+	s.repo.AptlyPrefix = "ubuntu"
+	s.downloader.ExpectResponse("http://aptly.example.com:8080/api/publish", upstreamPublishList)
+
+	c.Check(s.repo.FetchUpstreamSources(s.downloader), ErrorMatches, "published repository ubuntu/stable not found in upstream aptly http://aptly.example.com:8080/")
+
+	s.downloader.ExpectResponse("http://aptly.example.com:8080/api/publish", "<html>")
+	c.Check(s.repo.FetchUpstreamSources(s.downloader), ErrorMatches, "unable to parse response of upstream aptly: .*")
+}
+
+func (s *UpstreamSuite) TestFetchUpstreamSourcesNotAptly(c *C) {
+	s.repo.AptlyAPI = ""
+
+	c.Check(s.repo.IsAptlyUpstream(), Equals, false)
+	c.Check(s.repo.FetchUpstreamSources(s.downloader), IsNil)
+	c.Check(s.repo.UpstreamSources, IsNil)
+}
+
+func (s *UpstreamSuite) TestExpandSnapshotName(c *C) {
+	name, err := s.repo.ExpandSnapshotName("edge-main")
+	c.Check(err, IsNil)
+	c.Check(name, Equals, "edge-main")
+
+	_, err = s.repo.ExpandSnapshotName("edge-{upstream}")
+	c.Check(err, ErrorMatches, "mirror edge doesn't track upstream aptly snapshots, \\{upstream\\} can't be used in snapshot name")
+
+	s.repo.UpstreamSources = map[string]string{"main": "main-2024-06-01"}
+	name, err = s.repo.ExpandSnapshotName("edge-{upstream}")
+	c.Check(err, IsNil)
+	c.Check(name, Equals, "edge-main-2024-06-01")
+
+	s.repo.UpstreamSources = map[string]string{"main": "main-2024-06-01", "contrib": "contrib-2024-05-20", "non-free": "main-2024-06-01"}
+	name, err = s.repo.ExpandSnapshotName("{upstream}")
+	c.Check(err, IsNil)
+	c.Check(name, Equals, "contrib-2024-05-20+main-2024-06-01")
+}
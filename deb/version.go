@@ -181,6 +181,10 @@ const (
 	VersionGreater
 	VersionPatternMatch
 	VersionRegexp
+	VersionContains
+	// VersionNotEqual and VersionIn (value is one of the list) are supported only in field queries
+	VersionNotEqual
+	VersionIn
 )
 
 // Dependency is a parsed version of Debian dependency to package
@@ -189,11 +193,18 @@ type Dependency struct {
 	Relation     int
 	Version      string
 	Architecture string
-	Regexp       *regexp.Regexp
+	// ArchRestriction lists architectures dependency applies to, e.g. "linux-any !armel"
+	ArchRestriction string
+	Regexp          *regexp.Regexp
+	// CaseInsensitive is set for pattern relations matching ignoring case
+	CaseInsensitive bool
 }
 
 // Hash calculates some predefined unique ID of Dependency
 func (d *Dependency) Hash() string {
+	if d.CaseInsensitive {
+		return fmt.Sprintf("%s:%s:%d:%s:i", d.Architecture, d.Pkg, d.Relation, d.Version)
+	}
 	return fmt.Sprintf("%s:%s:%d:%s", d.Architecture, d.Pkg, d.Relation, d.Version)
 }
 
@@ -215,9 +226,14 @@ func (d *Dependency) String() string {
 		rel = "%"
 	case VersionRegexp:
 		rel = "~"
+	case VersionContains:
+		rel = "*="
 	case VersionDontCare:
 		return fmt.Sprintf("%s [%s]", d.Pkg, d.Architecture)
 	}
+	if d.CaseInsensitive {
+		return fmt.Sprintf("%s (%s %s i) [%s]", d.Pkg, rel, d.Version, d.Architecture)
+	}
 	return fmt.Sprintf("%s (%s %s) [%s]", d.Pkg, rel, d.Version, d.Architecture)
 }
 
@@ -236,18 +252,40 @@ func ParseDependencyVariants(variants string) (l []Dependency, err error) {
 	return
 }
 
-// ParseDependencyArch parses the dependency name in format "pkg:any" into name and architecture
+// AppliesTo checks whether dependency applies to architecture according to
+// architecture restrictions, restrictions are ignored for source and all architectures
+func (d *Dependency) AppliesTo(arch string) bool {
+	restrictions := strings.Fields(d.ArchRestriction)
+	if len(restrictions) == 0 || arch == ArchitectureSource || arch == ArchitectureAll {
+		return true
+	}
+
+	negated := strings.HasPrefix(restrictions[0], "!")
+	for _, restriction := range restrictions {
+		if ArchitectureMatches(strings.TrimPrefix(restriction, "!"), arch) {
+			return !negated
+		}
+	}
+
+	return negated
+}
+
+// ParseDependencyArch parses the dependency name in format "pkg:any" into name and architecture,
+// :any and :native qualifiers are mapped to empty architecture (architecture being processed)
 func ParseDependencyArch(d *Dependency) {
 	if strings.ContainsRune(d.Pkg, ':') {
 		parts := strings.SplitN(d.Pkg, ":", 2)
 		d.Pkg, d.Architecture = parts[0], parts[1]
-		if d.Architecture == "any" {
+		if d.Architecture == ArchitectureAny || d.Architecture == ArchitectureNative {
 			d.Architecture = ""
 		}
 	}
 }
 
 // ParseDependency parses dependency in format "pkg (>= 1.35) [arch]" into parts
+//
+// Architecture could be specified either as "{arch}" suffix (in queries) or as
+// Debian architecture restriction list "[linux-any !armel]"
 func ParseDependency(dep string) (d Dependency, err error) {
 	if strings.HasSuffix(dep, "}") {
 		i := strings.LastIndex(dep, "{")
@@ -260,6 +298,25 @@ func ParseDependency(dep string) (d Dependency, err error) {
 		dep = strings.TrimSpace(dep[:i])
 	}
 
+	if strings.HasSuffix(dep, "]") {
+		i := strings.LastIndex(dep, "[")
+		if i == -1 {
+			err = fmt.Errorf("unable to parse dependency: %s", dep)
+			return
+		}
+		restrictions := strings.Fields(dep[i+1 : len(dep)-1])
+		for _, restriction := range restrictions {
+			if strings.HasPrefix(restriction, "!") != strings.HasPrefix(restrictions[0], "!") {
+				err = fmt.Errorf("unable to parse dependency: %s, architecture restrictions can't mix negated and regular architectures", dep)
+				return
+			}
+		}
+
+		d.ArchRestriction = strings.Join(restrictions, " ")
+
+		dep = strings.TrimSpace(dep[:i])
+	}
+
 	if !strings.HasSuffix(dep, ")") {
 		d.Pkg = strings.TrimSpace(dep)
 		d.Relation = VersionDontCare
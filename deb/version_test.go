@@ -203,6 +203,41 @@ func (s *VersionSuite) TestParseDependency(c *C) {
 
 	d, e = ParseDependency("dpkg ) {i386}")
 	c.Check(e, ErrorMatches, "unable to parse.*")
+
+	d, e = ParseDependency("libselinux1-dev (>= 3.1) [linux-any]")
+	c.Check(e, IsNil)
+	c.Check(d.Pkg, Equals, "libselinux1-dev")
+	c.Check(d.Relation, Equals, VersionGreaterOrEqual)
+	c.Check(d.Version, Equals, "3.1")
+	c.Check(d.Architecture, Equals, "")
+	c.Check(d.ArchRestriction, Equals, "linux-any")
+
+	d, e = ParseDependency("libunwind-dev [!armel  !hurd-any]")
+	c.Check(e, IsNil)
+	c.Check(d.Pkg, Equals, "libunwind-dev")
+	c.Check(d.Relation, Equals, VersionDontCare)
+	c.Check(d.ArchRestriction, Equals, "!armel !hurd-any")
+
+	d, e = ParseDependency("libunwind-dev [amd64 !armel]")
+	c.Check(e, ErrorMatches, "unable to parse.*can't mix negated and regular architectures")
+
+	d, e = ParseDependency("dpkg amd64]")
+	c.Check(e, ErrorMatches, "unable to parse.*")
+}
+
+func (s *VersionSuite) TestDependencyAppliesTo(c *C) {
+	d, _ := ParseDependency("dpkg")
+	c.Check(d.AppliesTo("amd64"), Equals, true)
+
+	d, _ = ParseDependency("libselinux1-dev [linux-any]")
+	c.Check(d.AppliesTo("amd64"), Equals, true)
+	c.Check(d.AppliesTo("hurd-i386"), Equals, false)
+	c.Check(d.AppliesTo("source"), Equals, true)
+
+	d, _ = ParseDependency("libunwind-dev [!any-arm !hurd-any]")
+	c.Check(d.AppliesTo("amd64"), Equals, true)
+	c.Check(d.AppliesTo("armhf"), Equals, false)
+	c.Check(d.AppliesTo("hurd-i386"), Equals, false)
 }
 
 func (s *VersionSuite) TestParseDependencyVariants(c *C) {
@@ -238,4 +273,8 @@ func (s *VersionSuite) TestDependencyString(c *C) {
 	d, _ = ParseDependency("dpkg:any")
 	c.Check(d.Pkg, Equals, "dpkg")
 	c.Check(d.Architecture, Equals, "")
+
+	d, _ = ParseDependency("dpkg:native")
+	c.Check(d.Pkg, Equals, "dpkg")
+	c.Check(d.Architecture, Equals, "")
 }
@@ -59,7 +59,8 @@ func (pool *PackagePool) LegacyPath(filename string, checksums *utils.ChecksumIn
 	return filepath.Join(hashMD5[0:2], hashMD5[2:4], filename), nil
 }
 
-// buildPoolPath generates pool path based on file checksum
+// buildPoolPath generates pool path based on file contents (SHA256), so that files
+// with identical contents are stored exactly once, whatever their names are
 func (pool *PackagePool) buildPoolPath(filename string, checksums *utils.ChecksumInfo) (string, error) {
 	filename = filepath.Base(filename)
 	if filename == "." || filename == "/" {
@@ -73,9 +74,50 @@ func (pool *PackagePool) buildPoolPath(filename string, checksums *utils.Checksu
 		return "", fmt.Errorf("unable to compute pool location for filename %v, SHA256 is missing", filename)
 	}
 
+	return filepath.Join(hash[0:2], hash[2:4], hash[4:]), nil
+}
+
+// namedPoolPath returns path relative to pool's root for aptly before content-addressed
+// pool layout (based on SHA256 and filename)
+func (pool *PackagePool) namedPoolPath(filename string, checksums *utils.ChecksumInfo) (string, error) {
+	filename = filepath.Base(filename)
+	if filename == "." || filename == "/" {
+		return "", fmt.Errorf("filename %s is invalid", filename)
+	}
+
+	hash := checksums.SHA256
+
+	if len(hash) < 32 {
+		return "", fmt.Errorf("unable to compute pool location for filename %v, SHA256 is missing", filename)
+	}
+
 	return filepath.Join(hash[0:2], hash[2:4], hash[4:32]+"_"+filename), nil
 }
 
+// fallbackPoolPaths returns list of locations where file could have been stored by previous
+// versions of aptly
+func (pool *PackagePool) fallbackPoolPaths(filename string, checksums *utils.ChecksumInfo) ([]string, error) {
+	result := []string{}
+
+	if len(checksums.SHA256) >= 32 {
+		namedPath, err := pool.namedPoolPath(filename, checksums)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, namedPath)
+	}
+
+	if pool.supportLegacyPaths && checksums.MD5 != "" {
+		legacyPath, err := pool.LegacyPath(filename, checksums)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, legacyPath)
+	}
+
+	return result, nil
+}
+
 // FilepathList returns file paths of all the files in the pool
 func (pool *PackagePool) FilepathList(progress aptly.Progress) ([]string, error) {
 	pool.Lock()
@@ -178,13 +220,11 @@ func (pool *PackagePool) Verify(poolPath, basename string, checksums *utils.Chec
 			possiblePoolPaths = append(possiblePoolPaths, modernPath)
 		}
 
-		if pool.supportLegacyPaths && checksums.MD5 != "" {
-			legacyPath, err := pool.LegacyPath(basename, checksums)
-			if err != nil {
-				return "", false, err
-			}
-			possiblePoolPaths = append(possiblePoolPaths, legacyPath)
+		fallbackPaths, err := pool.fallbackPoolPaths(basename, checksums)
+		if err != nil {
+			return "", false, err
 		}
+		possiblePoolPaths = append(possiblePoolPaths, fallbackPaths...)
 	}
 
 	for _, path := range possiblePoolPaths {
@@ -287,41 +327,39 @@ func (pool *PackagePool) Import(srcPath, basename string, checksums *utils.Check
 		return "", fmt.Errorf("unable to import into pool: file %s already exists", fullPoolPath)
 	}
 
-	if pool.supportLegacyPaths {
-		// file doesn't exist at new location, check legacy location
-		var (
-			legacyTargetInfo           os.FileInfo
-			legacyPath, legacyFullPath string
-		)
+	// file doesn't exist at new location, check locations used by previous versions
+	fallbackPaths, err := pool.fallbackPoolPaths(basename, checksums)
+	if err != nil {
+		return "", err
+	}
 
-		legacyPath, err = pool.LegacyPath(basename, checksums)
-		if err != nil {
-			return "", err
-		}
-		legacyFullPath = filepath.Join(pool.rootPath, legacyPath)
+	for _, fallbackPath := range fallbackPaths {
+		var fallbackTargetInfo os.FileInfo
+
+		fallbackFullPath := filepath.Join(pool.rootPath, fallbackPath)
 
-		legacyTargetInfo, err = os.Stat(legacyFullPath)
+		fallbackTargetInfo, err = os.Stat(fallbackFullPath)
 		if err != nil {
 			if !os.IsNotExist(err) {
 				return "", err
 			}
-		} else {
-			// legacy file exists
-			if legacyTargetInfo.Size() == sourceInfo.Size() {
-				// file exists at legacy path and it's same size, consider it's already in the pool
-				var targetChecksums *utils.ChecksumInfo
-
-				targetChecksums, err = pool.ensureChecksums(legacyPath, legacyFullPath, checksumStorage)
-				if err != nil {
-					return "", err
-				}
-
-				*checksums = *targetChecksums
-				return legacyPath, nil
+			continue
+		}
+
+		if fallbackTargetInfo.Size() == sourceInfo.Size() {
+			// file exists at old path and it's same size, consider it's already in the pool
+			var targetChecksums *utils.ChecksumInfo
+
+			targetChecksums, err = pool.ensureChecksums(fallbackPath, fallbackFullPath, checksumStorage)
+			if err != nil {
+				return "", err
 			}
 
-			// size is different, import at new path
+			*checksums = *targetChecksums
+			return fallbackPath, nil
 		}
+
+		// size is different, import at new path
 	}
 
 	// create subdirs as necessary
@@ -107,7 +107,7 @@ func isSameDevice(s *PackagePoolSuite) bool {
 func (s *PackagePoolSuite) TestImportOk(c *C) {
 	path, err := s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// SHA256 should be automatically calculated
 	c.Check(s.checksum.SHA256, Equals, "c76b4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum storage is filled with new checksum
@@ -123,10 +123,10 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 		c.Check(info.Sys().(*syscall.Stat_t).Nlink, Equals, uint64(1))
 	}
 
-	// import as different name
+	// import as different name, same contents are stored once
 	path, err = s.pool.Import(s.debFile, "some.deb", &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_some.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum storage is filled with new checksum
 	c.Check(s.cs.(*MockChecksumStorage).Store[path].SHA256, Equals, "c76b4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 
@@ -134,7 +134,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	s.checksum.SHA512 = "" // clear checksum
 	path, err = s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on checksum storage
 	c.Check(s.checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 
@@ -143,7 +143,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	s.checksum.SHA512 = "" // clear checksum
 	path, err = s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on re-calculation of file in the pool
 	c.Check(s.checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 
@@ -151,7 +151,7 @@ func (s *PackagePoolSuite) TestImportOk(c *C) {
 	s.checksum.SHA512 = "" // clear checksum
 	path, err = s.pool.Import(s.debFile, "other.deb", &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_other.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 	// checksum is filled back based on re-calculation of source file
 	c.Check(s.checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 }
@@ -170,6 +170,36 @@ func (s *PackagePoolSuite) TestImportLegacy(c *C) {
 	c.Check(s.checksum.SHA512, Equals, "d7302241373da972aa9b9e71d2fd769b31a38f71182aa71bc0d69d090d452c69bb74b8612c002ccf8a89c279ced84ac27177c8b92d20f00023b3d268e6cec69c")
 }
 
+func (s *PackagePoolSuite) TestImportNamed(c *C) {
+	os.MkdirAll(filepath.Join(s.pool.rootPath, "c7", "6b"), 0755)
+	err := utils.CopyFile(s.debFile, filepath.Join(s.pool.rootPath, "c7", "6b", "4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb"))
+	c.Assert(err, IsNil)
+
+	var path string
+	path, err = s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
+	c.Check(err, IsNil)
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+
+	// under different name, file is stored at content-addressed path
+	path, err = s.pool.Import(s.debFile, "other.deb", &s.checksum, false, s.cs)
+	c.Check(err, IsNil)
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
+}
+
+func (s *PackagePoolSuite) TestVerifyNamed(c *C) {
+	s.checksum.SHA256 = "c76b4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12"
+	s.checksum.Size = 2738
+
+	os.MkdirAll(filepath.Join(s.pool.rootPath, "c7", "6b"), 0755)
+	err := utils.CopyFile(s.debFile, filepath.Join(s.pool.rootPath, "c7", "6b", "4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb"))
+	c.Assert(err, IsNil)
+
+	path, exists, err := s.pool.Verify("", filepath.Base(s.debFile), &s.checksum, s.cs)
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, true)
+}
+
 func (s *PackagePoolSuite) TestVerifyLegacy(c *C) {
 	s.checksum.Size = 2738
 	// file doesn't exist yet
@@ -200,7 +230,7 @@ func (s *PackagePoolSuite) TestVerify(c *C) {
 	// import file
 	path, err := s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 
 	// check existence
 	ppath, exists, err = s.pool.Verify("", filepath.Base(s.debFile), &s.checksum, s.cs)
@@ -285,7 +315,7 @@ func (s *PackagePoolSuite) TestImportMove(c *C) {
 
 	path, err := s.pool.Import(tmpPath, filepath.Base(tmpPath), &s.checksum, true, s.cs)
 	c.Check(err, IsNil)
-	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
+	c.Check(path, Equals, "c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12")
 
 	info, err := s.pool.Stat(path)
 	c.Assert(err, IsNil)
@@ -300,7 +330,7 @@ func (s *PackagePoolSuite) TestImportNotExist(c *C) {
 
 func (s *PackagePoolSuite) TestImportOverwrite(c *C) {
 	os.MkdirAll(filepath.Join(s.pool.rootPath, "c7", "6b"), 0755)
-	ioutil.WriteFile(filepath.Join(s.pool.rootPath, "c7", "6b", "4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb"), []byte("1"), 0644)
+	ioutil.WriteFile(filepath.Join(s.pool.rootPath, "c7", "6b", "4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12"), []byte("1"), 0644)
 
 	_, err := s.pool.Import(s.debFile, filepath.Base(s.debFile), &s.checksum, false, s.cs)
 	c.Check(err, ErrorMatches, "unable to import into pool.*")
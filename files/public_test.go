@@ -223,13 +223,13 @@ func (s *PublishedStorageSuite) TestLinkFromPool(c *C) {
 	for _, t := range tests {
 		tmpPath := filepath.Join(c.MkDir(), t.sourcePath)
 		os.MkdirAll(filepath.Dir(tmpPath), 0777)
-		err := ioutil.WriteFile(tmpPath, []byte("Contents"), 0644)
+		err := ioutil.WriteFile(tmpPath, []byte("Contents of "+t.sourcePath), 0644)
 		c.Assert(err, IsNil)
 
 		sourceChecksum, err := utils.ChecksumsForFile(tmpPath)
 		c.Assert(err, IsNil)
 
-		srcPoolPath, err := pool.Import(tmpPath, t.sourcePath, &utils.ChecksumInfo{MD5: "c1df1da7a1ce305a3b60af9d5733ac1d"}, false, s.cs)
+		srcPoolPath, err := pool.Import(tmpPath, t.sourcePath, &utils.ChecksumInfo{}, false, s.cs)
 		c.Assert(err, IsNil)
 
 		// Test using hardlinks
@@ -276,7 +276,7 @@ func (s *PublishedStorageSuite) TestLinkFromPool(c *C) {
 
 	// test linking files to duplicate final name
 	tmpPath := filepath.Join(c.MkDir(), "mars-invaders_1.03.deb")
-	err := ioutil.WriteFile(tmpPath, []byte("cONTENTS"), 0644)
+	err := ioutil.WriteFile(tmpPath, []byte("cONTENTS OF mars-invaders_1.03.deb"), 0644)
 	c.Assert(err, IsNil)
 
 	sourceChecksum, err := utils.ChecksumsForFile(tmpPath)
@@ -0,0 +1,507 @@
+// Package gcs handles publishing to Google Cloud Storage
+package gcs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+const (
+	defaultEndpoint     = "https://storage.googleapis.com"
+	defaultTokenURI     = "https://oauth2.googleapis.com/token"
+	defaultMetadataHost = "metadata.google.internal"
+	metadataTokenPath   = "/computeMetadata/v1/instance/service-accounts/default/token"
+	readWriteScope      = "https://www.googleapis.com/auth/devstorage.read_write"
+	fullControlScope    = "https://www.googleapis.com/auth/devstorage.full_control"
+)
+
+// tokenSource provides OAuth2 access tokens for GCS API requests
+//
+// Only two ways to get token are supported: service account key (OAuth2 JWT bearer grant,
+// RFC 7523) and GCE metadata server. Both are simple enough to be implemented here instead
+// of depending on cloud.google.com/go/storage and golang.org/x/oauth2, which would pull in
+// large dependency tree (gRPC, OpenTelemetry, ...) for a handful of JSON API calls. Other
+// kinds of credentials (user credentials, workload identity federation, impersonation) are
+// not supported.
+type tokenSource interface {
+	Token() (string, error)
+}
+
+// serviceAccountTokenSource exchanges self-signed JWT for access token
+// using service account key (JSON file)
+type serviceAccountTokenSource struct {
+	client *http.Client
+	email  string
+	key    *rsa.PrivateKey
+	uri    string
+	scope  string
+
+	sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// metadataTokenSource fetches access tokens from GCE metadata server
+type metadataTokenSource struct {
+	client *http.Client
+	url    string
+
+	sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+
+	// error response of OAuth2 token endpoint (RFC 6749, section 5.2)
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// newTokenSource builds token source from service account JSON file, falling back to
+// GOOGLE_APPLICATION_CREDENTIALS and to GCE metadata server
+//
+// Setting ACLs on objects requires full control scope, otherwise read-write scope is requested.
+// Scopes of tokens from metadata server are fixed by instance configuration.
+func newTokenSource(client *http.Client, credentialsFile string, acl string) (tokenSource, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	if credentialsFile == "" {
+		// GCE_METADATA_HOST overrides metadata server address, the same way as in Google Cloud SDKs
+		host := os.Getenv("GCE_METADATA_HOST")
+		if host == "" {
+			host = defaultMetadataHost
+		}
+
+		return &metadataTokenSource{client: client, url: "http://" + host + metadataTokenPath}, nil
+	}
+
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %s", err)
+	}
+
+	scope := readWriteScope
+	if acl != "" {
+		scope = fullControlScope
+	}
+
+	return newServiceAccountTokenSource(client, data, scope)
+}
+
+func newServiceAccountTokenSource(client *http.Client, data []byte, scope string) (*serviceAccountTokenSource, error) {
+	var account struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+
+	err := json.Unmarshal(data, &account)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse credentials: %s", err)
+	}
+
+	if account.Type != "service_account" {
+		return nil, fmt.Errorf("unsupported credentials type %q, service account key is required", account.Type)
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("unable to parse credentials: private key is not PEM encoded")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key: %s", err)
+		}
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse private key: RSA key is required")
+	}
+
+	if account.TokenURI == "" {
+		account.TokenURI = defaultTokenURI
+	}
+
+	return &serviceAccountTokenSource{
+		client: client,
+		email:  account.ClientEmail,
+		key:    key,
+		uri:    account.TokenURI,
+		scope:  scope,
+	}, nil
+}
+
+// assertion builds signed JWT to be exchanged for access token
+func (ts *serviceAccountTokenSource) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   ts.email,
+		"scope": ts.scope,
+		"aud":   ts.uri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token returns cached access token or requests new one
+func (ts *serviceAccountTokenSource) Token() (string, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	now := time.Now()
+	if ts.token != "" && now.Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	assertion, err := ts.assertion(now)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign token request: %s", err)
+	}
+
+	resp, err := ts.client.PostForm(ts.uri, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch access token: %s", err)
+	}
+
+	ts.token, ts.expiry, err = parseTokenResponse(resp, now)
+	return ts.token, err
+}
+
+// Token returns cached access token or requests new one
+func (ts *metadataTokenSource) Token() (string, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	now := time.Now()
+	if ts.token != "" && now.Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	req, err := http.NewRequest("GET", ts.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch access token from metadata server: %s", err)
+	}
+
+	ts.token, ts.expiry, err = parseTokenResponse(resp, now)
+	return ts.token, err
+}
+
+func parseTokenResponse(resp *http.Response, now time.Time) (string, time.Time, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		var token tokenResponse
+		if json.Unmarshal(body, &token) == nil && token.Error != "" {
+			return "", time.Time{}, fmt.Errorf("unable to fetch access token: %s: %s", token.Error, token.ErrorDescription)
+		}
+
+		return "", time.Time{}, fmt.Errorf("unable to fetch access token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token tokenResponse
+	err := json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to parse access token: %s", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("unable to parse access token: token is missing in response")
+	}
+
+	// refresh token a bit earlier than it actually expires
+	return token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute), nil
+}
+
+// apiError is error returned by GCS JSON API
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("GCS API error %d: %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.Code == http.StatusNotFound
+}
+
+// gcsObject is (partial) GCS object resource
+type gcsObject struct {
+	Name         string            `json:"name,omitempty"`
+	MD5Hash      string            `json:"md5Hash,omitempty"`
+	CacheControl string            `json:"cacheControl,omitempty"`
+	ContentType  string            `json:"contentType,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// md5 returns MD5 checksum of the object as hex string
+func (o *gcsObject) md5() string {
+	decoded, err := base64.StdEncoding.DecodeString(o.MD5Hash)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(decoded)
+}
+
+// gcsClient is minimal client for GCS JSON API
+type gcsClient struct {
+	client   *http.Client
+	tokens   tokenSource
+	endpoint string
+	bucket   string
+}
+
+func (gc *gcsClient) objectURL(name string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gc.endpoint, url.PathEscape(gc.bucket), url.PathEscape(name))
+}
+
+// do performs API request, decoding JSON response into result (if not nil)
+func (gc *gcsClient) do(req *http.Request, result interface{}) error {
+	req.Header.Set("User-Agent", "aptly/"+aptly.Version)
+
+	if gc.tokens != nil {
+		token, err := gc.tokens.Token()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResponse struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(body, &errResponse) != nil || errResponse.Error.Message == "" {
+			errResponse.Error.Message = strings.TrimSpace(string(body))
+		}
+
+		return &apiError{Code: resp.StatusCode, Message: errResponse.Error.Message}
+	}
+
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// upload uploads object with metadata using multipart upload
+func (gc *gcsClient) upload(object *gcsObject, source io.Reader, predefinedACL string) error {
+	query := url.Values{"uploadType": {"multipart"}}
+	if predefinedACL != "" {
+		query.Set("predefinedAcl", predefinedACL)
+	}
+
+	meta, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+		if err == nil {
+			_, err = w.Write(meta)
+		}
+		if err == nil {
+			w, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+		}
+		if err == nil {
+			_, err = io.Copy(w, source)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", gc.endpoint, url.PathEscape(gc.bucket), query.Encode()), pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+
+	err = gc.do(req, nil)
+	// make sure writer goroutine terminates if request failed before body was consumed
+	pr.CloseWithError(io.ErrClosedPipe)
+	return err
+}
+
+// get fetches object metadata
+func (gc *gcsClient) get(name string) (*gcsObject, error) {
+	req, err := http.NewRequest("GET", gc.objectURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &gcsObject{}
+	err = gc.do(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// remove deletes object
+func (gc *gcsClient) remove(name string) error {
+	req, err := http.NewRequest("DELETE", gc.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+
+	return gc.do(req, nil)
+}
+
+// list returns all objects under prefix
+func (gc *gcsClient) list(prefix string) ([]gcsObject, error) {
+	result := []gcsObject{}
+	pageToken := ""
+
+	for {
+		query := url.Values{
+			"prefix": {prefix},
+			"fields": {"items(name,md5Hash),nextPageToken"},
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/storage/v1/b/%s/o?%s", gc.endpoint, url.PathEscape(gc.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items         []gcsObject `json:"items"`
+			NextPageToken string      `json:"nextPageToken"`
+		}
+
+		err = gc.do(req, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page.Items...)
+
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// rewrite copies object src to dst, if object is not nil, it replaces metadata of the copy
+func (gc *gcsClient) rewrite(src, dst string, object *gcsObject, predefinedACL string) error {
+	var body []byte
+	if object != nil {
+		var err error
+		body, err = json.Marshal(object)
+		if err != nil {
+			return err
+		}
+	}
+
+	rewriteToken := ""
+
+	for {
+		query := url.Values{}
+		if predefinedACL != "" {
+			query.Set("destinationPredefinedAcl", predefinedACL)
+		}
+		if rewriteToken != "" {
+			query.Set("rewriteToken", rewriteToken)
+		}
+
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/rewriteTo/b/%s/o/%s?%s", gc.objectURL(src), url.PathEscape(gc.bucket),
+			url.PathEscape(dst), query.Encode()), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		var status struct {
+			Done         bool   `json:"done"`
+			RewriteToken string `json:"rewriteToken"`
+		}
+
+		err = gc.do(req, &status)
+		if err != nil {
+			return err
+		}
+
+		if status.Done {
+			return nil
+		}
+		rewriteToken = status.RewriteToken
+	}
+}
@@ -0,0 +1,308 @@
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type TokenSourceSuite struct {
+	key *rsa.PrivateKey
+	env map[string]string
+}
+
+var _ = Suite(&TokenSourceSuite{})
+
+func (s *TokenSourceSuite) SetUpSuite(c *C) {
+	var err error
+	s.key, err = rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+}
+
+func (s *TokenSourceSuite) SetUpTest(c *C) {
+	s.env = map[string]string{}
+	for _, name := range []string{"GOOGLE_APPLICATION_CREDENTIALS", "GCE_METADATA_HOST"} {
+		s.env[name] = os.Getenv(name)
+	}
+}
+
+func (s *TokenSourceSuite) TearDownTest(c *C) {
+	for name, value := range s.env {
+		os.Setenv(name, value)
+	}
+}
+
+// serviceAccount builds service account key in the format of key files created by Google Cloud
+func (s *TokenSourceSuite) serviceAccount(c *C, tokenURI string) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(s.key)
+	c.Assert(err, IsNil)
+
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "aptly",
+		"private_key_id": "0123456789abcdef",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "aptly@example.iam.gserviceaccount.com",
+		"client_id":      "1234567890",
+		"auth_uri":       "https://accounts.google.com/o/oauth2/auth",
+		"token_uri":      tokenURI,
+	})
+
+	return credentials
+}
+
+// tokenEndpoint emulates OAuth2 token endpoint: JWT assertion is verified the way Google
+// does it, expired or wrongly signed assertions are rejected with OAuth2 error response
+func (s *TokenSourceSuite) tokenEndpoint(c *C, requests *int) *httptest.Server {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		fail := func(code, description string) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+		}
+
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			fail("invalid_request", "Bad Request")
+			return
+		}
+
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			fail("unsupported_grant_type", "Invalid grant_type: "+r.FormValue("grant_type"))
+			return
+		}
+
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			fail("invalid_grant", "Invalid JWT")
+			return
+		}
+
+		var header map[string]string
+		data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		if json.Unmarshal(data, &header) != nil || header["alg"] != "RS256" {
+			fail("invalid_grant", "Invalid JWT header")
+			return
+		}
+
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			fail("invalid_grant", "Invalid JWT Signature.")
+			return
+		}
+
+		var claims struct {
+			Iss   string
+			Scope string
+			Aud   string
+			Iat   int64
+			Exp   int64
+		}
+		data, _ = base64.RawURLEncoding.DecodeString(parts[1])
+		if json.Unmarshal(data, &claims) != nil {
+			fail("invalid_grant", "Invalid JWT claims")
+			return
+		}
+
+		now := time.Now().Unix()
+		if claims.Iss != "aptly@example.iam.gserviceaccount.com" || claims.Aud != srv.URL || claims.Scope == "" {
+			fail("invalid_grant", "Invalid JWT claims")
+			return
+		}
+		if claims.Iat > now+60 || claims.Exp < now || claims.Exp-claims.Iat > 3600 {
+			fail("invalid_grant", "Invalid JWT: Token must be a short-lived token (60 minutes) and in a reasonable timeframe.")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d:%s", *requests, claims.Scope),
+			"expires_in":   3599,
+			"token_type":   "Bearer",
+		})
+	}))
+
+	return srv
+}
+
+func (s *TokenSourceSuite) TestServiceAccount(c *C) {
+	requests := 0
+	srv := s.tokenEndpoint(c, &requests)
+	defer srv.Close()
+
+	tokens, err := newServiceAccountTokenSource(srv.Client(), s.serviceAccount(c, srv.URL), readWriteScope)
+	c.Assert(err, IsNil)
+
+	token, err := tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token1:"+readWriteScope)
+
+	// token is cached
+	token, err = tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token1:"+readWriteScope)
+	c.Check(requests, Equals, 1)
+	c.Check(tokens.expiry.After(time.Now().Add(50*time.Minute)), Equals, true)
+
+	// expired token is refreshed
+	tokens.expiry = time.Now().Add(-time.Second)
+	token, err = tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token2:"+readWriteScope)
+	c.Check(requests, Equals, 2)
+}
+
+func (s *TokenSourceSuite) TestServiceAccountRejected(c *C) {
+	requests := 0
+	srv := s.tokenEndpoint(c, &requests)
+	defer srv.Close()
+
+	tokens, err := newServiceAccountTokenSource(srv.Client(), s.serviceAccount(c, srv.URL), readWriteScope)
+	c.Assert(err, IsNil)
+
+	// key which doesn't match the one registered for the service account
+	tokens.key, err = rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	_, err = tokens.Token()
+	c.Check(err, ErrorMatches, "unable to fetch access token: invalid_grant: Invalid JWT Signature.")
+
+	// failed request is not cached
+	_, err = tokens.Token()
+	c.Check(err, NotNil)
+	c.Check(requests, Equals, 2)
+}
+
+func (s *TokenSourceSuite) TestServiceAccountErrors(c *C) {
+	_, err := newServiceAccountTokenSource(nil, []byte("{"), readWriteScope)
+	c.Check(err, ErrorMatches, "unable to parse credentials: .*")
+
+	_, err = newServiceAccountTokenSource(nil, []byte(`{"type": "authorized_user"}`), readWriteScope)
+	c.Check(err, ErrorMatches, "unsupported credentials type \"authorized_user\", service account key is required")
+
+	_, err = newServiceAccountTokenSource(nil, []byte(`{"type": "service_account", "private_key": "garbage"}`), readWriteScope)
+	c.Check(err, ErrorMatches, "unable to parse credentials: private key is not PEM encoded")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("upstream unavailable\n"))
+	}))
+	defer srv.Close()
+
+	tokens, err := newServiceAccountTokenSource(srv.Client(), s.serviceAccount(c, srv.URL), readWriteScope)
+	c.Assert(err, IsNil)
+
+	_, err = tokens.Token()
+	c.Check(err, ErrorMatches, "unable to fetch access token: 502 Bad Gateway: upstream unavailable")
+}
+
+func (s *TokenSourceSuite) TestNewTokenSource(c *C) {
+	requests := 0
+	srv := s.tokenEndpoint(c, &requests)
+	defer srv.Close()
+
+	credentialsFile := filepath.Join(c.MkDir(), "key.json")
+	c.Assert(os.WriteFile(credentialsFile, s.serviceAccount(c, srv.URL), 0600), IsNil)
+
+	// object ACLs could be set only with full control scope
+	tokens, err := newTokenSource(srv.Client(), credentialsFile, "publicRead")
+	c.Assert(err, IsNil)
+	token, err := tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token1:"+fullControlScope)
+
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credentialsFile)
+	tokens, err = newTokenSource(srv.Client(), "", "")
+	c.Assert(err, IsNil)
+	token, err = tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token2:"+readWriteScope)
+
+	_, err = newTokenSource(srv.Client(), filepath.Join(c.MkDir(), "missing.json"), "")
+	c.Check(err, ErrorMatches, "unable to read credentials: .*")
+
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	os.Setenv("GCE_METADATA_HOST", "169.254.169.254:8080")
+	tokens, err = newTokenSource(srv.Client(), "", "")
+	c.Assert(err, IsNil)
+	c.Check(tokens.(*metadataTokenSource).url, Equals, "http://169.254.169.254:8080/computeMetadata/v1/instance/service-accounts/default/token")
+}
+
+func (s *TokenSourceSuite) TestMetadata(c *C) {
+	requests := 0
+
+	// metadata server requires Metadata-Flavor header to protect from SSRF
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Missing required header \"Metadata-Flavor\": \"Google\"\n"))
+			return
+		}
+
+		if r.URL.Path != metadataTokenPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", requests),
+			"expires_in":   3599,
+			"token_type":   "Bearer",
+		})
+	}))
+	defer srv.Close()
+
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	tokens, err := newTokenSource(srv.Client(), "", "")
+	c.Assert(err, IsNil)
+
+	token, err := tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token1")
+
+	token, err = tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token1")
+	c.Check(requests, Equals, 1)
+
+	tokens.(*metadataTokenSource).expiry = time.Now().Add(-time.Second)
+	token, err = tokens.Token()
+	c.Check(err, IsNil)
+	c.Check(token, Equals, "token2")
+
+	// metadata server is not available
+	tokens.(*metadataTokenSource).token = ""
+	tokens.(*metadataTokenSource).url = srv.URL + "/computeMetadata/v1/instance/service-accounts/missing/token"
+	_, err = tokens.Token()
+	c.Check(err, ErrorMatches, "unable to fetch access token: 404 Not Found: ")
+}
@@ -0,0 +1,337 @@
+package gcs
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultIndexCacheControl is Cache-Control for index files, which change on every publish
+	DefaultIndexCacheControl = "no-cache, max-age=0"
+	// DefaultPoolCacheControl is Cache-Control for package files, which almost never change
+	DefaultPoolCacheControl = "public, max-age=86400"
+)
+
+// PublishedStorage abstract file system with published files (actually hosted on GCS)
+type PublishedStorage struct {
+	gc                *gcsClient
+	prefix            string
+	acl               string
+	indexCacheControl string
+	poolCacheControl  string
+	pathCache         map[string]string
+}
+
+// Check interface
+var (
+	_ aptly.PublishedStorage         = (*PublishedStorage)(nil)
+	_ aptly.ChecksumPublishedStorage = (*PublishedStorage)(nil)
+)
+
+// NewPublishedStorage creates new instance of PublishedStorage with specified service account
+// credentials and bucket name
+//
+// With uniformBucketLevelAccess, object ACLs are not set (access is controlled
+// by bucket IAM policy), otherwise acl is applied as predefined ACL (e.g. publicRead)
+func NewPublishedStorage(bucket, prefix, credentialsFile, acl string, uniformBucketLevelAccess bool,
+	indexCacheControl, poolCacheControl, endpoint string) (*PublishedStorage, error) {
+	client := &http.Client{}
+
+	tokens, err := newTokenSource(client, credentialsFile, acl)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPublishedStorage(client, tokens, bucket, prefix, acl, uniformBucketLevelAccess, indexCacheControl, poolCacheControl, endpoint)
+}
+
+func newPublishedStorage(client *http.Client, tokens tokenSource, bucket, prefix, acl string, uniformBucketLevelAccess bool,
+	indexCacheControl, poolCacheControl, endpoint string) (*PublishedStorage, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("GCS bucket is not specified")
+	}
+
+	if uniformBucketLevelAccess && acl != "" {
+		return nil, fmt.Errorf("ACL %q can't be used with uniform bucket-level access", acl)
+	}
+
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if indexCacheControl == "" {
+		indexCacheControl = DefaultIndexCacheControl
+	}
+	if poolCacheControl == "" {
+		poolCacheControl = DefaultPoolCacheControl
+	}
+
+	return &PublishedStorage{
+		gc: &gcsClient{
+			client:   client,
+			tokens:   tokens,
+			endpoint: strings.TrimSuffix(endpoint, "/"),
+			bucket:   bucket,
+		},
+		prefix:            prefix,
+		acl:               acl,
+		indexCacheControl: indexCacheControl,
+		poolCacheControl:  poolCacheControl,
+	}, nil
+}
+
+// String
+func (storage *PublishedStorage) String() string {
+	return fmt.Sprintf("GCS: %s/%s", storage.gc.bucket, storage.prefix)
+}
+
+func (storage *PublishedStorage) objectName(path string) string {
+	return filepath.Join(storage.prefix, path)
+}
+
+// MkDir creates directory recursively under public path
+func (storage *PublishedStorage) MkDir(_ string) error {
+	// no op for GCS
+	return nil
+}
+
+// PutFile puts file into published storage at specified path
+func (storage *PublishedStorage) PutFile(path string, sourceFilename string) error {
+	sourceMD5, err := utils.MD5ChecksumForFile(sourceFilename)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(sourceFilename)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	err = storage.putFile(path, source, sourceMD5, storage.indexCacheControl)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("error uploading %s to %s", sourceFilename, storage))
+	}
+
+	return err
+}
+
+// putFile uploads file-like object, sourceMD5 (if set) is verified by GCS
+func (storage *PublishedStorage) putFile(path string, source io.Reader, sourceMD5, cacheControl string) error {
+	object := &gcsObject{
+		Name:         storage.objectName(path),
+		CacheControl: cacheControl,
+		ContentType:  "application/octet-stream",
+	}
+
+	if sourceMD5 != "" {
+		decoded, err := hex.DecodeString(sourceMD5)
+		if err != nil {
+			return err
+		}
+		object.MD5Hash = base64.StdEncoding.EncodeToString(decoded)
+	}
+
+	return storage.gc.upload(object, source, storage.acl)
+}
+
+// Remove removes single file under public path
+func (storage *PublishedStorage) Remove(path string) error {
+	err := storage.gc.remove(storage.objectName(path))
+	if err != nil && !isNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("error deleting %s from %s", path, storage))
+	}
+
+	delete(storage.pathCache, path)
+
+	return nil
+}
+
+// RemoveDirs removes directory structure under public path
+func (storage *PublishedStorage) RemoveDirs(path string, _ aptly.Progress) error {
+	filelist, _, err := storage.internalFilelist(path)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filelist {
+		err = storage.gc.remove(storage.objectName(filepath.Join(path, filename)))
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("error deleting path %s from %s: %s", filename, storage, err)
+		}
+		delete(storage.pathCache, filepath.Join(path, filename))
+	}
+
+	return nil
+}
+
+// LinkFromPool links package file from pool to dist's pool location
+//
+// publishedPrefix is desired prefix for the location in the pool.
+// publishedRelPath is desired location in pool (like pool/component/liba/libav/)
+// sourcePool is instance of aptly.PackagePool
+// sourcePath is filepath to package file in package pool
+//
+// LinkFromPool returns relative path for the published file to be included in package index
+func (storage *PublishedStorage) LinkFromPool(publishedPrefix, publishedRelPath, fileName string, sourcePool aptly.PackagePool,
+	sourcePath string, sourceChecksums utils.ChecksumInfo, force bool) error {
+
+	relPath := filepath.Join(publishedPrefix, publishedRelPath, fileName)
+	poolPath := storage.objectName(relPath)
+
+	if storage.pathCache == nil {
+		paths, md5s, err := storage.internalFilelist(filepath.Join(publishedPrefix, "pool"))
+		if err != nil {
+			return errors.Wrap(err, "error caching paths under prefix")
+		}
+
+		storage.pathCache = make(map[string]string, len(paths))
+
+		for i := range paths {
+			storage.pathCache[filepath.Join(publishedPrefix, "pool", paths[i])] = md5s[i]
+		}
+	}
+
+	destinationMD5, exists := storage.pathCache[relPath]
+	sourceMD5 := sourceChecksums.MD5
+
+	if exists {
+		if sourceMD5 == "" {
+			return fmt.Errorf("unable to compare object, MD5 checksum missing")
+		}
+
+		if destinationMD5 == sourceMD5 {
+			return nil
+		}
+
+		if !force {
+			return fmt.Errorf("error putting file to %s: file already exists and is different: %s", poolPath, storage)
+		}
+	}
+
+	source, err := sourcePool.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	err = storage.putFile(relPath, source, sourceMD5, storage.poolCacheControl)
+	if err == nil {
+		storage.pathCache[relPath] = sourceMD5
+	} else {
+		err = errors.Wrap(err, fmt.Sprintf("error uploading %s to %s: %s", sourcePath, storage, poolPath))
+	}
+
+	return err
+}
+
+// Filelist returns list of files under prefix
+func (storage *PublishedStorage) Filelist(prefix string) ([]string, error) {
+	paths, _, err := storage.internalFilelist(prefix)
+	return paths, err
+}
+
+// FileMD5s returns MD5 checksums of files under prefix
+func (storage *PublishedStorage) FileMD5s(prefix string) (map[string]string, error) {
+	paths, md5s, err := storage.internalFilelist(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(paths))
+	for i := range paths {
+		if md5s[i] != "" {
+			result[paths[i]] = md5s[i]
+		}
+	}
+
+	return result, nil
+}
+
+func (storage *PublishedStorage) internalFilelist(prefix string) (paths []string, md5s []string, err error) {
+	prefix = storage.objectName(prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	objects, err := storage.gc.list(prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing under prefix %s in %s: %s", prefix, storage, err)
+	}
+
+	paths = make([]string, 0, len(objects))
+	md5s = make([]string, 0, len(objects))
+
+	for i := range objects {
+		paths = append(paths, objects[i].Name[len(prefix):])
+		md5s = append(md5s, objects[i].md5())
+	}
+
+	return paths, md5s, nil
+}
+
+// RenameFile renames (moves) file
+func (storage *PublishedStorage) RenameFile(oldName, newName string) error {
+	err := storage.gc.rewrite(storage.objectName(oldName), storage.objectName(newName), nil, storage.acl)
+	if err != nil {
+		return fmt.Errorf("error copying %s -> %s in %s: %s", oldName, newName, storage, err)
+	}
+
+	return storage.Remove(oldName)
+}
+
+// SymLink creates a copy of src file and adds link information as meta data
+func (storage *PublishedStorage) SymLink(src string, dst string) error {
+	object := &gcsObject{
+		CacheControl: storage.indexCacheControl,
+		ContentType:  "application/octet-stream",
+		Metadata: map[string]string{
+			"SymLink": src,
+		},
+	}
+
+	err := storage.gc.rewrite(storage.objectName(src), storage.objectName(dst), object, storage.acl)
+	if err != nil {
+		return fmt.Errorf("error symlinking %s -> %s in %s: %s", src, dst, storage, err)
+	}
+
+	return nil
+}
+
+// HardLink using symlink functionality as hard links do not exist
+func (storage *PublishedStorage) HardLink(src string, dst string) error {
+	return storage.SymLink(src, dst)
+}
+
+// FileExists returns true if path exists
+func (storage *PublishedStorage) FileExists(path string) (bool, error) {
+	_, err := storage.gc.get(storage.objectName(path))
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadLink returns the symbolic link pointed to by path.
+// This simply reads text file created with SymLink
+func (storage *PublishedStorage) ReadLink(path string) (string, error) {
+	object, err := storage.gc.get(storage.objectName(path))
+	if err != nil {
+		return "", err
+	}
+
+	return object.Metadata["SymLink"], nil
+}
@@ -0,0 +1,262 @@
+package gcs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type staticTokenSource string
+
+func (token staticTokenSource) Token() (string, error) {
+	return string(token), nil
+}
+
+type PublishedStorageSuite struct {
+	srv                      *fakeServer
+	storage, prefixedStorage *PublishedStorage
+}
+
+var _ = Suite(&PublishedStorageSuite{})
+
+func (s *PublishedStorageSuite) SetUpTest(c *C) {
+	var err error
+
+	s.srv = newFakeServer("test")
+
+	s.storage, err = newPublishedStorage(s.srv.Client(), staticTokenSource("secret"), "test", "", "publicRead", false, "", "", s.srv.URL)
+	c.Assert(err, IsNil)
+	s.prefixedStorage, err = newPublishedStorage(s.srv.Client(), staticTokenSource("secret"), "test", "lala", "", true, "no-store", "max-age=60", s.srv.URL)
+	c.Assert(err, IsNil)
+}
+
+func (s *PublishedStorageSuite) TearDownTest(c *C) {
+	s.srv.Close()
+}
+
+func (s *PublishedStorageSuite) GetFile(c *C, path string) *fakeObject {
+	object := s.srv.objects[path]
+	c.Assert(object, NotNil)
+	return object
+}
+
+func (s *PublishedStorageSuite) PutFile(c *C, path string, data []byte) {
+	filename := filepath.Join(c.MkDir(), "a")
+	c.Assert(os.WriteFile(filename, data, 0644), IsNil)
+	c.Assert(s.storage.PutFile(path, filename), IsNil)
+}
+
+func (s *PublishedStorageSuite) TestNewPublishedStorage(c *C) {
+	_, err := newPublishedStorage(nil, nil, "", "", "", false, "", "", "")
+	c.Check(err, ErrorMatches, "GCS bucket is not specified")
+
+	_, err = newPublishedStorage(nil, nil, "test", "", "publicRead", true, "", "", "")
+	c.Check(err, ErrorMatches, "ACL \"publicRead\" can't be used with uniform bucket-level access")
+
+	storage, err := newPublishedStorage(nil, nil, "test", "lala", "", false, "", "", "")
+	c.Assert(err, IsNil)
+	c.Check(storage.gc.endpoint, Equals, "https://storage.googleapis.com")
+	c.Check(storage.indexCacheControl, Equals, DefaultIndexCacheControl)
+	c.Check(storage.poolCacheControl, Equals, DefaultPoolCacheControl)
+	c.Check(storage.String(), Equals, "GCS: test/lala")
+}
+
+func (s *PublishedStorageSuite) TestPutFile(c *C) {
+	s.PutFile(c, "dists/squeeze/Release", []byte("Welcome to GCS!"))
+
+	object := s.GetFile(c, "dists/squeeze/Release")
+	c.Check(string(object.data), Equals, "Welcome to GCS!")
+	c.Check(object.CacheControl, Equals, DefaultIndexCacheControl)
+	c.Check(object.acl, Equals, "publicRead")
+	c.Check(s.srv.tokens[0], Equals, "Bearer secret")
+
+	filename := filepath.Join(c.MkDir(), "a")
+	c.Assert(os.WriteFile(filename, []byte("Welcome to GCS!"), 0644), IsNil)
+	c.Assert(s.prefixedStorage.PutFile("dists/squeeze/Release", filename), IsNil)
+
+	object = s.GetFile(c, "lala/dists/squeeze/Release")
+	c.Check(object.CacheControl, Equals, "no-store")
+	c.Check(object.acl, Equals, "")
+}
+
+func (s *PublishedStorageSuite) TestFilelist(c *C) {
+	paths := []string{"a", "b", "c", "testa", "test/a", "test/b", "lala/a", "lala/b", "lala/c"}
+	for _, path := range paths {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	list, err := s.storage.Filelist("")
+	c.Check(err, IsNil)
+	sort.Strings(list)
+	c.Check(list, DeepEquals, []string{"a", "b", "c", "lala/a", "lala/b", "lala/c", "test/a", "test/b", "testa"})
+
+	list, err = s.storage.Filelist("test")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b"})
+
+	list, err = s.storage.Filelist("test2")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{})
+
+	list, err = s.prefixedStorage.Filelist("")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b", "c"})
+}
+
+func (s *PublishedStorageSuite) TestFileMD5s(c *C) {
+	for _, path := range []string{"a", "test/a", "test/b", "lala/test/a"} {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	md5s, err := s.storage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6", "b": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.prefixedStorage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.storage.FileMD5s("test2")
+	c.Check(err, IsNil)
+	c.Check(md5s, HasLen, 0)
+}
+
+func (s *PublishedStorageSuite) TestRemove(c *C) {
+	s.PutFile(c, "a/b", []byte("test"))
+
+	err := s.storage.Remove("a/b")
+	c.Check(err, IsNil)
+	c.Check(s.srv.objects["a/b"], IsNil)
+
+	// removing missing file is not an error
+	err = s.storage.Remove("a/b")
+	c.Check(err, IsNil)
+}
+
+func (s *PublishedStorageSuite) TestRemoveDirs(c *C) {
+	paths := []string{"a", "b", "c", "testa", "test/a+1", "test/a 1", "lala/a", "lala/b", "lala/c"}
+	for _, path := range paths {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	err := s.storage.RemoveDirs("test", nil)
+	c.Check(err, IsNil)
+
+	list, err := s.storage.Filelist("")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b", "c", "lala/a", "lala/b", "lala/c", "testa"})
+}
+
+func (s *PublishedStorageSuite) TestRenameFile(c *C) {
+	s.PutFile(c, "dists/squeeze/Release.tmp", []byte("Welcome to GCS!"))
+
+	err := s.storage.RenameFile("dists/squeeze/Release.tmp", "dists/squeeze/Release")
+	c.Check(err, IsNil)
+
+	object := s.GetFile(c, "dists/squeeze/Release")
+	c.Check(string(object.data), Equals, "Welcome to GCS!")
+	c.Check(object.CacheControl, Equals, DefaultIndexCacheControl)
+	c.Check(object.acl, Equals, "publicRead")
+	c.Check(s.srv.objects["dists/squeeze/Release.tmp"], IsNil)
+
+	err = s.storage.RenameFile("dists/squeeze/Release.tmp", "dists/squeeze/Release")
+	c.Check(err, ErrorMatches, "error copying .* GCS API error 404: No such object.*")
+}
+
+func (s *PublishedStorageSuite) TestLinkFromPool(c *C) {
+	root := c.MkDir()
+	pool := files.NewPackagePool(root, false)
+	cs := files.NewMockChecksumStorage()
+
+	tmpFile1 := filepath.Join(c.MkDir(), "mars-invaders_1.03.deb")
+	err := os.WriteFile(tmpFile1, []byte("Contents"), 0644)
+	c.Assert(err, IsNil)
+	cksum1 := utils.ChecksumInfo{MD5: "c1df1da7a1ce305a3b60af9d5733ac1d"}
+
+	tmpFile2 := filepath.Join(c.MkDir(), "mars-invaders_1.03.deb")
+	err = os.WriteFile(tmpFile2, []byte("Spam"), 0644)
+	c.Assert(err, IsNil)
+	cksum2 := utils.ChecksumInfo{MD5: "e9dfd31cc505d51fc26975250750deab"}
+
+	src1, err := pool.Import(tmpFile1, "mars-invaders_1.03.deb", &cksum1, true, cs)
+	c.Assert(err, IsNil)
+	src2, err := pool.Import(tmpFile2, "mars-invaders_1.03.deb", &cksum2, true, cs)
+	c.Assert(err, IsNil)
+
+	// first link from pool
+	err = s.storage.LinkFromPool("", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.03.deb", pool, src1, cksum1, false)
+	c.Check(err, IsNil)
+
+	object := s.GetFile(c, "pool/main/m/mars-invaders/mars-invaders_1.03.deb")
+	c.Check(string(object.data), Equals, "Contents")
+	c.Check(object.CacheControl, Equals, DefaultPoolCacheControl)
+
+	// duplicate link from pool
+	err = s.storage.LinkFromPool("", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.03.deb", pool, src1, cksum1, false)
+	c.Check(err, IsNil)
+
+	// link from pool with conflict
+	err = s.storage.LinkFromPool("", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.03.deb", pool, src2, cksum2, false)
+	c.Check(err, ErrorMatches, ".*file already exists and is different.*")
+
+	c.Check(string(s.GetFile(c, "pool/main/m/mars-invaders/mars-invaders_1.03.deb").data), Equals, "Contents")
+
+	// link from pool with conflict and force
+	err = s.storage.LinkFromPool("", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.03.deb", pool, src2, cksum2, true)
+	c.Check(err, IsNil)
+
+	c.Check(string(s.GetFile(c, "pool/main/m/mars-invaders/mars-invaders_1.03.deb").data), Equals, "Spam")
+
+	// for prefixed storage, with file already uploaded: path cache is populated from listing
+	s.srv.objects["lala/ppa/pool/main/m/mars-invaders/mars-invaders_1.03.deb"] = &fakeObject{
+		gcsObject: gcsObject{Name: "lala/ppa/pool/main/m/mars-invaders/mars-invaders_1.03.deb", MD5Hash: "wd8dp6HOMFo7YK+dVzOsHQ=="},
+	}
+
+	// wrong source path: upload should be skipped
+	err = s.prefixedStorage.LinkFromPool("ppa", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.03.deb", pool, "wrong-looks-like-pathcache-doesnt-work", cksum1, false)
+	c.Check(err, IsNil)
+
+	// checksum mismatch with wrong MD5 is reported by the server
+	err = s.prefixedStorage.LinkFromPool("ppa", filepath.Join("pool", "main", "m/mars-invaders"), "mars-invaders_1.04.deb", pool, src1, cksum2, false)
+	c.Check(err, ErrorMatches, ".*Provided MD5 hash doesn't match calculated MD5 hash.*")
+}
+
+func (s *PublishedStorageSuite) TestSymLink(c *C) {
+	s.PutFile(c, "a/b", []byte("test"))
+
+	err := s.storage.SymLink("a/b", "a/b.link")
+	c.Check(err, IsNil)
+
+	link, err := s.storage.ReadLink("a/b.link")
+	c.Check(err, IsNil)
+	c.Check(link, Equals, "a/b")
+
+	object := s.GetFile(c, "a/b.link")
+	c.Check(string(object.data), Equals, "test")
+	c.Check(object.CacheControl, Equals, DefaultIndexCacheControl)
+
+	link, err = s.storage.ReadLink("a/b")
+	c.Check(err, IsNil)
+	c.Check(link, Equals, "")
+}
+
+func (s *PublishedStorageSuite) TestFileExists(c *C) {
+	s.PutFile(c, "a/b", []byte("test"))
+
+	exists, err := s.storage.FileExists("a/b")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, true)
+
+	exists, err = s.storage.FileExists("a/b.invalid")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, false)
+
+	exists, err = s.prefixedStorage.FileExists("a/b")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, false)
+}
@@ -0,0 +1,207 @@
+package gcs
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeObject is object stored in fake GCS server
+type fakeObject struct {
+	gcsObject
+	data []byte
+	acl  string
+}
+
+// fakeServer is minimal implementation of GCS JSON API for testing
+type fakeServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	bucket   string
+	objects  map[string]*fakeObject
+	pageSize int
+	tokens   []string
+}
+
+func newFakeServer(bucket string) *fakeServer {
+	srv := &fakeServer{
+		bucket:   bucket,
+		objects:  map[string]*fakeObject{},
+		pageSize: 2,
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serveHTTP))
+
+	return srv
+}
+
+func (srv *fakeServer) fail(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": code, "message": message}})
+}
+
+func (srv *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.Lock()
+	defer srv.Unlock()
+
+	srv.tokens = append(srv.tokens, r.Header.Get("Authorization"))
+
+	parts := strings.Split(r.URL.EscapedPath(), "/")
+	for i := range parts {
+		parts[i], _ = url.PathUnescape(parts[i])
+	}
+
+	switch {
+	case r.Method == "POST" && len(parts) == 7 && parts[1] == "upload":
+		// /upload/storage/v1/b/<bucket>/o
+		srv.upload(w, r, parts[5])
+	case len(parts) == 6 && parts[4] == srv.bucket && r.Method == "GET":
+		// /storage/v1/b/<bucket>/o
+		srv.list(w, r)
+	case len(parts) == 7 && parts[4] == srv.bucket:
+		// /storage/v1/b/<bucket>/o/<object>
+		object := srv.objects[parts[6]]
+		if object == nil {
+			srv.fail(w, http.StatusNotFound, "No such object: "+parts[6])
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(object.gcsObject)
+		case "DELETE":
+			delete(srv.objects, parts[6])
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			srv.fail(w, http.StatusMethodNotAllowed, "unsupported method")
+		}
+	case r.Method == "POST" && len(parts) == 12 && parts[7] == "rewriteTo":
+		// /storage/v1/b/<bucket>/o/<src>/rewriteTo/b/<bucket>/o/<dst>
+		srv.rewrite(w, r, parts[6], parts[11])
+	default:
+		srv.fail(w, http.StatusNotFound, "unsupported request "+r.URL.Path)
+	}
+}
+
+func (srv *fakeServer) upload(w http.ResponseWriter, r *http.Request, bucket string) {
+	if bucket != srv.bucket {
+		srv.fail(w, http.StatusNotFound, "No such bucket")
+		return
+	}
+
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		srv.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mr := multipart.NewReader(r.Body, params["boundary"])
+
+	part, err := mr.NextPart()
+	if err != nil {
+		srv.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	object := &fakeObject{acl: r.URL.Query().Get("predefinedAcl")}
+	err = json.NewDecoder(part).Decode(&object.gcsObject)
+	if err != nil {
+		srv.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	part, err = mr.NextPart()
+	if err != nil {
+		srv.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	object.data, err = io.ReadAll(part)
+	if err != nil {
+		srv.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sum := md5.Sum(object.data)
+	md5Hash := base64.StdEncoding.EncodeToString(sum[:])
+	if object.MD5Hash != "" && object.MD5Hash != md5Hash {
+		srv.fail(w, http.StatusBadRequest, "Provided MD5 hash doesn't match calculated MD5 hash")
+		return
+	}
+	object.MD5Hash = md5Hash
+
+	srv.objects[object.Name] = object
+	json.NewEncoder(w).Encode(object.gcsObject)
+}
+
+func (srv *fakeServer) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	names := []string{}
+	for name := range srv.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	end := start + srv.pageSize
+
+	result := map[string]interface{}{}
+	if end < len(names) {
+		result["nextPageToken"] = strconv.Itoa(end)
+	} else {
+		end = len(names)
+	}
+
+	items := []gcsObject{}
+	for _, name := range names[start:end] {
+		items = append(items, gcsObject{Name: name, MD5Hash: srv.objects[name].MD5Hash})
+	}
+	result["items"] = items
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func (srv *fakeServer) rewrite(w http.ResponseWriter, r *http.Request, src, dst string) {
+	source := srv.objects[src]
+	if source == nil {
+		srv.fail(w, http.StatusNotFound, "No such object: "+src)
+		return
+	}
+
+	// first request returns rewrite token to check that client continues rewrite
+	if r.URL.Query().Get("rewriteToken") == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"done": false, "rewriteToken": "token"})
+		return
+	}
+
+	object := &fakeObject{gcsObject: source.gcsObject, data: source.data, acl: r.URL.Query().Get("destinationPredefinedAcl")}
+	object.Name = dst
+
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > 0 {
+		var meta gcsObject
+		err := json.Unmarshal(body, &meta)
+		if err != nil {
+			srv.fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		object.CacheControl = meta.CacheControl
+		object.Metadata = meta.Metadata
+	}
+
+	srv.objects[dst] = object
+	json.NewEncoder(w).Encode(map[string]interface{}{"done": true})
+}
@@ -0,0 +1,282 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/pkg/errors"
+)
+
+// configureTransport applies proxy and client TLS settings to transport
+func configureTransport(transport *http.Transport, options aptly.DownloaderOptions) error {
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse proxy URL %s", options.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.TLSClientCert == "" && options.TLSClientKey == "" && options.TLSCACert == "" {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if options.TLSClientCert != "" || options.TLSClientKey != "" {
+		if options.TLSClientCert == "" || options.TLSClientKey == "" {
+			return fmt.Errorf("both client TLS certificate and key should be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(options.TLSClientCert, options.TLSClientKey)
+		if err != nil {
+			return errors.Wrap(err, "unable to load client TLS certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if options.TLSCACert != "" {
+		pem, err := os.ReadFile(options.TLSCACert)
+		if err != nil {
+			return errors.Wrap(err, "unable to read CA certificates")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no CA certificates found in %s", options.TLSCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return nil
+}
+
+// authTransport answers HTTP basic and digest authentication challenges
+type authTransport struct {
+	base               http.RoundTripper
+	username, password string
+
+	mu     sync.Mutex
+	basic  bool
+	digest *digestChallenge
+	nc     int
+}
+
+func newAuthTransport(base http.RoundTripper, options aptly.DownloaderOptions) http.RoundTripper {
+	if options.Username == "" {
+		return base
+	}
+
+	return &authTransport{base: base, username: options.Username, password: options.Password}
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	authorized, scheme := t.authorize(req)
+	resp, err := t.base.RoundTrip(authorized)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	if !t.learn(resp.Header.Values("WWW-Authenticate"), scheme) {
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, resp.Body) // nolint: errcheck
+	resp.Body.Close()
+
+	authorized, _ = t.authorize(req)
+	return t.base.RoundTrip(authorized)
+}
+
+// authorize returns copy of request with credentials attached (if the scheme is already known)
+func (t *authTransport) authorize(req *http.Request) (*http.Request, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case t.digest != nil:
+		t.nc++
+		result := req.Clone(req.Context())
+		result.Header.Set("Authorization", t.digest.authorization(t.username, t.password, req.Method, req.URL.RequestURI(), t.nc))
+		return result, "digest"
+	case t.basic:
+		result := req.Clone(req.Context())
+		result.SetBasicAuth(t.username, t.password)
+		return result, "basic"
+	}
+
+	return req, ""
+}
+
+// learn processes server challenges and returns true if request should be retried
+func (t *authTransport) learn(challenges []string, usedScheme string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, challenge := range challenges {
+		scheme, params := parseChallenge(challenge)
+
+		switch scheme {
+		case "digest":
+			if usedScheme == "digest" && !strings.EqualFold(params["stale"], "true") {
+				// credentials were rejected
+				return false
+			}
+
+			digest, err := newDigestChallenge(params)
+			if err != nil {
+				continue
+			}
+
+			t.digest, t.nc = digest, 0
+			return true
+		case "basic":
+			if usedScheme != "" {
+				return false
+			}
+
+			t.basic = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseChallenge parses WWW-Authenticate header into scheme and parameters
+func parseChallenge(challenge string) (string, map[string]string) {
+	challenge = strings.TrimSpace(challenge)
+
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var key, value string
+
+		rest = strings.TrimLeft(rest, " ,")
+		key, rest, _ = strings.Cut(rest, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end == -1 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+
+		if key != "" {
+			params[key] = value
+		}
+	}
+
+	return strings.ToLower(scheme), params
+}
+
+// digestChallenge is parsed digest authentication challenge (RFC 7616)
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm string
+	qop                             bool
+	newHash                         func() hash.Hash
+}
+
+func newDigestChallenge(params map[string]string) (*digestChallenge, error) {
+	result := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+
+	switch strings.ToUpper(result.algorithm) {
+	case "", "MD5":
+		result.newHash = md5.New
+	case "SHA-256":
+		result.newHash = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %s", result.algorithm)
+	}
+
+	if qop, ok := params["qop"]; ok {
+		for _, option := range strings.Split(qop, ",") {
+			if strings.TrimSpace(option) == "auth" {
+				result.qop = true
+			}
+		}
+
+		if !result.qop {
+			return nil, fmt.Errorf("unsupported digest qop %s", qop)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *digestChallenge) hash(parts ...string) string {
+	h := d.newHash()
+	io.WriteString(h, strings.Join(parts, ":")) // nolint: errcheck
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (d *digestChallenge) authorization(username, password, method, uri string, nc int) string {
+	ha1 := d.hash(username, d.realm, password)
+	ha2 := d.hash(method, uri)
+
+	fields := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", d.realm),
+		fmt.Sprintf("nonce=%q", d.nonce),
+		fmt.Sprintf("uri=%q", uri),
+	}
+
+	if d.qop {
+		cnonceBytes := make([]byte, 8)
+		rand.Read(cnonceBytes) // nolint: errcheck
+		cnonce := hex.EncodeToString(cnonceBytes)
+		ncValue := fmt.Sprintf("%08x", nc)
+
+		fields = append(fields,
+			"qop=auth",
+			"nc="+ncValue,
+			fmt.Sprintf("cnonce=%q", cnonce),
+			fmt.Sprintf("response=%q", d.hash(ha1, d.nonce, ncValue, cnonce, "auth", ha2)))
+	} else {
+		fields = append(fields, fmt.Sprintf("response=%q", d.hash(ha1, d.nonce, ha2)))
+	}
+
+	if d.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", d.opaque))
+	}
+	if d.algorithm != "" {
+		fields = append(fields, "algorithm="+d.algorithm)
+	}
+
+	return "Digest " + strings.Join(fields, ", ")
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+
+	. "gopkg.in/check.v1"
+)
+
+type AuthSuite struct {
+	server *httptest.Server
+	dest   string
+}
+
+var _ = Suite(&AuthSuite{})
+
+func (s *AuthSuite) SetUpTest(c *C) {
+	s.dest = filepath.Join(c.MkDir(), "file")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/basic", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="aptly"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "basic")
+	})
+	mux.HandleFunc("/digest", func(w http.ResponseWriter, r *http.Request) {
+		_, params := parseChallenge(r.Header.Get("Authorization"))
+		if params["username"] == "user" {
+			ha1 := md5hex("user:aptly:secret")
+			ha2 := md5hex(r.Method + ":" + params["uri"])
+			expected := md5hex(ha1 + ":abcdef:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+			if params["response"] == expected && params["uri"] == r.URL.RequestURI() {
+				fmt.Fprint(w, "digest")
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Digest realm="aptly", qop="auth", nonce="abcdef", opaque="xyz"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	s.server = httptest.NewServer(mux)
+}
+
+func (s *AuthSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func md5hex(value string) string {
+	sum := md5.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *AuthSuite) download(c *C, path string, options aptly.DownloaderOptions) (string, error) {
+	d, err := NewDownloaderWithOptions(0, 1, nil, options)
+	c.Assert(err, IsNil)
+
+	err = d.Download(context.Background(), s.server.URL+path, s.dest)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(s.dest)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	c.Assert(err, IsNil)
+
+	return string(content), nil
+}
+
+func (s *AuthSuite) TestBasic(c *C) {
+	content, err := s.download(c, "/basic", aptly.DownloaderOptions{Username: "user", Password: "secret"})
+	c.Assert(err, IsNil)
+	c.Check(content, Equals, "basic")
+
+	_, err = s.download(c, "/basic", aptly.DownloaderOptions{Username: "user", Password: "wrong"})
+	c.Check(err, ErrorMatches, "HTTP code 401 while fetching .*")
+
+	_, err = s.download(c, "/basic", aptly.DownloaderOptions{})
+	c.Check(err, ErrorMatches, "HTTP code 401 while fetching .*")
+}
+
+func (s *AuthSuite) TestDigest(c *C) {
+	content, err := s.download(c, "/digest", aptly.DownloaderOptions{Username: "user", Password: "secret"})
+	c.Assert(err, IsNil)
+	c.Check(content, Equals, "digest")
+
+	_, err = s.download(c, "/digest", aptly.DownloaderOptions{Username: "user", Password: "wrong"})
+	c.Check(err, ErrorMatches, "HTTP code 401 while fetching .*")
+}
+
+func (s *AuthSuite) TestParseChallenge(c *C) {
+	scheme, params := parseChallenge(`Digest realm="a, b", qop="auth,auth-int", nonce=123, stale=TRUE`)
+	c.Check(scheme, Equals, "digest")
+	c.Check(params, DeepEquals, map[string]string{"realm": "a, b", "qop": "auth,auth-int", "nonce": "123", "stale": "TRUE"})
+}
+
+func (s *AuthSuite) TestTLSOptions(c *C) {
+	_, err := NewDownloaderWithOptions(0, 1, nil, aptly.DownloaderOptions{TLSClientCert: "cert.pem"})
+	c.Check(err, ErrorMatches, "both client TLS certificate and key should be specified")
+
+	_, err = NewDownloaderWithOptions(0, 1, nil, aptly.DownloaderOptions{TLSCACert: "/nonexistent"})
+	c.Check(err, ErrorMatches, "unable to read CA certificates.*")
+
+	_, err = NewDownloaderWithOptions(0, 1, nil, aptly.DownloaderOptions{Proxy: "://"})
+	c.Check(err, ErrorMatches, "unable to parse proxy URL.*")
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// IndexCache is on-disk cache of downloaded files keyed by SHA256 checksum
+//
+// Cache is shared by all the mirrors, so that mirrors of the same archive
+// (e.g. with different filters) download identical package indexes only once.
+type IndexCache struct {
+	root   string
+	maxAge time.Duration
+}
+
+// NewIndexCache creates cache in directory root, entries not used for maxAge are removed by Prune
+func NewIndexCache(root string, maxAge time.Duration) *IndexCache {
+	return &IndexCache{root: root, maxAge: maxAge}
+}
+
+func (cache *IndexCache) path(sha256 string) string {
+	return filepath.Join(cache.root, sha256[:2], sha256)
+}
+
+// Fetch copies cached file with checksum into destination, returns false if there is no such file in the cache
+//
+// Checksums of cached file are verified, so damaged entries are never used.
+func (cache *IndexCache) Fetch(expected *utils.ChecksumInfo, destination string) (bool, error) {
+	if len(expected.SHA256) < 2 {
+		return false, nil
+	}
+
+	cachePath := cache.path(expected.SHA256)
+
+	stat, err := os.Stat(cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if stat.Size() != expected.Size {
+		// partially written or damaged, will be replaced on next Store
+		return false, nil
+	}
+
+	actual, err := utils.ChecksumsForFile(cachePath)
+	if err != nil {
+		return false, err
+	}
+
+	if actual.SHA256 != expected.SHA256 || (expected.MD5 != "" && actual.MD5 != expected.MD5) {
+		// damaged entry, remove it so that it's replaced on next Store
+		_ = os.Remove(cachePath)
+		return false, nil
+	}
+
+	err = os.Link(cachePath, destination)
+	if err != nil {
+		err = utils.CopyFile(cachePath, destination)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// mark entry as recently used, so that it survives Prune
+	now := time.Now()
+	_ = os.Chtimes(cachePath, now, now)
+
+	return true, nil
+}
+
+// Store puts copy of file with checksum into the cache
+func (cache *IndexCache) Store(expected *utils.ChecksumInfo, source string) error {
+	if len(expected.SHA256) < 2 {
+		return nil
+	}
+
+	cachePath := cache.path(expected.SHA256)
+
+	err := os.MkdirAll(filepath.Dir(cachePath), 0777)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(cachePath), ".tmp")
+	if err != nil {
+		return err
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	err = utils.CopyFile(source, temp.Name())
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), cachePath)
+}
+
+// Prune removes entries which haven't been used for maxAge, returns number of removed entries
+func (cache *IndexCache) Prune() (int, error) {
+	removed := 0
+	deadline := time.Now().Add(-cache.maxAge)
+
+	err := filepath.Walk(cache.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() || !info.ModTime().Before(deadline) {
+			return nil
+		}
+
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+
+		return nil
+	})
+
+	return removed, err
+}
+
+// Downloader wraps downloader so that downloads with known SHA256 checksum are served
+// from the cache, and stored in the cache once downloaded
+//
+// Wrapper implements optional interfaces (ConditionalDownloader) only if d implements them.
+func (cache *IndexCache) Downloader(d aptly.Downloader) aptly.Downloader {
+	caching := &cachingDownloader{Downloader: d, cache: cache}
+
+	if conditional, ok := d.(aptly.ConditionalDownloader); ok {
+		return &cachingConditionalDownloader{cachingDownloader: caching, ConditionalDownloader: conditional}
+	}
+
+	return caching
+}
+
+// cachingDownloader serves downloads with checksums from IndexCache
+type cachingDownloader struct {
+	aptly.Downloader
+	cache *IndexCache
+}
+
+// cachingConditionalDownloader is cachingDownloader passing conditional requests
+// to the wrapped downloader
+type cachingConditionalDownloader struct {
+	*cachingDownloader
+	aptly.ConditionalDownloader
+}
+
+// Check interface
+var (
+	_ aptly.Downloader            = (*cachingConditionalDownloader)(nil)
+	_ aptly.ConditionalDownloader = (*cachingConditionalDownloader)(nil)
+)
+
+// DownloadWithChecksum looks up file in the cache before downloading it
+func (d *cachingDownloader) DownloadWithChecksum(ctx context.Context, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	if expected == nil || expected.SHA256 == "" || ignoreMismatch {
+		return d.Downloader.DownloadWithChecksum(ctx, url, destination, expected, ignoreMismatch)
+	}
+
+	found, err := d.cache.Fetch(expected, destination)
+	if err == nil && found {
+		return nil
+	}
+
+	err = d.Downloader.DownloadWithChecksum(ctx, url, destination, expected, false)
+	if err != nil {
+		return err
+	}
+
+	// cache is only an optimization, so failure to store file is not an error
+	_ = d.cache.Store(expected, destination)
+
+	return nil
+}
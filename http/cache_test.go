@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type IndexCacheSuite struct {
+	cache *IndexCache
+	root  string
+}
+
+var _ = Suite(&IndexCacheSuite{})
+
+func (s *IndexCacheSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	s.cache = NewIndexCache(filepath.Join(s.root, "cache"), time.Hour)
+}
+
+func (s *IndexCacheSuite) TestDownloader(c *C) {
+	source := filepath.Join(s.root, "source")
+	c.Assert(os.WriteFile(source, []byte("Package"), 0644), IsNil)
+	checksums, err := utils.ChecksumsForFile(source)
+	c.Assert(err, IsNil)
+
+	fake := NewFakeDownloader().ExpectResponse("http://example.com/Packages", "Package")
+	d := s.cache.Downloader(fake)
+
+	dest1 := filepath.Join(s.root, "dest1")
+	c.Assert(d.DownloadWithChecksum(context.Background(), "http://example.com/Packages", dest1, &checksums, false), IsNil)
+	c.Check(fake.Empty(), Equals, true)
+
+	// second mirror with the same index is served from the cache
+	other := NewFakeDownloader()
+	d = s.cache.Downloader(other)
+
+	dest2 := filepath.Join(s.root, "dest2")
+	c.Assert(d.DownloadWithChecksum(context.Background(), "http://mirror.example.com/Packages", dest2, &checksums, false), IsNil)
+	content, err := os.ReadFile(dest2)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "Package")
+
+	// without SHA256 cache is not used
+	err = d.DownloadWithChecksum(context.Background(), "http://mirror.example.com/Packages", filepath.Join(s.root, "dest3"),
+		&utils.ChecksumInfo{Size: 7, MD5: checksums.MD5}, false)
+	c.Check(err, ErrorMatches, "unexpected request.*")
+}
+
+func (s *IndexCacheSuite) TestFetchVerifiesChecksums(c *C) {
+	source := filepath.Join(s.root, "source")
+	c.Assert(os.WriteFile(source, []byte("Package"), 0644), IsNil)
+	checksums, err := utils.ChecksumsForFile(source)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.cache.Store(&checksums, source), IsNil)
+
+	found, err := s.cache.Fetch(&checksums, filepath.Join(s.root, "dest1"))
+	c.Assert(err, IsNil)
+	c.Check(found, Equals, true)
+
+	// damaged entry of the same size is not used and is removed
+	c.Assert(os.WriteFile(s.cache.path(checksums.SHA256), []byte("Pockage"), 0644), IsNil)
+
+	found, err = s.cache.Fetch(&checksums, filepath.Join(s.root, "dest2"))
+	c.Assert(err, IsNil)
+	c.Check(found, Equals, false)
+	_, err = os.Stat(filepath.Join(s.root, "dest2"))
+	c.Check(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(s.cache.path(checksums.SHA256))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *IndexCacheSuite) TestDownloaderInterfaces(c *C) {
+	d := s.cache.Downloader(NewFakeDownloader())
+	_, ok := d.(aptly.ConditionalDownloader)
+	c.Check(ok, Equals, true)
+
+	d = s.cache.Downloader(plainDownloader{NewFakeDownloader()})
+	_, ok = d.(aptly.ConditionalDownloader)
+	c.Check(ok, Equals, false)
+}
+
+// plainDownloader hides optional interfaces of wrapped downloader
+type plainDownloader struct {
+	aptly.Downloader
+}
+
+func (s *IndexCacheSuite) TestPrune(c *C) {
+	source := filepath.Join(s.root, "source")
+	c.Assert(os.WriteFile(source, []byte("Sources"), 0644), IsNil)
+	checksums, err := utils.ChecksumsForFile(source)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.cache.Store(&checksums, source), IsNil)
+
+	removed, err := s.cache.Prune()
+	c.Assert(err, IsNil)
+	c.Check(removed, Equals, 0)
+
+	old := time.Now().Add(-2 * time.Hour)
+	c.Assert(os.Chtimes(s.cache.path(checksums.SHA256), old, old), IsNil)
+
+	removed, err = s.cache.Prune()
+	c.Assert(err, IsNil)
+	c.Check(removed, Equals, 1)
+
+	found, err := s.cache.Fetch(&checksums, filepath.Join(s.root, "dest"))
+	c.Assert(err, IsNil)
+	c.Check(found, Equals, false)
+
+	removed, err = NewIndexCache(filepath.Join(s.root, "missing"), time.Hour).Prune()
+	c.Assert(err, IsNil)
+	c.Check(removed, Equals, 0)
+}
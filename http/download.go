@@ -22,20 +22,32 @@ import (
 
 // Check interface
 var (
-	_ aptly.Downloader = (*downloaderImpl)(nil)
+	_ aptly.Downloader            = (*downloaderImpl)(nil)
+	_ aptly.ConditionalDownloader = (*downloaderImpl)(nil)
 )
 
+// errNotModified is returned by conditional download if remote file hasn't changed
+var errNotModified = errors.New("not modified")
+
 // downloaderImpl is implementation of Downloader interface
 type downloaderImpl struct {
 	progress  aptly.Progress
 	aggWriter io.Writer
 	maxTries  int
 	client    *http.Client
+	transport *http.Transport
 }
 
 // NewDownloader creates new instance of Downloader which specified number
 // of threads and download limit in bytes/sec
 func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly.Downloader {
+	downloader, _ := NewDownloaderWithOptions(downLimit, maxTries, progress, aptly.DownloaderOptions{})
+	return downloader
+}
+
+// NewDownloaderWithOptions creates new instance of Downloader which accesses remote
+// repository via proxy and/or with credentials and client TLS certificates
+func NewDownloaderWithOptions(downLimit int64, maxTries int, progress aptly.Progress, options aptly.DownloaderOptions) (aptly.Downloader, error) {
 	transport := http.Transport{}
 	transport.Proxy = http.DefaultTransport.(*http.Transport).Proxy
 	transport.ResponseHeaderTimeout = 30 * time.Second
@@ -45,11 +57,16 @@ func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly
 	initTransport(&transport)
 	transport.RegisterProtocol("ftp", &protocol.FTPRoundTripper{})
 
+	if err := configureTransport(&transport, options); err != nil {
+		return nil, err
+	}
+
 	downloader := &downloaderImpl{
-		progress: progress,
-		maxTries: maxTries,
+		progress:  progress,
+		maxTries:  maxTries,
+		transport: &transport,
 		client: &http.Client{
-			Transport: &transport,
+			Transport: newAuthTransport(&transport, options),
 		},
 	}
 
@@ -65,7 +82,7 @@ func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly
 		downloader.aggWriter = progressWriter
 	}
 
-	return downloader
+	return downloader, nil
 }
 
 func (downloader *downloaderImpl) checkRedirect(req *http.Request, _ []*http.Request) error {
@@ -106,7 +123,7 @@ func (downloader *downloaderImpl) GetLength(ctx context.Context, url string) (in
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return -1, &Error{Code: resp.StatusCode, URL: url}
+		return -1, newError(resp, url)
 	}
 
 	if resp.ContentLength < 0 {
@@ -162,7 +179,7 @@ func (downloader *downloaderImpl) newRequest(ctx context.Context, method, url st
 	req.Close = true
 	req = req.WithContext(ctx)
 
-	proxyURL, _ := downloader.client.Transport.(*http.Transport).Proxy(req)
+	proxyURL, _ := downloader.transport.Proxy(req)
 	if proxyURL == nil && (req.URL.Scheme == "http" || req.URL.Scheme == "https") {
 		req.URL.Opaque = strings.Replace(req.URL.RequestURI(), "+", "%2b", -1)
 		req.URL.RawQuery = ""
@@ -180,14 +197,54 @@ func (downloader *downloaderImpl) DownloadWithChecksum(ctx context.Context, url
 		defer downloader.progress.Flush()
 	}
 	req, err := downloader.newRequest(ctx, "GET", url)
+	if err != nil {
+		return err
+	}
+
+	_, err = downloader.downloadWithRetries(req, url, destination, expected, ignoreMismatch, nil)
+	return err
+}
+
+// DownloadIfModified downloads url to destination only if remote file doesn't match validators
+func (downloader *downloaderImpl) DownloadIfModified(ctx context.Context, url string, destination string,
+	validators *aptly.HTTPValidators) (bool, error) {
+
+	if downloader.progress != nil {
+		downloader.progress.Printf("Downloading: %s\n", url)
+		defer downloader.progress.Flush()
+	}
+	req, err := downloader.newRequest(ctx, "GET", url)
+	if err != nil {
+		return false, err
+	}
+
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	return downloader.downloadWithRetries(req, url, destination, nil, false, validators)
+}
+
+func (downloader *downloaderImpl) downloadWithRetries(req *http.Request, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool, validators *aptly.HTTPValidators) (bool, error) {
+	var (
+		temppath string
+		err      error
+	)
 
-	var temppath string
 	maxTries := downloader.maxTries
 	const delayMax = time.Duration(5 * time.Minute)
 	delay := time.Duration(1 * time.Second)
 	const delayMultiplier = 2
 	for maxTries > 0 {
-		temppath, err = downloader.download(req, url, destination, expected, ignoreMismatch)
+		temppath, err = downloader.download(req, url, destination, expected, ignoreMismatch, validators)
+
+		if err == errNotModified {
+			return false, nil
+		}
 
 		if err != nil {
 			if retryableError(err) {
@@ -195,7 +252,17 @@ func (downloader *downloaderImpl) DownloadWithChecksum(ctx context.Context, url
 					downloader.progress.Printf("Error (retrying): %s\n", err)
 				}
 				maxTries--
-				time.Sleep(delay)
+
+				// throttling server might ask to wait longer
+				wait := delay
+				var httpErr *Error
+				if errors.As(err, &httpErr) && httpErr.RetryAfter > wait {
+					wait = httpErr.RetryAfter
+					if wait > delayMax {
+						wait = delayMax
+					}
+				}
+				time.Sleep(wait)
 				// Sleep exponentially at the next retry, but no longer than delayMax
 				delay *= delayMultiplier
 				if delay > delayMax {
@@ -221,19 +288,20 @@ func (downloader *downloaderImpl) DownloadWithChecksum(ctx context.Context, url
 		if downloader.progress != nil {
 			downloader.progress.Printf("Download Error: %s\n", url)
 		}
-		return err
+		return false, err
 	}
 
 	err = os.Rename(temppath, destination)
 	if err != nil {
 		os.Remove(temppath)
-		return errors.Wrap(err, url)
+		return false, errors.Wrap(err, url)
 	}
 
-	return nil
+	return true, nil
 }
 
-func (downloader *downloaderImpl) download(req *http.Request, url, destination string, expected *utils.ChecksumInfo, ignoreMismatch bool) (string, error) {
+func (downloader *downloaderImpl) download(req *http.Request, url, destination string, expected *utils.ChecksumInfo,
+	ignoreMismatch bool, validators *aptly.HTTPValidators) (string, error) {
 	resp, err := downloader.client.Do(req)
 	if err != nil {
 		return "", errors.Wrap(err, url)
@@ -242,8 +310,17 @@ func (downloader *downloaderImpl) download(req *http.Request, url, destination s
 		defer resp.Body.Close()
 	}
 
+	if validators != nil && resp.StatusCode == http.StatusNotModified {
+		return "", errNotModified
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", &Error{Code: resp.StatusCode, URL: url}
+		return "", newError(resp, url)
+	}
+
+	if validators != nil {
+		validators.ETag = resp.Header.Get("ETag")
+		validators.LastModified = resp.Header.Get("Last-Modified")
 	}
 
 	err = os.MkdirAll(filepath.Dir(destination), 0777)
@@ -281,16 +358,8 @@ func (downloader *downloaderImpl) download(req *http.Request, url, destination s
 	if expected != nil {
 		actual := checksummer.Sum()
 
-		if actual.Size != expected.Size {
-			err = fmt.Errorf("%s: size check mismatch %d != %d", url, actual.Size, expected.Size)
-		} else if expected.MD5 != "" && actual.MD5 != expected.MD5 {
-			err = fmt.Errorf("%s: md5 hash mismatch %#v != %#v", url, actual.MD5, expected.MD5)
-		} else if expected.SHA1 != "" && actual.SHA1 != expected.SHA1 {
-			err = fmt.Errorf("%s: sha1 hash mismatch %#v != %#v", url, actual.SHA1, expected.SHA1)
-		} else if expected.SHA256 != "" && actual.SHA256 != expected.SHA256 {
-			err = fmt.Errorf("%s: sha256 hash mismatch %#v != %#v", url, actual.SHA256, expected.SHA256)
-		} else if expected.SHA512 != "" && actual.SHA512 != expected.SHA512 {
-			err = fmt.Errorf("%s: sha512 hash mismatch %#v != %#v", url, actual.SHA512, expected.SHA512)
+		if err = expected.Verify(actual); err != nil {
+			err = fmt.Errorf("%s: %s", url, err)
 		}
 
 		if err != nil {
@@ -34,6 +34,14 @@ func (s *DownloaderSuiteBase) SetUpTest(c *C) {
 	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Hello, %s", r.URL.Path)
 	})
+	mux.HandleFunc("/conditional", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == "\"v1\"" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", "\"v1\"")
+		fmt.Fprintf(w, "Hello, %s", r.URL.Path)
+	})
 
 	s.ch = make(chan struct{})
 
@@ -77,6 +85,24 @@ func (s *DownloaderSuite) TestDownloadOK(c *C) {
 	c.Assert(s.d.Download(s.ctx, s.url+"/test", s.tempfile.Name()), IsNil)
 }
 
+func (s *DownloaderSuite) TestDownloadIfModified(c *C) {
+	validators := &aptly.HTTPValidators{}
+
+	modified, err := s.d.(aptly.ConditionalDownloader).DownloadIfModified(s.ctx, s.url+"/conditional", s.tempfile.Name(), validators)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Check(validators.ETag, Equals, "\"v1\"")
+
+	modified, err = s.d.(aptly.ConditionalDownloader).DownloadIfModified(s.ctx, s.url+"/conditional", s.tempfile.Name(), validators)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, false)
+
+	modified, err = s.d.(aptly.ConditionalDownloader).DownloadIfModified(s.ctx, s.url+"/test", s.tempfile.Name(), validators)
+	c.Assert(err, IsNil)
+	c.Check(modified, Equals, true)
+	c.Check(validators.Empty(), Equals, true)
+}
+
 func (s *DownloaderSuite) TestDownloadWithChecksum(c *C) {
 	c.Assert(s.d.DownloadWithChecksum(s.ctx, s.url+"/test", s.tempfile.Name(), &utils.ChecksumInfo{}, false),
 		ErrorMatches, ".*size check mismatch 12 != 0")
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/md5"
 	"fmt"
 	"io"
 	"os"
@@ -12,9 +13,10 @@ import (
 )
 
 type expectedRequest struct {
-	URL      string
-	Err      error
-	Response string
+	URL         string
+	Err         error
+	Response    string
+	NotModified bool
 }
 
 // FakeDownloader is like Downloader, but it used in tests
@@ -26,7 +28,8 @@ type FakeDownloader struct {
 
 // Check interface
 var (
-	_ aptly.Downloader = (*FakeDownloader)(nil)
+	_ aptly.Downloader            = (*FakeDownloader)(nil)
+	_ aptly.ConditionalDownloader = (*FakeDownloader)(nil)
 )
 
 // NewFakeDownloader creates new expected downloader
@@ -55,6 +58,12 @@ func (f *FakeDownloader) ExpectError(url string, err error) *FakeDownloader {
 	return f
 }
 
+// ExpectNotModified installs expectation on upcoming conditional download with "not modified" response
+func (f *FakeDownloader) ExpectNotModified(url string) *FakeDownloader {
+	f.expected = append(f.expected, expectedRequest{URL: url, NotModified: true})
+	return f
+}
+
 // Empty verifies that are planned downloads have happened
 func (f *FakeDownloader) Empty() bool {
 	return len(f.expected) == 0
@@ -95,7 +104,11 @@ func (f *FakeDownloader) DownloadWithChecksum(_ context.Context, url string, fil
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	return f.writeResponse(expectation, url, filename, expected, ignoreMismatch)
+}
+
+func (f *FakeDownloader) writeResponse(expectation *expectedRequest, url string, filename string, expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
 	if err != nil {
 		return err
 	}
@@ -128,6 +141,30 @@ func (f *FakeDownloader) DownloadWithChecksum(_ context.Context, url string, fil
 	return nil
 }
 
+// DownloadIfModified performs fake conditional download, ETag is generated from the response
+func (f *FakeDownloader) DownloadIfModified(_ context.Context, url string, filename string, validators *aptly.HTTPValidators) (bool, error) {
+	if len(f.expected) > 0 && f.expected[0].URL == url && f.expected[0].NotModified {
+		f.expected = f.expected[1:]
+		if !validators.Empty() {
+			return false, nil
+		}
+		return false, fmt.Errorf("unexpected conditional request without validators for %s", url)
+	}
+
+	expectation, err := f.getExpectedRequest(url)
+	if err != nil {
+		return false, err
+	}
+
+	err = f.writeResponse(expectation, url, filename, nil, false)
+	if err != nil {
+		return false, err
+	}
+
+	validators.ETag = fmt.Sprintf("\"%x\"", md5.Sum([]byte(expectation.Response)))
+	return true, nil
+}
+
 // Download performs fake download by matching against first expectation in the queue
 func (f *FakeDownloader) Download(ctx context.Context, url string, filename string) error {
 	return f.DownloadWithChecksum(ctx, url, filename, nil, false)
@@ -43,6 +43,20 @@ func NewGrabDownloader(downLimit int64, maxTries int, progress aptly.Progress) *
 	}
 }
 
+// NewGrabDownloaderWithOptions creates new grab downloader which accesses remote
+// repository via proxy and/or with credentials and client TLS certificates
+func NewGrabDownloaderWithOptions(downLimit int64, maxTries int, progress aptly.Progress, options aptly.DownloaderOptions) (*GrabDownloader, error) {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if err := configureTransport(transport, options); err != nil {
+		return nil, err
+	}
+
+	downloader := NewGrabDownloader(downLimit, maxTries, progress)
+	downloader.client.HTTPClient = &http.Client{Transport: newAuthTransport(transport, options)}
+
+	return downloader, nil
+}
+
 func (d *GrabDownloader) Download(ctx context.Context, url string, destination string) error {
 	return d.DownloadWithChecksum(ctx, url, destination, nil, false)
 }
@@ -3,13 +3,49 @@ package http
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // Error is download error connected to HTTP code
 type Error struct {
 	Code int
 	URL  string
+	// RetryAfter is delay requested by throttling server before retrying (if any)
+	RetryAfter time.Duration
+}
+
+// newError builds Error from HTTP response, picking up Retry-After of throttling servers
+func newError(resp *http.Response, url string) *Error {
+	result := &Error{Code: resp.StatusCode, URL: url}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+
+	return result
+}
+
+// parseRetryAfter parses value of Retry-After header: either delay in seconds or HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
 }
 
 // Error
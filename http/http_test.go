@@ -2,6 +2,7 @@ package http
 
 import (
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -10,3 +11,18 @@ import (
 func Test(t *testing.T) {
 	TestingT(t)
 }
+
+type HTTPErrorSuite struct{}
+
+var _ = Suite(&HTTPErrorSuite{})
+
+func (s *HTTPErrorSuite) TestParseRetryAfter(c *C) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	c.Check(parseRetryAfter("", now), Equals, time.Duration(0))
+	c.Check(parseRetryAfter("120", now), Equals, 2*time.Minute)
+	c.Check(parseRetryAfter("-5", now), Equals, time.Duration(0))
+	c.Check(parseRetryAfter("Thu, 01 Jun 2023 12:00:30 GMT", now), Equals, 30*time.Second)
+	c.Check(parseRetryAfter("Thu, 01 Jun 2023 11:00:00 GMT", now), Equals, time.Duration(0))
+	c.Check(parseRetryAfter("soon", now), Equals, time.Duration(0))
+}
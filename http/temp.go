@@ -45,3 +45,35 @@ func DownloadTempWithChecksum(ctx context.Context, downloader aptly.Downloader,
 
 	return file, nil
 }
+
+// DownloadTempIfModified is a DownloadTemp which uses conditional request if downloader supports it
+//
+// If remote file matches validators, nil file is returned along with false. Validators
+// are updated with the values returned by the server.
+func DownloadTempIfModified(ctx context.Context, downloader aptly.Downloader, url string, validators *aptly.HTTPValidators) (*os.File, bool, error) {
+	conditional, ok := downloader.(aptly.ConditionalDownloader)
+	if !ok {
+		file, err := DownloadTemp(ctx, downloader, url)
+		return file, true, err
+	}
+
+	tempdir, err := os.MkdirTemp(os.TempDir(), "aptly")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	tempfile := filepath.Join(tempdir, "buffer")
+
+	modified, err := conditional.DownloadIfModified(ctx, url, tempfile, validators)
+	if err != nil || !modified {
+		return nil, false, err
+	}
+
+	file, err := os.Open(tempfile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return file, true, nil
+}
@@ -0,0 +1,201 @@
+package pgp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Test interface
+var (
+	_ Signer = &ExternalSigner{}
+)
+
+// Signing modes requested from external signer
+const (
+	ExternalSignDetached  = "detached"
+	ExternalSignClearSign = "clearsign"
+)
+
+const (
+	armorSignature     = "-----BEGIN PGP SIGNATURE-----"
+	armorSignedMessage = "-----BEGIN PGP SIGNED MESSAGE-----"
+)
+
+// DefaultExternalSignerTimeout limits time spent waiting for single signature
+const DefaultExternalSignerTimeout = time.Minute
+
+// ExternalSigner is implementation of Signer interface which delegates signing to external
+// HTTP signing service or command, so that private keys are not stored on aptly host
+//
+// File to be signed is sent as request body (or command stdin), signature is read from
+// response body (or command stdout). Signing mode (detached or clearsign), key and digest
+// algorithm are passed as X-Aptly-Sign-Mode, X-Aptly-Sign-Key and X-Aptly-Digest-Algo headers
+// (or APTLY_SIGN_MODE, APTLY_SIGN_KEY and APTLY_DIGEST_ALGO environment variables).
+type ExternalSigner struct {
+	url        string
+	command    []string
+	token      string
+	timeout    time.Duration
+	keyRef     string
+	digestAlgo string
+	client     *http.Client
+}
+
+// NewExternalSigner creates signer which either calls signing service at url or runs command
+func NewExternalSigner(url string, command []string, token string, timeout time.Duration) *ExternalSigner {
+	if timeout <= 0 {
+		timeout = DefaultExternalSignerTimeout
+	}
+
+	return &ExternalSigner{
+		url:     url,
+		command: command,
+		token:   token,
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// SetBatch does nothing, external signer is never interactive
+func (e *ExternalSigner) SetBatch(batch bool) {
+}
+
+// SetDigestAlgorithm sets digest algorithm requested from external signer
+func (e *ExternalSigner) SetDigestAlgorithm(algo string) {
+	e.digestAlgo = algo
+}
+
+// SetKey sets key ID requested from external signer
+func (e *ExternalSigner) SetKey(keyRef string) {
+	e.keyRef = keyRef
+}
+
+// SetKeyRing does nothing, keys are managed by external signer
+func (e *ExternalSigner) SetKeyRing(keyring, secretKeyring string) {
+}
+
+// SetPassphrase does nothing, keys are managed by external signer
+func (e *ExternalSigner) SetPassphrase(passphrase, passphraseFile string) {
+}
+
+// Init verifies signer configuration
+func (e *ExternalSigner) Init() error {
+	var err error
+	e.digestAlgo, _, err = parseDigestAlgorithm(e.digestAlgo)
+	if err != nil {
+		return err
+	}
+
+	if e.url == "" && len(e.command) == 0 {
+		return fmt.Errorf("external signer requires either url or command to be configured")
+	}
+
+	if e.url != "" && len(e.command) > 0 {
+		return fmt.Errorf("external signer can't be configured with both url and command")
+	}
+
+	return nil
+}
+
+// DetachedSign signs file with detached signature in ASCII format
+func (e *ExternalSigner) DetachedSign(source string, destination string) error {
+	fmt.Printf("Signing file '%s' with external signer\n", filepath.Base(source))
+
+	return e.sign(ExternalSignDetached, armorSignature, source, destination)
+}
+
+// ClearSign clear-signs the file
+func (e *ExternalSigner) ClearSign(source string, destination string) error {
+	fmt.Printf("Clearsigning file '%s' with external signer\n", filepath.Base(source))
+
+	return e.sign(ExternalSignClearSign, armorSignedMessage, source, destination)
+}
+
+func (e *ExternalSigner) sign(mode, armorHeader, source, destination string) error {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", source, err)
+	}
+
+	var signature []byte
+	if e.url != "" {
+		signature, err = e.signHTTP(mode, content)
+	} else {
+		signature, err = e.signCommand(mode, content)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to sign %s with external signer: %s", filepath.Base(source), err)
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(signature), []byte(armorHeader)) {
+		return fmt.Errorf("unable to sign %s with external signer: response is not %s signature in ASCII armor",
+			filepath.Base(source), mode)
+	}
+
+	return os.WriteFile(destination, signature, 0644)
+}
+
+func (e *ExternalSigner) signHTTP(mode string, content []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Aptly-Sign-Mode", mode)
+	req.Header.Set("X-Aptly-Digest-Algo", e.digestAlgo)
+	if e.keyRef != "" {
+		req.Header.Set("X-Aptly-Sign-Key", e.keyRef)
+	}
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("signing service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+func (e *ExternalSigner) signCommand(mode string, content []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"APTLY_SIGN_MODE="+mode,
+		"APTLY_SIGN_KEY="+e.keyRef,
+		"APTLY_DIGEST_ALGO="+e.digestAlgo)
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,160 @@
+package pgp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ExternalSignerSuite struct {
+	server   *httptest.Server
+	verifier Verifier
+	headers  http.Header
+
+	cleartext, signed string
+}
+
+var _ = Suite(&ExternalSignerSuite{})
+
+func (s *ExternalSignerSuite) SetUpTest(c *C) {
+	tempDir := c.MkDir()
+
+	s.cleartext = filepath.Join(tempDir, "Release")
+	s.signed = filepath.Join(tempDir, "Release.gpg")
+	c.Assert(os.WriteFile(s.cleartext, []byte("Origin: aptly\nLabel: aptly\n"), 0644), IsNil)
+
+	// signing service signs with internal signer, keys are available only to the service
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.headers = r.Header
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+
+		serviceDir := c.MkDir()
+		source, destination := filepath.Join(serviceDir, "source"), filepath.Join(serviceDir, "signature")
+
+		body, _ := io.ReadAll(r.Body)
+		_ = os.WriteFile(source, body, 0644)
+
+		signer := &GoSigner{}
+		signer.SetBatch(true)
+		signer.SetKey(r.Header.Get("X-Aptly-Sign-Key"))
+		signer.SetKeyRing("keyrings/aptly.pub", "keyrings/aptly.sec")
+		signer.SetDigestAlgorithm(r.Header.Get("X-Aptly-Digest-Algo"))
+
+		err := signer.Init()
+		if err == nil {
+			if r.Header.Get("X-Aptly-Sign-Mode") == ExternalSignClearSign {
+				err = signer.ClearSign(source, destination)
+			} else {
+				err = signer.DetachedSign(source, destination)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		signature, _ := os.ReadFile(destination)
+		_, _ = w.Write(signature)
+	}))
+
+	s.verifier = &GoVerifier{}
+	s.verifier.AddKeyring("./keyrings/aptly.pub")
+	c.Assert(s.verifier.InitKeyring(false), IsNil)
+}
+
+func (s *ExternalSignerSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *ExternalSignerSuite) TestInit(c *C) {
+	c.Check(NewExternalSigner("", nil, "", 0).Init(), ErrorMatches, ".*requires either url or command.*")
+	c.Check(NewExternalSigner(s.server.URL, []string{"sign"}, "", 0).Init(), ErrorMatches, ".*both url and command.*")
+
+	signer := NewExternalSigner(s.server.URL, nil, "", 0)
+	signer.SetDigestAlgorithm("MD5")
+	c.Check(signer.Init(), ErrorMatches, "unsupported digest algorithm: MD5.*")
+}
+
+func (s *ExternalSignerSuite) TestHTTPDetachedSign(c *C) {
+	signer := NewExternalSigner(s.server.URL, nil, "secret", 0)
+	signer.SetKey("21DBB89C16DB3E6D")
+	c.Assert(signer.Init(), IsNil)
+
+	c.Assert(signer.DetachedSign(s.cleartext, s.signed), IsNil)
+	c.Check(s.headers.Get("X-Aptly-Sign-Mode"), Equals, ExternalSignDetached)
+	c.Check(s.headers.Get("X-Aptly-Sign-Key"), Equals, "21DBB89C16DB3E6D")
+	c.Check(s.headers.Get("X-Aptly-Digest-Algo"), Equals, "SHA256")
+
+	signature, err := os.Open(s.signed)
+	c.Assert(err, IsNil)
+	defer signature.Close()
+	cleartext, err := os.Open(s.cleartext)
+	c.Assert(err, IsNil)
+	defer cleartext.Close()
+
+	c.Check(s.verifier.VerifyDetachedSignature(signature, cleartext, false), IsNil)
+}
+
+func (s *ExternalSignerSuite) TestHTTPClearSign(c *C) {
+	signer := NewExternalSigner(s.server.URL, nil, "secret", 0)
+	c.Assert(signer.Init(), IsNil)
+
+	c.Assert(signer.ClearSign(s.cleartext, s.signed), IsNil)
+	c.Check(s.headers.Get("X-Aptly-Sign-Mode"), Equals, ExternalSignClearSign)
+
+	signed, err := os.Open(s.signed)
+	c.Assert(err, IsNil)
+	defer signed.Close()
+
+	keyInfo, err := s.verifier.VerifyClearsigned(signed, false)
+	c.Assert(err, IsNil)
+	c.Check(keyInfo.GoodKeys, DeepEquals, []Key{"21DBB89C16DB3E6D"})
+}
+
+func (s *ExternalSignerSuite) TestHTTPErrors(c *C) {
+	signer := NewExternalSigner(s.server.URL, nil, "wrong", 0)
+	c.Assert(signer.Init(), IsNil)
+
+	c.Check(signer.DetachedSign(s.cleartext, s.signed), ErrorMatches,
+		"unable to sign Release with external signer: signing service returned 403 Forbidden: access denied")
+
+	// detached signature is not accepted as clearsigned file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(armorSignature + "\n"))
+	}))
+	defer server.Close()
+
+	signer = NewExternalSigner(server.URL, nil, "", 0)
+	c.Assert(signer.Init(), IsNil)
+
+	c.Check(signer.ClearSign(s.cleartext, s.signed), ErrorMatches, ".*response is not clearsign signature in ASCII armor")
+	c.Check(signer.DetachedSign(s.cleartext, s.signed), IsNil)
+}
+
+func (s *ExternalSignerSuite) TestCommand(c *C) {
+	signer := NewExternalSigner("", []string{"sh", "-c", "echo $APTLY_SIGN_MODE $APTLY_SIGN_KEY $APTLY_DIGEST_ALGO >&2; echo '" +
+		armorSignature + "'; cat"}, "", 0)
+	signer.SetKey("KEY")
+	c.Assert(signer.Init(), IsNil)
+
+	c.Assert(signer.DetachedSign(s.cleartext, s.signed), IsNil)
+
+	signature, err := os.ReadFile(s.signed)
+	c.Assert(err, IsNil)
+	c.Check(string(signature), Equals, armorSignature+"\nOrigin: aptly\nLabel: aptly\n")
+
+	signer = NewExternalSigner("", []string{"sh", "-c", "echo $APTLY_SIGN_MODE $APTLY_SIGN_KEY $APTLY_DIGEST_ALGO >&2; exit 1"}, "", 0)
+	signer.SetKey("KEY")
+	c.Assert(signer.Init(), IsNil)
+
+	c.Check(signer.ClearSign(s.cleartext, s.signed), ErrorMatches,
+		"unable to sign Release with external signer: exit status 1: clearsign KEY SHA256")
+}
@@ -14,8 +14,9 @@ import (
 
 // Test interface
 var (
-	_ Signer   = &GpgSigner{}
-	_ Verifier = &GpgVerifier{}
+	_ Signer            = &GpgSigner{}
+	_ PublicKeyExporter = &GpgSigner{}
+	_ Verifier          = &GpgVerifier{}
 )
 
 // GpgSigner is implementation of Signer interface using gpg as external program
@@ -26,6 +27,7 @@ type GpgSigner struct {
 	keyring, secretKeyring     string
 	passphrase, passphraseFile string
 	batch                      bool
+	digestAlgo                 string
 }
 
 // SetBatch control --no-tty flag to gpg
@@ -33,7 +35,14 @@ func (g *GpgSigner) SetBatch(batch bool) {
 	g.batch = batch
 }
 
+// SetDigestAlgorithm sets digest algorithm passed as --digest-algo to gpg
+func (g *GpgSigner) SetDigestAlgorithm(algo string) {
+	g.digestAlgo = algo
+}
+
 // SetKey sets key ID to use when signing files
+//
+// Key ID suffixed with '!' forces gpg to use exactly that (sub)key
 func (g *GpgSigner) SetKey(keyRef string) {
 	g.keyRef = keyRef
 }
@@ -96,6 +105,12 @@ func NewGpgSigner(finder GPGFinder) *GpgSigner {
 
 // Init verifies availability of gpg & presence of keys
 func (g *GpgSigner) Init() error {
+	var err error
+	g.digestAlgo, _, err = parseDigestAlgorithm(g.digestAlgo)
+	if err != nil {
+		return err
+	}
+
 	output, err := exec.Command(g.gpg, "--list-keys", "--dry-run", "--no-auto-check-trustdb").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("unable to execute gpg: %s (is gpg installed?): %s", err, string(output))
@@ -112,7 +127,7 @@ func (g *GpgSigner) Init() error {
 func (g *GpgSigner) DetachedSign(source string, destination string) error {
 	fmt.Printf("Signing file '%s' with gpg, please enter your passphrase when prompted:\n", filepath.Base(source))
 
-	args := []string{"-o", destination, "--digest-algo", "SHA256", "--armor", "--yes"}
+	args := []string{"-o", destination, "--digest-algo", g.digestAlgo, "--armor", "--yes"}
 	args = append(args, g.gpgArgs()...)
 	args = append(args, "--detach-sign", source)
 	cmd := exec.Command(g.gpg, args...)
@@ -125,7 +140,7 @@ func (g *GpgSigner) DetachedSign(source string, destination string) error {
 // ClearSign clear-signs the file
 func (g *GpgSigner) ClearSign(source string, destination string) error {
 	fmt.Printf("Clearsigning file '%s' with gpg, please enter your passphrase when prompted:\n", filepath.Base(source))
-	args := []string{"-o", destination, "--digest-algo", "SHA256", "--yes"}
+	args := []string{"-o", destination, "--digest-algo", g.digestAlgo, "--yes"}
 	args = append(args, g.gpgArgs()...)
 	args = append(args, "--clearsign", source)
 	cmd := exec.Command(g.gpg, args...)
@@ -135,6 +150,56 @@ func (g *GpgSigner) ClearSign(source string, destination string) error {
 	return cmd.Run()
 }
 
+// ExportPublicKey exports public part of the signing key with gpg --export
+func (g *GpgSigner) ExportPublicKey(armored bool) ([]byte, error) {
+	keyringArgs := []string{}
+	if g.keyring != "" {
+		keyringArgs = append(keyringArgs, "--no-auto-check-trustdb", "--no-default-keyring", "--keyring", g.keyring)
+	}
+	if g.secretKeyring != "" && g.version == GPG1x {
+		keyringArgs = append(keyringArgs, "--secret-keyring", g.secretKeyring)
+	}
+
+	keyRef := strings.TrimSuffix(g.keyRef, "!")
+	if keyRef == "" {
+		// no key reference, gpg signs with the first secret key
+		args := append(append([]string{}, keyringArgs...), "--with-colons", "--list-secret-keys")
+		output, err := exec.Command(g.gpg, args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list secret keys: %s", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), ":")
+			if fields[0] == "fpr" && len(fields) > 9 {
+				keyRef = fields[9]
+				break
+			}
+		}
+
+		if keyRef == "" {
+			return nil, fmt.Errorf("looks like there are no keys in gpg, please create one (official manual: http://www.gnupg.org/gph/en/manual.html)")
+		}
+	}
+
+	args := append(append([]string{}, keyringArgs...), "--export")
+	if armored {
+		args = append(args, "--armor")
+	}
+	args = append(args, keyRef)
+
+	output, err := exec.Command(g.gpg, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to export public key %s: %s", keyRef, err)
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("unable to export public key %s: key not found", keyRef)
+	}
+
+	return output, nil
+}
+
 // GpgVerifier is implementation of Verifier interface using gpgv as external program
 type GpgVerifier struct {
 	gpg      string
@@ -2,6 +2,7 @@ package pgp
 
 import (
 	"bytes"
+	"crypto"
 	"fmt"
 	"io"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	openpgp_errors "github.com/ProtonMail/go-crypto/openpgp/errors"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
@@ -22,8 +24,9 @@ import (
 
 // Test interface
 var (
-	_ Signer   = &GoSigner{}
-	_ Verifier = &GoVerifier{}
+	_ Signer            = &GoSigner{}
+	_ PublicKeyExporter = &GoSigner{}
+	_ Verifier          = &GoVerifier{}
 )
 
 // Internal errors
@@ -37,10 +40,12 @@ type GoSigner struct {
 	keyringFile, secretKeyringFile string
 	passphrase, passphraseFile     string
 	batch                          bool
+	digestAlgo                     string
 
 	publicKeyring openpgp.EntityList
 	secretKeyring openpgp.EntityList
 	signer        *openpgp.Entity
+	signingKey    openpgp.Key
 	signerConfig  *packet.Config
 }
 
@@ -50,7 +55,15 @@ func (g *GoSigner) SetBatch(batch bool) {
 	g.batch = batch
 }
 
+// SetDigestAlgorithm sets digest algorithm used for signatures
+func (g *GoSigner) SetDigestAlgorithm(algo string) {
+	g.digestAlgo = algo
+}
+
 // SetKey sets key ID to use when signing files
+//
+// Key ID might reference signing subkey, in that case this subkey
+// is used for signing (optional '!' suffix is accepted as in gpg)
 func (g *GoSigner) SetKey(keyRef string) {
 	g.keyRef = keyRef
 }
@@ -67,7 +80,18 @@ func (g *GoSigner) SetPassphrase(passphrase, passphraseFile string) {
 
 // Init verifies availability of gpg & presence of keys
 func (g *GoSigner) Init() error {
+	var (
+		err  error
+		hash crypto.Hash
+	)
+
+	g.digestAlgo, hash, err = parseDigestAlgorithm(g.digestAlgo)
+	if err != nil {
+		return err
+	}
+
 	g.signerConfig = &packet.Config{
+		DefaultHash:            hash,
 		DefaultCompressionAlgo: packet.CompressionZLIB,
 		CompressionConfig: &packet.CompressionConfig{
 			Level: 9,
@@ -97,8 +121,6 @@ func (g *GoSigner) Init() error {
 		g.secretKeyringFile = "secring.gpg"
 	}
 
-	var err error
-
 	g.publicKeyring, err = loadKeyRing(g.keyringFile, false)
 	if err != nil {
 		return errors.Wrap(err, "error loading public keyring")
@@ -124,20 +146,30 @@ func (g *GoSigner) Init() error {
 			return fmt.Errorf("looks like there are no keys in gpg, please create one (official manual: http://www.gnupg.org/gph/en/manual.html)")
 		}
 	} else {
+		keyRef := strings.TrimSuffix(g.keyRef, "!")
+
 	pickKeyLoop:
 		for _, signer := range g.secretKeyring {
 			key := KeyFromUint64(signer.PrimaryKey.KeyId)
-			if key.Matches(Key(g.keyRef)) {
+			if key.Matches(Key(keyRef)) {
 				g.signer = signer
 				break
 			}
 
+			for _, subkey := range signer.Subkeys {
+				if KeyFromUint64(subkey.PublicKey.KeyId).Matches(Key(keyRef)) {
+					g.signer = signer
+					g.signerConfig.SigningKeyId = subkey.PublicKey.KeyId
+					break pickKeyLoop
+				}
+			}
+
 			if !validEntity(signer) {
 				continue
 			}
 
 			for name := range signer.Identities {
-				if strings.Contains(name, g.keyRef) {
+				if strings.Contains(name, keyRef) {
 					g.signer = signer
 					break pickKeyLoop
 				}
@@ -149,7 +181,17 @@ func (g *GoSigner) Init() error {
 		}
 	}
 
-	if g.signer.PrivateKey.Encrypted {
+	var ok bool
+	g.signingKey, ok = g.signer.SigningKeyById(g.signerConfig.Now(), g.signerConfig.SigningKeyId)
+	if !ok {
+		return errors.Errorf("key %s is not a valid signing key", KeyFromUint64(g.signerConfig.SigningKey()))
+	}
+
+	if g.signingKey.PrivateKey == nil {
+		return errors.Errorf("signing key %s doesn't have a private key", KeyFromUint64(g.signingKey.PublicKey.KeyId))
+	}
+
+	if g.signingKey.PrivateKey.Encrypted {
 		i := 0
 		for name := range g.signer.Identities {
 			if i == 0 {
@@ -161,10 +203,10 @@ func (g *GoSigner) Init() error {
 		}
 
 		fmt.Printf("openpgp: %s-bit %s key, ID %s, created %s\n",
-			keyBits(g.signer.PrimaryKey.PublicKey),
-			pubkeyAlgorithmName(g.signer.PrimaryKey.PubKeyAlgo),
-			KeyFromUint64(g.signer.PrimaryKey.KeyId),
-			g.signer.PrimaryKey.CreationTime.Format("2006-01-02"))
+			keyBits(g.signingKey.PublicKey.PublicKey),
+			pubkeyAlgorithmName(g.signingKey.PublicKey.PubKeyAlgo),
+			KeyFromUint64(g.signingKey.PublicKey.KeyId),
+			g.signingKey.PublicKey.CreationTime.Format("2006-01-02"))
 
 		if g.passphrase == "" {
 			if g.batch {
@@ -201,7 +243,7 @@ func (g *GoSigner) Init() error {
 }
 
 func (g *GoSigner) decryptKey() error {
-	err := g.signer.PrivateKey.Decrypt([]byte(g.passphrase))
+	err := g.signingKey.PrivateKey.Decrypt([]byte(g.passphrase))
 
 	if err == nil {
 		return nil
@@ -256,7 +298,7 @@ func (g *GoSigner) ClearSign(source string, destination string) error {
 	}
 	defer clearsigned.Close()
 
-	stream, err := clearsign.Encode(clearsigned, g.signer.PrivateKey, g.signerConfig)
+	stream, err := clearsign.Encode(clearsigned, g.signingKey.PrivateKey, g.signerConfig)
 	if err != nil {
 		return errors.Wrap(err, "error initializing clear signer")
 	}
@@ -275,6 +317,52 @@ func (g *GoSigner) ClearSign(source string, destination string) error {
 	return nil
 }
 
+// ExportPublicKey exports public part of the signing key (binary or ASCII-armored)
+//
+// Signer should be initialized with Init before exporting the key
+func (g *GoSigner) ExportPublicKey(armored bool) ([]byte, error) {
+	if g.signer == nil {
+		return nil, errors.New("signer is not initialized")
+	}
+
+	// prefer version of the key from public keyring, as it might carry more signatures
+	entity := g.signer
+	for _, candidate := range g.publicKeyring {
+		if bytes.Equal(candidate.PrimaryKey.Fingerprint, g.signer.PrimaryKey.Fingerprint) {
+			entity = candidate
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+
+	var armorWriter io.WriteCloser
+	if armored {
+		var err error
+		armorWriter, err = armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "error exporting public key")
+		}
+		w = armorWriter
+	}
+
+	err := entity.Serialize(w)
+	if err != nil {
+		return nil, errors.Wrap(err, "error exporting public key")
+	}
+
+	if armorWriter != nil {
+		err = armorWriter.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "error exporting public key")
+		}
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
 // GoVerifier is implementation of Verifier interface using Go internal OpenPGP library
 type GoVerifier struct {
 	keyRingFiles []string
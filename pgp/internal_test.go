@@ -1,6 +1,12 @@
 package pgp
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	. "gopkg.in/check.v1"
 )
 
@@ -40,3 +46,64 @@ func (s *GoSignerSuite) SetUpTest(c *C) {
 
 	s.SignerSuite.SetUpTest(c)
 }
+
+func (s *GoSignerSuite) TestSignWithSubkey(c *C) {
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+
+	entity, err := openpgp.NewEntity("Subkey Tester", "", "subkey@aptly.info", config)
+	c.Assert(err, IsNil)
+	c.Assert(entity.AddSigningSubkey(config), IsNil)
+
+	subkey := entity.Subkeys[len(entity.Subkeys)-1].PublicKey.KeyId
+
+	tempDir := c.MkDir()
+	pubring, secring := filepath.Join(tempDir, "pubring.gpg"), filepath.Join(tempDir, "secring.gpg")
+
+	f, err := os.Create(pubring)
+	c.Assert(err, IsNil)
+	c.Assert(entity.Serialize(f), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = os.Create(secring)
+	c.Assert(err, IsNil)
+	c.Assert(entity.SerializePrivate(f, nil), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	s.signer.SetKey(string(KeyFromUint64(subkey)) + "!")
+	s.signer.SetKeyRing(pubring, secring)
+
+	s.verifier = &GoVerifier{}
+	s.verifier.AddKeyring(pubring)
+	c.Assert(s.verifier.InitKeyring(false), IsNil)
+
+	s.testClearSign(c, KeyFromUint64(subkey))
+}
+
+func (s *GoSignerSuite) TestExportPublicKey(c *C) {
+	signer := &GoSigner{}
+	_, err := signer.ExportPublicKey(false)
+	c.Check(err, ErrorMatches, "signer is not initialized")
+
+	signer.SetBatch(true)
+	signer.SetKey(string(s.noPassphraseKey))
+	signer.SetKeyRing(s.keyringNoPassphrase[0], s.keyringNoPassphrase[1])
+	c.Assert(signer.Init(), IsNil)
+
+	key, err := signer.ExportPublicKey(false)
+	c.Assert(err, IsNil)
+
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(key))
+	c.Assert(err, IsNil)
+	c.Assert(entities, HasLen, 1)
+	c.Check(KeyFromUint64(entities[0].PrimaryKey.KeyId), Equals, s.noPassphraseKey)
+	c.Check(entities[0].PrivateKey, IsNil)
+
+	key, err = signer.ExportPublicKey(true)
+	c.Assert(err, IsNil)
+	c.Check(string(key), Matches, "(?s)-----BEGIN PGP PUBLIC KEY BLOCK-----\n.*-----END PGP PUBLIC KEY BLOCK-----\n")
+
+	entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	c.Assert(err, IsNil)
+	c.Assert(entities, HasLen, 1)
+	c.Check(KeyFromUint64(entities[0].PrimaryKey.KeyId), Equals, s.noPassphraseKey)
+}
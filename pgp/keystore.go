@@ -0,0 +1,335 @@
+package pgp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// GlobalKeyNamespace is namespace of KeyStore with keys trusted for all mirrors
+const GlobalKeyNamespace = "global"
+
+// DefaultKeyserver is keyserver used to fetch keys unless overridden
+const DefaultKeyserver = "hkps://keyserver.ubuntu.com"
+
+// maxKeySize limits size of key downloaded from keyserver or URL
+const maxKeySize = 16 * 1024 * 1024
+
+var (
+	namespaceRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	keyIDRegexp     = regexp.MustCompile(`^(0x)?([0-9A-Fa-f]{8}|[0-9A-Fa-f]{16}|[0-9A-Fa-f]{40})$`)
+)
+
+// MirrorKeyNamespace returns namespace of KeyStore with keys trusted for single mirror
+func MirrorKeyNamespace(mirrorUUID string) string {
+	return "mirror-" + mirrorUUID
+}
+
+// ParseKeyID validates key ID (short, long or fingerprint) and normalizes it
+func ParseKeyID(keyID string) (Key, error) {
+	if !keyIDRegexp.MatchString(keyID) {
+		return "", fmt.Errorf("invalid key ID: %s", keyID)
+	}
+
+	return Key(strings.ToUpper(strings.TrimPrefix(keyID, "0x"))), nil
+}
+
+// StoredKey describes key kept in KeyStore
+type StoredKey struct {
+	KeyID       Key
+	Fingerprint string
+	Algorithm   string
+	CreatedAt   time.Time
+	UserIDs     []string
+}
+
+// String returns human-readable description of the key
+func (key StoredKey) String() string {
+	return fmt.Sprintf("%s %s %s %s", key.KeyID, key.CreatedAt.Format("2006-01-02"), key.Algorithm, strings.Join(key.UserIDs, ", "))
+}
+
+// KeyStore is aptly's own store of trusted keys, so that users don't need to manage
+// GnuPG keyrings themselves
+//
+// Keys are kept in directory as binary OpenPGP keyrings (readable both by gpg and
+// internal verifier), one keyring per namespace: global keys and per mirror keys
+type KeyStore struct {
+	dir string
+}
+
+// NewKeyStore creates KeyStore in directory dir
+func NewKeyStore(dir string) *KeyStore {
+	return &KeyStore{dir: dir}
+}
+
+// KeyringPath returns path to keyring file of the namespace
+func (s *KeyStore) KeyringPath(namespace string) string {
+	return filepath.Join(s.dir, namespace+".gpg")
+}
+
+// Keyrings returns paths to existing keyrings of namespaces
+func (s *KeyStore) Keyrings(namespaces ...string) []string {
+	result := []string{}
+
+	for _, namespace := range namespaces {
+		path := s.KeyringPath(namespace)
+		if _, err := os.Stat(path); err == nil {
+			result = append(result, path)
+		}
+	}
+
+	return result
+}
+
+func (s *KeyStore) load(namespace string) (openpgp.EntityList, error) {
+	if !namespaceRegexp.MatchString(namespace) {
+		return nil, fmt.Errorf("invalid key namespace: %s", namespace)
+	}
+
+	f, err := os.Open(s.KeyringPath(namespace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return openpgp.ReadKeyRing(f)
+}
+
+func (s *KeyStore) save(namespace string, entities openpgp.EntityList) error {
+	if len(entities) == 0 {
+		return s.Drop(namespace)
+	}
+
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, entity := range entities {
+		if err = entity.Serialize(&buf); err != nil {
+			return err
+		}
+	}
+
+	path := s.KeyringPath(namespace)
+	err = os.WriteFile(path+".new", buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".new", path)
+}
+
+// Import adds keys from r (armored or binary) to namespace, keys already in the store are
+// replaced with imported versions
+//
+// If only is not empty, keys which don't match any of the key IDs are skipped
+func (s *KeyStore) Import(namespace string, r io.Reader, only []Key) ([]StoredKey, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		imported, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keys: %s", err)
+	}
+
+	entities, err := s.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []StoredKey{}
+	for _, entity := range imported {
+		if len(only) > 0 && !entityMatchesAny(entity, only) {
+			continue
+		}
+
+		replaced := false
+		for i := range entities {
+			if bytes.Equal(entities[i].PrimaryKey.Fingerprint, entity.PrimaryKey.Fingerprint) {
+				entities[i] = entity
+				replaced = true
+			}
+		}
+		if !replaced {
+			entities = append(entities, entity)
+		}
+
+		result = append(result, storedKey(entity))
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no matching keys found")
+	}
+
+	return result, s.save(namespace, entities)
+}
+
+// List returns keys of the namespace
+func (s *KeyStore) List(namespace string) ([]StoredKey, error) {
+	entities, err := s.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]StoredKey, len(entities))
+	for i, entity := range entities {
+		result[i] = storedKey(entity)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].KeyID < result[j].KeyID })
+
+	return result, nil
+}
+
+// Remove deletes keys matching key IDs from namespace
+func (s *KeyStore) Remove(namespace string, keys []Key) ([]StoredKey, error) {
+	entities, err := s.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []StoredKey{}
+	kept := openpgp.EntityList{}
+	for _, entity := range entities {
+		if entityMatchesAny(entity, keys) {
+			removed = append(removed, storedKey(entity))
+		} else {
+			kept = append(kept, entity)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("no matching keys found")
+	}
+
+	return removed, s.save(namespace, kept)
+}
+
+// Drop removes all the keys of namespace
+func (s *KeyStore) Drop(namespace string) error {
+	err := os.Remove(s.KeyringPath(namespace))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// entityMatchesAny checks whether primary key or any of subkeys matches any of key IDs
+func entityMatchesAny(entity *openpgp.Entity, keys []Key) bool {
+	publicKeys := []*packet.PublicKey{entity.PrimaryKey}
+	for _, subkey := range entity.Subkeys {
+		publicKeys = append(publicKeys, subkey.PublicKey)
+	}
+
+	for _, publicKey := range publicKeys {
+		keyID := KeyFromUint64(publicKey.KeyId)
+		fingerprint := Key(fmt.Sprintf("%X", publicKey.Fingerprint))
+
+		for _, key := range keys {
+			if key == fingerprint || key.Matches(keyID) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func storedKey(entity *openpgp.Entity) StoredKey {
+	key := StoredKey{
+		KeyID:       KeyFromUint64(entity.PrimaryKey.KeyId),
+		Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+		Algorithm:   pubkeyAlgorithmName(entity.PrimaryKey.PubKeyAlgo),
+		CreatedAt:   entity.PrimaryKey.CreationTime,
+		UserIDs:     []string{},
+	}
+
+	if bits, err := entity.PrimaryKey.BitLength(); err == nil {
+		key.Algorithm = fmt.Sprintf("%s/%d", key.Algorithm, bits)
+	}
+
+	for name := range entity.Identities {
+		key.UserIDs = append(key.UserIDs, name)
+	}
+	sort.Strings(key.UserIDs)
+
+	return key
+}
+
+// KeyserverURL builds URL to fetch key from keyserver using HKP protocol,
+// keyserver could be specified as hostname or hkp://, hkps://, http:// or https:// URL
+func KeyserverURL(keyserver string, key Key) (string, error) {
+	if keyserver == "" {
+		keyserver = DefaultKeyserver
+	}
+	if !strings.Contains(keyserver, "://") {
+		keyserver = "hkps://" + keyserver
+	}
+
+	u, err := url.Parse(keyserver)
+	if err != nil {
+		return "", fmt.Errorf("invalid keyserver %s: %s", keyserver, err)
+	}
+
+	switch u.Scheme {
+	case "hkp":
+		u.Scheme = "http"
+		if u.Port() == "" {
+			u.Host += ":11371"
+		}
+	case "hkps":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("invalid keyserver %s: unsupported scheme %s", keyserver, u.Scheme)
+	}
+
+	u.Path = "/pks/lookup"
+	u.RawQuery = url.Values{"op": {"get"}, "options": {"mr"}, "search": {"0x" + string(key)}}.Encode()
+
+	return u.String(), nil
+}
+
+// FetchKey downloads key from http(s) URL
+func FetchKey(keyURL string) ([]byte, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	resp, err := client.Get(keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch key: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch key from %s: HTTP code %d", keyURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch key: %s", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,168 @@
+package pgp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type KeyStoreSuite struct {
+	store *KeyStore
+	dir   string
+}
+
+var _ = Suite(&KeyStoreSuite{})
+
+func (s *KeyStoreSuite) SetUpTest(c *C) {
+	s.dir = filepath.Join(c.MkDir(), "keyrings")
+	s.store = NewKeyStore(s.dir)
+}
+
+func (s *KeyStoreSuite) importFile(c *C, namespace, path string, only []Key) ([]StoredKey, error) {
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	return s.store.Import(namespace, f, only)
+}
+
+func (s *KeyStoreSuite) TestImportListRemove(c *C) {
+	c.Check(s.store.Keyrings(GlobalKeyNamespace), DeepEquals, []string{})
+
+	keys, err := s.importFile(c, GlobalKeyNamespace, "keyrings/aptly.pub", nil)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+	c.Check(keys[0].KeyID, Equals, Key("21DBB89C16DB3E6D"))
+	c.Check(keys[0].Algorithm, Equals, "DSA/1024")
+	c.Check(keys[0].UserIDs, DeepEquals, []string{"Aptly Tester (don't use it) <test@aptly.info>"})
+
+	// armored key
+	keys, err = s.importFile(c, GlobalKeyNamespace, "keyrings/aptly2.pub.armor", nil)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+	c.Check(keys[0].KeyID, Equals, Key("751DF85C2B220D45"))
+
+	// re-import replaces the key
+	_, err = s.importFile(c, GlobalKeyNamespace, "keyrings/aptly.pub", nil)
+	c.Assert(err, IsNil)
+
+	keys, err = s.store.List(GlobalKeyNamespace)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 2)
+	c.Check(keys[0].KeyID, Equals, Key("21DBB89C16DB3E6D"))
+	c.Check(keys[1].KeyID, Equals, Key("751DF85C2B220D45"))
+
+	c.Check(s.store.Keyrings(GlobalKeyNamespace, MirrorKeyNamespace("uuid")), DeepEquals,
+		[]string{filepath.Join(s.dir, "global.gpg")})
+
+	// keyring is readable by verifier
+	verifier := &GoVerifier{}
+	verifier.AddKeyring(s.store.KeyringPath(GlobalKeyNamespace))
+	c.Assert(verifier.InitKeyring(false), IsNil)
+	c.Check(verifier.trustedKeyring, HasLen, 2)
+
+	_, err = s.store.Remove(GlobalKeyNamespace, []Key{"12345678"})
+	c.Check(err, ErrorMatches, "no matching keys found")
+
+	keys, err = s.store.Remove(GlobalKeyNamespace, []Key{"16DB3E6D"})
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+	c.Check(keys[0].KeyID, Equals, Key("21DBB89C16DB3E6D"))
+
+	keys, err = s.store.Remove(GlobalKeyNamespace, []Key{"E8AF7EE14162C2D63CFC1AD5751DF85C2B220D45"})
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+
+	// keyring without keys is removed
+	c.Check(s.store.Keyrings(GlobalKeyNamespace), DeepEquals, []string{})
+	keys, err = s.store.List(GlobalKeyNamespace)
+	c.Assert(err, IsNil)
+	c.Check(keys, HasLen, 0)
+}
+
+func (s *KeyStoreSuite) TestImportOnly(c *C) {
+	_, err := s.importFile(c, MirrorKeyNamespace("uuid"), "keyrings/aptly.pub", []Key{"751DF85C2B220D45"})
+	c.Check(err, ErrorMatches, "no matching keys found")
+
+	keys, err := s.importFile(c, MirrorKeyNamespace("uuid"), "keyrings/aptly.pub", []Key{"21DBB89C16DB3E6D"})
+	c.Assert(err, IsNil)
+	c.Check(keys, HasLen, 1)
+
+	c.Check(s.store.Keyrings(GlobalKeyNamespace, MirrorKeyNamespace("uuid")), DeepEquals,
+		[]string{filepath.Join(s.dir, "mirror-uuid.gpg")})
+
+	c.Check(s.store.Drop(MirrorKeyNamespace("uuid")), IsNil)
+	c.Check(s.store.Drop(MirrorKeyNamespace("uuid")), IsNil)
+	c.Check(s.store.Keyrings(MirrorKeyNamespace("uuid")), DeepEquals, []string{})
+}
+
+func (s *KeyStoreSuite) TestImportErrors(c *C) {
+	_, err := s.importFile(c, GlobalKeyNamespace, "1.text", nil)
+	c.Check(err, ErrorMatches, "unable to read keys: .*")
+
+	_, err = s.importFile(c, "../escape", "keyrings/aptly.pub", nil)
+	c.Check(err, ErrorMatches, "invalid key namespace: ../escape")
+}
+
+func (s *KeyStoreSuite) TestParseKeyID(c *C) {
+	key, err := ParseKeyID("0x16db3e6d")
+	c.Check(err, IsNil)
+	c.Check(key, Equals, Key("16DB3E6D"))
+
+	key, err = ParseKeyID("E8AF7EE14162C2D63CFC1AD5751DF85C2B220D45")
+	c.Check(err, IsNil)
+	c.Check(key, Equals, Key("E8AF7EE14162C2D63CFC1AD5751DF85C2B220D45"))
+
+	_, err = ParseKeyID("16DB3E6")
+	c.Check(err, ErrorMatches, "invalid key ID: 16DB3E6")
+
+	_, err = ParseKeyID("keyring.gpg")
+	c.Check(err, ErrorMatches, "invalid key ID: keyring.gpg")
+}
+
+func (s *KeyStoreSuite) TestKeyserverURL(c *C) {
+	url, err := KeyserverURL("", "16DB3E6D")
+	c.Check(err, IsNil)
+	c.Check(url, Equals, "https://keyserver.ubuntu.com/pks/lookup?op=get&options=mr&search=0x16DB3E6D")
+
+	url, err = KeyserverURL("hkp://keys.example.com", "16DB3E6D")
+	c.Check(err, IsNil)
+	c.Check(url, Equals, "http://keys.example.com:11371/pks/lookup?op=get&options=mr&search=0x16DB3E6D")
+
+	url, err = KeyserverURL("http://localhost:8080", "16DB3E6D")
+	c.Check(err, IsNil)
+	c.Check(url, Equals, "http://localhost:8080/pks/lookup?op=get&options=mr&search=0x16DB3E6D")
+
+	_, err = KeyserverURL("ldap://keys.example.com", "16DB3E6D")
+	c.Check(err, ErrorMatches, ".*unsupported scheme ldap")
+}
+
+func (s *KeyStoreSuite) TestFetchKey(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("search") != "0x21DBB89C16DB3E6D" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "keyrings/aptly.pub")
+	}))
+	defer server.Close()
+
+	url, err := KeyserverURL(server.URL, "21DBB89C16DB3E6D")
+	c.Assert(err, IsNil)
+
+	data, err := FetchKey(url)
+	c.Assert(err, IsNil)
+
+	expected, err := os.ReadFile("keyrings/aptly.pub")
+	c.Assert(err, IsNil)
+	c.Check(data, DeepEquals, expected)
+
+	url, err = KeyserverURL(server.URL, "16DB3E6D")
+	c.Assert(err, IsNil)
+
+	_, err = FetchKey(url)
+	c.Check(err, ErrorMatches, "unable to fetch key from .*: HTTP code 404")
+}
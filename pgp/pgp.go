@@ -2,9 +2,11 @@
 package pgp
 
 import (
+	"crypto"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // Key is key in PGP representation
@@ -32,6 +34,32 @@ func KeyFromUint64(key uint64) Key {
 	return Key(fmt.Sprintf("%016X", key))
 }
 
+// DefaultDigestAlgorithm is digest algorithm used for signatures unless overridden
+const DefaultDigestAlgorithm = "SHA256"
+
+// digestAlgorithms lists digest algorithms allowed for signing, SHA1 is rejected
+// by apt >= 1.1, so it's not supported
+var digestAlgorithms = map[string]crypto.Hash{
+	"SHA256": crypto.SHA256,
+	"SHA384": crypto.SHA384,
+	"SHA512": crypto.SHA512,
+}
+
+// parseDigestAlgorithm validates digest algorithm name
+func parseDigestAlgorithm(algo string) (string, crypto.Hash, error) {
+	if algo == "" {
+		algo = DefaultDigestAlgorithm
+	}
+
+	algo = strings.ToUpper(algo)
+	hash, ok := digestAlgorithms[algo]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported digest algorithm: %s (supported: SHA256, SHA384, SHA512)", algo)
+	}
+
+	return algo, hash, nil
+}
+
 // KeyInfo is response from signature verification
 type KeyInfo struct {
 	GoodKeys    []Key
@@ -45,10 +73,17 @@ type Signer interface {
 	SetKeyRing(keyring, secretKeyring string)
 	SetPassphrase(passphrase, passphraseFile string)
 	SetBatch(batch bool)
+	SetDigestAlgorithm(algo string)
 	DetachedSign(source string, destination string) error
 	ClearSign(source string, destination string) error
 }
 
+// PublicKeyExporter is implemented by signers which are able to export public part
+// of the signing key, e.g. to be published alongside the repository
+type PublicKeyExporter interface {
+	ExportPublicKey(armored bool) ([]byte, error)
+}
+
 // Verifier interface describes signature verification factility
 type Verifier interface {
 	InitKeyring(verbose bool) error
@@ -1,12 +1,15 @@
 package pgp
 
 import (
+	"crypto"
 	"crypto/rand"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	. "gopkg.in/check.v1"
 )
 
@@ -188,3 +191,43 @@ func (s *SignerSuite) TestClearSignPassphraseFile(c *C) {
 
 	s.testClearSign(c, s.passphraseKey)
 }
+
+func (s *SignerSuite) TestSignDetachedDigestAlgorithm(c *C) {
+	s.signer.SetKey(string(s.noPassphraseKey))
+	s.signer.SetKeyRing(s.keyringNoPassphrase[0], s.keyringNoPassphrase[1])
+	s.signer.SetDigestAlgorithm("sha512")
+
+	s.testSignDetached(c)
+
+	_, err := s.signedF.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+
+	block, err := armor.Decode(s.signedF)
+	c.Assert(err, IsNil)
+
+	p, err := packet.Read(block.Body)
+	c.Assert(err, IsNil)
+
+	sig, ok := p.(*packet.Signature)
+	c.Assert(ok, Equals, true)
+	c.Check(sig.Hash, Equals, crypto.SHA512)
+}
+
+func (s *SignerSuite) TestClearSignDigestAlgorithm(c *C) {
+	s.signer.SetKey(string(s.noPassphraseKey))
+	s.signer.SetKeyRing(s.keyringNoPassphrase[0], s.keyringNoPassphrase[1])
+	s.signer.SetDigestAlgorithm("SHA384")
+
+	s.testClearSign(c, s.noPassphraseKey)
+
+	signed, err := ioutil.ReadFile(s.signedF.Name())
+	c.Assert(err, IsNil)
+	c.Check(string(signed), Matches, "(?s)-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA384\n.*")
+}
+
+func (s *SignerSuite) TestUnsupportedDigestAlgorithm(c *C) {
+	s.signer.SetKeyRing(s.keyringNoPassphrase[0], s.keyringNoPassphrase[1])
+	s.signer.SetDigestAlgorithm("SHA1")
+
+	c.Check(s.signer.Init(), ErrorMatches, "unsupported digest algorithm: SHA1.*")
+}
@@ -27,11 +27,14 @@ const (
 	itemGt         // >>
 	itemGtEq       // >=, >
 	itemEq         // =
+	itemNotEq      // !=
 	itemPatMatch   // %
 	itemRegexp     // ~
+	itemContains   // *=
 	itemLeftCurly  // {
 	itemRightCurly // }
 	itemString
+	itemIn // in (a|b), recognized by parser
 )
 
 // item represents a token returned from the scanner.
@@ -162,7 +165,12 @@ func lexMain(l *lexer) stateFn {
 	case r == ',':
 		l.emit(itemAnd)
 	case r == '!':
-		l.emit(itemNot)
+		if strings.HasPrefix(l.input[l.pos:], "=") {
+			l.next()
+			l.emit(itemNotEq)
+		} else {
+			l.emit(itemNot)
+		}
 	case r == '<':
 		r2 := l.next()
 		if r2 == '<' {
@@ -189,6 +197,9 @@ func lexMain(l *lexer) stateFn {
 		l.emit(itemPatMatch)
 	case r == '~':
 		l.emit(itemRegexp)
+	case r == '*' && strings.HasPrefix(l.input[l.pos:], "="):
+		l.next()
+		l.emit(itemContains)
 	default:
 		l.backup()
 		return lexString
@@ -28,6 +28,36 @@ func (s *LexerSuite) TestLexing(c *C) {
 	c.Check(<-ch, Equals, item{typ: itemEOF, val: ""})
 }
 
+func (s *LexerSuite) TestLexingContains(c *C) {
+	_, ch := lex("query", "Description (*= backup i), Name (% *-dev)")
+
+	c.Check(<-ch, Equals, item{typ: itemString, val: "Description"})
+	c.Check(<-ch, Equals, item{typ: itemLeftParen, val: "("})
+	c.Check(<-ch, Equals, item{typ: itemContains, val: "*="})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "backup"})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "i"})
+	c.Check(<-ch, Equals, item{typ: itemRightParen, val: ")"})
+	c.Check(<-ch, Equals, item{typ: itemAnd, val: ","})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "Name"})
+	c.Check(<-ch, Equals, item{typ: itemLeftParen, val: "("})
+	c.Check(<-ch, Equals, item{typ: itemPatMatch, val: "%"})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "*-dev"})
+	c.Check(<-ch, Equals, item{typ: itemRightParen, val: ")"})
+	c.Check(<-ch, Equals, item{typ: itemEOF, val: ""})
+}
+
+func (s *LexerSuite) TestLexingNotEqual(c *C) {
+	_, ch := lex("query", "!Priority (!= extra)")
+
+	c.Check(<-ch, Equals, item{typ: itemNot, val: "!"})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "Priority"})
+	c.Check(<-ch, Equals, item{typ: itemLeftParen, val: "("})
+	c.Check(<-ch, Equals, item{typ: itemNotEq, val: "!="})
+	c.Check(<-ch, Equals, item{typ: itemString, val: "extra"})
+	c.Check(<-ch, Equals, item{typ: itemRightParen, val: ")"})
+	c.Check(<-ch, Equals, item{typ: itemEOF, val: ""})
+}
+
 func (s *LexerSuite) TestConsume(c *C) {
 	l, _ := lex("query", "package (<< 1.3)")
 
@@ -2,6 +2,8 @@
 package query
 
 import (
+	"fmt"
+
 	"github.com/aptly-dev/aptly/deb"
 )
 
@@ -15,15 +17,168 @@ import (
   B := C | '!' B
   C := '(' Query ')' | D
   D := <field> <condition> <arch_condition> | <pkg>_<version>_<arch>
-  field := <package-name> | <field> | $special_field
-  condition := '(' <operator> value ')' |
+  field := <package-name> | <field> | $special_field | $PackageSet | $Held
+  condition := '(' <operator> value <flags> ')' |
   arch_condition := '{' arch '}' |
-  operator := | << | < | <= | > | >> | >= | = | % | ~
+  operator := | << | < | <= | > | >> | >= | = | % | ~ | *=
+  flags := | i
 */
 
 // Parse parses input package query into PackageQuery tree ready for evaluation
+//
+// Package sets can't be resolved by Parse, so queries referencing package sets are
+// rejected: such queries should be parsed with ParseWithPackageSets.
 func Parse(query string) (result deb.PackageQuery, err error) {
+	result, err = parseTree(query)
+	if err != nil {
+		return
+	}
+
+	if names := PackageSetNames(result); len(names) > 0 {
+		result, err = nil, fmt.Errorf("unable to resolve package set %s: package sets are not supported here", names[0])
+	}
+	return
+}
+
+// parseTree parses input package query leaving package set references unresolved
+func parseTree(query string) (deb.PackageQuery, error) {
 	l, _ := lex("", query)
-	result, err = parse(l)
+	return parse(l)
+}
+
+// PackageSetSource looks up package sets by name
+type PackageSetSource interface {
+	ByName(name string) (*deb.PackageSet, error)
+}
+
+// ParseWithPackageSets parses input package query and resolves
+// all referenced package sets
+func ParseWithPackageSets(query string, sets PackageSetSource) (result deb.PackageQuery, err error) {
+	result, err = parseTree(query)
+	if err != nil {
+		return
+	}
+
+	err = ResolvePackageSets(result, sets)
+	if err != nil {
+		result = nil
+	}
+	return
+}
+
+// ResolvePackageSets walks query tree and fills in queries for package set references
+func ResolvePackageSets(q deb.PackageQuery, sets PackageSetSource) error {
+	return resolvePackageSets(q, sets, nil)
+}
+
+func resolvePackageSets(q deb.PackageQuery, sets PackageSetSource, stack []string) error {
+	switch q := q.(type) {
+	case *deb.OrQuery:
+		err := resolvePackageSets(q.L, sets, stack)
+		if err != nil {
+			return err
+		}
+		return resolvePackageSets(q.R, sets, stack)
+	case *deb.AndQuery:
+		err := resolvePackageSets(q.L, sets, stack)
+		if err != nil {
+			return err
+		}
+		return resolvePackageSets(q.R, sets, stack)
+	case *deb.NotQuery:
+		return resolvePackageSets(q.Q, sets, stack)
+	case *deb.PackageSetQuery:
+		for _, name := range stack {
+			if name == q.Name {
+				return fmt.Errorf("package set %s has circular reference", q.Name)
+			}
+		}
+
+		set, err := sets.ByName(q.Name)
+		if err != nil {
+			return err
+		}
+
+		if len(set.Queries) == 0 {
+			return fmt.Errorf("package set %s is empty", q.Name)
+		}
+
+		q.Q, err = parseTree(set.Query())
+		if err != nil {
+			return fmt.Errorf("package set %s: %s", q.Name, err)
+		}
+
+		return resolvePackageSets(q.Q, sets, append(stack, q.Name))
+	}
+
+	return nil
+}
+
+// ResolveHeld walks query tree and fills in packages held in local repo
+// for $Held references
+func ResolveHeld(q deb.PackageQuery, repo *deb.LocalRepo) {
+	switch q := q.(type) {
+	case *deb.OrQuery:
+		ResolveHeld(q.L, repo)
+		ResolveHeld(q.R, repo)
+	case *deb.AndQuery:
+		ResolveHeld(q.L, repo)
+		ResolveHeld(q.R, repo)
+	case *deb.NotQuery:
+		ResolveHeld(q.Q, repo)
+	case *deb.PackageSetQuery:
+		ResolveHeld(q.Q, repo)
+	case *deb.HeldQuery:
+		q.Resolve(repo)
+	}
+}
+
+// PackageSetNames returns names of package sets referenced directly in the query
+func PackageSetNames(q deb.PackageQuery) []string {
+	switch q := q.(type) {
+	case *deb.OrQuery:
+		return append(PackageSetNames(q.L), PackageSetNames(q.R)...)
+	case *deb.AndQuery:
+		return append(PackageSetNames(q.L), PackageSetNames(q.R)...)
+	case *deb.NotQuery:
+		return PackageSetNames(q.Q)
+	case *deb.PackageSetQuery:
+		return []string{q.Name}
+	}
+
+	return nil
+}
+
+// PackageSetReferences returns list of package sets and mirrors which
+// reference package set name in their queries
+func PackageSetReferences(name string, collectionFactory *deb.CollectionFactory) (result []string) {
+	references := func(input string) bool {
+		q, err := parseTree(input)
+		if err != nil {
+			return false
+		}
+
+		for _, setName := range PackageSetNames(q) {
+			if setName == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	collectionFactory.PackageSetCollection().ForEach(func(set *deb.PackageSet) error {
+		if set.Name != name && len(set.Queries) > 0 && references(set.Query()) {
+			result = append(result, fmt.Sprintf("package set %s", set))
+		}
+		return nil
+	})
+
+	collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
+		if repo.Filter != "" && references(repo.Filter) {
+			result = append(result, fmt.Sprintf("mirror %s", repo))
+		}
+		return nil
+	})
+
 	return
 }
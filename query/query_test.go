@@ -3,6 +3,9 @@ package query
 import (
 	"testing"
 
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/deb"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -10,3 +13,80 @@ import (
 func Test(t *testing.T) {
 	TestingT(t)
 }
+
+type PackageSetResolveSuite struct {
+	collection *deb.PackageSetCollection
+}
+
+var _ = Suite(&PackageSetResolveSuite{})
+
+func (s *PackageSetResolveSuite) SetUpTest(c *C) {
+	db, err := goleveldb.NewOpenDB(c.MkDir())
+	c.Assert(err, IsNil)
+
+	s.collection = deb.NewPackageSetCollection(db)
+	c.Assert(s.collection.Add(deb.NewPackageSet("runtime", "", []string{"libc6", "Name (% lib*)"})), IsNil)
+	c.Assert(s.collection.Add(deb.NewPackageSet("all", "", []string{"$PackageSet (runtime)", "bash"})), IsNil)
+	c.Assert(s.collection.Add(deb.NewPackageSet("loop", "", []string{"$PackageSet (loop)"})), IsNil)
+	c.Assert(s.collection.Add(deb.NewPackageSet("empty", "", nil)), IsNil)
+}
+
+func (s *PackageSetResolveSuite) TestResolve(c *C) {
+	q, err := ParseWithPackageSets("$PackageSet (all), !$Version (<< 1.0)", s.collection)
+	c.Assert(err, IsNil)
+
+	setQ := q.(*deb.AndQuery).L.(*deb.PackageSetQuery)
+	c.Check(setQ.Q, NotNil)
+	c.Check(setQ.Q.(*deb.OrQuery).L.(*deb.PackageSetQuery).Q, NotNil)
+	c.Check(setQ.String(), Equals, "$PackageSet (all)")
+
+	c.Check(q.Matches(&deb.Package{Name: "libc6", Version: "2.36", Architecture: "amd64"}), Equals, true)
+	c.Check(q.Matches(&deb.Package{Name: "bash", Version: "5.2", Architecture: "amd64"}), Equals, true)
+	c.Check(q.Matches(&deb.Package{Name: "zsh", Version: "5.9", Architecture: "amd64"}), Equals, false)
+}
+
+func (s *PackageSetResolveSuite) TestResolveErrors(c *C) {
+	_, err := ParseWithPackageSets("$PackageSet (missing)", s.collection)
+	c.Check(err, ErrorMatches, "package set with name missing not found")
+
+	_, err = ParseWithPackageSets("$PackageSet (loop)", s.collection)
+	c.Check(err, ErrorMatches, "package set loop has circular reference")
+
+	_, err = ParseWithPackageSets("bash | $PackageSet (empty)", s.collection)
+	c.Check(err, ErrorMatches, "package set empty is empty")
+
+	// package sets can't be resolved without collection of package sets
+	_, err = Parse("bash | !$PackageSet (runtime)")
+	c.Check(err, ErrorMatches, "unable to resolve package set runtime: package sets are not supported here")
+
+	// unresolved package set doesn't match anything
+	c.Check((&deb.PackageSetQuery{Name: "runtime"}).Matches(&deb.Package{Name: "bash"}), Equals, false)
+}
+
+func (s *PackageSetResolveSuite) TestPackageSetNames(c *C) {
+	q, err := parseTree("$PackageSet (all), !($PackageSet (runtime) | bash)")
+	c.Assert(err, IsNil)
+	c.Check(PackageSetNames(q), DeepEquals, []string{"all", "runtime"})
+
+	q, err = parseTree("bash")
+	c.Assert(err, IsNil)
+	c.Check(PackageSetNames(q), HasLen, 0)
+}
+
+func (s *PackageSetResolveSuite) TestResolveHeld(c *C) {
+	held := &deb.Package{Name: "libc6", Version: "2.36", Architecture: "amd64"}
+	other := &deb.Package{Name: "libc6", Version: "2.37", Architecture: "amd64"}
+
+	repo := deb.NewLocalRepo("repo", "")
+	repo.Hold(held)
+
+	q, err := ParseWithPackageSets("$PackageSet (runtime), !$Held", s.collection)
+	c.Assert(err, IsNil)
+
+	// unresolved $Held doesn't match anything
+	c.Check(q.Matches(held), Equals, true)
+
+	ResolveHeld(q, repo)
+	c.Check(q.Matches(held), Equals, false)
+	c.Check(q.Matches(other), Equals, true)
+}
@@ -100,10 +100,16 @@ func operatorToRelation(operator itemType) int {
 		return deb.VersionGreaterOrEqual
 	case itemEq:
 		return deb.VersionEqual
+	case itemNotEq:
+		return deb.VersionNotEqual
+	case itemIn:
+		return deb.VersionIn
 	case itemPatMatch:
 		return deb.VersionPatternMatch
 	case itemRegexp:
 		return deb.VersionRegexp
+	case itemContains:
+		return deb.VersionContains
 	}
 	panic("unable to map token to relation")
 }
@@ -123,7 +129,7 @@ func parsePackageRef(query string) (pkg, version, arch string, ok bool) {
 }
 
 // D := <field> <condition> <arch_condition> | <package>_<version>_<arch>
-// field := <package-name> | <field> | $special_field
+// field := <package-name> | <field> | $special_field | $PackageSet | $Held
 func (p *parser) D() deb.PackageQuery {
 	if p.input.Current().typ != itemString {
 		panic(fmt.Sprintf("unexpected token %s: expecting field or package name", p.input.Current()))
@@ -132,20 +138,41 @@ func (p *parser) D() deb.PackageQuery {
 	field := p.input.Current().val
 	p.input.Consume()
 
-	operator, value := p.Condition()
+	operator, value, values, caseInsensitive := p.Condition()
+
+	if field == "$PackageSet" {
+		if operator != itemEq {
+			panic(fmt.Sprintf("unexpected operator for %s: expecting package set name", field))
+		}
+		return &deb.PackageSetQuery{Name: value}
+	}
+
+	if field == "$Held" {
+		if operator != 0 {
+			panic(fmt.Sprintf("unexpected condition for %s: held packages are matched without condition", field))
+		}
+		return &deb.HeldQuery{}
+	}
 
 	r, _ := utf8.DecodeRuneInString(field)
 	if strings.HasPrefix(field, "$") || (unicode.IsUpper(r) && !strings.ContainsRune(field, '_')) {
 		// special field or regular field
-		q := &deb.FieldQuery{Field: field, Relation: operatorToRelation(operator), Value: value}
-		if q.Relation == deb.VersionRegexp {
-			var err error
-			q.Regexp, err = regexp.Compile(q.Value)
-			if err != nil {
-				panic(fmt.Sprintf("regexp compile failed: %s", err))
+		q := &deb.FieldQuery{Field: field, Relation: operatorToRelation(operator), Value: value, Values: values,
+			CaseInsensitive: caseInsensitive}
+		q.Regexp = compilePattern(q.Relation, q.Value, q.CaseInsensitive)
+		if deb.IsNumericField(field) && q.Relation != deb.VersionDontCare && !deb.IsPatternRelation(q.Relation) {
+			if q.Relation != deb.VersionIn {
+				values = []string{q.Value}
+			}
+			for _, v := range values {
+				if _, err := deb.ParseNumericValue(field, v); err != nil {
+					panic(fmt.Sprintf("invalid value for %s: %s", field, err))
+				}
 			}
 		}
 		return q
+	} else if operator == itemNotEq || operator == itemIn {
+		panic(fmt.Sprintf("unexpected condition for %s: operators != and in are supported only for fields", field))
 	} else if operator == 0 && value == "" {
 		if pkg, version, arch, ok := parsePackageRef(field); ok {
 			// query for specific package
@@ -155,23 +182,35 @@ func (p *parser) D() deb.PackageQuery {
 
 	// regular dependency-like query
 	q := &deb.DependencyQuery{Dep: deb.Dependency{
-		Pkg:          field,
-		Relation:     operatorToRelation(operator),
-		Version:      value,
-		Architecture: p.ArchCondition()}}
-	if q.Dep.Relation == deb.VersionRegexp {
-		var err error
-		q.Dep.Regexp, err = regexp.Compile(q.Dep.Version)
-		if err != nil {
+		Pkg:             field,
+		Relation:        operatorToRelation(operator),
+		Version:         value,
+		CaseInsensitive: caseInsensitive,
+		Architecture:    p.ArchCondition()}}
+	q.Dep.Regexp = compilePattern(q.Dep.Relation, q.Dep.Version, q.Dep.CaseInsensitive)
+	return q
+}
+
+// compilePattern compiles pattern for pattern relations, returns nil for other relations
+func compilePattern(relation int, value string, caseInsensitive bool) *regexp.Regexp {
+	if !deb.IsPatternRelation(relation) {
+		return nil
+	}
+
+	re, err := deb.CompilePattern(relation, value, caseInsensitive)
+	if err != nil {
+		if relation == deb.VersionRegexp {
 			panic(fmt.Sprintf("regexp compile failed: %s", err))
 		}
+		panic(fmt.Sprintf("pattern compile failed: %s", err))
 	}
-	return q
+	return re
 }
 
-// condition := '(' <operator> value ')' |
-// operator := | << | < | <= | > | >> | >= | = | % | ~
-func (p *parser) Condition() (operator itemType, value string) {
+// condition := '(' <operator> value <flags> ')' | '(' 'in' '(' value ['|' value]... ')' ')' |
+// operator := | << | < | <= | > | >> | >= | = | != | % | ~ | *=
+// flags := | i
+func (p *parser) Condition() (operator itemType, value string, values []string, caseInsensitive bool) {
 	if p.input.Current().typ != itemLeftParen {
 		return
 	}
@@ -182,12 +221,12 @@ func (p *parser) Condition() (operator itemType, value string) {
 		p.input.Current().typ == itemLtEq ||
 		p.input.Current().typ == itemGtEq ||
 		p.input.Current().typ == itemEq ||
+		p.input.Current().typ == itemNotEq ||
 		p.input.Current().typ == itemPatMatch ||
-		p.input.Current().typ == itemRegexp {
+		p.input.Current().typ == itemRegexp ||
+		p.input.Current().typ == itemContains {
 		operator = p.input.Current().typ
 		p.input.Consume()
-	} else {
-		operator = itemEq
 	}
 
 	if p.input.Current().typ != itemString {
@@ -196,6 +235,22 @@ func (p *parser) Condition() (operator itemType, value string) {
 	value = p.input.Current().val
 	p.input.Consume()
 
+	if operator == 0 {
+		if value == "in" && p.input.Current().typ == itemLeftParen {
+			operator, value, values = itemIn, "", p.ValueList()
+		} else {
+			operator = itemEq
+		}
+	}
+
+	if p.input.Current().typ == itemString && p.input.Current().val == "i" {
+		if operator != itemPatMatch && operator != itemRegexp && operator != itemContains {
+			panic("case-insensitive flag 'i' is supported only for %, ~ and *= operators")
+		}
+		caseInsensitive = true
+		p.input.Consume()
+	}
+
 	if p.input.Current().typ != itemRightParen {
 		panic(fmt.Sprintf("unexpected token %s: expecting ')'", p.input.Current()))
 	}
@@ -204,6 +259,31 @@ func (p *parser) Condition() (operator itemType, value string) {
 	return
 }
 
+// value_list := '(' value ['|' value]... ')'
+func (p *parser) ValueList() (values []string) {
+	p.input.Consume()
+
+	for {
+		if p.input.Current().typ != itemString {
+			panic(fmt.Sprintf("unexpected token %s: expecting value", p.input.Current()))
+		}
+		values = append(values, p.input.Current().val)
+		p.input.Consume()
+
+		if p.input.Current().typ != itemOr {
+			break
+		}
+		p.input.Consume()
+	}
+
+	if p.input.Current().typ != itemRightParen {
+		panic(fmt.Sprintf("unexpected token %s: expecting '|' or ')'", p.input.Current()))
+	}
+	p.input.Consume()
+
+	return
+}
+
 // arch_condition := '{' arch '}' |
 func (p *parser) ArchCondition() (arch string) {
 	if p.input.Current().typ != itemLeftCurly {
@@ -63,6 +63,12 @@ func (s *SyntaxSuite) TestParsing(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(q, DeepEquals, &deb.PkgQuery{Pkg: "Alien-data", Version: "1.3.4~dev", Arch: "i386"})
 
+	l, _ = lex("query", "alien-data_1.3.4~dev_linux-any")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q, DeepEquals, &deb.PkgQuery{Pkg: "alien-data", Version: "1.3.4~dev", Arch: "linux-any"})
+
 	l, _ = lex("query", "Name")
 	q, err = parse(l)
 
@@ -75,6 +81,70 @@ func (s *SyntaxSuite) TestParsing(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(q, DeepEquals, &deb.DependencyQuery{
 		Dep: deb.Dependency{Pkg: "package", Relation: deb.VersionGreaterOrEqual, Version: "5.3.7", Architecture: "amd64"}})
+
+	l, _ = lex("query", "package {any-arm}")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q, DeepEquals, &deb.DependencyQuery{
+		Dep: deb.Dependency{Pkg: "package", Relation: deb.VersionDontCare, Architecture: "any-arm"}})
+
+	l, _ = lex("query", "$PackageSet (base-runtime), !Name (lala)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.AndQuery).L, DeepEquals, &deb.PackageSetQuery{Name: "base-runtime"})
+
+	l, _ = lex("query", "$Held | openssl")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.OrQuery).L, DeepEquals, &deb.HeldQuery{})
+
+	l, _ = lex("query", "Description (*= Backup i), Section (% non-free/*)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.AndQuery).L, DeepEquals, &deb.FieldQuery{Field: "Description", Relation: deb.VersionContains, Value: "Backup",
+		CaseInsensitive: true, Regexp: regexp.MustCompile(`(?i)Backup`)})
+	c.Check(q.(*deb.AndQuery).R, DeepEquals, &deb.FieldQuery{Field: "Section", Relation: deb.VersionPatternMatch, Value: "non-free/*"})
+
+	l, _ = lex("query", "package (~ DEV i)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q, DeepEquals, &deb.DependencyQuery{Dep: deb.Dependency{Pkg: "package", Relation: deb.VersionRegexp, Version: "DEV",
+		CaseInsensitive: true, Regexp: regexp.MustCompile(`(?i)DEV`)}})
+
+	l, _ = lex("query", "$PackageFile (% *dbgsym*), $Size (>= 100MB)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.AndQuery).L, DeepEquals, &deb.FieldQuery{Field: "$PackageFile", Relation: deb.VersionPatternMatch, Value: "*dbgsym*"})
+	c.Check(q.(*deb.AndQuery).R, DeepEquals, &deb.FieldQuery{Field: "$Size", Relation: deb.VersionGreaterOrEqual, Value: "100MB"})
+
+	l, _ = lex("query", "$Installed-Size (>= 500MB) | $Depends-Count (>> 10)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.OrQuery).L, DeepEquals, &deb.FieldQuery{Field: "$Installed-Size", Relation: deb.VersionGreaterOrEqual, Value: "500MB"})
+	c.Check(q.(*deb.OrQuery).R, DeepEquals, &deb.FieldQuery{Field: "$Depends-Count", Relation: deb.VersionGreater, Value: "10"})
+
+	l, _ = lex("query", "Section (in (libs|'non-free/libs')), Priority (!= extra)")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.AndQuery).L, DeepEquals, &deb.FieldQuery{Field: "Section", Relation: deb.VersionIn, Values: []string{"libs", "non-free/libs"}})
+	c.Check(q.(*deb.AndQuery).R, DeepEquals, &deb.FieldQuery{Field: "Priority", Relation: deb.VersionNotEqual, Value: "extra"})
+	c.Check(q.String(), Equals, "(Section (in (libs|non-free/libs))), (Priority (!= extra))")
+
+	l, _ = lex("query", "Section (in), Section (= in), $Size (in (1K|2K))")
+	q, err = parse(l)
+
+	c.Assert(err, IsNil)
+	c.Check(q.(*deb.AndQuery).L, DeepEquals, &deb.FieldQuery{Field: "Section", Relation: deb.VersionEqual, Value: "in"})
+	c.Check(q.(*deb.AndQuery).R.(*deb.AndQuery).L, DeepEquals, &deb.FieldQuery{Field: "Section", Relation: deb.VersionEqual, Value: "in"})
+	c.Check(q.(*deb.AndQuery).R.(*deb.AndQuery).R, DeepEquals, &deb.FieldQuery{Field: "$Size", Relation: deb.VersionIn, Values: []string{"1K", "2K"}})
 }
 
 func (s *SyntaxSuite) TestParsingErrors(c *C) {
@@ -109,4 +179,44 @@ func (s *SyntaxSuite) TestParsingErrors(c *C) {
 	l, _ = lex("query", "$Name (~ 1.2[34)")
 	_, err = parse(l)
 	c.Check(err, ErrorMatches, "parsing failed: regexp compile failed: error parsing regexp: missing closing \\]: `\\[34`")
+
+	l, _ = lex("query", "Name (% lib[abc)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: pattern compile failed: syntax error in pattern: lib\\[abc")
+
+	l, _ = lex("query", "Name (>= lib i)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: case-insensitive flag 'i' is supported only for %, ~ and \\*= operators")
+
+	l, _ = lex("query", "$PackageSet (% base-*)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: unexpected operator for \\$PackageSet: expecting package set name")
+
+	l, _ = lex("query", "$Held (yes)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: unexpected condition for \\$Held: .*")
+
+	l, _ = lex("query", "$Size (>= lots)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: invalid value for \\$Size: unknown size unit: lots")
+
+	l, _ = lex("query", "Section (in (libs|))")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: unexpected token \\): expecting value")
+
+	l, _ = lex("query", "Section (in (libs libdevel))")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: unexpected token \"libdevel\": expecting '\\|' or '\\)'")
+
+	l, _ = lex("query", "package (!= 1.0)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: unexpected condition for package: operators != and in are supported only for fields")
+
+	l, _ = lex("query", "$Size (in (1K|lots))")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: invalid value for \\$Size: unknown size unit: lots")
+
+	l, _ = lex("query", "$Depends-Count (>= 1K)")
+	_, err = parse(l)
+	c.Check(err, ErrorMatches, "parsing failed: invalid value for \\$Depends-Count: .*invalid syntax")
 }
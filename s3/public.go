@@ -60,7 +60,8 @@ type PublishedStorage struct {
 
 // Check interface
 var (
-	_ aptly.PublishedStorage = (*PublishedStorage)(nil)
+	_ aptly.PublishedStorage         = (*PublishedStorage)(nil)
+	_ aptly.ChecksumPublishedStorage = (*PublishedStorage)(nil)
 )
 
 // NewPublishedStorageRaw creates published storage from raw aws credentials
@@ -168,13 +169,18 @@ func (storage *PublishedStorage) PutFile(path string, sourceFilename string) err
 		source *os.File
 		err    error
 	)
+	sourceMD5, err := utils.MD5ChecksumForFile(sourceFilename)
+	if err != nil {
+		return err
+	}
+
 	source, err = os.Open(sourceFilename)
 	if err != nil {
 		return err
 	}
 	defer source.Close()
 
-	err = storage.putFile(path, source, "")
+	err = storage.putFile(path, source, sourceMD5)
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("error uploading %s to %s", sourceFilename, storage))
 	}
@@ -402,6 +408,31 @@ func (storage *PublishedStorage) Filelist(prefix string) ([]string, error) {
 	return paths, err
 }
 
+// FileMD5s returns MD5 checksums of files under prefix
+func (storage *PublishedStorage) FileMD5s(prefix string) (map[string]string, error) {
+	paths, md5s, err := storage.internalFilelist(prefix, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(paths))
+	for i := range paths {
+		if len(md5s[i]) != 32 || storage.encryptByDefault {
+			// ETag is not MD5 of the object, fetch MD5 from metadata
+			md5s[i], err = storage.getMD5(filepath.Join(prefix, paths[i]))
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("error fetching MD5 of %s from %s", paths[i], storage))
+			}
+		}
+
+		if md5s[i] != "" {
+			result[paths[i]] = md5s[i]
+		}
+	}
+
+	return result, nil
+}
+
 func (storage *PublishedStorage) internalFilelist(prefix string, hidePlusWorkaround bool) (paths []string, md5s []string, err error) {
 	paths = make([]string, 0, 1024)
 	md5s = make([]string, 0, 1024)
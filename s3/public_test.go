@@ -150,6 +150,24 @@ func (s *PublishedStorageSuite) TestFilelist(c *C) {
 	c.Check(list, DeepEquals, []string{"a", "b", "c"})
 }
 
+func (s *PublishedStorageSuite) TestFileMD5s(c *C) {
+	for _, path := range []string{"a", "test/a", "test/b", "lala/test/a"} {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	md5s, err := s.storage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6", "b": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.prefixedStorage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.storage.FileMD5s("test2")
+	c.Check(err, IsNil)
+	c.Check(md5s, HasLen, 0)
+}
+
 func (s *PublishedStorageSuite) TestFilelistPlusWorkaround(c *C) {
 	s.storage.plusWorkaround = true
 	s.prefixedStorage.plusWorkaround = true
@@ -0,0 +1,209 @@
+// Package schedule runs recurring jobs (e.g. mirror updates) at times given with cron expressions
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression is parsed cron expression
+//
+// Expression has five fields: minute, hour, day of month, month and day of week. Each
+// field is either *, a value, a range (1-5) or a list of those (1,3,5-7), optionally
+// with step (*/15, 0-30/10). Months and days of week could be given as names (jan, mon).
+// Shortcuts @yearly, @monthly, @weekly, @daily and @hourly are supported as well.
+type Expression struct {
+	minute, hour, dom, month, dow uint64
+	// if both day of month and day of week are restricted, day matches if either matches
+	domAny, dowAny bool
+}
+
+type fieldRange struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteRange = fieldRange{name: "minute", min: 0, max: 59}
+	hourRange   = fieldRange{name: "hour", min: 0, max: 23}
+	domRange    = fieldRange{name: "day of month", min: 1, max: 31}
+	monthRange  = fieldRange{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well as 0
+	dowRange = fieldRange{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses cron expression
+func Parse(spec string) (*Expression, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := shortcuts[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute, hour, day of month, month, day of week), got %d",
+			spec, len(fields))
+	}
+
+	e := &Expression{
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+
+	var err error
+	for i, target := range []struct {
+		bits *uint64
+		r    fieldRange
+	}{
+		{&e.minute, minuteRange},
+		{&e.hour, hourRange},
+		{&e.dom, domRange},
+		{&e.month, monthRange},
+		{&e.dow, dowRange},
+	} {
+		*target.bits, err = parseField(fields[i], target.r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s", spec, err)
+		}
+	}
+
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+
+	return e, nil
+}
+
+func parseValue(value string, r fieldRange) (int, error) {
+	if n, ok := r.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", r.name, value)
+	}
+
+	if n < r.min || n > r.max {
+		return 0, fmt.Errorf("%s %d out of range %d-%d", r.name, n, r.min, r.max)
+	}
+
+	return n, nil
+}
+
+func parseField(field string, r fieldRange) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		hasStep := false
+
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s %q", r.name, part)
+			}
+			part = part[:i]
+			hasStep = true
+		}
+
+		var low, high int
+		var err error
+
+		if part == "*" {
+			low, high = r.min, r.max
+		} else if i := strings.IndexByte(part, '-'); i >= 0 {
+			low, err = parseValue(part[:i], r)
+			if err != nil {
+				return 0, err
+			}
+			high, err = parseValue(part[i+1:], r)
+			if err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid %s range %q", r.name, part)
+			}
+		} else {
+			low, err = parseValue(part, r)
+			if err != nil {
+				return 0, err
+			}
+			high = low
+			if hasStep {
+				// 5/15 means every 15 starting at 5
+				high = r.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (e *Expression) dayMatches(t time.Time) bool {
+	domMatches := e.dom&(1<<uint(t.Day())) != 0
+	dowMatches := e.dow&(1<<uint(t.Weekday())) != 0
+
+	if e.domAny || e.dowAny {
+		return domMatches && dowMatches
+	}
+
+	return domMatches || dowMatches
+}
+
+// Next returns first time after t matching the expression, zero time is returned
+// if there is no such time (e.g. for February 30th)
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if e.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if e.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if e.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ExpressionSuite struct{}
+
+var _ = Suite(&ExpressionSuite{})
+
+func (s *ExpressionSuite) TestParseErrors(c *C) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"x * * * *",
+		"@fortnightly",
+	} {
+		_, err := Parse(spec)
+		c.Check(err, NotNil, Commentf("spec %q", spec))
+	}
+}
+
+func (s *ExpressionSuite) TestNext(c *C) {
+	// Wednesday
+	base := time.Date(2024, 1, 10, 10, 17, 42, 0, time.UTC)
+
+	for _, t := range []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 1, 10, 10, 25, 0, 0, time.UTC)},
+		{"17 * * * *", time.Date(2024, 1, 10, 11, 17, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 1, 11, 2, 30, 0, 0, time.UTC)},
+		{"0 4,16 * * *", time.Date(2024, 1, 10, 16, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 1", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		e, err := Parse(t.spec)
+		c.Assert(err, IsNil, Commentf("spec %q", t.spec))
+		c.Check(e.Next(base), Equals, t.expected, Commentf("spec %q", t.spec))
+	}
+}
+
+type SchedulerSuite struct {
+	scheduler *Scheduler
+	now       time.Time
+}
+
+var _ = Suite(&SchedulerSuite{})
+
+func (s *SchedulerSuite) SetUpTest(c *C) {
+	s.now = time.Date(2024, 1, 10, 10, 17, 42, 0, time.UTC)
+	s.scheduler = NewScheduler(3)
+	s.scheduler.now = func() time.Time { return s.now }
+}
+
+func (s *SchedulerSuite) TestAdd(c *C) {
+	c.Check(s.scheduler.Add("mirror", "*/5 * * * *", func() error { return nil }), IsNil)
+	c.Check(s.scheduler.Add("mirror", "@daily", func() error { return nil }), ErrorMatches, "job mirror is already scheduled")
+	c.Check(s.scheduler.Add("other", "@sometimes", func() error { return nil }), ErrorMatches, "invalid schedule.*")
+
+	jobs := s.scheduler.Jobs()
+	c.Assert(jobs, HasLen, 1)
+	c.Check(jobs[0].Name, Equals, "mirror")
+	c.Check(jobs[0].Schedule, Equals, "*/5 * * * *")
+	c.Check(jobs[0].NextRun, Equals, time.Date(2024, 1, 10, 10, 20, 0, 0, time.UTC))
+	c.Check(jobs[0].History, HasLen, 0)
+}
+
+func (s *SchedulerSuite) TestRunDue(c *C) {
+	release := make(chan error)
+	c.Assert(s.scheduler.Add("mirror", "*/5 * * * *", func() error { return <-release }), IsNil)
+
+	c.Check(s.scheduler.runDue(), Equals, 2*time.Minute+18*time.Second)
+	c.Check(s.scheduler.Jobs()[0].History, HasLen, 0)
+
+	s.now = time.Date(2024, 1, 10, 10, 20, 0, 0, time.UTC)
+	c.Check(s.scheduler.runDue(), Equals, 5*time.Minute)
+
+	jobs := s.scheduler.Jobs()
+	c.Check(jobs[0].Running, Equals, true)
+	c.Check(jobs[0].NextRun, Equals, time.Date(2024, 1, 10, 10, 25, 0, 0, time.UTC))
+	c.Assert(jobs[0].History, HasLen, 1)
+	c.Check(jobs[0].History[0].State, Equals, RunRunning)
+
+	// overlapping run is skipped
+	s.now = time.Date(2024, 1, 10, 10, 25, 0, 0, time.UTC)
+	s.scheduler.runDue()
+	c.Check(s.scheduler.Trigger("mirror"), ErrorMatches, "job mirror is already running")
+
+	release <- fmt.Errorf("network is down")
+	s.scheduler.wg.Wait()
+
+	jobs = s.scheduler.Jobs()
+	c.Check(jobs[0].Running, Equals, false)
+	c.Assert(jobs[0].History, HasLen, 3)
+	c.Check(jobs[0].History[0].State, Equals, RunFailed)
+	c.Check(jobs[0].History[0].Error, Equals, "network is down")
+	c.Check(jobs[0].History[1].State, Equals, RunSkipped)
+	c.Check(jobs[0].History[2].State, Equals, RunSkipped)
+	c.Check(jobs[0].History[2].Manual, Equals, true)
+
+	c.Check(s.scheduler.Trigger("mirror"), IsNil)
+	release <- nil
+	s.scheduler.wg.Wait()
+
+	jobs = s.scheduler.Jobs()
+	// history is limited to the last 3 runs
+	c.Assert(jobs[0].History, HasLen, 3)
+	c.Check(jobs[0].History[2].State, Equals, RunSucceeded)
+	c.Check(jobs[0].History[2].Manual, Equals, true)
+
+	c.Check(s.scheduler.Trigger("other"), ErrorMatches, "job other is not scheduled")
+}
+
+func (s *SchedulerSuite) TestStartStop(c *C) {
+	done := make(chan struct{})
+	s.now = time.Date(2024, 1, 10, 10, 19, 59, 999000000, time.UTC)
+	c.Assert(s.scheduler.Add("mirror", "*/5 * * * *", func() error { close(done); return nil }), IsNil)
+	s.scheduler.Lock()
+	s.now = time.Date(2024, 1, 10, 10, 20, 0, 0, time.UTC)
+	s.scheduler.Unlock()
+
+	s.scheduler.Start()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("job hasn't been started")
+	}
+	s.scheduler.Stop()
+
+	c.Check(s.scheduler.Jobs()[0].History[0].State, Equals, RunSucceeded)
+}
@@ -0,0 +1,258 @@
+package schedule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Run states
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// JobRun is a single run of the job
+type JobRun struct {
+	Started  time.Time
+	Finished time.Time
+	State    string
+	Error    string `json:",omitempty"`
+	// Manual is set if job was triggered explicitly, not by the schedule
+	Manual bool
+}
+
+// JobFunc is action performed by the job
+type JobFunc func() error
+
+// JobStatus describes job and its recent runs
+type JobStatus struct {
+	Name     string
+	Schedule string
+	NextRun  time.Time
+	Running  bool
+	History  []JobRun
+}
+
+type job struct {
+	name       string
+	spec       string
+	expression *Expression
+	run        JobFunc
+	next       time.Time
+	running    bool
+	history    []*JobRun
+}
+
+// Scheduler runs jobs according to their schedules
+//
+// Job is never run concurrently with itself: if previous run is still in progress
+// when job is due, run is recorded as skipped.
+type Scheduler struct {
+	sync.Mutex
+	jobs        []*job
+	historySize int
+	now         func() time.Time
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewScheduler creates scheduler which keeps last historySize runs of each job
+func NewScheduler(historySize int) *Scheduler {
+	return &Scheduler{
+		historySize: historySize,
+		now:         time.Now,
+	}
+}
+
+func (s *Scheduler) find(name string) *job {
+	for _, j := range s.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+
+	return nil
+}
+
+// Add registers job run according to cron expression spec
+func (s *Scheduler) Add(name string, spec string, run JobFunc) error {
+	expression, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.find(name) != nil {
+		return fmt.Errorf("job %s is already scheduled", name)
+	}
+
+	s.jobs = append(s.jobs, &job{
+		name:       name,
+		spec:       spec,
+		expression: expression,
+		run:        run,
+		next:       expression.Next(s.now()),
+	})
+
+	return nil
+}
+
+// Start starts running jobs in background
+func (s *Scheduler) Start() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	go s.loop(s.stop)
+}
+
+// Stop stops running jobs and waits for runs in progress to finish
+func (s *Scheduler) Stop() {
+	s.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(stop chan struct{}) {
+	for {
+		// wake up at least once a minute, so that changes of wall clock are picked up
+		wait := s.runDue()
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// runDue starts jobs which are due and returns time until next job is due
+func (s *Scheduler) runDue() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	wait := time.Hour
+
+	for _, j := range s.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+
+		if !j.next.After(now) {
+			s.start(j, false)
+			j.next = j.expression.Next(now)
+			if j.next.IsZero() {
+				continue
+			}
+		}
+
+		if until := j.next.Sub(now); until < wait {
+			wait = until
+		}
+	}
+
+	return wait
+}
+
+// start runs the job in background, should be called with lock held
+func (s *Scheduler) start(j *job, manual bool) bool {
+	run := &JobRun{Started: s.now(), State: RunRunning, Manual: manual}
+	s.record(j, run)
+
+	if j.running {
+		run.Finished = run.Started
+		run.State = RunSkipped
+		run.Error = "previous run is still in progress"
+		return false
+	}
+
+	j.running = true
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		err := j.run()
+
+		s.Lock()
+		defer s.Unlock()
+
+		j.running = false
+		run.Finished = s.now()
+		if err != nil {
+			run.State = RunFailed
+			run.Error = err.Error()
+		} else {
+			run.State = RunSucceeded
+		}
+	}()
+
+	return true
+}
+
+func (s *Scheduler) record(j *job, run *JobRun) {
+	j.history = append(j.history, run)
+	if len(j.history) > s.historySize {
+		j.history = j.history[len(j.history)-s.historySize:]
+	}
+}
+
+// Trigger runs job immediately, error is returned if job is already running
+func (s *Scheduler) Trigger(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	j := s.find(name)
+	if j == nil {
+		return fmt.Errorf("job %s is not scheduled", name)
+	}
+
+	if !s.start(j, true) {
+		return fmt.Errorf("job %s is already running", name)
+	}
+
+	return nil
+}
+
+// Jobs returns status of all the jobs
+func (s *Scheduler) Jobs() []JobStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	result := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		result[i] = JobStatus{
+			Name:     j.name,
+			Schedule: j.spec,
+			NextRun:  j.next,
+			Running:  j.running,
+			History:  make([]JobRun, len(j.history)),
+		}
+
+		for k, run := range j.history {
+			result[i].History[k] = *run
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,345 @@
+package sftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// PublishedStorage abstract file system with published files (actually hosted on remote host, accessed over SFTP)
+type PublishedStorage struct {
+	conn     *ssh.Client
+	client   *sftp.Client
+	host     string
+	rootPath string
+
+	// copyFile copies file on the remote host, used when hardlinks are not supported
+	copyFile func(src, dst string) error
+	// fileMD5 calculates MD5 checksum of the file on the remote host, nil if not available
+	fileMD5 func(p string) (string, error)
+}
+
+// Check interface
+var (
+	_ aptly.PublishedStorage = (*PublishedStorage)(nil)
+)
+
+// NewPublishedStorage creates new instance of PublishedStorage connecting to the host over SSH
+func NewPublishedStorage(host string, port int, user, password, privateKeyFile, knownHostsFile string,
+	insecureIgnoreHostKey bool, rootPath string) (*PublishedStorage, error) {
+	if host == "" {
+		return nil, fmt.Errorf("SFTP host is not specified")
+	}
+
+	if rootPath == "" {
+		return nil, fmt.Errorf("SFTP root directory is not specified")
+	}
+
+	conn, err := dial(host, port, user, password, privateKeyFile, knownHostsFile, insecureIgnoreHostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to start SFTP session on %s: %s", host, err)
+	}
+
+	storage := newPublishedStorage(client, host, rootPath)
+	storage.conn = conn
+	storage.copyFile = func(src, dst string) error {
+		return remoteCopy(conn, src, dst)
+	}
+	storage.fileMD5 = func(p string) (string, error) {
+		return remoteMD5(conn, p)
+	}
+
+	return storage, nil
+}
+
+func newPublishedStorage(client *sftp.Client, host, rootPath string) *PublishedStorage {
+	storage := &PublishedStorage{
+		client:   client,
+		host:     host,
+		rootPath: rootPath,
+	}
+	storage.copyFile = storage.streamCopy
+
+	return storage
+}
+
+// String
+func (storage *PublishedStorage) String() string {
+	return fmt.Sprintf("SFTP: %s:%s", storage.host, storage.rootPath)
+}
+
+func (storage *PublishedStorage) remotePath(p string) string {
+	return path.Join(storage.rootPath, p)
+}
+
+// MkDir creates directory recursively under public path
+func (storage *PublishedStorage) MkDir(p string) error {
+	return storage.client.MkdirAll(storage.remotePath(p))
+}
+
+// upload writes contents of source to remote file atomically: data is written to temporary
+// file which is renamed over destination, so that clients never see partially written files
+func (storage *PublishedStorage) upload(p string, source io.Reader) error {
+	dst := storage.remotePath(p)
+	tmp := path.Join(path.Dir(dst), ".aptly-tmp."+path.Base(dst))
+
+	f, err := storage.client.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.ReadFrom(source)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = storage.rename(tmp, dst)
+	}
+
+	if err != nil {
+		_ = storage.client.Remove(tmp)
+	}
+
+	return err
+}
+
+// rename moves file over destination, atomically if server supports that
+func (storage *PublishedStorage) rename(src, dst string) error {
+	if _, ok := storage.client.HasExtension("posix-rename@openssh.com"); ok {
+		return storage.client.PosixRename(src, dst)
+	}
+
+	// plain SFTP rename fails if destination exists
+	err := storage.client.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return storage.client.Rename(src, dst)
+}
+
+// streamCopy copies remote file by reading it and writing back (slow, last resort)
+func (storage *PublishedStorage) streamCopy(src, dst string) error {
+	f, err := storage.client.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := storage.client.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteTo(w)
+	if err1 := w.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+// PutFile puts file into published storage at specified path
+func (storage *PublishedStorage) PutFile(p string, sourceFilename string) error {
+	source, err := os.Open(sourceFilename)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	err = storage.upload(p, source)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("error uploading %s to %s", sourceFilename, storage))
+	}
+
+	return err
+}
+
+// Remove removes single file under public path
+func (storage *PublishedStorage) Remove(p string) error {
+	if len(p) <= 0 {
+		panic("trying to remove empty path")
+	}
+
+	return storage.client.Remove(storage.remotePath(p))
+}
+
+// RemoveDirs removes directory structure under public path
+func (storage *PublishedStorage) RemoveDirs(p string, progress aptly.Progress) error {
+	if len(p) <= 0 {
+		panic("trying to remove the root directory")
+	}
+
+	remotePath := storage.remotePath(p)
+	if progress != nil {
+		progress.Printf("Removing %s on %s...\n", remotePath, storage.host)
+	}
+
+	err := storage.client.RemoveAll(remotePath)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// LinkFromPool uploads package file from pool to publishedPrefix/publishedRelPath/fileName
+// on the remote host, creating missing directories
+//
+// Package files are uploaded the same way as other files (see upload), so interrupted publish
+// doesn't leave truncated packages behind. Existing remote file is compared with the file in the
+// pool by MD5 checksum calculated on the remote host (with md5sum over SSH); if it can't be calculated,
+// existing file is considered up to date if its size matches. With force, file is always uploaded.
+func (storage *PublishedStorage) LinkFromPool(publishedPrefix, publishedRelPath, fileName string, sourcePool aptly.PackagePool,
+	sourcePath string, sourceChecksums utils.ChecksumInfo, force bool) error {
+
+	relPath := path.Join(publishedPrefix, publishedRelPath, fileName)
+	remotePath := storage.remotePath(relPath)
+
+	dstStat, err := storage.client.Stat(remotePath)
+	if err == nil && !force {
+		var same bool
+		same, err = storage.sameFile(remotePath, dstStat.Size(), sourcePool, sourcePath, sourceChecksums)
+		if err != nil {
+			return err
+		}
+
+		if same {
+			return nil
+		}
+
+		return fmt.Errorf("error putting file to %s: file already exists and is different: %s", remotePath, storage)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = storage.client.MkdirAll(path.Dir(remotePath))
+	if err != nil {
+		return err
+	}
+
+	source, err := sourcePool.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	err = storage.upload(relPath, source)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("error uploading %s to %s: %s", sourcePath, storage, remotePath))
+	}
+
+	return err
+}
+
+// sameFile checks whether existing remote file has the same contents as the file in the pool:
+// MD5 checksums are compared if remote checksum could be calculated, sizes otherwise
+func (storage *PublishedStorage) sameFile(remotePath string, remoteSize int64, sourcePool aptly.PackagePool,
+	sourcePath string, sourceChecksums utils.ChecksumInfo) (bool, error) {
+	if storage.fileMD5 != nil && sourceChecksums.MD5 != "" {
+		remoteMD5, err := storage.fileMD5(remotePath)
+		if err == nil {
+			return remoteMD5 == sourceChecksums.MD5, nil
+		}
+	}
+
+	if sourceChecksums.Size == 0 {
+		var err error
+		sourceChecksums.Size, err = sourcePool.Size(sourcePath)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return remoteSize == sourceChecksums.Size, nil
+}
+
+// Filelist returns list of files under prefix
+func (storage *PublishedStorage) Filelist(prefix string) ([]string, error) {
+	root := storage.remotePath(prefix)
+	result := []string{}
+
+	walker := storage.client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) && walker.Path() == root {
+				// file path doesn't exist, consider it empty
+				return []string{}, nil
+			}
+			return nil, err
+		}
+
+		if !walker.Stat().IsDir() {
+			result = append(result, strings.TrimPrefix(walker.Path(), root+"/"))
+		}
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// RenameFile renames (moves) file
+func (storage *PublishedStorage) RenameFile(oldName, newName string) error {
+	return storage.rename(storage.remotePath(oldName), storage.remotePath(newName))
+}
+
+// SymLink creates a symbolic link, which can be read with ReadLink
+func (storage *PublishedStorage) SymLink(src string, dst string) error {
+	return storage.client.Symlink(storage.remotePath(src), storage.remotePath(dst))
+}
+
+// HardLink creates a hardlink of a file, if server doesn't support hardlinks,
+// file is copied on the server side
+func (storage *PublishedStorage) HardLink(src string, dst string) error {
+	if _, ok := storage.client.HasExtension("hardlink@openssh.com"); ok {
+		err := storage.client.Link(storage.remotePath(src), storage.remotePath(dst))
+		if err == nil {
+			return nil
+		}
+	}
+
+	err := storage.copyFile(storage.remotePath(src), storage.remotePath(dst))
+	if err != nil {
+		return fmt.Errorf("error copying %s -> %s in %s: %s", src, dst, storage, err)
+	}
+
+	return nil
+}
+
+// FileExists returns true if path exists
+func (storage *PublishedStorage) FileExists(p string) (bool, error) {
+	_, err := storage.client.Lstat(storage.remotePath(p))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadLink returns the symbolic link pointed to by path (relative to public root)
+func (storage *PublishedStorage) ReadLink(p string) (string, error) {
+	target, err := storage.client.ReadLink(storage.remotePath(p))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(target, storage.rootPath+"/"), nil
+}
@@ -0,0 +1,246 @@
+package sftp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/pkg/sftp"
+
+	. "gopkg.in/check.v1"
+)
+
+type PublishedStorageSuite struct {
+	root    string
+	server  *sftp.Server
+	client  *sftp.Client
+	storage *PublishedStorage
+}
+
+var _ = Suite(&PublishedStorageSuite{})
+
+func (s *PublishedStorageSuite) SetUpTest(c *C) {
+	s.connect(c)
+}
+
+func (s *PublishedStorageSuite) connect(c *C) {
+	var err error
+
+	s.root = c.MkDir()
+
+	serverConn, clientConn := net.Pipe()
+
+	s.server, err = sftp.NewServer(serverConn)
+	c.Assert(err, IsNil)
+	go s.server.Serve()
+
+	s.client, err = sftp.NewClientPipe(clientConn, clientConn)
+	c.Assert(err, IsNil)
+
+	s.storage = newPublishedStorage(s.client, "localhost", s.root)
+}
+
+func (s *PublishedStorageSuite) TearDownTest(c *C) {
+	s.client.Close()
+	s.server.Close()
+}
+
+func (s *PublishedStorageSuite) PutFile(c *C, path string, data []byte) {
+	filename := filepath.Join(c.MkDir(), "a")
+	c.Assert(os.WriteFile(filename, data, 0644), IsNil)
+	c.Assert(s.storage.MkDir(filepath.Dir(path)), IsNil)
+	c.Assert(s.storage.PutFile(path, filename), IsNil)
+}
+
+func (s *PublishedStorageSuite) GetFile(c *C, path string) string {
+	data, err := os.ReadFile(filepath.Join(s.root, path))
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (s *PublishedStorageSuite) TestString(c *C) {
+	c.Check(s.storage.String(), Equals, "SFTP: localhost:"+s.root)
+}
+
+func (s *PublishedStorageSuite) TestPutFile(c *C) {
+	s.PutFile(c, "dists/squeeze/Release", []byte("Welcome to SFTP!"))
+	c.Check(s.GetFile(c, "dists/squeeze/Release"), Equals, "Welcome to SFTP!")
+
+	// overwrite existing file
+	s.PutFile(c, "dists/squeeze/Release", []byte("Updated"))
+	c.Check(s.GetFile(c, "dists/squeeze/Release"), Equals, "Updated")
+
+	list, err := s.storage.Filelist("dists")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"squeeze/Release"})
+}
+
+func (s *PublishedStorageSuite) TestPutFileWithoutPosixRename(c *C) {
+	c.Assert(sftp.SetSFTPExtensions(), IsNil)
+	defer sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+
+	// extensions are negotiated on connect
+	s.TearDownTest(c)
+	s.connect(c)
+
+	s.PutFile(c, "dists/squeeze/Release", []byte("Welcome to SFTP!"))
+	s.PutFile(c, "dists/squeeze/Release", []byte("Updated"))
+	c.Check(s.GetFile(c, "dists/squeeze/Release"), Equals, "Updated")
+
+	// hardlinks are emulated by copying
+	c.Assert(s.storage.HardLink("dists/squeeze/Release", "dists/squeeze/Release.copy"), IsNil)
+	c.Check(s.GetFile(c, "dists/squeeze/Release.copy"), Equals, "Updated")
+
+	st1, _ := os.Stat(filepath.Join(s.root, "dists/squeeze/Release"))
+	st2, _ := os.Stat(filepath.Join(s.root, "dists/squeeze/Release.copy"))
+	c.Check(os.SameFile(st1, st2), Equals, false)
+}
+
+func (s *PublishedStorageSuite) TestFilelist(c *C) {
+	for _, path := range []string{"a", "b", "c", "testa", "test/a", "test/b", "lala/a", "lala/b", "lala/c"} {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	list, err := s.storage.Filelist("")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b", "c", "lala/a", "lala/b", "lala/c", "test/a", "test/b", "testa"})
+
+	list, err = s.storage.Filelist("test")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b"})
+
+	list, err = s.storage.Filelist("test2")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{})
+}
+
+func (s *PublishedStorageSuite) TestRemove(c *C) {
+	s.PutFile(c, "a/b", []byte("test"))
+
+	c.Check(s.storage.Remove("a/b"), IsNil)
+
+	exists, err := s.storage.FileExists("a/b")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, false)
+
+	c.Check(func() { s.storage.Remove("") }, Panics, "trying to remove empty path")
+}
+
+func (s *PublishedStorageSuite) TestRemoveDirs(c *C) {
+	for _, path := range []string{"a", "b", "c", "testa", "test/a", "test/b/c", "lala/a"} {
+		s.PutFile(c, path, []byte("test"))
+	}
+
+	c.Check(s.storage.RemoveDirs("test", nil), IsNil)
+	c.Check(s.storage.RemoveDirs("missing", nil), IsNil)
+
+	list, err := s.storage.Filelist("")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"a", "b", "c", "lala/a", "testa"})
+}
+
+func (s *PublishedStorageSuite) TestRenameFile(c *C) {
+	s.PutFile(c, "dists/squeeze/Release.tmp", []byte("new"))
+	s.PutFile(c, "dists/squeeze/Release", []byte("old"))
+
+	c.Check(s.storage.RenameFile("dists/squeeze/Release.tmp", "dists/squeeze/Release"), IsNil)
+	c.Check(s.GetFile(c, "dists/squeeze/Release"), Equals, "new")
+
+	exists, _ := s.storage.FileExists("dists/squeeze/Release.tmp")
+	c.Check(exists, Equals, false)
+}
+
+func (s *PublishedStorageSuite) TestLinks(c *C) {
+	s.PutFile(c, "dists/squeeze/main/Packages", []byte("Package: a"))
+
+	c.Assert(s.storage.MkDir("dists/squeeze/main/by-hash/SHA256"), IsNil)
+	c.Assert(s.storage.HardLink("dists/squeeze/main/Packages", "dists/squeeze/main/by-hash/SHA256/abcd"), IsNil)
+
+	st1, _ := os.Stat(filepath.Join(s.root, "dists/squeeze/main/Packages"))
+	st2, _ := os.Stat(filepath.Join(s.root, "dists/squeeze/main/by-hash/SHA256/abcd"))
+	c.Check(os.SameFile(st1, st2), Equals, true)
+
+	c.Assert(s.storage.SymLink("dists/squeeze/main/by-hash/SHA256/abcd", "dists/squeeze/main/by-hash/SHA256/Packages"), IsNil)
+	c.Check(s.GetFile(c, "dists/squeeze/main/by-hash/SHA256/Packages"), Equals, "Package: a")
+
+	exists, err := s.storage.FileExists("dists/squeeze/main/by-hash/SHA256/Packages")
+	c.Check(err, IsNil)
+	c.Check(exists, Equals, true)
+
+	link, err := s.storage.ReadLink("dists/squeeze/main/by-hash/SHA256/Packages")
+	c.Check(err, IsNil)
+	c.Check(link, Equals, "dists/squeeze/main/by-hash/SHA256/abcd")
+}
+
+func (s *PublishedStorageSuite) TestLinkFromPool(c *C) {
+	pool := files.NewPackagePool(c.MkDir(), false)
+	cs := files.NewMockChecksumStorage()
+
+	importFile := func(contents string) (string, utils.ChecksumInfo) {
+		tmpFile := filepath.Join(c.MkDir(), "mars-invaders_1.03.deb")
+		c.Assert(os.WriteFile(tmpFile, []byte(contents), 0644), IsNil)
+
+		cksum, err := utils.ChecksumsForFile(tmpFile)
+		c.Assert(err, IsNil)
+
+		src, err := pool.Import(tmpFile, "mars-invaders_1.03.deb", &cksum, true, cs)
+		c.Assert(err, IsNil)
+
+		return src, cksum
+	}
+
+	src1, cksum1 := importFile("Contents")
+	src2, cksum2 := importFile("Other contents")
+
+	relPath := filepath.Join("pool", "main", "m/mars-invaders")
+	published := "ppa/pool/main/m/mars-invaders/mars-invaders_1.03.deb"
+
+	// directories are created on the remote host, no temporary files are left behind
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, cksum1, false), IsNil)
+	c.Check(s.GetFile(c, published), Equals, "Contents")
+
+	list, err := s.storage.Filelist("ppa")
+	c.Check(err, IsNil)
+	c.Check(list, DeepEquals, []string{"pool/main/m/mars-invaders/mars-invaders_1.03.deb"})
+
+	// remote checksum is compared when it can be calculated: file of the same size, but with
+	// different contents is not replaced without force
+	s.storage.fileMD5 = utils.MD5ChecksumForFile
+	c.Assert(os.WriteFile(filepath.Join(s.root, published), []byte("CONTENTS"), 0644), IsNil)
+	err = s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, cksum1, false)
+	c.Check(err, ErrorMatches, "error putting file to "+filepath.Join(s.root, published)+": file already exists and is different: SFTP: .*")
+	c.Check(s.GetFile(c, published), Equals, "CONTENTS")
+
+	// with force, file is always uploaded
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, cksum1, true), IsNil)
+	c.Check(s.GetFile(c, published), Equals, "Contents")
+
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, cksum1, false), IsNil)
+
+	// without remote checksum, remote file is compared by size
+	s.storage.fileMD5 = func(p string) (string, error) { return "", fmt.Errorf("md5sum: command not found") }
+	c.Assert(os.WriteFile(filepath.Join(s.root, published), []byte("CONTENTS"), 0644), IsNil)
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, cksum1, false), IsNil)
+	c.Check(s.GetFile(c, published), Equals, "CONTENTS")
+
+	// size is taken from the pool if it's missing in checksums
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src1, utils.ChecksumInfo{MD5: cksum1.MD5}, false), IsNil)
+
+	// file of different size is not replaced without force
+	err = s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src2, cksum2, false)
+	c.Check(err, ErrorMatches, "error putting file to "+filepath.Join(s.root, published)+": file already exists and is different: SFTP: .*")
+	c.Check(s.GetFile(c, published), Equals, "CONTENTS")
+
+	c.Check(s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.03.deb", pool, src2, cksum2, true), IsNil)
+	c.Check(s.GetFile(c, published), Equals, "Other contents")
+
+	// missing file in the pool
+	err = s.storage.LinkFromPool("ppa", relPath, "mars-invaders_1.04.deb", pool, "ma/rs/missing.deb", utils.ChecksumInfo{}, false)
+	c.Check(err, NotNil)
+
+	exists, _ := s.storage.FileExists("ppa/pool/main/m/mars-invaders/mars-invaders_1.04.deb")
+	c.Check(exists, Equals, false)
+}
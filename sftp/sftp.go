@@ -0,0 +1,143 @@
+// Package sftp handles publishing to remote hosts over SFTP/SSH
+package sftp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// dial establishes SSH connection, authenticating with private key, ssh-agent and password
+// (in that order, whatever is available)
+func dial(host string, port int, user, password, privateKeyFile, knownHostsFile string, insecureIgnoreHostKey bool) (*ssh.Client, error) {
+	if port == 0 {
+		port = 22
+	}
+
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+
+	auth := []ssh.AuthMethod{}
+
+	if privateKeyFile != "" {
+		key, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read private key: %s", err)
+		}
+
+		var signer ssh.Signer
+		if password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(password))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key: %s", err)
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	if password != "" && privateKeyFile == "" {
+		auth = append(auth, ssh.Password(password))
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if insecureIgnoreHostKey {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey() // nolint: gosec
+	} else {
+		if knownHostsFile == "" {
+			knownHostsFile = filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+		}
+
+		var err error
+		hostKeyCallback, err = knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load known hosts: %s", err)
+		}
+	}
+
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		ClientVersion:   "SSH-2.0-aptly_" + strings.ReplaceAll(aptly.Version, " ", "_"),
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)), config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %s", host, err)
+	}
+
+	return conn, nil
+}
+
+// shellQuote quotes argument for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteCopy copies file on the remote host by running cp(1), so that
+// data doesn't have to be transferred over the network
+func remoteCopy(conn *ssh.Client, src, dst string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(fmt.Sprintf("cp -p -- %s %s", shellQuote(src), shellQuote(dst)))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// remoteMD5 calculates MD5 checksum of the file on the remote host by running md5sum(1),
+// so that file doesn't have to be read back over the network
+func remoteMD5(conn *ssh.Client, p string) (string, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(fmt.Sprintf("md5sum -- %s", shellQuote(p)))
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return parseMD5Sum(string(output))
+}
+
+// parseMD5Sum extracts checksum from md5sum(1) output
+func parseMD5Sum(output string) (string, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 || len(fields[0]) != 32 {
+		return "", fmt.Errorf("unexpected md5sum output: %s", strings.TrimSpace(output))
+	}
+
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("unexpected md5sum output: %s", strings.TrimSpace(output))
+	}
+
+	return strings.ToLower(fields[0]), nil
+}
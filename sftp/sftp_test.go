@@ -0,0 +1,33 @@
+package sftp
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type SFTPSuite struct{}
+
+var _ = Suite(&SFTPSuite{})
+
+func (s *SFTPSuite) TestShellQuote(c *C) {
+	c.Check(shellQuote("/srv/www/dists/main"), Equals, "'/srv/www/dists/main'")
+	c.Check(shellQuote("it's"), Equals, `'it'\''s'`)
+}
+
+func (s *SFTPSuite) TestParseMD5Sum(c *C) {
+	md5, err := parseMD5Sum("3ab9b5d3e0f4a1c2d5e6f7a8b9c0d1e2  /srv/www/pool/main/a/a.deb\n")
+	c.Check(err, IsNil)
+	c.Check(md5, Equals, "3ab9b5d3e0f4a1c2d5e6f7a8b9c0d1e2")
+
+	_, err = parseMD5Sum("")
+	c.Check(err, ErrorMatches, "unexpected md5sum output: ")
+
+	_, err = parseMD5Sum("md5sum: /srv/www/a.deb: Permission denied")
+	c.Check(err, ErrorMatches, "unexpected md5sum output: .*")
+}
@@ -27,7 +27,8 @@ type swiftInfo map[string]interface{}
 
 // Check interface
 var (
-	_ aptly.PublishedStorage = (*PublishedStorage)(nil)
+	_ aptly.PublishedStorage         = (*PublishedStorage)(nil)
+	_ aptly.ChecksumPublishedStorage = (*PublishedStorage)(nil)
 )
 
 // NewPublishedStorage creates new instance of PublishedStorage with specified Swift access
@@ -215,9 +216,12 @@ func (storage *PublishedStorage) LinkFromPool(publishedPrefix, publishedRelPath,
 			return fmt.Errorf("unable to compare object, MD5 checksum missing")
 		}
 
-		if !force && info.Hash != sourceChecksums.MD5 {
-			return fmt.Errorf("error putting file to %s: file already exists and is different: %s", poolPath, storage)
+		if info.Hash == sourceChecksums.MD5 {
+			return nil
+		}
 
+		if !force {
+			return fmt.Errorf("error putting file to %s: file already exists and is different: %s", poolPath, storage)
 		}
 	}
 
@@ -256,6 +260,30 @@ func (storage *PublishedStorage) Filelist(prefix string) ([]string, error) {
 	return contents, nil
 }
 
+// FileMD5s returns MD5 checksums of files under prefix
+func (storage *PublishedStorage) FileMD5s(prefix string) (map[string]string, error) {
+	prefix = filepath.Join(storage.prefix, prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+	opts := swift.ObjectsOpts{
+		Prefix: prefix,
+	}
+	objects, err := storage.conn.ObjectsAll(storage.container, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing under prefix %s in %s: %s", prefix, storage, err)
+	}
+
+	result := make(map[string]string, len(objects))
+	for _, object := range objects {
+		if object.Hash != "" {
+			result[object.Name[len(prefix):]] = object.Hash
+		}
+	}
+
+	return result, nil
+}
+
 // RenameFile renames (moves) file
 func (storage *PublishedStorage) RenameFile(oldName, newName string) error {
 	err := storage.conn.ObjectMove(storage.container, filepath.Join(storage.prefix, oldName), storage.container, filepath.Join(storage.prefix, newName))
@@ -1,6 +1,7 @@
 package swift
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -100,6 +101,25 @@ func (s *PublishedStorageSuite) TestFilelist(c *C) {
 	c.Check(list, DeepEquals, []string{"a", "b", "c"})
 }
 
+func (s *PublishedStorageSuite) TestFileMD5s(c *C) {
+	for _, path := range []string{"a", "test/a", "test/b", "lala/test/a"} {
+		_, err := s.storage.conn.ObjectPut("test", path, bytes.NewReader([]byte("test")), false, "", "", nil)
+		c.Assert(err, IsNil)
+	}
+
+	md5s, err := s.storage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6", "b": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.prefixedStorage.FileMD5s("test")
+	c.Check(err, IsNil)
+	c.Check(md5s, DeepEquals, map[string]string{"a": "098f6bcd4621d373cade4e832627b4f6"})
+
+	md5s, err = s.storage.FileMD5s("test2")
+	c.Check(err, IsNil)
+	c.Check(md5s, HasLen, 0)
+}
+
 func (s *PublishedStorageSuite) TestRemove(c *C) {
 	dir := c.MkDir()
 	err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("welcome to swift!"), 0644)
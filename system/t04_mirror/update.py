@@ -242,7 +242,7 @@ class UpdateMirror15Test(BaseTest):
         super(UpdateMirror15Test, self).check()
         # check pool
         self.check_exists(
-            'pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
+            'pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
 
 
 class UpdateMirror16Test(BaseTest):
@@ -272,7 +272,7 @@ class UpdateMirror16Test(BaseTest):
         super(UpdateMirror16Test, self).check()
         # check pool
         self.check_not_exists(
-            'pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
+            'pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
 
 
 class UpdateMirror17Test(BaseTest):
@@ -299,7 +299,7 @@ class UpdateMirror17Test(BaseTest):
         super(UpdateMirror17Test, self).check()
         # check pool
         self.check_not_exists(
-            'pool/db/a2/f225645a2a8bd8378e2f64bd1faa_libboost-program-options-dev_1.62.0.1_i386.deb')
+            'pool/db/a2/f225645a2a8bd8378e2f64bd1faa7d24a90c4555538b4a83f71a0d0d25ac')
 
 
 class UpdateMirror18Test(BaseTest):
@@ -327,7 +327,7 @@ class UpdateMirror18Test(BaseTest):
         super(UpdateMirror18Test, self).check()
         # check pool
         self.check_exists(
-            'pool/db/a2/f225645a2a8bd8378e2f64bd1faa_libboost-program-options-dev_1.62.0.1_i386.deb')
+            'pool/db/a2/f225645a2a8bd8378e2f64bd1faa7d24a90c4555538b4a83f71a0d0d25ac')
 
 
 class UpdateMirror19Test(BaseTest):
@@ -451,3 +451,47 @@ class UpdateMirror25Test(BaseTest):
     ]
     runCmd = "aptly mirror update -keyring=aptlytest.gpg mirror19"
     outputMatchPrepare = filterOutSignature
+
+
+class UpdateMirror26Test(BaseTest):
+    """
+    update mirrors: dry run reports packages and size of download queue
+    """
+    fixtureCmds = [
+        "aptly mirror create --ignore-signatures budget ${url} hardy main",
+    ]
+    fixtureWebServer = "test_release3"
+    configOverride = {
+        "downloadRetries": 0,
+    }
+    runCmd = "aptly mirror update -dry-run --ignore-signatures budget"
+
+    def gold_processor(self, gold):
+        return string.Template(gold).substitute({'url': self.webServerUrl})
+
+    def check(self):
+        super(UpdateMirror26Test, self).check()
+        self.check_not_exists('pool')
+        self.check_cmd_output("aptly mirror show budget", "mirror_show")
+
+
+class UpdateMirror27Test(BaseTest):
+    """
+    update mirrors: download queue exceeds download budget
+    """
+    fixtureCmds = [
+        "aptly mirror create --ignore-signatures budget ${url} hardy main",
+    ]
+    fixtureWebServer = "test_release3"
+    configOverride = {
+        "downloadRetries": 0,
+    }
+    runCmd = "aptly mirror update -download-budget=2 --ignore-signatures budget"
+    expectedCode = 1
+
+    def gold_processor(self, gold):
+        return string.Template(gold).substitute({'url': self.webServerUrl})
+
+    def check(self):
+        super(UpdateMirror27Test, self).check()
+        self.check_not_exists('pool')
@@ -0,0 +1,47 @@
+from lib import BaseTest
+import re
+
+
+class RemoveSnapshot1Test(BaseTest):
+    """
+    remove from snapshot: simple remove
+    """
+    sortOutput = True
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot remove snap1 snap2 pyspi"
+
+    def check(self):
+        def remove_created_at(s):
+            return re.sub(r"Created At: [0-9:A-Za-z -]+\n", "", s)
+
+        self.check_output()
+        self.check_cmd_output("aptly snapshot show -with-packages snap2", "snapshot_show", match_prepare=remove_created_at)
+
+
+class RemoveSnapshot2Test(BaseTest):
+    """
+    remove from snapshot: dry run
+    """
+    sortOutput = True
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot remove -dry-run snap1 snap2 pyspi"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot show snap2", "snapshot_show", expected_code=1)
+
+
+class RemoveSnapshot3Test(BaseTest):
+    """
+    remove from snapshot: no such snapshot
+    """
+    runCmd = "aptly snapshot remove snap1 snap2 pyspi"
+    expectedCode = 1
@@ -1,6 +1,13 @@
+import re
+
 from lib import BaseTest
 
 
+def removeVolatileDetail(s):
+    s = re.sub(r'[ ]*"(UUID|CreatedAt|ReleasedAt)": "[^"]+",?\n', '', s)
+    return re.sub(r'( *)"ReleaseFiles": (\{\}|\{.*?\n\1\}),?\n', '', s, flags=re.S)
+
+
 class PublishList1Test(BaseTest):
     """
     publish list: empty list
@@ -68,3 +75,6 @@ class PublishList5Test(BaseTest):
         "aptly publish snapshot -keyring=${files}/aptly.pub -secret-keyring=${files}/aptly.sec -label=label1 snap2 ppa/tr2",
     ]
     runCmd = "aptly publish list -json"
+
+    def outputMatchPrepare(_, s):
+        return removeVolatileDetail(s)
@@ -1,6 +1,13 @@
+import re
+
 from lib import BaseTest
 
 
+def removeVolatileDetail(s):
+    s = re.sub(r'[ ]*"(UUID|CreatedAt|ReleasedAt)": "[^"]+",?\n', '', s)
+    return re.sub(r'( *)"ReleaseFiles": (\{\}|\{.*?\n\1\}),?\n', '', s, flags=re.S)
+
+
 class PublishShow1Test(BaseTest):
     """
     publish show: existing snapshot
@@ -39,6 +46,9 @@ class PublishShow3Test(BaseTest):
     ]
     runCmd = "aptly publish show -json maverick"
 
+    def outputMatchPrepare(_, s):
+        return removeVolatileDetail(s)
+
 
 class PublishShow4Test(BaseTest):
     """
@@ -51,3 +61,6 @@ class PublishShow4Test(BaseTest):
         "aptly publish snapshot -keyring=${files}/aptly.pub -secret-keyring=${files}/aptly.sec snap1 ppa/smira",
     ]
     runCmd = "aptly publish show -json maverick ppa/smira"
+
+    def outputMatchPrepare(_, s):
+        return removeVolatileDetail(s)
@@ -19,7 +19,7 @@ class AddRepo1Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo1", "repo_show")
 
         # check pool
-        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
 
 
 class AddRepo2Test(BaseTest):
@@ -36,10 +36,10 @@ class AddRepo2Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo2", "repo_show")
 
         # check pool
-        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f_pyspi_0.6.1-1.3.diff.gz')
-        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5_pyspi_0.6.1-1.3.dsc')
-        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba27_pyspi_0.6.1.orig.tar.gz')
-        self.check_exists('pool/28/9d/3aefa970876e9c43686ce2b02f47_pyspi-0.6.1-1.3.stripped.dsc')
+        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f3f2bf745e9ddb440c677df9c3aeaee3c')
+        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5722d6532ddc760ec16972e45c2625989')
+        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba279f093a4723965388cdd0ac02f029bfb9')
+        self.check_exists('pool/28/9d/3aefa970876e9c43686ce2b02f478d7f3ed35a713928464a98d54ae4fca3')
 
 
 class AddRepo3Test(BaseTest):
@@ -56,11 +56,11 @@ class AddRepo3Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo3", "repo_show")
 
         # check pool
-        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
-        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f_pyspi_0.6.1-1.3.diff.gz')
-        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5_pyspi_0.6.1-1.3.dsc')
-        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba27_pyspi_0.6.1.orig.tar.gz')
-        self.check_exists('pool/28/9d/3aefa970876e9c43686ce2b02f47_pyspi-0.6.1-1.3.stripped.dsc')
+        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
+        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f3f2bf745e9ddb440c677df9c3aeaee3c')
+        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5722d6532ddc760ec16972e45c2625989')
+        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba279f093a4723965388cdd0ac02f029bfb9')
+        self.check_exists('pool/28/9d/3aefa970876e9c43686ce2b02f478d7f3ed35a713928464a98d54ae4fca3')
 
 
 class AddRepo4Test(BaseTest):
@@ -97,10 +97,10 @@ class AddRepo4Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo4", "repo_show")
 
         # check pool
-        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
-        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f_pyspi_0.6.1-1.3.diff.gz')
-        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5_pyspi_0.6.1-1.3.dsc')
-        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba27_pyspi_0.6.1.orig.tar.gz')
+        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
+        self.check_exists('pool/2e/77/0b28df948f3197ed0b679bdea99f3f2bf745e9ddb440c677df9c3aeaee3c')
+        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5722d6532ddc760ec16972e45c2625989')
+        self.check_exists('pool/64/06/9ee828c50b1c597d10a3fefbba279f093a4723965388cdd0ac02f029bfb9')
 
         path = os.path.join(self.tempSrcDir, "01", "libboost-program-options-dev_1.49.0.1_i386.deb")
         if os.path.exists(path):
@@ -207,7 +207,7 @@ class AddRepo9Test(BaseTest):
         super(AddRepo9Test, self).prepare()
 
         os.makedirs(os.path.join(os.environ["HOME"], ".aptly", "pool/64/06/"))
-        with open(os.path.join(os.environ["HOME"], ".aptly", "pool/64/06/9ee828c50b1c597d10a3fefbba27_pyspi_0.6.1.orig.tar.gz"), "w") as f:
+        with open(os.path.join(os.environ["HOME"], ".aptly", "pool/64/06/9ee828c50b1c597d10a3fefbba279f093a4723965388cdd0ac02f029bfb9"), "w") as f:
             f.write("abcd")
 
 
@@ -259,7 +259,7 @@ class AddRepo12Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo12", "repo_show")
 
         # check pool
-        self.check_exists('pool/ef/ae/69921b97494e40437712053b60a5_dmraid-udeb_1.0.0.rc16-4.1_amd64.udeb')
+        self.check_exists('pool/ef/ae/69921b97494e40437712053b60a5105fa433f3cfbae3bb2991d341eb95a6')
 
 
 class AddRepo13Test(BaseTest):
@@ -276,8 +276,8 @@ class AddRepo13Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages repo13", "repo_show")
 
         # check pool
-        self.check_exists('pool/ef/ae/69921b97494e40437712053b60a5_dmraid-udeb_1.0.0.rc16-4.1_amd64.udeb')
-        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5_pyspi_0.6.1-1.3.dsc')
+        self.check_exists('pool/ef/ae/69921b97494e40437712053b60a5105fa433f3cfbae3bb2991d341eb95a6')
+        self.check_exists('pool/d4/94/aaf526f1ec6b02f14c2f81e060a5722d6532ddc760ec16972e45c2625989')
 
 
 class AddRepo14Test(BaseTest):
@@ -294,7 +294,7 @@ class AddRepo14Test(BaseTest):
     def check(self):
         super(AddRepo14Test, self).check()
         # check pool
-        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12')
 
 
 class AddRepo15Test(BaseTest):
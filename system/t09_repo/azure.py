@@ -26,17 +26,17 @@ class AzureRepoTest(AzureTest):
 
         # check pool
         self.check_exists_azure_only(
-            'c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb'
+            'c7/6b/4bd12fd92e4dfe1b55b18a67a669d92f62985d6a96c8a21d96120982cf12'
         )
         self.check_exists_azure_only(
-            '2e/77/0b28df948f3197ed0b679bdea99f_pyspi_0.6.1-1.3.diff.gz'
+            '2e/77/0b28df948f3197ed0b679bdea99f3f2bf745e9ddb440c677df9c3aeaee3c'
         )
         self.check_exists_azure_only(
-            'd4/94/aaf526f1ec6b02f14c2f81e060a5_pyspi_0.6.1-1.3.dsc'
+            'd4/94/aaf526f1ec6b02f14c2f81e060a5722d6532ddc760ec16972e45c2625989'
         )
         self.check_exists_azure_only(
-            '64/06/9ee828c50b1c597d10a3fefbba27_pyspi_0.6.1.orig.tar.gz'
+            '64/06/9ee828c50b1c597d10a3fefbba279f093a4723965388cdd0ac02f029bfb9'
         )
         self.check_exists_azure_only(
-            '28/9d/3aefa970876e9c43686ce2b02f47_pyspi-0.6.1-1.3.stripped.dsc'
+            '28/9d/3aefa970876e9c43686ce2b02f478d7f3ed35a713928464a98d54ae4fca3'
         )
@@ -33,9 +33,9 @@ class IncludeRepo1Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages unstable", "repo_show")
 
         # check pool
-        self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e5747_hardlink_0.2.1_amd64.deb')
-        self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289ef_hardlink_0.2.1.dsc')
-        self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f0_hardlink_0.2.1.tar.gz')
+        self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e574751e15f71820c6e08245dac7c5111a0ee')
+        self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289efbc9a396e6765cbbca45f51fde859ea70')
+        self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f017faae9205f5b1c6dfb0fb4207767271')
 
 
 class IncludeRepo2Test(BaseTest):
@@ -54,9 +54,9 @@ class IncludeRepo2Test(BaseTest):
         self.check_cmd_output("aptly repo show -with-packages my-unstable", "repo_show")
 
         # check pool
-        self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e5747_hardlink_0.2.1_amd64.deb')
-        self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289ef_hardlink_0.2.1.dsc')
-        self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f0_hardlink_0.2.1.tar.gz')
+        self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e574751e15f71820c6e08245dac7c5111a0ee')
+        self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289efbc9a396e6765cbbca45f51fde859ea70')
+        self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f017faae9205f5b1c6dfb0fb4207767271')
 
 
 class IncludeRepo3Test(BaseTest):
@@ -111,9 +111,9 @@ class IncludeRepo5Test(BaseTest):
             self.check_cmd_output("aptly repo show -with-packages unstable", "repo_show")
 
             # check pool
-            self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e5747_hardlink_0.2.1_amd64.deb')
-            self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289ef_hardlink_0.2.1.dsc')
-            self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f0_hardlink_0.2.1.tar.gz')
+            self.check_exists('pool/66/83/99580590bf1ffcd9eb161b6e574751e15f71820c6e08245dac7c5111a0ee')
+            self.check_exists('pool/c0/d7/458aa2ca3886cd6885f395a289efbc9a396e6765cbbca45f51fde859ea70')
+            self.check_exists('pool/4d/f0/adce005526a1f0e1b38171ddb1f017faae9205f5b1c6dfb0fb4207767271')
 
             for path in ["hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz", "hardlink_0.2.1_amd64.changes", "hardlink_0.2.1_amd64.deb"]:
                 path = os.path.join(self.tempSrcDir, "01", path)
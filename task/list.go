@@ -51,13 +51,24 @@ func (list *List) consumer() {
 			list.Unlock()
 
 			go func() {
-				retValue, err := task.process(aptly.Progress(task.output), task.detail)
+				var (
+					retValue *ProcessReturnValue
+					err      error
+				)
+
+				// task might have been canceled while waiting in the queue
+				if err = task.detail.Context().Err(); err == nil {
+					retValue, err = task.process(aptly.Progress(task.output), task.detail)
+				}
 
 				list.Lock()
 				{
 					task.processReturnValue = retValue
 					task.err = err
-					if err != nil {
+					if err != nil && task.detail.Context().Err() != nil {
+						task.output.Printf("Task canceled: %v", err)
+						task.State = CANCELED
+					} else if err != nil {
 						task.output.Printf("Task failed with error: %v", err)
 						task.State = FAILED
 					} else {
@@ -65,22 +76,13 @@ func (list *List) consumer() {
 						task.State = SUCCEEDED
 					}
 
+					task.cancel()
 					list.usedResources.Free(task.resources)
 
 					task.wgTask.Done()
 					list.wg.Done()
 
-					for _, t := range list.tasks {
-						if t.State == IDLE {
-							// check resources
-							blockingTasks := list.usedResources.UsedBy(t.resources)
-							if len(blockingTasks) == 0 {
-								list.usedResources.MarkInUse(task.resources, task)
-								list.queue <- t
-								break
-							}
-						}
-					}
+					list.queueNext()
 				}
 				list.Unlock()
 			}()
@@ -91,6 +93,24 @@ func (list *List) consumer() {
 	}
 }
 
+// queueNext queues first idle task which has all the resources available
+//
+// list should be locked by the caller
+func (list *List) queueNext() {
+	for _, t := range list.tasks {
+		if t.State == IDLE {
+			// check resources
+			blockingTasks := list.usedResources.UsedBy(t.resources)
+			if len(blockingTasks) == 0 {
+				list.usedResources.MarkInUse(t.resources, t)
+				t.queued = true
+				list.queue <- t
+				break
+			}
+		}
+	}
+}
+
 // Stop signals the consumer to stop processing tasks and waits for it to finish
 func (list *List) Stop() {
 	close(list.queueDone)
@@ -118,7 +138,7 @@ func (list *List) DeleteTaskByID(ID int) (Task, error) {
 	tasks := list.tasks
 	for i, task := range tasks {
 		if task.ID == ID {
-			if task.State == SUCCEEDED || task.State == FAILED {
+			if task.Finished() {
 				list.tasks = append(tasks[:i], tasks[i+1:]...)
 				return *task, nil
 			}
@@ -130,6 +150,40 @@ func (list *List) DeleteTaskByID(ID int) (Task, error) {
 	return Task{}, fmt.Errorf("Could not find task with id %v", ID)
 }
 
+// CancelTaskByID requests cancellation of the task with given id.
+//
+// Idle task is canceled right away, running task is notified and finishes
+// as soon as process handles cancellation.
+func (list *List) CancelTaskByID(ID int) (Task, error) {
+	list.Lock()
+	defer list.Unlock()
+
+	for _, task := range list.tasks {
+		if task.ID == ID {
+			switch task.State {
+			case IDLE:
+				task.cancel()
+				if !task.queued {
+					// task is waiting for resources, it would never be started
+					task.output.Print("Task canceled")
+					task.State = CANCELED
+
+					task.wgTask.Done()
+					list.wg.Done()
+				}
+			case RUNNING:
+				task.cancel()
+			default:
+				return *task, fmt.Errorf("Task with id %v is already finished with state=%d", ID, task.State)
+			}
+
+			return *task, nil
+		}
+	}
+
+	return Task{}, fmt.Errorf("Could not find task with id %v", ID)
+}
+
 // GetTaskByID returns task with given id
 func (list *List) GetTaskByID(ID int) (Task, error) {
 	list.Lock()
@@ -204,6 +258,7 @@ func (list *List) RunTaskInBackground(name string, resources []string, process P
 	tasks := list.usedResources.UsedBy(resources)
 	if len(tasks) == 0 {
 		list.usedResources.MarkInUse(task.resources, task)
+		task.queued = true
 		list.queue <- task
 	}
 
@@ -50,5 +50,43 @@ func (s *ListSuite) TestList(c *check.C) {
 	c.Check(detail, check.Equals, "Details")
 	_, deleteErr := list.DeleteTaskByID(task.ID)
 	c.Check(deleteErr, check.IsNil)
-        list.Stop()
+	list.Stop()
+}
+
+func (s *ListSuite) TestCancel(c *check.C) {
+	list := NewList()
+	defer list.Stop()
+
+	started := make(chan bool)
+	running, _ := list.RunTaskInBackground("Long task", []string{"repo"}, func(out aptly.Progress, detail *Detail) (*ProcessReturnValue, error) {
+		started <- true
+		<-detail.Context().Done()
+		return nil, detail.Context().Err()
+	})
+	<-started
+
+	waiting, _ := list.RunTaskInBackground("Waiting task", []string{"repo"}, func(out aptly.Progress, detail *Detail) (*ProcessReturnValue, error) {
+		return nil, nil
+	})
+
+	task, err := list.CancelTaskByID(waiting.ID)
+	c.Assert(err, check.IsNil)
+	c.Check(task.State, check.Equals, CANCELED)
+
+	_, err = list.CancelTaskByID(running.ID)
+	c.Assert(err, check.IsNil)
+
+	task, _ = list.WaitForTaskByID(running.ID)
+	c.Check(task.State, check.Equals, CANCELED)
+	output, _ := list.GetTaskOutputByID(running.ID)
+	c.Check(output, check.Equals, "Task canceled: context canceled")
+
+	_, err = list.CancelTaskByID(running.ID)
+	c.Check(err, check.ErrorMatches, "Task with id .* is already finished.*")
+	_, err = list.CancelTaskByID(42)
+	c.Check(err, check.ErrorMatches, "Could not find task with id 42")
+
+	list.Wait()
+	_, err = list.DeleteTaskByID(waiting.ID)
+	c.Check(err, check.IsNil)
 }
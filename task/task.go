@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 
@@ -13,6 +14,18 @@ type State int
 // Detail represents custom task details
 type Detail struct {
 	atomic.Value
+	ctx context.Context
+}
+
+// Context returns context which is canceled when task cancellation is requested
+//
+// Cancellation is cooperative: long-running processes should watch Context().Done()
+// and return as soon as possible.
+func (d *Detail) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
 }
 
 // PublishDetail represents publish task details
@@ -39,6 +52,8 @@ const (
 	SUCCEEDED
 	// FAILED when task failed
 	FAILED
+	// CANCELED when task has been canceled before completion
+	CANCELED
 )
 
 // Task represents as task in a queue encapsulates process code
@@ -53,13 +68,18 @@ type Task struct {
 	State              State
 	resources          []string
 	wgTask             *sync.WaitGroup
+	cancel             context.CancelFunc
+	queued             bool
 }
 
 // NewTask creates new task
 func NewTask(process Process, name string, ID int, resources []string, wgTask *sync.WaitGroup) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	task := &Task{
 		output:    NewOutput(),
-		detail:    &Detail{},
+		detail:    &Detail{ctx: ctx},
+		cancel:    cancel,
 		process:   process,
 		Name:      name,
 		ID:        ID,
@@ -69,3 +89,8 @@ func NewTask(process Process, name string, ID int, resources []string, wgTask *s
 	}
 	return task
 }
+
+// Finished returns true if task is not going to run anymore
+func (t *Task) Finished() bool {
+	return t.State == SUCCEEDED || t.State == FAILED || t.State == CANCELED
+}
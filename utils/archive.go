@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// VolumeWriter splits stream into sequence of files (volumes) of limited size
+//
+// Volumes are named <base>.001, <base>.002 and so on, original stream could be
+// restored by concatenating volumes; if volume size is zero, stream is written
+// to single file <base>.
+type VolumeWriter struct {
+	base       string
+	volumeSize int64
+	current    *os.File
+	written    int64
+	files      []string
+}
+
+// NewVolumeWriter creates VolumeWriter
+func NewVolumeWriter(base string, volumeSize int64) *VolumeWriter {
+	return &VolumeWriter{base: base, volumeSize: volumeSize}
+}
+
+func (w *VolumeWriter) nextVolume() error {
+	if w.current != nil {
+		if err := w.current.Close(); err != nil {
+			return err
+		}
+	}
+
+	name := w.base
+	if w.volumeSize > 0 {
+		name = fmt.Sprintf("%s.%03d", w.base, len(w.files)+1)
+	}
+
+	var err error
+	w.current, err = os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	w.written = 0
+	w.files = append(w.files, name)
+
+	return nil
+}
+
+// Write implements io.Writer
+func (w *VolumeWriter) Write(p []byte) (n int, err error) {
+	if w.current == nil {
+		if err = w.nextVolume(); err != nil {
+			return
+		}
+	}
+
+	for len(p) > 0 {
+		chunk := p
+		if w.volumeSize > 0 {
+			if w.written == w.volumeSize {
+				if err = w.nextVolume(); err != nil {
+					return
+				}
+			}
+
+			if int64(len(chunk)) > w.volumeSize-w.written {
+				chunk = chunk[:w.volumeSize-w.written]
+			}
+		}
+
+		var written int
+		written, err = w.current.Write(chunk)
+		n += written
+		w.written += int64(written)
+		if err != nil {
+			return
+		}
+
+		p = p[written:]
+	}
+
+	return
+}
+
+// Close closes last volume
+func (w *VolumeWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+
+	return w.current.Close()
+}
+
+// Files returns list of written volumes
+func (w *VolumeWriter) Files() []string {
+	return w.files
+}
+
+// TarDirectory writes contents of directory root to w as tar archive, paths in archive
+// are relative to root
+func TarDirectory(root string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ArchiveSuite struct {
+	tempDir string
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+func (s *ArchiveSuite) SetUpTest(c *C) {
+	s.tempDir = c.MkDir()
+}
+
+func (s *ArchiveSuite) TestVolumeWriterSingle(c *C) {
+	w := NewVolumeWriter(filepath.Join(s.tempDir, "export.tar"), 0)
+	_, err := w.Write([]byte("hello, "))
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("world"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	c.Check(w.Files(), DeepEquals, []string{filepath.Join(s.tempDir, "export.tar")})
+
+	content, err := os.ReadFile(w.Files()[0])
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "hello, world")
+}
+
+func (s *ArchiveSuite) TestVolumeWriterSplit(c *C) {
+	w := NewVolumeWriter(filepath.Join(s.tempDir, "export.tar"), 5)
+	n, err := w.Write([]byte("hello, wo"))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 9)
+	_, err = w.Write([]byte("rld"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	c.Check(w.Files(), DeepEquals, []string{
+		filepath.Join(s.tempDir, "export.tar.001"),
+		filepath.Join(s.tempDir, "export.tar.002"),
+		filepath.Join(s.tempDir, "export.tar.003"),
+	})
+
+	var joined []byte
+	for i, expected := range []string{"hello", ", wor", "ld"} {
+		content, err := os.ReadFile(w.Files()[i])
+		c.Assert(err, IsNil)
+		c.Check(string(content), Equals, expected)
+		joined = append(joined, content...)
+	}
+	c.Check(string(joined), Equals, "hello, world")
+}
+
+func (s *ArchiveSuite) TestTarDirectory(c *C) {
+	root := filepath.Join(s.tempDir, "root")
+	c.Assert(os.MkdirAll(filepath.Join(root, "dists", "stable"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "dists", "stable", "Release"), []byte("Origin: aptly\n"), 0644), IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(TarDirectory(root, &buf), IsNil)
+
+	names := []string{}
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		names = append(names, header.Name)
+
+		if header.Name == "dists/stable/Release" {
+			content, err := io.ReadAll(tr)
+			c.Assert(err, IsNil)
+			c.Check(string(content), Equals, "Origin: aptly\n")
+		}
+	}
+
+	c.Check(names, DeepEquals, []string{"dists/", "dists/stable/", "dists/stable/Release"})
+}
@@ -9,6 +9,9 @@ import (
 	"hash"
 	"io"
 	"os"
+	"runtime"
+	"sort"
+	"sync"
 )
 
 // MD5ChecksumForFile computes just the MD5 hash and not all the others
@@ -42,6 +45,24 @@ func (cksum *ChecksumInfo) Complete() bool {
 	return cksum.MD5 != "" && cksum.SHA1 != "" && cksum.SHA256 != "" && cksum.SHA512 != ""
 }
 
+// Verify checks that actual checksums match size and all the checksums set in cksum
+func (cksum *ChecksumInfo) Verify(actual ChecksumInfo) error {
+	switch {
+	case actual.Size != cksum.Size:
+		return fmt.Errorf("size check mismatch %d != %d", actual.Size, cksum.Size)
+	case cksum.MD5 != "" && actual.MD5 != cksum.MD5:
+		return fmt.Errorf("md5 hash mismatch %#v != %#v", actual.MD5, cksum.MD5)
+	case cksum.SHA1 != "" && actual.SHA1 != cksum.SHA1:
+		return fmt.Errorf("sha1 hash mismatch %#v != %#v", actual.SHA1, cksum.SHA1)
+	case cksum.SHA256 != "" && actual.SHA256 != cksum.SHA256:
+		return fmt.Errorf("sha256 hash mismatch %#v != %#v", actual.SHA256, cksum.SHA256)
+	case cksum.SHA512 != "" && actual.SHA512 != cksum.SHA512:
+		return fmt.Errorf("sha512 hash mismatch %#v != %#v", actual.SHA512, cksum.SHA512)
+	}
+
+	return nil
+}
+
 // ChecksumsForReader generates size, MD5, SHA1 & SHA256 checksums for the given
 // io.Reader
 func ChecksumsForReader(rd io.Reader) (ChecksumInfo, error) {
@@ -67,11 +88,62 @@ func ChecksumsForFile(path string) (ChecksumInfo, error) {
 	return ChecksumsForReader(file)
 }
 
+// ChecksumsForFiles generates checksums for multiple files in parallel, each file is
+// read once with all the checksums computed in the same pass
+//
+// Up to workers files are processed at the same time (GOMAXPROCS if workers is not
+// positive). Files are scheduled largest first, so that big files don't end up being
+// processed last while other workers are idle. Results (and errors) are returned in
+// the order of paths, errs[i] is not nil if checksums for paths[i] can't be computed.
+func ChecksumsForFiles(paths []string, workers int) (result []ChecksumInfo, errs []error) {
+	result = make([]ChecksumInfo, len(paths))
+	errs = make([]error, len(paths))
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	sizes := make([]int64, len(paths))
+	order := make([]int, len(paths))
+	for i, path := range paths {
+		order[i] = i
+		if info, err := os.Stat(path); err == nil {
+			sizes[i] = info.Size()
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return sizes[order[i]] > sizes[order[j]] })
+
+	queue := make(chan int, len(paths))
+	for _, i := range order {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				result[i], errs[i] = ChecksumsForFile(paths[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return
+}
+
 // ChecksumWriter is a writer that does checksum calculation on the fly passing data
 // to real writer
 type ChecksumWriter struct {
 	sum    ChecksumInfo
 	hashes []hash.Hash
+	w      io.Writer
 }
 
 // Interface check
@@ -81,8 +153,15 @@ var (
 
 // NewChecksumWriter creates checksum calculator for given writer w
 func NewChecksumWriter() *ChecksumWriter {
+	hashes := []hash.Hash{md5.New(), sha1.New(), sha256.New(), sha512.New()}
+	writers := make([]io.Writer, len(hashes))
+	for i := range hashes {
+		writers[i] = hashes[i]
+	}
+
 	return &ChecksumWriter{
-		hashes: []hash.Hash{md5.New(), sha1.New(), sha256.New(), sha512.New()},
+		hashes: hashes,
+		w:      io.MultiWriter(writers...),
 	}
 }
 
@@ -90,9 +169,8 @@ func NewChecksumWriter() *ChecksumWriter {
 func (c *ChecksumWriter) Write(p []byte) (n int, err error) {
 	c.sum.Size += int64(len(p))
 
-	for _, h := range c.hashes {
-		h.Write(p)
-	}
+	// hash.Hash never returns an error
+	_, _ = c.w.Write(p)
 
 	return len(p), nil
 }
@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	. "gopkg.in/check.v1"
 )
@@ -31,9 +34,60 @@ func (s *ChecksumSuite) TestChecksumsForFile(c *C) {
 	c.Check(info.SHA256, Equals, "f2775692fd3b70bd0faa4054b7afa92d427bf994cd8629741710c4864ee4dc95")
 }
 
+func (s *ChecksumSuite) TestChecksumsForFiles(c *C) {
+	dir := c.MkDir()
+
+	paths := []string{s.tempfile.Name()}
+	for i, size := range []int{0, 1 << 20, 1000, 1 << 16} {
+		path := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		c.Assert(os.WriteFile(path, bytes.Repeat([]byte("a"), size), 0644), IsNil)
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(dir, "missing"))
+
+	for _, workers := range []int{0, 1, 3, 10} {
+		result, errs := ChecksumsForFiles(paths, workers)
+		c.Assert(result, HasLen, len(paths))
+		c.Assert(errs, HasLen, len(paths))
+
+		for i, path := range paths[:len(paths)-1] {
+			expected, err := ChecksumsForFile(path)
+			c.Assert(err, IsNil)
+			c.Check(errs[i], IsNil)
+			c.Check(result[i], DeepEquals, expected)
+		}
+
+		c.Check(result[0].SHA256, Equals, "f2775692fd3b70bd0faa4054b7afa92d427bf994cd8629741710c4864ee4dc95")
+		c.Check(result[2].Size, Equals, int64(1<<20))
+		c.Check(errs[len(paths)-1], ErrorMatches, ".*no such file or directory")
+	}
+
+	result, errs := ChecksumsForFiles(nil, 0)
+	c.Check(result, HasLen, 0)
+	c.Check(errs, HasLen, 0)
+}
+
 func (s *ChecksumSuite) TestMD5ChecksumForFile(c *C) {
 	md5sum, err := MD5ChecksumForFile(s.tempfile.Name())
 
 	c.Assert(err, IsNil)
 	c.Check(md5sum, Equals, "43470766afbfdca292440eecdceb80fb")
 }
+
+func (s *ChecksumSuite) TestVerify(c *C) {
+	actual, err := ChecksumsForFile(s.tempfile.Name())
+	c.Assert(err, IsNil)
+
+	expected := ChecksumInfo{Size: 83, SHA256: actual.SHA256}
+	c.Check(expected.Verify(actual), IsNil)
+	c.Check(actual.Verify(actual), IsNil)
+
+	expected = ChecksumInfo{Size: 84}
+	c.Check(expected.Verify(actual), ErrorMatches, "size check mismatch 83 != 84")
+
+	expected = ChecksumInfo{Size: 83, MD5: "00000000000000000000000000000000", SHA256: actual.SHA256}
+	c.Check(expected.Verify(actual), ErrorMatches, "md5 hash mismatch .*")
+
+	expected = ChecksumInfo{Size: 83, SHA1: actual.SHA1, SHA512: "abcd"}
+	c.Check(expected.Verify(actual), ErrorMatches, "sha512 hash mismatch .*")
+}
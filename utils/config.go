@@ -5,8 +5,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
 )
 
+// ConfigEnvPrefix is prefix of environment variables overriding configuration settings
+const ConfigEnvPrefix = "APTLY_"
+
 // ConfigStructure is structure of main configuration
 type ConfigStructure struct { // nolint: maligned
 	RootDir                string                           `json:"rootDir"`
@@ -24,6 +33,7 @@ type ConfigStructure struct { // nolint: maligned
 	GpgDisableSign         bool                             `json:"gpgDisableSign"`
 	GpgDisableVerify       bool                             `json:"gpgDisableVerify"`
 	GpgProvider            string                           `json:"gpgProvider"`
+	ExternalSigner         ExternalSignerConfig             `json:"externalSigner"`
 	DownloadSourcePackages bool                             `json:"downloadSourcePackages"`
 	PackagePoolStorage     PackagePoolStorage               `json:"packagePoolStorage"`
 	SkipLegacyPool         bool                             `json:"skipLegacyPool"`
@@ -35,11 +45,31 @@ type ConfigStructure struct { // nolint: maligned
 	S3PublishRoots         map[string]S3PublishRoot         `json:"S3PublishEndpoints"`
 	SwiftPublishRoots      map[string]SwiftPublishRoot      `json:"SwiftPublishEndpoints"`
 	AzurePublishRoots      map[string]AzureEndpoint         `json:"AzurePublishEndpoints"`
+	GCSPublishRoots        map[string]GCSPublishRoot        `json:"GCSPublishEndpoints"`
+	SFTPPublishRoots       map[string]SFTPPublishRoot       `json:"SFTPPublishEndpoints"`
+	Webhooks               []WebhookConfig                  `json:"webhooks"`
 	AsyncAPI               bool                             `json:"AsyncAPI"`
 	EnableMetricsEndpoint  bool                             `json:"enableMetricsEndpoint"`
 	LogLevel               string                           `json:"logLevel"`
 	LogFormat              string                           `json:"logFormat"`
 	ServeInAPIMode         bool                             `json:"serveInAPIMode"`
+	EnableAuditLog         bool                             `json:"enableAuditLog"`
+	AuditLogRetention      int                              `json:"auditLogRetention"`
+	APITokens              []APITokenConfig                 `json:"apiTokens"`
+	UploadExpiration       int                              `json:"uploadExpiration"`
+	SnapshotDeltaInterval  int                              `json:"snapshotDeltaInterval"`
+	MirrorSchedules        []MirrorScheduleConfig           `json:"mirrorSchedules"`
+	PackageCacheSize       int                              `json:"packageCacheSize"`
+	MirrorStaleAfter       int                              `json:"mirrorStaleAfter"`
+	DatabaseBackend        DBConfig                         `json:"databaseBackend"`
+}
+
+// DBConfig describes database backend used to keep metadata
+type DBConfig struct {
+	Type   string `json:"type"`
+	DbPath string `json:"dbPath"`
+	URL    string `json:"url"`
+	Prefix string `json:"prefix"`
 }
 
 type LocalPoolStorage struct {
@@ -140,6 +170,71 @@ type AzureEndpoint struct {
 	Endpoint    string `json:"endpoint"`
 }
 
+// GCSPublishRoot describes single Google Cloud Storage publishing entry point
+type GCSPublishRoot struct {
+	Bucket                   string `json:"bucket"`
+	Prefix                   string `json:"prefix"`
+	CredentialsFile          string `json:"credentialsFile"`
+	ACL                      string `json:"acl"`
+	UniformBucketLevelAccess bool   `json:"uniformBucketLevelAccess"`
+	IndexCacheControl        string `json:"indexCacheControl"`
+	PoolCacheControl         string `json:"poolCacheControl"`
+	Endpoint                 string `json:"endpoint"`
+}
+
+// SFTPPublishRoot describes single SFTP/SSH publishing entry point
+type SFTPPublishRoot struct {
+	Host                  string `json:"host"`
+	Port                  int    `json:"port"`
+	User                  string `json:"user"`
+	Password              string `json:"password"`
+	PrivateKeyFile        string `json:"privateKeyFile"`
+	KnownHostsFile        string `json:"knownHostsFile"`
+	InsecureIgnoreHostKey bool   `json:"insecureIgnoreHostKey"`
+	RootDir               string `json:"rootDir"`
+}
+
+// ExternalSignerConfig describes external signing service used with "external" gpg provider,
+// either URL or Command should be set
+type ExternalSignerConfig struct {
+	URL     string   `json:"url"`
+	Command []string `json:"command"`
+	Token   string   `json:"token"`
+	// Timeout in seconds
+	Timeout int `json:"timeout"`
+}
+
+// WebhookConfig describes single webhook notified about repository events
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// MirrorScheduleConfig describes recurring update of the mirror by API server
+type MirrorScheduleConfig struct {
+	Mirror string `json:"mirror"`
+	// Schedule is cron expression (minute, hour, day of month, month, day of week) in local time
+	Schedule string `json:"schedule"`
+	// Snapshot (if set) is name of snapshot created after update, {mirror} and {timestamp} are replaced
+	Snapshot string `json:"snapshot"`
+	// PublishPrefix and PublishDistribution (if set) identify published repository switched to the new snapshot
+	PublishPrefix       string `json:"publishPrefix"`
+	PublishDistribution string `json:"publishDistribution"`
+	PublishComponent    string `json:"publishComponent"`
+}
+
+// APITokenConfig describes token accepted by API server and permissions granted to it
+type APITokenConfig struct {
+	// Name identifies token holder, e.g. in the audit log
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Role is one of read-only, uploader, publisher, admin
+	Role string `json:"role"`
+	// PublishPrefixes (if set) limits changes of published repositories to matching prefixes
+	PublishPrefixes []string `json:"publishPrefixes"`
+}
+
 // Config is configuration for aptly, shared by all modules
 var Config = ConfigStructure{
 	RootDir:                filepath.Join(os.Getenv("HOME"), ".aptly"),
@@ -153,6 +248,7 @@ var Config = ConfigStructure{
 	DepFollowAllVariants:   false,
 	DepFollowSource:        false,
 	GpgProvider:            "gpg",
+	ExternalSigner:         ExternalSignerConfig{Command: []string{}},
 	GpgDisableSign:         false,
 	GpgDisableVerify:       false,
 	DownloadSourcePackages: false,
@@ -166,14 +262,26 @@ var Config = ConfigStructure{
 	S3PublishRoots:         map[string]S3PublishRoot{},
 	SwiftPublishRoots:      map[string]SwiftPublishRoot{},
 	AzurePublishRoots:      map[string]AzureEndpoint{},
+	GCSPublishRoots:        map[string]GCSPublishRoot{},
+	SFTPPublishRoots:       map[string]SFTPPublishRoot{},
+	Webhooks:               []WebhookConfig{},
 	AsyncAPI:               false,
 	EnableMetricsEndpoint:  false,
 	LogLevel:               "debug",
 	LogFormat:              "default",
 	ServeInAPIMode:         false,
+	EnableAuditLog:         false,
+	AuditLogRetention:      0,
+	APITokens:              []APITokenConfig{},
+	UploadExpiration:       0,
+	SnapshotDeltaInterval:  0,
+	MirrorSchedules:        []MirrorScheduleConfig{},
+	PackageCacheSize:       0,
+	MirrorStaleAfter:       0,
+	DatabaseBackend:        DBConfig{},
 }
 
-// LoadConfig loads configuration from json file
+// LoadConfig loads configuration from json file (or YAML file, if file has .yaml/.yml extension)
 func LoadConfig(filename string, config *ConfigStructure) error {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -181,10 +289,104 @@ func LoadConfig(filename string, config *ConfigStructure) error {
 	}
 	defer f.Close()
 
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".yaml" || ext == ".yml" {
+		var document interface{}
+
+		err = yaml.NewDecoder(f).Decode(&document)
+		if err != nil {
+			return err
+		}
+
+		// YAML document is re-encoded as JSON, so that the same field names apply to both formats
+		var encoded []byte
+		encoded, err = json.Marshal(document)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(encoded, &config)
+	}
+
 	dec := json.NewDecoder(f)
 	return dec.Decode(&config)
 }
 
+// ConfigEnvName returns name of environment variable overriding configuration setting
+// with JSON name key, e.g. rootDir -> APTLY_ROOT_DIR
+func ConfigEnvName(key string) string {
+	runes := []rune(key)
+	result := []rune(ConfigEnvPrefix)
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				result = append(result, '_')
+			}
+		}
+		result = append(result, unicode.ToUpper(r))
+	}
+
+	return string(result)
+}
+
+// LoadConfigEnvironment overrides top-level scalar configuration settings (strings, numbers,
+// booleans and comma-separated lists) with values of environment variables
+//
+// lookupEnv is usually os.LookupEnv
+func LoadConfigEnvironment(config *ConfigStructure, lookupEnv func(string) (string, bool)) error {
+	value := reflect.ValueOf(config).Elem()
+	typ := value.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		key := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name := ConfigEnvName(key)
+		envValue, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		field := value.Field(i)
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(envValue)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(envValue)
+			if err != nil {
+				return fmt.Errorf("error parsing environment variable %s: %s", name, err)
+			}
+			field.SetBool(parsed)
+		case reflect.Int, reflect.Int64:
+			parsed, err := strconv.ParseInt(envValue, 10, 64)
+			if err != nil {
+				return fmt.Errorf("error parsing environment variable %s: %s", name, err)
+			}
+			field.SetInt(parsed)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+
+			list := []string{}
+			for _, item := range strings.Split(envValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		}
+	}
+
+	return nil
+}
+
 // SaveConfig write configuration to json file
 func SaveConfig(filename string, config *ConfigStructure) error {
 	f, err := os.Create(filename)
@@ -26,6 +26,56 @@ func (s *ConfigSuite) TestLoadConfig(c *C) {
 	c.Check(s.config.DatabaseOpenAttempts, Equals, 33)
 }
 
+func (s *ConfigSuite) TestLoadConfigYAML(c *C) {
+	configname := filepath.Join(c.MkDir(), "aptly.yaml")
+	f, _ := os.Create(configname)
+	f.WriteString(configFileYAML)
+	f.Close()
+
+	var config ConfigStructure
+	err := LoadConfig(configname, &config)
+	c.Assert(err, IsNil)
+	c.Check(config.RootDir, Equals, "/opt/aptly/")
+	c.Check(config.DownloadConcurrency, Equals, 33)
+	c.Check(config.Architectures, DeepEquals, []string{"amd64", "arm64"})
+	c.Check(config.S3PublishRoots["test"].Bucket, Equals, "repo")
+	c.Check(config.PackagePoolStorage.Local.Path, Equals, "/opt/aptly-pool")
+}
+
+func (s *ConfigSuite) TestConfigEnvName(c *C) {
+	c.Check(ConfigEnvName("rootDir"), Equals, "APTLY_ROOT_DIR")
+	c.Check(ConfigEnvName("downloadSpeedLimit"), Equals, "APTLY_DOWNLOAD_SPEED_LIMIT")
+	c.Check(ConfigEnvName("ppaDistributorID"), Equals, "APTLY_PPA_DISTRIBUTOR_ID")
+	c.Check(ConfigEnvName("AsyncAPI"), Equals, "APTLY_ASYNC_API")
+}
+
+func (s *ConfigSuite) TestLoadConfigEnvironment(c *C) {
+	env := map[string]string{
+		"APTLY_ROOT_DIR":             "/srv/aptly",
+		"APTLY_DOWNLOAD_CONCURRENCY": "8",
+		"APTLY_ARCHITECTURES":        "amd64, i386",
+		"APTLY_GPG_DISABLE_SIGN":     "true",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	config := ConfigStructure{GpgProvider: "internal"}
+
+	err := LoadConfigEnvironment(&config, lookupEnv)
+	c.Assert(err, IsNil)
+	c.Check(config.RootDir, Equals, "/srv/aptly")
+	c.Check(config.DownloadConcurrency, Equals, 8)
+	c.Check(config.Architectures, DeepEquals, []string{"amd64", "i386"})
+	c.Check(config.GpgDisableSign, Equals, true)
+	c.Check(config.GpgProvider, Equals, "internal")
+
+	env["APTLY_DOWNLOAD_CONCURRENCY"] = "many"
+	err = LoadConfigEnvironment(&config, lookupEnv)
+	c.Check(err, ErrorMatches, "error parsing environment variable APTLY_DOWNLOAD_CONCURRENCY: .*")
+}
+
 func (s *ConfigSuite) TestSaveConfig(c *C) {
 	configname := filepath.Join(c.MkDir(), "aptly.json")
 
@@ -43,12 +93,33 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		Region: "us-east-1",
 		Bucket: "repo"}}
 
+	s.config.SFTPPublishRoots = map[string]SFTPPublishRoot{"test": {
+		Host: "repo.example.com", RootDir: "/srv/www"}}
+
+	s.config.Webhooks = []WebhookConfig{{
+		URL: "https://ci.example.com/hooks/aptly", Events: []string{"publish-completed"}}}
+
 	s.config.SwiftPublishRoots = map[string]SwiftPublishRoot{"test": {
 		Container: "repo"}}
 
 	s.config.AzurePublishRoots = map[string]AzureEndpoint{"test": {
 		Container: "repo"}}
 
+	s.config.GCSPublishRoots = map[string]GCSPublishRoot{"test": {
+		Bucket: "repo"}}
+
+	s.config.SFTPPublishRoots = map[string]SFTPPublishRoot{"test": {
+		Host: "repo.example.com", RootDir: "/srv/www"}}
+
+	s.config.Webhooks = []WebhookConfig{{
+		URL: "https://ci.example.com/hooks/aptly", Events: []string{"publish-completed"}}}
+
+	s.config.MirrorSchedules = []MirrorScheduleConfig{{
+		Mirror: "debian", Schedule: "@daily", Snapshot: "{mirror}-{timestamp}"}}
+
+	s.config.ExternalSigner = ExternalSignerConfig{
+		URL: "https://sign.example.com/sign", Timeout: 30}
+
 	s.config.LogLevel = "info"
 	s.config.LogFormat = "json"
 
@@ -79,6 +150,12 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"  \"gpgDisableSign\": false,\n"+
 		"  \"gpgDisableVerify\": false,\n"+
 		"  \"gpgProvider\": \"gpg\",\n"+
+		"  \"externalSigner\": {\n"+
+		"    \"url\": \"https://sign.example.com/sign\",\n"+
+		"    \"command\": null,\n"+
+		"    \"token\": \"\",\n"+
+		"    \"timeout\": 30\n"+
+		"  },\n"+
 		"  \"downloadSourcePackages\": false,\n"+
 		"  \"packagePoolStorage\": {\n"+
 		"    \"type\": \"local\",\n"+
@@ -139,12 +216,83 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"      \"endpoint\": \"\"\n"+
 		"    }\n"+
 		"  },\n"+
+		"  \"GCSPublishEndpoints\": {\n"+
+		"    \"test\": {\n"+
+		"      \"bucket\": \"repo\",\n"+
+		"      \"prefix\": \"\",\n"+
+		"      \"credentialsFile\": \"\",\n"+
+		"      \"acl\": \"\",\n"+
+		"      \"uniformBucketLevelAccess\": false,\n"+
+		"      \"indexCacheControl\": \"\",\n"+
+		"      \"poolCacheControl\": \"\",\n"+
+		"      \"endpoint\": \"\"\n"+
+		"    }\n"+
+		"  },\n"+
+		"  \"SFTPPublishEndpoints\": {\n"+
+		"    \"test\": {\n"+
+		"      \"host\": \"repo.example.com\",\n"+
+		"      \"port\": 0,\n"+
+		"      \"user\": \"\",\n"+
+		"      \"password\": \"\",\n"+
+		"      \"privateKeyFile\": \"\",\n"+
+		"      \"knownHostsFile\": \"\",\n"+
+		"      \"insecureIgnoreHostKey\": false,\n"+
+		"      \"rootDir\": \"/srv/www\"\n"+
+		"    }\n"+
+		"  },\n"+
+		"  \"webhooks\": [\n"+
+		"    {\n"+
+		"      \"url\": \"https://ci.example.com/hooks/aptly\",\n"+
+		"      \"events\": [\n"+
+		"        \"publish-completed\"\n"+
+		"      ],\n"+
+		"      \"secret\": \"\"\n"+
+		"    }\n"+
+		"  ],\n"+
 		"  \"AsyncAPI\": false,\n"+
 		"  \"enableMetricsEndpoint\": false,\n"+
 		"  \"logLevel\": \"info\",\n"+
 		"  \"logFormat\": \"json\",\n"+
-		"  \"serveInAPIMode\": false\n"+
+		"  \"serveInAPIMode\": false,\n"+
+		"  \"enableAuditLog\": false,\n"+
+		"  \"auditLogRetention\": 0,\n"+
+		"  \"apiTokens\": null,\n"+
+		"  \"uploadExpiration\": 0,\n"+
+		"  \"snapshotDeltaInterval\": 0,\n"+
+		"  \"mirrorSchedules\": [\n"+
+		"    {\n"+
+		"      \"mirror\": \"debian\",\n"+
+		"      \"schedule\": \"@daily\",\n"+
+		"      \"snapshot\": \"{mirror}-{timestamp}\",\n"+
+		"      \"publishPrefix\": \"\",\n"+
+		"      \"publishDistribution\": \"\",\n"+
+		"      \"publishComponent\": \"\"\n"+
+		"    }\n"+
+		"  ],\n"+
+		"  \"packageCacheSize\": 0,\n"+
+		"  \"mirrorStaleAfter\": 0,\n"+
+		"  \"databaseBackend\": {\n"+
+		"    \"type\": \"\",\n"+
+		"    \"dbPath\": \"\",\n"+
+		"    \"url\": \"\",\n"+
+		"    \"prefix\": \"\"\n"+
+		"  }\n"+
 		"}")
 }
 
 const configFile = `{"rootDir": "/opt/aptly/", "downloadConcurrency": 33, "databaseOpenAttempts": 33}`
+
+const configFileYAML = `
+rootDir: /opt/aptly/
+downloadConcurrency: 33
+architectures:
+  - amd64
+  - arm64
+packagePoolStorage:
+  type: local
+  path: /opt/aptly-pool
+S3PublishEndpoints:
+  test:
+    region: us-east-1
+    bucket: repo
+`
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 // HumanBytes converts bytes to human readable string
@@ -20,3 +23,45 @@ func HumanBytes(i int64) (result string) {
 	}
 	return
 }
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1024,
+	"kb":  1000,
+	"kib": 1024,
+	"m":   1024 * 1024,
+	"mb":  1000 * 1000,
+	"mib": 1024 * 1024,
+	"g":   1024 * 1024 * 1024,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1024 * 1024 * 1024,
+	"t":   1024 * 1024 * 1024 * 1024,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseHumanBytes converts human readable size (e.g. 100MB, 1.5GiB) to bytes
+//
+// Suffixes KB, MB, GB, TB are decimal, KiB, MiB, GiB, TiB (and single letters
+// K, M, G, T) are binary, suffix is case-insensitive.
+func ParseHumanBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	pos := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	if pos == -1 {
+		pos = len(s)
+	}
+
+	multiplier, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[pos:]))]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit: %s", s)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(s[:pos]), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("unable to parse size: %s", s)
+	}
+
+	return int64(value * multiplier), nil
+}
@@ -19,3 +19,37 @@ func (s *HumanSuite) TestHumanBytes(c *C) {
 	c.Check(HumanBytes(824000000480), Equals, "0.75 TiB")
 	c.Check(HumanBytes(824000000000480), Equals, "749.42 TiB")
 }
+
+func (s *HumanSuite) TestParseHumanBytes(c *C) {
+	for _, t := range []struct {
+		input    string
+		expected int64
+	}{
+		{"50", 50},
+		{"50B", 50},
+		{"2K", 2048},
+		{"2KB", 2000},
+		{"2KiB", 2048},
+		{"100MB", 100000000},
+		{"100 mib", 104857600},
+		{"1.5GiB", 1610612736},
+		{"1G", 1073741824},
+		{"2TB", 2000000000000},
+	} {
+		size, err := ParseHumanBytes(t.input)
+		c.Check(err, IsNil, Commentf("input: %s", t.input))
+		c.Check(size, Equals, t.expected, Commentf("input: %s", t.input))
+	}
+
+	_, err := ParseHumanBytes("100XB")
+	c.Check(err, ErrorMatches, "unknown size unit: 100XB")
+
+	_, err = ParseHumanBytes("lots")
+	c.Check(err, ErrorMatches, "unknown size unit: lots")
+
+	_, err = ParseHumanBytes("MB")
+	c.Check(err, ErrorMatches, "unable to parse size: MB")
+
+	_, err = ParseHumanBytes("-5MB")
+	c.Check(err, ErrorMatches, "unable to parse size: -5MB")
+}
@@ -0,0 +1,134 @@
+// Package webhook notifies external services (chat bots, deployment pipelines) about changes to repositories
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Events which are reported to webhooks
+const (
+	EventMirrorUpdated    = "mirror-updated"
+	EventSnapshotCreated  = "snapshot-created"
+	EventPublishCompleted = "publish-completed"
+	EventPublishFailed    = "publish-failed"
+)
+
+// Events is a list of all known events
+var Events = []string{EventMirrorUpdated, EventSnapshotCreated, EventPublishCompleted, EventPublishFailed}
+
+// Payload is JSON document POSTed to webhook URL
+type Payload struct {
+	Event     string                 `json:"event"`
+	Timestamp time.Time              `json:"timestamp"`
+	Error     string                 `json:"error,omitempty"`
+	Entities  map[string]interface{} `json:"entities"`
+}
+
+// Notifier delivers events to configured webhooks
+type Notifier struct {
+	hooks  []utils.WebhookConfig
+	client *http.Client
+}
+
+// NewNotifier creates new Notifier for the list of webhooks
+func NewNotifier(hooks []utils.WebhookConfig) *Notifier {
+	return &Notifier{
+		hooks:  hooks,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// wants checks whether webhook is subscribed to the event, empty list of events means all events
+func wants(hook utils.WebhookConfig, event string) bool {
+	return len(hook.Events) == 0 || utils.StrSliceHasItem(hook.Events, event)
+}
+
+// Notify delivers event to all webhooks subscribed to it
+//
+// Failure to deliver to one webhook doesn't prevent delivery to others, all
+// the failures are reported in returned error.
+func (n *Notifier) Notify(event string, entities map[string]interface{}, eventErr error) error {
+	if entities == nil {
+		entities = map[string]interface{}{}
+	}
+
+	payload := Payload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Entities:  entities,
+	}
+	if eventErr != nil {
+		payload.Error = eventErr.Error()
+	}
+
+	var body []byte
+	errors := []string{}
+
+	for _, hook := range n.hooks {
+		if !wants(hook, event) {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			body, err = json.Marshal(payload)
+			if err != nil {
+				return fmt.Errorf("unable to encode webhook payload: %s", err)
+			}
+		}
+
+		err := n.deliver(hook, event, body)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", hook.URL, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("unable to deliver %s event:\n  %s", event, strings.Join(errors, "\n  "))
+	}
+
+	return nil
+}
+
+func (n *Notifier) deliver(hook utils.WebhookConfig, event string, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("aptly/%s", aptly.Version))
+	req.Header.Set("X-Aptly-Event", event)
+
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Aptly-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type request struct {
+	path      string
+	event     string
+	signature string
+	body      []byte
+}
+
+type NotifierSuite struct {
+	srv      *httptest.Server
+	requests []request
+}
+
+var _ = Suite(&NotifierSuite{})
+
+func (s *NotifierSuite) SetUpTest(c *C) {
+	s.requests = nil
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.requests = append(s.requests, request{
+			path:      r.URL.Path,
+			event:     r.Header.Get("X-Aptly-Event"),
+			signature: r.Header.Get("X-Aptly-Signature"),
+			body:      body,
+		})
+
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+}
+
+func (s *NotifierSuite) TearDownTest(c *C) {
+	s.srv.Close()
+}
+
+func (s *NotifierSuite) TestNotify(c *C) {
+	n := NewNotifier([]utils.WebhookConfig{
+		{URL: s.srv.URL + "/all"},
+		{URL: s.srv.URL + "/publish", Events: []string{EventPublishCompleted, EventPublishFailed}, Secret: "s3cr3t"},
+	})
+
+	err := n.Notify(EventSnapshotCreated, map[string]interface{}{"snapshot": "snap1"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.requests, HasLen, 1)
+	c.Check(s.requests[0].path, Equals, "/all")
+	c.Check(s.requests[0].event, Equals, EventSnapshotCreated)
+	c.Check(s.requests[0].signature, Equals, "")
+
+	var payload Payload
+	c.Assert(json.Unmarshal(s.requests[0].body, &payload), IsNil)
+	c.Check(payload.Event, Equals, EventSnapshotCreated)
+	c.Check(payload.Entities, DeepEquals, map[string]interface{}{"snapshot": "snap1"})
+	c.Check(payload.Error, Equals, "")
+
+	err = n.Notify(EventPublishFailed, nil, errors.New("unable to publish"))
+	c.Assert(err, IsNil)
+	c.Assert(s.requests, HasLen, 3)
+	c.Check(s.requests[2].path, Equals, "/publish")
+
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(s.requests[2].body)
+	c.Check(s.requests[2].signature, Equals, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	c.Assert(json.Unmarshal(s.requests[2].body, &payload), IsNil)
+	c.Check(payload.Event, Equals, EventPublishFailed)
+	c.Check(payload.Error, Equals, "unable to publish")
+}
+
+func (s *NotifierSuite) TestNotifyErrors(c *C) {
+	n := NewNotifier([]utils.WebhookConfig{
+		{URL: s.srv.URL + "/broken"},
+		{URL: s.srv.URL + "/all"},
+	})
+
+	err := n.Notify(EventMirrorUpdated, nil, nil)
+	c.Check(err, ErrorMatches, "(?s)unable to deliver mirror-updated event:.*/broken: HTTP code 500")
+
+	// delivery to other webhooks is not affected
+	c.Check(s.requests, HasLen, 2)
+}